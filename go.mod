@@ -1,16 +1,24 @@
 module jarvis
 
-go 1.23
+go 1.23.0
 
 require github.com/stretchr/testify v1.10.0 // indirect
 
 require (
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
+	github.com/tklauser/go-sysconf v0.3.12 // indirect
+	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
 
 require (
 	github.com/mark3labs/mcp-go v0.32.0
+	github.com/shirou/gopsutil/v4 v4.25.1
 	github.com/spf13/cast v1.7.1 // indirect
+	golang.org/x/crypto v0.31.0
+	golang.org/x/image v0.25.0
+	golang.org/x/text v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvis/internal/common"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// approvedOperationKey marks the context of a call made by
+// approve_operation, so it runs instead of asking for approval again
+type approvedOperationKey struct{}
+
+// approvalHandlers are the tools that can ask for approval, which
+// approve_operation runs once approved
+var approvalHandlers = map[string]server.ToolHandlerFunc{
+	"execute_command":        HandleExecuteCommand,
+	"execute_commands":       HandleExecuteCommands,
+	"run_shell_script":       HandleRunShellScript,
+	"run_template":           HandleRunTemplate,
+	"run_pipeline":           HandleRunPipeline,
+	"start_command":          HandleStartCommand,
+	"write_session_input":    HandleWriteSessionInput,
+	"watch_command":          HandleWatchCommand,
+	"watch_and_run":          HandleWatchAndRun,
+	"schedule_job":           HandleScheduleJob,
+	"ssh_execute_command":    HandleSSHExecuteCommand,
+	"delete_file":            HandleDeleteFile,
+	"kill_process":           HandleKillProcess,
+	"kill_process_tree":      HandleKillProcessTree,
+	"reset_session_quotas":   HandleResetSessionQuotas,
+	"grant_temporary_access": HandleGrantTemporaryAccess,
+}
+
+// requireApproval returns a pending-approval result when the call has not
+// been approved yet, or nil when it may go ahead. The token is written to
+// the server log for the user rather than returned, so the client that
+// asked cannot approve its own operation.
+func requireApproval(ctx context.Context, req mcp.CallToolRequest, summary string) *mcp.CallToolResult {
+	if ctx.Value(approvedOperationKey{}) != nil {
+		return nil
+	}
+
+	pending, err := common.RequestApproval(req.Params.Name, summary, req.GetArguments())
+	if err != nil {
+		return toolErrorOf(err)
+	}
+	log.Printf("Approval required: %s; approve it with approve_operation token %s or discard it with deny_operation before %s",
+		summary, pending.Token, pending.ExpiresAt.Format(time.RFC3339))
+
+	return mcp.NewToolResultText(common.Localize(
+		"Approval required: %s\nNothing was done. The approval token was written to the server log; ask the user to approve the operation with it, or to discard it. The token expires at %s.",
+		summary, pending.ExpiresAt.Format(time.RFC3339)))
+}
+
+// requireCommandApproval asks for approval when command matches an
+// approvalPatterns entry
+func requireCommandApproval(ctx context.Context, req mcp.CallToolRequest, command string) *mcp.CallToolResult {
+	pattern, ok := common.CommandNeedsApproval(command)
+	if !ok {
+		return nil
+	}
+	if pattern == "" {
+		return requireApproval(ctx, req, fmt.Sprintf("%s of %q, which nests too deeply to check", req.Params.Name, command))
+	}
+	return requireApproval(ctx, req, fmt.Sprintf("%s of %q, which matches approval pattern %q", req.Params.Name, command, pattern))
+}
+
+func HandleApproveOperation(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	token, err := req.RequireString("token")
+	if err != nil {
+		return toolErrorf("Invalid token parameter: %v", err), nil
+	}
+
+	pending, err := common.TakeApproval(token)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	handler, ok := approvalHandlers[pending.Tool]
+	if !ok {
+		handler, ok = policyApprovalHandler(pending.Tool)
+	}
+	if !ok {
+		return toolErrorf("Tool %s cannot be run by approve_operation", pending.Tool), nil
+	}
+	if err := common.CheckClientRole(sessionID(ctx), clientName(ctx), pending.Tool, pending.Arguments); err != nil {
+		return toolErrorf("Permission denied: %v", err), nil
+	}
+	if check := common.CheckToolPolicy(pending.Tool, pending.Arguments); check.Decision == common.PolicyBlocked {
+		return toolError(common.ErrorPermissionDenied, common.Localize("Permission denied: %s", check.Reason)), nil
+	}
+	if err := common.CheckSessionQuotas(sessionID(ctx), pending.Tool); err != nil {
+		return toolError(common.ErrorQuotaExceeded, common.Localize("Session quota reached: %v", err)), nil
+	}
+
+	approved := mcp.CallToolRequest{}
+	approved.Params.Name = pending.Tool
+	approved.Params.Arguments = pending.Arguments
+	approved.Params.Meta = req.Params.Meta
+	return handler(context.WithValue(ctx, approvedOperationKey{}, token), approved)
+}
+
+func HandleDenyOperation(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	token, err := req.RequireString("token")
+	if err != nil {
+		return toolErrorf("Invalid token parameter: %v", err), nil
+	}
+
+	pending, err := common.TakeApproval(token)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Discarded: %s", pending.Summary)), nil
+}
+
+func HandleListPendingApprovals(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pending := common.ListPendingApprovals()
+	if len(pending) == 0 {
+		return mcp.NewToolResultText(common.Localize("No operations are waiting for approval")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal pending approvals: %v", err), nil
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
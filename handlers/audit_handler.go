@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"jarvis/internal/types"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AuditToolCalls is a tool handler middleware recording every tool call
+// in the audit log with its outcome and duration
+func AuditToolCalls(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started := time.Now()
+		result, err := next(ctx, req)
+
+		entry := types.AuditEntry{
+			Time:       started,
+			Session:    sessionID(ctx),
+			Tool:       req.Params.Name,
+			Outcome:    common.AuditOutcomeSuccess,
+			DurationMs: time.Since(started).Milliseconds(),
+		}
+		entry.Arguments, _ = common.AuditArguments(req.GetArguments())
+		entry.Files = common.ToolCallPaths(req.Params.Name, req.GetArguments())
+		switch {
+		case err != nil:
+			entry.Outcome, entry.Error, entry.ErrorCode = common.AuditOutcomeError, err.Error(), common.ErrorCodeOf(err)
+		case result != nil && result.IsError:
+			entry.Outcome, entry.ErrorCode = common.AuditOutcomeError, resultErrorCode(result)
+			if text, ok := firstText(result); ok {
+				entry.Error, _ = common.TruncateHistoryOutput(text)
+			}
+		}
+		common.RecordAudit(entry)
+
+		return result, err
+	}
+}
+
+func HandleQueryAuditLog(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filter := common.AuditLogFilter{
+		Tool:    mcp.ParseString(req, "tool", ""),
+		Session: mcp.ParseString(req, "session", ""),
+		Outcome: mcp.ParseString(req, "outcome", ""),
+		Path:    mcp.ParseString(req, "path", ""),
+		Query:   mcp.ParseString(req, "query", ""),
+		Limit:   int(mcp.ParseFloat64(req, "limit", 100)),
+	}
+	if filter.Outcome != "" && filter.Outcome != common.AuditOutcomeSuccess && filter.Outcome != common.AuditOutcomeError {
+		return toolErrorf("Invalid outcome %q: use success or error", filter.Outcome), nil
+	}
+
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := mcp.ParseString(req, name, "")
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err == nil {
+			*target = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+			*target = t
+		} else {
+			return toolErrorf("Invalid %s value %q: use an RFC3339 timestamp or a duration like 15m", name, value), nil
+		}
+	}
+
+	entries, err := common.QueryAuditLog(filter)
+	if err != nil {
+		return toolErrorf("Failed to read audit log: %v", err), nil
+	}
+	if len(entries) == 0 {
+		return mcp.NewToolResultText(common.Localize("No tool calls recorded")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal audit log: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
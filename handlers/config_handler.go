@@ -4,8 +4,10 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"jarvis/internal/common"
+	"jarvis/internal/types"
+	"os"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -13,76 +15,434 @@ import (
 func HandleGetConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	configJSON, err := common.GetJSON()
 	if err != nil {
-		return mcp.NewToolResultError(common.FormatError(err, "get configuration")), nil
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "get configuration")), nil
 	}
 	return mcp.NewToolResultText(configJSON), nil
 }
 
+func HandleGetConfigSchema(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(common.ConfigSchema, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal config schema: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
 func HandleSetConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	key, err := req.RequireString("key")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid key parameter: %v", err)), nil
+		return toolErrorf("Invalid key parameter: %v", err), nil
 	}
 
 	value, err := req.RequireString("value")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid value parameter: %v", err)), nil
+		return toolErrorf("Invalid value parameter: %v", err), nil
 	}
 
 	if err := common.Set(key, value); err != nil {
-		return mcp.NewToolResultError(common.FormatError(err, "set configuration")), nil
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "set configuration")), nil
 	}
-	return mcp.NewToolResultText(fmt.Sprintf("Configuration key '%s' set to '%s'", key, value)), nil
+	return mcp.NewToolResultText(common.Localize("Configuration key '%s' set to '%s'", key, value)), nil
 }
 
 func HandleAddAllowedDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	directory, err := req.RequireString("directory")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid directory parameter: %v", err)), nil
+		return toolErrorf("Invalid directory parameter: %v", err), nil
 	}
 
 	if err := common.AddAllowedDirectory(directory); err != nil {
-		return mcp.NewToolResultError(common.FormatError(err, "add allowed directory")), nil
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "add allowed directory")), nil
 	}
-	return mcp.NewToolResultText(fmt.Sprintf("Directory '%s' added to allowed list", directory)), nil
+	return mcp.NewToolResultText(common.Localize("Directory '%s' added to allowed list", directory)), nil
 }
 
 func HandleRemoveAllowedDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	directory, err := req.RequireString("directory")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid directory parameter: %v", err)), nil
+		return toolErrorf("Invalid directory parameter: %v", err), nil
 	}
 
 	err = common.RemoveAllowedDirectory(directory)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to remove allowed directory: %v", err)), nil
+		return toolErrorf("Failed to remove allowed directory: %v", err), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Directory '%s' removed from allowed list", directory)), nil
+	return mcp.NewToolResultText(common.Localize("Directory '%s' removed from allowed list", directory)), nil
 }
 
 func HandleAddBlockedCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	pattern, err := req.RequireString("pattern")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid pattern parameter: %v", err)), nil
+		return toolErrorf("Invalid pattern parameter: %v", err), nil
 	}
 
 	err = common.AddBlockedCommand(pattern)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to add blocked command: %v", err)), nil
+		return toolErrorf("Failed to add blocked command: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Command pattern '%s' added to blocked list", pattern)), nil
+}
+
+func HandleListBlockedCommands(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	blocked := common.Get().BlockedCommands
+	if blocked == nil {
+		blocked = []string{}
+	}
+	jsonData, err := json.MarshalIndent(blocked, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal blocked commands: %v", err), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Command pattern '%s' added to blocked list", pattern)), nil
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleRemoveBlockedCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pattern, err := req.RequireString("pattern")
+	if err != nil {
+		return toolErrorf("Invalid pattern parameter: %v", err), nil
+	}
+
+	err = common.RemoveBlockedCommand(pattern)
+	if err != nil {
+		return toolErrorf("Failed to remove blocked command: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Command pattern '%s' removed from blocked list", pattern)), nil
+}
+
+func HandleTestCommandAgainstPolicy(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	command, err := req.RequireString("command")
+	if err != nil {
+		return toolErrorf("Invalid command parameter: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(common.CheckCommandPolicy(command), "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal policy check: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
 func HandleValidateConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	if err := common.Validate(); err != nil {
-		return mcp.NewToolResultError(common.FormatError(err, "validate configuration")), nil
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "validate configuration")), nil
 	}
-	return mcp.NewToolResultText("Configuration is valid"), nil
+	return mcp.NewToolResultText(common.Localize("Configuration is valid")), nil
 }
 
 func HandleResetConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	common.Reset()
-	return mcp.NewToolResultText("Configuration reset to default values"), nil
+	return mcp.NewToolResultText(common.Localize("Configuration reset to default values")), nil
+}
+
+func HandleSaveCommandTemplate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return toolErrorf("Invalid command parameter: %v", err), nil
+	}
+
+	template := types.CommandTemplate{
+		Description:    mcp.ParseString(req, "description", ""),
+		Command:        common.SanitizeCommand(command),
+		Shell:          mcp.ParseString(req, "shell", ""),
+		WorkingDir:     mcp.ParseString(req, "working_dir", ""),
+		TimeoutSeconds: int(mcp.ParseFloat64(req, "timeout_seconds", 0)),
+	}
+	if parameters := mcp.ParseString(req, "parameters", ""); parameters != "" {
+		if err := json.Unmarshal([]byte(parameters), &template.Parameters); err != nil {
+			return toolErrorf("Invalid parameters parameter: must be a JSON array: %v", err), nil
+		}
+	}
+	if template.WorkingDir != "" && !common.IsPathAllowed(template.WorkingDir) {
+		return toolErrorf("Access to working directory is not allowed"), nil
+	}
+
+	if err := common.SaveCommandTemplate(name, template); err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "save command template")), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Command template '%s' saved", name)), nil
+}
+
+func HandleDeleteCommandTemplate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	if err := common.DeleteCommandTemplate(name); err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "delete command template")), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Command template '%s' deleted", name)), nil
+}
+
+func HandleListCommandTemplates(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	templates := common.Get().CommandTemplates
+	if len(templates) == 0 {
+		return mcp.NewToolResultText(common.Localize("No command templates")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal command templates: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleSaveSSHHost(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	address, err := req.RequireString("address")
+	if err != nil {
+		return toolErrorf("Invalid address parameter: %v", err), nil
+	}
+
+	host := types.SSHHost{
+		Host:           address,
+		Port:           int(mcp.ParseFloat64(req, "port", 0)),
+		User:           mcp.ParseString(req, "user", ""),
+		IdentityFile:   mcp.ParseString(req, "identity_file", ""),
+		KnownHostsFile: mcp.ParseString(req, "known_hosts_file", ""),
+	}
+
+	if err := common.SaveSSHHost(name, host); err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "save SSH host")), nil
+	}
+	return mcp.NewToolResultText(common.Localize("SSH host '%s' saved", name)), nil
+}
+
+func HandleRemoveSSHHost(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	if err := common.RemoveSSHHost(name); err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "remove SSH host")), nil
+	}
+	return mcp.NewToolResultText(common.Localize("SSH host '%s' removed", name)), nil
+}
+
+func HandleListConfigProfiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	profiles, err := common.ListConfigProfiles()
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "list config profiles")), nil
+	}
+	if len(profiles) == 0 {
+		return mcp.NewToolResultText(common.Localize("No config profiles")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal config profiles: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleSaveConfigProfile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	settings := json.RawMessage(mcp.ParseString(req, "settings", ""))
+	if err := common.SaveConfigProfile(name, settings); err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "save config profile")), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Config profile '%s' saved", name)), nil
+}
+
+func HandleDeleteConfigProfile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	if err := common.DeleteConfigProfile(name); err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "delete config profile")), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Config profile '%s' deleted", name)), nil
+}
+
+func HandleSwitchConfigProfile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	changes, err := common.SwitchConfigProfile(name)
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "switch config profile")), nil
+	}
+	if len(changes) == 0 {
+		return mcp.NewToolResultText(common.Localize("Switched to config profile '%s'; no settings changed", name)), nil
+	}
+
+	jsonData, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal changed settings: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Switched to config profile '%s'; changed settings:\n%s", name, jsonData)), nil
+}
+
+func HandleDiffConfigProfiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	from, err := req.RequireString("from")
+	if err != nil {
+		return toolErrorf("Invalid from parameter: %v", err), nil
+	}
+	to := mcp.ParseString(req, "to", common.CurrentConfigName)
+
+	differences, err := common.DiffConfigProfiles(from, to)
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "diff config profiles")), nil
+	}
+	if len(differences) == 0 {
+		return mcp.NewToolResultText(common.Localize("'%s' and '%s' have the same settings", from, to)), nil
+	}
+
+	jsonData, err := json.MarshalIndent(differences, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal differences: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleReloadConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	changes, err := common.ReloadConfig(true)
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "reload configuration")), nil
+	}
+	if len(changes) == 0 {
+		return mcp.NewToolResultText(common.Localize("Configuration reloaded; no settings changed")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal changed settings: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Configuration reloaded; changed settings:\n%s", jsonData)), nil
+}
+
+func HandleExportConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	data, err := common.ExportConfig(mcp.ParseBoolean(req, "strip_secrets", true))
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "export configuration")), nil
+	}
+
+	path := mcp.ParseString(req, "path", "")
+	if path == "" {
+		return mcp.NewToolResultText(string(data)), nil
+	}
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+	if err := common.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return toolErrorf("Failed to write %s: %v", path, err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Configuration exported to %s (%s)", path, common.FormatBytes(int64(len(data)+1)))), nil
+}
+
+func HandleImportConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := mcp.ParseString(req, "path", "")
+	content := mcp.ParseString(req, "config", "")
+	if (path == "") == (content == "") {
+		return toolErrorf("Give either path or config"), nil
+	}
+	data := []byte(content)
+	if path != "" {
+		if !common.IsPathAllowed(path) {
+			return toolErrorf("Access to this path is not allowed"), nil
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return toolErrorf("Failed to read %s: %v", path, err), nil
+		}
+	}
+
+	apply := mcp.ParseBoolean(req, "apply", false)
+	differences, err := common.ImportConfig(data, apply)
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "import configuration")), nil
+	}
+	if len(differences) == 0 {
+		return mcp.NewToolResultText(common.Localize("The imported configuration has the same settings as the current one")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(differences, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal differences: %v", err), nil
+	}
+
+	if !apply {
+		return mcp.NewToolResultText(common.Localize("Importing would change these settings; repeat with apply=true to import:\n%s", jsonData)), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Configuration imported; changed settings:\n%s", jsonData)), nil
+}
+
+func HandleListConfigVersions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	limit := mcp.ParseInt(req, "limit", 20)
+	if limit <= 0 {
+		return toolErrorf("limit must be positive"), nil
+	}
+
+	versions, err := common.ListConfigVersions()
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "list configuration versions")), nil
+	}
+	if len(versions) == 0 {
+		return mcp.NewToolResultText(common.Localize("No configuration versions recorded yet; one is kept each time the configuration changes")), nil
+	}
+	if len(versions) > limit {
+		versions = versions[:limit]
+	}
+
+	jsonData, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal configuration versions: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleRollbackConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	version := int(mcp.ParseFloat64(req, "version", 0))
+	if version <= 0 {
+		return toolErrorf("Invalid version"), nil
+	}
+
+	apply := mcp.ParseBoolean(req, "apply", false)
+	differences, err := common.RollbackConfig(version, apply)
+	if err != nil {
+		return toolError(common.ErrorCodeOf(err), common.FormatError(err, "roll back configuration")), nil
+	}
+	if len(differences) == 0 {
+		return mcp.NewToolResultText(common.Localize("Configuration version %d has the same settings as the current one", version)), nil
+	}
+
+	jsonData, err := json.MarshalIndent(differences, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal differences: %v", err), nil
+	}
+
+	if !apply {
+		return mcp.NewToolResultText(common.Localize("Rolling back to version %d would change these settings; repeat with apply=true to roll back:\n%s", version, jsonData)), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Configuration rolled back to version %d; changed settings:\n%s", version, jsonData)), nil
 }
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func HandleSQLiteSchema(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return toolErrorf("Database file not accessible: %v", err), nil
+	}
+
+	table := mcp.ParseString(req, "table", "")
+	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 30)) * time.Second
+
+	schema, err := common.GetSQLiteSchema(ctx, path, table, timeout)
+	if err != nil {
+		return toolErrorf("Failed to read schema: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(schema), nil
+}
+
+func HandleSQLiteQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	query, err := req.RequireString("query")
+	if err != nil {
+		return toolErrorf("Invalid query parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return toolErrorf("Database file not accessible: %v", err), nil
+	}
+
+	var params []interface{}
+	if paramsStr := mcp.ParseString(req, "params", ""); paramsStr != "" {
+		if err := json.Unmarshal([]byte(paramsStr), &params); err != nil {
+			return toolErrorf("Failed to parse params: %v", err), nil
+		}
+	}
+
+	allowWrite := mcp.ParseBoolean(req, "allow_write", false)
+	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 30)) * time.Second
+
+	rows, err := common.RunSQLiteQuery(ctx, path, query, params, !allowWrite, timeout)
+	if err != nil {
+		return toolErrorf("Query failed: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(rows), nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"jarvis/internal/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// errorCodeMetaKey is the _meta key of the error code of a failed call
+const errorCodeMetaKey = "errorCode"
+
+// AttachErrorCodes is a tool handler middleware giving every failed tool
+// call an error code in the _meta of its result. Handlers set the code with
+// toolError, toolErrorf or toolErrorOf; a failed result without one is
+// given ErrorOperationFailed.
+func AttachErrorCodes(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, req)
+		if result == nil || !result.IsError || resultErrorCode(result) != "" {
+			return result, err
+		}
+		setErrorCode(result, common.ErrorOperationFailed)
+		return result, err
+	}
+}
+
+// toolError returns an error result with an error code
+func toolError(code, message string) *mcp.CallToolResult {
+	result := mcp.NewToolResultError(message)
+	setErrorCode(result, code)
+	return result
+}
+
+// toolErrorf returns an error result with the localized message of format
+// and args, coded as common.ErrorCodeFor finds
+func toolErrorf(format string, args ...any) *mcp.CallToolResult {
+	return toolError(common.ErrorCodeFor(format, args...), common.Localize(format, args...))
+}
+
+// toolErrorOf returns an error result with the message and code of err
+func toolErrorOf(err error) *mcp.CallToolResult {
+	return toolError(common.ErrorCodeOf(err), err.Error())
+}
+
+func setErrorCode(result *mcp.CallToolResult, code string) {
+	if result.Meta == nil {
+		result.Meta = make(map[string]any)
+	}
+	result.Meta[errorCodeMetaKey] = code
+}
+
+// resultErrorCode returns the error code of a result, if it has one
+func resultErrorCode(result *mcp.CallToolResult) string {
+	code, _ := result.Meta[errorCodeMetaKey].(string)
+	return code
+}
+
+func firstText(result *mcp.CallToolResult) (string, bool) {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			return text.Text, true
+		}
+	}
+	return "", false
+}
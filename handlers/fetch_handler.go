@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"jarvis/internal/common"
@@ -16,19 +17,26 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// errChecksumMismatch stops a fresh download that does not match its
+// expected checksum from being saved
+var errChecksumMismatch = errors.New("checksum mismatch")
+
 // HandleFetchWeb processes a structured HTTP request for fetching resources
 func HandleFetchWeb(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	url, err := req.RequireString("url")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL parameter: %v", err)), nil
+		return toolErrorf("Invalid URL parameter: %v", err), nil
 	}
 
 	if err := common.ValidateURL(url); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL: %v", err)), nil
+		return toolErrorf("Invalid URL: %v", err), nil
 	}
 
 	method := mcp.ParseString(req, "method", "GET")
-	timeout := time.Duration(mcp.ParseFloat64(req, "timeout", 30)) * time.Second
+	timeout, err := common.ResolveTimeout(common.TimeoutFetch, mcp.ParseFloat64(req, "timeout", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout parameter: %v", err), nil
+	}
 	followRedirects := mcp.ParseBoolean(req, "follow_redirects", true)
 	maxRedirects := int(mcp.ParseFloat64(req, "max_redirects", 10))
 
@@ -43,19 +51,14 @@ func HandleFetchWeb(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 
 	httpReq, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create request: %v", err)), nil
+		return toolErrorf("Failed to create request: %v", err), nil
 	}
 
 	// Set headers
 	httpReq.Header.Set("User-Agent", common.BuildUserAgent("Jarvis-MCP", "1.0.0"))
 
-	if headersStr := mcp.ParseString(req, "headers", ""); headersStr != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(headersStr), &headers); err == nil {
-			for key, value := range headers {
-				httpReq.Header.Set(key, value)
-			}
-		}
+	if err := setRequestHeaders(httpReq, req); err != nil {
+		return toolErrorf("Invalid headers parameter: %v", err), nil
 	}
 
 	// Execute request
@@ -64,15 +67,16 @@ func HandleFetchWeb(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 	duration := time.Since(start)
 
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Request failed: %v", err)), nil
+		return toolErrorf("Request failed: %v", err), nil
 	}
 	defer resp.Body.Close()
 
 	// Read response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read response: %v", err)), nil
+		return toolErrorf("Failed to read response: %v", err), nil
 	}
+	common.RecordDownload(sessionID(ctx), int64(len(body)))
 
 	// Format result
 	result := fmt.Sprintf("Status: %s\n", resp.Status)
@@ -91,18 +95,18 @@ func HandleFetchWeb(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 func HandleFetchWebContent(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	url, err := req.RequireString("url")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL parameter: %v", err)), nil
+		return toolErrorf("Invalid URL parameter: %v", err), nil
 	}
 
 	if err := common.ValidateURL(url); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL: %v", err)), nil
+		return toolErrorf("Invalid URL: %v", err), nil
 	}
 
 	method := mcp.ParseString(req, "method", "GET")
 	userAgent := mcp.ParseString(req, "user_agent", common.BuildUserAgent("Jarvis-MCP", "1.0.0"))
 	includeHeaders := mcp.ParseBoolean(req, "include_headers", false)
 
-	client := common.CreateHTTPClient(30*time.Second, true, 10)
+	client := common.CreateHTTPClient(common.DefaultTimeout(common.TimeoutFetch), true, 10)
 
 	var bodyReader io.Reader
 	if body := mcp.ParseString(req, "body", ""); body != "" {
@@ -111,35 +115,31 @@ func HandleFetchWebContent(ctx context.Context, req mcp.CallToolRequest) (*mcp.C
 
 	httpReq, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create request: %v", err)), nil
+		return toolErrorf("Failed to create request: %v", err), nil
 	}
 
 	httpReq.Header.Set("User-Agent", userAgent)
 
 	// Set additional headers
-	if headersStr := mcp.ParseString(req, "headers", ""); headersStr != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(headersStr), &headers); err == nil {
-			for key, value := range headers {
-				httpReq.Header.Set(key, value)
-			}
-		}
+	if err := setRequestHeaders(httpReq, req); err != nil {
+		return toolErrorf("Invalid headers parameter: %v", err), nil
 	}
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Request failed: %v", err)), nil
+		return toolErrorf("Request failed: %v", err), nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return mcp.NewToolResultError(fmt.Sprintf("HTTP Error %d: %s", resp.StatusCode, resp.Status)), nil
+		return toolErrorf("HTTP Error %d: %s", resp.StatusCode, resp.Status), nil
 	}
 
 	content, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read content: %v", err)), nil
+		return toolErrorf("Failed to read content: %v", err), nil
 	}
+	common.RecordDownload(sessionID(ctx), int64(len(content)))
 
 	result := string(content)
 	if includeHeaders {
@@ -156,16 +156,16 @@ func HandleFetchWebContent(ctx context.Context, req mcp.CallToolRequest) (*mcp.C
 func HandleFetchWebFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	url, err := req.RequireString("url")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL parameter: %v", err)), nil
+		return toolErrorf("Invalid URL parameter: %v", err), nil
 	}
 
 	filePath, err := req.RequireString("filepath")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid filepath parameter: %v", err)), nil
+		return toolErrorf("Invalid filepath parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(filePath) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
 	overwrite := mcp.ParseBoolean(req, "overwrite", false)
@@ -173,22 +173,30 @@ func HandleFetchWebFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 	verifyChecksum := mcp.ParseBoolean(req, "verify_checksum", false)
 	expectedChecksum := mcp.ParseString(req, "expected_checksum", "")
 
+	var algorithm, expectedDigest string
+	if verifyChecksum && expectedChecksum != "" {
+		algorithm, expectedDigest, err = common.ParseChecksum(expectedChecksum)
+		if err != nil {
+			return toolErrorf("Invalid expected_checksum parameter: %v", err), nil
+		}
+	}
+
 	// Check if file exists
 	existingSize := int64(0)
 	if stat, err := os.Stat(filePath); err == nil {
 		if !overwrite && !resume {
-			return mcp.NewToolResultError("File already exists and overwrite is false"), nil
+			return toolErrorf("File already exists and overwrite is false"), nil
 		}
 		if resume {
 			existingSize = stat.Size()
 		}
 	}
 
-	client := common.CreateHTTPClient(10*time.Minute, true, 10)
+	client := common.CreateHTTPClient(common.DefaultTimeout(common.TimeoutDownload), true, 10)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create request: %v", err)), nil
+		return toolErrorf("Failed to create request: %v", err), nil
 	}
 
 	// Set headers
@@ -199,48 +207,70 @@ func HandleFetchWebFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", existingSize))
 	}
 
-	if headersStr := mcp.ParseString(req, "headers", ""); headersStr != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(headersStr), &headers); err == nil {
-			for key, value := range headers {
-				httpReq.Header.Set(key, value)
-			}
-		}
+	if err := setRequestHeaders(httpReq, req); err != nil {
+		return toolErrorf("Invalid headers parameter: %v", err), nil
 	}
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Download failed: %v", err)), nil
+		return toolErrorf("Download failed: %v", err), nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return mcp.NewToolResultError(fmt.Sprintf("HTTP Error %d: %s", resp.StatusCode, resp.Status)), nil
+		return toolErrorf("HTTP Error %d: %s", resp.StatusCode, resp.Status), nil
 	}
 
 	// Create directory
 	if err := common.EnsureDir(filepath.Dir(filePath)); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create directory: %v", err)), nil
+		return toolErrorf("Failed to create directory: %v", err), nil
 	}
 
-	// Open file for writing
-	var file *os.File
+	// Download with progress tracking. Resumed downloads append to the
+	// partial file in place; fresh downloads are renamed into place only
+	// once complete.
+	// Fresh downloads are hashed as they are written, and one that does
+	// not match the expected checksum is never put in place.
+	var written int64
+	var actualDigest string
 	if resume && existingSize > 0 && resp.StatusCode == 206 {
-		file, err = os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
-	} else {
-		file, err = os.Create(filePath)
-	}
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create file: %v", err)), nil
-	}
-	defer file.Close()
+		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return toolErrorf("Failed to create file: %v", err), nil
+		}
+		defer file.Close()
 
-	// Download with progress tracking
-	written, err := io.Copy(file, resp.Body)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to save file: %v", err)), nil
+		written, err = io.Copy(file, resp.Body)
+		if err != nil {
+			return toolErrorf("Failed to save file: %v", err), nil
+		}
+	} else {
+		err = common.WriteFileAtomicFunc(filePath, 0644, func(w io.Writer) error {
+			if algorithm == "" {
+				var copyErr error
+				written, copyErr = io.Copy(w, resp.Body)
+				return copyErr
+			}
+			digests, n, copyErr := common.HashReader(io.TeeReader(resp.Body, w), algorithm)
+			written = n
+			if copyErr != nil {
+				return copyErr
+			}
+			if actualDigest = digests[algorithm]; actualDigest != expectedDigest {
+				return errChecksumMismatch
+			}
+			return nil
+		})
+		if errors.Is(err, errChecksumMismatch) {
+			common.RecordDownload(sessionID(ctx), written)
+			return toolErrorf("Checksum mismatch; the file was not saved. Expected %s: %s, Got: %s", algorithm, expectedDigest, actualDigest), nil
+		}
+		if err != nil {
+			return toolErrorf("Failed to save file: %v", err), nil
+		}
 	}
 
+	common.RecordDownload(sessionID(ctx), written)
 	totalSize := existingSize + written
 	if resume && existingSize > 0 {
 		totalSize = existingSize + written
@@ -248,45 +278,46 @@ func HandleFetchWebFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 		totalSize = written
 	}
 
-	// Verify checksum if requested
-	if verifyChecksum && expectedChecksum != "" {
-		actualChecksum, err := common.CalculateFileChecksum(filePath)
+	// A resumed download is verified as a whole once complete
+	if algorithm != "" && actualDigest == "" {
+		digests, err := common.HashFile(filePath, algorithm)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to calculate checksum: %v", err)), nil
+			return toolErrorf("Failed to calculate checksum: %v", err), nil
 		}
-		if actualChecksum != expectedChecksum {
-			return mcp.NewToolResultError(fmt.Sprintf("Checksum mismatch. Expected: %s, Got: %s", expectedChecksum, actualChecksum)), nil
+		if actualDigest = digests[algorithm]; actualDigest != expectedDigest {
+			return toolErrorf("Checksum mismatch. Expected %s: %s, Got: %s", algorithm, expectedDigest, actualDigest), nil
 		}
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("File downloaded successfully: %s (%s)", filePath, common.FormatBytes(totalSize))), nil
+	return mcp.NewToolResultText(common.Localize("File downloaded successfully: %s (%s)", filePath, common.FormatBytes(totalSize))), nil
 }
 
 func HandleFetchWebImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	url, err := req.RequireString("url")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL parameter: %v", err)), nil
+		return toolErrorf("Invalid URL parameter: %v", err), nil
 	}
 
 	filePath, err := req.RequireString("filepath")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid filepath parameter: %v", err)), nil
+		return toolErrorf("Invalid filepath parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(filePath) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
 	validateImage := mcp.ParseBoolean(req, "validate_image", true)
 	expectedFormat := mcp.ParseString(req, "format", "")
 	maxSizeMB := mcp.ParseFloat64(req, "max_size_mb", 50)
 	convertFormat := mcp.ParseBoolean(req, "convert_format", false)
+	quality := mcp.ParseInt(req, "quality", common.DefaultImageQuality)
 
-	client := common.CreateHTTPClient(5*time.Minute, true, 10)
+	client := common.CreateHTTPClient(common.DefaultTimeout(common.TimeoutDownload), true, 10)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create request: %v", err)), nil
+		return toolErrorf("Failed to create request: %v", err), nil
 	}
 
 	httpReq.Header.Set("User-Agent", common.BuildUserAgent("Jarvis-MCP", "1.0.0"))
@@ -294,18 +325,18 @@ func HandleFetchWebImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Download failed: %v", err)), nil
+		return toolErrorf("Download failed: %v", err), nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return mcp.NewToolResultError(fmt.Sprintf("HTTP Error %d: %s", resp.StatusCode, resp.Status)), nil
+		return toolErrorf("HTTP Error %d: %s", resp.StatusCode, resp.Status), nil
 	}
 
 	// Check content type
 	contentType := resp.Header.Get("Content-Type")
 	if validateImage && common.GetContentTypeCategory(contentType) != "image" {
-		return mcp.NewToolResultError(fmt.Sprintf("Content is not an image: %s", contentType)), nil
+		return toolErrorf("Content is not an image: %s", contentType), nil
 	}
 
 	// Check file size
@@ -313,7 +344,7 @@ func HandleFetchWebImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 		if size, err := common.ParseInt64(contentLength); err == nil {
 			sizeMB := float64(size) / (1024 * 1024)
 			if sizeMB > maxSizeMB {
-				return mcp.NewToolResultError(fmt.Sprintf("File too large: %.1fMB (max: %.1fMB)", sizeMB, maxSizeMB)), nil
+				return toolErrorf("File too large: %.1fMB (max: %.1fMB)", sizeMB, maxSizeMB), nil
 			}
 		}
 	}
@@ -322,36 +353,36 @@ func HandleFetchWebImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 	if expectedFormat != "" {
 		expectedMimeType := "image/" + expectedFormat
 		if !strings.Contains(contentType, expectedMimeType) && !convertFormat {
-			return mcp.NewToolResultError(fmt.Sprintf("Image format mismatch. Expected: %s, Got: %s", expectedMimeType, contentType)), nil
+			return toolErrorf("Image format mismatch. Expected: %s, Got: %s", expectedMimeType, contentType), nil
 		}
 	}
 
 	// Create directory
 	if err := common.EnsureDir(filepath.Dir(filePath)); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create directory: %v", err)), nil
+		return toolErrorf("Failed to create directory: %v", err), nil
 	}
 
 	// Download file
-	file, err := os.Create(filePath)
+	var size int64
+	err = common.WriteFileAtomicFunc(filePath, 0644, func(w io.Writer) error {
+		var copyErr error
+		size, copyErr = io.Copy(w, resp.Body)
+		return copyErr
+	})
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create file: %v", err)), nil
+		return toolErrorf("Failed to save image: %v", err), nil
 	}
-	defer file.Close()
+	common.RecordDownload(sessionID(ctx), size)
 
-	size, err := io.Copy(file, resp.Body)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to save image: %v", err)), nil
-	}
-
-	result := fmt.Sprintf("Image downloaded successfully: %s (%s, %s)", filePath, common.FormatBytes(size), contentType)
+	result := common.Localize("Image downloaded successfully: %s (%s, %s)", filePath, common.FormatBytes(size), contentType)
 
 	// Convert format if requested
 	if convertFormat && expectedFormat != "" && !strings.Contains(contentType, "image/"+expectedFormat) {
-		convertedPath, err := common.ConvertImageFormat(filePath, expectedFormat)
+		convertedPath, err := common.ConvertImageFormat(ctx, filePath, expectedFormat, quality)
 		if err != nil {
-			result += fmt.Sprintf("\nWarning: Format conversion failed: %v", err)
+			result += common.Localize("\nWarning: Format conversion failed: %v", err)
 		} else {
-			result += fmt.Sprintf("\nConverted to: %s", convertedPath)
+			result += common.Localize("\nConverted to: %s", convertedPath)
 		}
 	}
 
@@ -361,18 +392,18 @@ func HandleFetchWebImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 func HandleFetchWebJSON(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	url, err := req.RequireString("url")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL parameter: %v", err)), nil
+		return toolErrorf("Invalid URL parameter: %v", err), nil
 	}
 
 	if err := common.ValidateURL(url); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid URL: %v", err)), nil
+		return toolErrorf("Invalid URL: %v", err), nil
 	}
 
 	method := mcp.ParseString(req, "method", "GET")
 	prettyPrint := mcp.ParseBoolean(req, "pretty_print", true)
 	jsonPath := mcp.ParseString(req, "json_path", "")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: common.DefaultTimeout(common.TimeoutFetch)}
 
 	var bodyReader io.Reader
 	if body := mcp.ParseString(req, "body", ""); body != "" {
@@ -381,7 +412,7 @@ func HandleFetchWebJSON(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 
 	httpReq, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create request: %v", err)), nil
+		return toolErrorf("Failed to create request: %v", err), nil
 	}
 
 	httpReq.Header.Set("User-Agent", common.BuildUserAgent("Jarvis-MCP", "1.0.0"))
@@ -392,47 +423,43 @@ func HandleFetchWebJSON(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 	}
 
 	// Set additional headers
-	if headersStr := mcp.ParseString(req, "headers", ""); headersStr != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(headersStr), &headers); err == nil {
-			for key, value := range headers {
-				httpReq.Header.Set(key, value)
-			}
-		}
+	if err := setRequestHeaders(httpReq, req); err != nil {
+		return toolErrorf("Invalid headers parameter: %v", err), nil
 	}
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Request failed: %v", err)), nil
+		return toolErrorf("Request failed: %v", err), nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return mcp.NewToolResultError(fmt.Sprintf("HTTP Error %d: %s", resp.StatusCode, resp.Status)), nil
+		return toolErrorf("HTTP Error %d: %s", resp.StatusCode, resp.Status), nil
 	}
 
 	// Check content type
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "application/json") {
-		return mcp.NewToolResultError(fmt.Sprintf("Response is not JSON: %s", contentType)), nil
+		return toolErrorf("Response is not JSON: %s", contentType), nil
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read response: %v", err)), nil
+		return toolErrorf("Failed to read response: %v", err), nil
 	}
+	common.RecordDownload(sessionID(ctx), int64(len(body)))
 
 	// Parse JSON
 	var jsonData interface{}
 	if err := json.Unmarshal(body, &jsonData); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid JSON response: %v", err)), nil
+		return toolErrorf("Invalid JSON response: %v", err), nil
 	}
 
 	// Apply JSONPath if specified
 	if jsonPath != "" {
 		extractedData, err := common.ApplyJSONPath(jsonData, jsonPath)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("JSONPath error: %v", err)), nil
+			return toolErrorf("JSONPath error: %v", err), nil
 		}
 		jsonData = extractedData
 	}
@@ -452,12 +479,17 @@ func HandleFetchWebJSON(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 func HandleFetchWebBatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	urlsStr, err := req.RequireString("urls")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid urls parameter: %v", err)), nil
+		return toolErrorf("Invalid urls parameter: %v", err), nil
 	}
 
 	var urlConfigs []types.HTTPRequestConfig
 	if err := json.Unmarshal([]byte(urlsStr), &urlConfigs); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse URLs: %v", err)), nil
+		return toolErrorf("Failed to parse URLs: %v", err), nil
+	}
+	for i, config := range urlConfigs {
+		if _, err := common.ResolveTimeout(common.TimeoutFetch, float64(config.Timeout)); err != nil {
+			return toolErrorf("Invalid timeout of URL %d: %v", i+1, err), nil
+		}
 	}
 
 	maxConcurrent := int(mcp.ParseFloat64(req, "max_concurrent", 5))
@@ -466,14 +498,19 @@ func HandleFetchWebBatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 	includeTiming := mcp.ParseBoolean(req, "include_timing", true)
 
 	results, err := common.FetchURLsBatch(ctx, urlConfigs, maxConcurrent, delayMs, failFast, includeTiming)
+	for _, result := range results {
+		if body, ok := result.Data.(string); ok {
+			common.RecordDownload(sessionID(ctx), int64(len(body)))
+		}
+	}
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Batch fetch failed: %v", err)), nil
+		return toolErrorf("Batch fetch failed: %v", err), nil
 	}
 
 	// Format results
 	output, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to format results: %v", err)), nil
+		return toolErrorf("Failed to format results: %v", err), nil
 	}
 
 	return mcp.NewToolResultText(string(output)), nil
@@ -482,10 +519,13 @@ func HandleFetchWebBatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 func HandleCheckURLStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	urlsStr, err := req.RequireString("urls")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid urls parameter: %v", err)), nil
+		return toolErrorf("Invalid urls parameter: %v", err), nil
 	}
 
-	timeout := time.Duration(mcp.ParseFloat64(req, "timeout", 10)) * time.Second
+	timeout, err := common.ResolveTimeout(common.TimeoutFetch, mcp.ParseFloat64(req, "timeout", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout parameter: %v", err), nil
+	}
 	followRedirects := mcp.ParseBoolean(req, "follow_redirects", true)
 	checkSSL := mcp.ParseBoolean(req, "check_ssl", true)
 	includeHeaders := mcp.ParseBoolean(req, "include_headers", false)
@@ -494,7 +534,7 @@ func HandleCheckURLStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 	var urls []string
 	if strings.HasPrefix(strings.TrimSpace(urlsStr), "[") {
 		if err := json.Unmarshal([]byte(urlsStr), &urls); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to parse URLs array: %v", err)), nil
+			return toolErrorf("Failed to parse URLs array: %v", err), nil
 		}
 	} else {
 		urls = []string{urlsStr}
@@ -502,14 +542,36 @@ func HandleCheckURLStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 
 	results, err := common.CheckURLsStatus(ctx, urls, timeout, followRedirects, checkSSL, includeHeaders)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("URL status check failed: %v", err)), nil
+		return toolErrorf("URL status check failed: %v", err), nil
 	}
 
 	// Format results
 	output, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to format results: %v", err)), nil
+		return toolErrorf("Failed to format results: %v", err), nil
 	}
 
 	return mcp.NewToolResultText(string(output)), nil
 }
+
+// setRequestHeaders sets the headers given as a JSON object in the headers
+// parameter, with {{secret:NAME}} references replaced by stored secrets.
+// Headers that are not valid JSON are ignored.
+func setRequestHeaders(httpReq *http.Request, req mcp.CallToolRequest) error {
+	headersStr := mcp.ParseString(req, "headers", "")
+	if headersStr == "" {
+		return nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(headersStr), &headers); err != nil {
+		return nil
+	}
+	for key, value := range headers {
+		expanded, err := common.ExpandSecrets(value, nil)
+		if err != nil {
+			return fmt.Errorf("header %s: %v", key, err)
+		}
+		httpReq.Header.Set(key, expanded)
+	}
+	return nil
+}
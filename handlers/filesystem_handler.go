@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"jarvis/internal/common"
+	"jarvis/internal/types"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,17 +19,18 @@ import (
 func HandleReadFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+		return toolErrorf("Failed to read file: %v", err), nil
 	}
+	common.RememberContent(path, content)
 
 	lines := common.SplitLines(string(content))
 
@@ -74,30 +79,188 @@ func HandleReadFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 	return mcp.NewToolResultText(result), nil
 }
 
+func HandleReadFileChunk(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	offset := int64(mcp.ParseFloat64(req, "byte_offset", 0))
+	maxBytes := int64(mcp.ParseFloat64(req, "max_bytes", common.DefaultChunkSize))
+	alignLines := mcp.ParseBoolean(req, "align_lines", true)
+	token := mcp.ParseString(req, "continuation_token", "")
+
+	// A continuation token overrides byte_offset and pins the file version
+	if token != "" {
+		tokenOffset, tokenModTime, err := common.DecodeChunkToken(token)
+		if err != nil {
+			return toolErrorOf(err), nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return toolErrorf("Failed to stat file: %v", err), nil
+		}
+		if info.ModTime().UnixNano() != tokenModTime {
+			return toolErrorf("File has been modified since the continuation token was issued; restart from byte_offset"), nil
+		}
+		offset = tokenOffset
+	}
+
+	chunk, err := common.ReadFileChunk(path, offset, maxBytes, alignLines)
+	if err != nil {
+		return toolErrorf("Failed to read file chunk: %v", err), nil
+	}
+
+	output, err := json.MarshalIndent(chunk, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to format results: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(output)), nil
+}
+
+func HandleReadSpreadsheet(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	sheet := mcp.ParseString(req, "sheet", "")
+	cellRange := mcp.ParseString(req, "range", "")
+	outputFormat := mcp.ParseString(req, "output_format", "csv")
+	listSheets := mcp.ParseBoolean(req, "list_sheets", false)
+	maxRows := int(mcp.ParseFloat64(req, "max_rows", float64(common.Get().FileReadLineLimit)))
+
+	spreadsheet, err := common.OpenSpreadsheet(path)
+	if err != nil {
+		return toolErrorf("Failed to open spreadsheet: %v", err), nil
+	}
+	defer spreadsheet.Close()
+
+	if listSheets {
+		var names []string
+		for i, s := range spreadsheet.Sheets() {
+			names = append(names, fmt.Sprintf("%d: %s", i+1, s.Name))
+		}
+		return mcp.NewToolResultText(strings.Join(names, "\n")), nil
+	}
+
+	rows, err := spreadsheet.ReadSheet(sheet, cellRange, maxRows)
+	if err != nil {
+		return toolErrorf("Failed to read sheet: %v", err), nil
+	}
+
+	if outputFormat == "json" {
+		output, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to format results: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+
+	var result strings.Builder
+	writer := csv.NewWriter(&result)
+	if err := writer.WriteAll(rows); err != nil {
+		return toolErrorf("Failed to format CSV: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
 func HandleWriteFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	content, err := req.RequireString("content")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid content parameter: %v", err)), nil
+		return toolErrorf("Invalid content parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	// Refuse to clobber changes made since the caller read the file
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	append := mcp.ParseBoolean(req, "append", false)
 	createBackup := mcp.ParseBoolean(req, "create_backup", false)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+	expectedOffset := int64(mcp.ParseFloat64(req, "expected_offset", -1))
+
+	if err := common.CheckWriteLineLimit(content); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(content))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// In chunked writes every append must start where the previous chunk
+	// ended, so a lost or repeated chunk is detected instead of corrupting
+	// the file
+	if expectedOffset >= 0 {
+		if !append {
+			return toolErrorf("expected_offset requires append=true"), nil
+		}
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		} else if !os.IsNotExist(err) {
+			return toolErrorf("Failed to stat file: %v", err), nil
+		}
+		if size != expectedOffset {
+			return toolErrorf("File is %d bytes but expected_offset is %d; a chunk may be missing or duplicated", size, expectedOffset), nil
+		}
+	}
+
+	if dryRun {
+		oldName := path
+		before, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return toolErrorf("Failed to read file: %v", err), nil
+		}
+
+		after := content
+		if append {
+			after = string(before) + content
+		}
+		diff := common.EditDiff(oldName, path, string(before), after, 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	// Appending never changes existing lines, so only overwrites are guarded
+	if !append {
+		if before, err := os.ReadFile(path); err == nil {
+			if err := common.CheckLargeEdit(path, string(before), content, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+				return toolErrorOf(err), nil
+			}
+		}
+	}
 
 	// Create backup if requested and file exists
 	if createBackup {
 		if _, err := os.Stat(path); err == nil {
 			backupPath, err := common.CreateBackup(path)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
+				return toolErrorf("Failed to create backup: %v", err), nil
 			}
 			defer func() {
 				// Log backup creation
@@ -108,43 +271,53 @@ func HandleWriteFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 
 	// Ensure parent directory exists
 	if err := common.EnsureDir(filepath.Dir(path)); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create parent directory: %v", err)), nil
+		return toolErrorf("Failed to create parent directory: %v", err), nil
 	}
 
-	flag := os.O_CREATE | os.O_WRONLY
+	before, readErr := os.ReadFile(path)
+	existed := readErr == nil
+
+	// Write through a temporary file and rename so a failed write never
+	// leaves a truncated file behind
 	if append {
-		flag |= os.O_APPEND
+		err = common.AppendFileAtomic(path, []byte(content), 0644)
 	} else {
-		flag |= os.O_TRUNC
+		err = common.WriteFileAtomic(path, []byte(content), 0644)
 	}
-
-	file, err := os.OpenFile(path, flag, 0644)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to open file: %v", err)), nil
+		return toolErrorf("Failed to write file: %v", err), nil
 	}
-	defer file.Close()
-
-	_, err = file.WriteString(content)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
+	common.RecordWrite(sessionID(ctx), int64(len(content)))
+
+	switch {
+	case !existed:
+		common.RecordCreate(sessionID(ctx), "write_file", path, []byte(content))
+	case append:
+		common.RecordEdit(sessionID(ctx), "write_file", path, before, []byte(string(before)+content))
+	default:
+		common.RecordEdit(sessionID(ctx), "write_file", path, before, []byte(content))
 	}
 
-	operation := "written"
+	format := "Content successfully written to %s"
 	if append {
-		operation = "appended"
+		format = "Content successfully appended to %s"
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Content successfully %s to %s", operation, path)), nil
+	result := common.Localize(format, path)
+	if info, err := os.Stat(path); err == nil {
+		result += common.Localize(" (file is now %d bytes; use expected_offset=%d for the next chunk)", info.Size(), info.Size())
+	}
+	return mcp.NewToolResultText(result), nil
 }
 
 func HandleCreateDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
 	createParents := mcp.ParseBoolean(req, "create_parents", true)
@@ -164,26 +337,31 @@ func HandleCreateDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.C
 	}
 
 	if createErr != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create directory: %v", createErr)), nil
+		return toolErrorf("Failed to create directory: %v", createErr), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Directory created: %s", path)), nil
+	return mcp.NewToolResultText(common.Localize("Directory created: %s", path)), nil
 }
 
 func HandleListDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
 	includeHidden := mcp.ParseBoolean(req, "include_hidden", false)
 	recursive := mcp.ParseBoolean(req, "recursive", false)
+	sortBy := mcp.ParseString(req, "sort_by", "name")
+	sortOrder := mcp.ParseString(req, "sort_order", "asc")
+	offset := int(mcp.ParseFloat64(req, "offset", 0))
+	limit := int(mcp.ParseFloat64(req, "limit", 0))
+	outputFormat := mcp.ParseString(req, "output_format", "text")
 
-	var result strings.Builder
+	var entries []types.DirectoryEntry
 
 	if recursive {
 		err = filepath.Walk(path, func(walkPath string, info os.FileInfo, err error) error {
@@ -191,8 +369,9 @@ func HandleListDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 				return err
 			}
 
-			// Skip hidden files if not requested
-			if !includeHidden && strings.HasPrefix(info.Name(), ".") && walkPath != path {
+			// Skip hidden files if not requested, and denied paths
+			denied := common.SkipInWalk(walkPath, info.Mode())
+			if (!includeHidden && strings.HasPrefix(info.Name(), ".") || denied) && walkPath != path {
 				if info.IsDir() {
 					return filepath.SkipDir
 				}
@@ -204,58 +383,148 @@ func HandleListDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 				relPath = filepath.Base(path)
 			}
 
-			result.WriteString(common.FormatFileInfo(relPath, info))
+			entries = append(entries, common.NewDirectoryEntry(relPath, info))
 			return nil
 		})
 	} else {
-		entries, err := os.ReadDir(path)
+		dirEntries, err := os.ReadDir(path)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to read directory: %v", err)), nil
+			return toolErrorf("Failed to read directory: %v", err), nil
 		}
 
-		for _, entry := range entries {
-			// Skip hidden files if not requested
+		for _, entry := range dirEntries {
+			// Skip hidden files if not requested, and denied paths
 			if !includeHidden && strings.HasPrefix(entry.Name(), ".") {
 				continue
 			}
+			if common.SkipInWalk(filepath.Join(path, entry.Name()), entry.Type()) {
+				continue
+			}
 
 			info, err := entry.Info()
 			if err != nil {
 				continue
 			}
 
-			result.WriteString(common.FormatFileInfo(entry.Name(), info))
+			entries = append(entries, common.NewDirectoryEntry(entry.Name(), info))
 		}
 	}
 
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list directory: %v", err)), nil
+		return toolErrorf("Failed to list directory: %v", err), nil
+	}
+
+	if err := common.SortDirectoryEntries(entries, sortBy, sortOrder == "desc"); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Apply pagination
+	total := len(entries)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	entries = entries[offset:end]
+
+	if outputFormat == "json" {
+		output, err := json.MarshalIndent(types.DirectoryListing{
+			Path:    path,
+			Total:   total,
+			Offset:  offset,
+			Entries: entries,
+		}, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to format results: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+
+	var result strings.Builder
+	for _, entry := range entries {
+		result.WriteString(common.FormatDirectoryEntry(entry))
+	}
+	if end < total {
+		result.WriteString(common.Localize("... (%d more entries, use offset=%d to continue)\n", total-end, end))
 	}
 
 	return mcp.NewToolResultText(result.String()), nil
 }
 
+func HandleTree(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	opts := common.TreeOptions{
+		MaxDepth:         int(mcp.ParseFloat64(req, "max_depth", 3)),
+		MaxEntries:       int(mcp.ParseFloat64(req, "max_entries", 500)),
+		IncludeHidden:    mcp.ParseBoolean(req, "include_hidden", false),
+		RespectGitignore: mcp.ParseBoolean(req, "respect_gitignore", true),
+		DirectoriesOnly:  mcp.ParseBoolean(req, "directories_only", false),
+	}
+	showSize := mcp.ParseBoolean(req, "show_size", false)
+	outputFormat := mcp.ParseString(req, "output_format", "text")
+
+	tree, count, err := common.BuildTree(path, opts)
+	if err != nil {
+		return toolErrorf("Failed to build tree: %v", err), nil
+	}
+
+	if outputFormat == "json" {
+		output, err := json.MarshalIndent(tree, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to format results: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+
+	result := common.RenderTree(tree, showSize)
+	result += common.Localize("\n%d entries", count)
+
+	return mcp.NewToolResultText(result), nil
+}
+
 func HandleSearchFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	pattern, err := req.RequireString("pattern")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid pattern parameter: %v", err)), nil
+		return toolErrorf("Invalid pattern parameter: %v", err), nil
 	}
 
 	directory := mcp.ParseString(req, "directory", ".")
 	if !common.IsPathAllowed(directory) {
-		return mcp.NewToolResultError("Access to this directory is not allowed"), nil
+		return toolErrorf("Access to this directory is not allowed"), nil
 	}
 
 	caseSensitive := mcp.ParseBoolean(req, "case_sensitive", false)
 	includeDirectories := mcp.ParseBoolean(req, "include_directories", false)
 	maxDepth := int(mcp.ParseFloat64(req, "max_depth", -1))
 
+	maxResults := int(mcp.ParseFloat64(req, "max_results", 1000))
+
 	var matches []string
+	truncated := false
 
 	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip problematic files
 		}
+		if common.SkipInWalk(path, info.Mode()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 
 		// Check depth limit
 		if maxDepth >= 0 {
@@ -282,6 +551,10 @@ func HandleSearchFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 		}
 
 		if matched, _ := filepath.Match(pattern, name); matched || strings.Contains(name, pattern) {
+			if maxResults > 0 && len(matches) >= maxResults {
+				truncated = true
+				return filepath.SkipAll
+			}
 			matches = append(matches, path)
 		}
 
@@ -289,27 +562,66 @@ func HandleSearchFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 	})
 
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+		return toolErrorf("Search failed: %v", err), nil
+	}
+
+	result := strings.Join(matches, "\n")
+	if truncated {
+		result += common.Localize("\n... results truncated at %d matches", maxResults)
+	}
+
+	return mcp.NewToolResultText(result), nil
+}
+
+func HandleGlob(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pattern, err := req.RequireString("pattern")
+	if err != nil {
+		return toolErrorf("Invalid pattern parameter: %v", err), nil
+	}
+
+	root := mcp.ParseString(req, "root", ".")
+	if !common.IsPathAllowed(root) {
+		return toolErrorf("Access to this directory is not allowed"), nil
+	}
+
+	maxResults := int(mcp.ParseFloat64(req, "max_results", 1000))
+	includeDirectories := mcp.ParseBoolean(req, "include_directories", false)
+	includeHidden := mcp.ParseBoolean(req, "include_hidden", false)
+	caseSensitive := mcp.ParseBoolean(req, "case_sensitive", true)
+
+	matches, truncated, err := common.Glob(root, pattern, maxResults, includeDirectories, includeHidden, caseSensitive)
+	if err != nil {
+		return toolErrorf("Glob failed: %v", err), nil
+	}
+
+	if len(matches) == 0 {
+		return mcp.NewToolResultText(common.Localize("No matches found")), nil
+	}
+
+	result := strings.Join(matches, "\n")
+	if truncated {
+		result += common.Localize("\n... (truncated at %d results)", maxResults)
 	}
 
-	return mcp.NewToolResultText(strings.Join(matches, "\n")), nil
+	return mcp.NewToolResultText(result), nil
 }
 
 func HandleGetFileInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
 	includeChecksum := mcp.ParseBoolean(req, "include_checksum", false)
+	includeXattrs := mcp.ParseBoolean(req, "include_xattrs", false)
 
 	info, err := os.Stat(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get file info: %v", err)), nil
+		return toolErrorf("Failed to get file info: %v", err), nil
 	}
 
 	var result strings.Builder
@@ -319,6 +631,16 @@ func HandleGetFileInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 	result.WriteString(fmt.Sprintf("Modified: %s\n", info.ModTime().Format(time.RFC3339)))
 	result.WriteString(fmt.Sprintf("Is Directory: %t\n", info.IsDir()))
 
+	if ext, ok := common.GetExtendedFileInfo(info); ok {
+		result.WriteString(fmt.Sprintf("Owner: %s (%d)\n", ext.Owner, ext.UID))
+		result.WriteString(fmt.Sprintf("Group: %s (%d)\n", ext.Group, ext.GID))
+		result.WriteString(fmt.Sprintf("Inode: %d\n", ext.Inode))
+		result.WriteString(fmt.Sprintf("Links: %d\n", ext.Links))
+		if ext.CreatedAt != nil {
+			result.WriteString(fmt.Sprintf("Created: %s\n", ext.CreatedAt.Format(time.RFC3339)))
+		}
+	}
+
 	if !info.IsDir() {
 		result.WriteString(fmt.Sprintf("Is Text File: %t\n", common.IsTextFile(path)))
 
@@ -330,178 +652,1126 @@ func HandleGetFileInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 		}
 	}
 
+	if includeXattrs {
+		attrs, err := common.ListXattrs(path)
+		if err != nil {
+			result.WriteString(fmt.Sprintf("Extended Attributes: unavailable (%v)\n", err))
+		} else if len(attrs) > 0 {
+			result.WriteString("Extended Attributes:\n")
+			for _, name := range common.SortedKeys(attrs) {
+				result.WriteString(fmt.Sprintf("  %s: %s\n", name, attrs[name]))
+			}
+		}
+	}
+
 	return mcp.NewToolResultText(result.String()), nil
 }
 
-func HandleCopyFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	source, err := req.RequireString("source")
+func HandleGetImageInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid source parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
-	destination, err := req.RequireString("destination")
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	includeAllTags := mcp.ParseBoolean(req, "include_all_tags", false)
+
+	info, err := common.GetImageInfo(path, includeAllTags)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid destination parameter: %v", err)), nil
+		return toolErrorf("Failed to get image info: %v", err), nil
 	}
 
-	if !common.IsPathAllowed(source) || !common.IsPathAllowed(destination) {
-		return mcp.NewToolResultError("Access to one or both paths is not allowed"), nil
+	jsonData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal image info: %v", err), nil
 	}
 
-	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
 
-	// Check if destination exists
-	if _, err := os.Stat(destination); err == nil && !overwrite {
-		return mcp.NewToolResultError("Destination exists and overwrite is false"), nil
+func HandleConvertImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
-	// Ensure destination directory exists
-	if err := common.EnsureDir(filepath.Dir(destination)); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create destination directory: %v", err)), nil
+	format := mcp.ParseString(req, "format", "")
+	outputPath := mcp.ParseString(req, "output_path", "")
+	switch {
+	case format == "" && outputPath == "":
+		return toolErrorf("Either format or output_path must be provided"), nil
+	case outputPath == "":
+		outputPath = common.ImagePathWithFormat(path, format)
 	}
 
-	// Copy file
-	err = common.CopyFile(source, destination)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to copy file: %v", err)), nil
+	transform := types.ImageTransform{
+		Rotate:  mcp.ParseInt(req, "rotate", 0),
+		Flip:    mcp.ParseString(req, "flip", ""),
+		Format:  format,
+		Quality: mcp.ParseInt(req, "quality", common.DefaultImageQuality),
+	}
+	if width, height := mcp.ParseInt(req, "crop_width", 0), mcp.ParseInt(req, "crop_height", 0); width != 0 || height != 0 {
+		transform.Crop = &types.ImageRect{
+			X:      mcp.ParseInt(req, "crop_x", 0),
+			Y:      mcp.ParseInt(req, "crop_y", 0),
+			Width:  width,
+			Height: height,
+		}
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("File copied from %s to %s", source, destination)), nil
+	return writeImage(ctx, req, path, outputPath, transform)
 }
 
-func HandleMoveFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	source, err := req.RequireString("source")
+func HandleResizeImage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid source parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
-	destination, err := req.RequireString("destination")
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid destination parameter: %v", err)), nil
+	transform := types.ImageTransform{
+		Width:   mcp.ParseInt(req, "width", 0),
+		Height:  mcp.ParseInt(req, "height", 0),
+		Fit:     mcp.ParseString(req, "fit", common.ImageFitContain),
+		Quality: mcp.ParseInt(req, "quality", common.DefaultImageQuality),
+	}
+	if transform.Width <= 0 && transform.Height <= 0 {
+		return toolErrorf("Either width or height must be provided"), nil
 	}
 
-	if !common.IsPathAllowed(source) || !common.IsPathAllowed(destination) {
-		return mcp.NewToolResultError("Access to one or both paths is not allowed"), nil
+	return writeImage(ctx, req, path, mcp.ParseString(req, "output_path", path), transform)
+}
+
+// writeImage writes the image at path, changed by transform, to
+// outputPath, backing path up first when it is replaced and the call asks
+// for create_backup
+func writeImage(ctx context.Context, req mcp.CallToolRequest, path, outputPath string, transform types.ImageTransform) (*mcp.CallToolResult, error) {
+	if !common.IsPathAllowed(path) || !common.IsPathAllowed(outputPath) {
+		return toolErrorf("Access to one or both paths is not allowed"), nil
 	}
 
-	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+	info, err := os.Stat(path)
+	if err != nil {
+		return toolErrorf("Failed to access file: %v", err), nil
+	}
 
-	// Check if destination exists
-	if _, err := os.Stat(destination); err == nil && !overwrite {
-		return mcp.NewToolResultError("Destination exists and overwrite is false"), nil
+	replacing := filepath.Clean(outputPath) == filepath.Clean(path)
+	if _, err := os.Stat(outputPath); err == nil && !replacing && !mcp.ParseBoolean(req, "overwrite", false) {
+		return toolErrorf("Output file %s already exists (set overwrite to replace it)", outputPath), nil
 	}
 
-	// Ensure destination directory exists
-	if err := common.EnsureDir(filepath.Dir(destination)); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create destination directory: %v", err)), nil
+	// The written image is not known in advance; the source is the
+	// estimate of its size
+	if err := common.CheckWriteQuota(info.Size()); err != nil {
+		return toolErrorOf(err), nil
 	}
 
-	// Move file
-	err = os.Rename(source, destination)
+	var backupPath string
+	if replacing && mcp.ParseBoolean(req, "create_backup", false) {
+		if backupPath, err = common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	result, err := common.ProcessImage(ctx, path, outputPath, transform)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to move file: %v", err)), nil
+		return toolErrorf("Failed to process image: %v", err), nil
 	}
+	common.RecordWrite(sessionID(ctx), result.Size)
 
-	return mcp.NewToolResultText(fmt.Sprintf("File moved from %s to %s", source, destination)), nil
+	text := common.Localize("Wrote %s (%s, %dx%d, %s) from %s (%s, %dx%d, %s)",
+		result.Destination, result.Format, result.Width, result.Height, common.FormatBytes(result.Size),
+		result.Source, result.SourceFormat, result.OriginalWidth, result.OriginalHeight, common.FormatBytes(result.OriginalSize))
+	if backupPath != "" {
+		text += common.Localize("\nPrevious content backed up to: %s", backupPath)
+	}
+	return mcp.NewToolResultText(text), nil
 }
 
-func HandleDeleteFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func HandleProbeMedia(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
-	recursive := mcp.ParseBoolean(req, "recursive", false)
-	createBackup := mcp.ParseBoolean(req, "create_backup", false)
+	useFFprobe := mcp.ParseBoolean(req, "use_ffprobe", true)
+	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 30)) * time.Second
 
-	// Create backup if requested
-	if createBackup {
-		if _, err := os.Stat(path); err == nil {
-			backupPath, err := common.CreateBackup(path)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
-			}
-			defer func() {
-				fmt.Printf("Backup created: %s\n", backupPath)
-			}()
-		}
-	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	// Delete file or directory
-	var deleteErr error
-	if recursive {
-		deleteErr = os.RemoveAll(path)
-	} else {
-		deleteErr = os.Remove(path)
+	info, err := common.ProbeMedia(probeCtx, path, useFFprobe)
+	if err != nil {
+		return toolErrorf("Failed to probe media: %v", err), nil
 	}
 
-	if deleteErr != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to delete: %v", deleteErr)), nil
+	jsonData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal media info: %v", err), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Deleted: %s", path)), nil
+	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
-func HandleFindInFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	pattern, err := req.RequireString("pattern")
+func HandleGetXattr(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid pattern parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
-	directory := mcp.ParseString(req, "directory", ".")
-	if !common.IsPathAllowed(directory) {
-		return mcp.NewToolResultError("Access to this directory is not allowed"), nil
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
-	filePattern := mcp.ParseString(req, "file_pattern", "*")
-	caseSensitive := mcp.ParseBoolean(req, "case_sensitive", false)
-	contextLines := int(mcp.ParseFloat64(req, "context_lines", 0))
-
-	var results []string
-
-	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
-
-		// Check file pattern
-		if matched, _ := filepath.Match(filePattern, info.Name()); !matched {
-			return nil
-		}
-
-		// Only search in text files
-		if !common.IsTextFile(path) {
-			return nil
-		}
-
-		// Search in file
-		matches, err := common.SearchInFile(path, pattern, caseSensitive, contextLines)
+	name := mcp.ParseString(req, "name", "")
+	if name != "" {
+		value, err := common.GetXattr(path, name)
 		if err != nil {
-			return nil // Skip files that can't be read
-		}
-
-		if len(matches) > 0 {
-			results = append(results, fmt.Sprintf("=== %s ===", path))
-			results = append(results, matches...)
-			results = append(results, "")
+			return toolErrorf("Failed to get extended attribute: %v", err), nil
 		}
+		return mcp.NewToolResultText(value), nil
+	}
 
-		return nil
-	})
-
+	attrs, err := common.ListXattrs(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+		return toolErrorf("Failed to list extended attributes: %v", err), nil
 	}
 
-	if len(results) == 0 {
-		return mcp.NewToolResultText("No matches found"), nil
+	if len(attrs) == 0 {
+		return mcp.NewToolResultText(common.Localize("No extended attributes")), nil
 	}
 
-	return mcp.NewToolResultText(strings.Join(results, "\n")), nil
-}
+	var result strings.Builder
+	for _, key := range common.SortedKeys(attrs) {
+		result.WriteString(fmt.Sprintf("%s: %s\n", key, attrs[key]))
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+func HandleSetXattr(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if mcp.ParseBoolean(req, "remove", false) {
+		if err := common.RemoveXattr(path, name); err != nil {
+			return toolErrorf("Failed to remove extended attribute: %v", err), nil
+		}
+		return mcp.NewToolResultText(common.Localize("Removed attribute '%s' from %s", name, path)), nil
+	}
+
+	value := mcp.ParseString(req, "value", "")
+	if err := common.SetXattr(path, name, value); err != nil {
+		return toolErrorf("Failed to set extended attribute: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Attribute '%s' set on %s", name, path)), nil
+}
+
+func HandleFileStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	headLines := int(mcp.ParseFloat64(req, "head", 0))
+
+	stats, err := common.CalculateFileStats(path, headLines)
+	if err != nil {
+		return toolErrorf("Failed to get file stats: %v", err), nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Lines: %d\n", stats.Lines))
+	result.WriteString(fmt.Sprintf("Words: %d\n", stats.Words))
+	result.WriteString(fmt.Sprintf("Bytes: %s (%d bytes)\n", common.FormatBytes(stats.Bytes), stats.Bytes))
+	result.WriteString(fmt.Sprintf("Blank Lines: %d\n", stats.BlankLines))
+	result.WriteString(fmt.Sprintf("Longest Line: %d characters (line %d)\n", stats.LongestLine, stats.LongestLineNum))
+
+	if headLines > 0 {
+		result.WriteString(fmt.Sprintf("\nFirst %d lines:\n", len(stats.Head)))
+		result.WriteString(common.JoinLines(stats.Head))
+		result.WriteString("\n")
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+func HandleTouchFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	noCreate := mcp.ParseBoolean(req, "no_create", false)
+	reference := mcp.ParseString(req, "reference", "")
+	timestamp := mcp.ParseString(req, "timestamp", "")
+	setAtime := mcp.ParseBoolean(req, "access_time", true)
+	setMtime := mcp.ParseBoolean(req, "modification_time", true)
+
+	// Resolve the target times
+	atime, mtime := time.Now(), time.Now()
+	switch {
+	case reference != "":
+		if !common.IsPathAllowed(reference) {
+			return toolErrorf("Access to the reference path is not allowed"), nil
+		}
+		refInfo, err := os.Stat(reference)
+		if err != nil {
+			return toolErrorf("Failed to stat reference file: %v", err), nil
+		}
+		mtime = refInfo.ModTime()
+		atime = mtime
+		if refAtime, ok := common.GetAccessTime(refInfo); ok {
+			atime = refAtime
+		}
+	case timestamp != "":
+		parsed, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			return toolErrorf("Invalid timestamp (expected RFC3339): %v", err), nil
+		}
+		atime, mtime = parsed, parsed
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if noCreate {
+			return mcp.NewToolResultText(common.Localize("File does not exist, not created: %s", path)), nil
+		}
+		if err := common.EnsureDir(filepath.Dir(path)); err != nil {
+			return toolErrorf("Failed to create parent directory: %v", err), nil
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return toolErrorf("Failed to create file: %v", err), nil
+		}
+		file.Close()
+		if info, err = os.Stat(path); err != nil {
+			return toolErrorf("Failed to stat file: %v", err), nil
+		}
+	} else if err != nil {
+		return toolErrorf("Failed to stat file: %v", err), nil
+	}
+
+	// Keep the existing value for whichever time is not being changed
+	if !setMtime {
+		mtime = info.ModTime()
+	}
+	if !setAtime {
+		if current, ok := common.GetAccessTime(info); ok {
+			atime = current
+		} else {
+			atime = info.ModTime()
+		}
+	}
+
+	if err := os.Chtimes(path, atime, mtime); err != nil {
+		return toolErrorf("Failed to set file times: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Touched %s (atime: %s, mtime: %s)", path, atime.Format(time.RFC3339), mtime.Format(time.RFC3339))), nil
+}
+
+func HandleCopyFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	source, err := req.RequireString("source")
+	if err != nil {
+		return toolErrorf("Invalid source parameter: %v", err), nil
+	}
+
+	destination, err := req.RequireString("destination")
+	if err != nil {
+		return toolErrorf("Invalid destination parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(source) || !common.IsPathAllowed(destination) {
+		return toolErrorf("Access to one or both paths is not allowed"), nil
+	}
+
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+
+	// Check if destination exists
+	if _, err := os.Stat(destination); err == nil && !overwrite {
+		return toolErrorf("Destination exists and overwrite is false"), nil
+	}
+
+	// Ensure destination directory exists
+	if err := common.EnsureDir(filepath.Dir(destination)); err != nil {
+		return toolErrorf("Failed to create destination directory: %v", err), nil
+	}
+
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return toolErrorf("Failed to access source: %v", err), nil
+	}
+	if err := common.CheckWriteQuota(sourceInfo.Size()); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Copy file
+	err = common.CopyFile(source, destination)
+	if err != nil {
+		return toolErrorf("Failed to copy file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), sourceInfo.Size())
+
+	return mcp.NewToolResultText(common.Localize("File copied from %s to %s", source, destination)), nil
+}
+
+func HandleMoveFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	source, err := req.RequireString("source")
+	if err != nil {
+		return toolErrorf("Invalid source parameter: %v", err), nil
+	}
+
+	destination, err := req.RequireString("destination")
+	if err != nil {
+		return toolErrorf("Invalid destination parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(source) || !common.IsPathAllowed(destination) {
+		return toolErrorf("Access to one or both paths is not allowed"), nil
+	}
+	if denied, ok := common.FindDeniedPath(source); ok {
+		return toolErrorf("Refusing to move %s: it contains denied path %s", source, denied), nil
+	}
+
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	// Check if destination exists
+	_, statErr := os.Stat(destination)
+	if statErr == nil && !overwrite {
+		return toolErrorf("Destination exists and overwrite is false"), nil
+	}
+
+	if dryRun {
+		if _, err := os.Lstat(source); err != nil {
+			return toolErrorf("Failed to move file: %v", err), nil
+		}
+		result := common.Localize("DRY RUN - would move %s to %s", source, destination)
+		if statErr == nil {
+			result += " (overwriting the existing destination)"
+		}
+		return mcp.NewToolResultText(result), nil
+	}
+
+	// Ensure destination directory exists
+	if err := common.EnsureDir(filepath.Dir(destination)); err != nil {
+		return toolErrorf("Failed to create destination directory: %v", err), nil
+	}
+
+	// Move file
+	err = os.Rename(source, destination)
+	if err != nil {
+		return toolErrorf("Failed to move file: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("File moved from %s to %s", source, destination)), nil
+}
+
+func HandleRenameFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	directory, err := req.RequireString("directory")
+	if err != nil {
+		return toolErrorf("Invalid directory parameter: %v", err), nil
+	}
+
+	pattern, err := req.RequireString("pattern")
+	if err != nil {
+		return toolErrorf("Invalid pattern parameter: %v", err), nil
+	}
+
+	replacement, err := req.RequireString("replacement")
+	if err != nil {
+		return toolErrorf("Invalid replacement parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(directory) {
+		return toolErrorf("Access to this directory is not allowed"), nil
+	}
+
+	useGlob := mcp.ParseString(req, "pattern_type", "regex") == "glob"
+	recursive := mcp.ParseBoolean(req, "recursive", false)
+	dryRun := mcp.ParseBoolean(req, "dry_run", true)
+
+	plan, err := common.PlanRenames(directory, pattern, replacement, useGlob, recursive)
+	if err != nil {
+		return toolErrorf("Failed to plan renames: %v", err), nil
+	}
+
+	if len(plan) == 0 {
+		return mcp.NewToolResultText(common.Localize("No files matched the pattern")), nil
+	}
+
+	var result strings.Builder
+	conflicts := 0
+	for _, op := range plan {
+		result.WriteString(fmt.Sprintf("%s -> %s", op.Source, filepath.Base(op.Destination)))
+		if op.Conflict != "" {
+			result.WriteString(fmt.Sprintf("  [CONFLICT: %s]", op.Conflict))
+			conflicts++
+		}
+		result.WriteString("\n")
+	}
+
+	if dryRun {
+		return mcp.NewToolResultText(common.Localize("DRY RUN - %d renames planned, %d conflicts:\n%s", len(plan), conflicts, result.String())), nil
+	}
+
+	if conflicts > 0 {
+		return toolErrorf("Refusing to rename: %d conflicts detected\n%s", conflicts, result.String()), nil
+	}
+
+	if err := common.CheckWriteQuota(make([]int64, len(plan))...); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.ExecuteRenames(plan); err != nil {
+		return toolErrorf("Failed to rename files: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Renamed %d files:\n%s", len(plan), result.String())), nil
+}
+
+func HandleDeleteFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	recursive := mcp.ParseBoolean(req, "recursive", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", false)
+	secureDelete := mcp.ParseBoolean(req, "secure_delete", false)
+	overwritePasses := int(mcp.ParseFloat64(req, "overwrite_passes", 1))
+	ignoreStorageWarnings := mcp.ParseBoolean(req, "ignore_storage_warnings", false)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	// A recursive delete must not take denied paths inside the tree with it
+	if recursive {
+		if denied, ok := common.FindDeniedPath(path); ok {
+			return toolErrorf("Refusing to delete %s: it contains denied path %s", path, denied), nil
+		}
+	}
+
+	if recursive && !dryRun && common.Get().ApprovalRequired {
+		if result := requireApproval(ctx, req, fmt.Sprintf("recursively delete %s", path)); result != nil {
+			return result, nil
+		}
+	}
+
+	if secureDelete {
+		if createBackup {
+			return toolErrorf("secure_delete cannot be combined with create_backup"), nil
+		}
+
+		deleted, warnings, err := common.SecureDelete(path, recursive, overwritePasses, ignoreStorageWarnings, dryRun)
+		if err != nil {
+			return toolErrorf("Secure delete failed: %v", err), nil
+		}
+
+		result := common.Localize("Securely deleted: %s (%d file(s), %d overwrite pass(es))", path, deleted, max(overwritePasses, 1))
+		if dryRun {
+			result = common.Localize("DRY RUN - would securely delete %s (%d file(s), %d overwrite pass(es))", path, deleted, max(overwritePasses, 1))
+		}
+		for _, warning := range warnings {
+			result += common.Localize("\nWarning: %s", warning)
+		}
+		return mcp.NewToolResultText(result), nil
+	}
+
+	if dryRun {
+		files, dirs, bytes, err := common.PlanDelete(path, recursive)
+		if err != nil {
+			return toolErrorf("Failed to delete: %v", err), nil
+		}
+		result := common.Localize("DRY RUN - would delete %s (%d file(s), %d dir(s), %s)", path, files, dirs, common.FormatBytes(bytes))
+		if createBackup && dirs == 0 {
+			result += "\nA backup would be created first"
+		}
+		return mcp.NewToolResultText(result), nil
+	}
+
+	// Create backup if requested
+	if createBackup {
+		if _, err := os.Stat(path); err == nil {
+			backupPath, err := common.CreateBackup(path)
+			if err != nil {
+				return toolErrorf("Failed to create backup: %v", err), nil
+			}
+			defer func() {
+				fmt.Printf("Backup created: %s\n", backupPath)
+			}()
+		}
+	}
+
+	// Keep the content of a single file so the deletion can be undone
+	var before []byte
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		before, _ = os.ReadFile(path)
+	}
+
+	// Delete file or directory
+	var deleteErr error
+	if recursive {
+		deleteErr = os.RemoveAll(path)
+	} else {
+		deleteErr = os.Remove(path)
+	}
+
+	if deleteErr != nil {
+		return toolErrorf("Failed to delete: %v", deleteErr), nil
+	}
+	if before != nil {
+		common.RecordDelete(sessionID(ctx), "delete_file", path, before)
+	}
+
+	return mcp.NewToolResultText(common.Localize("Deleted: %s", path)), nil
+}
+
+// HandleListBackups lists the backups of a file or directory
+func HandleListBackups(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	recursive := mcp.ParseBoolean(req, "recursive", false)
+
+	backups, err := common.ListBackups(path, recursive)
+	if err != nil {
+		return toolErrorf("Failed to list backups: %v", err), nil
+	}
+	if len(backups) == 0 {
+		return mcp.NewToolResultText(common.Localize("No backups found for %s", path)), nil
+	}
+
+	data, err := json.MarshalIndent(backups, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to encode backups: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// HandleRestoreBackup restores a file from a backup
+func HandleRestoreBackup(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	backupPath, err := req.RequireString("backup_path")
+	if err != nil {
+		return toolErrorf("Invalid backup_path parameter: %v", err), nil
+	}
+
+	target := mcp.ParseString(req, "target", "")
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+
+	if !common.IsPathAllowed(backupPath) && !common.IsInBackupDirectory(backupPath) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+	restoreTo := target
+	if restoreTo == "" {
+		restoreTo, err = common.BackupOriginal(backupPath)
+		if err != nil {
+			return toolErrorf("Failed to restore backup: %v", err), nil
+		}
+	}
+	if !common.IsPathAllowed(restoreTo) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	before, readErr := os.ReadFile(restoreTo)
+
+	restored, currentBackup, err := common.RestoreBackup(backupPath, restoreTo, createBackup)
+	if err != nil {
+		return toolErrorf("Failed to restore backup: %v", err), nil
+	}
+
+	if after, err := os.ReadFile(restored); err == nil {
+		if readErr != nil {
+			common.RecordCreate(sessionID(ctx), "restore_backup", restored, after)
+		} else {
+			common.RecordEdit(sessionID(ctx), "restore_backup", restored, before, after)
+		}
+	}
+
+	result := common.Localize("Restored %s from %s", restored, backupPath)
+	if currentBackup != "" {
+		result += common.Localize("\nPrevious content backed up to: %s", currentBackup)
+	}
+	return mcp.NewToolResultText(result), nil
+}
+
+// HandlePruneBackups deletes backups beyond the retention limits
+func HandlePruneBackups(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	config := common.Get()
+	recursive := mcp.ParseBoolean(req, "recursive", false)
+	maxCount := int(mcp.ParseFloat64(req, "max_count", float64(config.BackupMaxCount)))
+	maxAgeDays := int(mcp.ParseFloat64(req, "max_age_days", float64(config.BackupMaxAgeDays)))
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	if maxCount <= 0 && maxAgeDays <= 0 {
+		return toolErrorf("Either max_count or max_age_days is required when no retention policy is configured"), nil
+	}
+
+	pruned, err := common.PruneBackups(path, recursive, maxCount, maxAgeDays, dryRun)
+	if err != nil {
+		return toolErrorf("Failed to prune backups: %v", err), nil
+	}
+
+	var result strings.Builder
+	if dryRun {
+		result.WriteString(common.Localize("Dry run: %d backup(s) would be removed\n", len(pruned)))
+	} else {
+		result.WriteString(common.Localize("Removed %d backup(s)\n", len(pruned)))
+	}
+	for _, backup := range pruned {
+		result.WriteString(fmt.Sprintf("  %s (%s)\n", backup.Path, backup.CreatedAt.Format(time.RFC3339)))
+	}
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+func HandleFindInFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pattern, err := req.RequireString("pattern")
+	if err != nil {
+		return toolErrorf("Invalid pattern parameter: %v", err), nil
+	}
+
+	directory := mcp.ParseString(req, "directory", ".")
+	if !common.IsPathAllowed(directory) {
+		return toolErrorf("Access to this directory is not allowed"), nil
+	}
+
+	filePattern := mcp.ParseString(req, "file_pattern", "*")
+	caseSensitive := mcp.ParseBoolean(req, "case_sensitive", false)
+	useRegex := mcp.ParseBoolean(req, "regex", false)
+	contextLines := int(mcp.ParseFloat64(req, "context_lines", 0))
+	maxResults := int(mcp.ParseFloat64(req, "max_results", 200))
+	maxMatchesPerFile := int(mcp.ParseFloat64(req, "max_matches_per_file", 0))
+	offset := int(mcp.ParseFloat64(req, "offset", 0))
+	sortBy := mcp.ParseString(req, "sort_by", "path")
+	outputFormat := mcp.ParseString(req, "output_format", "text")
+
+	if maxResults <= 0 {
+		maxResults = 200
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if sortBy != "path" && sortBy != "matches" {
+		return toolErrorf("Invalid sort_by: %s (use path or matches)", sortBy), nil
+	}
+
+	re, err := common.CompileSearchPattern(pattern, useRegex, caseSensitive)
+	if err != nil {
+		return toolErrorf("Invalid pattern: %v", err), nil
+	}
+
+	results := types.SearchResults{Offset: offset}
+	var fileMatches [][]types.SearchMatch
+	collected := 0
+
+	// Sorting by path can stop as soon as the requested page is filled;
+	// ranking by match count needs every file
+	limit := offset + maxResults
+
+	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if common.SkipInWalk(path, info.Mode()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Check file pattern
+		if matched, _ := filepath.Match(filePattern, info.Name()); !matched {
+			return nil
+		}
+
+		// Only search in text files
+		if !common.IsTextFile(path) {
+			return nil
+		}
+
+		// Search in file
+		matches, err := common.FindMatchesInFile(path, re, contextLines, maxMatchesPerFile)
+		if err != nil {
+			return nil // Skip files that can't be read
+		}
+		results.FilesSearched++
+
+		if len(matches) > 0 {
+			results.FilesMatched++
+			fileMatches = append(fileMatches, matches)
+			collected += len(matches)
+		}
+
+		if sortBy == "path" && collected > limit {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+
+	if err != nil {
+		return toolErrorf("Search failed: %v", err), nil
+	}
+
+	if sortBy == "matches" {
+		sort.SliceStable(fileMatches, func(i, j int) bool {
+			return len(fileMatches[i]) > len(fileMatches[j])
+		})
+	}
+
+	var allMatches []types.SearchMatch
+	for _, matches := range fileMatches {
+		allMatches = append(allMatches, matches...)
+	}
+
+	if offset < len(allMatches) {
+		end := offset + maxResults
+		if end < len(allMatches) {
+			results.Truncated = true
+			results.NextOffset = end
+		} else {
+			end = len(allMatches)
+		}
+		results.Matches = allMatches[offset:end]
+	}
+	if results.Matches == nil {
+		results.Matches = []types.SearchMatch{}
+	}
+
+	if outputFormat == "json" {
+		jsonData, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to marshal results: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+
+	if len(results.Matches) == 0 {
+		return mcp.NewToolResultText(common.Localize("No matches found")), nil
+	}
+
+	text := common.FormatSearchMatches(results.Matches)
+	if results.Truncated {
+		text += common.Localize("\n... results truncated; use offset=%d to see more", results.NextOffset)
+	}
+
+	return mcp.NewToolResultText(text), nil
+}
+
+func HandleCreateWorkspace(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	prefix := mcp.ParseString(req, "prefix", "jarvis-workspace")
+
+	ws, err := common.CreateWorkspace(prefix)
+	if err != nil {
+		return toolErrorf("Failed to create workspace: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Workspace created: %s\nPath: %s", ws.ID, ws.Path)), nil
+}
+
+func HandleCleanupWorkspace(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id := mcp.ParseString(req, "id", "")
+	all := mcp.ParseBoolean(req, "all", false)
+
+	if all {
+		count := len(common.ListWorkspaces())
+		if errs := common.CleanupAllWorkspaces(); len(errs) > 0 {
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			return toolErrorf("Failed to clean up workspaces: %s", strings.Join(messages, "; ")), nil
+		}
+		return mcp.NewToolResultText(common.Localize("Removed %d workspaces", count)), nil
+	}
+
+	if id == "" {
+		return toolErrorf("Either id or all=true must be specified"), nil
+	}
+
+	if err := common.CleanupWorkspace(id); err != nil {
+		return toolErrorf("Failed to clean up workspace: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Workspace removed: %s", id)), nil
+}
 
 // Helper functions
+
+func HandleEncryptFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	outputPath := mcp.ParseString(req, "output_path", path+".enc")
+
+	if !common.IsPathAllowed(path) || !common.IsPathAllowed(outputPath) {
+		return toolErrorf("Access to one or both paths is not allowed"), nil
+	}
+
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+	removeOriginal := mcp.ParseBoolean(req, "remove_original", false)
+
+	secret, err := common.ResolveEncryptionSecret(mcp.ParseString(req, "passphrase", ""), mcp.ParseString(req, "key_file", ""))
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if _, err := os.Stat(outputPath); err == nil && !overwrite {
+		return toolErrorf("Output file exists and overwrite is false"), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return toolErrorf("Failed to access file: %v", err), nil
+	}
+	if err := common.CheckWriteQuota(info.Size()); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.EncryptFile(path, outputPath, secret); err != nil {
+		return toolErrorf("Failed to encrypt file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), info.Size())
+
+	result := common.Localize("File encrypted: %s -> %s", path, outputPath)
+	if removeOriginal {
+		if err := os.Remove(path); err != nil {
+			return toolErrorf("File encrypted but failed to remove original: %v", err), nil
+		}
+		result += " (original removed)"
+	}
+
+	return mcp.NewToolResultText(result), nil
+}
+
+func HandleDecryptFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	defaultOutput := strings.TrimSuffix(path, ".enc")
+	if defaultOutput == path {
+		defaultOutput = path + ".dec"
+	}
+	outputPath := mcp.ParseString(req, "output_path", defaultOutput)
+
+	if !common.IsPathAllowed(path) || !common.IsPathAllowed(outputPath) {
+		return toolErrorf("Access to one or both paths is not allowed"), nil
+	}
+
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+
+	secret, err := common.ResolveEncryptionSecret(mcp.ParseString(req, "passphrase", ""), mcp.ParseString(req, "key_file", ""))
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if _, err := os.Stat(outputPath); err == nil && !overwrite {
+		return toolErrorf("Output file exists and overwrite is false"), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return toolErrorf("Failed to access file: %v", err), nil
+	}
+	if err := common.CheckWriteQuota(info.Size()); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.DecryptFile(path, outputPath, secret); err != nil {
+		return toolErrorf("Failed to decrypt file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), info.Size())
+
+	return mcp.NewToolResultText(common.Localize("File decrypted: %s -> %s", path, outputPath)), nil
+}
+
+func HandleCreateManifest(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	outputPath := mcp.ParseString(req, "output_path", "")
+
+	if !common.IsPathAllowed(path) || (outputPath != "" && !common.IsPathAllowed(outputPath)) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	opts := common.ManifestOptions{
+		IncludeHidden:    mcp.ParseBoolean(req, "include_hidden", false),
+		RespectGitignore: mcp.ParseBoolean(req, "respect_gitignore", false),
+		Exclude:          common.SplitPatternList(mcp.ParseString(req, "exclude", "")),
+	}
+
+	// Never record the manifest file itself
+	if outputPath != "" {
+		if rel, err := filepath.Rel(path, outputPath); err == nil && !strings.HasPrefix(rel, "..") {
+			opts.Exclude = append(opts.Exclude, filepath.ToSlash(rel))
+		}
+	}
+
+	manifest, err := common.BuildManifest(path, opts)
+	if err != nil {
+		return toolErrorf("Failed to create manifest: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal manifest: %v", err), nil
+	}
+
+	if outputPath == "" {
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(jsonData))); err != nil {
+		return toolErrorOf(err), nil
+	}
+	if err := common.WriteFileAtomic(outputPath, jsonData, 0644); err != nil {
+		return toolErrorf("Failed to write manifest: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(jsonData)))
+
+	return mcp.NewToolResultText(common.Localize("Manifest of %d files written to %s", len(manifest.Files), outputPath)), nil
+}
+
+func HandleVerifyManifest(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	manifestPath, err := req.RequireString("manifest_path")
+	if err != nil {
+		return toolErrorf("Invalid manifest_path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(manifestPath) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return toolErrorf("Failed to read manifest: %v", err), nil
+	}
+
+	var manifest types.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return toolErrorf("Failed to parse manifest: %v", err), nil
+	}
+
+	root := mcp.ParseString(req, "path", manifest.Root)
+	if root == "" {
+		return toolErrorf("Manifest has no root; specify path"), nil
+	}
+	if !common.IsPathAllowed(root) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	opts := common.ManifestOptions{
+		IncludeHidden:    mcp.ParseBoolean(req, "include_hidden", false),
+		RespectGitignore: mcp.ParseBoolean(req, "respect_gitignore", false),
+		Exclude:          common.SplitPatternList(mcp.ParseString(req, "exclude", "")),
+	}
+	if rel, err := filepath.Rel(root, manifestPath); err == nil && !strings.HasPrefix(rel, "..") {
+		opts.Exclude = append(opts.Exclude, filepath.ToSlash(rel))
+	}
+
+	diff, err := common.VerifyManifest(&manifest, root, opts, mcp.ParseBoolean(req, "compare_mtime", false))
+	if err != nil {
+		return toolErrorf("Failed to verify manifest: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal result: %v", err), nil
+	}
+
+	status := "OK: tree matches manifest"
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) > 0 {
+		status = common.Localize("DRIFT: %d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed))
+	}
+
+	return mcp.NewToolResultText(status + "\n" + string(jsonData)), nil
+}
+
+func HandleFindDuplicateFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	opts := common.ManifestOptions{
+		IncludeHidden:    mcp.ParseBoolean(req, "include_hidden", false),
+		RespectGitignore: mcp.ParseBoolean(req, "respect_gitignore", false),
+		Exclude:          common.SplitPatternList(mcp.ParseString(req, "exclude", "")),
+	}
+	algorithm := mcp.ParseString(req, "algorithm", common.HashXXHash)
+	minSize := int64(mcp.ParseFloat64(req, "min_size", 1))
+
+	report, err := common.FindDuplicateFiles(path, opts, algorithm, minSize)
+	if err != nil {
+		return toolErrorf("Failed to find duplicate files: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal result: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("%d groups of duplicate files, %s reclaimable\n%s", len(report.Groups), common.FormatBytes(report.WastedBytes), jsonData)), nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"jarvis/internal/common"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the configuration the tests change out of the real home directory
+	home, err := os.MkdirTemp("", "jarvis-handlers")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// allowedTempDir returns a temporary directory the file tools may use
+func allowedTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddAllowedDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { common.RemoveAllowedDirectory(dir) })
+	return dir
+}
+
+func toolRequest(arguments map[string]any) mcp.CallToolRequest {
+	var req mcp.CallToolRequest
+	req.Params.Arguments = arguments
+	return req
+}
+
+func TestMoveFileDryRunLeavesSource(t *testing.T) {
+	dir := allowedTempDir(t)
+	source := filepath.Join(dir, "source.txt")
+	destination := filepath.Join(dir, "destination.txt")
+	if err := os.WriteFile(source, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := HandleMoveFile(context.Background(), toolRequest(map[string]any{
+		"source":      source,
+		"destination": destination,
+		"dry_run":     true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError {
+		t.Fatalf("dry run failed: %v", result.Content)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Errorf("source is gone after a dry run: %v", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("destination was created by a dry run: %v", err)
+	}
+}
+
+func TestServerFilesAreNotAllowed(t *testing.T) {
+	home := allowedTempDir(t)
+	t.Setenv("HOME", home)
+
+	for _, name := range []string{".jarvis-mcp.json", ".jarvis-mcp.json.sig", ".jarvis-mcp-jobs.json"} {
+		if common.IsPathAllowed(filepath.Join(home, name)) {
+			t.Errorf("%s is allowed", name)
+		}
+	}
+	if !common.IsPathAllowed(filepath.Join(home, "notes.txt")) {
+		t.Error("an ordinary file in the allowed directory is not allowed")
+	}
+}
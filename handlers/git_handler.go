@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// gitRepository reads and checks the repo_path parameter shared by the
+// git tools
+func gitRepository(ctx context.Context, req mcp.CallToolRequest) (string, *mcp.CallToolResult) {
+	repoPath, err := req.RequireString("repo_path")
+	if err != nil {
+		return "", toolErrorf("Invalid repo_path parameter: %v", err)
+	}
+	dir, err := common.CheckGitRepository(ctx, repoPath)
+	if err != nil {
+		return "", toolErrorOf(err)
+	}
+	return dir, nil
+}
+
+// gitPaths splits the comma-separated paths parameter
+func gitPaths(req mcp.CallToolRequest) []string {
+	var paths []string
+	for _, path := range strings.Split(mcp.ParseString(req, "paths", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// gitJSONResult marshals a git tool result
+func gitJSONResult(v interface{}) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal result: %v", err), nil
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleGitStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	status, err := common.GitStatus(ctx, dir)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return gitJSONResult(status)
+}
+
+func HandleGitDiff(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	staged := mcp.ParseBoolean(req, "staged", false)
+	ref := mcp.ParseString(req, "ref", "")
+	contextLines := mcp.ParseInt(req, "context_lines", 3)
+	if contextLines < 0 {
+		return toolErrorf("context_lines cannot be negative"), nil
+	}
+
+	diff, err := common.GitDiff(ctx, dir, staged, ref, gitPaths(req), contextLines, common.Get().MaxOutputBytes)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return gitJSONResult(diff)
+}
+
+func HandleGitLog(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	opts := common.GitLogOptions{
+		Ref:    mcp.ParseString(req, "ref", ""),
+		Path:   mcp.ParseString(req, "path", ""),
+		Author: mcp.ParseString(req, "author", ""),
+		Since:  mcp.ParseString(req, "since", ""),
+		Limit:  mcp.ParseInt(req, "limit", 20),
+	}
+	if opts.Limit <= 0 {
+		return toolErrorf("limit must be positive"), nil
+	}
+
+	commits, err := common.GitLog(ctx, dir, opts)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return gitJSONResult(commits)
+}
+
+func HandleGitBlame(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	startLine := mcp.ParseInt(req, "start_line", 0)
+	endLine := mcp.ParseInt(req, "end_line", 0)
+	if startLine < 0 || endLine < 0 || (endLine > 0 && endLine < startLine) {
+		return toolErrorf("Invalid line range"), nil
+	}
+
+	lines, err := common.GitBlame(ctx, dir, path, mcp.ParseString(req, "ref", ""), startLine, endLine)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return gitJSONResult(lines)
+}
+
+func HandleGitBranch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	action := mcp.ParseString(req, "action", "list")
+	name := mcp.ParseString(req, "name", "")
+	if action != "list" && name == "" {
+		return toolErrorf("name is required to %s a branch", action), nil
+	}
+
+	switch action {
+	case "list":
+		branches, err := common.GitBranches(ctx, dir, mcp.ParseBoolean(req, "include_remote", false))
+		if err != nil {
+			return toolErrorOf(err), nil
+		}
+		return gitJSONResult(branches)
+	case "create":
+		if err := common.GitCreateBranch(ctx, dir, name, mcp.ParseString(req, "start_point", "")); err != nil {
+			return toolErrorOf(err), nil
+		}
+		return mcp.NewToolResultText(common.Localize("Created branch %s", name)), nil
+	case "delete":
+		if err := common.GitDeleteBranch(ctx, dir, name, mcp.ParseBoolean(req, "force", false)); err != nil {
+			return toolErrorOf(err), nil
+		}
+		return mcp.NewToolResultText(common.Localize("Deleted branch %s", name)), nil
+	default:
+		return toolErrorf("Unknown action %q (use list, create or delete)", action), nil
+	}
+}
+
+func HandleGitAdd(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	if err := common.GitAdd(ctx, dir, gitPaths(req), mcp.ParseBoolean(req, "all", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	status, err := common.GitStatus(ctx, dir)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return gitJSONResult(status)
+}
+
+func HandleGitCommit(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	message, err := req.RequireString("message")
+	if err != nil {
+		return toolErrorf("Invalid message parameter: %v", err), nil
+	}
+
+	commit, err := common.GitCommit(ctx, dir, common.GitCommitOptions{
+		Message:    message,
+		Amend:      mcp.ParseBoolean(req, "amend", false),
+		AllowEmpty: mcp.ParseBoolean(req, "allow_empty", false),
+	})
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return gitJSONResult(commit)
+}
+
+func HandleGitStash(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	action := mcp.ParseString(req, "action", "push")
+	if action == "list" {
+		stashes, err := common.GitStashList(ctx, dir)
+		if err != nil {
+			return toolErrorOf(err), nil
+		}
+		return gitJSONResult(stashes)
+	}
+
+	output, err := common.GitStash(ctx, dir, action,
+		mcp.ParseString(req, "message", ""),
+		mcp.ParseString(req, "stash", ""),
+		mcp.ParseBoolean(req, "include_untracked", false))
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	if output == "" {
+		output = common.Localize("git stash %s succeeded", action)
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func HandleGitCheckout(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, errResult := gitRepository(ctx, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	target := mcp.ParseString(req, "target", "")
+	paths := gitPaths(req)
+
+	branch, err := common.GitCheckout(ctx, dir, target, mcp.ParseBoolean(req, "create", false), paths)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if len(paths) > 0 {
+		return mcp.NewToolResultText(common.Localize("Restored %d path(s)", len(paths))), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Switched to %s (HEAD: %s)", target, branch)), nil
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvis/internal/common"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TrackTemporaryGrants is a tool handler middleware counting the tool calls
+// that rely on a temporary grant against its uses, so the grant is revoked
+// once the last of them finishes
+func TrackTemporaryGrants(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ids := common.BeginGrantUses(req.Params.Name, req.GetArguments())
+		defer common.EndGrantUses(ids)
+		return next(ctx, req)
+	}
+}
+
+func HandleGrantTemporaryAccess(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	kind, err := req.RequireString("kind")
+	if err != nil {
+		return toolErrorf("Invalid kind parameter: %v", err), nil
+	}
+	value, err := req.RequireString("value")
+	if err != nil {
+		return toolErrorf("Invalid value parameter: %v", err), nil
+	}
+	uses := int(mcp.ParseFloat64(req, "uses", 0))
+
+	var duration time.Duration
+	if value := mcp.ParseString(req, "duration", ""); value != "" {
+		duration, err = time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return toolErrorf("Invalid duration %q: use a positive duration like 15m", value), nil
+		}
+	}
+
+	if common.Get().ApprovalRequired {
+		limit := fmt.Sprintf("for %s", duration)
+		switch {
+		case duration == 0:
+			limit = fmt.Sprintf("for %d uses", uses)
+		case uses > 0:
+			limit = fmt.Sprintf("for %s or %d uses", duration, uses)
+		}
+		if result := requireApproval(ctx, req, fmt.Sprintf("grant temporary %s access to %q %s", kind, value, limit)); result != nil {
+			return result, nil
+		}
+	}
+
+	grant, err := common.GrantTemporaryAccess(kind, value, sessionID(ctx), duration, uses)
+	if err != nil {
+		return toolErrorf("Failed to grant temporary access: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(grant, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal grant: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleListTemporaryGrants(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	grants := common.ListTemporaryGrants()
+	if len(grants) == 0 {
+		return mcp.NewToolResultText(common.Localize("No temporary grants in force")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(grants, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal grants: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleRevokeTemporaryAccess(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("id")
+	if err != nil {
+		return toolErrorf("Invalid id parameter: %v", err), nil
+	}
+
+	grant, err := common.RevokeTemporaryAccess(id)
+	if err != nil {
+		return toolErrorf("Failed to revoke temporary access: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Revoked temporary %s access to %q", grant.Kind, grant.Value)), nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// CollectToolMetrics is a tool handler middleware counting tool calls,
+// errors and latencies while telemetry is enabled
+func CollectToolMetrics(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started := time.Now()
+		result, err := next(ctx, req)
+		common.RecordToolMetrics(req.Params.Name, time.Since(started), err != nil || (result != nil && result.IsError))
+		return result, err
+	}
+}
+
+func HandleGetMetrics(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	format := mcp.ParseString(req, "format", "json")
+	report := common.GetMetrics()
+	if mcp.ParseBoolean(req, "reset", false) {
+		common.ResetMetrics()
+	}
+
+	switch format {
+	case "prometheus":
+		return mcp.NewToolResultText(common.FormatPrometheusMetrics(report)), nil
+	case "json":
+		jsonData, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to marshal metrics: %v", err), nil
+		}
+		result := string(jsonData)
+		if !report.Enabled {
+			result += "\nTelemetry is disabled; set telemetryEnabled to collect metrics"
+		}
+		return mcp.NewToolResultText(result), nil
+	}
+	return toolErrorf("Invalid format %q: use json or prometheus", format), nil
+}
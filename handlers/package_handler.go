@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func HandleListPackages(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	manager, err := req.RequireString("manager")
+	if err != nil {
+		return toolErrorf("Invalid manager parameter: %v", err), nil
+	}
+
+	filter := mcp.ParseString(req, "filter", "")
+
+	packages, err := common.ListInstalledPackages(ctx, manager, filter)
+	if err != nil {
+		return toolErrorf("Failed to list packages: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(packages, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal packages: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleInstallPackages(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return changePackages(ctx, req, false)
+}
+
+func HandleRemovePackages(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return changePackages(ctx, req, true)
+}
+
+// changePackages implements install_packages and remove_packages
+func changePackages(ctx context.Context, req mcp.CallToolRequest, remove bool) (*mcp.CallToolResult, error) {
+	manager, err := req.RequireString("manager")
+	if err != nil {
+		return toolErrorf("Invalid manager parameter: %v", err), nil
+	}
+
+	packagesParam, err := req.RequireString("packages")
+	if err != nil {
+		return toolErrorf("Invalid packages parameter: %v", err), nil
+	}
+
+	var packages []string
+	for _, spec := range strings.Split(packagesParam, ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			packages = append(packages, spec)
+		}
+	}
+
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 600)) * time.Second
+
+	result, err := common.ChangePackages(ctx, manager, packages, remove, dryRun, timeout, common.Get().MaxOutputBytes)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal command result: %v", err), nil
+	}
+
+	toolResult := mcp.NewToolResultText(string(jsonData))
+	toolResult.IsError = result.ExitCode != 0 || result.TimedOut
+	return toolResult, nil
+}
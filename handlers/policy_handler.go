@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvis/internal/common"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var (
+	policyHandlersMutex sync.Mutex
+	// policyApprovalHandlers are the handlers of the tools a policy rule
+	// asked approval for, which approve_operation runs once approved
+	policyApprovalHandlers = make(map[string]server.ToolHandlerFunc)
+)
+
+// EnforceToolPolicy is a tool handler middleware deciding each call with
+// the policyRules: it refuses the calls a deny rule matches and asks for
+// approval of those an approve rule matches
+func EnforceToolPolicy(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		check := common.CheckToolPolicy(req.Params.Name, req.GetArguments())
+		switch check.Decision {
+		case common.PolicyBlocked:
+			return toolError(common.ErrorPermissionDenied, common.Localize("Permission denied: %s", check.Reason)), nil
+		case common.PolicyNeedsApproval:
+			policyHandlersMutex.Lock()
+			policyApprovalHandlers[req.Params.Name] = next
+			policyHandlersMutex.Unlock()
+			if result := requireApproval(ctx, req, fmt.Sprintf("%s, since %s", req.Params.Name, check.Reason)); result != nil {
+				return result, nil
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// policyApprovalHandler returns the handler approve_operation runs for a
+// tool a policy rule asked approval for
+func policyApprovalHandler(tool string) (server.ToolHandlerFunc, bool) {
+	policyHandlersMutex.Lock()
+	defer policyHandlersMutex.Unlock()
+	handler, ok := policyApprovalHandlers[tool]
+	return handler, ok
+}
+
+func HandleTestToolAgainstPolicy(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tool, err := req.RequireString("tool")
+	if err != nil {
+		return toolErrorf("Invalid tool parameter: %v", err), nil
+	}
+	var arguments map[string]any
+	if value := mcp.ParseString(req, "arguments", ""); value != "" {
+		if err := json.Unmarshal([]byte(value), &arguments); err != nil {
+			return toolErrorf("Invalid arguments parameter: use a JSON object: %v", err), nil
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(common.CheckToolPolicy(tool, arguments), "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal policy check: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
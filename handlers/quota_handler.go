@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jarvis/internal/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// EnforceSessionQuotas is a tool handler middleware refusing the
+// destructive tools of a client session that has reached a session quota
+func EnforceSessionQuotas(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := common.CheckSessionQuotas(sessionID(ctx), req.Params.Name); err != nil {
+			return toolError(common.ErrorQuotaExceeded, common.Localize("Session quota reached: %v", err)), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+func HandleGetSessionUsage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	usages := common.GetSessionUsage()
+	if len(usages) == 0 {
+		return mcp.NewToolResultText(common.Localize("No session has used a quota yet")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(usages, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal session usage: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleResetSessionQuotas(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session := mcp.ParseString(req, "session", "")
+
+	if common.Get().ApprovalRequired {
+		summary := "reset the quotas of every session"
+		if session != "" {
+			summary = fmt.Sprintf("reset the quotas of session %s", session)
+		}
+		if result := requireApproval(ctx, req, summary); result != nil {
+			return result, nil
+		}
+	}
+
+	if !common.ResetSessionQuotas(session) {
+		if session != "" {
+			return toolErrorf("No quota usage recorded for session %s", session), nil
+		}
+		return mcp.NewToolResultText(common.Localize("No quota usage to reset")), nil
+	}
+	if session != "" {
+		return mcp.NewToolResultText(common.Localize("Reset the quotas of session %s", session)), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Reset the quotas of every session")), nil
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"context"
+	"jarvis/internal/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// LimitToolRate is a tool handler middleware refusing calls over the
+// rateLimits of the tool or its categories
+func LimitToolRate(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := common.CheckRateLimit(req.Params.Name); err != nil {
+			return toolError(common.ErrorRateLimited, common.Localize("Rate limited: %v", err)), nil
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func HandleSSHExecuteCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	host, err := req.RequireString("host")
+	if err != nil {
+		return toolErrorf("Invalid host parameter: %v", err), nil
+	}
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return toolErrorf("Invalid command parameter: %v", err), nil
+	}
+
+	command = common.SanitizeCommand(command)
+	if common.IsCommandBlocked(command) {
+		return toolErrorf("Command contains blocked patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, command); result != nil {
+		return result, nil
+	}
+
+	timeout, err := common.ResolveTimeout(common.TimeoutCommand, mcp.ParseFloat64(req, "timeout_seconds", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout_seconds parameter: %v", err), nil
+	}
+
+	cfg := common.Get()
+	return runShellCommand(ctx, req, shellCommand{
+		tool:          "ssh_execute_command",
+		command:       command,
+		shell:         "ssh:" + host,
+		timeout:       timeout,
+		captureStderr: true,
+		maxOutput:     int(mcp.ParseFloat64(req, "max_output_bytes", float64(cfg.MaxOutputBytes))),
+		sshHost:       host,
+	})
+}
+
+func HandleSSHCopyFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	host, err := req.RequireString("host")
+	if err != nil {
+		return toolErrorf("Invalid host parameter: %v", err), nil
+	}
+
+	direction, err := req.RequireString("direction")
+	if err != nil {
+		return toolErrorf("Invalid direction parameter: %v", err), nil
+	}
+	if direction != "upload" && direction != "download" {
+		return toolErrorf("direction must be upload or download"), nil
+	}
+
+	localPath, err := req.RequireString("local_path")
+	if err != nil {
+		return toolErrorf("Invalid local_path parameter: %v", err), nil
+	}
+
+	remotePath, err := req.RequireString("remote_path")
+	if err != nil {
+		return toolErrorf("Invalid remote_path parameter: %v", err), nil
+	}
+
+	recursive := mcp.ParseBoolean(req, "recursive", false)
+	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 300)) * time.Second
+
+	copyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	upload := direction == "upload"
+	if err := common.CopySSHFile(copyCtx, host, upload, localPath, remotePath, recursive); err != nil {
+		return toolErrorf("Failed to copy file: %v", err), nil
+	}
+
+	if upload {
+		return mcp.NewToolResultText(common.Localize("Uploaded %s to %s:%s", localPath, host, remotePath)), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Downloaded %s:%s to %s", host, remotePath, localPath)), nil
+}
+
+func HandleListSSHHosts(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	hosts := common.Get().SSHHosts
+	if len(hosts) == 0 {
+		return mcp.NewToolResultText(common.Localize("No SSH hosts configured")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal SSH hosts: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
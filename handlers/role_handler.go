@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+	"os"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// EnforceClientRole is a tool handler middleware refusing the calls the
+// role of the calling client does not allow
+func EnforceClientRole(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := common.CheckClientRole(sessionID(ctx), clientName(ctx), req.Params.Name, req.GetArguments()); err != nil {
+			return toolErrorf("Permission denied: %v", err), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// FilterToolsByRole is a tool filter leaving out of tools/list the tools
+// the role of the calling client does not allow
+func FilterToolsByRole(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	client := clientName(ctx)
+	allowed := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if common.ClientAllowsTool(client, tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// clientName returns JARVIS_CLIENT_NAME when it is set, so whoever starts
+// the server can name the client, or else the name the client gave when
+// it connected, or for a scheduled job the client that scheduled it
+func clientName(ctx context.Context) string {
+	if name := os.Getenv("JARVIS_CLIENT_NAME"); name != "" {
+		return name
+	}
+	if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo); ok {
+		return session.GetClientInfo().Name
+	}
+	if caller, ok := ctx.Value(jobCallerKey{}).(jobCaller); ok {
+		return caller.client
+	}
+	return ""
+}
+
+func HandleGetClientRole(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	role := common.ResolveClientRole(clientName(ctx))
+	role.Session = sessionID(ctx)
+
+	jsonData, err := json.MarshalIndent(role, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal client role: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
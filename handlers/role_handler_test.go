@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScheduledJobCallsRunAsTheSchedulingClient(t *testing.T) {
+	t.Setenv("JARVIS_CLIENT_NAME", "")
+	ctx := context.WithValue(context.Background(), jobCallerKey{}, jobCaller{client: "ci-agent", session: "session-1"})
+
+	if name := clientName(ctx); name != "ci-agent" {
+		t.Errorf("clientName = %q, want ci-agent", name)
+	}
+	if id := sessionID(ctx); id != "session-1" {
+		t.Errorf("sessionID = %q, want session-1", id)
+	}
+}
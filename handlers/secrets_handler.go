@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarvis/internal/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RedactToolResults is a tool handler middleware replacing stored secret
+// values in tool results with their {{secret:NAME}} references
+func RedactToolResults(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, req)
+		if result != nil {
+			for i, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					text.Text = common.RedactSecrets(text.Text)
+					result.Content[i] = text
+				}
+			}
+		}
+		return result, err
+	}
+}
+
+func HandleSetSecret(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+	value, err := req.RequireString("value")
+	if err != nil {
+		return toolErrorf("Invalid value parameter: %v", err), nil
+	}
+
+	if err := common.SetSecret(name, value); err != nil {
+		return toolErrorf("Failed to set secret: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Secret %s stored; reference it as {{secret:%s}}", name, name)), nil
+}
+
+func HandleListSecrets(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	secrets, err := common.ListSecrets()
+	if err != nil {
+		return toolErrorf("Failed to list secrets: %v", err), nil
+	}
+	if len(secrets) == 0 {
+		return mcp.NewToolResultText(common.Localize("No secrets stored")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal secrets: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleDeleteSecret(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	if err := common.DeleteSecret(name); err != nil {
+		return toolErrorf("Failed to delete secret: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Secret %s deleted", name)), nil
+}
@@ -2,20 +2,31 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"jarvis/internal/common"
+	"jarvis/internal/types"
+	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 )
 
 func HandleExecuteCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	command, err := req.RequireString("command")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid command parameter: %v", err)), nil
+		return toolErrorf("Invalid command parameter: %v", err), nil
 	}
 
 	// Sanitize the command
@@ -23,161 +34,509 @@ func HandleExecuteCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 
 	// Security check
 	if common.IsCommandBlocked(command) {
-		return mcp.NewToolResultError("Command contains blocked patterns"), nil
+		return toolErrorf("Command contains blocked patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, command); result != nil {
+		return result, nil
 	}
 
-	cfg := common.Get()
-	shell := mcp.ParseString(req, "shell", cfg.DefaultShell)
-	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 30)) * time.Second
+	// Without working_dir the command runs in the directory set with
+	// set_working_directory, and a relative working_dir is resolved
+	// against it
+	session := sessionID(ctx)
 	workingDir := mcp.ParseString(req, "working_dir", "")
-	captureStderr := mcp.ParseBoolean(req, "capture_stderr", false)
+	if workingDir == "" {
+		workingDir = common.SessionWorkingDirectory(session)
+	} else if workingDir, err = common.ResolveSessionPath(session, workingDir); err != nil {
+		return toolErrorf("Invalid working_dir parameter: %v", err), nil
+	}
+
+	env, err := commandEnvironment(req)
+	if err != nil {
+		return toolErrorf("Invalid env parameter: %v", err), nil
+	}
+	timeout, err := common.ResolveTimeout(common.TimeoutCommand, mcp.ParseFloat64(req, "timeout_seconds", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout_seconds parameter: %v", err), nil
+	}
+
+	cfg := common.Get()
+	return runShellCommand(ctx, req, shellCommand{
+		tool:          "execute_command",
+		command:       command,
+		shell:         mcp.ParseString(req, "shell", cfg.DefaultShell),
+		workingDir:    workingDir,
+		timeout:       timeout,
+		captureStderr: mcp.ParseBoolean(req, "capture_stderr", true),
+		maxOutput:     int(mcp.ParseFloat64(req, "max_output_bytes", float64(cfg.MaxOutputBytes))),
+		outputFile:    mcp.ParseString(req, "output_file", ""),
+		runAsUser:     mcp.ParseString(req, "run_as_user", ""),
+		env:           env,
+	})
+}
+
+// commandEnvironment returns the variables of the env parameter as
+// NAME=value entries, with {{secret:NAME}} references replaced by stored
+// secrets
+func commandEnvironment(req mcp.CallToolRequest) ([]string, error) {
+	envStr := mcp.ParseString(req, "env", "")
+	if envStr == "" {
+		return nil, nil
+	}
+	var variables map[string]string
+	if err := json.Unmarshal([]byte(envStr), &variables); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of strings: %v", err)
+	}
+	env := make([]string, 0, len(variables))
+	for name, value := range variables {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		expanded, err := common.ExpandSecrets(value, nil)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %v", name, err)
+		}
+		env = append(env, name+"="+expanded)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// shellCommand is a command for runShellCommand to execute
+type shellCommand struct {
+	tool          string
+	command       string
+	shell         string
+	workingDir    string
+	timeout       time.Duration
+	captureStderr bool
+	maxOutput     int
+	outputFile    string
+	runAsUser     string
+	// env holds extra NAME=value variables for local commands
+	env []string
+	// sshHost runs the command on this configured host instead of locally
+	sshHost string
+}
 
+// runShellCommand executes a command, streaming its output to the client,
+// records it in the command history and returns a types.CommandResult
+func runShellCommand(ctx context.Context, req mcp.CallToolRequest, run shellCommand) (*mcp.CallToolResult, error) {
 	// Create context with timeout
-	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, run.timeout)
 	defer cancel()
 
 	// Prepare command
-	cmd := exec.CommandContext(cmdCtx, shell, "-c", command)
+	var cmd *exec.Cmd
+	var sandbox string
+	var err error
+	if run.sshHost != "" {
+		if cmd, err = common.SSHCommand(cmdCtx, run.sshHost, run.command); err != nil {
+			return toolErrorf("Failed to prepare SSH command: %v", err), nil
+		}
+	} else {
+		cmd = exec.CommandContext(cmdCtx, run.shell, common.ShellCommandArgs(run.shell, run.command)...)
+		if len(run.env) > 0 {
+			cmd.Env = append(os.Environ(), run.env...)
+		}
 
-	if workingDir != "" && common.IsPathAllowed(workingDir) {
-		cmd.Dir = workingDir
+		if run.workingDir != "" && common.IsPathAllowed(run.workingDir) {
+			cmd.Dir = run.workingDir
+		}
+
+		if sandbox, err = common.SandboxCommand(cmd); err != nil {
+			return toolErrorf("Failed to sandbox command: %v", err), nil
+		}
 	}
 
-	// Execute command
-	var output []byte
-	if captureStderr {
-		stdout, err1 := cmd.Output()
-		stderr := ""
-		if err1 != nil {
-			if exitErr, ok := err1.(*exec.ExitError); ok {
-				stderr = string(exitErr.Stderr)
-			}
+	if run.runAsUser != "" {
+		if err := common.ConfigureRunAsUser(cmd, run.runAsUser); err != nil {
+			return toolErrorf("Invalid run_as_user: %v", err), nil
 		}
+	}
+
+	var spill *commandOutputFile
+	if run.outputFile != "" {
+		if spill, err = createOutputFile(run.outputFile); err != nil {
+			return toolErrorf("Invalid output_file: %v", err), nil
+		}
+	}
 
-		result := fmt.Sprintf("STDOUT:\n%s\n\nSTDERR:\n%s", string(stdout), stderr)
-		if err1 != nil {
-			result += fmt.Sprintf("\n\nEXIT CODE: %v", err1)
+	common.RecordCommands(sessionID(ctx), 1)
+
+	// Execute command, streaming output to the client as it arrives and
+	// keeping the start and end of it for the response
+	stdout, stderr := common.NewOutputBuffer(run.maxOutput), common.NewOutputBuffer(run.maxOutput)
+	streamer := newCommandStreamer(ctx, req, run.tool)
+	stdoutWriters, stderrWriters := []io.Writer{stdout}, []io.Writer{stderr}
+	if run.captureStderr {
+		if streamer != nil {
+			stdoutWriters = append(stdoutWriters, streamer.Writer(""))
+			stderrWriters = append(stderrWriters, streamer.Writer("[stderr] "))
+		}
+		if spill != nil {
+			stdoutWriters = append(stdoutWriters, spill)
+			stderrWriters = append(stderrWriters, spill)
 		}
-		return mcp.NewToolResultText(result), nil
+		cmd.Stdout, cmd.Stderr = io.MultiWriter(stdoutWriters...), io.MultiWriter(stderrWriters...)
 	} else {
-		output, err = cmd.CombinedOutput()
+		if streamer != nil {
+			stdoutWriters = append(stdoutWriters, streamer.Writer(""))
+		}
+		if spill != nil {
+			stdoutWriters = append(stdoutWriters, spill)
+		}
+		combined := io.MultiWriter(stdoutWriters...)
+		cmd.Stdout, cmd.Stderr = combined, combined
+	}
+
+	startedAt := time.Now()
+	err = cmd.Run()
+	if streamer != nil {
+		streamer.Close()
+	}
+
+	result := types.CommandResult{
+		Command:    run.command,
+		ExitCode:   common.CommandExitCode(err),
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		TimedOut:   cmdCtx.Err() == context.DeadlineExceeded,
+		Truncated:  stdout.Truncated() || stderr.Truncated(),
+		Sandbox:    sandbox,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.TimedOut {
+		result.Error = fmt.Sprintf("command timed out after %s", run.timeout)
+	}
+	if spill != nil {
+		if closeErr := spill.Close(sessionID(ctx)); closeErr != nil {
+			if result.Error != "" {
+				result.Error += "; "
+			}
+			result.Error += fmt.Sprintf("failed to write output file: %v", closeErr)
+		} else {
+			result.OutputFile = spill.path
+		}
+	}
+
+	common.RecordCommand(types.CommandHistoryEntry{
+		Tool:       run.tool,
+		Command:    run.command,
+		Shell:      run.shell,
+		WorkingDir: cmd.Dir,
+		User:       run.runAsUser,
+		StartedAt:  startedAt,
+		DurationMs: result.DurationMs,
+		ExitCode:   result.ExitCode,
+		Error:      result.Error,
+		Output:     result.Stdout + result.Stderr,
+	})
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal command result: %v", err), nil
+	}
+
+	toolResult := mcp.NewToolResultText(string(jsonData))
+	toolResult.IsError = result.ExitCode != 0 || result.TimedOut
+	return toolResult, nil
+}
+
+// commandOutputFile receives the complete output of a command for output_file.
+// The output is spooled to a temporary file and only replaces the target
+// atomically once the command is done, counted against the write quotas.
+// Writes never fail, so a full disk or an exceeded quota cannot stop the
+// command; the error is reported when the file is closed instead.
+type commandOutputFile struct {
+	mu    sync.Mutex
+	path  string
+	spool *os.File
+	size  int64
+	err   error
+}
+
+// createOutputFile prepares the output_file of a command, which receives
+// its complete output while the response is capped
+func createOutputFile(path string) (*commandOutputFile, error) {
+	if !common.IsPathAllowed(path) {
+		return nil, fmt.Errorf("access to %s is not allowed", path)
+	}
+	if err := common.CheckWriteQuota(0); err != nil {
+		return nil, err
+	}
+	if err := common.EnsureDir(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	spool, err := os.CreateTemp("", "jarvis-output-*")
+	if err != nil {
+		return nil, err
 	}
+	return &commandOutputFile{path: path, spool: spool}, nil
+}
+
+func (f *commandOutputFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.size += int64(len(p))
+	if f.err == nil {
+		f.err = common.CheckWriteQuota(f.size)
+	}
+	if f.err == nil {
+		_, f.err = f.spool.Write(p)
+	}
+	return len(p), nil
+}
 
+// Close moves the spooled output to the target path and records the write
+// for session
+func (f *commandOutputFile) Close(session string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer os.Remove(f.spool.Name())
+
+	err := f.err
+	if err == nil {
+		_, err = f.spool.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = common.WriteFileAtomicFunc(f.path, 0644, func(w io.Writer) error {
+			_, err := io.Copy(w, f.spool)
+			return err
+		})
+	}
+	f.spool.Close()
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Command failed: %v\nOutput: %s", err, string(output))), nil
+		return err
+	}
+	common.RecordWrite(session, f.size)
+	return nil
+}
+
+// closeOutputFile closes an output_file and returns the note telling the
+// client where the full output went
+func closeOutputFile(file *commandOutputFile, session string) string {
+	if err := file.Close(session); err != nil {
+		return common.Localize("\n\nFailed to write full output to %s: %v", file.path, err)
+	}
+	return common.Localize("\n\nFull output (%s) written to: %s", common.FormatBytes(file.size), file.path)
+}
+
+// newCommandStreamer forwards command output to the client while the
+// command runs: as progress notifications when the request carries a
+// progress token, otherwise as log messages. It returns nil when streaming
+// is turned off or there is no client to notify.
+func newCommandStreamer(ctx context.Context, req mcp.CallToolRequest, logger string) *common.OutputStreamer {
+	if !mcp.ParseBoolean(req, "stream_output", true) {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
 	}
 
-	return mcp.NewToolResultText(string(output)), nil
+	var token mcp.ProgressToken
+	if req.Params.Meta != nil {
+		token = req.Params.Meta.ProgressToken
+	}
+
+	sent := 0
+	return common.NewOutputStreamer(250*time.Millisecond, func(lines []string) {
+		sent += len(lines)
+		text := common.RedactSecrets(strings.Join(lines, "\n"))
+		if token != nil {
+			srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+				"progressToken": token,
+				"progress":      sent,
+				"message":       text,
+			})
+			return
+		}
+		srv.SendNotificationToClient(ctx, "notifications/message", map[string]any{
+			"level":  "info",
+			"logger": logger,
+			"data":   text,
+		})
+	})
+}
+
+// errorText returns the message of err, or "" when it is nil
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
 
 func HandleListProcesses(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	filter := mcp.ParseString(req, "filter", "")
 	includeThreads := mcp.ParseBoolean(req, "include_threads", false)
 
-	var cmd *exec.Cmd
-	if includeThreads {
-		cmd = exec.Command("ps", "auxH")
-	} else {
-		cmd = exec.Command("ps", "aux")
+	processes, err := common.ListProcesses(ctx, filter, includeThreads)
+	if err != nil {
+		return toolErrorf("Failed to list processes: %v", err), nil
+	}
+	if processes == nil {
+		processes = []types.ProcessSummary{}
 	}
 
-	output, err := cmd.Output()
+	jsonData, err := json.MarshalIndent(processes, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list processes: %v", err)), nil
+		return toolErrorf("Failed to marshal processes: %v", err), nil
 	}
 
-	result := string(output)
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
 
-	// Apply filter if specified
-	if filter != "" {
-		lines := strings.Split(result, "\n")
-		var filteredLines []string
+func HandleKillProcess(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pid := int(mcp.ParseFloat64(req, "pid", 0))
+	if pid <= 0 {
+		return toolErrorf("Invalid PID"), nil
+	}
 
-		// Keep header
-		if len(lines) > 0 {
-			filteredLines = append(filteredLines, lines[0])
-		}
+	signal := "SIGTERM"
+	if mcp.ParseBoolean(req, "force", false) {
+		signal = "SIGKILL"
+	}
+	signal = mcp.ParseString(req, "signal", signal)
+	grace := time.Duration(mcp.ParseFloat64(req, "escalate_after_seconds", 0) * float64(time.Second))
 
-		// Filter processes
-		for i := 1; i < len(lines); i++ {
-			if strings.Contains(strings.ToLower(lines[i]), strings.ToLower(filter)) {
-				filteredLines = append(filteredLines, lines[i])
-			}
+	if common.Get().ApprovalRequired {
+		if result := requireApproval(ctx, req, fmt.Sprintf("send %s to process %d", signal, pid)); result != nil {
+			return result, nil
 		}
+	}
 
-		result = strings.Join(filteredLines, "\n")
+	escalated, err := common.StopProcess(ctx, pid, signal, grace)
+	if err != nil {
+		return toolErrorf("Failed to signal process %d: %v", pid, err), nil
 	}
+	common.RecordProcessesKilled(sessionID(ctx), 1)
 
-	return mcp.NewToolResultText(result), nil
+	if escalated {
+		return mcp.NewToolResultText(common.Localize("Process %d did not exit within %s of %s and was force killed", pid, grace, signal)), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Sent %s to process %d", signal, pid)), nil
 }
 
-func HandleKillProcess(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func HandleGetProcessInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	pid := int(mcp.ParseFloat64(req, "pid", 0))
 	if pid <= 0 {
-		return mcp.NewToolResultError("Invalid PID"), nil
+		return toolErrorf("Invalid PID"), nil
 	}
 
-	force := mcp.ParseBoolean(req, "force", false)
+	details, err := common.GetProcessInfo(ctx, pid)
+	if err != nil {
+		return toolErrorf("Failed to get process info for PID %d: %v", pid, err), nil
+	}
 
-	var cmd *exec.Cmd
-	if force {
-		cmd = exec.Command("kill", "-9", strconv.Itoa(pid))
-	} else {
-		cmd = exec.Command("kill", strconv.Itoa(pid))
+	jsonData, err := json.MarshalIndent(details, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal process info: %v", err), nil
 	}
 
-	err := cmd.Run()
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleGetProcessTree(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pid := int(mcp.ParseFloat64(req, "pid", 0))
+	if pid <= 0 {
+		return toolErrorf("Invalid PID"), nil
+	}
+
+	depth := int(mcp.ParseFloat64(req, "depth", 0))
+
+	tree, err := common.GetProcessTree(ctx, pid, depth)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to kill process %d: %v", pid, err)), nil
+		return toolErrorf("Failed to get process tree for PID %d: %v", pid, err), nil
 	}
 
-	killType := "terminated"
-	if force {
-		killType = "force killed"
+	jsonData, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal process tree: %v", err), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Process %d %s", pid, killType)), nil
+	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
-func HandleGetProcessInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func HandleKillProcessTree(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	pid := int(mcp.ParseFloat64(req, "pid", 0))
 	if pid <= 0 {
-		return mcp.NewToolResultError("Invalid PID"), nil
+		return toolErrorf("Invalid PID"), nil
+	}
+	if pid == os.Getpid() {
+		return toolErrorf("Refusing to kill the server's own process tree"), nil
 	}
 
-	// Get detailed process information
-	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "pid,ppid,user,cpu,mem,vsz,rss,tty,stat,start,time,command")
-	output, err := cmd.Output()
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get process info for PID %d: %v", pid, err)), nil
+	force := mcp.ParseBoolean(req, "force", false)
+
+	if common.Get().ApprovalRequired {
+		if result := requireApproval(ctx, req, fmt.Sprintf("kill process %d and all of its descendants", pid)); result != nil {
+			return result, nil
+		}
 	}
 
-	result := string(output)
+	killed, err := common.KillProcessTree(ctx, pid, force)
+	common.RecordProcessesKilled(sessionID(ctx), len(killed))
+	if err != nil {
+		return toolErrorf("Failed to kill process tree %d: %v (stopped: %v)", pid, err, killed), nil
+	}
 
-	// Try to get additional information from /proc if available
-	cmd = exec.Command("cat", fmt.Sprintf("/proc/%d/status", pid))
-	if statusOutput, err := cmd.Output(); err == nil {
-		result += "\n\nProcess Status:\n" + string(statusOutput)
+	format := "Process tree %d terminated: %v"
+	if force {
+		format = "Process tree %d force killed: %v"
 	}
 
-	return mcp.NewToolResultText(result), nil
+	return mcp.NewToolResultText(common.Localize(format, pid, killed)), nil
 }
 
 func HandleRunShellScript(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	script, err := req.RequireString("script")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid script parameter: %v", err)), nil
+		return toolErrorf("Invalid script parameter: %v", err), nil
+	}
+
+	cfg := common.Get()
+	shell := mcp.ParseString(req, "shell", cfg.DefaultShell)
+
+	// Pre-flight checks report every problem at once instead of running a
+	// script that stops halfway with partial side effects
+	validateOnly := mcp.ParseBoolean(req, "validate_only", false)
+	if mcp.ParseBoolean(req, "preflight", false) || validateOnly {
+		check, err := common.CheckScript(ctx, script, shell, mcp.ParseBoolean(req, "shellcheck", true))
+		if err != nil {
+			return toolErrorf("Failed to check script: %v", err), nil
+		}
+		if !check.Passed || validateOnly {
+			jsonData, err := json.MarshalIndent(check, "", "  ")
+			if err != nil {
+				return toolErrorf("Failed to marshal script check: %v", err), nil
+			}
+			toolResult := mcp.NewToolResultText(string(jsonData))
+			toolResult.IsError = !check.Passed
+			return toolResult, nil
+		}
 	}
 
 	// Basic security check on script content
 	if common.IsCommandBlocked(script) {
-		return mcp.NewToolResultError("Script contains blocked command patterns"), nil
+		return toolErrorf("Script contains blocked command patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, script); result != nil {
+		return result, nil
 	}
 
-	cfg := common.Get()
-	shell := mcp.ParseString(req, "shell", cfg.DefaultShell)
-	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 60)) * time.Second
+	timeout, err := common.ResolveTimeout(common.TimeoutScript, mcp.ParseFloat64(req, "timeout_seconds", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout_seconds parameter: %v", err), nil
+	}
 	createTempFile := mcp.ParseBoolean(req, "create_temp_file", true)
+	maxOutput := int(mcp.ParseFloat64(req, "max_output_bytes", float64(cfg.MaxOutputBytes)))
+	outputFile := mcp.ParseString(req, "output_file", "")
 
 	// Create context with timeout
 	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
@@ -189,29 +548,72 @@ func HandleRunShellScript(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 		// Create temporary script file
 		tempFile, err := common.CreateTempScript(script, shell)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create temp script: %v", err)), nil
+			return toolErrorf("Failed to create temp script: %v", err), nil
 		}
 		defer common.CleanupTempFile(tempFile)
 
-		cmd = exec.CommandContext(cmdCtx, shell, tempFile)
+		cmd = exec.CommandContext(cmdCtx, shell, common.ShellScriptArgs(shell, tempFile)...)
 	} else {
 		// Execute directly
-		cmd = exec.CommandContext(cmdCtx, shell, "-c", script)
+		cmd = exec.CommandContext(cmdCtx, shell, common.ShellCommandArgs(shell, script)...)
+	}
+
+	if _, err := common.SandboxCommand(cmd); err != nil {
+		return toolErrorf("Failed to sandbox script: %v", err), nil
+	}
+
+	var spill *commandOutputFile
+	if outputFile != "" {
+		if spill, err = createOutputFile(outputFile); err != nil {
+			return toolErrorf("Invalid output_file: %v", err), nil
+		}
+	}
+
+	common.RecordCommands(sessionID(ctx), 1)
+
+	// Execute script, streaming output to the client as it arrives
+	output := common.NewOutputBuffer(maxOutput)
+	writers := []io.Writer{output}
+	streamer := newCommandStreamer(ctx, req, "run_shell_script")
+	if streamer != nil {
+		writers = append(writers, streamer.Writer(""))
 	}
+	if spill != nil {
+		writers = append(writers, spill)
+	}
+	combined := io.MultiWriter(writers...)
+	cmd.Stdout, cmd.Stderr = combined, combined
 
-	// Execute script
-	output, err := cmd.CombinedOutput()
+	startedAt := time.Now()
+	err = cmd.Run()
+	if streamer != nil {
+		streamer.Close()
+	}
+	note := ""
+	if spill != nil {
+		note = closeOutputFile(spill, sessionID(ctx))
+	}
+	common.RecordCommand(types.CommandHistoryEntry{
+		Tool:       "run_shell_script",
+		Command:    script,
+		Shell:      shell,
+		StartedAt:  startedAt,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		ExitCode:   common.CommandExitCode(err),
+		Error:      errorText(err),
+		Output:     output.String(),
+	})
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Script execution failed: %v\nOutput: %s", err, string(output))), nil
+		return toolErrorf("Script execution failed: %v\nOutput: %s%s", err, output.String(), note), nil
 	}
 
-	return mcp.NewToolResultText(string(output)), nil
+	return mcp.NewToolResultText(output.String() + note), nil
 }
 
 func HandleCheckCommandExists(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	command, err := req.RequireString("command")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid command parameter: %v", err)), nil
+		return toolErrorf("Invalid command parameter: %v", err), nil
 	}
 
 	// Use 'which' command to check if command exists
@@ -219,45 +621,1078 @@ func HandleCheckCommandExists(ctx context.Context, req mcp.CallToolRequest) (*mc
 	output, err := cmd.Output()
 
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Command '%s' not found in PATH", command)), nil
+		return mcp.NewToolResultText(common.Localize("Command '%s' not found in PATH", command)), nil
 	}
 
 	path := strings.TrimSpace(string(output))
-	return mcp.NewToolResultText(fmt.Sprintf("Command '%s' found at: %s", command, path)), nil
+	return mcp.NewToolResultText(common.Localize("Command '%s' found at: %s", command, path)), nil
 }
 
 func HandleGetSystemInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	var result strings.Builder
+	info := common.GetSystemInfo(ctx)
+
+	jsonData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal system info: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleGetEnvironment(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var maskPatterns []string
+	for _, pattern := range strings.Split(mcp.ParseString(req, "mask_patterns", ""), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return toolErrorf("Invalid mask_patterns parameter: %q: %v", pattern, err), nil
+			}
+			maskPatterns = append(maskPatterns, pattern)
+		}
+	}
+
+	info := common.GetEnvironment(sessionID(ctx),
+		mcp.ParseBoolean(req, "include_variables", true),
+		mcp.ParseString(req, "filter", ""),
+		maskPatterns)
+
+	jsonData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal environment: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleListNetworkInterfaces(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	interfaces, err := common.ListNetworkInterfaces(ctx)
+	if err != nil {
+		return toolErrorf("Failed to list network interfaces: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(interfaces, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal network interfaces: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleListNetworkConnections(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	kind := mcp.ParseString(req, "kind", "inet")
+	status := mcp.ParseString(req, "status", "")
+	pid := int(mcp.ParseFloat64(req, "pid", 0))
+
+	if !slices.Contains(common.ConnectionKinds, kind) {
+		return toolErrorf("Invalid kind: use one of %s", strings.Join(common.ConnectionKinds, ", ")), nil
+	}
+
+	connections, err := common.ListConnections(ctx, kind, status, pid)
+	if err != nil {
+		return toolErrorf("Failed to list connections: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(connections, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal connections: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
 
-	// Get OS information
-	if output, err := exec.Command("uname", "-a").Output(); err == nil {
-		result.WriteString("System: " + strings.TrimSpace(string(output)) + "\n")
+func HandleListListeningPorts(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ports, err := common.ListListeningPorts(ctx)
+	if err != nil {
+		return toolErrorf("Failed to list listening ports: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(ports, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal listening ports: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleGetRoutingTable(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	routes, err := common.GetRoutingTable()
+	if err != nil {
+		return toolErrorf("Failed to read routing table: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal routes: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleWaitForPort(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	url := mcp.ParseString(req, "url", "")
+	host := mcp.ParseString(req, "host", "localhost")
+	port := int(mcp.ParseFloat64(req, "port", 0))
+	expectedStatus := int(mcp.ParseFloat64(req, "expected_status", 0))
+	timeout := time.Duration(mcp.ParseFloat64(req, "timeout_seconds", 30)) * time.Second
+	interval := time.Duration(mcp.ParseFloat64(req, "interval_ms", 500)) * time.Millisecond
+
+	if (url == "") == (port == 0) {
+		return toolErrorf("Set either port or url"), nil
+	}
+	if port < 0 || port > 65535 {
+		return toolErrorf("Invalid port"), nil
+	}
+	if timeout <= 0 {
+		return toolErrorf("timeout_seconds must be greater than 0"), nil
+	}
+	if interval < 50*time.Millisecond {
+		interval = 50 * time.Millisecond
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var result types.WaitResult
+	if url != "" {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return toolErrorf("url must start with http:// or https://"), nil
+		}
+		result = common.WaitForURL(waitCtx, url, expectedStatus, interval)
+	} else {
+		result = common.WaitForPort(waitCtx, net.JoinHostPort(host, strconv.Itoa(port)), interval)
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal wait result: %v", err), nil
+	}
+
+	toolResult := mcp.NewToolResultText(string(jsonData))
+	toolResult.IsError = !result.Ready
+	return toolResult, nil
+}
+
+func HandleWatchAndRun(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return toolErrorf("Invalid command parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	// Sanitize the command
+	command = common.SanitizeCommand(command)
+
+	// Security check
+	if common.IsCommandBlocked(command) {
+		return toolErrorf("Command contains blocked patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, command); result != nil {
+		return result, nil
 	}
 
-	// Get uptime
-	if output, err := exec.Command("uptime").Output(); err == nil {
-		result.WriteString("Uptime: " + strings.TrimSpace(string(output)) + "\n")
+	cfg := common.Get()
+	shell := mcp.ParseString(req, "shell", cfg.DefaultShell)
+	pattern := mcp.ParseString(req, "pattern", "")
+	workingDir := mcp.ParseString(req, "working_dir", "")
+	includeHidden := mcp.ParseBoolean(req, "include_hidden", false)
+	runOnStart := mcp.ParseBoolean(req, "run_on_start", false)
+	debounce := time.Duration(mcp.ParseFloat64(req, "debounce_ms", 500)) * time.Millisecond
+	pollInterval := time.Duration(mcp.ParseFloat64(req, "poll_interval_ms", 500)) * time.Millisecond
+	maxRuns := int(mcp.ParseFloat64(req, "max_runs", 5))
+	duration := time.Duration(mcp.ParseFloat64(req, "duration_seconds", 300)) * time.Second
+	timeout, err := common.ResolveTimeout(common.TimeoutCommand, mcp.ParseFloat64(req, "timeout_seconds", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout_seconds parameter: %v", err), nil
 	}
 
-	// Get memory information
-	if output, err := exec.Command("free", "-h").Output(); err == nil {
-		result.WriteString("\nMemory:\n" + string(output))
+	if maxRuns <= 0 {
+		return toolErrorf("max_runs must be greater than 0"), nil
+	}
+	if duration <= 0 {
+		return toolErrorf("duration_seconds must be greater than 0"), nil
 	}
 
-	// Get disk usage
-	if output, err := exec.Command("df", "-h").Output(); err == nil {
-		result.WriteString("\nDisk Usage:\n" + string(output))
+	if _, err := os.Stat(path); err != nil {
+		return toolErrorf("Failed to access path: %v", err), nil
 	}
 
-	// Get CPU information
-	if output, err := exec.Command("nproc").Output(); err == nil {
-		result.WriteString("\nCPU Cores: " + strings.TrimSpace(string(output)) + "\n")
+	if workingDir != "" && !common.IsPathAllowed(workingDir) {
+		return toolErrorf("Access to working directory is not allowed"), nil
 	}
 
-	// Get load average
-	if output, err := exec.Command("cat", "/proc/loadavg").Output(); err == nil {
-		result.WriteString("Load Average: " + strings.TrimSpace(string(output)) + "\n")
+	watchCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var runs []types.WatchRun
+	runCommand := func(trigger []string) {
+		run := types.WatchRun{
+			Run:       len(runs) + 1,
+			StartedAt: time.Now(),
+			Trigger:   trigger,
+		}
+
+		cmdCtx, cmdCancel := context.WithTimeout(watchCtx, timeout)
+		defer cmdCancel()
+
+		cmd := exec.CommandContext(cmdCtx, shell, common.ShellCommandArgs(shell, command)...)
+		if workingDir != "" {
+			cmd.Dir = workingDir
+		}
+
+		var output []byte
+		_, err := common.SandboxCommand(cmd)
+		ran := err == nil
+		if ran {
+			common.RecordCommands(sessionID(ctx), 1)
+			output, err = cmd.CombinedOutput()
+		}
+		run.Duration = time.Since(run.StartedAt).Round(time.Millisecond).String()
+		run.Output = common.TruncateString(string(output), 4096)
+		if err != nil {
+			run.ExitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				run.ExitCode = exitErr.ExitCode()
+			}
+			run.Error = err.Error()
+		}
+		if ran {
+			common.RecordCommand(types.CommandHistoryEntry{
+				Tool:       "watch_and_run",
+				Command:    command,
+				Shell:      shell,
+				WorkingDir: cmd.Dir,
+				StartedAt:  run.StartedAt,
+				DurationMs: time.Since(run.StartedAt).Milliseconds(),
+				ExitCode:   run.ExitCode,
+				Error:      run.Error,
+				Output:     string(output),
+			})
+		}
+
+		runs = append(runs, run)
+	}
+
+	if runOnStart {
+		runCommand(nil)
+	}
+
+	if len(runs) < maxRuns {
+		opts := common.WatchOptions{
+			Pattern:       pattern,
+			IncludeHidden: includeHidden,
+			PollInterval:  pollInterval,
+			Debounce:      debounce,
+		}
+		err = common.WatchForChanges(watchCtx, path, opts, func(changed []string) bool {
+			runCommand(changed)
+			return len(runs) < maxRuns && watchCtx.Err() == nil
+		})
+		if err != nil {
+			return toolErrorf("Failed to watch path: %v", err), nil
+		}
+	}
+
+	stopReason := "max_runs reached"
+	if len(runs) < maxRuns {
+		stopReason = "duration elapsed"
+		if ctx.Err() != nil {
+			stopReason = "cancelled"
+		}
+	}
+
+	summary := map[string]interface{}{
+		"path":        path,
+		"command":     command,
+		"runs":        runs,
+		"total_runs":  len(runs),
+		"stop_reason": stopReason,
+	}
+
+	jsonData, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal watch results: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleWatchCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	command, err := req.RequireString("command")
+	if err != nil {
+		return toolErrorf("Invalid command parameter: %v", err), nil
+	}
+
+	// Sanitize the command
+	command = common.SanitizeCommand(command)
+
+	// Security check
+	if common.IsCommandBlocked(command) {
+		return toolErrorf("Command contains blocked patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, command); result != nil {
+		return result, nil
+	}
+
+	cfg := common.Get()
+	shell := mcp.ParseString(req, "shell", cfg.DefaultShell)
+	workingDir := mcp.ParseString(req, "working_dir", "")
+	interval := time.Duration(mcp.ParseFloat64(req, "interval_seconds", 5) * float64(time.Second))
+	duration := time.Duration(mcp.ParseFloat64(req, "duration_seconds", 300)) * time.Second
+	timeout, err := common.ResolveTimeout(common.TimeoutCommand, mcp.ParseFloat64(req, "timeout_seconds", 0))
+	if err != nil {
+		return toolErrorf("Invalid timeout_seconds parameter: %v", err), nil
+	}
+	untilPattern := mcp.ParseString(req, "until_pattern", "")
+	stopOnChange := mcp.ParseBoolean(req, "stop_on_change", false)
+
+	if interval < time.Second {
+		return toolErrorf("interval_seconds must be at least 1"), nil
+	}
+	if duration <= 0 {
+		return toolErrorf("duration_seconds must be greater than 0"), nil
+	}
+	if workingDir != "" && !common.IsPathAllowed(workingDir) {
+		return toolErrorf("Access to working directory is not allowed"), nil
+	}
+
+	var until *regexp.Regexp
+	if untilPattern != "" {
+		if until, err = regexp.Compile(untilPattern); err != nil {
+			return toolErrorf("Invalid until_pattern: %v", err), nil
+		}
+	}
+
+	watchCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	runCommand := func() (string, int, error) {
+		cmdCtx, cmdCancel := context.WithTimeout(watchCtx, timeout)
+		defer cmdCancel()
+
+		cmd := exec.CommandContext(cmdCtx, shell, common.ShellCommandArgs(shell, command)...)
+		if workingDir != "" {
+			cmd.Dir = workingDir
+		}
+
+		output := common.NewOutputBuffer(cfg.MaxOutputBytes)
+		cmd.Stdout, cmd.Stderr = output, output
+		if _, err := common.SandboxCommand(cmd); err != nil {
+			return output.String(), common.CommandExitCode(err), err
+		}
+		common.RecordCommands(sessionID(ctx), 1)
+		startedAt := time.Now()
+		err := cmd.Run()
+		common.RecordCommand(types.CommandHistoryEntry{
+			Tool:       "watch_command",
+			Command:    command,
+			Shell:      shell,
+			WorkingDir: cmd.Dir,
+			StartedAt:  startedAt,
+			DurationMs: time.Since(startedAt).Milliseconds(),
+			ExitCode:   common.CommandExitCode(err),
+			Error:      errorText(err),
+			Output:     output.String(),
+			Truncated:  output.Truncated(),
+		})
+		return output.String(), common.CommandExitCode(err), err
+	}
+
+	streamer := newCommandStreamer(ctx, req, "watch_command")
+	var changes []types.WatchChange
+	var lastOutput string
+	lastExitCode := 0
+	runs := 0
+	stopReason := "duration elapsed"
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		output, exitCode, runErr := runCommand()
+		if watchCtx.Err() != nil && runs > 0 {
+			break
+		}
+		runs++
+
+		changed := runs > 1 && (output != lastOutput || exitCode != lastExitCode)
+		if changed {
+			change := types.WatchChange{
+				Run:      runs,
+				At:       time.Now(),
+				ExitCode: exitCode,
+				Diff:     common.UnifiedDiff(fmt.Sprintf("run %d", runs-1), fmt.Sprintf("run %d", runs), lastOutput, output, 3),
+			}
+			if runErr != nil && exitCode == -1 {
+				change.Error = runErr.Error()
+			}
+			changes = append(changes, change)
+			if streamer != nil {
+				fmt.Fprintf(streamer.Writer(""), "Output changed on run %d (exit code %d)\n%s", runs, exitCode, change.Diff)
+			}
+		}
+		lastOutput, lastExitCode = output, exitCode
+
+		if until != nil && until.MatchString(output) {
+			stopReason = "until_pattern matched"
+			break
+		}
+		if stopOnChange && changed {
+			stopReason = "output changed"
+			break
+		}
+
+		select {
+		case <-watchCtx.Done():
+		case <-ticker.C:
+		}
+		if watchCtx.Err() != nil {
+			break
+		}
+	}
+	if streamer != nil {
+		streamer.Close()
+	}
+	if ctx.Err() != nil {
+		stopReason = "cancelled"
+	}
+
+	summary := map[string]interface{}{
+		"command":        command,
+		"runs":           runs,
+		"changes":        changes,
+		"total_changes":  len(changes),
+		"last_output":    lastOutput,
+		"last_exit_code": lastExitCode,
+		"stop_reason":    stopReason,
+	}
+
+	jsonData, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal watch results: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleStartCommand(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	command, err := req.RequireString("command")
+	if err != nil {
+		return toolErrorf("Invalid command parameter: %v", err), nil
+	}
+
+	// Sanitize the command
+	command = common.SanitizeCommand(command)
+
+	// Security check
+	if common.IsCommandBlocked(command) {
+		return toolErrorf("Command contains blocked patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, command); result != nil {
+		return result, nil
+	}
+
+	cfg := common.Get()
+	shell := mcp.ParseString(req, "shell", cfg.DefaultShell)
+	workingDir := mcp.ParseString(req, "working_dir", "")
+	wait := time.Duration(mcp.ParseFloat64(req, "wait_ms", 500)) * time.Millisecond
+	opts := common.SessionOptions{
+		PTY:  mcp.ParseBoolean(req, "pty", false),
+		Rows: int(mcp.ParseFloat64(req, "rows", 24)),
+		Cols: int(mcp.ParseFloat64(req, "cols", 80)),
+	}
+
+	if workingDir != "" && !common.IsPathAllowed(workingDir) {
+		return toolErrorf("Access to working directory is not allowed"), nil
+	}
+
+	session, err := common.StartSession(command, shell, workingDir, opts)
+	if err != nil {
+		return toolErrorf("Failed to start command: %v", err), nil
+	}
+	common.RecordCommands(sessionID(ctx), 1)
+
+	// Return whatever the command printed while starting up
+	output, err := common.ReadSessionOutput(session.ID, wait, 65536)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal session: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleReadSessionOutput(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := req.RequireString("session_id")
+	if err != nil {
+		return toolErrorf("Invalid session_id parameter: %v", err), nil
+	}
+
+	wait := time.Duration(mcp.ParseFloat64(req, "wait_ms", 1000)) * time.Millisecond
+	maxBytes := int(mcp.ParseFloat64(req, "max_bytes", 65536))
+	if maxBytes <= 0 {
+		return toolErrorf("max_bytes must be greater than 0"), nil
+	}
+
+	output, err := common.ReadSessionOutput(sessionID, wait, maxBytes)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal session output: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleWriteSessionInput(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := req.RequireString("session_id")
+	if err != nil {
+		return toolErrorf("Invalid session_id parameter: %v", err), nil
+	}
+
+	input := mcp.ParseString(req, "input", "")
+	appendNewline := mcp.ParseBoolean(req, "append_newline", true)
+	closeStdin := mcp.ParseBoolean(req, "close_stdin", false)
+
+	if input == "" && !closeStdin {
+		return toolErrorf("Either input or close_stdin must be provided"), nil
+	}
+	if input != "" && appendNewline && !strings.HasSuffix(input, "\n") {
+		input += "\n"
+	}
+
+	// The session may be a shell reading its commands from stdin, so the
+	// input is checked like a script, together with what earlier writes
+	// left unterminated, before any of it is written
+	script, err := common.SessionInputScript(sessionID, input)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	if strings.TrimSpace(script) != "" {
+		if common.IsCommandBlocked(script) {
+			return toolErrorf("Session input %q contains blocked patterns", script), nil
+		}
+		if result := requireCommandApproval(ctx, req, script); result != nil {
+			return result, nil
+		}
+	}
+
+	if err := common.WriteSessionInput(sessionID, input, script, closeStdin); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	result := common.Localize("Wrote %d bytes to %s", len(input), sessionID)
+	if closeStdin {
+		result += " and closed its stdin"
+	}
+	return mcp.NewToolResultText(result), nil
+}
+
+func HandleTerminateSession(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := req.RequireString("session_id")
+	if err != nil {
+		return toolErrorf("Invalid session_id parameter: %v", err), nil
+	}
+
+	force := mcp.ParseBoolean(req, "force", false)
+	grace := time.Duration(mcp.ParseFloat64(req, "grace_seconds", 5)) * time.Second
+
+	session, err := common.TerminateSession(sessionID, force, grace)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal session: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleListSessions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(common.ListSessions(), "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal sessions: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleReadSessionScreen(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := req.RequireString("session_id")
+	if err != nil {
+		return toolErrorf("Invalid session_id parameter: %v", err), nil
+	}
+
+	wait := time.Duration(mcp.ParseFloat64(req, "wait_ms", 0)) * time.Millisecond
+
+	screen, err := common.ReadSessionScreen(sessionID, wait)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(screen, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal screen: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleResizeSession(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := req.RequireString("session_id")
+	if err != nil {
+		return toolErrorf("Invalid session_id parameter: %v", err), nil
+	}
+
+	rows := int(mcp.ParseFloat64(req, "rows", 0))
+	cols := int(mcp.ParseFloat64(req, "cols", 0))
+
+	if err := common.ResizeSession(sessionID, rows, cols); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Resized %s to %d rows x %d columns", sessionID, rows, cols)), nil
+}
+
+func HandleGetCommandHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filter := common.CommandHistoryFilter{
+		Status: mcp.ParseString(req, "status", ""),
+		Query:  mcp.ParseString(req, "query", ""),
+		Tool:   mcp.ParseString(req, "tool", ""),
+		Limit:  int(mcp.ParseFloat64(req, "limit", 50)),
+	}
+	if filter.Status != "" && filter.Status != "success" && filter.Status != "failure" {
+		return toolErrorf("Invalid status %q: use success or failure", filter.Status), nil
+	}
+
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := mcp.ParseString(req, name, "")
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err == nil {
+			*target = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+			*target = t
+		} else {
+			return toolErrorf("Invalid %s value %q: use an RFC3339 timestamp or a duration like 15m", name, value), nil
+		}
+	}
+
+	entries, err := common.ListCommandHistory(filter)
+	if err != nil {
+		return toolErrorf("Failed to read command history: %v", err), nil
+	}
+	if len(entries) == 0 {
+		return mcp.NewToolResultText(common.Localize("No commands recorded")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal command history: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// jobCallerKey marks the context of a tool call made by a scheduled job
+// with the jobCaller that scheduled it
+type jobCallerKey struct{}
+
+// jobCaller is the client and session a scheduled job calls tools as
+type jobCaller struct {
+	client  string
+	session string
+}
+
+// EnableJobToolCalls lets scheduled jobs call the tools registered on s by
+// sending it tools/call requests, made as the client that scheduled the job
+// so its role and session quotas apply
+func EnableJobToolCalls(s *server.MCPServer) {
+	common.SetJobToolInvoker(func(ctx context.Context, job types.ScheduledJob) (string, bool, error) {
+		name := job.Tool
+		message, err := json.Marshal(map[string]any{
+			"jsonrpc": mcp.JSONRPC_VERSION,
+			"id":      1,
+			"method":  mcp.MethodToolsCall,
+			"params":  map[string]any{"name": name, "arguments": job.Arguments},
+		})
+		if err != nil {
+			return "", false, err
+		}
+
+		ctx = context.WithValue(ctx, jobCallerKey{}, jobCaller{client: job.Client, session: job.Session})
+		switch response := s.HandleMessage(ctx, message).(type) {
+		case mcp.JSONRPCError:
+			return "", false, fmt.Errorf("%s", response.Error.Message)
+		case mcp.JSONRPCResponse:
+			result, ok := response.Result.(mcp.CallToolResult)
+			if !ok {
+				return "", false, fmt.Errorf("unexpected result from %s", name)
+			}
+			var texts []string
+			for _, content := range result.Content {
+				if text, ok := mcp.AsTextContent(content); ok {
+					texts = append(texts, text.Text)
+				}
+			}
+			return strings.Join(texts, "\n"), result.IsError, nil
+		default:
+			return "", false, fmt.Errorf("no response from %s", name)
+		}
+	})
+}
+
+func HandleScheduleJob(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	cfg := common.Get()
+	job := types.ScheduledJob{
+		Name:            mcp.ParseString(req, "name", ""),
+		Command:         mcp.ParseString(req, "command", ""),
+		Shell:           mcp.ParseString(req, "shell", cfg.DefaultShell),
+		WorkingDir:      mcp.ParseString(req, "working_dir", ""),
+		Tool:            mcp.ParseString(req, "tool", ""),
+		Cron:            mcp.ParseString(req, "cron", ""),
+		IntervalSeconds: int(mcp.ParseFloat64(req, "interval_seconds", 0)),
+		TimeoutSeconds:  int(mcp.ParseFloat64(req, "timeout_seconds", 300)),
+	}
+
+	if job.Command != "" {
+		job.Command = common.SanitizeCommand(job.Command)
+	} else {
+		job.Shell = ""
+	}
+	if job.WorkingDir != "" && !common.IsPathAllowed(job.WorkingDir) {
+		return toolErrorf("Access to working directory is not allowed"), nil
+	}
+	if arguments := mcp.ParseString(req, "arguments", ""); arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &job.Arguments); err != nil {
+			return toolErrorf("Invalid arguments parameter: must be a JSON object: %v", err), nil
+		}
+	}
+
+	if job.Command != "" {
+		if common.IsCommandBlocked(job.Command) {
+			return toolErrorf("Command contains blocked patterns"), nil
+		}
+		if result := requireCommandApproval(ctx, req, job.Command); result != nil {
+			return result, nil
+		}
+	}
+
+	job.Client, job.Session = clientName(ctx), sessionID(ctx)
+	job, err := common.ScheduleJob(job)
+	if err != nil {
+		return toolErrorf("Failed to schedule job: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal job: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleListJobs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobs := common.ListJobs()
+	if len(jobs) == 0 {
+		return mcp.NewToolResultText(common.Localize("No scheduled jobs")), nil
+	}
+
+	jsonData, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal jobs: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleCancelJob(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobID, err := req.RequireString("job_id")
+	if err != nil {
+		return toolErrorf("Invalid job_id parameter: %v", err), nil
+	}
+
+	job, err := common.CancelJob(jobID)
+	if err != nil {
+		return toolErrorf("Failed to cancel job: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Cancelled %s after %d runs", job.ID, job.RunCount)), nil
+}
+
+func HandleGetJobRuns(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobID, err := req.RequireString("job_id")
+	if err != nil {
+		return toolErrorf("Invalid job_id parameter: %v", err), nil
+	}
+
+	limit := int(mcp.ParseFloat64(req, "limit", 10))
+
+	runs, err := common.GetJobRuns(jobID, limit)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	if len(runs) == 0 {
+		return mcp.NewToolResultText(common.Localize("%s has not run yet", jobID)), nil
+	}
+
+	jsonData, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal job runs: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleRunTemplate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	template, err := common.GetCommandTemplate(name)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Argument values may be given as JSON strings, numbers or booleans
+	arguments := make(map[string]string)
+	if argumentsJSON := mcp.ParseString(req, "arguments", ""); argumentsJSON != "" {
+		var raw map[string]any
+		if err := json.Unmarshal([]byte(argumentsJSON), &raw); err != nil {
+			return toolErrorf("Invalid arguments parameter: must be a JSON object: %v", err), nil
+		}
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				arguments[key] = v
+			case float64, bool:
+				arguments[key] = fmt.Sprint(v)
+			default:
+				return toolErrorf("Invalid argument %s: must be a string, number or boolean", key), nil
+			}
+		}
+	}
+
+	cfg := common.Get()
+	shell := template.Shell
+	if shell == "" {
+		shell = cfg.DefaultShell
+	}
+
+	command, err := common.RenderCommandTemplate(template, shell, arguments)
+	if err != nil {
+		return toolErrorf("Failed to render template %s: %v", name, err), nil
+	}
+	if common.IsCommandBlocked(command) {
+		return toolErrorf("Command contains blocked patterns"), nil
+	}
+	if result := requireCommandApproval(ctx, req, command); result != nil {
+		return result, nil
+	}
+
+	timeout, err := common.ResolveTimeout(common.TimeoutCommand, float64(template.TimeoutSeconds))
+	if err != nil {
+		return toolErrorf("Template %s: %v", name, err), nil
+	}
+
+	return runShellCommand(ctx, req, shellCommand{
+		tool:          "run_template",
+		command:       command,
+		shell:         shell,
+		workingDir:    template.WorkingDir,
+		timeout:       timeout,
+		captureStderr: true,
+		maxOutput:     cfg.MaxOutputBytes,
+	})
+}
+
+func HandleRunPipeline(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	stagesJSON, err := req.RequireString("stages")
+	if err != nil {
+		return toolErrorf("Invalid stages parameter: %v", err), nil
+	}
+
+	var stages []types.PipelineStage
+	if err := json.Unmarshal([]byte(stagesJSON), &stages); err != nil {
+		return toolErrorf("Invalid stages parameter: must be a JSON array of stages: %v", err), nil
+	}
+	for i := range stages {
+		stages[i].Command = common.SanitizeCommand(stages[i].Command)
+	}
+	if _, err := common.ValidatePipeline(stages); err != nil {
+		return toolErrorf("Invalid pipeline: %v", err), nil
+	}
+	for _, stage := range stages {
+		if result := requireCommandApproval(ctx, req, stage.Command); result != nil {
+			return result, nil
+		}
+	}
+
+	cfg := common.Get()
+	workingDir := mcp.ParseString(req, "working_dir", "")
+	if workingDir != "" && !common.IsPathAllowed(workingDir) {
+		return toolErrorf("Access to working directory %s is not allowed", workingDir), nil
+	}
+
+	opts := common.PipelineOptions{
+		Shell:      mcp.ParseString(req, "shell", cfg.DefaultShell),
+		WorkingDir: workingDir,
+		FailFast:   mcp.ParseBoolean(req, "fail_fast", true),
+		MaxOutput:  mcp.ParseInt(req, "max_output_bytes", 4096),
+	}
+
+	// Report each finished stage to the client as the pipeline runs
+	if streamer := newCommandStreamer(ctx, req, "run_pipeline"); streamer != nil {
+		defer streamer.Close()
+		progress := streamer.Writer("")
+		opts.OnStage = func(done, total int, stage types.PipelineStageResult) {
+			fmt.Fprintf(progress, "[%d/%d] %s: %s (exit code %d, %dms)\n", done, total, stage.Name, stage.Status, stage.ExitCode, stage.DurationMs)
+		}
+	}
+
+	result, err := common.RunPipeline(ctx, stages, opts)
+	if err != nil {
+		return toolErrorf("Invalid pipeline: %v", err), nil
+	}
+	for _, stage := range result.Stages {
+		if stage.Status != "skipped" {
+			common.RecordCommands(sessionID(ctx), 1)
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal pipeline result: %v", err), nil
+	}
+
+	toolResult := mcp.NewToolResultText(string(jsonData))
+	toolResult.IsError = !result.Success
+	return toolResult, nil
+}
+
+// sessionID returns the ID of the MCP client session of a request, or of
+// the session that scheduled the job making it
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	if caller, ok := ctx.Value(jobCallerKey{}).(jobCaller); ok {
+		return caller.session
+	}
+	return ""
+}
+
+func HandleSetWorkingDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session := sessionID(ctx)
+	if mcp.ParseBoolean(req, "reset", false) {
+		common.ClearSessionWorkingDirectory(session)
+		return mcp.NewToolResultText(common.Localize("Working directory reset; commands run in the server's working directory")), nil
+	}
+
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	dir, err := common.SetSessionWorkingDirectory(session, path)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Working directory: %s", dir)), nil
+}
+
+func HandleGetWorkingDirectory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if dir := common.SessionWorkingDirectory(sessionID(ctx)); dir != "" {
+		return mcp.NewToolResultText(dir), nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return toolErrorf("Failed to get working directory: %v", err), nil
+	}
+	return mcp.NewToolResultText(dir), nil
+}
+
+func HandleExecuteCommands(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	commandsJSON, err := req.RequireString("commands")
+	if err != nil {
+		return toolErrorf("Invalid commands parameter: %v", err), nil
+	}
+
+	// Each command is a string or an object with its own settings
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(commandsJSON), &raw); err != nil {
+		return toolErrorf("Invalid commands parameter: must be a JSON array: %v", err), nil
+	}
+
+	cfg := common.Get()
+	session := sessionID(ctx)
+	workingDir := mcp.ParseString(req, "working_dir", "")
+	if workingDir == "" {
+		workingDir = common.SessionWorkingDirectory(session)
+	} else if workingDir, err = common.ResolveSessionPath(session, workingDir); err != nil {
+		return toolErrorf("Invalid working_dir parameter: %v", err), nil
+	}
+	defaults := types.ParallelCommand{
+		Shell:          mcp.ParseString(req, "shell", cfg.DefaultShell),
+		WorkingDir:     workingDir,
+		TimeoutSeconds: mcp.ParseInt(req, "timeout_seconds", 0),
+	}
+
+	commands := make([]types.ParallelCommand, 0, len(raw))
+	for i, item := range raw {
+		command := defaults
+		if err := json.Unmarshal(item, &command.Command); err != nil {
+			if err := json.Unmarshal(item, &command); err != nil {
+				return toolErrorf("Invalid command %d: must be a string or an object: %v", i+1, err), nil
+			}
+		}
+		if command.WorkingDir != defaults.WorkingDir {
+			if command.WorkingDir, err = common.ResolveSessionPath(session, command.WorkingDir); err != nil {
+				return toolErrorf("Invalid working_dir of command %d: %v", i+1, err), nil
+			}
+		}
+
+		command.Command = common.SanitizeCommand(command.Command)
+		if common.IsCommandBlocked(command.Command) {
+			return toolErrorf("Command %d contains blocked patterns", i+1), nil
+		}
+		if result := requireCommandApproval(ctx, req, command.Command); result != nil {
+			return result, nil
+		}
+		commands = append(commands, command)
+	}
+
+	parallel := mcp.ParseInt(req, "max_parallel", 4)
+	maxOutput := int(mcp.ParseFloat64(req, "max_output_bytes", float64(cfg.MaxOutputBytes)))
+	result, err := common.RunCommands(ctx, commands, parallel, maxOutput)
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	common.RecordCommands(sessionID(ctx), len(result.Results))
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal command results: %v", err), nil
 	}
 
-	return mcp.NewToolResultText(result.String()), nil
+	toolResult := mcp.NewToolResultText(string(jsonData))
+	toolResult.IsError = result.Failed > 0
+	return toolResult, nil
 }
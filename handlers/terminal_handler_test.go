@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"jarvis/internal/common"
+)
+
+func TestOutputFileReplacesTargetOnClose(t *testing.T) {
+	dir := allowedTempDir(t)
+	path := filepath.Join(dir, "output.log")
+	if err := os.WriteFile(path, []byte("previous run"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := createOutputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("first line\n"))
+	if data, _ := os.ReadFile(path); string(data) != "previous run" {
+		t.Errorf("target changed before close: %q", data)
+	}
+	file.Write([]byte("second line\n"))
+	if err := file.Close(""); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first line\nsecond line\n" {
+		t.Errorf("output file contains %q", data)
+	}
+}
+
+func TestOutputFileRespectsMaxWriteBytes(t *testing.T) {
+	dir := allowedTempDir(t)
+	path := filepath.Join(dir, "output.log")
+
+	previous := common.Get().MaxWriteBytes
+	if err := common.Set("maxWriteBytes", "8"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { common.Set("maxWriteBytes", strconv.FormatInt(previous, 10)) })
+
+	file, err := createOutputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := file.Write([]byte("more than eight bytes")); n != 21 || err != nil {
+		t.Errorf("Write returned %d, %v; want the write to succeed for the command", n, err)
+	}
+	if err := file.Close(""); err == nil {
+		t.Error("Close succeeded for output exceeding maxWriteBytes")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("output file was written despite the quota: %v", err)
+	}
+}
+
+func TestWriteSessionInputChecksInputAcrossWrites(t *testing.T) {
+	session, err := common.StartSession("cat > /dev/null", "/bin/sh", t.TempDir(), common.SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { common.TerminateSession(session.ID, true, 0) })
+
+	write := func(input string, appendNewline bool) *mcp.CallToolResult {
+		t.Helper()
+		result, err := HandleWriteSessionInput(context.Background(), toolRequest(map[string]any{
+			"session_id":     session.ID,
+			"input":          input,
+			"append_newline": appendNewline,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := write("rm -r", false); result.IsError {
+		t.Fatalf("first half of a command was refused: %v", result.Content)
+	}
+	if result := write("f /", true); !result.IsError {
+		t.Error("a blocked command split across two writes was written")
+	}
+	// The refused write leaves "rm -r" waiting for the rest of its line
+	if result := write("\n", false); result.IsError {
+		t.Fatalf("ending the line was refused: %v", result.Content)
+	}
+	if result := write("rm \\\n-rf /", true); !result.IsError {
+		t.Error("a blocked command continued with a backslash was written")
+	}
+}
+
+func TestWriteSessionInputReadsCarriageReturnsOnPTY(t *testing.T) {
+	session, err := common.StartSession("cat > /dev/null", "/bin/sh", t.TempDir(), common.SessionOptions{PTY: true, Rows: 24, Cols: 80})
+	if err != nil {
+		t.Skipf("pseudo-terminal unavailable: %v", err)
+	}
+	t.Cleanup(func() { common.TerminateSession(session.ID, true, 0) })
+
+	result, err := HandleWriteSessionInput(context.Background(), toolRequest(map[string]any{
+		"session_id": session.ID,
+		"input":      "echo hi\rrm -rf /",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Error("a blocked command after a carriage return was written to a pty session")
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"jarvis/internal/common"
 	"jarvis/internal/types"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -15,28 +17,33 @@ import (
 func HandleEditBlock(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	startLine := int(mcp.ParseFloat64(req, "start_line", 1))
 	endLine := int(mcp.ParseFloat64(req, "end_line", 1))
 	replacement, err := req.RequireString("replacement")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid replacement parameter: %v", err)), nil
+		return toolErrorf("Invalid replacement parameter: %v", err), nil
 	}
 
 	showDiff := mcp.ParseBoolean(req, "show_diff", true)
 	createBackup := mcp.ParseBoolean(req, "create_backup", true)
 	validateSyntax := mcp.ParseBoolean(req, "validate_syntax", false)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
 
 	// Read file
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+		return toolErrorf("Failed to read file: %v", err), nil
 	}
 
 	originalContent := string(content)
@@ -44,48 +51,59 @@ func HandleEditBlock(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 
 	// Validate line range
 	if err := common.ValidateLineRange(startLine, endLine, len(lines)); err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
-	}
-
-	// Create backup
-	if createBackup {
-		if _, err := common.CreateBackup(path); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
-		}
+		return toolErrorOf(err), nil
 	}
 
-	// Get original section for diff
-	startIdx := startLine - 1
-	endIdx := endLine
-	originalSection := common.JoinLines(lines[startIdx:endIdx])
-
 	// Apply replacement
-	newLines := make([]string, 0, len(lines)+(strings.Count(replacement, "\n")+1)-(endIdx-startIdx))
-	newLines = append(newLines, lines[:startIdx]...)
-	newLines = append(newLines, common.SplitLines(replacement)...)
-	newLines = append(newLines, lines[endIdx:]...)
-
-	newContent := common.JoinLines(newLines)
+	newContent := common.JoinLines(common.SpliceLines(lines, startLine-1, endLine, common.SplitLines(replacement)))
 
 	// Validate syntax if requested
 	if validateSyntax {
 		if err := common.ValidateFileSyntax(path, newContent); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Syntax validation failed: %v", err)), nil
+			return toolErrorf("Syntax validation failed: %v", err), nil
+		}
+	}
+
+	if dryRun {
+		diff := common.EditDiff(path, path, common.JoinLines(lines), newContent, 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, originalContent, newContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Create backup
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
 		}
 	}
 
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
 	// Write file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	err = common.WriteFileAtomic(path, []byte(newContent), 0644)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
+		return toolErrorf("Failed to write file: %v", err), nil
 	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "edit_block", path, content, []byte(newContent))
 
-	result := fmt.Sprintf("Successfully edited lines %d-%d in %s", startLine, endLine, path)
+	result := common.Localize("Successfully edited lines %d-%d in %s", startLine, endLine, path)
 
 	// Show diff if requested
 	if showDiff {
-		diff := common.GenerateCharacterDiff(originalSection, replacement)
-		result += "\n\nDiff:\n" + diff
+		if diff := common.EditDiff(path, path, common.JoinLines(lines), newContent, 3); diff != "" {
+			result += "\n\nDiff:\n" + diff
+		} else {
+			result += "\n\nDiff: no changes"
+		}
 	}
 
 	return mcp.NewToolResultText(result), nil
@@ -94,32 +112,38 @@ func HandleEditBlock(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 func HandleEditFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	operationsStr, err := req.RequireString("operations")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid operations parameter: %v", err)), nil
+		return toolErrorf("Invalid operations parameter: %v", err), nil
 	}
 
 	var operations []types.EditOperation
 	if err := json.Unmarshal([]byte(operationsStr), &operations); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse operations: %v", err)), nil
+		return toolErrorf("Failed to parse operations: %v", err), nil
 	}
 
 	createBackup := mcp.ParseBoolean(req, "create_backup", true)
 	validateOperations := mcp.ParseBoolean(req, "validate_operations", true)
 	showPreview := mcp.ParseBoolean(req, "show_preview", false)
+	showDiff := mcp.ParseBoolean(req, "show_diff", false)
 	atomic := mcp.ParseBoolean(req, "atomic", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
 
 	// Read file
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+		return toolErrorf("Failed to read file: %v", err), nil
 	}
 
 	originalContent := string(content)
@@ -128,110 +152,136 @@ func HandleEditFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 	// Validate operations
 	if validateOperations {
 		if err := common.ValidateEditOperations(lines, operations); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Operation validation failed: %v", err)), nil
+			return toolErrorf("Operation validation failed: %v", err), nil
 		}
 	}
 
 	// Sort operations by start line (descending) to avoid line number shifts
 	sortedOps := common.SortOperationsByLine(operations)
 
+	finalContent := common.JoinLines(common.ApplyEditOperations(lines, sortedOps))
+
 	// Preview mode
-	if showPreview {
-		preview := common.GenerateEditPreview(lines, sortedOps)
-		return mcp.NewToolResultText(fmt.Sprintf("Preview of changes for %s:\n%s", path, preview)), nil
+	if showPreview || dryRun {
+		diff := common.EditDiff(path, path, common.JoinLines(lines), finalContent, 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		prefix := ""
+		if dryRun {
+			prefix = "DRY RUN - "
+		}
+		return mcp.NewToolResultText(common.Localize("%sPreview of changes for %s:\n%s", prefix, path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, originalContent, finalContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	// Create backup
 	if createBackup {
 		if _, err := common.CreateBackup(path); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
+			return toolErrorf("Failed to create backup: %v", err), nil
 		}
 	}
 
-	// Apply operations
-	resultLines := make([]string, len(lines))
-	copy(resultLines, lines)
-
 	if atomic {
-		// Apply all operations atomically
-		for _, op := range sortedOps {
-			startIdx := op.StartLine - 1
-			endIdx := op.EndLine
-
-			newLines := make([]string, 0, len(resultLines)+(strings.Count(op.Replacement, "\n")+1)-(endIdx-startIdx))
-			newLines = append(newLines, resultLines[:startIdx]...)
-			newLines = append(newLines, common.SplitLines(op.Replacement)...)
-			newLines = append(newLines, resultLines[endIdx:]...)
-
-			resultLines = newLines
+		// Apply all operations atomically and write file once
+		if err := common.CheckWriteQuota(int64(len(finalContent))); err != nil {
+			return toolErrorOf(err), nil
 		}
-
-		// Write file once
-		newContent := common.JoinLines(resultLines)
-		if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
+		if err := common.WriteFileAtomic(path, []byte(finalContent), 0644); err != nil {
+			return toolErrorf("Failed to write file: %v", err), nil
 		}
+		common.RecordWrite(sessionID(ctx), int64(len(finalContent)))
+		common.RecordEdit(sessionID(ctx), "edit_file", path, content, []byte(finalContent))
 	} else {
 		// Apply operations one by one
-		for i, op := range sortedOps {
-			startIdx := op.StartLine - 1
-			endIdx := op.EndLine
+		resultLines := lines
+		previous := content
 
-			newLines := make([]string, 0, len(resultLines)+(strings.Count(op.Replacement, "\n")+1)-(endIdx-startIdx))
-			newLines = append(newLines, resultLines[:startIdx]...)
-			newLines = append(newLines, common.SplitLines(op.Replacement)...)
-			newLines = append(newLines, resultLines[endIdx:]...)
-
-			resultLines = newLines
+		for i, op := range sortedOps {
+			splice := common.EditOperationSplice(op)
+			resultLines = common.SpliceLines(resultLines, splice.Start, splice.End, splice.Lines)
 
 			// Write after each operation for non-atomic mode
 			newContent := common.JoinLines(resultLines)
-			if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to write file at operation %d: %v", i+1, err)), nil
+			if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+				return toolErrorf("Stopped at operation %d: %v", i+1, err), nil
 			}
+			if err := common.WriteFileAtomic(path, []byte(newContent), 0644); err != nil {
+				return toolErrorf("Failed to write file at operation %d: %v", i+1, err), nil
+			}
+			common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+			common.RecordEdit(sessionID(ctx), "edit_file", path, previous, []byte(newContent))
+			previous = []byte(newContent)
+		}
+	}
+
+	result := common.Localize("Successfully applied %d operations to %s", len(operations), path)
+	if showDiff {
+		if diff := common.EditDiff(path, path, common.JoinLines(lines), finalContent, 3); diff != "" {
+			result += "\n\nDiff:\n" + diff
 		}
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully applied %d operations to %s", len(operations), path)), nil
+	return mcp.NewToolResultText(result), nil
 }
 
 func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	filesStr, err := req.RequireString("files")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid files parameter: %v", err)), nil
+		return toolErrorf("Invalid files parameter: %v", err), nil
 	}
 
 	var fileRequests []types.FileEditRequest
 	if err := json.Unmarshal([]byte(filesStr), &fileRequests); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse files: %v", err)), nil
+		return toolErrorf("Failed to parse files: %v", err), nil
 	}
 
 	atomic := mcp.ParseBoolean(req, "atomic", true)
 	dryRun := mcp.ParseBoolean(req, "dry_run", false)
 	continueOnError := mcp.ParseBoolean(req, "continue_on_error", false)
 	validateAll := mcp.ParseBoolean(req, "validate_all", true)
+	confirmLargeEdit := mcp.ParseBoolean(req, "confirm_large_edit", false)
 
 	var results []string
 	var errors []string
 
+	// Enforce the per-call file limit before touching anything
+	if !dryRun {
+		if err := common.CheckWriteQuota(make([]int64, len(fileRequests))...); err != nil {
+			return toolErrorOf(err), nil
+		}
+	}
+
 	// Validate all files and operations first if requested
 	if validateAll || atomic {
 		for i, fileReq := range fileRequests {
 			if !common.IsPathAllowed(fileReq.Path) {
-				err := fmt.Sprintf("Access to path %s (file %d) is not allowed", fileReq.Path, i+1)
+				err := common.Localize("Access to path %s (file %d) is not allowed", fileReq.Path, i+1)
 				if atomic {
-					return mcp.NewToolResultError(err), nil
+					return toolError(common.ErrorPathNotAllowed, err), nil
 				}
 				errors = append(errors, err)
 				continue
 			}
 
+			if err := common.CheckExpectedState(fileReq.Path, fileReq.ExpectedChecksum, fileReq.ExpectedMtime); err != nil {
+				errMsg := common.Localize("File %s (file %d): %v", fileReq.Path, i+1, err)
+				if atomic {
+					return toolError(common.ErrorCodeOf(err), errMsg), nil
+				}
+				errors = append(errors, errMsg)
+				continue
+			}
+
 			// Check if file exists and is readable
 			content, err := os.ReadFile(fileReq.Path)
 			if err != nil {
-				errMsg := fmt.Sprintf("File %s (file %d) is not accessible: %v", fileReq.Path, i+1, err)
+				errMsg := common.Localize("File %s (file %d) is not accessible: %v", fileReq.Path, i+1, err)
 				if atomic {
-					return mcp.NewToolResultError(errMsg), nil
+					return toolError(common.ErrorCodeOf(err), errMsg), nil
 				}
 				errors = append(errors, errMsg)
 				continue
@@ -239,25 +289,60 @@ func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp
 
 			lines := common.SplitLines(string(content))
 			if err := common.ValidateEditOperations(lines, fileReq.Operations); err != nil {
-				errMsg := fmt.Sprintf("Invalid operations in file %s: %v", fileReq.Path, err)
+				errMsg := common.Localize("Invalid operations in file %s: %v", fileReq.Path, err)
 				if atomic {
-					return mcp.NewToolResultError(errMsg), nil
+					return toolError(common.ErrorValidationFailed, errMsg), nil
 				}
 				errors = append(errors, errMsg)
 			}
 		}
 
 		if atomic && len(errors) > 0 {
-			return mcp.NewToolResultError("Validation failed: " + strings.Join(errors, "; ")), nil
+			return toolError(common.ErrorValidationFailed, "Validation failed: "+strings.Join(errors, "; ")), nil
+		}
+	}
+
+	// In atomic mode every file is snapshotted before it is written, and a
+	// failure part way through restores all files written so far
+	var tx *common.FileTransaction
+	if atomic && !dryRun {
+		tx = common.NewFileTransaction()
+	}
+	type pendingEdit struct {
+		path          string
+		before, after []byte
+	}
+	var pending []pendingEdit
+	abort := func(code, errMsg string) *mcp.CallToolResult {
+		if tx == nil {
+			return toolError(code, errMsg)
 		}
+		restored, rollbackErrs := tx.Rollback()
+		errMsg = common.Localize("%s; rolled back %d file(s)", errMsg, restored)
+		for _, rollbackErr := range rollbackErrs {
+			errMsg += "; " + rollbackErr.Error()
+		}
+		return toolError(code, errMsg)
 	}
 
 	// Process each file
 	for i, fileReq := range fileRequests {
 		if !common.IsPathAllowed(fileReq.Path) {
-			errMsg := fmt.Sprintf("Access to path %s (file %d) is not allowed", fileReq.Path, i+1)
+			errMsg := common.Localize("Access to path %s (file %d) is not allowed", fileReq.Path, i+1)
+			if atomic {
+				return abort(common.ErrorPathNotAllowed, errMsg), nil
+			}
+			errors = append(errors, errMsg)
+			if !continueOnError {
+				break
+			}
+			continue
+		}
+
+		if err := common.CheckExpectedState(fileReq.Path, fileReq.ExpectedChecksum, fileReq.ExpectedMtime); err != nil {
+			errMsg := common.Localize("File %s (file %d): %v", fileReq.Path, i+1, err)
 			if atomic {
-				return mcp.NewToolResultError(errMsg), nil
+				return abort(common.ErrorCodeOf(err), errMsg), nil
 			}
 			errors = append(errors, errMsg)
 			if !continueOnError {
@@ -268,9 +353,9 @@ func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp
 
 		content, err := os.ReadFile(fileReq.Path)
 		if err != nil {
-			errMsg := fmt.Sprintf("Failed to read file %s: %v", fileReq.Path, err)
+			errMsg := common.Localize("Failed to read file %s: %v", fileReq.Path, err)
 			if atomic {
-				return mcp.NewToolResultError(errMsg), nil
+				return abort(common.ErrorCodeOf(err), errMsg), nil
 			}
 			errors = append(errors, errMsg)
 			if !continueOnError {
@@ -282,17 +367,32 @@ func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp
 		lines := common.SplitLines(string(content))
 
 		if dryRun {
-			preview := common.GenerateEditPreview(lines, fileReq.Operations)
-			results = append(results, fmt.Sprintf("File: %s\n%s", fileReq.Path, preview))
+			if err := common.ValidateEditOperations(lines, fileReq.Operations); err != nil {
+				errors = append(errors, common.Localize("Invalid operations in file %s: %v", fileReq.Path, err))
+				continue
+			}
+			diff := common.EditDiff(fileReq.Path, fileReq.Path, common.JoinLines(lines), common.JoinLines(common.ApplyEditOperations(lines, fileReq.Operations)), 3)
+			if diff == "" {
+				diff = common.Localize("No changes\n")
+			}
+			results = append(results, fmt.Sprintf("File: %s\n%s", fileReq.Path, diff))
 			continue
 		}
 
+		// Snapshot the file before anything touches it so the batch can be
+		// rolled back
+		if tx != nil {
+			if err := tx.Snapshot(fileReq.Path); err != nil {
+				return abort(common.ErrorCodeOf(err), err.Error()), nil
+			}
+		}
+
 		// Create backup if requested
 		if fileReq.CreateBackup {
 			if _, err := common.CreateBackup(fileReq.Path); err != nil {
-				errMsg := fmt.Sprintf("Failed to create backup for %s: %v", fileReq.Path, err)
+				errMsg := common.Localize("Failed to create backup for %s: %v", fileReq.Path, err)
 				if atomic {
-					return mcp.NewToolResultError(errMsg), nil
+					return abort(common.ErrorCodeOf(err), errMsg), nil
 				}
 				errors = append(errors, errMsg)
 				if !continueOnError {
@@ -302,32 +402,31 @@ func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp
 			}
 		}
 
-		// Sort operations by start line (descending)
-		sortedOps := common.SortOperationsByLine(fileReq.Operations)
-
 		// Apply operations
-		resultLines := make([]string, len(lines))
-		copy(resultLines, lines)
-
-		for _, op := range sortedOps {
-			startIdx := op.StartLine - 1
-			endIdx := op.EndLine
-
-			newLines := make([]string, 0, len(resultLines)+(strings.Count(op.Replacement, "\n")+1)-(endIdx-startIdx))
-			newLines = append(newLines, resultLines[:startIdx]...)
-			newLines = append(newLines, common.SplitLines(op.Replacement)...)
-			newLines = append(newLines, resultLines[endIdx:]...)
-
-			resultLines = newLines
-		}
+		resultLines := common.ApplyEditOperations(lines, fileReq.Operations)
 
 		// Write file
 		newContent := common.JoinLines(resultLines)
-		err = os.WriteFile(fileReq.Path, []byte(newContent), 0644)
+		err = common.CheckLargeEdit(fileReq.Path, string(content), newContent, confirmLargeEdit)
+		if err == nil {
+			err = common.CheckWriteQuota(int64(len(newContent)))
+		}
+		if err == nil {
+			err = common.WriteFileAtomic(fileReq.Path, []byte(newContent), 0644)
+		}
+		if err == nil {
+			if tx != nil {
+				// Record once the whole batch has been written
+				pending = append(pending, pendingEdit{fileReq.Path, content, []byte(newContent)})
+			} else {
+				common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+				common.RecordEdit(sessionID(ctx), "edit_multiple_files", fileReq.Path, content, []byte(newContent))
+			}
+		}
 		if err != nil {
-			errMsg := fmt.Sprintf("Failed to write file %s: %v", fileReq.Path, err)
+			errMsg := common.Localize("Failed to write file %s: %v", fileReq.Path, err)
 			if atomic {
-				return mcp.NewToolResultError(errMsg), nil
+				return abort(common.ErrorCodeOf(err), errMsg), nil
 			}
 			errors = append(errors, errMsg)
 			if !continueOnError {
@@ -336,7 +435,12 @@ func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp
 			continue
 		}
 
-		results = append(results, fmt.Sprintf("Successfully applied %d operations to %s", len(fileReq.Operations), fileReq.Path))
+		results = append(results, common.Localize("Successfully applied %d operations to %s", len(fileReq.Operations), fileReq.Path))
+	}
+
+	for _, edit := range pending {
+		common.RecordWrite(sessionID(ctx), int64(len(edit.after)))
+		common.RecordEdit(sessionID(ctx), "edit_multiple_files", edit.path, edit.before, edit.after)
 	}
 
 	// Prepare result
@@ -362,138 +466,1945 @@ func HandleEditMultipleFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp
 func HandleReplaceText(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	find, err := req.RequireString("find")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid find parameter: %v", err)), nil
+		return toolErrorf("Invalid find parameter: %v", err), nil
 	}
 
 	replace, err := req.RequireString("replace")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid replace parameter: %v", err)), nil
+		return toolErrorf("Invalid replace parameter: %v", err), nil
 	}
 
-	regex := mcp.ParseBoolean(req, "regex", false)
-	caseSensitive := mcp.ParseBoolean(req, "case_sensitive", true)
-	wholeWord := mcp.ParseBoolean(req, "whole_word", false)
-	maxReplacements := int(mcp.ParseFloat64(req, "max_replacements", -1))
+	opts := common.ReplaceOptions{
+		Regex:           mcp.ParseBoolean(req, "regex", false),
+		CaseSensitive:   mcp.ParseBoolean(req, "case_sensitive", true),
+		WholeWord:       mcp.ParseBoolean(req, "whole_word", false),
+		Multiline:       mcp.ParseBoolean(req, "multiline", false),
+		DotAll:          mcp.ParseBoolean(req, "dot_all", false),
+		MaxReplacements: int(mcp.ParseFloat64(req, "max_replacements", -1)),
+	}
 	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
 
 	// Read file
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+		return toolErrorf("Failed to read file: %v", err), nil
 	}
 
 	originalContent := string(content)
 
+	// Perform replacement
+	newContent, count, err := common.ReplaceText(originalContent, find, replace, opts)
+	if err != nil {
+		return toolErrorf("Failed to replace text: %v", err), nil
+	}
+
+	if count == 0 {
+		return mcp.NewToolResultText(common.Localize("No occurrences found in %s", path)), nil
+	}
+
+	if dryRun {
+		diff := common.EditDiff(path, path, originalContent, newContent, 3)
+		return mcp.NewToolResultText(common.Localize("DRY RUN - would replace %d occurrences in %s:\n%s", count, path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, originalContent, newContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
 	// Create backup
 	if createBackup {
 		if _, err := common.CreateBackup(path); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
+			return toolErrorf("Failed to create backup: %v", err), nil
 		}
 	}
 
-	// Perform replacement
-	newContent, count, err := common.ReplaceText(originalContent, find, replace, regex, caseSensitive, wholeWord, maxReplacements)
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Write file
+	err = common.WriteFileAtomic(path, []byte(newContent), 0644)
+	if err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "replace_text", path, content, []byte(newContent))
+
+	return mcp.NewToolResultText(common.Localize("Replaced %d occurrences in %s", count, path)), nil
+}
+
+func HandleStrReplaceEdit(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	oldString, err := req.RequireString("old_string")
+	if err != nil {
+		return toolErrorf("Invalid old_string parameter: %v", err), nil
+	}
+
+	newString, err := req.RequireString("new_string")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to replace text: %v", err)), nil
+		return toolErrorf("Invalid new_string parameter: %v", err), nil
+	}
+
+	replaceAll := mcp.ParseBoolean(req, "replace_all", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	showDiff := mcp.ParseBoolean(req, "show_diff", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	if oldString == newString {
+		return toolErrorf("old_string and new_string are identical"), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	// Read file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	newContent, count, err := common.ReplaceExact(string(content), oldString, newString, replaceAll)
+	if err != nil {
+		return toolErrorf("Failed to edit %s: %v", path, err), nil
+	}
+
+	diff := common.EditDiff(path, path, string(content), newContent, 3)
+
+	if dryRun {
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, string(content), newContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Create backup
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	// Write file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	err = common.WriteFileAtomic(path, []byte(newContent), info.Mode().Perm())
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "str_replace_edit", path, content, []byte(newContent))
+
+	result := common.Localize("Replaced %d occurrence(s) in %s", count, path)
+	if showDiff {
+		result += "\n\nDiff:\n" + diff
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Replaced %d occurrences in %s", count, path)), nil
+	return mcp.NewToolResultText(result), nil
 }
 
 func HandleInsertText(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	insertionsStr, err := req.RequireString("insertions")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid insertions parameter: %v", err)), nil
+		return toolErrorf("Invalid insertions parameter: %v", err), nil
 	}
 
 	var insertions = &[]types.TextInsertion{}
 	if err := json.Unmarshal([]byte(insertionsStr), insertions); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse insertions: %v", err)), nil
+		return toolErrorf("Failed to parse insertions: %v", err), nil
 	}
 
 	createBackup := mcp.ParseBoolean(req, "create_backup", true)
 	adjustLineNumbers := mcp.ParseBoolean(req, "adjust_line_numbers", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
 
 	// Read file
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+		return toolErrorf("Failed to read file: %v", err), nil
 	}
 
 	originalContent := string(content)
 
+	// Apply insertions
+	newContent, err := common.ApplyTextInsertions(originalContent, *insertions, adjustLineNumbers)
+	if err != nil {
+		return toolErrorf("Failed to apply insertions: %v", err), nil
+	}
+
+	if dryRun {
+		diff := common.EditDiff(path, path, originalContent, newContent, 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, originalContent, newContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
 	// Create backup
 	if createBackup {
 		if _, err := common.CreateBackup(path); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
+			return toolErrorf("Failed to create backup: %v", err), nil
 		}
 	}
 
-	// Apply insertions
-	newContent, err := common.ApplyTextInsertions(originalContent, *insertions, adjustLineNumbers)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to apply insertions: %v", err)), nil
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
 	}
 
 	// Write file
-	err = os.WriteFile(path, []byte(newContent), 0644)
+	err = common.WriteFileAtomic(path, []byte(newContent), 0644)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
+		return toolErrorf("Failed to write file: %v", err), nil
 	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "insert_text", path, content, []byte(newContent))
 
-	return mcp.NewToolResultText(fmt.Sprintf("Applied %d insertions to %s", len(*insertions), path)), nil
+	return mcp.NewToolResultText(common.Localize("Applied %d insertions to %s", len(*insertions), path)), nil
 }
 
-func HandleFormatCode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func HandleTransformLines(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := req.RequireString("path")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+		return toolErrorf("Invalid path parameter: %v", err), nil
 	}
 
 	if !common.IsPathAllowed(path) {
-		return mcp.NewToolResultError("Access to this path is not allowed"), nil
+		return toolErrorf("Access to this path is not allowed"), nil
 	}
 
-	formatter := mcp.ParseString(req, "formatter", "")
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	operationsStr, err := req.RequireString("operations")
+	if err != nil {
+		return toolErrorf("Invalid operations parameter: %v", err), nil
+	}
+
+	startLine := int(mcp.ParseFloat64(req, "start_line", 1))
+	endLine := int(mcp.ParseFloat64(req, "end_line", -1))
+	opts := common.LineTransformOptions{
+		IgnoreCase: mcp.ParseBoolean(req, "ignore_case", false),
+		Descending: mcp.ParseBoolean(req, "descending", false),
+	}
 	createBackup := mcp.ParseBoolean(req, "create_backup", true)
-	configFile := mcp.ParseString(req, "config_file", "")
+	showDiff := mcp.ParseBoolean(req, "show_diff", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	// Read file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	originalContent := string(content)
+	lines := common.SplitLines(originalContent)
+	if endLine == -1 {
+		endLine = len(lines)
+	}
+
+	// Validate line range
+	if err := common.ValidateLineRange(startLine, endLine, len(lines)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	transformed, err := common.TransformLines(lines[startLine-1:endLine], strings.Split(operationsStr, ","), opts)
+	if err != nil {
+		return toolErrorf("Failed to transform lines: %v", err), nil
+	}
+
+	newContent := common.JoinLines(common.SpliceLines(lines, startLine-1, endLine, transformed))
+
+	diff := common.EditDiff(path, path, common.JoinLines(lines), newContent, 3)
+	if diff == "" {
+		return mcp.NewToolResultText(common.Localize("No changes to lines %d-%d in %s", startLine, endLine, path)), nil
+	}
+
+	if dryRun {
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, originalContent, newContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
 
 	// Create backup
 	if createBackup {
 		if _, err := common.CreateBackup(path); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create backup: %v", err)), nil
+			return toolErrorf("Failed to create backup: %v", err), nil
 		}
 	}
 
-	// Format code
-	err = common.FormatCodeFile(path, formatter, configFile)
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Write file
+	err = common.WriteFileAtomic(path, []byte(newContent), 0644)
+	if err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "transform_lines", path, content, []byte(newContent))
+
+	result := common.Localize("Transformed lines %d-%d in %s (%d -> %d lines)", startLine, endLine, path, endLine-startLine+1, len(transformed))
+	if showDiff {
+		result += "\n\nDiff:\n" + diff
+	}
+
+	return mcp.NewToolResultText(result), nil
+}
+
+func HandleFormatCode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	formatter := mcp.ParseString(req, "formatter", "")
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	configFile := mcp.ParseString(req, "config_file", "")
+	showDiff := mcp.ParseBoolean(req, "show_diff", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+	timeoutSeconds := int(mcp.ParseFloat64(req, "timeout_seconds", 30))
+
+	if configFile != "" && !common.IsPathAllowed(configFile) {
+		return toolErrorf("Access to the config file is not allowed"), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	formatCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	formatted, diagnostics, err := common.FormatCode(formatCtx, path, formatter, configFile, content)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to format code: %v", err)), nil
+		return toolErrorf("Failed to format code: %v", err), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Code formatted successfully: %s", path)), nil
+	diff := common.EditDiff(path, path, string(content), string(formatted), 3)
+
+	var result strings.Builder
+	switch {
+	case diff == "":
+		result.WriteString(common.Localize("Already formatted: %s", path))
+	case dryRun:
+		result.WriteString(common.Localize("DRY RUN - Preview of formatting changes for %s:\n%s", path, diff))
+	default:
+		if err := common.CheckLargeEdit(path, string(content), string(formatted), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		// Create backup
+		if createBackup {
+			if _, err := common.CreateBackup(path); err != nil {
+				return toolErrorf("Failed to create backup: %v", err), nil
+			}
+		}
+
+		if err := common.CheckWriteQuota(int64(len(formatted))); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		if err := common.WriteFileAtomic(path, formatted, info.Mode().Perm()); err != nil {
+			return toolErrorf("Failed to write file: %v", err), nil
+		}
+		common.RecordWrite(sessionID(ctx), int64(len(formatted)))
+		common.RecordEdit(sessionID(ctx), "format_code", path, content, formatted)
+
+		result.WriteString(common.Localize("Code formatted successfully: %s", path))
+		if showDiff {
+			result.WriteString("\n\nDiff:\n" + diff)
+		}
+	}
+
+	if diagnostics != "" {
+		result.WriteString("\n\nFormatter output:\n" + diagnostics)
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+func HandleReadStructured(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	format, err := common.DetectStructuredFormat(path, mcp.ParseString(req, "format", "auto"))
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	valuePath := mcp.ParseString(req, "value_path", "")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	value, err := common.GetStructuredValue(content, format, valuePath)
+	if err != nil {
+		return toolErrorf("Failed to read value: %v", err), nil
+	}
+
+	jsonData, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to marshal value: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func HandleSetStructuredValue(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	valuePath, err := req.RequireString("value_path")
+	if err != nil {
+		return toolErrorf("Invalid value_path parameter: %v", err), nil
+	}
+
+	rawValue, err := req.RequireString("value")
+	if err != nil {
+		return toolErrorf("Invalid value parameter: %v", err), nil
+	}
+
+	format, err := common.DetectStructuredFormat(path, mcp.ParseString(req, "format", "auto"))
+	if err != nil {
+		return toolErrorOf(err), nil
+	}
+	createMissing := mcp.ParseBoolean(req, "create_missing", true)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	var value interface{} = rawValue
+	if !mcp.ParseBoolean(req, "raw_string", false) {
+		value = common.ParseStructuredInput(rawValue)
+	}
+
+	newContent, err := common.SetStructuredValue(content, format, valuePath, value, createMissing)
+	if err != nil {
+		return toolErrorf("Failed to set value: %v", err), nil
+	}
+
+	if err := common.CheckLargeEdit(path, string(content), string(newContent), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Create backup
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	// Write file
+	err = common.WriteFileAtomic(path, newContent, 0644)
+	if err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "set_structured_value", path, content, newContent)
+
+	return mcp.NewToolResultText(common.Localize("Set %s in %s (%s)", valuePath, path, format)), nil
+}
+
+func HandleEditYAML(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	valuePath, err := req.RequireString("value_path")
+	if err != nil {
+		return toolErrorf("Invalid value_path parameter: %v", err), nil
+	}
+
+	action := mcp.ParseString(req, "action", "set")
+	opts := common.YAMLEditOptions{
+		Document:      int(mcp.ParseFloat64(req, "document", -2)),
+		Selector:      mcp.ParseString(req, "select", ""),
+		CreateMissing: mcp.ParseBoolean(req, "create_missing", true),
+	}
+	// Without an explicit document a selector searches every document,
+	// otherwise the first document is edited
+	if opts.Document == -2 {
+		opts.Document = 0
+		if opts.Selector != "" {
+			opts.Document = -1
+		}
+	}
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	var value interface{}
+	if action == "set" {
+		rawValue, err := req.RequireString("value")
+		if err != nil {
+			return toolErrorf("Invalid value parameter: %v", err), nil
+		}
+		value = rawValue
+		if !mcp.ParseBoolean(req, "raw_string", false) {
+			value = common.ParseStructuredInput(rawValue)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	newContent, edited, err := common.EditYAML(content, action, valuePath, value, opts)
+	if err != nil {
+		return toolErrorf("Failed to edit YAML: %v", err), nil
+	}
+
+	diff := common.EditDiff(path, path, string(content), string(newContent), 3)
+	if diff == "" {
+		diff = common.Localize("No changes\n")
+	}
+
+	if dryRun {
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, string(content), string(newContent), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Create backup
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	// Write file
+	err = common.WriteFileAtomic(path, newContent, 0644)
+	if err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "edit_yaml", path, content, newContent)
+
+	format := "Set %s in %d document(s) of %s\n\nDiff:\n%s"
+	if action == "delete" {
+		format = "Deleted %s in %d document(s) of %s\n\nDiff:\n%s"
+	}
+	return mcp.NewToolResultText(common.Localize(format, valuePath, edited, path, diff)), nil
+}
+
+func HandlePatchJSON(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	patch, err := req.RequireString("patch")
+	if err != nil {
+		return toolErrorf("Invalid patch parameter: %v", err), nil
+	}
+
+	patchType := mcp.ParseString(req, "patch_type", "auto")
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	// A JSON Patch is an array of operations, a merge patch is an object
+	if patchType == "auto" {
+		patchType = "merge_patch"
+		if strings.HasPrefix(strings.TrimSpace(patch), "[") {
+			patchType = "json_patch"
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	var newContent []byte
+	switch patchType {
+	case "json_patch":
+		newContent, err = common.ApplyJSONPatch(content, []byte(patch))
+	case "merge_patch":
+		newContent, err = common.ApplyJSONMergePatch(content, []byte(patch))
+	default:
+		return toolErrorf("Unknown patch_type: %s (use auto, json_patch or merge_patch)", patchType), nil
+	}
+	if err != nil {
+		return toolErrorf("Failed to apply patch: %v", err), nil
+	}
+
+	diff := common.EditDiff(path, path, string(content), string(newContent), 3)
+	if diff == "" {
+		return mcp.NewToolResultText(common.Localize("Patch applied cleanly but made no changes to %s", path)), nil
+	}
+
+	if dryRun {
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, string(content), string(newContent), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Create backup
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	// Write file
+	err = common.WriteFileAtomic(path, newContent, 0644)
+	if err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "patch_json", path, content, newContent)
+
+	return mcp.NewToolResultText(common.Localize("Applied %s to %s\n\nDiff:\n%s", strings.ReplaceAll(patchType, "_", " "), path, diff)), nil
+}
+
+func HandleFrontMatter(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	action := mcp.ParseString(req, "action", "get")
+	key := mcp.ParseString(req, "key", "")
+	createMissing := mcp.ParseBoolean(req, "create_missing", true)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	var newContent []byte
+	switch action {
+	case "get":
+		value, err := common.GetFrontMatter(content, key)
+		if err != nil {
+			return toolErrorf("Failed to read front matter: %v", err), nil
+		}
+		jsonData, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to marshal value: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	case "set":
+		rawValue, err := req.RequireString("value")
+		if err != nil {
+			return toolErrorf("Invalid value parameter: %v", err), nil
+		}
+		var value interface{} = rawValue
+		if !mcp.ParseBoolean(req, "raw_string", false) {
+			value = common.ParseStructuredInput(rawValue)
+		}
+		newContent, err = common.SetFrontMatter(content, key, value, createMissing)
+		if err != nil {
+			return toolErrorf("Failed to set front matter: %v", err), nil
+		}
+	case "delete":
+		newContent, err = common.DeleteFrontMatter(content, key)
+		if err != nil {
+			return toolErrorf("Failed to delete front matter key: %v", err), nil
+		}
+	default:
+		return toolErrorf("Unknown action: %s (use get, set or delete)", action), nil
+	}
+
+	if dryRun {
+		diff := common.EditDiff(path, path, string(content), string(newContent), 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckLargeEdit(path, string(content), string(newContent), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	// Create backup
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	// Write file
+	err = common.WriteFileAtomic(path, newContent, 0644)
+	if err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "front_matter", path, content, newContent)
+
+	if action == "delete" {
+		return mcp.NewToolResultText(common.Localize("Deleted %s from the front matter of %s", key, path)), nil
+	}
+	return mcp.NewToolResultText(common.Localize("Set %s in the front matter of %s", key, path)), nil
+}
+
+func HandleApplyPatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	patchText, err := req.RequireString("patch")
+	if err != nil {
+		return toolErrorf("Invalid patch parameter: %v", err), nil
+	}
+
+	baseDir := mcp.ParseString(req, "base_dir", "")
+	strip := int(mcp.ParseFloat64(req, "strip", -1))
+	fuzz := int(mcp.ParseFloat64(req, "fuzz", 2))
+	reverse := mcp.ParseBoolean(req, "reverse", false)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+	atomic := mcp.ParseBoolean(req, "atomic", true)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+
+	if baseDir != "" && !common.IsPathAllowed(baseDir) {
+		return toolErrorf("Access to base directory is not allowed"), nil
+	}
+
+	patches, err := common.ParsePatch(patchText, strip)
+	if err != nil {
+		return toolErrorf("Failed to parse patch: %v", err), nil
+	}
+	if len(patches) == 0 {
+		return toolErrorf("Patch contains no file changes"), nil
+	}
+
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) || baseDir == "" {
+			return path
+		}
+		return filepath.Join(baseDir, path)
+	}
+
+	type plannedWrite struct {
+		source   string
+		target   string
+		original string
+		content  string
+	}
+
+	var planned []plannedWrite
+	var results []types.PatchFileResult
+	failed := false
+
+	for _, patch := range patches {
+		if reverse {
+			patch = patch.Reverse()
+		}
+		source, target := resolve(patch.OldPath), resolve(patch.NewPath)
+
+		result := types.PatchFileResult{Path: target, Action: "modify"}
+		switch {
+		case source == "":
+			result.Action = "create"
+		case target == "":
+			result.Path, result.Action = source, "delete"
+		case source != target:
+			result.Action = "rename"
+		}
+
+		fail := func(msg string) {
+			result.Error = msg
+			results = append(results, result)
+			failed = true
+		}
+
+		if (source != "" && !common.IsPathAllowed(source)) || (target != "" && !common.IsPathAllowed(target)) {
+			fail("access to this path is not allowed")
+			continue
+		}
+
+		content := ""
+		if source != "" {
+			data, err := os.ReadFile(source)
+			if err != nil {
+				fail(fmt.Sprintf("failed to read file: %v", err))
+				continue
+			}
+			content = string(data)
+		}
+		if target != "" && target != source {
+			if _, err := os.Stat(target); err == nil {
+				fail("target file already exists")
+				continue
+			}
+		}
+
+		newContent, hunkResults, ok := common.ApplyHunks(content, patch.Hunks, fuzz)
+		result.Hunks = hunkResults
+		if !ok {
+			fail("one or more hunks failed")
+			continue
+		}
+		if target == "" && newContent != "" {
+			fail("file is not empty after applying deletion")
+			continue
+		}
+
+		results = append(results, result)
+		planned = append(planned, plannedWrite{source: source, target: target, original: content, content: newContent})
+	}
+
+	applyWrites := !dryRun && !(atomic && failed)
+	written := 0
+	if applyWrites {
+		sizes := make([]int64, 0, len(planned))
+		for _, write := range planned {
+			if write.target != "" {
+				sizes = append(sizes, int64(len(write.content)))
+			}
+		}
+		if err := common.CheckWriteQuota(sizes...); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		confirmLargeEdit := mcp.ParseBoolean(req, "confirm_large_edit", false)
+		for _, write := range planned {
+			if write.source != "" && write.target != "" {
+				if err := common.CheckLargeEdit(write.target, write.original, write.content, confirmLargeEdit); err != nil {
+					return toolErrorOf(err), nil
+				}
+			}
+		}
+
+		for _, write := range planned {
+			if createBackup && write.source != "" {
+				if _, err := common.CreateBackup(write.source); err != nil {
+					return toolErrorf("Failed to create backup for %s: %v (%d file(s) already written)", write.source, err, written), nil
+				}
+			}
+
+			if write.target != "" {
+				if err := common.EnsureDir(filepath.Dir(write.target)); err != nil {
+					return toolErrorf("Failed to create directory for %s: %v (%d file(s) already written)", write.target, err, written), nil
+				}
+				if err := common.WriteFileAtomic(write.target, []byte(write.content), 0644); err != nil {
+					return toolErrorf("Failed to write %s: %v (%d file(s) already written)", write.target, err, written), nil
+				}
+				common.RecordWrite(sessionID(ctx), int64(len(write.content)))
+				if write.source == write.target {
+					common.RecordEdit(sessionID(ctx), "apply_patch", write.target, []byte(write.original), []byte(write.content))
+				} else {
+					common.RecordCreate(sessionID(ctx), "apply_patch", write.target, []byte(write.content))
+				}
+			}
+			if write.source != "" && write.source != write.target {
+				if err := os.Remove(write.source); err != nil {
+					return toolErrorf("Failed to remove %s: %v (%d file(s) already written)", write.source, err, written), nil
+				}
+				common.RecordDelete(sessionID(ctx), "apply_patch", write.source, []byte(write.original))
+			}
+			written++
+		}
+	}
+
+	var summary strings.Builder
+	switch {
+	case dryRun:
+		summary.WriteString(common.Localize("Dry run: %d of %d file(s) would apply cleanly\n", len(planned), len(patches)))
+	case atomic && failed:
+		summary.WriteString(common.Localize("Patch not applied: %d of %d file(s) failed, no files were changed\n", len(patches)-len(planned), len(patches)))
+	default:
+		summary.WriteString(common.Localize("Applied patch to %d of %d file(s)\n", written, len(patches)))
+	}
+
+	for _, result := range results {
+		summary.WriteString(fmt.Sprintf("\n%s (%s)", result.Path, result.Action))
+		if result.Error != "" {
+			summary.WriteString(fmt.Sprintf(": FAILED - %s", result.Error))
+		}
+		summary.WriteString("\n")
+		for _, hunk := range result.Hunks {
+			if !hunk.Applied {
+				summary.WriteString(fmt.Sprintf("  hunk %d: FAILED - %s\n", hunk.Hunk, hunk.Error))
+				continue
+			}
+			line := fmt.Sprintf("  hunk %d: applied at line %d", hunk.Hunk, hunk.Line)
+			if hunk.Offset != 0 {
+				line += fmt.Sprintf(" (offset %+d)", hunk.Offset)
+			}
+			if hunk.Fuzz > 0 {
+				line += fmt.Sprintf(" (fuzz %d)", hunk.Fuzz)
+			}
+			summary.WriteString(line + "\n")
+		}
+	}
+
+	if failed && !dryRun {
+		return toolError(common.ErrorConflict, summary.String()), nil
+	}
+	return mcp.NewToolResultText(summary.String()), nil
+}
+
+// HandleListEditJournal lists recorded edits that can be undone
+func HandleListEditJournal(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := mcp.ParseString(req, "path", "")
+	limit := int(mcp.ParseFloat64(req, "limit", 50))
+	includeUndone := mcp.ParseBoolean(req, "include_undone", false)
+
+	if path != "" && !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	entries := common.ListJournalEntries(sessionID(ctx), path, includeUndone, limit)
+	if len(entries) == 0 {
+		return mcp.NewToolResultText(common.Localize("No edits recorded")), nil
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to encode journal: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// HandleUndoLastEdit restores the content a file had before the most recent edit
+func HandleUndoLastEdit(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := mcp.ParseString(req, "path", "")
+	force := mcp.ParseBoolean(req, "force", false)
+
+	if path != "" && !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	undone, err := common.UndoLastEdit(sessionID(ctx), path, force)
+	if err != nil {
+		return toolErrorf("Failed to undo edit: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.FormatUndoneEdits(undone)), nil
+}
+
+// HandleUndoEditsSince reverts every edit made since a journal ID or point in time
+func HandleUndoEditsSince(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sinceID := int(mcp.ParseFloat64(req, "since_id", 0))
+	sinceStr := mcp.ParseString(req, "since", "")
+	force := mcp.ParseBoolean(req, "force", false)
+
+	var since time.Time
+	if sinceStr != "" {
+		if d, err := time.ParseDuration(sinceStr); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, sinceStr); err == nil {
+			since = t
+		} else {
+			return toolErrorf("Invalid since value %q: use an RFC3339 timestamp or a duration like 15m", sinceStr), nil
+		}
+	}
+	if sinceID <= 0 && since.IsZero() {
+		return toolErrorf("Either since_id or since is required"), nil
+	}
+
+	undone, err := common.UndoEditsSince(sessionID(ctx), sinceID, since, force)
+	if err != nil {
+		return toolErrorf("Failed to undo edits: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.FormatUndoneEdits(undone)), nil
+}
+
+// HandleSaveSnippet stores a named snippet in the snippet library
+func HandleSaveSnippet(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	content, err := req.RequireString("content")
+	if err != nil {
+		return toolErrorf("Invalid content parameter: %v", err), nil
+	}
+
+	snippet := types.Snippet{
+		Name:        name,
+		Description: mcp.ParseString(req, "description", ""),
+		Language:    mcp.ParseString(req, "language", ""),
+		Content:     content,
+	}
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+
+	snippet, err = common.SaveSnippet(snippet, overwrite)
+	if err != nil {
+		return toolErrorf("Failed to save snippet: %v", err), nil
+	}
+
+	result := common.Localize("Saved snippet %s", snippet.Name)
+	if len(snippet.Placeholders) > 0 {
+		result += common.Localize(" with placeholders: %s", strings.Join(snippet.Placeholders, ", "))
+	}
+	return mcp.NewToolResultText(result), nil
+}
+
+// HandleListSnippets lists the snippets in the snippet library
+func HandleListSnippets(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	language := mcp.ParseString(req, "language", "")
+	query := mcp.ParseString(req, "query", "")
+	showContent := mcp.ParseBoolean(req, "show_content", false)
+
+	snippets, err := common.ListSnippets(language, query)
+	if err != nil {
+		return toolErrorf("Failed to list snippets: %v", err), nil
+	}
+	if len(snippets) == 0 {
+		return mcp.NewToolResultText(common.Localize("No snippets found")), nil
+	}
+
+	if !showContent {
+		for i := range snippets {
+			snippets[i].Content = ""
+		}
+	}
+
+	data, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		return toolErrorf("Failed to encode snippets: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// HandleDeleteSnippet removes a snippet from the snippet library
+func HandleDeleteSnippet(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	if err := common.DeleteSnippet(name); err != nil {
+		return toolErrorf("Failed to delete snippet: %v", err), nil
+	}
+
+	return mcp.NewToolResultText(common.Localize("Deleted snippet %s", name)), nil
+}
+
+// HandleInsertSnippet expands a snippet's placeholders and inserts it into a file
+func HandleInsertSnippet(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return toolErrorf("Invalid name parameter: %v", err), nil
+	}
+
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	values := map[string]string{}
+	if valuesStr := mcp.ParseString(req, "values", ""); valuesStr != "" {
+		if err := json.Unmarshal([]byte(valuesStr), &values); err != nil {
+			return toolErrorf("Failed to parse values: %v", err), nil
+		}
+	}
+	line := int(mcp.ParseFloat64(req, "line", 0))
+	before := mcp.ParseBoolean(req, "before", false)
+	anchor := mcp.ParseString(req, "anchor", "")
+	anchorRegex := mcp.ParseBoolean(req, "anchor_regex", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	snippet, err := common.LoadSnippet(name)
+	if err != nil {
+		return toolErrorf("Failed to load snippet: %v", err), nil
+	}
+
+	if _, ok := values["filename"]; !ok {
+		values["filename"] = filepath.Base(path)
+	}
+	text, err := common.ExpandSnippet(snippet.Content, values)
+	if err != nil {
+		return toolErrorf("Failed to expand snippet: %v", err), nil
+	}
+
+	// A missing file is created when the snippet is simply appended
+	content, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !(os.IsNotExist(err) && line == 0 && anchor == "") {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+	originalContent := string(content)
+
+	var newContent string
+	if line == 0 && anchor == "" {
+		newContent = originalContent
+		if newContent != "" && !strings.HasSuffix(newContent, "\n") {
+			newContent += "\n"
+		}
+		newContent += text
+	} else {
+		insertion := types.TextInsertion{
+			Line:        line,
+			Content:     strings.TrimSuffix(text, "\n"),
+			Before:      before,
+			Anchor:      anchor,
+			AnchorRegex: anchorRegex,
+		}
+		newContent, err = common.ApplyTextInsertions(originalContent, []types.TextInsertion{insertion}, true)
+		if err != nil {
+			return toolErrorf("Failed to insert snippet: %v", err), nil
+		}
+	}
+
+	if dryRun {
+		oldName := path
+		if !exists {
+			oldName = "/dev/null"
+		}
+		diff := common.EditDiff(oldName, path, originalContent, newContent, 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if createBackup && exists {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	if err := common.WriteFileAtomic(path, []byte(newContent), 0644); err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	if exists {
+		common.RecordEdit(sessionID(ctx), "insert_snippet", path, content, []byte(newContent))
+	} else {
+		common.RecordCreate(sessionID(ctx), "insert_snippet", path, []byte(newContent))
+	}
+
+	return mcp.NewToolResultText(common.Localize("Inserted snippet %s into %s", name, path)), nil
+}
+
+// HandleMarkdownOutline returns the heading outline of a Markdown file
+func HandleMarkdownOutline(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	maxLevel := int(mcp.ParseFloat64(req, "max_level", 6))
+	format := mcp.ParseString(req, "format", "text")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	var headings []types.MarkdownHeading
+	for _, heading := range common.ParseMarkdownHeadings(string(content)) {
+		if heading.Level <= maxLevel {
+			headings = append(headings, heading)
+		}
+	}
+	if len(headings) == 0 {
+		return mcp.NewToolResultText(common.Localize("No headings found")), nil
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(headings, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to encode outline: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(data)), nil
+	case "text":
+		var result strings.Builder
+		for _, heading := range headings {
+			result.WriteString(fmt.Sprintf("%s%s (line %d, #%s)\n", strings.Repeat("  ", heading.Level-1), heading.Text, heading.Line, heading.Anchor))
+		}
+		return mcp.NewToolResultText(result.String()), nil
+	default:
+		return toolErrorf("Unknown format: %s (use text or json)", format), nil
+	}
+}
+
+// HandleUpdateMarkdownTOC generates or refreshes the table of contents of a Markdown file
+func HandleUpdateMarkdownTOC(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	minLevel := int(mcp.ParseFloat64(req, "min_level", 2))
+	maxLevel := int(mcp.ParseFloat64(req, "max_level", 3))
+	ordered := mcp.ParseBoolean(req, "ordered", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	if minLevel < 1 || maxLevel > 6 || minLevel > maxLevel {
+		return toolErrorf("min_level and max_level must satisfy 1 <= min_level <= max_level <= 6"), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	newContent, err := common.UpdateMarkdownTOC(string(content), minLevel, maxLevel, ordered)
+	if err != nil {
+		return toolErrorf("Failed to update table of contents: %v", err), nil
+	}
+
+	if newContent == string(content) {
+		return mcp.NewToolResultText(common.Localize("Table of contents in %s is up to date", path)), nil
+	}
+
+	if dryRun {
+		diff := common.EditDiff(path, path, string(content), newContent, 3)
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	if err := common.WriteFileAtomic(path, []byte(newContent), 0644); err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "update_markdown_toc", path, content, []byte(newContent))
+
+	return mcp.NewToolResultText(common.Localize("Updated table of contents in %s", path)), nil
+}
+
+// HandleNumberMarkdownHeadings adds, renumbers or removes section numbers on Markdown headings
+func HandleNumberMarkdownHeadings(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	minLevel := int(mcp.ParseFloat64(req, "min_level", 2))
+	maxLevel := int(mcp.ParseFloat64(req, "max_level", 4))
+	remove := mcp.ParseBoolean(req, "remove", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	if minLevel < 1 || maxLevel > 6 || minLevel > maxLevel {
+		return toolErrorf("min_level and max_level must satisfy 1 <= min_level <= max_level <= 6"), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	newContent, changed := common.NumberMarkdownHeadings(string(content), minLevel, maxLevel, remove)
+	if changed == 0 {
+		return mcp.NewToolResultText(common.Localize("No headings changed in %s", path)), nil
+	}
+
+	if dryRun {
+		diff := common.EditDiff(path, path, string(content), newContent, 3)
+		return mcp.NewToolResultText(common.Localize("DRY RUN - Preview of changes for %s:\n%s", path, diff)), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if createBackup {
+		if _, err := common.CreateBackup(path); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	if err := common.WriteFileAtomic(path, []byte(newContent), 0644); err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+	common.RecordEdit(sessionID(ctx), "number_markdown_headings", path, content, []byte(newContent))
+
+	result := common.Localize("Updated %d headings in %s", changed, path)
+	if strings.Contains(newContent, "<!-- toc -->") {
+		result += "\nHeading anchors changed; run update_markdown_toc to refresh the table of contents"
+	}
+	return mcp.NewToolResultText(result), nil
+}
+
+// HandleCheckText reports spelling and style problems in a text or Markdown file
+func HandleCheckText(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	rulesStr := mcp.ParseString(req, "rules", "")
+	dictionaryPath := mcp.ParseString(req, "dictionary", "")
+	wordsStr := mcp.ParseString(req, "words", "")
+	ext := strings.ToLower(filepath.Ext(path))
+	opts := common.TextCheckOptions{
+		Language:      mcp.ParseString(req, "language", ""),
+		MaxLineLength: int(mcp.ParseFloat64(req, "max_line_length", 120)),
+		Markdown:      mcp.ParseBoolean(req, "markdown", ext == ".md" || ext == ".markdown"),
+	}
+	maxFindings := int(mcp.ParseFloat64(req, "max_findings", 200))
+	format := mcp.ParseString(req, "format", "text")
+	timeoutSeconds := int(mcp.ParseFloat64(req, "timeout_seconds", 30))
+
+	if rulesStr != "" {
+		opts.Rules = strings.Split(rulesStr, ",")
+	}
+	for _, word := range strings.Split(wordsStr, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			opts.Dictionary = append(opts.Dictionary, word)
+		}
+	}
+	if dictionaryPath != "" {
+		if !common.IsPathAllowed(dictionaryPath) {
+			return toolErrorf("Access to the dictionary path is not allowed"), nil
+		}
+		words, err := common.ReadDictionary(dictionaryPath)
+		if err != nil {
+			return toolErrorf("Failed to read dictionary: %v", err), nil
+		}
+		opts.Dictionary = append(opts.Dictionary, words...)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	findings, notes, err := common.CheckText(checkCtx, string(content), opts)
+	if err != nil {
+		return toolErrorf("Failed to check text: %v", err), nil
+	}
+
+	total := len(findings)
+	if maxFindings > 0 && total > maxFindings {
+		findings = findings[:maxFindings]
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"path":     path,
+			"total":    total,
+			"findings": findings,
+			"notes":    notes,
+		}, "", "  ")
+		if err != nil {
+			return toolErrorf("Failed to encode findings: %v", err), nil
+		}
+		return mcp.NewToolResultText(string(data)), nil
+	}
+
+	var result strings.Builder
+	for _, finding := range findings {
+		result.WriteString(fmt.Sprintf("%s:%d:%d: [%s] %s", path, finding.Line, finding.Column, finding.Rule, finding.Message))
+		if len(finding.Suggestions) > 0 {
+			result.WriteString(fmt.Sprintf(" (suggestions: %s)", strings.Join(finding.Suggestions, ", ")))
+		}
+		result.WriteString("\n")
+	}
+	if total == 0 {
+		result.WriteString(common.Localize("No problems found\n"))
+	} else if total > len(findings) {
+		result.WriteString(common.Localize("... %d more findings not shown\n", total-len(findings)))
+	}
+	for _, note := range notes {
+		result.WriteString(note + "\n")
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+// HandleNormalizeUnicode fixes BOMs, invisible characters, unusual spaces and mixed normalization forms
+func HandleNormalizeUnicode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	opts := common.UnicodeFixOptions{
+		BOM:               mcp.ParseBoolean(req, "fix_bom", true),
+		ZeroWidth:         mcp.ParseBoolean(req, "fix_zero_width", true),
+		NonBreakingSpaces: mcp.ParseBoolean(req, "fix_nbsp", true),
+		Normalization:     strings.ToLower(mcp.ParseString(req, "normalization", "nfc")),
+	}
+	if opts.Normalization == "none" {
+		opts.Normalization = ""
+	}
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	newContent, changes, err := common.NormalizeUnicode(string(content), opts)
+	if err != nil {
+		return toolErrorf("Failed to normalize %s: %v", path, err), nil
+	}
+
+	var result strings.Builder
+	if composed, decomposed := common.DetectNormalizationForms(string(content)); composed > 0 && decomposed > 0 {
+		result.WriteString(common.Localize("Mixed normalization forms: %d precomposed (NFC) and %d decomposed (NFD) characters\n", composed, decomposed))
+	}
+	if len(changes) == 0 {
+		result.WriteString(common.Localize("No changes needed in %s\n", path))
+		return mcp.NewToolResultText(result.String()), nil
+	}
+
+	if dryRun {
+		result.WriteString(common.Localize("DRY RUN - %d changes would be made to %s:\n", len(changes), path))
+	} else {
+		if err := common.CheckLargeEdit(path, string(content), newContent, mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		if err := common.CheckWriteQuota(int64(len(newContent))); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		if createBackup {
+			if _, err := common.CreateBackup(path); err != nil {
+				return toolErrorf("Failed to create backup: %v", err), nil
+			}
+		}
+
+		if err := common.WriteFileAtomic(path, []byte(newContent), 0644); err != nil {
+			return toolErrorf("Failed to write file: %v", err), nil
+		}
+		common.RecordWrite(sessionID(ctx), int64(len(newContent)))
+		common.RecordEdit(sessionID(ctx), "normalize_unicode", path, content, []byte(newContent))
+
+		result.WriteString(common.Localize("Made %d changes to %s:\n", len(changes), path))
+	}
+
+	for _, change := range changes {
+		result.WriteString(common.Localize("Line %d, column %d: %s\n", change.Line, change.Column, change.Detail))
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+// HandleBeautifyFile reformats JSON, CSS, JavaScript or SQL for readability
+func HandleBeautifyFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	outputPath := mcp.ParseString(req, "output_path", "")
+	tool := mcp.ParseString(req, "tool", "")
+	indentSize := int(mcp.ParseFloat64(req, "indent", 2))
+	useTabs := mcp.ParseBoolean(req, "use_tabs", false)
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+	timeoutSeconds := int(mcp.ParseFloat64(req, "timeout_seconds", 30))
+
+	if outputPath == "" {
+		outputPath = path
+	} else if !common.IsPathAllowed(outputPath) {
+		return toolErrorf("Access to the output path is not allowed"), nil
+	}
+
+	indent := strings.Repeat(" ", indentSize)
+	if useTabs {
+		indent = "\t"
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	toolCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	beautified, tool, err := common.BeautifyCode(toolCtx, path, tool, content, indent)
+	if err != nil {
+		return toolErrorf("Failed to beautify file: %v", err), nil
+	}
+
+	report := common.Localize("Beautified %s with %s\n%s", path, tool, common.FormatSizeChange(len(content), len(beautified)))
+
+	if dryRun {
+		diff := common.EditDiff(path, outputPath, string(content), string(beautified), 3)
+		if diff == "" {
+			diff = common.Localize("No changes\n")
+		}
+		return mcp.NewToolResultText(common.Localize("DRY RUN - %s\n%s", report, diff)), nil
+	}
+
+	existing, err := os.ReadFile(outputPath)
+	exists := err == nil
+	if exists && outputPath != path && !overwrite {
+		return toolErrorf("Output file %s already exists (set overwrite to replace it)", outputPath), nil
+	}
+
+	if err := common.CheckLargeEdit(outputPath, string(existing), string(beautified), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(beautified))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if createBackup && exists {
+		if _, err := common.CreateBackup(outputPath); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	if err := common.WriteFileAtomic(outputPath, beautified, 0644); err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(beautified)))
+	if exists {
+		common.RecordEdit(sessionID(ctx), "beautify_file", outputPath, existing, beautified)
+	} else {
+		common.RecordCreate(sessionID(ctx), "beautify_file", outputPath, beautified)
+	}
+
+	return mcp.NewToolResultText(common.Localize("%s\nWritten to %s", report, outputPath)), nil
+}
+
+// HandleMinifyFile strips whitespace and comments from JSON, CSS, JavaScript or SQL
+func HandleMinifyFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolErrorf("Invalid path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(path) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if err := common.CheckExpectedState(path, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	outputPath := mcp.ParseString(req, "output_path", "")
+	tool := mcp.ParseString(req, "tool", "")
+	overwrite := mcp.ParseBoolean(req, "overwrite", false)
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+	timeoutSeconds := int(mcp.ParseFloat64(req, "timeout_seconds", 30))
+
+	if outputPath == "" {
+		outputPath = path
+	} else if !common.IsPathAllowed(outputPath) {
+		return toolErrorf("Access to the output path is not allowed"), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return toolErrorf("Failed to read file: %v", err), nil
+	}
+
+	toolCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	minified, tool, err := common.MinifyCode(toolCtx, path, tool, content)
+	if err != nil {
+		return toolErrorf("Failed to minify file: %v", err), nil
+	}
+
+	report := common.Localize("Minified %s with %s\n%s", path, tool, common.FormatSizeChange(len(content), len(minified)))
+
+	if dryRun {
+		return mcp.NewToolResultText("DRY RUN - " + report), nil
+	}
+
+	existing, err := os.ReadFile(outputPath)
+	exists := err == nil
+	if exists && outputPath != path && !overwrite {
+		return toolErrorf("Output file %s already exists (set overwrite to replace it)", outputPath), nil
+	}
+
+	if err := common.CheckLargeEdit(outputPath, string(existing), string(minified), mcp.ParseBoolean(req, "confirm_large_edit", false)); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if err := common.CheckWriteQuota(int64(len(minified))); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	if createBackup && exists {
+		if _, err := common.CreateBackup(outputPath); err != nil {
+			return toolErrorf("Failed to create backup: %v", err), nil
+		}
+	}
+
+	if err := common.WriteFileAtomic(outputPath, minified, 0644); err != nil {
+		return toolErrorf("Failed to write file: %v", err), nil
+	}
+	common.RecordWrite(sessionID(ctx), int64(len(minified)))
+	if exists {
+		common.RecordEdit(sessionID(ctx), "minify_file", outputPath, existing, minified)
+	} else {
+		common.RecordCreate(sessionID(ctx), "minify_file", outputPath, minified)
+	}
+
+	return mcp.NewToolResultText(common.Localize("%s\nWritten to %s", report, outputPath)), nil
+}
+
+// HandleMoveCode moves a declaration or range of lines from one file to
+// another, writing both files or neither
+func HandleMoveCode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sourcePath, err := req.RequireString("source_path")
+	if err != nil {
+		return toolErrorf("Invalid source_path parameter: %v", err), nil
+	}
+
+	destinationPath, err := req.RequireString("destination_path")
+	if err != nil {
+		return toolErrorf("Invalid destination_path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(sourcePath) || !common.IsPathAllowed(destinationPath) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	if filepath.Clean(sourcePath) == filepath.Clean(destinationPath) {
+		return toolErrorf("Source and destination must be different files"), nil
+	}
+
+	symbol := mcp.ParseString(req, "symbol", "")
+	startLine := int(mcp.ParseFloat64(req, "start_line", 0))
+	endLine := int(mcp.ParseFloat64(req, "end_line", 0))
+	createBackup := mcp.ParseBoolean(req, "create_backup", true)
+	dryRun := mcp.ParseBoolean(req, "dry_run", false)
+
+	if symbol == "" && startLine == 0 {
+		return toolErrorf("Either symbol or start_line must be provided"), nil
+	}
+	if endLine == 0 {
+		endLine = startLine
+	}
+
+	if err := common.CheckExpectedState(sourcePath, mcp.ParseString(req, "expected_checksum", ""), mcp.ParseString(req, "expected_mtime", "")); err != nil {
+		return toolErrorOf(err), nil
+	}
+
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return toolErrorf("Failed to read source file: %v", err), nil
+	}
+
+	destination, err := os.ReadFile(destinationPath)
+	destinationExists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return toolErrorf("Failed to read destination file: %v", err), nil
+	}
+
+	move, err := common.MoveCode(sourcePath, destinationPath, source, destination, symbol, startLine, endLine)
+	if err != nil {
+		return toolErrorf("Failed to move code: %v", err), nil
+	}
+
+	what := fmt.Sprintf("lines %d-%d", move.StartLine, move.EndLine)
+	if symbol != "" {
+		what = fmt.Sprintf("%s (lines %d-%d)", symbol, move.StartLine, move.EndLine)
+	}
+
+	var result strings.Builder
+	if dryRun {
+		result.WriteString(common.Localize("DRY RUN - Preview of moving %s from %s to %s:\n", what, sourcePath, destinationPath))
+		result.WriteString(common.EditDiff(sourcePath, sourcePath, string(source), string(move.Source), 3))
+		result.WriteString(common.EditDiff(destinationPath, destinationPath, string(destination), string(move.Destination), 3))
+	} else {
+		confirmLargeEdit := mcp.ParseBoolean(req, "confirm_large_edit", false)
+		if err := common.CheckLargeEdit(sourcePath, string(source), string(move.Source), confirmLargeEdit); err != nil {
+			return toolErrorOf(err), nil
+		}
+		if err := common.CheckLargeEdit(destinationPath, string(destination), string(move.Destination), confirmLargeEdit); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		if err := common.CheckWriteQuota(int64(len(move.Source) + len(move.Destination))); err != nil {
+			return toolErrorOf(err), nil
+		}
+
+		tx := common.NewFileTransaction()
+		for _, path := range []string{sourcePath, destinationPath} {
+			if err := tx.Snapshot(path); err != nil {
+				return toolErrorOf(err), nil
+			}
+		}
+
+		if createBackup {
+			if _, err := common.CreateBackup(sourcePath); err != nil {
+				return toolErrorf("Failed to create backup: %v", err), nil
+			}
+			if destinationExists {
+				if _, err := common.CreateBackup(destinationPath); err != nil {
+					return toolErrorf("Failed to create backup: %v", err), nil
+				}
+			}
+		}
+
+		if err := common.EnsureDir(filepath.Dir(destinationPath)); err != nil {
+			return toolErrorf("Failed to create destination directory: %v", err), nil
+		}
+
+		// Write the destination first so a failure never loses the moved code
+		err := common.WriteFileAtomic(destinationPath, move.Destination, 0644)
+		if err == nil {
+			err = common.WriteFileAtomic(sourcePath, move.Source, 0644)
+		}
+		if err != nil {
+			errMsg := common.Localize("Failed to write files: %v", err)
+			restored, rollbackErrs := tx.Rollback()
+			errMsg += common.Localize("; rolled back %d file(s)", restored)
+			for _, rollbackErr := range rollbackErrs {
+				errMsg += fmt.Sprintf("; %v", rollbackErr)
+			}
+			return toolError(common.ErrorCodeOf(err), errMsg), nil
+		}
+
+		common.RecordWrite(sessionID(ctx), int64(len(move.Destination)))
+		common.RecordWrite(sessionID(ctx), int64(len(move.Source)))
+		common.RecordEdit(sessionID(ctx), "move_code", sourcePath, source, move.Source)
+		if destinationExists {
+			common.RecordEdit(sessionID(ctx), "move_code", destinationPath, destination, move.Destination)
+		} else {
+			common.RecordCreate(sessionID(ctx), "move_code", destinationPath, move.Destination)
+		}
+
+		result.WriteString(common.Localize("Moved %s from %s to %s\n", what, sourcePath, destinationPath))
+	}
+
+	for _, note := range move.Notes {
+		result.WriteString(common.Localize("Note: %s\n", note))
+	}
+
+	return mcp.NewToolResultText(result.String()), nil
+}
+
+func HandleDiffFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	oldPath, err := req.RequireString("old_path")
+	if err != nil {
+		return toolErrorf("Invalid old_path parameter: %v", err), nil
+	}
+	newPath, err := req.RequireString("new_path")
+	if err != nil {
+		return toolErrorf("Invalid new_path parameter: %v", err), nil
+	}
+
+	if !common.IsPathAllowed(oldPath) || !common.IsPathAllowed(newPath) {
+		return toolErrorf("Access to this path is not allowed"), nil
+	}
+
+	style := mcp.ParseString(req, "style", common.Get().DiffStyle)
+	if style != common.DiffStyleWord && style != common.DiffStyleUnified {
+		return toolErrorf("Invalid style %q: use word or unified", style), nil
+	}
+	contextLines := int(mcp.ParseFloat64(req, "context_lines", 3))
+
+	var contents [2]string
+	for i, path := range []string{oldPath, newPath} {
+		if !common.IsTextFile(path) {
+			return toolErrorf("%s is not a text file", path), nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return toolErrorf("Failed to read file: %v", err), nil
+		}
+		contents[i] = string(content)
+	}
+
+	stat := common.DiffStats(common.ComputeDiffHunks(contents[0], contents[1], 0))
+	if stat.Added+stat.Deleted == 0 {
+		return mcp.NewToolResultText(common.Localize("%s and %s are identical", oldPath, newPath)), nil
+	}
+
+	summary := fmt.Sprintf("%s -> %s: %s", oldPath, newPath, common.FormatDiffStat(stat))
+	if mcp.ParseBoolean(req, "stat_only", false) {
+		return mcp.NewToolResultText(summary), nil
+	}
+	return mcp.NewToolResultText(summary + "\n" + common.StyledDiff(oldPath, newPath, contents[0], contents[1], contextLines, style)), nil
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"jarvis/internal/common"
+)
+
+func TestRewritingToolsRequireConfirmLargeEdit(t *testing.T) {
+	dir := allowedTempDir(t)
+
+	previous := common.Get().LargeEditLines
+	if err := common.Set("largeEditLines", "1"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { common.Set("largeEditLines", strconv.Itoa(previous)) })
+
+	unicodePath := filepath.Join(dir, "notes.txt")
+	jsonPath := filepath.Join(dir, "data.json")
+	sourcePath := filepath.Join(dir, "source.txt")
+	files := map[string]string{
+		unicodePath: "a\u00a0b\nc\u00a0d\ne\u00a0f\n",
+		jsonPath:    `{"a":1,"b":2,"c":3}` + "\n",
+		sourcePath:  "one\ntwo\nthree\nfour\n",
+	}
+
+	for _, test := range []struct {
+		name      string
+		handler   func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		arguments map[string]any
+	}{
+		{"normalize_unicode", HandleNormalizeUnicode, map[string]any{"path": unicodePath}},
+		{"beautify_file", HandleBeautifyFile, map[string]any{"path": jsonPath, "tool": "native"}},
+		{"move_code", HandleMoveCode, map[string]any{"source_path": sourcePath, "destination_path": filepath.Join(dir, "moved.txt"), "start_line": 1, "end_line": 3}},
+	} {
+		for path, content := range files {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		result, err := test.handler(context.Background(), toolRequest(test.arguments))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsError {
+			t.Errorf("%s applied an edit over largeEditLines without confirm_large_edit", test.name)
+		}
+		for path, content := range files {
+			if data, _ := os.ReadFile(path); string(data) != content {
+				t.Errorf("%s changed %s without confirm_large_edit", test.name, filepath.Base(path))
+			}
+		}
+
+		test.arguments["confirm_large_edit"] = true
+		result, err = test.handler(context.Background(), toolRequest(test.arguments))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError {
+			t.Errorf("%s refused a confirmed edit: %v", test.name, result.Content)
+		}
+	}
+}
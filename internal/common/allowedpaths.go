@@ -0,0 +1,365 @@
+package common
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+
+	"jarvis/internal/types"
+)
+
+// caseInsensitiveDirs caches whether the file system holding a directory
+// ignores case, keyed by the directory
+var caseInsensitiveDirs sync.Map
+
+// ExpandHomeDir replaces a leading "~" or "~/" with the home directory of
+// the user the server runs as; other paths are returned unchanged
+func ExpandHomeDir(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot expand %s: %v", path, err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// normalizeAllowedDirectory turns an allowedDirectories entry into a clean
+// absolute path or pattern: "~" is expanded and trailing slashes dropped
+func normalizeAllowedDirectory(entry string) (string, error) {
+	expanded, err := ExpandHomeDir(strings.TrimSpace(entry))
+	if err != nil {
+		return "", err
+	}
+	if expanded == "" {
+		return "", fmt.Errorf("allowed directory cannot be empty")
+	}
+	return filepath.Abs(expanded)
+}
+
+// hasGlobMeta reports whether a path uses glob syntax
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// validateAllowedDirectory checks the glob syntax of an allowedDirectories
+// entry
+func validateAllowedDirectory(entry string) error {
+	normalized, err := normalizeAllowedDirectory(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := filepath.Match(normalized, ""); err != nil {
+		return fmt.Errorf("invalid allowedDirectories entry %q", entry)
+	}
+	return nil
+}
+
+// matchAllowedDirectory reports whether an absolute path is within an
+// allowedDirectories entry. An entry with glob syntax allows every
+// directory it matches and everything below them: "~/projects/*" allows
+// each project but not ~/projects itself.
+func matchAllowedDirectory(absPath, entry string) bool {
+	normalized, err := normalizeAllowedDirectory(entry)
+	if err != nil {
+		return false
+	}
+	foldCase := isCaseInsensitiveDir(globLiteralPrefix(normalized))
+	if !hasGlobMeta(normalized) {
+		if foldCase {
+			return IsSubPath(strings.ToLower(absPath), strings.ToLower(normalized))
+		}
+		return IsSubPath(absPath, normalized)
+	}
+
+	// The pattern is matched against the leading path elements, as many
+	// as it has, so a match allows the directory and what is below it
+	patternParts := splitPathElements(normalized)
+	pathParts := splitPathElements(absPath)
+	if len(pathParts) < len(patternParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		name := pathParts[i]
+		if foldCase {
+			part, name = strings.ToLower(part), strings.ToLower(name)
+		}
+		if matched, err := filepath.Match(part, name); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPathElements splits a clean absolute path into its volume or root
+// followed by its elements
+func splitPathElements(path string) []string {
+	volume := filepath.VolumeName(path)
+	rest := strings.Trim(path[len(volume):], string(filepath.Separator))
+	parts := []string{volume}
+	if rest != "" {
+		parts = append(parts, strings.Split(rest, string(filepath.Separator))...)
+	}
+	return parts
+}
+
+// globLiteralPrefix returns the leading elements of a pattern that use no
+// glob syntax
+func globLiteralPrefix(pattern string) string {
+	prefix := pattern
+	for hasGlobMeta(prefix) {
+		prefix = filepath.Dir(prefix)
+	}
+	return prefix
+}
+
+// maxSymlinkHops bounds the dangling symlinks resolvePath follows
+const maxSymlinkHops = 40
+
+// resolvePath resolves the symlinks of an absolute path. A path that does
+// not exist yet is resolved through its nearest existing parent, and a
+// dangling symlink through its target, so a new file is judged by where it
+// would really be created. ok is false for symlink loops.
+func resolvePath(absPath string) (resolvedPath string, ok bool) {
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		existing, rest := absPath, ""
+		followed := false
+		for !followed {
+			if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+				return filepath.Join(resolved, rest), true
+			}
+			if info, err := os.Lstat(existing); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Readlink(existing)
+				if err != nil {
+					return "", false
+				}
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(filepath.Dir(existing), target)
+				}
+				absPath, followed = filepath.Join(target, rest), true
+				continue
+			}
+			parent := filepath.Dir(existing)
+			if parent == existing {
+				return absPath, true
+			}
+			rest = filepath.Join(filepath.Base(existing), rest)
+			existing = parent
+		}
+	}
+	return "", false
+}
+
+// resolveAllowedDirectory resolves the symlinks of the part of an
+// allowedDirectories entry before any glob syntax, so that the entry
+// matches paths whose symlinks were resolved
+func resolveAllowedDirectory(entry string) string {
+	normalized, err := normalizeAllowedDirectory(entry)
+	if err != nil {
+		return entry
+	}
+	prefix := globLiteralPrefix(normalized)
+	resolved, ok := resolvePath(prefix)
+	if !ok {
+		return normalized
+	}
+	return resolved + normalized[len(prefix):]
+}
+
+// expandAllowedDirectories returns the existing directories the
+// allowedDirectories entries name, with patterns expanded
+func expandAllowedDirectories(entries []string) []string {
+	var dirs []string
+	for _, entry := range entries {
+		normalized, err := normalizeAllowedDirectory(entry)
+		if err != nil {
+			continue
+		}
+		if !hasGlobMeta(normalized) {
+			dirs = append(dirs, normalized)
+			continue
+		}
+		matches, _ := filepath.Glob(normalized)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				dirs = append(dirs, match)
+			}
+		}
+	}
+	return dirs
+}
+
+// isCaseInsensitiveDir reports whether the file system holding dir ignores
+// case, found by looking the nearest existing directory up with its case
+// swapped. Directories without letters to swap fall back to the platform
+// default: case-insensitive on Windows and macOS.
+func isCaseInsensitiveDir(dir string) bool {
+	if cached, ok := caseInsensitiveDirs.Load(dir); ok {
+		return cached.(bool)
+	}
+
+	insensitive := runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+	for probe := dir; ; probe = filepath.Dir(probe) {
+		info, err := os.Stat(probe)
+		swapped := swapCase(probe)
+		if err == nil && swapped != probe {
+			other, err := os.Stat(swapped)
+			insensitive = err == nil && os.SameFile(info, other)
+			break
+		}
+		if parent := filepath.Dir(probe); parent == probe {
+			break
+		}
+	}
+	caseInsensitiveDirs.Store(dir, insensitive)
+	return insensitive
+}
+
+// swapCase swaps the case of the letters of the last element of path
+func swapCase(path string) string {
+	dir, name := filepath.Split(path)
+	swapped := []rune(name)
+	for i, r := range swapped {
+		if unicode.IsUpper(r) {
+			swapped[i] = unicode.ToLower(r)
+		} else if unicode.IsLower(r) {
+			swapped[i] = unicode.ToUpper(r)
+		}
+	}
+	return dir + string(swapped)
+}
+
+// IsPathDenied returns the deniedDirectories or deniedPathPatterns entry
+// that denies a path or the path its symlinks lead to. Denied paths are
+// refused even within allowed directories.
+func IsPathDenied(path string) (string, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	config := Get()
+	if entry, denied := deniedBy(absPath, config); denied {
+		return entry, true
+	}
+	if resolved, ok := resolvePath(absPath); ok && resolved != absPath {
+		return deniedBy(resolved, config)
+	}
+	return "", false
+}
+
+// SkipInWalk reports whether a recursive walk should leave out path: a
+// denied path, or a symlink leading outside the allowed directories
+func SkipInWalk(path string, mode fs.FileMode) bool {
+	if mode&fs.ModeSymlink != 0 && !IsPathAllowed(path) {
+		return true
+	}
+	_, denied := IsPathDenied(path)
+	return denied
+}
+
+// deniedBy returns the entry of config that denies an absolute path.
+// deniedDirectories entries are compared as allowedDirectories entries
+// are, with and without their symlinks resolved; deniedPathPatterns are
+// matched against each element of the path.
+func deniedBy(absPath string, config *types.ServerConfig) (string, bool) {
+	for _, entry := range config.DeniedDirectories {
+		if matchAllowedDirectory(absPath, entry) || matchAllowedDirectory(absPath, resolveAllowedDirectory(entry)) {
+			return entry, true
+		}
+	}
+	if len(config.DeniedPathPatterns) == 0 {
+		return "", false
+	}
+
+	// Case sensitivity is looked up for the top-level directory only, so
+	// that walking a tree does not probe every directory in it
+	elements := splitPathElements(absPath)
+	top := elements[0] + string(filepath.Separator)
+	if len(elements) > 1 {
+		top += elements[1]
+	}
+	foldCase := isCaseInsensitiveDir(top)
+	for _, name := range elements[1:] {
+		if foldCase {
+			name = strings.ToLower(name)
+		}
+		for _, pattern := range config.DeniedPathPatterns {
+			compared := pattern
+			if foldCase {
+				compared = strings.ToLower(pattern)
+			}
+			if matched, _ := filepath.Match(compared, name); matched {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isServerFile reports whether an absolute path is one of the files the
+// server keeps its policy, secrets and records in: the config file and its
+// signature, the config history rollbacks restore from, the scheduled
+// jobs, the secrets file and its key, the command history, and the audit
+// log with its rotated copies. File tools may never read or write them,
+// whatever the allowed directories.
+func isServerFile(absPath string) bool {
+	files := []string{getConfigPath(), configSignaturePath(), configHistoryPath(), jobsPath(), secretsPath(), secretsKeyPath(), historyPath()}
+	for _, file := range files {
+		if absFile, err := filepath.Abs(file); err == nil && absPath == absFile {
+			return true
+		}
+		if resolved, ok := resolvePath(file); ok && absPath == resolved {
+			return true
+		}
+	}
+	return isAuditLogFile(absPath)
+}
+
+// isAuditLogFile reports whether an absolute path is the audit log or one
+// of its rotated copies (path.1, path.2, ...), whatever auditLogMaxFiles
+// is now
+func isAuditLogFile(absPath string) bool {
+	log := auditLogPath()
+	var candidates []string
+	if absLog, err := filepath.Abs(log); err == nil {
+		candidates = append(candidates, absLog)
+	}
+	if resolved, ok := resolvePath(log); ok {
+		candidates = append(candidates, resolved)
+	}
+	for _, candidate := range candidates {
+		if absPath == candidate {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(absPath, candidate+"."); ok && isDigits(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDeniedPath returns the first denied path within root, so that
+// operations on a whole tree, such as a recursive delete, can refuse it
+func FindDeniedPath(root string) (string, bool) {
+	if _, denied := IsPathDenied(root); denied {
+		return root, true
+	}
+	var found string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if _, denied := IsPathDenied(path); denied {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, found != ""
+}
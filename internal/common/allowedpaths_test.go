@@ -0,0 +1,47 @@
+package common
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// allowDirectory adds dir to the allowed directories for the rest of the
+// test
+func allowDirectory(t *testing.T, dir string) {
+	t.Helper()
+	if err := AddAllowedDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { RemoveAllowedDirectory(dir) })
+}
+
+// allowedTempDir returns a temporary directory that is allowed for the
+// rest of the test
+func allowedTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowDirectory(t, dir)
+	return dir
+}
+
+func TestIsPathAllowedRefusesServerRecords(t *testing.T) {
+	dir := allowedTempDir(t)
+	// The command history is kept next to the config file
+	allowDirectory(t, filepath.Dir(getConfigPath()))
+	auditLog := filepath.Join(dir, "audit.jsonl")
+	setConfig(t, "auditLogPath", auditLog, "")
+
+	for _, path := range []string{auditLog, auditLog + ".1", auditLog + ".12", historyPath()} {
+		if IsPathAllowed(path) {
+			t.Errorf("IsPathAllowed(%s) = true for a file the server records to", path)
+		}
+	}
+	for _, path := range []string{filepath.Join(dir, "notes.txt"), auditLog + ".bak"} {
+		if !IsPathAllowed(path) {
+			t.Errorf("IsPathAllowed(%s) = false", path)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+var (
+	// Operations waiting for approval, keyed by token. They are kept in
+	// memory only, so a restart drops them.
+	pendingApprovals = make(map[string]types.PendingApproval)
+	approvalMutex    sync.Mutex
+)
+
+// CommandNeedsApproval returns the approvalPatterns entry command matches
+// that no temporary grant covers
+func CommandNeedsApproval(command string) (string, bool) {
+	patterns := Get().ApprovalPatterns
+	approval, tooDeep, _ := commandPatternsInForce(command, patterns)
+	if len(approval) > 0 {
+		return approval[0], true
+	}
+	return "", tooDeep && len(patterns) > 0
+}
+
+// RequestApproval records an operation that must be approved before it
+// runs and returns it with its token
+func RequestApproval(tool, summary string, arguments map[string]any) (types.PendingApproval, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return types.PendingApproval{}, fmt.Errorf("failed to create approval token: %v", err)
+	}
+
+	timeout := Get().ApprovalTimeoutSeconds
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	now := time.Now()
+	pending := types.PendingApproval{
+		Token:       "approval-" + hex.EncodeToString(token),
+		Tool:        tool,
+		Summary:     summary,
+		Arguments:   arguments,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(time.Duration(timeout) * time.Second),
+	}
+
+	approvalMutex.Lock()
+	defer approvalMutex.Unlock()
+	pruneApprovals(now)
+	pendingApprovals[pending.Token] = pending
+	return pending, nil
+}
+
+// TakeApproval removes a pending operation so it can run; each token can
+// be used once
+func TakeApproval(token string) (types.PendingApproval, error) {
+	approvalMutex.Lock()
+	defer approvalMutex.Unlock()
+
+	pending, ok := pendingApprovals[token]
+	if !ok {
+		return pending, fmt.Errorf("no pending operation with token %s", token)
+	}
+	delete(pendingApprovals, token)
+	if time.Now().After(pending.ExpiresAt) {
+		return pending, fmt.Errorf("approval token %s expired at %s", token, pending.ExpiresAt.Format(time.RFC3339))
+	}
+	return pending, nil
+}
+
+// ListPendingApprovals returns the operations waiting for approval, oldest
+// first
+func ListPendingApprovals() []types.PendingApproval {
+	approvalMutex.Lock()
+	defer approvalMutex.Unlock()
+
+	pruneApprovals(time.Now())
+	list := make([]types.PendingApproval, 0, len(pendingApprovals))
+	for _, pending := range pendingApprovals {
+		list = append(list, pending)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RequestedAt.Before(list[j].RequestedAt)
+	})
+	return list
+}
+
+// pruneApprovals drops expired operations; callers hold approvalMutex
+func pruneApprovals(now time.Time) {
+	for token, pending := range pendingApprovals {
+		if now.After(pending.ExpiresAt) {
+			delete(pendingApprovals, token)
+		}
+	}
+}
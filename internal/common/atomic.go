@@ -0,0 +1,102 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces the contents of path without ever leaving a
+// partially written file behind: data goes to a temporary file in the same
+// directory, is synced and then renamed over the target. An existing
+// file keeps its permissions; perm applies to new files.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteFileAtomicFunc(path, perm, func(w io.Writer) error {
+		_, err := io.Copy(w, bytes.NewReader(data))
+		return err
+	})
+}
+
+// AppendFileAtomic appends data to path through an atomic replace, so a
+// failed append leaves the original file intact
+func AppendFileAtomic(path string, data []byte, perm os.FileMode) error {
+	target := resolveWriteTarget(path)
+	return WriteFileAtomicFunc(target, perm, func(w io.Writer) error {
+		existing, err := os.Open(target)
+		if err == nil {
+			_, err = io.Copy(w, existing)
+			existing.Close()
+			if err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+}
+
+// WriteFileAtomicFunc streams the new contents of path from write and
+// atomically renames the result into place once write succeeds
+func WriteFileAtomicFunc(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	target := resolveWriteTarget(path)
+	if info, err := os.Stat(target); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := write(tmp); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// resolveWriteTarget follows a symlink so the rename replaces the file it
+// points to rather than the link itself
+func resolveWriteTarget(path string) string {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return resolved
+		}
+	}
+	return path
+}
+
+// syncDir flushes a directory entry so a completed rename survives a crash.
+// Not every platform supports syncing directories, so errors are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,304 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// Outcomes of audited tool calls
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeError   = "error"
+)
+
+// maxAuditValueBytes is how much of each string argument the audit log
+// keeps, so that file contents written by a tool do not fill it
+const maxAuditValueBytes = 1024
+
+// PathArguments are the tool arguments naming local files, which are
+// listed as the files an audited call touched and checked against the
+// directories of client roles, grants and policy rules. Every tool
+// argument naming a local file must be listed here.
+var PathArguments = map[string]bool{
+	"path": true, "paths": true, "files": true, "filepath": true, "directory": true,
+	"repo_path": true, "working_dir": true, "root": true, "base_dir": true,
+	"source": true, "source_path": true, "destination": true, "destination_path": true, "target": true,
+	"output_path": true, "output_file": true, "local_path": true, "manifest_path": true, "backup_path": true,
+	"old_path": true, "new_path": true, "key_file": true, "identity_file": true, "known_hosts_file": true,
+	"config_file": true, "dictionary": true,
+}
+
+// JSONArguments are the tool arguments holding JSON text that names more
+// files or commands, such as the edits of edit_multiple_files or the
+// stages of run_pipeline. They are decoded to find the paths inside;
+// arguments holding file content never are.
+var JSONArguments = map[string]bool{"files": true, "commands": true, "stages": true, "arguments": true}
+
+// toolPathExtractors report the files a tool names other than through
+// PathArguments, such as the files apply_patch's patch changes
+var toolPathExtractors = map[string]func(arguments map[string]any) []string{
+	"apply_patch": patchArgumentPaths,
+}
+
+// auditSensitiveArguments are the tool arguments holding passphrases,
+// passwords, tokens or key material, whose values the audit log masks
+var auditSensitiveArguments = map[string]bool{
+	"passphrase": true, "password": true, "token": true, "secret": true,
+	"api_key": true, "private_key": true,
+}
+
+var auditMutex sync.Mutex
+
+// AuditLogFilter selects entries returned by QueryAuditLog
+type AuditLogFilter struct {
+	Since   time.Time
+	Until   time.Time
+	Tool    string
+	Session string
+	// Outcome is AuditOutcomeSuccess, AuditOutcomeError or empty for both
+	Outcome string
+	// Path matches calls that touched this file or a file below it
+	Path string
+	// Query matches the arguments or error, ignoring case
+	Query string
+	Limit int // <= 0 means unlimited
+}
+
+// auditLogPath returns the audit log file: the auditLogPath config value,
+// or a JSON lines file next to the config file
+func auditLogPath() string {
+	if path := Get().AuditLogPath; path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-audit.jsonl")
+}
+
+// AuditArguments prepares tool arguments for the audit log: values of
+// auditSensitiveArguments are masked, stored secrets and passwords in URLs
+// hidden and long strings shortened. It also returns the local files the
+// arguments name, including those inside JSONArguments.
+func AuditArguments(arguments map[string]any) (map[string]any, []string) {
+	var files []string
+	redacted, _ := redactAuditValue("", arguments, &files).(map[string]any)
+	return redacted, files
+}
+
+// ToolCallPaths returns the local files a call of tool names: those
+// AuditArguments finds and those its toolPathExtractors entry reports
+func ToolCallPaths(tool string, arguments map[string]any) []string {
+	_, files := AuditArguments(arguments)
+	if extract := toolPathExtractors[tool]; extract != nil {
+		files = append(files, extract(arguments)...)
+	}
+	return files
+}
+
+func redactAuditValue(key string, value any, files *[]string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for name, item := range v {
+			if auditSensitiveArguments[name] {
+				redacted[name] = MaskedValue
+				continue
+			}
+			redacted[name] = redactAuditValue(name, item, files)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, item := range v {
+			redacted[i] = redactAuditValue(key, item, files)
+		}
+		return redacted
+	case string:
+		decoded, isJSON := any(nil), false
+		if JSONArguments[key] {
+			decoded, isJSON = decodeJSONArgument(v)
+		}
+		switch {
+		case isJSON:
+			// Kept as JSON text, with the values inside redacted
+			if data, err := json.Marshal(redactAuditValue(key, decoded, files)); err == nil {
+				v = string(data)
+			}
+		case PathArguments[key] && v != "":
+			if absPath, err := filepath.Abs(v); err == nil {
+				*files = append(*files, absPath)
+			}
+		}
+		v = maskURLPassword(RedactSecrets(v))
+		if len(v) > maxAuditValueBytes {
+			v = fmt.Sprintf("%s... (%d bytes)", strings.ToValidUTF8(v[:maxAuditValueBytes], ""), len(v))
+		}
+		return v
+	}
+	return value
+}
+
+// RecordAudit appends a tool call to the audit log, rotating the log when
+// it would grow past auditLogMaxBytes. Failures are logged and never fail
+// the call.
+func RecordAudit(entry types.AuditEntry) {
+	entry.Error = RedactSecrets(entry.Error)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Audit log error: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	cfg := Get()
+	path := auditLogPath()
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		log.Printf("Audit log error: %v", err)
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > cfg.AuditLogMaxBytes {
+		if err := rotateAuditLog(path, cfg.AuditLogMaxFiles); err != nil {
+			log.Printf("Audit log rotation error: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Audit log error: %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		log.Printf("Audit log error: %v", err)
+	}
+}
+
+// rotateAuditLog renames the log to path.1, shifting older logs up and
+// dropping the one past maxFiles; callers hold auditMutex
+func rotateAuditLog(path string, maxFiles int) error {
+	if maxFiles < 1 {
+		maxFiles = 1
+	}
+	os.Remove(fmt.Sprintf("%s.%d", path, maxFiles))
+	for i := maxFiles - 1; i >= 1; i-- {
+		older := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(older); err == nil {
+			if err := os.Rename(older, fmt.Sprintf("%s.%d", path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// readAuditLog returns the entries of the audit log and its rotated files,
+// oldest first. Lines that cannot be parsed are skipped.
+func readAuditLog() ([]types.AuditEntry, error) {
+	path := auditLogPath()
+	files := []string{path}
+	for i := 1; ; i++ {
+		rotated := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(rotated); err != nil {
+			break
+		}
+		files = append([]string{rotated}, files...)
+	}
+
+	var entries []types.AuditEntry
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			var entry types.AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// QueryAuditLog returns audited tool calls matching filter, newest first
+func QueryAuditLog(filter AuditLogFilter) ([]types.AuditEntry, error) {
+	auditMutex.Lock()
+	entries, err := readAuditLog()
+	auditMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	filterPath := ""
+	if filter.Path != "" {
+		if filterPath, err = filepath.Abs(filter.Path); err != nil {
+			return nil, fmt.Errorf("invalid path %s: %v", filter.Path, err)
+		}
+	}
+	query := strings.ToLower(filter.Query)
+
+	matched := []types.AuditEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		switch {
+		case !filter.Since.IsZero() && entry.Time.Before(filter.Since):
+			continue
+		case !filter.Until.IsZero() && entry.Time.After(filter.Until):
+			continue
+		case filter.Tool != "" && entry.Tool != filter.Tool:
+			continue
+		case filter.Session != "" && entry.Session != filter.Session:
+			continue
+		case filter.Outcome != "" && entry.Outcome != filter.Outcome:
+			continue
+		case filterPath != "" && !auditTouched(entry, filterPath):
+			continue
+		case query != "" && !auditContains(entry, query):
+			continue
+		}
+		matched = append(matched, entry)
+		if filter.Limit > 0 && len(matched) >= filter.Limit {
+			break
+		}
+	}
+	return matched, nil
+}
+
+// auditTouched reports whether an entry touched path or a file below it
+func auditTouched(entry types.AuditEntry, path string) bool {
+	for _, file := range entry.Files {
+		if IsSubPath(file, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditContains reports whether the arguments or error of an entry
+// contain the lower-case query
+func auditContains(entry types.AuditEntry, query string) bool {
+	arguments, _ := json.Marshal(entry.Arguments)
+	return strings.Contains(strings.ToLower(string(arguments)), query) ||
+		strings.Contains(strings.ToLower(entry.Error), query)
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAuditArgumentsMasksSensitiveArguments(t *testing.T) {
+	arguments, files := AuditArguments(map[string]any{
+		"passphrase": "correct horse battery staple",
+		"session_id": "session-1",
+		"old_path":   "a.txt",
+		"new_path":   "b.txt",
+		"key_file":   "secret.key",
+	})
+
+	if arguments["passphrase"] != MaskedValue {
+		t.Errorf("passphrase = %v, want it masked", arguments["passphrase"])
+	}
+	if arguments["session_id"] != "session-1" {
+		t.Errorf("session_id = %v, want it kept", arguments["session_id"])
+	}
+	for _, name := range []string{"a.txt", "b.txt", "secret.key"} {
+		path, _ := filepath.Abs(name)
+		if !slices.Contains(files, path) {
+			t.Errorf("files %v do not include %s", files, path)
+		}
+	}
+}
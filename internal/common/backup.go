@@ -0,0 +1,346 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// Backups are named <original>.backup.<unix seconds>
+var backupNamePattern = regexp.MustCompile(`^(.+)\.backup\.(\d+)$`)
+
+var (
+	backupDirsMutex sync.Mutex
+	// backupDirs are the directories backups were written to since the
+	// server started, which SweepBackups prunes
+	backupDirs = make(map[string]bool)
+)
+
+// backupPathFor returns where a backup of filePath taken at t is written
+func backupPathFor(filePath string, t time.Time) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(absPath)
+	if mirror := backupMirrorDir(dir); mirror != "" {
+		dir = mirror
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s.backup.%d", filepath.Base(absPath), t.Unix())), nil
+}
+
+// backupMirrorDir maps a directory to its counterpart inside the configured
+// backup directory, or returns "" when backups are kept next to originals
+func backupMirrorDir(absDir string) string {
+	backupDir := Get().BackupDirectory
+	if backupDir == "" {
+		return ""
+	}
+	volume := filepath.VolumeName(absDir)
+	return filepath.Join(backupDir, strings.TrimSuffix(volume, ":"), absDir[len(volume):])
+}
+
+// backupOriginalPath derives the file a backup was taken from
+func backupOriginalPath(backupPath string) (string, time.Time, bool) {
+	match := backupNamePattern.FindStringSubmatch(filepath.Base(backupPath))
+	if match == nil {
+		return "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	created := time.Unix(unix, 0)
+
+	original := filepath.Join(filepath.Dir(backupPath), match[1])
+	backupDir := Get().BackupDirectory
+	if backupDir == "" || !IsSubPath(backupPath, backupDir) {
+		return original, created, true
+	}
+
+	rel, err := filepath.Rel(backupDir, original)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	if runtime.GOOS == "windows" {
+		// The first component is the drive letter
+		drive, rest, _ := strings.Cut(rel, string(filepath.Separator))
+		return drive + ":" + string(filepath.Separator) + rest, created, true
+	}
+	return string(filepath.Separator) + rel, created, true
+}
+
+// BackupOriginal returns the path of the file a backup was taken from
+func BackupOriginal(backupPath string) (string, error) {
+	absBackup, err := filepath.Abs(backupPath)
+	if err != nil {
+		return "", err
+	}
+	original, _, ok := backupOriginalPath(absBackup)
+	if !ok {
+		return "", fmt.Errorf("%s is not a backup file", backupPath)
+	}
+	return original, nil
+}
+
+// IsInBackupDirectory reports whether path lies inside the configured
+// backup directory
+func IsInBackupDirectory(path string) bool {
+	backupDir := Get().BackupDirectory
+	if backupDir == "" {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return IsSubPath(absPath, backupDir)
+}
+
+// ListBackups returns the backups of a file, or of the files in a directory,
+// newest first. Backups next to the originals and in the configured backup
+// directory are both included.
+func ListBackups(target string, recursive bool) ([]types.BackupInfo, error) {
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	onlyFile := ""
+	if info, err := os.Stat(absTarget); err == nil && info.IsDir() {
+		dirs = append(dirs, absTarget)
+		if mirror := backupMirrorDir(absTarget); mirror != "" {
+			dirs = append(dirs, mirror)
+		}
+	} else {
+		// A missing target is treated as a file so backups of deleted files
+		// can still be found
+		onlyFile = absTarget
+		recursive = false
+		dirs = append(dirs, filepath.Dir(absTarget))
+		if mirror := backupMirrorDir(filepath.Dir(absTarget)); mirror != "" {
+			dirs = append(dirs, mirror)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var backups []types.BackupInfo
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == dir && os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if seen[path] {
+				return nil
+			}
+
+			original, created, ok := backupOriginalPath(path)
+			if !ok || (onlyFile != "" && original != onlyFile) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			_, statErr := os.Stat(original)
+
+			seen[path] = true
+			backups = append(backups, types.BackupInfo{
+				Path:           path,
+				OriginalPath:   original,
+				CreatedAt:      created,
+				Size:           info.Size(),
+				OriginalExists: statErr == nil,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].Path < backups[j].Path
+	})
+	return backups, nil
+}
+
+// PruneBackups removes backups beyond the newest maxCount per original file
+// and backups older than maxAgeDays. A zero limit is not applied. With
+// dryRun the backups that would be removed are returned without deleting.
+func PruneBackups(target string, recursive bool, maxCount, maxAgeDays int, dryRun bool) ([]types.BackupInfo, error) {
+	if maxCount <= 0 && maxAgeDays <= 0 {
+		return nil, fmt.Errorf("no retention limit given")
+	}
+
+	backups, err := ListBackups(target, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+	kept := make(map[string]int)
+	var pruned []types.BackupInfo
+	for _, backup := range backups {
+		kept[backup.OriginalPath]++
+		tooMany := maxCount > 0 && kept[backup.OriginalPath] > maxCount
+		tooOld := maxAgeDays > 0 && backup.CreatedAt.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+				return pruned, fmt.Errorf("failed to remove %s: %w", backup.Path, err)
+			}
+		}
+		pruned = append(pruned, backup)
+	}
+	return pruned, nil
+}
+
+// applyBackupRetention prunes the backups of a file according to the
+// configured retention policy
+func applyBackupRetention(filePath string) {
+	config := Get()
+	if config.BackupMaxCount <= 0 && config.BackupMaxAgeDays <= 0 {
+		return
+	}
+	PruneBackups(filePath, false, config.BackupMaxCount, config.BackupMaxAgeDays, false)
+}
+
+// rememberBackupDirectory records a directory a backup was written to, for
+// SweepBackups
+func rememberBackupDirectory(dir string) {
+	backupDirsMutex.Lock()
+	backupDirs[dir] = true
+	backupDirsMutex.Unlock()
+}
+
+// SweepBackups applies the retention limits to every backup in the backup
+// directory and in the directories backups were written to since the
+// server started, returning the backups removed
+func SweepBackups() ([]types.BackupInfo, error) {
+	config := Get()
+	if config.BackupMaxCount <= 0 && config.BackupMaxAgeDays <= 0 {
+		return nil, nil
+	}
+
+	var targets []string
+	recursive := make(map[string]bool)
+	if config.BackupDirectory != "" {
+		targets = append(targets, config.BackupDirectory)
+		recursive[config.BackupDirectory] = true
+	}
+	backupDirsMutex.Lock()
+	for dir := range backupDirs {
+		if config.BackupDirectory == "" || !IsSubPath(dir, config.BackupDirectory) {
+			targets = append(targets, dir)
+		}
+	}
+	backupDirsMutex.Unlock()
+	sort.Strings(targets)
+
+	var pruned []types.BackupInfo
+	var errs []error
+	for _, target := range targets {
+		removed, err := PruneBackups(target, recursive[target], config.BackupMaxCount, config.BackupMaxAgeDays, false)
+		pruned = append(pruned, removed...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// StartBackupSweep runs SweepBackups now and every
+// backupSweepIntervalMinutes until ctx is done
+func StartBackupSweep(ctx context.Context) {
+	go func() {
+		for {
+			pruned, err := SweepBackups()
+			if err != nil {
+				log.Printf("Backup sweep error: %v", err)
+			}
+			if len(pruned) > 0 {
+				log.Printf("Backup sweep removed %d backups past the retention limits", len(pruned))
+			}
+
+			interval := time.Duration(Get().BackupSweepIntervalMinutes) * time.Minute
+			if interval <= 0 {
+				interval = DefaultBackupSweepInterval * time.Minute
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// RestoreBackup copies a backup over its original file, or over target when
+// given. With backupCurrent the current content is backed up first; its
+// backup path is returned along with the restored path.
+func RestoreBackup(backupPath, target string, backupCurrent bool) (string, string, error) {
+	original, err := BackupOriginal(backupPath)
+	if err != nil {
+		return "", "", err
+	}
+	if target == "" {
+		target = original
+	}
+
+	content, err := os.ReadFile(backupPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	mode := os.FileMode(0644)
+	currentBackup := ""
+	if info, err := os.Stat(target); err == nil {
+		if info.IsDir() {
+			return "", "", fmt.Errorf("%s is a directory", target)
+		}
+		mode = info.Mode().Perm()
+		if backupCurrent {
+			if currentBackup, err = CreateBackup(target); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if err := EnsureDir(filepath.Dir(target)); err != nil {
+		return "", "", fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if err := WriteFileAtomic(target, content, mode); err != nil {
+		return "", "", fmt.Errorf("failed to restore backup: %w", err)
+	}
+	return target, currentBackup, nil
+}
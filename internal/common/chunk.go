@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"jarvis/internal/types"
+)
+
+// DefaultChunkSize is the default number of bytes returned per chunk
+const DefaultChunkSize = 64 * 1024
+
+// EncodeChunkToken builds an opaque continuation token for the next chunk.
+// The file's modification time is embedded so that stale tokens are detected.
+func EncodeChunkToken(offset int64, modTime time.Time) string {
+	raw := fmt.Sprintf("%d:%d", offset, modTime.UnixNano())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeChunkToken parses a continuation token produced by EncodeChunkToken
+func DecodeChunkToken(token string) (int64, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid continuation token")
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid continuation token")
+	}
+
+	offset, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid continuation token")
+	}
+	modTime, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid continuation token")
+	}
+
+	return offset, modTime, nil
+}
+
+// ReadFileChunk reads up to maxBytes starting at offset without loading the
+// whole file. When alignLines is set the chunk is cut after the last complete
+// line; otherwise it is only trimmed so a UTF-8 sequence is never split.
+func ReadFileChunk(filePath string, offset, maxBytes int64, alignLines bool) (*types.FileChunk, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultChunkSize
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if offset < 0 || offset > info.Size() {
+		return nil, fmt.Errorf("offset %d is outside file bounds (size %d)", offset, info.Size())
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek: %w", err)
+	}
+
+	buf := make([]byte, maxBytes)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	buf = buf[:n]
+
+	atEOF := offset+int64(n) >= info.Size()
+	if !atEOF && n >= utf8.UTFMax {
+		if alignLines {
+			if idx := bytes.LastIndexByte(buf, '\n'); idx >= 0 {
+				buf = buf[:idx+1]
+			}
+		}
+		// Never split a multi-byte rune across chunks
+		start := len(buf) - 1
+		for start > 0 && len(buf)-start < utf8.UTFMax && !utf8.RuneStart(buf[start]) {
+			start--
+		}
+		if start >= 0 && !utf8.FullRune(buf[start:]) {
+			buf = buf[:start]
+		}
+	}
+
+	next := offset + int64(len(buf))
+	chunk := &types.FileChunk{
+		Path:       filePath,
+		Offset:     offset,
+		Length:     int64(len(buf)),
+		FileSize:   info.Size(),
+		NextOffset: next,
+		EOF:        next >= info.Size(),
+		Content:    string(buf),
+	}
+	if !chunk.EOF {
+		chunk.ContinuationToken = EncodeChunkToken(next, info.ModTime())
+	}
+
+	return chunk, nil
+}
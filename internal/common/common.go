@@ -1,20 +1,25 @@
 package common
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
-	"image"
-	"image/jpeg"
-	"image/png"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"jarvis/internal/types"
 )
@@ -27,28 +32,77 @@ var (
 )
 
 const (
-	DefaultShell           = "bash"
-	DefaultFileReadLimit   = 1000
-	DefaultFileWriteLimit  = 50
-	DefaultTelemetryStatus = false
+	DefaultShell            = "bash"
+	DefaultFileReadLimit    = 1000
+	DefaultFileWriteLimit   = 50
+	DefaultTelemetryStatus  = false
+	DefaultDiffStyle        = DiffStyleWord
+	DefaultMaxOutputBytes   = 100 * 1024
+	DefaultLanguage         = LanguageEnglish
+	DefaultCommandTimeout   = 30
+	DefaultScriptTimeout    = 60
+	DefaultFetchTimeout     = 30
+	DefaultDownloadTimeout  = 600
+	DefaultApprovalTimeout  = 300
+	DefaultAuditLogMaxBytes = 10 * 1024 * 1024
+	DefaultAuditLogMaxFiles = 5
+
+	DefaultMetricsReportInterval = 60
+	DefaultBackupSweepInterval   = 60
 )
 
+// DefaultSecretEnvPatterns are the environment variable names whose values
+// get_environment masks unless secretEnvPatterns is configured
+var DefaultSecretEnvPatterns = []string{
+	"*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*", "*CREDENTIAL*", "*API_KEY*", "*APIKEY*",
+	"*PRIVATE_KEY*", "*ACCESS_KEY*", "*_KEY", "*AUTH*", "*COOKIE*", "*SESSION*",
+}
+
+// DefaultDeniedPathPatterns are the path elements file tools refuse unless
+// deniedPathPatterns is configured
+var DefaultDeniedPathPatterns = []string{".ssh", ".aws", ".gnupg"}
+
 func Initialize() {
 	once.Do(func() {
-		instance = &types.ServerConfig{
-			BlockedCommands:    []string{"rm -rf", "dd", "mkfs", "format", "del /f /s /q"},
-			DefaultShell:       DefaultShell,
-			AllowedDirectories: []string{"/home", "/tmp", "/var/log", "/opt/jarvis"},
-			FileReadLineLimit:  DefaultFileReadLimit,
-			FileWriteLineLimit: DefaultFileWriteLimit,
-			TelemetryEnabled:   DefaultTelemetryStatus,
-		}
+		instance = defaultConfig()
+		loadConfigKey()
 
 		// Try to load from config file if exists
 		loadFromFile()
+		notifyConfigChange()
 	})
 }
 
+// defaultConfig returns the configuration used when the config file does
+// not set a value
+func defaultConfig() *types.ServerConfig {
+	return &types.ServerConfig{
+		BlockedCommands:    []string{"rm -rf", "dd", "mkfs", "format", "del /f /s /q"},
+		DefaultShell:       DefaultShell,
+		AllowedDirectories: []string{"/home", "/tmp", "/var/log", "/opt/jarvis"},
+		FileReadLineLimit:  DefaultFileReadLimit,
+		FileWriteLineLimit: DefaultFileWriteLimit,
+		TelemetryEnabled:   DefaultTelemetryStatus,
+		DeniedPathPatterns: append([]string(nil), DefaultDeniedPathPatterns...),
+		DiffStyle:          DefaultDiffStyle,
+		MaxOutputBytes:     DefaultMaxOutputBytes,
+		Language:           DefaultLanguage,
+
+		CommandTimeoutSeconds:  DefaultCommandTimeout,
+		ScriptTimeoutSeconds:   DefaultScriptTimeout,
+		FetchTimeoutSeconds:    DefaultFetchTimeout,
+		DownloadTimeoutSeconds: DefaultDownloadTimeout,
+
+		MetricsReportIntervalSeconds: DefaultMetricsReportInterval,
+		BackupSweepIntervalMinutes:   DefaultBackupSweepInterval,
+		ApprovalTimeoutSeconds:       DefaultApprovalTimeout,
+		SecretEnvPatterns:            append([]string(nil), DefaultSecretEnvPatterns...),
+		AuditLogMaxBytes:             DefaultAuditLogMaxBytes,
+		AuditLogMaxFiles:             DefaultAuditLogMaxFiles,
+		ToolCategories:               defaultToolCategories(),
+	}
+}
+
 func Get() *types.ServerConfig {
 	mutex.RLock()
 	defer mutex.RUnlock()
@@ -62,35 +116,45 @@ func Get() *types.ServerConfig {
 	return &config
 }
 
-// Set updates a configuration value (thread-safe)
+// Set updates a configuration value (thread-safe). The value is parsed as
+// the type ConfigSchema gives the key, and the configuration it results in
+// must pass validation.
 func Set(key, value string) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+	field, ok := LookupConfigField(key)
+	if !ok {
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown configuration key: %s", key))
+	}
+	if field.EditedWith != "" {
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("%s cannot be set with set_config_value; use %s", key, field.EditedWith))
+	}
+	parsed, err := parseConfigValue(field, value)
+	if err != nil {
+		return WithErrorCode(ErrorValidationFailed, err)
+	}
+	patch, err := json.Marshal(map[string]any{key: parsed})
+	if err != nil {
+		return fmt.Errorf("invalid %s value: %v", key, err)
+	}
 
-	if instance == nil {
-		Initialize()
+	// Validation reads the configuration, so it runs without the lock
+	candidate, err := patchConfig(Get(), patch)
+	if err != nil {
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("invalid %s value: %v", key, err))
+	}
+	if err := ValidateConfig(candidate); err != nil {
+		return fmt.Errorf("invalid %s value: %w", key, err)
 	}
 
-	switch key {
-	case "defaultShell":
-		instance.DefaultShell = value
-	case "telemetryEnabled":
-		instance.TelemetryEnabled = value == "true"
-	case "fileReadLineLimit":
-		if limit, err := parseIntValue(value); err == nil {
-			instance.FileReadLineLimit = limit
-		} else {
-			return fmt.Errorf("invalid fileReadLineLimit value: %s", value)
-		}
-	case "fileWriteLineLimit":
-		if limit, err := parseIntValue(value); err == nil {
-			instance.FileWriteLineLimit = limit
-		} else {
-			return fmt.Errorf("invalid fileWriteLineLimit value: %s", value)
-		}
-	default:
-		return fmt.Errorf("unknown configuration key: %s", key)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Applied to the configuration as it is now, in case it changed
+	// while the candidate was validated
+	updated, err := patchConfig(instance, patch)
+	if err != nil {
+		return fmt.Errorf("invalid %s value: %v", key, err)
 	}
+	instance = updated
 
 	// Save to file after successful update
 	saveToFile()
@@ -116,9 +180,14 @@ func AddAllowedDirectory(dir string) error {
 		Initialize()
 	}
 
-	// Check if already exists
+	if err := validateAllowedDirectory(dir); err != nil {
+		return err
+	}
+
+	// Check if already exists, ignoring "~" and trailing slashes
+	normalized, _ := normalizeAllowedDirectory(dir)
 	for _, existing := range instance.AllowedDirectories {
-		if existing == dir {
+		if other, err := normalizeAllowedDirectory(existing); err == nil && other == normalized {
 			return nil // Already exists
 		}
 	}
@@ -137,8 +206,9 @@ func RemoveAllowedDirectory(dir string) error {
 		Initialize()
 	}
 
+	normalized, _ := normalizeAllowedDirectory(dir)
 	for i, existing := range instance.AllowedDirectories {
-		if existing == dir {
+		if other, err := normalizeAllowedDirectory(existing); existing == dir || (err == nil && other == normalized) {
 			instance.AllowedDirectories = append(
 				instance.AllowedDirectories[:i],
 				instance.AllowedDirectories[i+1:]...,
@@ -148,7 +218,7 @@ func RemoveAllowedDirectory(dir string) error {
 		}
 	}
 
-	return fmt.Errorf("directory not found in allowed list: %s", dir)
+	return WithErrorCode(ErrorNotFound, fmt.Errorf("directory not found in allowed list: %s", dir))
 }
 
 // AddBlockedCommand adds a command pattern to the blocked list
@@ -172,38 +242,184 @@ func AddBlockedCommand(pattern string) error {
 	return nil
 }
 
-// IsCommandBlocked checks if a command contains any blocked patterns
-func IsCommandBlocked(command string) bool {
-	config := Get()
+// RemoveBlockedCommand removes a command pattern from the blocked list
+func RemoveBlockedCommand(pattern string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	for _, blocked := range config.BlockedCommands {
-		if contains(command, blocked) {
-			return true
+	if instance == nil {
+		Initialize()
+	}
+
+	for i, existing := range instance.BlockedCommands {
+		if existing == pattern {
+			// Copied rather than modified in place, since Get shares the slice
+			blocked := make([]string, 0, len(instance.BlockedCommands)-1)
+			blocked = append(blocked, instance.BlockedCommands[:i]...)
+			instance.BlockedCommands = append(blocked, instance.BlockedCommands[i+1:]...)
+			saveToFile()
+			return nil
 		}
 	}
-	return false
+
+	return WithErrorCode(ErrorNotFound, fmt.Errorf("pattern not found in blocked list: %s", pattern))
+}
+
+// IsCommandBlocked checks if a command matches a blocked pattern that no
+// temporary grant covers; see MatchCommandPattern for how commands are
+// compared
+func IsCommandBlocked(command string) bool {
+	patterns := Get().BlockedCommands
+	blocked, tooDeep, _ := commandPatternsInForce(command, patterns)
+	return len(blocked) > 0 || (tooDeep && len(patterns) > 0)
+}
+
+// Decisions of CheckCommandPolicy
+const (
+	PolicyBlocked       = "blocked"
+	PolicyNeedsApproval = "needs_approval"
+	PolicyAllowed       = "allowed"
+)
+
+// CheckCommandPolicy explains what the command tools would do with a
+// command without running it: refuse it for a blocked pattern, wait for
+// approval for an approval pattern, or run it. Every matching pattern is
+// listed, since removing one may leave another in force.
+func CheckCommandPolicy(command string) types.CommandPolicyCheck {
+	cfg := Get()
+	check := types.CommandPolicyCheck{Command: SanitizeCommand(command), Sandbox: cfg.Sandbox}
+
+	blocked, tooDeep, grant := commandPatternsInForce(check.Command, cfg.BlockedCommands)
+	approval, _, _ := commandPatternsInForce(check.Command, cfg.ApprovalPatterns)
+	check.BlockedBy, check.ApprovalPatterns, check.NestedTooDeep = blocked, approval, tooDeep
+
+	switch {
+	case tooDeep && len(cfg.BlockedCommands) > 0:
+		check.Decision = PolicyBlocked
+		check.Reason = "the command nests too deeply to check against the blocked patterns"
+	case len(blocked) > 0:
+		check.Decision = PolicyBlocked
+		check.Reason = fmt.Sprintf("the command matches blocked pattern %q", blocked[0])
+	case tooDeep && len(cfg.ApprovalPatterns) > 0:
+		check.Decision = PolicyNeedsApproval
+		check.Reason = "the command nests too deeply to check against the approval patterns"
+	case len(approval) > 0:
+		check.Decision = PolicyNeedsApproval
+		check.Reason = fmt.Sprintf("the command matches approval pattern %q", approval[0])
+	case grant != nil:
+		check.Decision = PolicyAllowed
+		check.Reason = fmt.Sprintf("the command is allowed by temporary grant %s for %q", grant.ID, grant.Value)
+	default:
+		check.Decision = PolicyAllowed
+		check.Reason = "the command matches no blocked or approval pattern"
+	}
+	return check
 }
 
 // IsPathAllowed checks if a path is within allowed directories
 
-// Validate checks if the current configuration is valid
+// Validate checks if the current configuration is valid, and reports a
+// config file that was rejected
 func Validate() error {
-	config := Get()
+	if err := ConfigFileError(); err != nil {
+		return err
+	}
+	return ValidateConfig(Get())
+}
+
+// ValidateConfig checks a configuration, such as a profile before it is
+// switched to
+func ValidateConfig(config *types.ServerConfig) error {
+	return WithErrorCode(ErrorValidationFailed, validateConfig(config))
+}
+
+func validateConfig(config *types.ServerConfig) error {
+	// Types, bounds and allowed values come from ConfigSchema
+	if err := validateConfigSchema(config); err != nil {
+		return err
+	}
 
 	if config.DefaultShell == "" {
 		return fmt.Errorf("defaultShell cannot be empty")
 	}
 
-	if config.FileReadLineLimit < 1 {
-		return fmt.Errorf("fileReadLineLimit must be positive")
+	if len(config.AllowedDirectories) == 0 {
+		return fmt.Errorf("at least one allowed directory must be specified")
+	}
+	for _, entry := range config.AllowedDirectories {
+		if err := validateAllowedDirectory(entry); err != nil {
+			return err
+		}
+	}
+
+	for name, template := range config.CommandTemplates {
+		if err := ValidateCommandTemplate(name, template); err != nil {
+			return fmt.Errorf("command template %s: %w", name, err)
+		}
 	}
 
-	if config.FileWriteLineLimit < 1 {
-		return fmt.Errorf("fileWriteLineLimit must be positive")
+	for _, name := range config.RunAsUsers {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("runAsUsers cannot contain empty names")
+		}
 	}
 
-	if len(config.AllowedDirectories) == 0 {
-		return fmt.Errorf("at least one allowed directory must be specified")
+	for name, host := range config.SSHHosts {
+		if err := ValidateSSHHost(name, host); err != nil {
+			return fmt.Errorf("SSH host %s: %w", name, err)
+		}
+	}
+
+	if config.MetricsListenAddress != "" {
+		if _, _, err := net.SplitHostPort(config.MetricsListenAddress); err != nil {
+			return fmt.Errorf("invalid metricsListenAddress %q: use host:port", config.MetricsListenAddress)
+		}
+	}
+
+	for _, entry := range config.DeniedDirectories {
+		if _, err := filepath.Match(entry, ""); err != nil || strings.TrimSpace(entry) == "" {
+			return fmt.Errorf("invalid deniedDirectories entry %q", entry)
+		}
+	}
+
+	for _, pattern := range config.DeniedPathPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("invalid deniedPathPatterns entry %q", pattern)
+		}
+	}
+
+	for _, entry := range config.AllowedPackages {
+		if _, err := path.Match(entry, ""); err != nil {
+			return fmt.Errorf("invalid allowedPackages entry %q", entry)
+		}
+	}
+
+	for _, pattern := range config.SecretEnvPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid secretEnvPatterns entry %q", pattern)
+		}
+	}
+
+	for _, pattern := range config.AllowedURLPatterns {
+		if !strings.HasPrefix(pattern, "http://") && !strings.HasPrefix(pattern, "https://") {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("invalid allowedURLPatterns entry %q: it must start with http:// or https://", pattern))
+		}
+	}
+
+	if err := validateRateLimits(config); err != nil {
+		return err
+	}
+
+	if err := validateRoles(config); err != nil {
+		return err
+	}
+
+	if err := validatePolicyRules(config); err != nil {
+		return err
+	}
+
+	if err := validateTimeouts(config); err != nil {
+		return err
 	}
 
 	return nil
@@ -214,34 +430,14 @@ func Reset() {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	instance = nil
-	Initialize()
-	saveToFile()
-}
-
-// Helper functions
-
-func parseIntValue(value string) (int, error) {
-	var result int
-	_, err := fmt.Sscanf(value, "%d", &result)
-	return result, err
-}
-
-func contains(text, pattern string) bool {
-	return len(text) >= len(pattern) &&
-		(text == pattern ||
-			text[:len(pattern)] == pattern ||
-			text[len(text)-len(pattern):] == pattern ||
-			indexOf(text, pattern) != -1)
-}
-
-func indexOf(text, pattern string) int {
-	for i := 0; i <= len(text)-len(pattern); i++ {
-		if text[i:i+len(pattern)] == pattern {
-			return i
-		}
+	// Profiles are kept, so switching to one undoes the reset
+	var profiles map[string]json.RawMessage
+	if instance != nil {
+		profiles = instance.Profiles
 	}
-	return -1
+	instance = defaultConfig()
+	instance.Profiles = profiles
+	saveToFile()
 }
 
 // Configuration file management
@@ -261,54 +457,170 @@ func loadFromFile() {
 		return // Use defaults if config file doesn't exist
 	}
 
-	var fileConfig types.ServerConfig
-	if err := json.Unmarshal(data, &fileConfig); err != nil {
-		return // Use defaults if config file is invalid
+	// An invalid file is rejected as a whole rather than partly applied
+	config, err := parseConfigFile(data)
+	rememberConfigFile(data)
+	setConfigFileError(err)
+	if err != nil {
+		log.Printf("Using the default configuration: %v", err)
+		return
 	}
+	instance = config
+}
 
+// mergeFileConfig applies the values set in the config file to config
+func mergeFileConfig(config *types.ServerConfig, fileConfig *types.ServerConfig) {
 	// Merge with defaults (keep existing values, add missing ones)
 	if len(fileConfig.BlockedCommands) > 0 {
-		instance.BlockedCommands = fileConfig.BlockedCommands
+		config.BlockedCommands = fileConfig.BlockedCommands
 	}
 	if fileConfig.DefaultShell != "" {
-		instance.DefaultShell = fileConfig.DefaultShell
+		config.DefaultShell = fileConfig.DefaultShell
 	}
 	if len(fileConfig.AllowedDirectories) > 0 {
-		instance.AllowedDirectories = fileConfig.AllowedDirectories
+		config.AllowedDirectories = fileConfig.AllowedDirectories
 	}
 	if fileConfig.FileReadLineLimit > 0 {
-		instance.FileReadLineLimit = fileConfig.FileReadLineLimit
+		config.FileReadLineLimit = fileConfig.FileReadLineLimit
 	}
 	if fileConfig.FileWriteLineLimit > 0 {
-		instance.FileWriteLineLimit = fileConfig.FileWriteLineLimit
+		config.FileWriteLineLimit = fileConfig.FileWriteLineLimit
+	}
+	config.TelemetryEnabled = fileConfig.TelemetryEnabled
+	config.MetricsListenAddress = fileConfig.MetricsListenAddress
+	config.MetricsReportPath = fileConfig.MetricsReportPath
+	if fileConfig.MetricsReportIntervalSeconds > 0 {
+		config.MetricsReportIntervalSeconds = fileConfig.MetricsReportIntervalSeconds
+	}
+	if fileConfig.MaxWriteBytes > 0 {
+		config.MaxWriteBytes = fileConfig.MaxWriteBytes
+	}
+	if fileConfig.MaxFilesPerCall > 0 {
+		config.MaxFilesPerCall = fileConfig.MaxFilesPerCall
+	}
+	if fileConfig.MaxSessionWriteBytes > 0 {
+		config.MaxSessionWriteBytes = fileConfig.MaxSessionWriteBytes
+	}
+	if fileConfig.MaxSessionFilesWritten > 0 {
+		config.MaxSessionFilesWritten = fileConfig.MaxSessionFilesWritten
+	}
+	if fileConfig.MaxSessionBytesDownloaded > 0 {
+		config.MaxSessionBytesDownloaded = fileConfig.MaxSessionBytesDownloaded
+	}
+	if fileConfig.MaxSessionProcessesKilled > 0 {
+		config.MaxSessionProcessesKilled = fileConfig.MaxSessionProcessesKilled
+	}
+	if fileConfig.MaxSessionCommands > 0 {
+		config.MaxSessionCommands = fileConfig.MaxSessionCommands
+	}
+	if fileConfig.LargeEditLines > 0 {
+		config.LargeEditLines = fileConfig.LargeEditLines
+	}
+	if fileConfig.LargeEditPercent > 0 {
+		config.LargeEditPercent = fileConfig.LargeEditPercent
+	}
+	config.BackupDirectory = fileConfig.BackupDirectory
+	if fileConfig.BackupMaxCount > 0 {
+		config.BackupMaxCount = fileConfig.BackupMaxCount
+	}
+	if fileConfig.BackupMaxAgeDays > 0 {
+		config.BackupMaxAgeDays = fileConfig.BackupMaxAgeDays
+	}
+	if fileConfig.BackupSweepIntervalMinutes > 0 {
+		config.BackupSweepIntervalMinutes = fileConfig.BackupSweepIntervalMinutes
+	}
+	if fileConfig.DiffStyle != "" {
+		config.DiffStyle = fileConfig.DiffStyle
+	}
+	if fileConfig.MaxOutputBytes > 0 {
+		config.MaxOutputBytes = fileConfig.MaxOutputBytes
+	}
+	if fileConfig.Language != "" {
+		config.Language = fileConfig.Language
+	}
+	if fileConfig.CommandTimeoutSeconds > 0 {
+		config.CommandTimeoutSeconds = fileConfig.CommandTimeoutSeconds
+	}
+	if fileConfig.ScriptTimeoutSeconds > 0 {
+		config.ScriptTimeoutSeconds = fileConfig.ScriptTimeoutSeconds
+	}
+	if fileConfig.FetchTimeoutSeconds > 0 {
+		config.FetchTimeoutSeconds = fileConfig.FetchTimeoutSeconds
+	}
+	if fileConfig.DownloadTimeoutSeconds > 0 {
+		config.DownloadTimeoutSeconds = fileConfig.DownloadTimeoutSeconds
+	}
+	config.MaxCommandTimeoutSeconds = fileConfig.MaxCommandTimeoutSeconds
+	config.MaxScriptTimeoutSeconds = fileConfig.MaxScriptTimeoutSeconds
+	config.MaxFetchTimeoutSeconds = fileConfig.MaxFetchTimeoutSeconds
+	config.MaxDownloadTimeoutSeconds = fileConfig.MaxDownloadTimeoutSeconds
+	config.CommandTemplates = fileConfig.CommandTemplates
+	config.RunAsUsers = fileConfig.RunAsUsers
+	config.Sandbox = fileConfig.Sandbox
+	config.SandboxAllowNetwork = fileConfig.SandboxAllowNetwork
+	config.SSHHosts = fileConfig.SSHHosts
+	config.PackageManagementEnabled = fileConfig.PackageManagementEnabled
+	config.AllowedPackages = fileConfig.AllowedPackages
+	config.ApprovalRequired = fileConfig.ApprovalRequired
+	config.ApprovalPatterns = fileConfig.ApprovalPatterns
+	if fileConfig.ApprovalTimeoutSeconds > 0 {
+		config.ApprovalTimeoutSeconds = fileConfig.ApprovalTimeoutSeconds
+	}
+	config.DeniedDirectories = fileConfig.DeniedDirectories
+	if len(fileConfig.DeniedPathPatterns) > 0 {
+		config.DeniedPathPatterns = fileConfig.DeniedPathPatterns
+	}
+	if len(fileConfig.SecretEnvPatterns) > 0 {
+		config.SecretEnvPatterns = fileConfig.SecretEnvPatterns
+	}
+	config.AuditLogPath = fileConfig.AuditLogPath
+	if fileConfig.AuditLogMaxBytes > 0 {
+		config.AuditLogMaxBytes = fileConfig.AuditLogMaxBytes
+	}
+	if fileConfig.AuditLogMaxFiles > 0 {
+		config.AuditLogMaxFiles = fileConfig.AuditLogMaxFiles
+	}
+	config.AllowedURLPatterns = fileConfig.AllowedURLPatterns
+	if len(fileConfig.ToolCategories) > 0 {
+		config.ToolCategories = fileConfig.ToolCategories
 	}
-	instance.TelemetryEnabled = fileConfig.TelemetryEnabled
+	config.RateLimits = fileConfig.RateLimits
+	config.Roles = fileConfig.Roles
+	config.ClientRoles = fileConfig.ClientRoles
+	config.DefaultRole = fileConfig.DefaultRole
+	config.PolicyRules = fileConfig.PolicyRules
+	config.Profiles = fileConfig.Profiles
+	config.ActiveProfile = fileConfig.ActiveProfile
 }
 
+// saveToFile writes the configuration to the config file and tells the
+// OnConfigChange listeners what changed. The caller must hold mutex.
 func saveToFile() {
+	notifyConfigChange()
+
 	configPath := getConfigPath()
 	data, err := json.MarshalIndent(instance, "", "  ")
 	if err != nil {
 		return // Silently fail if can't marshal
 	}
 
-	os.WriteFile(configPath, data, 0644)
-}
-
-// GenerateCharacterDiff creates a character-level diff between two strings
-func GenerateCharacterDiff(original, replacement string) string {
-	if original == replacement {
-		return "No changes"
+	// A rejected file is kept rather than overwritten, so its settings can
+	// still be fixed and restored
+	if ConfigFileError() != nil {
+		if err := os.Rename(configPath, configPath+".rejected"); err == nil {
+			log.Printf("Rejected config file kept as %s.rejected", configPath)
+		}
 	}
 
-	var diff strings.Builder
-	diff.WriteString("- Original:\n")
-	diff.WriteString(original)
-	diff.WriteString("\n+ Replacement:\n")
-	diff.WriteString(replacement)
-	diff.WriteString("\n")
-
-	return diff.String()
+	// Remembered before writing, so a reload cannot pick up the write
+	// before it is signed
+	rememberConfigFile(data)
+	if WriteFileAtomic(configPath, data, 0644) == nil {
+		setConfigFileError(nil)
+		if err := signConfigFile(data); err != nil {
+			log.Printf("Config signature error: %v", err)
+		}
+	}
 }
 
 // OperationsOverlap checks if two edit operations overlap
@@ -316,45 +628,28 @@ func OperationsOverlap(op1, op2 types.EditOperation) bool {
 	return !(op1.EndLine < op2.StartLine || op2.EndLine < op1.StartLine)
 }
 
-// GenerateEditPreview creates a preview of edit operations
-func GenerateEditPreview(lines []string, operations []types.EditOperation) string {
-	var preview strings.Builder
-
-	for _, op := range operations {
-		preview.WriteString(fmt.Sprintf("Lines %d-%d:\n", op.StartLine, op.EndLine))
-		preview.WriteString("- Original:\n")
-		for i := op.StartLine - 1; i < op.EndLine && i < len(lines); i++ {
-			preview.WriteString(fmt.Sprintf("  %d: %s\n", i+1, lines[i]))
-		}
-		preview.WriteString("+ Replacement:\n")
-		replacementLines := strings.Split(op.Replacement, "\n")
-		for i, line := range replacementLines {
-			preview.WriteString(fmt.Sprintf("  %d: %s\n", op.StartLine+i, line))
-		}
-		if op.Description != "" {
-			preview.WriteString(fmt.Sprintf("  Description: %s\n", op.Description))
-		}
-		preview.WriteString("\n")
+// ApplyEditOperations returns lines with the operations applied. Line
+// numbers refer to the original lines, so operations may be in any order.
+func ApplyEditOperations(lines []string, operations []types.EditOperation) []string {
+	splices := make([]LineSplice, len(operations))
+	for i, op := range operations {
+		splices[i] = EditOperationSplice(op)
 	}
+	return ApplyLineSplices(lines, splices)
+}
 
-	return preview.String()
+// EditOperationSplice returns the line splice of an edit operation
+func EditOperationSplice(op types.EditOperation) LineSplice {
+	return LineSplice{Start: op.StartLine - 1, End: op.EndLine, Lines: SplitLines(op.Replacement)}
 }
 
 // SortOperationsByLine sorts edit operations by start line in descending order
 // This prevents line number shifts during application
 func SortOperationsByLine(operations []types.EditOperation) []types.EditOperation {
-	sorted := make([]types.EditOperation, len(operations))
-	copy(sorted, operations)
-
-	// Simple bubble sort by start line (descending)
-	for i := 0; i < len(sorted)-1; i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if sorted[i].StartLine < sorted[j].StartLine {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
-	}
-
+	sorted := append([]types.EditOperation(nil), operations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartLine > sorted[j].StartLine
+	})
 	return sorted
 }
 
@@ -365,19 +660,25 @@ func ValidateEditOperations(lines []string, operations []types.EditOperation) er
 			return fmt.Errorf("operation %d: line numbers must be positive", i+1)
 		}
 		if op.StartLine > len(lines) || op.EndLine > len(lines) {
-			return fmt.Errorf("operation %d: line numbers exceed file length (%d lines)", i+1, len(lines))
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("operation %d: line numbers exceed file length (%d lines)", i+1, len(lines)))
 		}
 		if op.StartLine > op.EndLine {
 			return fmt.Errorf("operation %d: start_line (%d) > end_line (%d)", i+1, op.StartLine, op.EndLine)
 		}
 	}
 
-	// Check for overlapping operations
-	for i := 0; i < len(operations); i++ {
-		for j := i + 1; j < len(operations); j++ {
-			if OperationsOverlap(operations[i], operations[j]) {
-				return fmt.Errorf("operations %d and %d overlap", i+1, j+1)
-			}
+	// Sorted by start line, the operations overlap only if two neighbours do
+	order := make([]int, len(operations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return operations[order[i]].StartLine < operations[order[j]].StartLine
+	})
+	for k := 1; k < len(order); k++ {
+		i, j := order[k-1], order[k]
+		if OperationsOverlap(operations[i], operations[j]) {
+			return fmt.Errorf("operations %d and %d overlap", min(i, j)+1, max(i, j)+1)
 		}
 	}
 
@@ -386,23 +687,70 @@ func ValidateEditOperations(lines []string, operations []types.EditOperation) er
 
 // File utilities
 
-// CreateBackup creates a timestamped backup of a file
+// CreateBackup creates a timestamped backup of a file and applies the
+// configured retention policy to its older backups
 func CreateBackup(filePath string) (string, error) {
-	backupPath := filePath + ".backup." + fmt.Sprintf("%d", time.Now().Unix())
+	backupPath, err := backupPathFor(filePath, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup: %v", err)
+	}
 
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read original file: %v", err)
 	}
 
-	err = os.WriteFile(backupPath, content, 0644)
+	if err := EnsureDir(filepath.Dir(backupPath)); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	err = WriteFileAtomic(backupPath, content, 0644)
 	if err != nil {
 		return "", fmt.Errorf("failed to create backup: %v", err)
 	}
 
+	rememberBackupDirectory(filepath.Dir(backupPath))
+	applyBackupRetention(filePath)
+
 	return backupPath, nil
 }
 
+// PlanDelete counts the files, directories and bytes that deleting path
+// would remove, failing where the delete itself would fail
+func PlanDelete(path string, recursive bool) (int, int, int64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if !info.IsDir() {
+		return 1, 0, info.Size(), nil
+	}
+
+	var files, dirs int
+	var bytes int64
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs++
+			return nil
+		}
+		files++
+		if info, err := d.Info(); err == nil {
+			bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if !recursive && (files > 0 || dirs > 1) {
+		return 0, 0, 0, fmt.Errorf("directory %s is not empty; set recursive=true to delete it", path)
+	}
+	return files, dirs, bytes, nil
+}
+
 // EnsureDir creates a directory if it doesn't exist
 func EnsureDir(dirPath string) error {
 	return os.MkdirAll(dirPath, 0755)
@@ -442,6 +790,50 @@ func GetFileSize(filePath string) (int64, error) {
 	return info.Size(), nil
 }
 
+// CalculateFileStats streams a file and counts lines, words and bytes.
+// If headLines is positive, the first headLines lines are returned as well.
+func CalculateFileStats(filePath string, headLines int) (*types.FileStats, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	stats := &types.FileStats{Path: filePath}
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			stats.Bytes += int64(len(line))
+			stats.Lines++
+
+			line = strings.TrimRight(line, "\r\n")
+			if strings.TrimSpace(line) == "" {
+				stats.BlankLines++
+			}
+			stats.Words += len(strings.Fields(line))
+
+			if length := utf8.RuneCountInString(line); length > stats.LongestLine {
+				stats.LongestLine = length
+				stats.LongestLineNum = stats.Lines
+			}
+
+			if headLines > 0 && len(stats.Head) < headLines {
+				stats.Head = append(stats.Head, line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	return stats, nil
+}
+
 // Web utilities
 
 // BuildUserAgent creates a user agent string
@@ -487,6 +879,10 @@ func ValidateURL(url string) error {
 		return fmt.Errorf("URL must start with http:// or https://")
 	}
 
+	if !IsURLAllowed(url) {
+		return WithErrorCode(ErrorURLNotAllowed, fmt.Errorf("URL matches no allowedURLPatterns entry or temporary grant: %s", url))
+	}
+
 	return nil
 }
 
@@ -566,6 +962,16 @@ func SplitLines(content string) []string {
 	return strings.Split(content, "\n")
 }
 
+// SortedKeys returns the keys of a string map in sorted order
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // JoinLines joins lines with the appropriate line ending for the platform
 func JoinLines(lines []string) string {
 	return strings.Join(lines, "\n")
@@ -615,7 +1021,7 @@ func FormatError(err error, operation string) string {
 	if err == nil {
 		return ""
 	}
-	return fmt.Sprintf("Failed to %s: %v", operation, err)
+	return Localize("Failed to "+operation+": %v", err)
 }
 
 // NewErrorResult creates a standardized error result
@@ -658,10 +1064,10 @@ func ValidateLineRange(startLine, endLine, totalLines int) error {
 		return fmt.Errorf("end line must be positive, got %d", endLine)
 	}
 	if startLine > totalLines {
-		return fmt.Errorf("start line %d exceeds file length (%d lines)", startLine, totalLines)
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("start line %d exceeds file length (%d lines)", startLine, totalLines))
 	}
 	if endLine > totalLines {
-		return fmt.Errorf("end line %d exceeds file length (%d lines)", endLine, totalLines)
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("end line %d exceeds file length (%d lines)", endLine, totalLines))
 	}
 	if startLine > endLine {
 		return fmt.Errorf("start line (%d) cannot be greater than end line (%d)", startLine, endLine)
@@ -669,10 +1075,13 @@ func ValidateLineRange(startLine, endLine, totalLines int) error {
 	return nil
 }
 
+// CalculateFileChecksum returns the hex-encoded SHA-256 digest of a file
 func CalculateFileChecksum(filePath string) (string, error) {
-	// Placeholder for checksum calculation logic
-	// This could be implemented using a hash function like SHA-256
-	return "", fmt.Errorf("checksum calculation not implemented")
+	digests, err := HashFile(filePath, HashSHA256)
+	if err != nil {
+		return "", err
+	}
+	return digests[HashSHA256], nil
 }
 
 func CopyFile(src, dst string) error {
@@ -682,13 +1091,10 @@ func CopyFile(src, dst string) error {
 	}
 	defer input.Close()
 
-	output, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer output.Close()
-
-	_, err = io.Copy(output, input)
+	err = WriteFileAtomicFunc(dst, 0644, func(w io.Writer) error {
+		_, err := io.Copy(w, input)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to copy file contents: %w", err)
 	}
@@ -709,38 +1115,179 @@ func FormatFileInfo(name string, info os.FileInfo) string {
 		name)
 }
 
-func SearchInFile(filePath, pattern string, caseSensitive bool, contextLines int) ([]string, error) {
-	var results []string
+// NewDirectoryEntry builds a directory listing entry from file info
+func NewDirectoryEntry(name string, info os.FileInfo) types.DirectoryEntry {
+	entryType := "file"
+	switch {
+	case info.IsDir():
+		entryType = "directory"
+	case info.Mode()&os.ModeSymlink != 0:
+		entryType = "symlink"
+	case !info.Mode().IsRegular():
+		entryType = "other"
+	}
+
+	return types.DirectoryEntry{
+		Name:        name,
+		Type:        entryType,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Permissions: info.Mode().String(),
+	}
+}
+
+// FormatDirectoryEntry formats a directory entry in the same layout as FormatFileInfo
+func FormatDirectoryEntry(entry types.DirectoryEntry) string {
+	sizeStr := FormatBytes(entry.Size)
+	if entry.Type == "directory" {
+		sizeStr = "<DIR>"
+	}
 
+	return fmt.Sprintf("%-10s %10s %s %s\n",
+		entry.Permissions,
+		sizeStr,
+		entry.ModTime.Format("2006-01-02 15:04:05"),
+		entry.Name)
+}
+
+// SortDirectoryEntries sorts entries in place by name, size or modification time
+func SortDirectoryEntries(entries []types.DirectoryEntry, sortBy string, descending bool) error {
+	var less func(a, b types.DirectoryEntry) bool
+
+	switch sortBy {
+	case "", "name":
+		less = func(a, b types.DirectoryEntry) bool { return a.Name < b.Name }
+	case "size":
+		less = func(a, b types.DirectoryEntry) bool { return a.Size < b.Size }
+	case "modified", "mtime":
+		less = func(a, b types.DirectoryEntry) bool { return a.ModTime.Before(b.ModTime) }
+	default:
+		return fmt.Errorf("invalid sort_by value: %s (expected name, size or modified)", sortBy)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if descending {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+	return nil
+}
+
+// CompileSearchPattern builds the matcher used by content searches. Plain
+// patterns are matched literally.
+func CompileSearchPattern(pattern string, useRegex, caseSensitive bool) (*regexp.Regexp, error) {
+	if !useRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// FindMatchesInFile returns every match of re in a file with its 1-based
+// line and column. maxMatches <= 0 means unlimited.
+func FindMatchesInFile(filePath string, re *regexp.Regexp, contextLines, maxMatches int) ([]types.SearchMatch, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	lines := strings.Split(string(content), "\n")
+	var matches []types.SearchMatch
+	lines := SplitLines(string(content))
 	for i, line := range lines {
-		if (caseSensitive && strings.Contains(line, pattern)) || (!caseSensitive && strings.Contains(strings.ToLower(line), strings.ToLower(pattern))) {
-			start := i - contextLines
-			if start < 0 {
-				start = 0
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue // Skip empty matches
+			}
+			match := types.SearchMatch{
+				File:     filePath,
+				Line:     i + 1,
+				Column:   utf8.RuneCountInString(line[:loc[0]]) + 1,
+				Match:    line[loc[0]:loc[1]],
+				LineText: line,
 			}
-			end := i + contextLines + 1
-			if end > len(lines) {
-				end = len(lines)
+			if contextLines > 0 {
+				start := i - contextLines
+				if start < 0 {
+					start = 0
+				}
+				end := i + contextLines + 1
+				if end > len(lines) {
+					end = len(lines)
+				}
+				match.ContextBefore = lines[start:i]
+				match.ContextAfter = lines[i+1 : end]
+			}
+			matches = append(matches, match)
+			if maxMatches > 0 && len(matches) >= maxMatches {
+				return matches, nil
 			}
-			results = append(results, fmt.Sprintf("Context for line %d:\n%s\n", i+1, strings.Join(lines[start:end], "\n")))
 		}
 	}
 
-	return results, nil
+	return matches, nil
 }
 
-func CreateTempScript(scriptContent string, dir string) (string, error) {
-	if dir == "" {
-		dir = os.TempDir()
+// FormatSearchMatches renders matches grouped by file in a grep-like
+// layout. Overlapping context is merged and gaps are marked with "--".
+func FormatSearchMatches(matches []types.SearchMatch) string {
+	var sb strings.Builder
+
+	for start := 0; start < len(matches); {
+		file := matches[start].File
+		end := start
+		for end < len(matches) && matches[end].File == file {
+			end++
+		}
+
+		lines := make(map[int]string)
+		columns := make(map[int]int)
+		for _, m := range matches[start:end] {
+			for j, line := range m.ContextBefore {
+				lines[m.Line-len(m.ContextBefore)+j] = line
+			}
+			lines[m.Line] = m.LineText
+			for j, line := range m.ContextAfter {
+				lines[m.Line+j+1] = line
+			}
+			if _, ok := columns[m.Line]; !ok {
+				columns[m.Line] = m.Column
+			}
+		}
+
+		numbers := make([]int, 0, len(lines))
+		for n := range lines {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+
+		if start > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("=== %s ===\n", file))
+		for i, n := range numbers {
+			if i > 0 && n != numbers[i-1]+1 {
+				sb.WriteString("--\n")
+			}
+			if column, ok := columns[n]; ok {
+				sb.WriteString(fmt.Sprintf("%d:%d: %s\n", n, column, lines[n]))
+			} else {
+				sb.WriteString(fmt.Sprintf("%d-  %s\n", n, lines[n]))
+			}
+		}
+
+		start = end
 	}
 
-	tempFile, err := os.CreateTemp(dir, "script-*.sh")
+	return sb.String()
+}
+
+// CreateTempScript writes a script for shell to a temporary file with the
+// extension the shell expects
+func CreateTempScript(scriptContent string, shell string) (string, error) {
+	tempFile, err := os.CreateTemp("", "script-*"+LookupShell(shell).ScriptExtension)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary script file: %w", err)
 	}
@@ -781,49 +1328,6 @@ func ParseInt64(s string) (int64, error) {
 	return strconv.ParseInt(s, 10, 64)
 }
 
-func ConvertImageFormat(filePath, targetFormat string) (string, error) {
-	// Open the source image
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open image: %v", err)
-	}
-	defer file.Close()
-
-	// Decode the image
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %v", err)
-	}
-
-	// Create destination file path with new extension
-	ext := "." + strings.ToLower(targetFormat)
-	baseFilePath := strings.TrimSuffix(filePath, filepath.Ext(filePath))
-	newPath := baseFilePath + ext
-
-	// Create the destination file
-	destFile, err := os.Create(newPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create destination file: %v", err)
-	}
-	defer destFile.Close()
-
-	// Encode the image to the desired format
-	switch strings.ToLower(targetFormat) {
-	case "jpg", "jpeg":
-		err = jpeg.Encode(destFile, img, &jpeg.Options{Quality: 90})
-	case "png":
-		err = png.Encode(destFile, img)
-	default:
-		return "", fmt.Errorf("unsupported format: %s", targetFormat)
-	}
-
-	if err != nil {
-		return "", fmt.Errorf("failed to encode image: %v", err)
-	}
-
-	return newPath, nil
-}
-
 // ApplyJSONPath extracts data from a JSON object using a JSONPath expression
 func ApplyJSONPath(data interface{}, jsonPath string) (interface{}, error) {
 	if jsonPath == "" {
@@ -862,7 +1366,7 @@ func ApplyJSONPath(data interface{}, jsonPath string) (interface{}, error) {
 					}
 					return nil, fmt.Errorf("not an array: %s", key)
 				}
-				return nil, fmt.Errorf("key not found: %s", key)
+				return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("key not found: %s", key))
 			}
 			return nil, fmt.Errorf("not a map at path segment: %s", part)
 		}
@@ -872,7 +1376,7 @@ func ApplyJSONPath(data interface{}, jsonPath string) (interface{}, error) {
 			if val, exists := m[part]; exists {
 				current = val
 			} else {
-				return nil, fmt.Errorf("key not found: %s", part)
+				return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("key not found: %s", part))
 			}
 		} else {
 			return nil, fmt.Errorf("not a map at path segment: %s", part)
@@ -905,8 +1409,9 @@ func FetchURLsBatch(ctx context.Context, urlConfigs []types.HTTPRequestConfig, m
 		// Validate URL
 		if err := ValidateURL(config.URL); err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Invalid URL: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Invalid URL: %v", err),
+				ErrorCode: ErrorCodeFor("Invalid URL: %v", err),
 				Metadata: map[string]interface{}{
 					"url": config.URL,
 				},
@@ -923,7 +1428,7 @@ func FetchURLsBatch(ctx context.Context, urlConfigs []types.HTTPRequestConfig, m
 		}
 
 		// Prepare HTTP client
-		timeout := 30 * time.Second
+		timeout := DefaultTimeout(TimeoutFetch)
 		if config.Timeout > 0 {
 			timeout = time.Duration(config.Timeout) * time.Second
 		}
@@ -943,8 +1448,9 @@ func FetchURLsBatch(ctx context.Context, urlConfigs []types.HTTPRequestConfig, m
 		req, err := http.NewRequestWithContext(ctx, method, config.URL, bodyReader)
 		if err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to create request: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Failed to create request: %v", err),
+				ErrorCode: ErrorCodeFor("Failed to create request: %v", err),
 				Metadata: map[string]interface{}{
 					"url": config.URL,
 				},
@@ -973,8 +1479,9 @@ func FetchURLsBatch(ctx context.Context, urlConfigs []types.HTTPRequestConfig, m
 
 		if err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Request failed: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Request failed: %v", err),
+				ErrorCode: ErrorCodeFor("Request failed: %v", err),
 				Metadata: map[string]interface{}{
 					"url":      config.URL,
 					"duration": FormatDuration(duration),
@@ -991,8 +1498,9 @@ func FetchURLsBatch(ctx context.Context, urlConfigs []types.HTTPRequestConfig, m
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to read response: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Failed to read response: %v", err),
+				ErrorCode: ErrorCodeFor("Failed to read response: %v", err),
 				Metadata: map[string]interface{}{
 					"url":         config.URL,
 					"status_code": resp.StatusCode,
@@ -1058,8 +1566,9 @@ func CheckURLsStatus(ctx context.Context, urls []string, timeout time.Duration,
 		// Validate URL
 		if err := ValidateURL(url); err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Invalid URL: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Invalid URL: %v", err),
+				ErrorCode: ErrorCodeFor("Invalid URL: %v", err),
 				Metadata: map[string]interface{}{
 					"url": url,
 				},
@@ -1071,8 +1580,9 @@ func CheckURLsStatus(ctx context.Context, urls []string, timeout time.Duration,
 		req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 		if err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to create request: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Failed to create request: %v", err),
+				ErrorCode: ErrorCodeFor("Failed to create request: %v", err),
 				Metadata: map[string]interface{}{
 					"url": url,
 				},
@@ -1089,8 +1599,9 @@ func CheckURLsStatus(ctx context.Context, urls []string, timeout time.Duration,
 
 		if err != nil {
 			results[i] = types.OperationResult{
-				Success: false,
-				Error:   fmt.Sprintf("Request failed: %v", err),
+				Success:   false,
+				Error:     fmt.Sprintf("Request failed: %v", err),
+				ErrorCode: ErrorCodeFor("Request failed: %v", err),
 				Metadata: map[string]interface{}{
 					"url":      url,
 					"duration": FormatDuration(duration),
@@ -1129,138 +1640,243 @@ func CheckURLsStatus(ctx context.Context, urls []string, timeout time.Duration,
 	return results, nil
 }
 
-// ReplaceText replaces text in a string with various options
-func ReplaceText(content, find, replace string, regex, caseSensitive, wholeWord bool, maxReplacements int) (string, int, error) {
+// ReplaceOptions controls how ReplaceText matches text
+type ReplaceOptions struct {
+	Regex           bool
+	CaseSensitive   bool
+	WholeWord       bool
+	Multiline       bool // ^ and $ match at line boundaries (regex only)
+	DotAll          bool // . also matches newlines (regex only)
+	MaxReplacements int  // <= 0 means unlimited
+}
+
+// ReplaceText replaces occurrences of find in content and returns the new
+// content with the number of replacements made. In regex mode the
+// replacement may reference capture groups as $1 or ${name}; otherwise it is
+// inserted literally.
+func ReplaceText(content, find, replace string, opts ReplaceOptions) (string, int, error) {
 	if find == "" {
 		return content, 0, fmt.Errorf("find pattern cannot be empty")
 	}
 
-	// For simple string replacement
-	if !regex && !wholeWord && caseSensitive {
-		count := strings.Count(content, find)
-		if maxReplacements > 0 && count > maxReplacements {
-			count = maxReplacements
-			// This is a simplified implementation
-			// In a real implementation, you would need to limit the number of replacements
-		}
-		result := strings.Replace(content, find, replace, maxReplacements)
-		return result, count, nil
+	pattern := find
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(find)
+	}
+	flags := ""
+	if !opts.CaseSensitive {
+		flags += "i"
+	}
+	if opts.Regex && opts.Multiline {
+		flags += "m"
+	}
+	if opts.Regex && opts.DotAll {
+		flags += "s"
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
 	}
 
-	// For case-insensitive string replacement
-	if !regex && !wholeWord && !caseSensitive {
-		findLower := strings.ToLower(find)
-		count := 0
-		result := ""
-		remaining := content
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return content, 0, fmt.Errorf("invalid regular expression: %w", err)
+	}
 
-		for {
-			lowerRemaining := strings.ToLower(remaining)
-			index := strings.Index(lowerRemaining, findLower)
-			if index == -1 || (maxReplacements > 0 && count >= maxReplacements) {
-				result += remaining
-				break
-			}
+	var result strings.Builder
+	last, count := 0, 0
+	for _, match := range re.FindAllStringSubmatchIndex(content, -1) {
+		if opts.MaxReplacements > 0 && count >= opts.MaxReplacements {
+			break
+		}
+		if opts.WholeWord && !isWholeWordMatch(content, match[0], match[1]) {
+			continue
+		}
 
-			result += remaining[:index] + replace
-			remaining = remaining[index+len(find):]
-			count++
+		result.WriteString(content[last:match[0]])
+		if opts.Regex {
+			result.Write(re.ExpandString(nil, replace, content, match))
+		} else {
+			result.WriteString(replace)
 		}
+		last = match[1]
+		count++
+	}
 
-		return result, count, nil
+	if count == 0 {
+		return content, 0, nil
+	}
+	result.WriteString(content[last:])
+	return result.String(), count, nil
+}
+
+// isWholeWordMatch reports whether content[start:end] is not part of a
+// longer word. Word characters are Unicode letters, digits and underscore.
+func isWholeWordMatch(content string, start, end int) bool {
+	if start == end {
+		return false
 	}
+	first, _ := utf8.DecodeRuneInString(content[start:end])
+	if isWordRune(first) && start > 0 {
+		if prev, _ := utf8.DecodeLastRuneInString(content[:start]); isWordRune(prev) {
+			return false
+		}
+	}
+	lastRune, _ := utf8.DecodeLastRuneInString(content[start:end])
+	if isWordRune(lastRune) && end < len(content) {
+		if next, _ := utf8.DecodeRuneInString(content[end:]); isWordRune(next) {
+			return false
+		}
+	}
+	return true
+}
 
-	// For more complex replacements (regex, whole word)
-	// In a real implementation, you would use the regexp package
-	return content, 0, fmt.Errorf("regex and whole word replacements not implemented in this simplified version")
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
 }
 
-// ApplyTextInsertions applies multiple text insertions to a string
-func ApplyTextInsertions(content string, insertions []types.TextInsertion, adjustLineNumbers bool) (string, error) {
-	if len(insertions) == 0 {
-		return content, nil
+// ReplaceExact replaces oldString in content with newString. Unless
+// replaceAll is set, oldString must occur exactly once. When content uses
+// CRLF line endings and oldString uses LF, both strings are matched with
+// CRLF instead.
+func ReplaceExact(content, oldString, newString string, replaceAll bool) (string, int, error) {
+	if oldString == "" {
+		return "", 0, fmt.Errorf("old_string cannot be empty")
 	}
 
-	lines := SplitLines(content)
+	count := strings.Count(content, oldString)
+	if count == 0 && strings.Contains(content, "\r\n") && !strings.Contains(oldString, "\r\n") {
+		crlfOld := strings.ReplaceAll(oldString, "\n", "\r\n")
+		if n := strings.Count(content, crlfOld); n > 0 {
+			oldString, newString, count = crlfOld, strings.ReplaceAll(newString, "\n", "\r\n"), n
+		}
+	}
 
-	// Sort insertions by line number in descending order to avoid line number shifts
-	// Simple bubble sort
-	for i := 0; i < len(insertions)-1; i++ {
-		for j := i + 1; j < len(insertions); j++ {
-			if insertions[i].Line < insertions[j].Line {
-				insertions[i], insertions[j] = insertions[j], insertions[i]
+	switch {
+	case count == 0:
+		return "", 0, WithErrorCode(ErrorNotFound, fmt.Errorf("old_string not found; it must match the file exactly, including whitespace and indentation"))
+	case count > 1 && !replaceAll:
+		var lines []string
+		offset := 0
+		for {
+			idx := strings.Index(content[offset:], oldString)
+			if idx == -1 {
+				break
 			}
+			offset += idx
+			lines = append(lines, strconv.Itoa(strings.Count(content[:offset], "\n")+1))
+			offset += len(oldString)
 		}
+		return "", 0, fmt.Errorf("old_string is ambiguous: found %d occurrences (at lines %s); include more surrounding context or set replace_all", count, strings.Join(lines, ", "))
 	}
 
-	// Apply insertions
-	for _, insertion := range insertions {
-		line := insertion.Line
+	return strings.ReplaceAll(content, oldString, newString), count, nil
+}
 
-		// Validate line number
-		if line < 1 || line > len(lines)+1 {
-			return content, fmt.Errorf("invalid line number: %d (file has %d lines)", line, len(lines))
+// ResolveInsertionAnchors sets the line of every anchored insertion to the
+// selected matching line. Insertions whose anchor is not found are dropped
+// when IfNotFound is "skip".
+func ResolveInsertionAnchors(lines []string, insertions []types.TextInsertion) ([]types.TextInsertion, error) {
+	resolved := make([]types.TextInsertion, 0, len(insertions))
+	for i, insertion := range insertions {
+		if insertion.Anchor == "" {
+			resolved = append(resolved, insertion)
+			continue
 		}
 
-		// Insert content
-		insertContent := insertion.Content
-		insertLines := SplitLines(insertContent)
-
-		if insertion.Before {
-			// Insert before the line
-			newLines := make([]string, 0, len(lines)+len(insertLines))
-			newLines = append(newLines, lines[:line-1]...)
-			newLines = append(newLines, insertLines...)
-			newLines = append(newLines, lines[line-1:]...)
-			lines = newLines
+		var matches []int
+		if insertion.AnchorRegex {
+			re, err := regexp.Compile(insertion.Anchor)
+			if err != nil {
+				return nil, fmt.Errorf("insertion %d: invalid anchor pattern: %v", i+1, err)
+			}
+			for n, line := range lines {
+				if re.MatchString(line) {
+					matches = append(matches, n+1)
+				}
+			}
 		} else {
-			// Insert after the line
-			newLines := make([]string, 0, len(lines)+len(insertLines))
-			newLines = append(newLines, lines[:line]...)
-			newLines = append(newLines, insertLines...)
-			newLines = append(newLines, lines[line:]...)
-			lines = newLines
+			for n, line := range lines {
+				if strings.Contains(line, insertion.Anchor) {
+					matches = append(matches, n+1)
+				}
+			}
+		}
+
+		line := 0
+		switch occurrence := insertion.Occurrence; occurrence {
+		case "", "first":
+			if len(matches) > 0 {
+				line = matches[0]
+			}
+		case "last":
+			if len(matches) > 0 {
+				line = matches[len(matches)-1]
+			}
+		default:
+			nth, err := strconv.Atoi(occurrence)
+			if err != nil || nth < 1 {
+				return nil, fmt.Errorf("insertion %d: invalid occurrence %q (use first, last or a positive number)", i+1, occurrence)
+			}
+			if nth <= len(matches) {
+				line = matches[nth-1]
+			}
 		}
-	}
 
-	return JoinLines(lines), nil
+		if line == 0 {
+			switch insertion.IfNotFound {
+			case "", "error":
+				return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("insertion %d: anchor %q not found (%d match(es))", i+1, insertion.Anchor, len(matches)))
+			case "skip":
+				continue
+			case "start":
+				line, insertion.Before = 1, true
+			case "end":
+				// Stay in front of the empty element left by a trailing newline
+				line, insertion.Before = len(lines), lines[len(lines)-1] == ""
+			default:
+				return nil, fmt.Errorf("insertion %d: invalid if_not_found %q (use error, skip, start or end)", i+1, insertion.IfNotFound)
+			}
+		}
+
+		insertion.Line = line
+		resolved = append(resolved, insertion)
+	}
+	return resolved, nil
 }
 
-// FormatCodeFile formats a code file using the specified formatter
-func FormatCodeFile(filePath, formatter, configFile string) error {
-	if filePath == "" {
-		return fmt.Errorf("file path cannot be empty")
+// ApplyTextInsertions applies multiple text insertions to a string
+func ApplyTextInsertions(content string, insertions []types.TextInsertion, adjustLineNumbers bool) (string, error) {
+	if len(insertions) == 0 {
+		return content, nil
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("file does not exist: %s", filePath)
-	}
-
-	// Determine formatter based on file extension if not specified
-	if formatter == "" {
-		ext := GetFileExtension(filePath)
-		switch ext {
-		case ".go":
-			formatter = "gofmt"
-		case ".py":
-			formatter = "black"
-		case ".js", ".ts", ".jsx", ".tsx", ".json":
-			formatter = "prettier"
-		case ".java":
-			formatter = "google-java-format"
-		case ".c", ".cpp", ".h", ".hpp":
-			formatter = "clang-format"
-		default:
-			return fmt.Errorf("no default formatter for file type: %s", ext)
+	lines := SplitLines(content)
+
+	// Anchors are resolved against the original content, like line numbers
+	insertions, err := ResolveInsertionAnchors(lines, insertions)
+	if err != nil {
+		return content, err
+	}
+
+	splices := make([]LineSplice, 0, len(insertions))
+	for _, insertion := range insertions {
+		line, at := insertion.Line, insertion.Line
+		if insertion.Before {
+			at = line - 1
+		}
+		if line < 1 || at > len(lines) {
+			return content, fmt.Errorf("invalid line number: %d (file has %d lines)", line, len(lines))
 		}
+		splices = append(splices, LineSplice{Start: at, End: at, Lines: SplitLines(insertion.Content)})
 	}
 
-	// This is a placeholder implementation
-	// In a real implementation, you would execute the formatter command
-	return fmt.Errorf("code formatting not implemented in this simplified version")
+	return JoinLines(ApplyLineSplices(lines, splices)), nil
 }
 
+// IsPathAllowed checks if a path is within an allowed directory or
+// workspace and not denied; see matchAllowedDirectory for how entries are
+// compared. Both the path and the path its symlinks lead to must pass, so
+// a link in an allowed directory cannot reach outside it.
 func IsPathAllowed(path string) bool {
 	config := Get()
 
@@ -1268,19 +1884,35 @@ func IsPathAllowed(path string) bool {
 	if err != nil {
 		return false
 	}
+	resolved, ok := resolvePath(absPath)
+	if !ok {
+		return false
+	}
+	if _, denied := deniedBy(absPath, config); denied {
+		return false
+	}
+	if _, denied := deniedBy(resolved, config); denied {
+		return false
+	}
+	if isServerFile(absPath) || isServerFile(resolved) {
+		return false
+	}
 
-	for _, allowedDir := range config.AllowedDirectories {
-		allowedAbs, err := filepath.Abs(allowedDir)
-		if err != nil {
-			continue
-		}
+	allowedDirs := append([]string{}, config.AllowedDirectories...)
+	allowedDirs = append(allowedDirs, workspaceDirectories()...)
+	allowedDirs = append(allowedDirs, grantedValues(GrantDirectory)...)
 
-		if IsSubPath(absPath, allowedAbs) {
-			return true
+	lexicalAllowed, resolvedAllowed := false, false
+	for _, allowedDir := range allowedDirs {
+		if matchAllowedDirectory(absPath, allowedDir) {
+			lexicalAllowed = true
+		}
+		if matchAllowedDirectory(resolved, allowedDir) || matchAllowedDirectory(resolved, resolveAllowedDirectory(allowedDir)) {
+			resolvedAllowed = true
 		}
 	}
 
-	return false
+	return lexicalAllowed && resolvedAllowed
 }
 
 func IsSubPath(path, parent string) bool {
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRememberedFiles bounds how many read versions are kept for drift
+	// diffs; the least recently stored are forgotten first
+	maxRememberedFiles = 200
+	// Files larger than this are not remembered, so drift on them is
+	// reported without a diff
+	maxRememberedBytes = 1024 * 1024
+)
+
+var (
+	// Last content the session read or wrote for each file, keyed by
+	// absolute path, used to show what changed on disk behind its back
+	rememberedContent = make(map[string][]byte)
+	rememberedOrder   []string
+	rememberedMutex   sync.Mutex
+)
+
+// RememberContent records content as the version of path the session last
+// saw. A nil content forgets the file.
+func RememberContent(path string, content []byte) {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+
+	rememberedMutex.Lock()
+	defer rememberedMutex.Unlock()
+
+	for i, remembered := range rememberedOrder {
+		if remembered == path {
+			rememberedOrder = append(rememberedOrder[:i], rememberedOrder[i+1:]...)
+			break
+		}
+	}
+	delete(rememberedContent, path)
+
+	if content == nil || len(content) > maxRememberedBytes {
+		return
+	}
+	rememberedContent[path] = append([]byte(nil), content...)
+	rememberedOrder = append(rememberedOrder, path)
+	if len(rememberedOrder) > maxRememberedFiles {
+		delete(rememberedContent, rememberedOrder[0])
+		rememberedOrder = rememberedOrder[1:]
+	}
+}
+
+// CheckExpectedState refuses a write when path no longer matches the
+// SHA-256 checksum or modification time the caller saw when it read the
+// file. Empty expectations are not checked. expectedMtime is RFC3339; a
+// value without fractional seconds is compared to the second. When the
+// session remembers an earlier version of the file, the error includes a
+// diff of the drift.
+func CheckExpectedState(path, expectedChecksum, expectedMtime string) error {
+	if expectedChecksum == "" && expectedMtime == "" {
+		return nil
+	}
+
+	var wantTime time.Time
+	if expectedMtime != "" {
+		var err error
+		if wantTime, err = time.Parse(time.RFC3339Nano, expectedMtime); err != nil {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("invalid expected_mtime %q: use RFC3339, e.g. 2006-01-02T15:04:05Z", expectedMtime))
+		}
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return WithErrorCode(ErrorConflict, fmt.Errorf("file %s no longer exists; it was removed since it was read", path))
+	}
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	var current []byte
+	if expectedChecksum != "" {
+		if current, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		if actual := contentHash(current); !strings.EqualFold(actual, strings.TrimSpace(expectedChecksum)) {
+			problems = append(problems, fmt.Sprintf("sha256 is %s, expected %s", actual, expectedChecksum))
+		}
+	}
+	if expectedMtime != "" {
+		actualTime := info.ModTime()
+		if wantTime.Nanosecond() == 0 {
+			actualTime = actualTime.Truncate(time.Second)
+		}
+		if !actualTime.Equal(wantTime) {
+			problems = append(problems, fmt.Sprintf("modified %s, expected %s", info.ModTime().Format(time.RFC3339Nano), expectedMtime))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("file %s changed since it was read (%s); re-read it before writing", path, strings.Join(problems, "; "))
+
+	absPath, _ := filepath.Abs(path)
+	rememberedMutex.Lock()
+	previous, ok := rememberedContent[absPath]
+	rememberedMutex.Unlock()
+	if ok {
+		if current == nil {
+			if current, err = os.ReadFile(path); err != nil {
+				return WithErrorCode(ErrorConflict, errors.New(message))
+			}
+		}
+		if diff := UnifiedDiff(path+" (last read)", path+" (on disk)", string(previous), string(current), 3); diff != "" {
+			message += "\nChanges on disk:\n" + diff
+		}
+	}
+	return WithErrorCode(ErrorConflict, errors.New(message))
+}
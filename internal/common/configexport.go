@@ -0,0 +1,78 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"jarvis/internal/types"
+)
+
+// ExportConfig returns the live configuration as an indented JSON config
+// file. With stripSecrets, stored secret values are replaced with their
+// {{secret:NAME}} references and passwords in URLs are masked, so the
+// export can be shared or versioned.
+func ExportConfig(stripSecrets bool) ([]byte, error) {
+	Initialize()
+	mutex.RLock()
+	data, err := json.Marshal(instance)
+	mutex.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	var settings any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	if stripSecrets {
+		settings = stripConfigSecrets(settings)
+	}
+	return json.MarshalIndent(settings, "", "  ")
+}
+
+// stripConfigSecrets redacts secrets from every string of a decoded
+// configuration
+func stripConfigSecrets(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = stripConfigSecrets(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = stripConfigSecrets(item)
+		}
+	case string:
+		return maskURLPassword(RedactSecrets(v))
+	}
+	return value
+}
+
+// ImportConfig reads a configuration exported with ExportConfig, or any
+// config file, as strictly as the config file is read, and returns the
+// settings importing it changes. Only with apply does it replace the live
+// configuration. Profiles in the import replace the saved ones; when it
+// has none the saved profiles are kept.
+func ImportConfig(data []byte, apply bool) ([]types.ConfigDifference, error) {
+	imported, err := parseConfig(data, "imported config")
+	if err != nil {
+		return nil, err
+	}
+
+	Initialize()
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(imported.Profiles) == 0 {
+		imported.Profiles, imported.ActiveProfile = instance.Profiles, instance.ActiveProfile
+	}
+	differences, err := diffConfigSettings(instance, imported)
+	if err != nil {
+		return nil, err
+	}
+	if apply {
+		instance = imported
+		saveToFile()
+	}
+	return differences, nil
+}
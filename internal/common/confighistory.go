@@ -0,0 +1,159 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// maxConfigVersions is how many configuration versions are kept
+const maxConfigVersions = 50
+
+var configHistoryMutex sync.Mutex
+
+// configHistoryPath returns the file configuration versions are kept in,
+// next to the config file
+func configHistoryPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-config-history.json")
+}
+
+func readConfigHistory() ([]types.ConfigVersion, error) {
+	data, err := os.ReadFile(configHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config history: %v", err)
+	}
+	var versions []types.ConfigVersion
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("invalid config history %s: %v", configHistoryPath(), err)
+	}
+	return versions, nil
+}
+
+// newConfigVersion snapshots settings, signed like the config file when
+// ConfigKeyEnv is set
+func newConfigVersion(version int, settings map[string]any, changes []types.ConfigDifference) (types.ConfigVersion, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return types.ConfigVersion{}, err
+	}
+	snapshot := types.ConfigVersion{Version: version, Time: time.Now(), Settings: data}
+	for _, change := range changes {
+		snapshot.Changed = append(snapshot.Changed, change.Key)
+	}
+	if len(configKey) > 0 {
+		snapshot.Signature = configSignature(data)
+	}
+	return snapshot, nil
+}
+
+// recordConfigVersion adds the settings a change led to to the history.
+// The first change also records the settings before it, so it can be
+// rolled back.
+func recordConfigVersion(previous, settings map[string]any, changes []types.ConfigDifference) {
+	configHistoryMutex.Lock()
+	defer configHistoryMutex.Unlock()
+
+	versions, err := readConfigHistory()
+	if err != nil {
+		log.Printf("Config history error: %v", err)
+		return
+	}
+	if len(versions) == 0 {
+		initial, err := newConfigVersion(1, previous, nil)
+		if err != nil {
+			log.Printf("Config history error: %v", err)
+			return
+		}
+		versions = append(versions, initial)
+	}
+	snapshot, err := newConfigVersion(versions[len(versions)-1].Version+1, settings, changes)
+	if err != nil {
+		log.Printf("Config history error: %v", err)
+		return
+	}
+	versions = append(versions, snapshot)
+	if len(versions) > maxConfigVersions {
+		versions = versions[len(versions)-maxConfigVersions:]
+	}
+
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err == nil {
+		err = WriteFileAtomic(configHistoryPath(), data, 0600)
+	}
+	if err != nil {
+		log.Printf("Config history error: %v", err)
+	}
+}
+
+// ListConfigVersions returns the kept configuration versions, newest
+// first, without their settings
+func ListConfigVersions() ([]types.ConfigVersion, error) {
+	configHistoryMutex.Lock()
+	versions, err := readConfigHistory()
+	configHistoryMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make([]types.ConfigVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		version.Settings, version.Signature = nil, ""
+		listed = append(listed, version)
+	}
+	return listed, nil
+}
+
+// RollbackConfig returns the settings going back to a configuration
+// version changes. Only with apply does it replace the live settings;
+// saved profiles are kept. The rollback is itself recorded as a new
+// version, so it can be undone the same way.
+func RollbackConfig(version int, apply bool) ([]types.ConfigDifference, error) {
+	configHistoryMutex.Lock()
+	versions, err := readConfigHistory()
+	configHistoryMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot *types.ConfigVersion
+	for i := range versions {
+		if versions[i].Version == version {
+			snapshot = &versions[i]
+		}
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("no config version %d; list_config_versions shows the kept versions", version)
+	}
+	if len(configKey) > 0 && snapshot.Signature != configSignature(snapshot.Settings) {
+		return nil, WithErrorCode(ErrorConflict, fmt.Errorf("config version %d was modified outside the server: its signature does not match", version))
+	}
+	restored, err := parseConfig(snapshot.Settings, fmt.Sprintf("config version %d", version))
+	if err != nil {
+		return nil, err
+	}
+
+	Initialize()
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	restored.Profiles, restored.ActiveProfile = instance.Profiles, instance.ActiveProfile
+	differences, err := diffConfigSettings(instance, restored)
+	if err != nil {
+		return nil, err
+	}
+	if apply && len(differences) > 0 {
+		instance = restored
+		saveToFile()
+	}
+	return differences, nil
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"log"
+	"sync"
+
+	"jarvis/internal/types"
+)
+
+// ToolAvailabilitySettings are the settings that change which tools a
+// client may call, and with it the tool list it is shown
+var ToolAvailabilitySettings = []string{"roles", "clientRoles", "defaultRole", "toolCategories"}
+
+var (
+	configListenersMutex sync.Mutex
+	configListeners      []func(changes []types.ConfigDifference)
+	// notifiedSettings are the settings listeners were last told about
+	notifiedSettings map[string]any
+	// pendingConfigChanges are the changes waiting to be passed to the
+	// listeners, in the order they were made
+	pendingConfigChanges [][]types.ConfigDifference
+	configChangeSignal   = make(chan struct{}, 1)
+	startConfigListeners sync.Once
+)
+
+// OnConfigChange registers a function called with the settings that
+// changed each time the configuration changes, whether through a tool or
+// a reload of the config file. Listeners are called one change at a time
+// from a single goroutine, in the order the changes were made.
+func OnConfigChange(listener func(changes []types.ConfigDifference)) {
+	Initialize()
+	configListenersMutex.Lock()
+	configListeners = append(configListeners, listener)
+	configListenersMutex.Unlock()
+
+	startConfigListeners.Do(func() {
+		go dispatchConfigChanges()
+	})
+}
+
+// notifyConfigChange queues the settings instance changed since listeners
+// were last told, when it changed any. The caller must hold mutex.
+func notifyConfigChange() {
+	settings, err := configSettings(instance)
+	if err != nil {
+		log.Printf("Config change notification error: %v", err)
+		return
+	}
+
+	configListenersMutex.Lock()
+	defer configListenersMutex.Unlock()
+	previous := notifiedSettings
+	notifiedSettings = settings
+	if previous == nil {
+		return
+	}
+	changes := diffSettings(previous, settings)
+	if len(changes) == 0 {
+		return
+	}
+	recordConfigVersion(previous, settings, changes)
+
+	pendingConfigChanges = append(pendingConfigChanges, changes)
+	select {
+	case configChangeSignal <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchConfigChanges passes queued changes to the listeners
+func dispatchConfigChanges() {
+	for range configChangeSignal {
+		configListenersMutex.Lock()
+		pending := pendingConfigChanges
+		pendingConfigChanges = nil
+		listeners := append([]func(changes []types.ConfigDifference){}, configListeners...)
+		configListenersMutex.Unlock()
+
+		for _, changes := range pending {
+			for _, listener := range listeners {
+				listener(changes)
+			}
+		}
+	}
+}
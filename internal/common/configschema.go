@@ -0,0 +1,284 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// Value types of configuration keys
+const (
+	ConfigTypeString     = "string"
+	ConfigTypeBool       = "bool"
+	ConfigTypeInt        = "int"
+	ConfigTypeStringList = "string_list"
+	ConfigTypeObject     = "object"
+)
+
+func configBound(n int64) *int64 {
+	return &n
+}
+
+// ConfigSchema describes every configuration key, in the order of
+// types.ServerConfig
+var ConfigSchema = []types.ConfigField{
+	{Key: "blockedCommands", Type: ConfigTypeStringList, Description: "Command patterns that are refused"},
+	{Key: "defaultShell", Type: ConfigTypeString, Description: "Shell commands run with when a tool does not name one"},
+	{Key: "allowedDirectories", Type: ConfigTypeStringList, Path: true, Description: "Directories file and command tools may access; entries may start with ~ and use glob patterns such as ~/projects/*"},
+	{Key: "fileReadLineLimit", Type: ConfigTypeInt, Min: configBound(1), Description: "Lines read_file returns per page"},
+	{Key: "fileWriteLineLimit", Type: ConfigTypeInt, Min: configBound(1), Description: "Lines a single write may contain"},
+	{Key: "telemetryEnabled", Type: ConfigTypeBool, Description: "Collect per-tool call counts, error rates and latencies for get_metrics"},
+	{Key: "metricsListenAddress", Type: ConfigTypeString, Description: "host:port serving metrics in the Prometheus format at /metrics; read at startup, empty disables it"},
+	{Key: "metricsReportPath", Type: ConfigTypeString, Path: true, Description: "File a JSON metrics report is written to periodically; empty disables it"},
+	{Key: "metricsReportIntervalSeconds", Type: ConfigTypeInt, Min: configBound(1), Description: "Seconds between metrics reports"},
+	{Key: "deniedDirectories", Type: ConfigTypeStringList, Path: true, Description: "Directories refused even within allowed directories; entries may start with ~ and use glob patterns"},
+	{Key: "deniedPathPatterns", Type: ConfigTypeStringList, Description: "Glob patterns of path elements refused anywhere, such as .ssh"},
+	{Key: "maxWriteBytes", Type: ConfigTypeInt, Min: configBound(0), Description: "Bytes a single write may contain; 0 is unlimited"},
+	{Key: "maxFilesPerCall", Type: ConfigTypeInt, Min: configBound(0), Description: "Files a single call may write; 0 is unlimited"},
+	{Key: "maxSessionWriteBytes", Type: ConfigTypeInt, Min: configBound(0), Description: "Bytes a client session may write; 0 is unlimited"},
+	{Key: "maxSessionFilesWritten", Type: ConfigTypeInt, Min: configBound(0), Description: "File writes after which a client session's destructive tools are refused until reset_session_quotas; 0 is unlimited"},
+	{Key: "maxSessionBytesDownloaded", Type: ConfigTypeInt, Min: configBound(0), Description: "Bytes downloaded after which a client session's destructive tools are refused until reset_session_quotas; 0 is unlimited"},
+	{Key: "maxSessionProcessesKilled", Type: ConfigTypeInt, Min: configBound(0), Description: "Processes killed after which a client session's destructive tools are refused until reset_session_quotas; 0 is unlimited"},
+	{Key: "maxSessionCommands", Type: ConfigTypeInt, Min: configBound(0), Description: "Commands run after which a client session's destructive tools are refused until reset_session_quotas; 0 is unlimited"},
+	{Key: "largeEditLines", Type: ConfigTypeInt, Min: configBound(0), Description: "Changed lines above which an edit needs confirm_large_edit; 0 disables the check"},
+	{Key: "largeEditPercent", Type: ConfigTypeInt, Min: configBound(0), Max: configBound(100), Description: "Changed percent of a file above which an edit needs confirm_large_edit; 0 disables the check"},
+	{Key: "backupDirectory", Type: ConfigTypeString, Path: true, Description: "Directory backups are kept in; empty keeps them next to the file"},
+	{Key: "backupMaxCount", Type: ConfigTypeInt, Min: configBound(0), Description: "Backups kept per file; 0 keeps every backup"},
+	{Key: "backupMaxAgeDays", Type: ConfigTypeInt, Min: configBound(0), Description: "Days backups are kept; 0 keeps every backup"},
+	{Key: "backupSweepIntervalMinutes", Type: ConfigTypeInt, Min: configBound(1), Description: "Minutes between sweeps pruning backups past backupMaxCount or backupMaxAgeDays"},
+	{Key: "diffStyle", Type: ConfigTypeString, Values: []string{DiffStyleWord, DiffStyleUnified}, Description: "Diff format of edit results"},
+	{Key: "maxOutputBytes", Type: ConfigTypeInt, Min: configBound(1), Description: "Bytes of command output returned to the client"},
+	{Key: "language", Type: ConfigTypeString, Values: Languages, Description: "Language of tool result messages"},
+	{Key: "commandTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(1), Description: "Timeout of commands that do not give one"},
+	{Key: "scriptTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(1), Description: "Timeout of shell scripts that do not give one"},
+	{Key: "fetchTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(1), Description: "Timeout of web requests that do not give one"},
+	{Key: "downloadTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(1), Description: "Timeout of file and image downloads"},
+	{Key: "maxCommandTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(0), Description: "Longest timeout a command may ask for; 0 is no limit"},
+	{Key: "maxScriptTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(0), Description: "Longest timeout a shell script may ask for; 0 is no limit"},
+	{Key: "maxFetchTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(0), Description: "Longest timeout a web request may ask for; 0 is no limit"},
+	{Key: "maxDownloadTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(0), Description: "Longest timeout a download may ask for; 0 is no limit"},
+	{Key: "commandTemplates", Type: ConfigTypeObject, EditedWith: "save_command_template", Description: "Named commands run with run_template"},
+	{Key: "runAsUsers", Type: ConfigTypeStringList, Description: "Users execute_command may run commands as; empty disables run_as_user"},
+	{Key: "sandbox", Type: ConfigTypeString, Values: []string{SandboxNone, SandboxBwrap, SandboxFirejail}, Description: "Sandbox commands run in; empty runs them directly"},
+	{Key: "sandboxAllowNetwork", Type: ConfigTypeBool, Description: "Let sandboxed commands use the network"},
+	{Key: "sshHosts", Type: ConfigTypeObject, EditedWith: "save_ssh_host", Description: "Remote hosts the SSH tools may connect to"},
+	{Key: "packageManagementEnabled", Type: ConfigTypeBool, Description: "Allow installing and removing packages"},
+	{Key: "allowedPackages", Type: ConfigTypeStringList, Description: "Glob patterns of packages that may be installed or removed"},
+	{Key: "approvalRequired", Type: ConfigTypeBool, Description: "Destructive operations wait for approve_operation"},
+	{Key: "approvalPatterns", Type: ConfigTypeStringList, Description: "Command patterns that always wait for approve_operation"},
+	{Key: "approvalTimeoutSeconds", Type: ConfigTypeInt, Min: configBound(1), Description: "Seconds an operation waits for approval"},
+	{Key: "secretEnvPatterns", Type: ConfigTypeStringList, Description: "Glob patterns of environment variable names get_environment masks"},
+	{Key: "auditLogPath", Type: ConfigTypeString, Path: true, Description: "File tool calls are recorded in; empty keeps .jarvis-mcp-audit.jsonl next to the config file"},
+	{Key: "auditLogMaxBytes", Type: ConfigTypeInt, Min: configBound(1024), Description: "Size at which the audit log is rotated"},
+	{Key: "auditLogMaxFiles", Type: ConfigTypeInt, Min: configBound(1), Description: "Rotated audit logs kept"},
+	{Key: "allowedURLPatterns", Type: ConfigTypeStringList, Description: "URL patterns the fetch tools may access, * matching any text; empty allows every http and https URL"},
+	{Key: "toolCategories", Type: ConfigTypeObject, Description: "Named groups of tools rate limits can refer to, as a JSON object of tool name lists"},
+	{Key: "rateLimits", Type: ConfigTypeObject, Description: "Calls per minute allowed for a tool or a tool category, as a JSON object such as {\"delete_file\": 10, \"network\": 30}"},
+	{Key: "roles", Type: ConfigTypeObject, Description: "Named privilege sets, as a JSON object of roles with allowedDirectories, tools, deniedTools and session quotas"},
+	{Key: "clientRoles", Type: ConfigTypeObject, Description: "Role of each MCP client name, * matching any text, as a JSON object such as {\"ci-*\": \"ci\"}"},
+	{Key: "defaultRole", Type: ConfigTypeString, Description: "Role of clients matching no clientRoles entry; empty leaves them unrestricted"},
+	{Key: "policyRules", Type: ConfigTypeObject, Description: "Named rules denying, asking approval for or allowing tool calls by tool or category, target path, URL host and command, as a JSON object such as {\"prod-writes\": {\"effect\": \"approve\", \"tools\": [\"write\"], \"paths\": [\"*/prod/*\"]}}"},
+	{Key: "profiles", Type: ConfigTypeObject, EditedWith: "save_config_profile", Description: "Named sets of settings"},
+	{Key: "activeProfile", Type: ConfigTypeString, EditedWith: "switch_config_profile", Description: "Profile last switched to"},
+}
+
+// LookupConfigField returns the schema of a configuration key
+func LookupConfigField(key string) (types.ConfigField, bool) {
+	for _, field := range ConfigSchema {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return types.ConfigField{}, false
+}
+
+// parseConfigValue converts a set_config_value value to the type of the
+// field. Lists are a JSON array or comma-separated and objects a JSON
+// object; path values have "~" expanded and are made absolute.
+func parseConfigValue(field types.ConfigField, value string) (any, error) {
+	makeAbs := func(path string) (string, error) {
+		if !field.Path || path == "" {
+			return path, nil
+		}
+		expanded, err := ExpandHomeDir(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s path %s: %v", field.Key, path, err)
+		}
+		absPath, err := filepath.Abs(expanded)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s path %s: %v", field.Key, path, err)
+		}
+		return absPath, nil
+	}
+
+	switch field.Type {
+	case ConfigTypeBool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: use true or false", field.Key, value)
+		}
+		return parsed, nil
+	case ConfigTypeInt:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: use a whole number", field.Key, value)
+		}
+		return parsed, nil
+	case ConfigTypeStringList:
+		var entries []string
+		if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "[") {
+			if err := json.Unmarshal([]byte(trimmed), &entries); err != nil {
+				return nil, fmt.Errorf("invalid %s value: %v", field.Key, err)
+			}
+		} else {
+			entries = strings.Split(value, ",")
+		}
+		list := []string{}
+		for _, entry := range entries {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			entry, err := makeAbs(entry)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, entry)
+		}
+		return list, nil
+	case ConfigTypeString:
+		return makeAbs(value)
+	case ConfigTypeObject:
+		var object map[string]any
+		if err := json.Unmarshal([]byte(value), &object); err != nil {
+			return nil, fmt.Errorf("invalid %s value: use a JSON object: %v", field.Key, err)
+		}
+		return object, nil
+	}
+	return nil, fmt.Errorf("%s cannot be set from text", field.Key)
+}
+
+// validateConfigSchema checks the configuration against the bounds and
+// allowed values of the schema
+func validateConfigSchema(config *types.ServerConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var settings map[string]any
+	if err := decoder.Decode(&settings); err != nil {
+		return err
+	}
+
+	for _, field := range ConfigSchema {
+		value, ok := settings[field.Key]
+		if !ok {
+			// Numbers with omitempty are left out when they are zero
+			if field.Type != ConfigTypeInt {
+				continue
+			}
+			value = json.Number("0")
+		}
+		switch field.Type {
+		case ConfigTypeInt:
+			number, err := value.(json.Number).Int64()
+			if err != nil {
+				return fmt.Errorf("%s must be a whole number", field.Key)
+			}
+			if field.Min != nil && number < *field.Min {
+				return fmt.Errorf("%s must be at least %d", field.Key, *field.Min)
+			}
+			if field.Max != nil && number > *field.Max {
+				return fmt.Errorf("%s must be at most %d", field.Key, *field.Max)
+			}
+		case ConfigTypeString:
+			if text, _ := value.(string); len(field.Values) > 0 && !slices.Contains(field.Values, text) {
+				return fmt.Errorf("%s must be one of %s, not %q", field.Key, describeConfigValues(field.Values), text)
+			}
+		}
+	}
+	return nil
+}
+
+// describeConfigValues lists allowed values, showing the empty value as ""
+func describeConfigValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// patchConfig returns a deep copy of config with the settings of the JSON
+// object patch applied. A setting in the patch replaces the old value
+// whole, so an object setting does not keep keys the patch leaves out.
+func patchConfig(config *types.ServerConfig, patch []byte) (*types.ServerConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var settings, patched map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &patched); err != nil {
+		return nil, err
+	}
+	for key, value := range patched {
+		settings[key] = value
+	}
+	if data, err = json.Marshal(settings); err != nil {
+		return nil, err
+	}
+	copied := new(types.ServerConfig)
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// parseConfigFile reads the contents of a config file strictly: unknown
+// keys, values of the wrong type and settings that fail validation are
+// errors, as is a signature mismatch when ConfigKeyEnv is set. Settings
+// the file leaves out keep their defaults.
+func parseConfigFile(data []byte) (*types.ServerConfig, error) {
+	if err := verifyConfigFile(data); err != nil {
+		return nil, err
+	}
+	return parseConfig(data, "config file "+getConfigPath())
+}
+
+// parseConfig reads a configuration as parseConfigFile does, naming it
+// source in errors
+func parseConfig(data []byte, source string) (*types.ServerConfig, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var fileConfig types.ServerConfig
+	if err := decoder.Decode(&fileConfig); err != nil {
+		return nil, fmt.Errorf("%s is not valid: %v", source, err)
+	}
+
+	// Values the file sets are checked as given too, since merging skips
+	// zero and negative numbers instead of reporting them
+	explicit := defaultConfig()
+	if err := json.Unmarshal(data, explicit); err != nil {
+		return nil, fmt.Errorf("%s is not valid: %v", source, err)
+	}
+	if err := validateConfigSchema(explicit); err != nil {
+		return nil, fmt.Errorf("%s is not valid: %w", source, err)
+	}
+
+	config := defaultConfig()
+	mergeFileConfig(config, &fileConfig)
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("%s is not valid: %w", source, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// ConfigKeyEnv names the environment variable holding the key the config
+// file is signed with. When it is set, every save writes an HMAC of the
+// file next to it and a file whose HMAC does not match is refused, so a
+// change made outside the server cannot take effect.
+const ConfigKeyEnv = "JARVIS_CONFIG_KEY"
+
+// ConfigSignatureModeEnv names the environment variable that, set to
+// "warn", loads a config file with a missing or wrong signature and only
+// logs a warning
+const ConfigSignatureModeEnv = "JARVIS_CONFIG_SIGNATURE"
+
+// configKey is the value of ConfigKeyEnv, read once and unset so commands
+// the server runs do not get it in their environment. Unsetting does not
+// scrub it: the environment the server was started with stays readable in
+// /proc/<pid>/environ to processes of the same user.
+var configKey []byte
+
+func loadConfigKey() {
+	if key := os.Getenv(ConfigKeyEnv); key != "" {
+		configKey = []byte(key)
+		os.Unsetenv(ConfigKeyEnv)
+	}
+}
+
+// configSignaturePath returns the file holding the signature of the
+// config file
+func configSignaturePath() string {
+	return getConfigPath() + ".sig"
+}
+
+func configSignature(data []byte) string {
+	mac := hmac.New(sha256.New, configKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signConfigFile writes the signature of the config file contents, when
+// ConfigKeyEnv is set
+func signConfigFile(data []byte) error {
+	if len(configKey) == 0 {
+		return nil
+	}
+	if err := WriteFileAtomic(configSignaturePath(), []byte(configSignature(data)+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write config signature: %v", err)
+	}
+	return nil
+}
+
+// verifyConfigFile checks the config file contents against their
+// signature, when ConfigKeyEnv is set
+func verifyConfigFile(data []byte) error {
+	if len(configKey) == 0 {
+		return nil
+	}
+
+	var err error
+	signature, readErr := os.ReadFile(configSignaturePath())
+	switch {
+	case os.IsNotExist(readErr):
+		err = fmt.Errorf("config file %s has no signature in %s; it was not saved by the server", getConfigPath(), configSignaturePath())
+	case readErr != nil:
+		err = fmt.Errorf("failed to read config signature: %v", readErr)
+	case !hmac.Equal([]byte(strings.TrimSpace(string(signature))), []byte(configSignature(data))):
+		err = WithErrorCode(ErrorConflict, fmt.Errorf("config file %s was modified outside the server: its signature does not match", getConfigPath()))
+	}
+
+	if err != nil && os.Getenv(ConfigSignatureModeEnv) == "warn" {
+		log.Printf("Config signature warning: %v", err)
+		return nil
+	}
+	return err
+}
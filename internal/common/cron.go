@@ -0,0 +1,173 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Each field is a bit set of allowed values.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Standard cron runs a job when either day field matches if both are
+	// restricted
+	domRestricted, dowRestricted bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseCron parses a cron expression such as "*/15 9-17 * * mon-fri" or a
+// macro such as @daily
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	schedule := &CronSchedule{}
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	// 7 is accepted as Sunday
+	if schedule.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow = schedule.dow&^(1<<7) | 1
+	}
+	schedule.domRestricted = fields[2] != "*" && fields[2] != "?"
+	schedule.dowRestricted = fields[4] != "*" && fields[4] != "?"
+	return schedule, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges (a-b) and
+// steps (*/n, a-b/n, a/n) into a bit set
+func parseCronField(field string, low, high int, names map[string]int) (uint64, error) {
+	parseValue := func(text string) (int, error) {
+		if value, ok := names[strings.ToLower(text)]; ok {
+			return value, nil
+		}
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", text)
+		}
+		if value < low || value > high {
+			return 0, fmt.Errorf("%d is out of range %d-%d", value, low, high)
+		}
+		return value, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := low, high
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseValue(from); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(to); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("range %s is reversed", rangePart)
+			}
+		default:
+			value, err := parseValue(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			start, end = value, value
+			if hasStep {
+				end = high
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t that matches the schedule, or the
+// zero time if none does within five years
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
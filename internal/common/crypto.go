@@ -0,0 +1,231 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted files start with a fixed header followed by AES-256-GCM sealed
+// chunks. Each chunk uses the nonce prefix plus a chunk counter, and the
+// header plus a final-chunk flag as additional data, so reordered or
+// truncated files fail authentication.
+const (
+	encryptionMagic      = "JARVISENC"
+	encryptionVersion    = 1
+	encryptionChunkSize  = 64 * 1024
+	encryptionIterations = 600000
+	// maxEncryptionIterations bounds the iteration count DecryptFile
+	// accepts from a file header, so a crafted file cannot keep the key
+	// derivation busy indefinitely
+	maxEncryptionIterations = 10 * encryptionIterations
+	encryptionSaltSize      = 16
+	encryptionHeaderSize    = len(encryptionMagic) + 1 + 4 + encryptionSaltSize + 8 + 4
+)
+
+// ErrDecryptionFailed is returned when the key is wrong or the file was
+// modified
+var ErrDecryptionFailed = errors.New("decryption failed: wrong passphrase/key or corrupted file")
+
+// EncryptFile encrypts src into dst using a key derived from secret
+func EncryptFile(src, dst string, secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("passphrase or key file is required")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	header := make([]byte, encryptionHeaderSize)
+	copy(header, encryptionMagic)
+	offset := len(encryptionMagic)
+	header[offset] = encryptionVersion
+	binary.BigEndian.PutUint32(header[offset+1:], encryptionIterations)
+	salt := header[offset+5 : offset+5+encryptionSaltSize]
+	noncePrefix := header[offset+5+encryptionSaltSize : offset+13+encryptionSaltSize]
+	binary.BigEndian.PutUint32(header[offset+13+encryptionSaltSize:], encryptionChunkSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return err
+	}
+
+	aead, err := newFileAEAD(secret, salt, encryptionIterations)
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomicFunc(dst, 0600, func(w io.Writer) error {
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+
+		reader := bufio.NewReaderSize(in, encryptionChunkSize)
+		current := make([]byte, encryptionChunkSize)
+		next := make([]byte, encryptionChunkSize)
+
+		n, err := io.ReadFull(reader, current)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		for counter := uint32(0); ; counter++ {
+			m, err := io.ReadFull(reader, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			final := m == 0
+			sealed := aead.Seal(nil, chunkNonce(noncePrefix, counter), current[:n], chunkAAD(header, final))
+			if _, err := w.Write(sealed); err != nil {
+				return err
+			}
+			if final {
+				return nil
+			}
+			if counter == ^uint32(0) {
+				return fmt.Errorf("file too large to encrypt")
+			}
+			current, next = next, current
+			n = m
+		}
+	})
+}
+
+// DecryptFile decrypts src into dst. Nothing is left at dst when
+// authentication fails.
+func DecryptFile(src, dst string, secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("passphrase or key file is required")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	reader := bufio.NewReader(in)
+	header := make([]byte, encryptionHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil || !IsEncryptedHeader(header) {
+		return fmt.Errorf("not an encrypted file")
+	}
+	offset := len(encryptionMagic)
+	if header[offset] != encryptionVersion {
+		return fmt.Errorf("unsupported encryption version %d", header[offset])
+	}
+	iterations := binary.BigEndian.Uint32(header[offset+1:])
+	if iterations > maxEncryptionIterations {
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("iteration count %d in header exceeds the limit of %d", iterations, maxEncryptionIterations))
+	}
+	salt := header[offset+5 : offset+5+encryptionSaltSize]
+	noncePrefix := header[offset+5+encryptionSaltSize : offset+13+encryptionSaltSize]
+	chunkSize := binary.BigEndian.Uint32(header[offset+13+encryptionSaltSize:])
+	if chunkSize == 0 || chunkSize > 16*1024*1024 {
+		return fmt.Errorf("invalid chunk size in header")
+	}
+
+	aead, err := newFileAEAD(secret, salt, int(iterations))
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomicFunc(dst, 0600, func(w io.Writer) error {
+		sealed := make([]byte, int(chunkSize)+aead.Overhead())
+		for counter := uint32(0); ; counter++ {
+			n, err := io.ReadFull(reader, sealed)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			final := n < len(sealed)
+			if !final {
+				if _, err := reader.Peek(1); err == io.EOF {
+					final = true
+				}
+			}
+			if n == 0 && counter > 0 {
+				return ErrDecryptionFailed
+			}
+			plain, err := aead.Open(nil, chunkNonce(noncePrefix, counter), sealed[:n], chunkAAD(header, final))
+			if err != nil {
+				return ErrDecryptionFailed
+			}
+			if _, err := w.Write(plain); err != nil {
+				return err
+			}
+			if final {
+				return nil
+			}
+		}
+	})
+}
+
+// IsEncryptedHeader reports whether data starts with the encrypted file
+// magic
+func IsEncryptedHeader(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptionMagic))
+}
+
+func newFileAEAD(secret, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 {
+		return nil, fmt.Errorf("invalid key derivation parameters")
+	}
+	key := pbkdf2.Key(secret, salt, iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[8:], counter)
+	return nonce
+}
+
+func chunkAAD(header []byte, final bool) []byte {
+	aad := append([]byte{}, header...)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// ResolveEncryptionSecret returns the secret used for key derivation from
+// either a passphrase or the contents of a key file
+func ResolveEncryptionSecret(passphrase, keyFile string) ([]byte, error) {
+	switch {
+	case passphrase != "" && keyFile != "":
+		return nil, fmt.Errorf("specify either passphrase or key_file, not both")
+	case passphrase != "":
+		return []byte(passphrase), nil
+	case keyFile != "":
+		if !IsPathAllowed(keyFile) {
+			return nil, WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to key file is not allowed"))
+		}
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("key file is empty")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("passphrase or key_file is required")
+	}
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	plain, encrypted, decrypted := filepath.Join(dir, "plain"), filepath.Join(dir, "encrypted"), filepath.Join(dir, "decrypted")
+	content := bytes.Repeat([]byte("jarvis "), encryptionChunkSize/3)
+	if err := os.WriteFile(plain, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptFile(plain, encrypted, []byte("passphrase")); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile(encrypted, decrypted, []byte("wrong")); err != ErrDecryptionFailed {
+		t.Errorf("decrypting with the wrong passphrase: got %v, want ErrDecryptionFailed", err)
+	}
+	if err := DecryptFile(encrypted, decrypted, []byte("passphrase")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(decrypted); !bytes.Equal(got, content) {
+		t.Error("decrypted content differs from the original")
+	}
+}
+
+func TestDecryptFileRejectsExcessiveIterations(t *testing.T) {
+	dir := t.TempDir()
+	crafted, output := filepath.Join(dir, "crafted"), filepath.Join(dir, "output")
+	header := make([]byte, encryptionHeaderSize)
+	copy(header, encryptionMagic)
+	header[len(encryptionMagic)] = encryptionVersion
+	binary.BigEndian.PutUint32(header[len(encryptionMagic)+1:], ^uint32(0))
+	binary.BigEndian.PutUint32(header[encryptionHeaderSize-4:], encryptionChunkSize)
+	if err := os.WriteFile(crafted, append(header, make([]byte, 32)...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	err := DecryptFile(crafted, output, []byte("passphrase"))
+	if err == nil || !strings.Contains(err.Error(), "iteration count") {
+		t.Fatalf("got %v, want the iteration count refused", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("refusing the file took %s; the key was derived first", elapsed)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output was written: %v", err)
+	}
+}
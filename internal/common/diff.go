@@ -0,0 +1,467 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// Marks a final line that has no terminating newline so it never compares
+// equal to the same text followed by a newline
+const noNewlineSentinel = "\x00\\ No newline at end of file"
+
+const noNewlineMarker = "\\ No newline at end of file"
+
+// maxDiffEdits bounds the Myers search; beyond it the differing region is
+// reported as a single replacement
+const maxDiffEdits = 2000
+
+// DiffHunk is one hunk of a unified diff. Lines carry their ' ', '-' or '+'
+// prefix; a "\ No newline at end of file" line follows the affected line.
+type DiffHunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+// Header returns the @@ line of the hunk
+func (h DiffHunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", formatHunkRange(h.OldStart, h.OldLines), formatHunkRange(h.NewStart, h.NewLines))
+}
+
+func formatHunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// diffOp is a single step of a line edit script
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns a unified diff between two texts with the given number
+// of context lines, or an empty string when they are identical
+func UnifiedDiff(oldName, newName, oldContent, newContent string, context int) string {
+	hunks := ComputeDiffHunks(oldContent, newContent, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	return FormatUnifiedDiff(oldName, newName, hunks)
+}
+
+// FormatUnifiedDiff renders hunks with ---/+++ file headers
+func FormatUnifiedDiff(oldName, newName string, hunks []DiffHunk) string {
+	var diff strings.Builder
+	diff.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	for _, hunk := range hunks {
+		diff.WriteString(hunk.Header())
+		diff.WriteString("\n")
+		for _, line := range hunk.Lines {
+			diff.WriteString(line)
+			diff.WriteString("\n")
+		}
+	}
+	return diff.String()
+}
+
+// ComputeDiffHunks diffs two texts line by line using the Myers algorithm
+// and groups the changes into hunks with the given number of context lines
+func ComputeDiffHunks(oldContent, newContent string, context int) []DiffHunk {
+	if context < 0 {
+		context = 0
+	}
+	ops := diffLineOps(splitDiffLines(oldContent), splitDiffLines(newContent))
+
+	// Line numbers before each op
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var hunks []DiffHunk
+	i := 0
+	for i < len(ops) {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// Changes closer than 2*context lines share a hunk, so the leading
+		// context never overlaps the previous hunk
+		start := max(i-context, 0)
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = run
+		}
+
+		hunk := DiffHunk{
+			OldStart: oldPos[start] + 1,
+			NewStart: newPos[start] + 1,
+			OldLines: oldPos[end] - oldPos[start],
+			NewLines: newPos[end] - newPos[start],
+		}
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		for _, op := range ops[start:end] {
+			if text, ok := strings.CutSuffix(op.text, noNewlineSentinel); ok {
+				hunk.Lines = append(hunk.Lines, string(op.kind)+text, noNewlineMarker)
+			} else {
+				hunk.Lines = append(hunk.Lines, string(op.kind)+op.text)
+			}
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+
+	return hunks
+}
+
+// splitDiffLines splits content into lines, tagging a final line that lacks
+// a newline
+func splitDiffLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += noNewlineSentinel
+	return lines
+}
+
+// diffLineOps returns a minimal edit script turning a into b. Each op is
+// ' ' (keep), '-' (delete from a) or '+' (insert from b).
+func diffLineOps(a, b []string) []diffOp {
+	// Common prefix and suffix never take part in the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceOps(a, b)
+	}
+
+	// Compare integer ids instead of strings
+	ids := make(map[string]int)
+	ai := make([]int, n)
+	bi := make([]int, m)
+	for i, line := range a {
+		if _, ok := ids[line]; !ok {
+			ids[line] = len(ids)
+		}
+		ai[i] = ids[line]
+	}
+	for i, line := range b {
+		if _, ok := ids[line]; !ok {
+			ids[line] = len(ids)
+		}
+		bi[i] = ids[line]
+	}
+
+	maxD := min(n+m, maxDiffEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// trace[d] holds v[-d..d] as it was before step d
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && ai[x] == bi[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return replaceOps(a, b)
+	}
+
+	// Walk the trace backwards to recover the edit script
+	var reversed []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffOp{'+', b[y-1]})
+			} else {
+				reversed = append(reversed, diffOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+func replaceOps(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// Diff styles for edit results
+const (
+	DiffStyleWord    = "word"
+	DiffStyleUnified = "unified"
+)
+
+// minWordDiffSimilarity is the share of a changed line that must survive
+// for it to be shown as an inline word diff rather than a -/+ pair
+const minWordDiffSimilarity = 0.4
+
+// EditDiff returns the diff shown in edit tool results, in the configured
+// diff style
+func EditDiff(oldName, newName, oldContent, newContent string, context int) string {
+	return StyledDiff(oldName, newName, oldContent, newContent, context, Get().DiffStyle)
+}
+
+// StyledDiff returns a diff between two texts in a diff style. With the
+// word style, a removed line replaced by a similar added line is shown as a
+// single ~ line marking the changed words as [-old-]{+new+}; otherwise it
+// is a plain unified diff.
+func StyledDiff(oldName, newName, oldContent, newContent string, context int, style string) string {
+	hunks := ComputeDiffHunks(oldContent, newContent, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	if style != DiffStyleUnified {
+		for i := range hunks {
+			hunks[i].Lines = highlightWordChanges(hunks[i].Lines)
+		}
+	}
+	return FormatUnifiedDiff(oldName, newName, hunks)
+}
+
+// DiffStats counts the lines added and deleted by unified diff hunks
+func DiffStats(hunks []DiffHunk) types.DiffStat {
+	var stat types.DiffStat
+	for _, hunk := range hunks {
+		for _, line := range hunk.Lines {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				stat.Added++
+			case strings.HasPrefix(line, "-"):
+				stat.Deleted++
+			}
+		}
+	}
+	return stat
+}
+
+// FormatDiffStat summarizes a diff stat as git does, e.g.
+// "3 insertions(+), 1 deletion(-)"
+func FormatDiffStat(stat types.DiffStat) string {
+	plural := func(n int, word string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, word)
+		}
+		return fmt.Sprintf("%d %ss", n, word)
+	}
+	return fmt.Sprintf("%s(+), %s(-)", plural(stat.Added, "insertion"), plural(stat.Deleted, "deletion"))
+}
+
+// highlightWordChanges pairs each run of removed lines with the run of
+// added lines that follows it and merges similar pairs into ~ lines
+func highlightWordChanges(lines []string) []string {
+	var result []string
+	for i := 0; i < len(lines); {
+		if !strings.HasPrefix(lines[i], "-") {
+			result = append(result, lines[i])
+			i++
+			continue
+		}
+
+		removedEnd := i
+		for removedEnd < len(lines) && strings.HasPrefix(lines[removedEnd], "-") {
+			removedEnd++
+		}
+		addedEnd := removedEnd
+		for addedEnd < len(lines) && strings.HasPrefix(lines[addedEnd], "+") {
+			addedEnd++
+		}
+		removed, added := lines[i:removedEnd], lines[removedEnd:addedEnd]
+
+		// Lines are paired in order; any surplus stays a plain - or + line
+		var unpaired []string
+		for j := range removed {
+			if j >= len(added) {
+				result = append(result, removed[j])
+				continue
+			}
+			merged, similar := WordDiff(removed[j][1:], added[j][1:])
+			if similar {
+				result = append(result, "~"+merged)
+			} else {
+				result = append(result, removed[j])
+				unpaired = append(unpaired, added[j])
+			}
+		}
+		result = append(result, unpaired...)
+		if len(added) > len(removed) {
+			result = append(result, added[len(removed):]...)
+		}
+		i = addedEnd
+	}
+	return result
+}
+
+// WordDiff marks the differences between two lines word by word as
+// [-removed-] and {+added+}. It also reports whether enough of the line is
+// unchanged for the inline form to be easier to read than the two lines.
+func WordDiff(oldLine, newLine string) (string, bool) {
+	ops := myersDiff(splitWords(oldLine), splitWords(newLine))
+
+	var b strings.Builder
+	kept := 0
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			b.WriteString(ops[i].text)
+			kept += len(ops[i].text)
+			i++
+			continue
+		}
+
+		// Group a run of changes, including whitespace kept between them,
+		// so it reads as one replacement
+		var removed, added strings.Builder
+		hasRemoved, hasAdded := false, false
+		for i < len(ops) && (ops[i].kind != ' ' || (i+1 < len(ops) && ops[i+1].kind != ' ' && strings.TrimSpace(ops[i].text) == "")) {
+			switch ops[i].kind {
+			case ' ':
+				removed.WriteString(ops[i].text)
+				added.WriteString(ops[i].text)
+			case '-':
+				removed.WriteString(ops[i].text)
+				hasRemoved = true
+			default:
+				added.WriteString(ops[i].text)
+				hasAdded = true
+			}
+			i++
+		}
+		if hasRemoved {
+			b.WriteString("[-" + removed.String() + "-]")
+		}
+		if hasAdded {
+			b.WriteString("{+" + added.String() + "+}")
+		}
+	}
+
+	longest := max(len(oldLine), len(newLine))
+	similar := longest == 0 || float64(kept)/float64(longest) >= minWordDiffSimilarity
+	return b.String(), similar
+}
+
+// splitWords splits a line into words and the runs of whitespace between
+// them, as git's word diff does
+func splitWords(line string) []string {
+	var tokens []string
+	start := 0
+	for i := 1; i <= len(line); i++ {
+		if i == len(line) || isDiffSpace(line[i]) != isDiffSpace(line[i-1]) {
+			tokens = append(tokens, line[start:i])
+			start = i
+		}
+	}
+	return tokens
+}
+
+func isDiffSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
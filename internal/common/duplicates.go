@@ -0,0 +1,106 @@
+package common
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"jarvis/internal/types"
+)
+
+// FindDuplicateFiles walks root and groups the regular files of at least
+// minSize bytes that have identical contents. Only files sharing a size
+// are hashed, with algorithm. Groups are sorted by the space they waste.
+func FindDuplicateFiles(root string, opts ManifestOptions, algorithm string, minSize int64) (*types.DuplicateReport, error) {
+	if _, err := NewHash(algorithm); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", root)
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var ignore *GitignoreMatcher
+	if opts.RespectGitignore {
+		ignore = LoadGitignore(root)
+	}
+
+	report := &types.DuplicateReport{Root: absRoot, Algorithm: algorithm, Groups: []types.DuplicateGroup{}}
+	bySize := make(map[int64][]string)
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil // Skip unreadable entries
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if SkipInWalk(path, d.Type()) || !manifestIncludes(rel, d, opts, ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		fileInfo, err := d.Info()
+		if err != nil || fileInfo.Size() < minSize {
+			return nil
+		}
+		report.FilesScanned++
+		bySize[fileInfo.Size()] = append(bySize[fileInfo.Size()], rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		byDigest := make(map[string][]string)
+		for _, rel := range paths {
+			digests, err := HashFile(filepath.Join(root, filepath.FromSlash(rel)), algorithm)
+			if err != nil {
+				continue
+			}
+			byDigest[digests[algorithm]] = append(byDigest[digests[algorithm]], rel)
+		}
+		for digest, same := range byDigest {
+			if len(same) < 2 {
+				continue
+			}
+			sort.Strings(same)
+			report.Groups = append(report.Groups, types.DuplicateGroup{Size: size, Checksum: digest, Paths: same})
+			report.WastedBytes += size * int64(len(same)-1)
+		}
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		wastedA, wastedB := a.Size*int64(len(a.Paths)-1), b.Size*int64(len(b.Paths)-1)
+		if wastedA != wastedB {
+			return wastedA > wastedB
+		}
+		return a.Paths[0] < b.Paths[0]
+	})
+
+	return report, nil
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Error codes of failed tool calls and operations, for clients to branch
+// on instead of the message
+const (
+	ErrorPathNotAllowed   = "PATH_NOT_ALLOWED"
+	ErrorURLNotAllowed    = "URL_NOT_ALLOWED"
+	ErrorCommandBlocked   = "COMMAND_BLOCKED"
+	ErrorPermissionDenied = "PERMISSION_DENIED"
+	ErrorRateLimited      = "RATE_LIMITED"
+	ErrorQuotaExceeded    = "QUOTA_EXCEEDED"
+	ErrorValidationFailed = "VALIDATION_FAILED"
+	ErrorTimeout          = "TIMEOUT"
+	ErrorNotFound         = "NOT_FOUND"
+	ErrorAlreadyExists    = "ALREADY_EXISTS"
+	ErrorConflict         = "CONFLICT"
+	ErrorOperationFailed  = "OPERATION_FAILED"
+)
+
+// ErrorCodes lists the error codes
+var ErrorCodes = []string{
+	ErrorPathNotAllowed, ErrorURLNotAllowed, ErrorCommandBlocked, ErrorPermissionDenied,
+	ErrorRateLimited, ErrorQuotaExceeded, ErrorValidationFailed, ErrorTimeout,
+	ErrorNotFound, ErrorAlreadyExists, ErrorConflict, ErrorOperationFailed,
+}
+
+// CodedError is an error carrying its error code
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string { return e.Err.Error() }
+
+func (e *CodedError) Unwrap() error { return e.Err }
+
+// WithErrorCode returns err carrying code, or nil when err is nil
+func WithErrorCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+// ErrorCodeOf returns the code of an error: the one it carries, else the
+// one its kind implies, else ErrorOperationFailed. The message is never
+// inspected, since it includes paths and other text from the caller.
+func ErrorCodeOf(err error) string {
+	var coded *CodedError
+	var timeout interface{ Timeout() bool }
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &coded):
+		return coded.Code
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &timeout) && timeout.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, os.ErrNotExist):
+		return ErrorNotFound
+	case errors.Is(err, os.ErrExist):
+		return ErrorAlreadyExists
+	case errors.Is(err, os.ErrPermission):
+		return ErrorPermissionDenied
+	}
+	return ErrorOperationFailed
+}
+
+// ErrorCodeFor returns the code of a message formatted from format and
+// args: that of the first error among args with a code of its own, else
+// the one format implies
+func ErrorCodeFor(format string, args ...any) string {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			if code := ErrorCodeOf(err); code != ErrorOperationFailed {
+				return code
+			}
+		}
+	}
+	return ClassifyErrorFormat(format)
+}
+
+// errorFormatCodes map phrases of message formats to their codes. They are
+// tried in order, so the refusals come before the phrases that may appear
+// in their explanation.
+var errorFormatCodes = []struct {
+	code    string
+	phrases []string
+}{
+	{ErrorPathNotAllowed, []string{"path is not allowed", "paths is not allowed", "directory is not allowed", "does not allow access to"}},
+	{ErrorURLNotAllowed, []string{"allowedurlpatterns"}},
+	{ErrorCommandBlocked, []string{"blocked pattern", "blocked command", "matches blocked"}},
+	{ErrorPermissionDenied, []string{"permission denied", "operation not permitted", "refusing to"}},
+	{ErrorRateLimited, []string{"rate limited"}},
+	{ErrorQuotaExceeded, []string{"quota"}},
+	{ErrorConflict, []string{"changed since", "checksum mismatch", "modified outside", "has changed on disk"}},
+	{ErrorAlreadyExists, []string{"already exists", "exists and overwrite is false"}},
+	{ErrorValidationFailed, []string{"invalid ", "must be", "must start", "must satisfy", "cannot be", "cannot contain", "exceeds", "validation failed", "give either", "requires ", "use either", "missing ", "unknown "}},
+	{ErrorTimeout, []string{"timed out", "deadline exceeded"}},
+	{ErrorNotFound, []string{"no such file", "not found", "does not exist", "no config version"}},
+}
+
+// ClassifyErrorFormat returns the code the untranslated format of an error
+// message implies, or ErrorOperationFailed when it implies none. Only the
+// fixed text is classified, never a message with its values filled in.
+func ClassifyErrorFormat(format string) string {
+	format = strings.ToLower(format)
+	for _, rule := range errorFormatCodes {
+		for _, phrase := range rule.phrases {
+			if strings.Contains(format, phrase) {
+				return rule.code
+			}
+		}
+	}
+	return ErrorOperationFailed
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestErrorCodesIgnoreInterpolatedText(t *testing.T) {
+	missing := &os.PathError{Op: "open", Path: "/tmp/timeout.log", Err: os.ErrNotExist}
+	for _, test := range []struct {
+		name   string
+		format string
+		args   []any
+		want   string
+	}{
+		{"os error", "Failed to read file: %v", []any{missing}, ErrorNotFound},
+		{"coded error", "Failed to write file: %v", []any{WithErrorCode(ErrorQuotaExceeded, errors.New("write exceeds maxWriteBytes"))}, ErrorQuotaExceeded},
+		{"path in value", "Failed to read file: %v", []any{errors.New("quota-report.txt: invalid kota")}, ErrorOperationFailed},
+		{"format", "Invalid path parameter: %v", []any{errors.New("reading timeout.log")}, ErrorValidationFailed},
+		{"refusal", "Access to this path is not allowed", nil, ErrorPathNotAllowed},
+	} {
+		if got := ErrorCodeFor(test.format, test.args...); got != test.want {
+			t.Errorf("%s: ErrorCodeFor(%q) = %s, want %s", test.name, fmt.Sprintf(test.format, test.args...), got, test.want)
+		}
+	}
+
+	if got := ErrorCodeOf(fmt.Errorf("parse quota-report.txt: invalid header")); got != ErrorOperationFailed {
+		t.Errorf("ErrorCodeOf classified an uncoded error by its message as %s", got)
+	}
+	if got := ErrorCodeOf(fmt.Errorf("read config: %w", missing)); got != ErrorNotFound {
+		t.Errorf("ErrorCodeOf(wrapped os.ErrNotExist) = %s, want %s", got, ErrorNotFound)
+	}
+}
@@ -0,0 +1,17 @@
+package common
+
+import (
+	"syscall"
+	"time"
+)
+
+// statBirthTime returns the file creation time reported by the kernel
+func statBirthTime(st *syscall.Stat_t) *time.Time {
+	created := time.Unix(st.Birthtimespec.Unix())
+	return &created
+}
+
+// statAccessTime returns the last access time from a stat result
+func statAccessTime(st *syscall.Stat_t) time.Time {
+	return time.Unix(st.Atimespec.Unix())
+}
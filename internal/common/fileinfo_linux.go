@@ -0,0 +1,84 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// statBirthTime returns nil on Linux because syscall.Stat_t does not carry
+// the creation time (it requires statx).
+func statBirthTime(st *syscall.Stat_t) *time.Time {
+	return nil
+}
+
+// statAccessTime returns the last access time from a stat result
+func statAccessTime(st *syscall.Stat_t) time.Time {
+	return time.Unix(st.Atim.Unix())
+}
+
+// ListXattrs returns all extended attributes of a file
+func ListXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extended attributes: %w", err)
+	}
+
+	attrs := make(map[string]string)
+	if size == 0 {
+		return attrs, nil
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extended attributes: %w", err)
+	}
+
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := GetXattr(path, string(name))
+		if err != nil {
+			continue
+		}
+		attrs[string(name)] = value
+	}
+
+	return attrs, nil
+}
+
+// GetXattr returns the value of a single extended attribute
+func GetXattr(path, name string) (string, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to read attribute %s: %w", name, err)
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to read attribute %s: %w", name, err)
+	}
+
+	return strings.TrimRight(string(buf[:size]), "\x00"), nil
+}
+
+// SetXattr sets an extended attribute on a file
+func SetXattr(path, name, value string) error {
+	if err := syscall.Setxattr(path, name, []byte(value), 0); err != nil {
+		return fmt.Errorf("failed to set attribute %s: %w", name, err)
+	}
+	return nil
+}
+
+// RemoveXattr removes an extended attribute from a file
+func RemoveXattr(path, name string) error {
+	if err := syscall.Removexattr(path, name); err != nil {
+		return fmt.Errorf("failed to remove attribute %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package common
+
+import (
+	"os"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// GetExtendedFileInfo is not supported on this platform
+func GetExtendedFileInfo(info os.FileInfo) (*types.ExtendedFileInfo, bool) {
+	return nil, false
+}
+
+// GetAccessTime is not supported on this platform
+func GetAccessTime(info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
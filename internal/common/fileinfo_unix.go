@@ -0,0 +1,48 @@
+//go:build linux || darwin
+
+package common
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// GetExtendedFileInfo returns ownership, inode and link details for a file.
+// The boolean result is false when the platform does not expose them.
+func GetExtendedFileInfo(info os.FileInfo) (*types.ExtendedFileInfo, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, false
+	}
+
+	ext := &types.ExtendedFileInfo{
+		UID:       st.Uid,
+		GID:       st.Gid,
+		Inode:     uint64(st.Ino),
+		Links:     uint64(st.Nlink),
+		CreatedAt: statBirthTime(st),
+	}
+
+	if u, err := user.LookupId(strconv.FormatUint(uint64(st.Uid), 10)); err == nil {
+		ext.Owner = u.Username
+	}
+	if g, err := user.LookupGroupId(strconv.FormatUint(uint64(st.Gid), 10)); err == nil {
+		ext.Group = g.Name
+	}
+
+	return ext, true
+}
+
+// GetAccessTime returns the last access time of a file
+func GetAccessTime(info os.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return statAccessTime(st), true
+}
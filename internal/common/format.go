@@ -0,0 +1,133 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DetectFormatter returns the default formatter for a file based on its
+// extension. Go files use goimports when it is installed.
+func DetectFormatter(filePath string) (string, error) {
+	ext := GetFileExtension(filePath)
+	switch ext {
+	case ".go":
+		if _, err := exec.LookPath("goimports"); err == nil {
+			return "goimports", nil
+		}
+		return "gofmt", nil
+	case ".py":
+		return "black", nil
+	case ".js", ".ts", ".jsx", ".tsx", ".json", ".css", ".scss", ".html", ".md", ".yaml", ".yml":
+		return "prettier", nil
+	case ".java":
+		return "google-java-format", nil
+	case ".c", ".cpp", ".cc", ".h", ".hpp":
+		return "clang-format", nil
+	default:
+		return "", fmt.Errorf("no default formatter for file type: %s", ext)
+	}
+}
+
+// formatterArgs returns the arguments that make a formatter read source from
+// stdin and write the formatted result to stdout
+func formatterArgs(formatter, filePath, configFile string) ([]string, error) {
+	switch formatter {
+	case "gofmt":
+		return nil, nil
+	case "goimports":
+		return []string{"-srcdir", filepath.Dir(filePath)}, nil
+	case "black":
+		args := []string{"--quiet", "--stdin-filename", filePath}
+		if configFile != "" {
+			args = append(args, "--config", configFile)
+		}
+		return append(args, "-"), nil
+	case "prettier":
+		args := []string{"--stdin-filepath", filePath}
+		if configFile != "" {
+			args = append(args, "--config", configFile)
+		}
+		return args, nil
+	case "clang-format":
+		args := []string{"--assume-filename=" + filePath}
+		if configFile != "" {
+			args = append(args, "--style=file:"+configFile)
+		}
+		return args, nil
+	case "google-java-format":
+		return []string{"-"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported formatter: %s", formatter)
+	}
+}
+
+// FormatCode runs a formatter over content, which is treated as the content
+// of filePath, and returns the formatted code along with any diagnostics the
+// formatter printed. An empty formatter is detected from the file extension.
+func FormatCode(ctx context.Context, filePath, formatter, configFile string, content []byte) ([]byte, string, error) {
+	if filePath == "" {
+		return nil, "", fmt.Errorf("file path cannot be empty")
+	}
+
+	if formatter == "" {
+		var err error
+		if formatter, err = DetectFormatter(filePath); err != nil {
+			return nil, "", err
+		}
+	}
+
+	args, err := formatterArgs(formatter, filePath, configFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := exec.LookPath(formatter); err != nil {
+		return nil, "", WithErrorCode(ErrorNotFound, fmt.Errorf("formatter %s not found in PATH", formatter))
+	}
+
+	formatted, diagnostics, err := runFilterCommand(ctx, formatter, args, filepath.Dir(filePath), content)
+	if err != nil {
+		return nil, diagnostics, err
+	}
+
+	// A formatter that printed nothing for non-empty input did not format it
+	if len(formatted) == 0 && len(content) > 0 {
+		return nil, diagnostics, fmt.Errorf("%s produced no output", formatter)
+	}
+
+	return formatted, diagnostics, nil
+}
+
+// runFilterCommand runs tool with content on stdin in dir and returns its
+// stdout and trimmed stderr
+func runFilterCommand(ctx context.Context, tool string, args []string, dir string, content []byte) ([]byte, string, error) {
+	binary, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, "", WithErrorCode(ErrorNotFound, fmt.Errorf("%s not found in PATH", tool))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	diagnostics := strings.TrimSpace(stderr.String())
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, diagnostics, WithErrorCode(ErrorTimeout, fmt.Errorf("%s timed out", tool))
+		}
+		if diagnostics != "" {
+			return nil, diagnostics, fmt.Errorf("%s failed: %v\n%s", tool, err, diagnostics)
+		}
+		return nil, diagnostics, fmt.Errorf("%s failed: %v", tool, err)
+	}
+
+	return stdout.Bytes(), diagnostics, nil
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is a Markdown document split around its YAML front matter
+type frontMatter struct {
+	open    string // optional BOM and the opening --- line
+	yaml    string
+	close   string // the closing --- or ... line
+	body    string
+	newline string
+	found   bool
+}
+
+// splitFrontMatter locates a YAML front matter block delimited by --- lines
+// at the very start of content
+func splitFrontMatter(content string) (frontMatter, error) {
+	fm := frontMatter{body: content, newline: "\n"}
+	if strings.Contains(content, "\r\n") {
+		fm.newline = "\r\n"
+	}
+
+	text := strings.TrimPrefix(content, "\ufeff")
+	bom := content[:len(content)-len(text)]
+
+	end := strings.IndexByte(text, '\n')
+	if end == -1 || strings.TrimRight(text[:end], "\r \t") != "---" {
+		return fm, nil
+	}
+
+	rest := text[end+1:]
+	pos := 0
+	for pos < len(rest) {
+		next := len(rest)
+		line := rest[pos:]
+		if i := strings.IndexByte(line, '\n'); i != -1 {
+			line = line[:i]
+			next = pos + i + 1
+		}
+		if trimmed := strings.TrimRight(line, "\r \t"); trimmed == "---" || trimmed == "..." {
+			fm.open = bom + text[:end+1]
+			fm.yaml = rest[:pos]
+			fm.close = rest[pos:next]
+			fm.body = rest[next:]
+			fm.found = true
+			return fm, nil
+		}
+		pos = next
+	}
+	return fm, fmt.Errorf("front matter is not terminated by a --- line")
+}
+
+func (fm frontMatter) join(yamlText string) []byte {
+	if fm.newline != "\n" {
+		yamlText = strings.ReplaceAll(yamlText, "\n", fm.newline)
+	}
+	return []byte(fm.open + yamlText + fm.close + fm.body)
+}
+
+// GetFrontMatter returns the value at path in the YAML front matter of a
+// Markdown document, or the whole front matter when path is empty
+func GetFrontMatter(content []byte, path string) (interface{}, error) {
+	fm, err := splitFrontMatter(string(content))
+	if err != nil {
+		return nil, err
+	}
+	if !fm.found {
+		return nil, fmt.Errorf("document has no front matter")
+	}
+	if strings.TrimSpace(fm.yaml) == "" {
+		if path == "" {
+			return NewOrderedObject(), nil
+		}
+		return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("%s: key not found", path))
+	}
+	return GetStructuredValue([]byte(fm.yaml), FormatYAML, path)
+}
+
+// SetFrontMatter sets the value at path in the front matter, adding a front
+// matter block when the document has none. The body is left untouched.
+func SetFrontMatter(content []byte, path string, value interface{}, createMissing bool) ([]byte, error) {
+	fm, err := splitFrontMatter(string(content))
+	if err != nil {
+		return nil, err
+	}
+	if !fm.found {
+		fm.open = "---" + fm.newline
+		fm.close = "---" + fm.newline
+	}
+
+	updated, err := SetStructuredValue([]byte(strings.ReplaceAll(fm.yaml, "\r\n", "\n")), FormatYAML, path, value, createMissing)
+	if err != nil {
+		return nil, err
+	}
+	return fm.join(string(updated)), nil
+}
+
+// DeleteFrontMatter removes the key or item at path from the front matter
+func DeleteFrontMatter(content []byte, path string) ([]byte, error) {
+	fm, err := splitFrontMatter(string(content))
+	if err != nil {
+		return nil, err
+	}
+	if !fm.found {
+		return nil, fmt.Errorf("document has no front matter")
+	}
+
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	yamlText := strings.ReplaceAll(fm.yaml, "\r\n", "\n")
+	docs, err := decodeYAMLDocuments([]byte(yamlText))
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("%s: key not found", path))
+	}
+	if err := deleteYAMLNode(docs[0], segments); err != nil {
+		return nil, err
+	}
+
+	// An emptied front matter stays as an empty block rather than {}
+	if root := docs[0].Content[0]; root.Kind == yaml.MappingNode && len(root.Content) == 0 {
+		return fm.join(""), nil
+	}
+
+	updated, err := encodeYAMLDocuments(docs, len(detectIndent(yamlText, "  ")))
+	if err != nil {
+		return nil, err
+	}
+	return fm.join(string(updated)), nil
+}
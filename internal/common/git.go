@@ -0,0 +1,590 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// Git tools run the git command line client and parse its machine-readable
+// output (porcelain v2, -z, custom log formats). Every repository path and
+// pathspec must be in an allowed directory, and refs and paths are passed
+// after "--" or validated so they can never be read as options.
+
+// gitCommandTimeout bounds each git invocation
+const gitCommandTimeout = 2 * time.Minute
+
+// gitSafeConfig overrides every setting that makes git run a program named
+// in the repository's configuration. File tools can write .git/config and
+// .git/hooks, and a command started that way would bypass blockedCommands,
+// approvals, the sandbox and run_as_user.
+var gitSafeConfig = []string{
+	"-c", "color.ui=false",
+	"-c", "core.fsmonitor=false",
+	"-c", "core.hooksPath=/dev/null",
+	"-c", "gpg.program=gpg",
+	"-c", "gpg.ssh.program=ssh-keygen",
+	"-c", "gpg.x509.program=gpgsm",
+}
+
+// runGit runs git in dir and returns its standard output. Errors carry
+// git's own message from standard error.
+func runGit(ctx context.Context, dir string, stdin string, args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", WithErrorCode(ErrorNotFound, fmt.Errorf("git command not found in PATH"))
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, gitCommandTimeout)
+	defer cancel()
+
+	gitArgs := append(append([]string{"-C", dir}, gitSafeConfig...), args...)
+	cmd := exec.CommandContext(cmdCtx, "git", gitArgs...)
+	// Never prompt for credentials, keep messages parseable and let read
+	// operations skip refreshing the index
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_OPTIONAL_LOCKS=0", "LC_ALL=C")
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			return "", WithErrorCode(ErrorTimeout, fmt.Errorf("git %s timed out after %s", args[0], gitCommandTimeout))
+		}
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// CheckGitRepository checks that dir is an allowed directory inside a git
+// work tree and returns it as an absolute path
+func CheckGitRepository(ctx context.Context, dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository path: %v", err)
+	}
+	if !IsPathAllowed(absDir) {
+		return "", WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to %s is not allowed", absDir))
+	}
+	if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", absDir)
+	}
+	if _, err := runGit(ctx, absDir, "", "rev-parse", "--is-inside-work-tree"); err != nil {
+		return "", fmt.Errorf("%s is not inside a git work tree", absDir)
+	}
+	return absDir, nil
+}
+
+// checkGitPaths checks that pathspecs, relative to the repository path,
+// stay in allowed directories
+func checkGitPaths(dir string, paths []string) error {
+	for _, path := range paths {
+		target := path
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		if !IsPathAllowed(target) {
+			return WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to %s is not allowed", target))
+		}
+	}
+	return nil
+}
+
+// checkGitRef rejects refs that git could read as options
+func checkGitRef(ref string) error {
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\r\n") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	return nil
+}
+
+// GitStatus returns the branch and changed files of the repository at dir
+func GitStatus(ctx context.Context, dir string) (*types.GitStatus, error) {
+	output, err := runGit(ctx, dir, "", "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=normal")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &types.GitStatus{Files: []types.GitFileStatus{}}
+	records := strings.Split(output, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		switch {
+		case strings.HasPrefix(record, "# branch.oid "):
+			status.Commit = strings.TrimPrefix(record, "# branch.oid ")
+		case strings.HasPrefix(record, "# branch.head "):
+			status.Branch = strings.TrimPrefix(record, "# branch.head ")
+		case strings.HasPrefix(record, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(record, "# branch.upstream ")
+		case strings.HasPrefix(record, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(record, "# branch.ab "), "+%d -%d", &status.Ahead, &status.Behind)
+		case strings.HasPrefix(record, "1 "):
+			// 1 XY sub mH mI mW hH hI path
+			fields := strings.SplitN(record, " ", 9)
+			if len(fields) == 9 {
+				status.Files = append(status.Files, gitFileStatus("changed", fields[1], fields[8], ""))
+			}
+		case strings.HasPrefix(record, "2 "):
+			// 2 XY sub mH mI mW hH hI Xscore path, then the original path
+			fields := strings.SplitN(record, " ", 10)
+			if len(fields) == 10 && i+1 < len(records) {
+				i++
+				status.Files = append(status.Files, gitFileStatus("renamed", fields[1], fields[9], records[i]))
+			}
+		case strings.HasPrefix(record, "u "):
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			fields := strings.SplitN(record, " ", 11)
+			if len(fields) == 11 {
+				status.Files = append(status.Files, gitFileStatus("unmerged", fields[1], fields[10], ""))
+			}
+		case strings.HasPrefix(record, "? "):
+			status.Files = append(status.Files, types.GitFileStatus{Path: record[2:], Kind: "untracked"})
+		}
+	}
+	if status.Commit == "(initial)" {
+		status.Commit = ""
+	}
+	status.Clean = len(status.Files) == 0
+	return status, nil
+}
+
+func gitFileStatus(kind, xy, path, origPath string) types.GitFileStatus {
+	file := types.GitFileStatus{Path: path, OrigPath: origPath, Kind: kind}
+	if len(xy) == 2 {
+		if xy[0] != '.' {
+			file.Staged = string(xy[0])
+		}
+		if xy[1] != '.' {
+			file.Unstaged = string(xy[1])
+		}
+	}
+	return file
+}
+
+// GitDiff returns the diff of the work tree against the index, of the
+// index against HEAD with staged, or of the work tree against ref, limited
+// to paths when given. The patch is capped at maxBytes.
+func GitDiff(ctx context.Context, dir string, staged bool, ref string, paths []string, contextLines, maxBytes int) (*types.GitDiff, error) {
+	if err := checkGitPaths(dir, paths); err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--no-ext-diff", "--no-textconv"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	if ref != "" {
+		if err := checkGitRef(ref); err != nil {
+			return nil, err
+		}
+		args = append(args, ref)
+	}
+
+	patchArgs := append(append([]string{}, args...), fmt.Sprintf("-U%d", contextLines), "--")
+	patch, err := runGit(ctx, dir, "", append(patchArgs, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	statArgs := append(append([]string{}, args...), "--numstat", "-z", "--")
+	numstat, err := runGit(ctx, dir, "", append(statArgs, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := NewOutputBuffer(maxBytes)
+	buf.Write([]byte(patch))
+	diff := &types.GitDiff{Patch: buf.String(), Truncated: buf.Truncated(), Files: []types.GitDiffStat{}}
+
+	// Each entry is "added\tdeleted\tpath", or "added\tdeleted\t" followed
+	// by the old and new paths for renames; binary files show "-" counts
+	records := strings.Split(numstat, "\x00")
+	for i := 0; i < len(records); i++ {
+		fields := strings.SplitN(records[i], "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		stat := types.GitDiffStat{Path: fields[2], Binary: fields[0] == "-"}
+		stat.Added, _ = strconv.Atoi(fields[0])
+		stat.Deleted, _ = strconv.Atoi(fields[1])
+		if stat.Path == "" && i+2 < len(records) {
+			stat.OrigPath, stat.Path = records[i+1], records[i+2]
+			i += 2
+		}
+		diff.Files = append(diff.Files, stat)
+	}
+	return diff, nil
+}
+
+// GitLogOptions selects the commits GitLog returns
+type GitLogOptions struct {
+	Ref    string
+	Path   string
+	Author string
+	Since  string
+	Limit  int
+}
+
+// GitLog returns commits reachable from opts.Ref, or HEAD, newest first
+func GitLog(ctx context.Context, dir string, opts GitLogOptions) ([]types.GitCommit, error) {
+	args := []string{"log", "--format=%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%P%x1f%s%x1f%b%x1e", fmt.Sprintf("--max-count=%d", opts.Limit)}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Ref != "" {
+		if err := checkGitRef(opts.Ref); err != nil {
+			return nil, err
+		}
+		args = append(args, opts.Ref)
+	}
+	args = append(args, "--")
+	if opts.Path != "" {
+		if err := checkGitPaths(dir, []string{opts.Path}); err != nil {
+			return nil, err
+		}
+		args = append(args, opts.Path)
+	}
+
+	output, err := runGit(ctx, dir, "", args...)
+	if err != nil {
+		// A repository without commits has no log
+		if strings.Contains(err.Error(), "does not have any commits") {
+			return []types.GitCommit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := []types.GitCommit{}
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 8 {
+			continue
+		}
+		commit := types.GitCommit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Email:     fields[3],
+			Subject:   fields[6],
+			Body:      strings.TrimSpace(fields[7]),
+			Parents:   strings.Fields(fields[5]),
+		}
+		if date, err := time.Parse(time.RFC3339, fields[4]); err == nil {
+			commit.Date = date
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// GitBlame returns who last changed each line of path, from startLine to
+// endLine when they are positive
+func GitBlame(ctx context.Context, dir, path, ref string, startLine, endLine int) ([]types.GitBlameLine, error) {
+	if err := checkGitPaths(dir, []string{path}); err != nil {
+		return nil, err
+	}
+	args := []string{"blame", "--porcelain"}
+	if startLine > 0 || endLine > 0 {
+		rangeSpec := fmt.Sprintf("%d,", max(startLine, 1))
+		if endLine > 0 {
+			rangeSpec += strconv.Itoa(endLine)
+		}
+		args = append(args, "-L", rangeSpec)
+	}
+	if ref != "" {
+		if err := checkGitRef(ref); err != nil {
+			return nil, err
+		}
+		args = append(args, ref)
+	}
+	output, err := runGit(ctx, dir, "", append(args, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Porcelain output gives each line as a "<hash> <orig> <final> [<n>]"
+	// header, commit details the first time a commit appears, then the
+	// line content prefixed with a tab
+	type commitInfo struct {
+		author  string
+		date    time.Time
+		summary string
+	}
+	commits := make(map[string]*commitInfo)
+	lines := []types.GitBlameLine{}
+	var current types.GitBlameLine
+	var info *commitInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Content = line[1:]
+			current.Author, current.Date, current.Summary = info.author, info.date, info.summary
+			lines = append(lines, current)
+		case strings.HasPrefix(line, "author "):
+			info.author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			if seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+				info.date = time.Unix(seconds, 0).UTC()
+			}
+		case strings.HasPrefix(line, "summary "):
+			info.summary = strings.TrimPrefix(line, "summary ")
+		default:
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && len(fields[0]) >= 40 {
+				lineNumber, err := strconv.Atoi(fields[2])
+				if err != nil {
+					continue
+				}
+				if commits[fields[0]] == nil {
+					commits[fields[0]] = &commitInfo{}
+				}
+				info = commits[fields[0]]
+				current = types.GitBlameLine{Line: lineNumber, Commit: fields[0]}
+			}
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// GitBranches lists local branches, and remote-tracking ones with remote
+func GitBranches(ctx context.Context, dir string, remote bool) ([]types.GitBranch, error) {
+	refs := []string{"refs/heads"}
+	if remote {
+		refs = append(refs, "refs/remotes")
+	}
+	output, err := runGit(ctx, dir, "", append([]string{"for-each-ref", "--format=%(refname)%1f%(objectname:short)%1f%(upstream:short)%1f%(HEAD)%1f%(contents:subject)"}, refs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []types.GitBranch{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 || strings.HasSuffix(fields[0], "/HEAD") {
+			continue
+		}
+		branch := types.GitBranch{
+			Commit:   fields[1],
+			Upstream: fields[2],
+			Current:  fields[3] == "*",
+			Subject:  fields[4],
+		}
+		if name, ok := strings.CutPrefix(fields[0], "refs/heads/"); ok {
+			branch.Name = name
+		} else {
+			branch.Name = strings.TrimPrefix(fields[0], "refs/remotes/")
+			branch.Remote = true
+		}
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+// checkBranchName validates a new branch name with git itself
+func checkBranchName(ctx context.Context, dir, name string) error {
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	if _, err := runGit(ctx, dir, "", "check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	return nil
+}
+
+// GitCreateBranch creates a branch at startPoint, or HEAD, without
+// switching to it
+func GitCreateBranch(ctx context.Context, dir, name, startPoint string) error {
+	if err := checkBranchName(ctx, dir, name); err != nil {
+		return err
+	}
+	args := []string{"branch", "--", name}
+	if startPoint != "" {
+		if err := checkGitRef(startPoint); err != nil {
+			return err
+		}
+		args = append(args, startPoint)
+	}
+	_, err := runGit(ctx, dir, "", args...)
+	return err
+}
+
+// GitDeleteBranch deletes a local branch; unless force is set it must be
+// merged
+func GitDeleteBranch(ctx context.Context, dir, name string, force bool) error {
+	if err := checkGitRef(name); err != nil {
+		return err
+	}
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+	_, err := runGit(ctx, dir, "", "branch", flag, "--", name)
+	return err
+}
+
+// GitAdd stages paths, or every change in the work tree with all
+func GitAdd(ctx context.Context, dir string, paths []string, all bool) error {
+	if all {
+		if len(paths) > 0 {
+			return fmt.Errorf("set either paths or all")
+		}
+		if !IsPathAllowed(dir) {
+			return WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to %s is not allowed", dir))
+		}
+		_, err := runGit(ctx, dir, "", "add", "--all")
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths to add")
+	}
+	if err := checkGitPaths(dir, paths); err != nil {
+		return err
+	}
+	_, err := runGit(ctx, dir, "", append([]string{"add", "--"}, paths...)...)
+	return err
+}
+
+// GitCommitOptions configures GitCommit
+type GitCommitOptions struct {
+	Message    string
+	Amend      bool
+	AllowEmpty bool
+}
+
+// GitCommit commits the staged changes and returns the new commit
+func GitCommit(ctx context.Context, dir string, opts GitCommitOptions) (*types.GitCommit, error) {
+	if strings.TrimSpace(opts.Message) == "" {
+		return nil, fmt.Errorf("commit message cannot be empty")
+	}
+	// The message is read from standard input so it is never parsed as
+	// an option. Hooks never run; see gitSafeConfig.
+	args := []string{"commit", "--file=-", "--no-verify"}
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if opts.AllowEmpty {
+		args = append(args, "--allow-empty")
+	}
+	if _, err := runGit(ctx, dir, opts.Message, args...); err != nil {
+		return nil, err
+	}
+
+	commits, err := GitLog(ctx, dir, GitLogOptions{Limit: 1})
+	if err != nil || len(commits) == 0 {
+		return nil, fmt.Errorf("committed, but failed to read the new commit: %v", err)
+	}
+	return &commits[0], nil
+}
+
+// GitStashList returns the stash entries, newest first
+func GitStashList(ctx context.Context, dir string) ([]types.GitStash, error) {
+	output, err := runGit(ctx, dir, "", "stash", "list", "--format=%gd%x1f%H%x1f%gs")
+	if err != nil {
+		return nil, err
+	}
+	stashes := []types.GitStash{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) == 3 {
+			stashes = append(stashes, types.GitStash{Ref: fields[0], Commit: fields[1], Message: fields[2]})
+		}
+	}
+	return stashes, nil
+}
+
+// GitStash runs a stash action: push (with an optional message and
+// untracked files), pop, apply or drop (of stash, default the newest)
+func GitStash(ctx context.Context, dir, action, message, stash string, includeUntracked bool) (string, error) {
+	var args []string
+	switch action {
+	case "push":
+		args = []string{"stash", "push"}
+		if includeUntracked {
+			args = append(args, "--include-untracked")
+		}
+		if message != "" {
+			args = append(args, "--message="+message)
+		}
+	case "pop", "apply", "drop":
+		args = []string{"stash", action}
+		if stash != "" {
+			if !strings.HasPrefix(stash, "stash@{") {
+				return "", fmt.Errorf("invalid stash %q: use stash@{n}", stash)
+			}
+			args = append(args, stash)
+		}
+	default:
+		return "", WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown stash action %q (use push, pop, apply, drop or list)", action))
+	}
+	output, err := runGit(ctx, dir, "", args...)
+	return strings.TrimSpace(output), err
+}
+
+// GitCheckout switches to target, creating it as a new branch with
+// create, or restores paths from target (default the index) without
+// switching branches
+func GitCheckout(ctx context.Context, dir, target string, create bool, paths []string) (string, error) {
+	var args []string
+	switch {
+	case len(paths) > 0:
+		if create {
+			return "", fmt.Errorf("create cannot be combined with paths")
+		}
+		if err := checkGitPaths(dir, paths); err != nil {
+			return "", err
+		}
+		args = []string{"checkout"}
+		if target != "" {
+			if err := checkGitRef(target); err != nil {
+				return "", err
+			}
+			args = append(args, target)
+		}
+		args = append(append(args, "--"), paths...)
+	case target == "":
+		return "", fmt.Errorf("set a target branch or commit, or paths to restore")
+	case create:
+		if err := checkBranchName(ctx, dir, target); err != nil {
+			return "", err
+		}
+		args = []string{"checkout", "-b", target}
+	default:
+		if err := checkGitRef(target); err != nil {
+			return "", err
+		}
+		args = []string{"checkout", target, "--"}
+	}
+
+	if _, err := runGit(ctx, dir, "", args...); err != nil {
+		return "", err
+	}
+	// Report the branch HEAD is on afterwards, or "(detached)"
+	status, err := GitStatus(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	return status.Branch, nil
+}
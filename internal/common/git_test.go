@@ -0,0 +1,56 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitIgnoresRepositoryCommands(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	if _, err := runGit(ctx, dir, "", "init", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file tool could write both of these into the repository
+	script := filepath.Join(dir, ".git", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, hook := range []string{"pre-commit", "commit-msg", "post-commit"} {
+		if err := os.WriteFile(filepath.Join(dir, ".git", "hooks", hook), []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := runGit(ctx, dir, "", "config", "core.fsmonitor", script); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"user.name", "user.email"} {
+		if _, err := runGit(ctx, dir, "", "config", key, "test@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("content\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GitStatus(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runGit(ctx, dir, "", "add", "--", "file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GitCommit(ctx, dir, GitCommitOptions{Message: "commit"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("git ran a command configured in the repository")
+	}
+}
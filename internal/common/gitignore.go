@@ -0,0 +1,79 @@
+package common
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreRule represents a single pattern from a .gitignore file
+type gitignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// GitignoreMatcher matches slash-separated paths relative to a root
+// directory against the rules of that root's .gitignore file.
+type GitignoreMatcher struct {
+	rules []gitignoreRule
+}
+
+// LoadGitignore reads root/.gitignore. A missing file yields an empty matcher.
+func LoadGitignore(root string) *GitignoreMatcher {
+	matcher := &GitignoreMatcher{}
+
+	file, err := os.Open(filepath.Join(root, ".gitignore"))
+	if err != nil {
+		return matcher
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := gitignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		// Patterns without a slash match at any depth; others are anchored
+		if strings.HasPrefix(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+
+		rule.pattern = line
+		matcher.rules = append(matcher.rules, rule)
+	}
+
+	return matcher
+}
+
+// Match reports whether relPath is ignored. Later rules override earlier ones.
+func (m *GitignoreMatcher) Match(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if MatchGlob(rule.pattern, relPath, true) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
@@ -0,0 +1,193 @@
+package common
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandBraces expands brace groups in a glob pattern, e.g. "*.{go,mod}"
+// becomes ["*.go", "*.mod"]. Nested groups are supported.
+func ExpandBraces(pattern string) []string {
+	start := -1
+	depth := 0
+	for i, ch := range pattern {
+		switch ch {
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 && start >= 0 {
+				prefix := pattern[:start]
+				suffix := pattern[i+1:]
+
+				var expanded []string
+				for _, alt := range splitBraceAlternatives(pattern[start+1 : i]) {
+					expanded = append(expanded, ExpandBraces(prefix+alt+suffix)...)
+				}
+				return expanded
+			}
+		}
+	}
+	return []string{pattern}
+}
+
+// splitBraceAlternatives splits the body of a brace group on top-level commas
+func splitBraceAlternatives(body string) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i, ch := range body {
+		switch ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, body[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, body[last:])
+}
+
+// MatchGlob reports whether a slash-separated relative path matches a glob
+// pattern. "**" matches any number of path segments, including none.
+func MatchGlob(pattern, relPath string, caseSensitive bool) bool {
+	if !caseSensitive {
+		pattern = strings.ToLower(pattern)
+		relPath = strings.ToLower(relPath)
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive ** segments
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], parts[0]); err != nil || !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		parts = parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// Glob walks root and returns paths (relative to root) matching any of the
+// brace-expanded patterns. The boolean result reports whether the result set
+// was truncated at maxResults.
+func Glob(root, pattern string, maxResults int, includeDirs, includeHidden, caseSensitive bool) ([]string, bool, error) {
+	if pattern == "" {
+		return nil, false, fmt.Errorf("pattern cannot be empty")
+	}
+
+	patterns := ExpandBraces(filepath.ToSlash(pattern))
+	var matches []string
+	truncated := false
+
+	err := filepath.WalkDir(root, func(walkPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if walkPath == root {
+			return nil
+		}
+
+		denied := SkipInWalk(walkPath, d.Type())
+		if !includeHidden && strings.HasPrefix(d.Name(), ".") || denied {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() && !includeDirs {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, walkPath)
+		if err != nil {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		for _, p := range patterns {
+			if MatchGlob(p, relPath, caseSensitive) {
+				if maxResults > 0 && len(matches) >= maxResults {
+					truncated = true
+					return filepath.SkipAll
+				}
+				matches = append(matches, relPath)
+				break
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return matches, truncated, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	return matches, truncated, nil
+}
+
+// SplitPatternList splits a comma-separated list of glob patterns. Commas
+// inside braces belong to the pattern and empty items are dropped.
+func SplitPatternList(list string) []string {
+	var patterns []string
+	depth := 0
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '{':
+				depth++
+				continue
+			case '}':
+				if depth > 0 {
+					depth--
+				}
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if pattern := strings.TrimSpace(list[start:i]); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+		start = i + 1
+	}
+	return patterns
+}
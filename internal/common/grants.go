@@ -0,0 +1,374 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// Kinds of temporary grants
+const (
+	GrantDirectory = "directory"
+	GrantURL       = "url"
+	GrantCommand   = "command"
+)
+
+// grantCommandOperators are the characters "*" in a command grant does not
+// match, so a grant cannot be stretched over a second command or further
+// arguments
+const grantCommandOperators = ";&|`$()<>\n \t"
+
+// grantURLPattern finds URLs in tool arguments
+var grantURLPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// temporaryGrant is a grant with the number of calls using it that have
+// not finished, which keep it in force after its last use is taken
+type temporaryGrant struct {
+	types.TemporaryGrant
+	inFlight int
+}
+
+var (
+	grantsMutex sync.Mutex
+	grants      = make(map[string]*temporaryGrant)
+)
+
+// GrantTemporaryAccess adds a directory, URL pattern or command pattern to
+// what is allowed for duration or maxUses tool calls; at least one of them
+// must be set
+func GrantTemporaryAccess(kind, value, session string, duration time.Duration, maxUses int) (types.TemporaryGrant, error) {
+	if duration < 0 || maxUses < 0 {
+		return types.TemporaryGrant{}, fmt.Errorf("duration and uses cannot be negative")
+	}
+	if duration == 0 && maxUses == 0 {
+		return types.TemporaryGrant{}, fmt.Errorf("a temporary grant needs a duration, a number of uses or both")
+	}
+
+	value = strings.TrimSpace(value)
+	switch kind {
+	case GrantDirectory:
+		if err := validateAllowedDirectory(value); err != nil {
+			return types.TemporaryGrant{}, err
+		}
+		normalized, _ := normalizeAllowedDirectory(value)
+		if entry, denied := IsPathDenied(globLiteralPrefix(normalized)); denied {
+			return types.TemporaryGrant{}, fmt.Errorf("%s is denied by %q, which a temporary grant cannot override", value, entry)
+		}
+		value = normalized
+	case GrantURL:
+		if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return types.TemporaryGrant{}, fmt.Errorf("URL pattern must start with http:// or https://")
+		}
+		if len(Get().AllowedURLPatterns) == 0 {
+			return types.TemporaryGrant{}, WithErrorCode(ErrorValidationFailed, fmt.Errorf("allowedURLPatterns is empty, so every URL is already allowed"))
+		}
+	case GrantCommand:
+		if value == "" {
+			return types.TemporaryGrant{}, fmt.Errorf("command pattern cannot be empty")
+		}
+	default:
+		return types.TemporaryGrant{}, fmt.Errorf("invalid grant kind %q: use %s, %s or %s", kind, GrantDirectory, GrantURL, GrantCommand)
+	}
+
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return types.TemporaryGrant{}, fmt.Errorf("failed to create grant ID: %v", err)
+	}
+	now := time.Now()
+	grant := types.TemporaryGrant{
+		ID:        "grant-" + hex.EncodeToString(id),
+		Kind:      kind,
+		Value:     value,
+		Session:   session,
+		GrantedAt: now,
+		MaxUses:   maxUses,
+	}
+	if duration > 0 {
+		expires := now.Add(duration)
+		grant.ExpiresAt = &expires
+	}
+
+	grantsMutex.Lock()
+	grants[grant.ID] = &temporaryGrant{TemporaryGrant: grant}
+	grantsMutex.Unlock()
+	return grant, nil
+}
+
+// RevokeTemporaryAccess removes a grant before it runs out
+func RevokeTemporaryAccess(id string) (types.TemporaryGrant, error) {
+	grantsMutex.Lock()
+	defer grantsMutex.Unlock()
+	grant, ok := grants[id]
+	if !ok {
+		return types.TemporaryGrant{}, fmt.Errorf("no temporary grant %s", id)
+	}
+	delete(grants, id)
+	return grant.TemporaryGrant, nil
+}
+
+// ListTemporaryGrants returns the grants in force, oldest first
+func ListTemporaryGrants() []types.TemporaryGrant {
+	pruneGrants()
+	grantsMutex.Lock()
+	defer grantsMutex.Unlock()
+
+	list := make([]types.TemporaryGrant, 0, len(grants))
+	for _, grant := range grants {
+		list = append(list, grant.TemporaryGrant)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].GrantedAt.Before(list[j].GrantedAt) })
+	return list
+}
+
+// pruneGrants removes expired grants, recording each in the audit log
+func pruneGrants() {
+	now := time.Now()
+	var expired []types.TemporaryGrant
+	grantsMutex.Lock()
+	for id, grant := range grants {
+		if grant.ExpiresAt != nil && !now.Before(*grant.ExpiresAt) {
+			expired = append(expired, grant.TemporaryGrant)
+			delete(grants, id)
+		}
+	}
+	grantsMutex.Unlock()
+
+	for _, grant := range expired {
+		recordGrantRevoked(grant, *grant.ExpiresAt, "expired")
+	}
+}
+
+// recordGrantRevoked records in the audit log that a grant ran out
+func recordGrantRevoked(grant types.TemporaryGrant, at time.Time, reason string) {
+	RecordAudit(types.AuditEntry{
+		Time:    at,
+		Session: grant.Session,
+		Tool:    "revoke_temporary_access",
+		Arguments: map[string]any{
+			"id": grant.ID, "kind": grant.Kind, "value": grant.Value, "uses": grant.Uses, "reason": reason,
+		},
+		Outcome: AuditOutcomeSuccess,
+	})
+}
+
+// grantedValues returns the values of the grants of a kind in force
+func grantedValues(kind string) []string {
+	pruneGrants()
+	grantsMutex.Lock()
+	defer grantsMutex.Unlock()
+
+	var values []string
+	for _, grant := range grants {
+		if grant.Kind == kind && grantUsable(grant) {
+			values = append(values, grant.Value)
+		}
+	}
+	return values
+}
+
+// grantUsable reports whether a grant has uses left or is being used by
+// a call that has not finished; callers hold grantsMutex
+func grantUsable(grant *temporaryGrant) bool {
+	return grant.MaxUses == 0 || grant.Uses < grant.MaxUses || grant.inFlight > 0
+}
+
+// matchWildcard matches text against a pattern in which "*" matches any
+// run of characters other than those in stop
+func matchWildcard(pattern, text, stop string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == '*' {
+			pattern = pattern[1:]
+			for i := 0; i <= len(text); i++ {
+				if matchWildcard(pattern, text[i:], stop) {
+					return true
+				}
+				if i < len(text) && strings.IndexByte(stop, text[i]) >= 0 {
+					return false
+				}
+			}
+			return false
+		}
+		if len(text) == 0 || pattern[0] != text[0] {
+			return false
+		}
+		pattern, text = pattern[1:], text[1:]
+	}
+	return len(text) == 0
+}
+
+// IsURLAllowed reports whether a URL matches an allowedURLPatterns entry or
+// a URL grant; every URL is allowed when allowedURLPatterns is empty
+func IsURLAllowed(url string) bool {
+	patterns := Get().AllowedURLPatterns
+	if len(patterns) == 0 {
+		return true
+	}
+	return matchesAnyURLPattern(url, patterns) || matchesAnyURLPattern(url, grantedValues(GrantURL))
+}
+
+// CommandGranted returns the command grant in force that matches command
+// whole. Its "*" matches neither shell operators nor whitespace, so each
+// "*" stands for part of a single word.
+func CommandGranted(command string) (types.TemporaryGrant, bool) {
+	pruneGrants()
+	command = strings.TrimSpace(SanitizeCommand(command))
+	grantsMutex.Lock()
+	defer grantsMutex.Unlock()
+
+	for _, grant := range grants {
+		if grant.Kind == GrantCommand && grantUsable(grant) && matchWildcard(grant.Value, command, grantCommandOperators) {
+			return grant.TemporaryGrant, true
+		}
+	}
+	return types.TemporaryGrant{}, false
+}
+
+// commandPatternsInForce returns the patterns command matches, compared as
+// MatchAllCommandPatterns does, leaving out those a command grant for it
+// covers. A grant covers only the patterns its own value matches, so
+// granting "rm -rf /tmp/build*" lifts a blocked "rm -rf" but no other
+// blocked pattern.
+func commandPatternsInForce(command string, patterns []string) (matched []string, tooDeep bool, grant *types.TemporaryGrant) {
+	matched, tooDeep = MatchAllCommandPatterns(command, patterns)
+	if found, ok := CommandGranted(command); ok {
+		grant = &found
+		covered, _ := MatchAllCommandPatterns(found.Value, matched)
+		matched = slices.DeleteFunc(matched, func(pattern string) bool {
+			return slices.Contains(covered, pattern)
+		})
+	}
+	return matched, tooDeep, grant
+}
+
+// BeginGrantUses takes a use of every grant a call of tool relies on,
+// judged from its arguments: a directory grant for a file outside the allowed
+// directories, a URL grant for a URL outside allowedURLPatterns and a
+// command grant for a command it matches. It returns the IDs of the
+// grants to pass to EndGrantUses once the call finishes.
+func BeginGrantUses(tool string, arguments map[string]any) []string {
+	pruneGrants()
+	config := Get()
+	allowedDirs := append(append([]string{}, config.AllowedDirectories...), workspaceDirectories()...)
+	files := ToolCallPaths(tool, arguments)
+	texts := grantArgumentStrings(arguments, nil)
+
+	grantsMutex.Lock()
+	defer grantsMutex.Unlock()
+
+	var used []string
+	for id, grant := range grants {
+		if grant.MaxUses > 0 && grant.Uses >= grant.MaxUses {
+			continue
+		}
+		if grantUsedBy(grant, allowedDirs, config.AllowedURLPatterns, files, texts) {
+			grant.Uses++
+			grant.inFlight++
+			used = append(used, id)
+		}
+	}
+	return used
+}
+
+// EndGrantUses finishes the uses BeginGrantUses took, revoking grants
+// whose last use was taken
+func EndGrantUses(ids []string) {
+	var exhausted []types.TemporaryGrant
+	grantsMutex.Lock()
+	for _, id := range ids {
+		grant, ok := grants[id]
+		if !ok {
+			continue
+		}
+		grant.inFlight--
+		if grant.MaxUses > 0 && grant.Uses >= grant.MaxUses && grant.inFlight == 0 {
+			exhausted = append(exhausted, grant.TemporaryGrant)
+			delete(grants, id)
+		}
+	}
+	grantsMutex.Unlock()
+
+	for _, grant := range exhausted {
+		recordGrantRevoked(grant, time.Now(), "uses exhausted")
+	}
+}
+
+// grantUsedBy reports whether a call with the given files and argument
+// strings relies on a grant rather than the allowed directories and URL
+// patterns; callers hold grantsMutex
+func grantUsedBy(grant *temporaryGrant, allowedDirs, urlPatterns, files, texts []string) bool {
+	switch grant.Kind {
+	case GrantDirectory:
+		for _, file := range files {
+			if matchAllowedDirectory(file, grant.Value) && !matchesAnyAllowedDirectory(file, allowedDirs) {
+				return true
+			}
+		}
+	case GrantURL:
+		for _, text := range texts {
+			for _, url := range grantURLPattern.FindAllString(text, -1) {
+				if matchWildcard(grant.Value, url, "") && !matchesAnyURLPattern(url, urlPatterns) {
+					return true
+				}
+			}
+		}
+	case GrantCommand:
+		for _, text := range texts {
+			if matchWildcard(grant.Value, strings.TrimSpace(SanitizeCommand(text)), grantCommandOperators) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAnyAllowedDirectory(path string, entries []string) bool {
+	for _, entry := range entries {
+		if matchAllowedDirectory(path, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyURLPattern(url string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchWildcard(pattern, url, "") {
+			return true
+		}
+	}
+	return false
+}
+
+// grantArgumentStrings collects the strings of tool arguments, looking
+// inside strings holding JSON arrays or objects, such as the commands of
+// execute_commands
+func grantArgumentStrings(value any, texts []string) []string {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, item := range v {
+			texts = grantArgumentStrings(item, texts)
+		}
+	case []any:
+		for _, item := range v {
+			texts = grantArgumentStrings(item, texts)
+		}
+	case string:
+		texts = append(texts, v)
+		if trimmed := strings.TrimSpace(v); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+			var decoded any
+			if json.Unmarshal([]byte(trimmed), &decoded) == nil {
+				texts = grantArgumentStrings(decoded, texts)
+			}
+		}
+	}
+	return texts
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the configuration the tests change out of the real home directory
+	home, err := os.MkdirTemp("", "jarvis-common")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// grantCommand grants a command pattern for the rest of the test
+func grantCommand(t *testing.T, pattern string) {
+	t.Helper()
+	grant, err := GrantTemporaryAccess(GrantCommand, pattern, "", time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { RevokeTemporaryAccess(grant.ID) })
+}
+
+func TestCommandGrantCoversOneWordPerWildcard(t *testing.T) {
+	grantCommand(t, "rm -rf /tmp/build*")
+
+	for command, blocked := range map[string]bool{
+		"rm -rf /tmp/build":          false,
+		"rm -rf /tmp/build-2":        false,
+		"rm -rf /tmp/build /":        true,
+		"rm -rf /tmp/build ~ /etc":   true,
+		"rm -rf /tmp/build;rm -rf /": true,
+		"rm -rf /tmp/build\trm":      true,
+	} {
+		if got := IsCommandBlocked(command); got != blocked {
+			t.Errorf("IsCommandBlocked(%q) = %v, want %v", command, got, blocked)
+		}
+	}
+}
+
+func TestCommandGrantLiftsOnlyPatternsItMatches(t *testing.T) {
+	grantCommand(t, "rm * /tmp/build*")
+
+	if IsCommandBlocked("rm -f /tmp/build") {
+		t.Error("a command the grant allows is blocked")
+	}
+	// The grant does not name -rf, so the blocked "rm -rf" still applies
+	if !IsCommandBlocked("rm -rf /tmp/build") {
+		t.Error("a granted command escaped a blocked pattern the grant does not cover")
+	}
+	if check := CheckCommandPolicy("rm -rf /tmp/build"); check.Decision != PolicyBlocked {
+		t.Errorf("CheckCommandPolicy decided %s: %s", check.Decision, check.Reason)
+	}
+}
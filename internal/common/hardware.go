@@ -0,0 +1,316 @@
+package common
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+
+	"jarvis/internal/types"
+)
+
+// hardwareToolTimeout bounds each GPU and battery tool GetSystemInfo runs
+const hardwareToolTimeout = 10 * time.Second
+
+// runHardwareTool runs a reporting tool when it is installed. ok is false
+// when it is not, which is not worth a warning.
+func runHardwareTool(ctx context.Context, name string, args ...string) (output []byte, ok bool, err error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, false, nil
+	}
+	toolCtx, cancel := context.WithTimeout(ctx, hardwareToolTimeout)
+	defer cancel()
+	output, err = exec.CommandContext(toolCtx, name, args...).Output()
+	return output, true, err
+}
+
+// collectGPUs asks every GPU tool that is installed about its GPUs
+func collectGPUs(ctx context.Context, warn func(part string, err error)) []types.GPUInfo {
+	var gpus []types.GPUInfo
+
+	if output, ok, err := runHardwareTool(ctx, "nvidia-smi",
+		"--query-gpu=index,name,driver_version,memory.total,memory.used,utilization.gpu,temperature.gpu,power.draw",
+		"--format=csv,noheader,nounits"); err != nil {
+		warn("NVIDIA GPUs", err)
+	} else if ok {
+		found, err := parseNvidiaSMI(output)
+		if err != nil {
+			warn("NVIDIA GPUs", err)
+		}
+		gpus = append(gpus, found...)
+	}
+
+	if output, ok, err := runHardwareTool(ctx, "rocm-smi", "--showproductname", "--showmeminfo", "vram", "--showuse", "--showtemp", "--showpower", "--json"); err != nil {
+		warn("AMD GPUs", err)
+	} else if ok {
+		found, err := parseROCmSMI(output)
+		if err != nil {
+			warn("AMD GPUs", err)
+		}
+		gpus = append(gpus, found...)
+	}
+
+	if runtime.GOOS == "darwin" {
+		if output, ok, err := runHardwareTool(ctx, "system_profiler", "-json", "SPDisplaysDataType"); err != nil {
+			warn("GPUs", err)
+		} else if ok {
+			found, err := parseSystemProfilerGPUs(output)
+			if err != nil {
+				warn("GPUs", err)
+			}
+			gpus = append(gpus, found...)
+		}
+	}
+	return gpus
+}
+
+// parseNvidiaSMI reads nvidia-smi CSV output, where memory is in MiB and
+// unsupported fields are "[N/A]"
+func parseNvidiaSMI(output []byte) ([]types.GPUInfo, error) {
+	reader := csv.NewReader(strings.NewReader(string(output)))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unreadable nvidia-smi output: %v", err)
+	}
+
+	var gpus []types.GPUInfo
+	for _, record := range records {
+		if len(record) < 8 {
+			continue
+		}
+		index, _ := strconv.Atoi(record[0])
+		gpus = append(gpus, types.GPUInfo{
+			Index:              index,
+			Vendor:             "NVIDIA",
+			Model:              record[1],
+			Driver:             record[2],
+			MemoryTotal:        uint64(hardwareNumber(record[3]) * 1024 * 1024),
+			MemoryUsed:         uint64(hardwareNumber(record[4]) * 1024 * 1024),
+			UtilizationPercent: hardwareNumber(record[5]),
+			TemperatureC:       hardwareNumber(record[6]),
+			PowerWatts:         hardwareNumber(record[7]),
+			Source:             "nvidia-smi",
+		})
+	}
+	return gpus, nil
+}
+
+// rocmCardPattern matches the card keys of rocm-smi JSON output
+var rocmCardPattern = regexp.MustCompile(`^card(\d+)$`)
+
+// parseROCmSMI reads rocm-smi JSON output. Its field names differ between
+// versions, so fields are found by the words they contain.
+func parseROCmSMI(output []byte) ([]types.GPUInfo, error) {
+	var cards map[string]map[string]string
+	if err := json.Unmarshal(output, &cards); err != nil {
+		return nil, fmt.Errorf("unreadable rocm-smi output: %v", err)
+	}
+
+	var gpus []types.GPUInfo
+	for card, fields := range cards {
+		m := rocmCardPattern.FindStringSubmatch(card)
+		if m == nil {
+			continue
+		}
+		gpu := types.GPUInfo{Vendor: "AMD", Source: "rocm-smi"}
+		gpu.Index, _ = strconv.Atoi(m[1])
+		for key, value := range fields {
+			lower := strings.ToLower(key)
+			switch {
+			case lower == "card series" || (lower == "card model" && gpu.Model == ""):
+				gpu.Model = value
+			case strings.Contains(lower, "vram total used memory"):
+				gpu.MemoryUsed = uint64(hardwareNumber(value))
+			case strings.Contains(lower, "vram total memory"):
+				gpu.MemoryTotal = uint64(hardwareNumber(value))
+			case strings.HasPrefix(lower, "gpu use"):
+				gpu.UtilizationPercent = hardwareNumber(value)
+			case strings.HasPrefix(lower, "temperature") && (strings.Contains(lower, "edge") || gpu.TemperatureC == 0):
+				gpu.TemperatureC = hardwareNumber(value)
+			case strings.Contains(lower, "power") && strings.Contains(lower, "(w)"):
+				gpu.PowerWatts = hardwareNumber(value)
+			}
+		}
+		gpus = append(gpus, gpu)
+	}
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].Index < gpus[j].Index })
+	return gpus, nil
+}
+
+// parseSystemProfilerGPUs reads the SPDisplaysDataType report of macOS
+func parseSystemProfilerGPUs(output []byte) ([]types.GPUInfo, error) {
+	var report struct {
+		Displays []map[string]any `json:"SPDisplaysDataType"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("unreadable system_profiler output: %v", err)
+	}
+
+	text := func(fields map[string]any, key string) string {
+		value, _ := fields[key].(string)
+		return value
+	}
+	var gpus []types.GPUInfo
+	for i, fields := range report.Displays {
+		gpu := types.GPUInfo{Index: i, Model: text(fields, "sppci_model"), Source: "system_profiler"}
+		// Vendors are reported as "sppci_vendor_Apple" or "NVIDIA (0x10de)"
+		vendor := strings.TrimPrefix(text(fields, "sppci_vendor"), "sppci_vendor_")
+		gpu.Vendor, _, _ = strings.Cut(vendor, " (")
+		gpu.Cores, _ = strconv.Atoi(text(fields, "sppci_cores"))
+		for _, key := range []string{"spdisplays_vram", "spdisplays_vram_shared"} {
+			if vram := text(fields, key); vram != "" && gpu.MemoryTotal == 0 {
+				gpu.MemoryTotal = parseHardwareSize(vram)
+			}
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// collectTemperatures reads the temperature sensors gopsutil knows about.
+// Linux reports sensors it could not read as warnings next to the ones it
+// could, so readings are kept whenever there are any.
+func collectTemperatures(ctx context.Context, warn func(part string, err error)) []types.SensorTemp {
+	stats, err := sensors.TemperaturesWithContext(ctx)
+	if err != nil && len(stats) == 0 {
+		if runtime.GOOS == "linux" {
+			warn("temperature sensors", err)
+		}
+		return nil
+	}
+
+	var temps []types.SensorTemp
+	for _, stat := range stats {
+		if stat.Temperature == 0 {
+			continue
+		}
+		temps = append(temps, types.SensorTemp{
+			Sensor:   stat.SensorKey,
+			Current:  stat.Temperature,
+			High:     stat.High,
+			Critical: stat.Critical,
+		})
+	}
+	sort.Slice(temps, func(i, j int) bool { return temps[i].Sensor < temps[j].Sensor })
+	return temps
+}
+
+// collectBatteries reads battery state from /sys/class/power_supply on
+// Linux and pmset on macOS; machines without batteries return none
+func collectBatteries(ctx context.Context, warn func(part string, err error)) []types.Battery {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxBatteries()
+	case "darwin":
+		output, ok, err := runHardwareTool(ctx, "pmset", "-g", "batt")
+		if err != nil {
+			warn("battery", err)
+		}
+		if !ok || err != nil {
+			return nil
+		}
+		return parsePmsetBatteries(string(output))
+	}
+	return nil
+}
+
+// linuxBatteries reads each power supply of type Battery
+func linuxBatteries() []types.Battery {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	var batteries []types.Battery
+	for _, dir := range supplies {
+		read := func(name string) string {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(string(data))
+		}
+		if read("type") != "Battery" || read("present") == "0" {
+			continue
+		}
+
+		battery := types.Battery{Name: filepath.Base(dir), Status: strings.ToLower(read("status"))}
+		battery.Percent = hardwareNumber(read("capacity"))
+		battery.CycleCount, _ = strconv.Atoi(read("cycle_count"))
+
+		// Batteries report either energy (µWh) and power (µW) or charge
+		// (µAh) and current (µA)
+		now, full, design, rate := read("energy_now"), read("energy_full"), read("energy_full_design"), read("power_now")
+		if now == "" {
+			now, full, design, rate = read("charge_now"), read("charge_full"), read("charge_full_design"), read("current_now")
+		}
+		if d := hardwareNumber(design); d > 0 && hardwareNumber(full) > 0 {
+			battery.HealthPercent = math.Round(hardwareNumber(full)/d*1000) / 10
+		}
+		if r := hardwareNumber(rate); r > 0 {
+			switch battery.Status {
+			case "discharging":
+				battery.RemainingMinutes = int(hardwareNumber(now) / r * 60)
+			case "charging":
+				battery.RemainingMinutes = int((hardwareNumber(full) - hardwareNumber(now)) / r * 60)
+			}
+		}
+		batteries = append(batteries, battery)
+	}
+	return batteries
+}
+
+// pmsetBatteryPattern matches battery lines of "pmset -g batt" such as
+// " -InternalBattery-0 (id=1234)	85%; discharging; 4:12 remaining present: true"
+var pmsetBatteryPattern = regexp.MustCompile(`-(\S+)\s+\(id=\d+\)\s+(\d+)%;\s*([^;]+);\s*(?:(\d+):(\d+) remaining)?`)
+
+func parsePmsetBatteries(output string) []types.Battery {
+	var batteries []types.Battery
+	for _, m := range pmsetBatteryPattern.FindAllStringSubmatch(output, -1) {
+		battery := types.Battery{Name: m[1], Status: strings.TrimSpace(m[3])}
+		battery.Percent, _ = strconv.ParseFloat(m[2], 64)
+		if battery.Status == "charged" {
+			battery.Status = "full"
+		}
+		if m[4] != "" {
+			hours, _ := strconv.Atoi(m[4])
+			minutes, _ := strconv.Atoi(m[5])
+			battery.RemainingMinutes = hours*60 + minutes
+		}
+		batteries = append(batteries, battery)
+	}
+	return batteries
+}
+
+// hardwareNumber parses a number reported by a hardware tool, ignoring
+// units after it; values such as "[N/A]" give 0
+func hardwareNumber(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	number, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// parseHardwareSize parses sizes such as "8 GB" or "1536 MB" into bytes
+func parseHardwareSize(value string) uint64 {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return 0
+	}
+	units := map[string]float64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40}
+	return uint64(hardwareNumber(fields[0]) * units[strings.ToUpper(fields[1])])
+}
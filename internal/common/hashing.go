@@ -0,0 +1,227 @@
+package common
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+)
+
+// Hash algorithms of NewHash. MD5 and SHA-1 only serve to compare against
+// published digests; xxHash is the fast choice for detecting identical
+// files.
+const (
+	HashSHA256 = "sha256"
+	HashSHA1   = "sha1"
+	HashMD5    = "md5"
+	HashXXHash = "xxhash"
+)
+
+// HashAlgorithms lists the hash algorithms
+var HashAlgorithms = []string{HashSHA256, HashSHA1, HashMD5, HashXXHash}
+
+// NewHash returns a hash of an algorithm. xxhash is the 64-bit XXH64 with
+// seed 0.
+func NewHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashMD5:
+		return md5.New(), nil
+	case HashXXHash, "xxh64":
+		return newXXHash64(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm %q; use %s", algorithm, strings.Join(HashAlgorithms, ", "))
+}
+
+// HashReader reads r to the end and returns its hex-encoded digest in
+// each algorithm, computed in a single pass, with the number of bytes read
+func HashReader(r io.Reader, algorithms ...string) (map[string]string, int64, error) {
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		h, err := NewHash(algorithm)
+		if err != nil {
+			return nil, 0, err
+		}
+		hashes[algorithm] = h
+		writers = append(writers, h)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		return nil, n, err
+	}
+
+	digests := make(map[string]string, len(hashes))
+	for algorithm, h := range hashes {
+		digests[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digests, n, nil
+}
+
+// HashFile returns the hex-encoded digest of a file in each algorithm
+func HashFile(filePath string, algorithms ...string) (map[string]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	digests, _, err := HashReader(file, algorithms...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return digests, nil
+}
+
+// ParseChecksum splits an expected checksum into its algorithm and
+// lowercased hex digest. The algorithm is taken from an "algorithm:"
+// prefix, or else from the length of the digest.
+func ParseChecksum(checksum string) (string, string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	algorithm, digest, prefixed := strings.Cut(checksum, ":")
+	if !prefixed {
+		digest = checksum
+		switch len(digest) {
+		case sha256.Size * 2:
+			algorithm = HashSHA256
+		case sha1.Size * 2:
+			algorithm = HashSHA1
+		case md5.Size * 2:
+			algorithm = HashMD5
+		case 16:
+			algorithm = HashXXHash
+		default:
+			return "", "", fmt.Errorf("cannot tell the algorithm of checksum %q; prefix it like sha256:", checksum)
+		}
+	}
+	if _, err := NewHash(algorithm); err != nil {
+		return "", "", err
+	}
+	if _, err := hex.DecodeString(digest); err != nil || digest == "" {
+		return "", "", fmt.Errorf("checksum %q is not a hex digest", checksum)
+	}
+	return algorithm, digest, nil
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxHash64 is a streaming XXH64 hash with seed 0
+type xxHash64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	buf            [32]byte
+	n              int
+}
+
+func newXXHash64() *xxHash64 {
+	h := &xxHash64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxHash64) Reset() {
+	// The seed accumulators wrap around, which constant arithmetic refuses
+	h.v1, h.v2, h.v3, h.v4 = xxPrime1, xxPrime2, 0, 0
+	h.v1 += xxPrime2
+	h.v4 -= xxPrime1
+	h.total, h.n = 0, 0
+}
+
+func (h *xxHash64) Size() int { return 8 }
+
+func (h *xxHash64) BlockSize() int { return 32 }
+
+func (h *xxHash64) Write(p []byte) (int, error) {
+	written := len(p)
+	h.total += uint64(written)
+
+	if h.n+len(p) < 32 {
+		h.n += copy(h.buf[h.n:], p)
+		return written, nil
+	}
+	if h.n > 0 {
+		filled := copy(h.buf[h.n:], p)
+		h.stripe(h.buf[:])
+		p, h.n = p[filled:], 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		h.stripe(p)
+	}
+	h.n = copy(h.buf[:], p)
+	return written, nil
+}
+
+func (h *xxHash64) stripe(p []byte) {
+	h.v1 = xxRound(h.v1, binary.LittleEndian.Uint64(p[0:8]))
+	h.v2 = xxRound(h.v2, binary.LittleEndian.Uint64(p[8:16]))
+	h.v3 = xxRound(h.v3, binary.LittleEndian.Uint64(p[16:24]))
+	h.v4 = xxRound(h.v4, binary.LittleEndian.Uint64(p[24:32]))
+}
+
+func (h *xxHash64) Sum64() uint64 {
+	var sum uint64
+	if h.total >= 32 {
+		sum = bits.RotateLeft64(h.v1, 1) + bits.RotateLeft64(h.v2, 7) + bits.RotateLeft64(h.v3, 12) + bits.RotateLeft64(h.v4, 18)
+		sum = xxMergeRound(sum, h.v1)
+		sum = xxMergeRound(sum, h.v2)
+		sum = xxMergeRound(sum, h.v3)
+		sum = xxMergeRound(sum, h.v4)
+	} else {
+		sum = xxPrime5
+	}
+	sum += h.total
+
+	p := h.buf[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		sum ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		sum = bits.RotateLeft64(sum, 27)*xxPrime1 + xxPrime4
+	}
+	if len(p) >= 4 {
+		sum ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		sum = bits.RotateLeft64(sum, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		sum ^= uint64(b) * xxPrime5
+		sum = bits.RotateLeft64(sum, 11) * xxPrime1
+	}
+
+	sum ^= sum >> 33
+	sum *= xxPrime2
+	sum ^= sum >> 29
+	sum *= xxPrime3
+	sum ^= sum >> 32
+	return sum
+}
+
+func (h *xxHash64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, value uint64) uint64 {
+	acc ^= xxRound(0, value)
+	return acc*xxPrime1 + xxPrime4
+}
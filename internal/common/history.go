@@ -0,0 +1,219 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+const (
+	// maxHistoryEntries bounds the command history; the file is compacted
+	// to this many entries once it holds twice as many
+	maxHistoryEntries = 1000
+	// maxHistoryOutputBytes is how much output each entry keeps, split
+	// between the start and the end of the output
+	maxHistoryOutputBytes = 4096
+)
+
+var (
+	historyMutex   sync.Mutex
+	historyLoaded  bool
+	historyNextID  = 1
+	historyEntries int
+)
+
+// historyPath returns the command history file, stored next to the config
+// file as JSON lines
+func historyPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-history.jsonl")
+}
+
+// CommandHistoryFilter selects entries returned by ListCommandHistory
+type CommandHistoryFilter struct {
+	Since time.Time
+	Until time.Time
+	// Status is "success", "failure" or empty for both
+	Status string
+	// Query matches the command or output, ignoring case
+	Query string
+	Tool  string
+	Limit int // <= 0 means unlimited
+}
+
+// TruncateHistoryOutput shortens output to the start and end that fit in
+// the history, reporting whether anything was cut
+func TruncateHistoryOutput(output string) (string, bool) {
+	buf := NewOutputBuffer(maxHistoryOutputBytes)
+	buf.Write([]byte(output))
+	return buf.String(), buf.Truncated()
+}
+
+// CommandExitCode returns the exit code for the error returned by running
+// a command: 0 on success, -1 when it did not exit normally
+func CommandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// RecordCommand appends a finished command to the persistent history.
+// History is best effort: failures to write it never fail the command.
+func RecordCommand(entry types.CommandHistoryEntry) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	loadHistoryState()
+
+	entry.ID = historyNextID
+	historyNextID++
+	if entry.WorkingDir == "" {
+		entry.WorkingDir, _ = os.Getwd()
+	}
+	if entry.WorkingDir != "" {
+		if absPath, err := filepath.Abs(entry.WorkingDir); err == nil {
+			entry.WorkingDir = absPath
+		}
+	}
+	entry.Command, entry.Output = RedactSecrets(entry.Command), RedactSecrets(entry.Output)
+	if !entry.Truncated {
+		entry.Output, entry.Truncated = TruncateHistoryOutput(entry.Output)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := EnsureDir(filepath.Dir(historyPath())); err != nil {
+		return
+	}
+	file, err := os.OpenFile(historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	_, err = file.Write(append(data, '\n'))
+	file.Close()
+	if err != nil {
+		return
+	}
+
+	historyEntries++
+	if historyEntries >= 2*maxHistoryEntries {
+		compactHistory()
+	}
+}
+
+// loadHistoryState reads the next ID and entry count from the history
+// file on first use; callers hold historyMutex
+func loadHistoryState() {
+	if historyLoaded {
+		return
+	}
+	historyLoaded = true
+
+	entries, err := readHistory()
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	historyEntries = len(entries)
+	historyNextID = entries[len(entries)-1].ID + 1
+}
+
+// compactHistory keeps the newest maxHistoryEntries entries; callers hold
+// historyMutex
+func compactHistory() {
+	entries, err := readHistory()
+	if err != nil {
+		return
+	}
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := WriteFileAtomic(historyPath(), buf.Bytes(), 0600); err == nil {
+		historyEntries = len(entries)
+	}
+}
+
+// readHistory returns every entry in the history file, oldest first.
+// Lines that cannot be parsed, such as a partially written last line, are
+// skipped.
+func readHistory() ([]types.CommandHistoryEntry, error) {
+	file, err := os.Open(historyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []types.CommandHistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry types.CommandHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ListCommandHistory returns recorded commands matching filter, newest
+// first
+func ListCommandHistory(filter CommandHistoryFilter) ([]types.CommandHistoryEntry, error) {
+	historyMutex.Lock()
+	entries, err := readHistory()
+	historyMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.ToLower(filter.Query)
+	var matched []types.CommandHistoryEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		switch {
+		case !filter.Since.IsZero() && entry.StartedAt.Before(filter.Since):
+			continue
+		case !filter.Until.IsZero() && entry.StartedAt.After(filter.Until):
+			continue
+		case filter.Status == "success" && entry.ExitCode != 0:
+			continue
+		case filter.Status == "failure" && entry.ExitCode == 0:
+			continue
+		case filter.Tool != "" && entry.Tool != filter.Tool:
+			continue
+		case query != "" && !strings.Contains(strings.ToLower(entry.Command), query) && !strings.Contains(strings.ToLower(entry.Output), query):
+			continue
+		}
+		matched = append(matched, entry)
+		if filter.Limit > 0 && len(matched) >= filter.Limit {
+			break
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,676 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// maxEXIFBytes bounds the metadata read from an image: a PNG or WebP EXIF
+// chunk, or the start of a TIFF file, whose tags usually come before the
+// image data. Chunk lengths come from the file and are never trusted.
+const maxEXIFBytes = 16 * 1024 * 1024
+
+// GetImageInfo returns dimensions, format, color depth and EXIF data of a
+// local image. JPEG, PNG, GIF, BMP, WebP and TIFF files are recognized.
+func GetImageInfo(path string, includeAllTags bool) (*types.ImageInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 32)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	header = header[:n]
+
+	info := &types.ImageInfo{
+		Path:     path,
+		Format:   sniffImageFormat(header),
+		FileSize: stat.Size(),
+	}
+
+	var exifData []byte
+	switch info.Format {
+	case "jpeg", "gif":
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		config, _, err := image.DecodeConfig(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		info.Width, info.Height = config.Width, config.Height
+		info.ColorModel, info.BitDepth, info.Channels = describeColorModel(config.ColorModel)
+		if info.Format == "jpeg" {
+			exifData, err = findJPEGExif(file)
+		}
+	case "png":
+		if len(header) < 26 {
+			return nil, fmt.Errorf("truncated PNG header")
+		}
+		info.Width = int(binary.BigEndian.Uint32(header[16:20]))
+		info.Height = int(binary.BigEndian.Uint32(header[20:24]))
+		info.BitDepth = int(header[24])
+		info.ColorModel, info.Channels = describePNGColorType(header[25])
+		exifData, err = findPNGExif(file)
+	case "bmp":
+		if len(header) < 30 {
+			return nil, fmt.Errorf("truncated BMP header")
+		}
+		info.Width = int(int32(binary.LittleEndian.Uint32(header[18:22])))
+		info.Height = int(int32(binary.LittleEndian.Uint32(header[22:26])))
+		if info.Height < 0 {
+			info.Height = -info.Height
+		}
+		bitCount := int(binary.LittleEndian.Uint16(header[28:30]))
+		info.ColorModel = fmt.Sprintf("%d-bit", bitCount)
+		if bitCount >= 24 {
+			info.BitDepth, info.Channels = 8, bitCount/8
+		} else {
+			info.BitDepth, info.Channels = bitCount, 1
+		}
+	case "webp":
+		if err := readWebPDimensions(header, info); err != nil {
+			return nil, err
+		}
+		exifData, err = findWebPExif(file)
+	case "tiff":
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		exifData, err = io.ReadAll(io.LimitReader(file, maxEXIFBytes))
+	default:
+		return nil, fmt.Errorf("unsupported or unrecognized image format")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	if len(exifData) > 0 {
+		exif, fields, err := parseEXIF(exifData, includeAllTags)
+		if err == nil {
+			if info.Format == "tiff" {
+				info.Width, info.Height = fields.width, fields.height
+				info.BitDepth, info.Channels = fields.bitsPerSample, fields.samplesPerPixel
+			}
+			info.EXIF = exif
+		} else if info.Format == "tiff" {
+			return nil, fmt.Errorf("failed to parse TIFF: %w", err)
+		}
+	}
+
+	return info, nil
+}
+
+func sniffImageFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg"
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return "gif"
+	case bytes.HasPrefix(header, []byte("BM")):
+		return "bmp"
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return "tiff"
+	default:
+		return "unknown"
+	}
+}
+
+func describeColorModel(model color.Model) (string, int, int) {
+	if palette, ok := model.(color.Palette); ok {
+		depth := bits.Len(uint(len(palette) - 1))
+		if depth == 0 {
+			depth = 1
+		}
+		return fmt.Sprintf("Paletted (%d colors)", len(palette)), depth, 1
+	}
+
+	switch model {
+	case color.RGBAModel:
+		return "RGBA", 8, 4
+	case color.NRGBAModel:
+		return "NRGBA", 8, 4
+	case color.RGBA64Model:
+		return "RGBA64", 16, 4
+	case color.NRGBA64Model:
+		return "NRGBA64", 16, 4
+	case color.GrayModel:
+		return "Gray", 8, 1
+	case color.Gray16Model:
+		return "Gray16", 16, 1
+	case color.YCbCrModel:
+		return "YCbCr", 8, 3
+	case color.NYCbCrAModel:
+		return "NYCbCrA", 8, 4
+	case color.CMYKModel:
+		return "CMYK", 8, 4
+	default:
+		return "unknown", 0, 0
+	}
+}
+
+func describePNGColorType(colorType byte) (string, int) {
+	switch colorType {
+	case 0:
+		return "Gray", 1
+	case 2:
+		return "RGB", 3
+	case 3:
+		return "Paletted", 1
+	case 4:
+		return "Gray+Alpha", 2
+	case 6:
+		return "RGBA", 4
+	default:
+		return "unknown", 0
+	}
+}
+
+func readWebPDimensions(header []byte, info *types.ImageInfo) error {
+	if len(header) < 30 {
+		return fmt.Errorf("truncated WebP header")
+	}
+
+	switch string(header[12:16]) {
+	case "VP8X":
+		info.Width = int(uint32(header[24])|uint32(header[25])<<8|uint32(header[26])<<16) + 1
+		info.Height = int(uint32(header[27])|uint32(header[28])<<8|uint32(header[29])<<16) + 1
+		info.ColorModel = "RGB"
+		if header[20]&0x10 != 0 {
+			info.ColorModel = "RGBA"
+		}
+	case "VP8 ":
+		info.Width = int(binary.LittleEndian.Uint16(header[26:28]) & 0x3FFF)
+		info.Height = int(binary.LittleEndian.Uint16(header[28:30]) & 0x3FFF)
+		info.ColorModel = "YCbCr"
+	case "VP8L":
+		b := binary.LittleEndian.Uint32(header[21:25])
+		info.Width = int(b&0x3FFF) + 1
+		info.Height = int((b>>14)&0x3FFF) + 1
+		info.ColorModel = "RGBA"
+	default:
+		return fmt.Errorf("unrecognized WebP chunk %q", header[12:16])
+	}
+
+	info.BitDepth = 8
+	info.Channels = len(info.ColorModel)
+	if info.ColorModel == "YCbCr" {
+		info.Channels = 3
+	}
+	return nil
+}
+
+// findJPEGExif returns the TIFF payload of the APP1 Exif segment
+func findJPEGExif(file *os.File) ([]byte, error) {
+	if _, err := file.Seek(2, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	marker := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(file, marker); err != nil {
+			return nil, nil
+		}
+		if marker[0] != 0xFF {
+			return nil, nil
+		}
+		// Start of scan or end of image: no metadata segments follow
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(marker[2:4])) - 2
+		if length < 0 {
+			return nil, nil
+		}
+		if marker[1] != 0xE1 {
+			if _, err := file.Seek(int64(length), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(file, segment); err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+// findPNGExif returns the contents of the eXIf chunk
+func findPNGExif(file *os.File) ([]byte, error) {
+	if _, err := file.Seek(8, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return nil, nil
+		}
+		length := int64(binary.BigEndian.Uint32(chunk[0:4]))
+		switch string(chunk[4:8]) {
+		case "eXIf":
+			return readEXIFChunk(file, length)
+		case "IEND":
+			return nil, nil
+		}
+		if _, err := file.Seek(length+4, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// findWebPExif returns the contents of the RIFF EXIF chunk
+func findWebPExif(file *os.File) ([]byte, error) {
+	if _, err := file.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return nil, nil
+		}
+		length := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if string(chunk[0:4]) == "EXIF" {
+			data, err := readEXIFChunk(file, length)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.TrimPrefix(data, []byte("Exif\x00\x00")), nil
+		}
+		// Chunks are padded to an even size
+		if _, err := file.Seek(length+length%2, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readEXIFChunk reads an EXIF chunk of length bytes at the current offset
+// of file, refusing lengths past the end of the file or over maxEXIFBytes
+func readEXIFChunk(file *os.File, length int64) ([]byte, error) {
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if length > stat.Size()-offset {
+		return nil, fmt.Errorf("EXIF chunk of %d bytes runs past the end of the file", length)
+	}
+	if length > maxEXIFBytes {
+		return nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("EXIF chunk of %s exceeds the limit of %s", FormatBytes(length), FormatBytes(maxEXIFBytes)))
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// EXIF tags exposed as named fields or included in the full tag listing
+var exifTagNames = map[uint16]string{
+	0x0100: "ImageWidth",
+	0x0101: "ImageLength",
+	0x0102: "BitsPerSample",
+	0x0103: "Compression",
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x011A: "XResolution",
+	0x011B: "YResolution",
+	0x0115: "SamplesPerPixel",
+	0x0128: "ResolutionUnit",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x8298: "Copyright",
+	0x829A: "ExposureTime",
+	0x829D: "FNumber",
+	0x8822: "ExposureProgram",
+	0x8827: "ISOSpeedRatings",
+	0x9000: "ExifVersion",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x9010: "OffsetTime",
+	0x9011: "OffsetTimeOriginal",
+	0x9201: "ShutterSpeedValue",
+	0x9202: "ApertureValue",
+	0x9204: "ExposureBiasValue",
+	0x9207: "MeteringMode",
+	0x9209: "Flash",
+	0x920A: "FocalLength",
+	0xA001: "ColorSpace",
+	0xA002: "PixelXDimension",
+	0xA003: "PixelYDimension",
+	0xA402: "ExposureMode",
+	0xA403: "WhiteBalance",
+	0xA405: "FocalLengthIn35mmFilm",
+	0xA433: "LensMake",
+	0xA434: "LensModel",
+}
+
+var gpsTagNames = map[uint16]string{
+	0x0000: "GPSVersionID",
+	0x0001: "GPSLatitudeRef",
+	0x0002: "GPSLatitude",
+	0x0003: "GPSLongitudeRef",
+	0x0004: "GPSLongitude",
+	0x0005: "GPSAltitudeRef",
+	0x0006: "GPSAltitude",
+	0x0007: "GPSTimeStamp",
+	0x0012: "GPSMapDatum",
+	0x001D: "GPSDateStamp",
+}
+
+const (
+	exifIFDPointer = 0x8769
+	gpsIFDPointer  = 0x8825
+)
+
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiffImageFields holds the image structure tags of a TIFF file
+type tiffImageFields struct {
+	width           int
+	height          int
+	bitsPerSample   int
+	samplesPerPixel int
+}
+
+// parseEXIF decodes a TIFF-structured EXIF block
+func parseEXIF(data []byte, includeAllTags bool) (*types.ImageEXIF, *tiffImageFields, error) {
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("EXIF data too short")
+	}
+
+	var order binary.ByteOrder
+	switch string(data[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, fmt.Errorf("invalid TIFF byte order")
+	}
+	if order.Uint16(data[2:4]) != 42 {
+		return nil, nil, fmt.Errorf("invalid TIFF marker")
+	}
+
+	ifd0, err := readTIFFIFD(data, order, order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exifIFD := map[uint16]tiffEntry{}
+	if entry, ok := ifd0[exifIFDPointer]; ok {
+		if exifIFD, err = readTIFFIFD(data, order, entry.uint(0)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gpsIFD := map[uint16]tiffEntry{}
+	if entry, ok := ifd0[gpsIFDPointer]; ok {
+		if gpsIFD, err = readTIFFIFD(data, order, entry.uint(0)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fields := &tiffImageFields{
+		width:           int(ifd0[0x0100].uint(0)),
+		height:          int(ifd0[0x0101].uint(0)),
+		bitsPerSample:   int(ifd0[0x0102].uint(0)),
+		samplesPerPixel: int(ifd0[0x0115].uint(0)),
+	}
+
+	exif := &types.ImageEXIF{
+		Make:              ifd0[0x010F].string(),
+		Model:             ifd0[0x0110].string(),
+		Software:          ifd0[0x0131].string(),
+		Artist:            ifd0[0x013B].string(),
+		Copyright:         ifd0[0x8298].string(),
+		Orientation:       int(ifd0[0x0112].uint(0)),
+		DateTime:          ifd0[0x0132].string(),
+		LensModel:         exifIFD[0xA434].string(),
+		DateTimeOriginal:  exifIFD[0x9003].string(),
+		DateTimeDigitized: exifIFD[0x9004].string(),
+		FNumber:           roundTo(exifIFD[0x829D].float(0), 2),
+		ISO:               int(exifIFD[0x8827].uint(0)),
+		FocalLength:       roundTo(exifIFD[0x920A].float(0), 2),
+	}
+
+	if entry, ok := exifIFD[0x829A]; ok && entry.count > 0 {
+		exif.ExposureTime = formatExposureTime(entry)
+	}
+
+	if lat, ok := gpsIFD[0x0002]; ok && lat.count >= 3 {
+		if lon, ok := gpsIFD[0x0004]; ok && lon.count >= 3 {
+			gps := &types.ImageGPS{
+				Latitude:  roundTo(gpsCoordinate(lat, gpsIFD[0x0001].string(), "S"), 7),
+				Longitude: roundTo(gpsCoordinate(lon, gpsIFD[0x0003].string(), "W"), 7),
+			}
+			if alt, ok := gpsIFD[0x0006]; ok && alt.count > 0 {
+				altitude := roundTo(alt.float(0), 2)
+				if ref, ok := gpsIFD[0x0005]; ok && ref.uint(0) == 1 {
+					altitude = -altitude
+				}
+				gps.Altitude = &altitude
+			}
+			if ts, ok := gpsIFD[0x0007]; ok && ts.count >= 3 {
+				clock := fmt.Sprintf("%02d:%02d:%02d UTC", int(ts.float(0)), int(ts.float(1)), int(ts.float(2)))
+				if date := gpsIFD[0x001D].string(); date != "" {
+					clock = date + " " + clock
+				}
+				gps.Timestamp = clock
+			}
+			exif.GPS = gps
+		}
+	}
+
+	if includeAllTags {
+		exif.Tags = make(map[string]string)
+		addTIFFTags(exif.Tags, ifd0, exifTagNames)
+		addTIFFTags(exif.Tags, exifIFD, exifTagNames)
+		addTIFFTags(exif.Tags, gpsIFD, gpsTagNames)
+	}
+
+	return exif, fields, nil
+}
+
+func readTIFFIFD(data []byte, order binary.ByteOrder, offset uint32) (map[uint16]tiffEntry, error) {
+	if int64(offset)+2 > int64(len(data)) {
+		return nil, fmt.Errorf("IFD offset out of range")
+	}
+
+	count := int(order.Uint16(data[offset:]))
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		pos := int64(offset) + 2 + int64(i)*12
+		if pos+12 > int64(len(data)) {
+			return nil, fmt.Errorf("IFD entry out of range")
+		}
+		raw := data[pos : pos+12]
+		entry := tiffEntry{
+			typ:   order.Uint16(raw[2:4]),
+			count: order.Uint32(raw[4:8]),
+			order: order,
+		}
+
+		size := int64(tiffTypeSize(entry.typ)) * int64(entry.count)
+		if size == 0 {
+			continue
+		}
+		if size <= 4 {
+			entry.data = raw[8 : 8+size]
+		} else {
+			valueOffset := int64(order.Uint32(raw[8:12]))
+			if valueOffset+size > int64(len(data)) {
+				continue
+			}
+			entry.data = data[valueOffset : valueOffset+size]
+		}
+		entries[order.Uint16(raw[0:2])] = entry
+	}
+
+	return entries, nil
+}
+
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 11:
+		return 4
+	case 5, 10, 12:
+		return 8
+	default:
+		return 0
+	}
+}
+
+func (e tiffEntry) string() string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.data), "\x00"))
+}
+
+func (e tiffEntry) uint(i int) uint32 {
+	if i >= int(e.count) {
+		return 0
+	}
+	switch e.typ {
+	case 1, 7:
+		return uint32(e.data[i])
+	case 3:
+		return uint32(e.order.Uint16(e.data[i*2:]))
+	case 4, 9:
+		return e.order.Uint32(e.data[i*4:])
+	default:
+		return 0
+	}
+}
+
+func (e tiffEntry) rational(i int) (float64, float64) {
+	if i >= int(e.count) || (e.typ != 5 && e.typ != 10) {
+		return 0, 0
+	}
+	num, den := e.order.Uint32(e.data[i*8:]), e.order.Uint32(e.data[i*8+4:])
+	if e.typ == 10 {
+		return float64(int32(num)), float64(int32(den))
+	}
+	return float64(num), float64(den)
+}
+
+func (e tiffEntry) float(i int) float64 {
+	if e.typ == 5 || e.typ == 10 {
+		num, den := e.rational(i)
+		if den == 0 {
+			return 0
+		}
+		return num / den
+	}
+	return float64(e.uint(i))
+}
+
+// format renders any entry as a human readable value for the full tag listing
+func (e tiffEntry) format() string {
+	switch e.typ {
+	case 2:
+		return e.string()
+	case 5, 10:
+		values := make([]string, 0, e.count)
+		for i := 0; i < int(e.count) && i < 16; i++ {
+			num, den := e.rational(i)
+			values = append(values, fmt.Sprintf("%g/%g", num, den))
+		}
+		return strings.Join(values, ", ")
+	case 1, 3, 4, 9:
+		values := make([]string, 0, e.count)
+		for i := 0; i < int(e.count) && i < 16; i++ {
+			values = append(values, fmt.Sprintf("%d", e.uint(i)))
+		}
+		return strings.Join(values, ", ")
+	default:
+		if len(e.data) > 32 {
+			return fmt.Sprintf("%d bytes", len(e.data))
+		}
+		return fmt.Sprintf("%x", e.data)
+	}
+}
+
+func addTIFFTags(tags map[string]string, entries map[uint16]tiffEntry, names map[uint16]string) {
+	for tag, entry := range entries {
+		if tag == exifIFDPointer || tag == gpsIFDPointer {
+			continue
+		}
+		name, ok := names[tag]
+		if !ok {
+			name = fmt.Sprintf("0x%04X", tag)
+		}
+		tags[name] = entry.format()
+	}
+}
+
+func formatExposureTime(entry tiffEntry) string {
+	num, den := entry.rational(0)
+	if num == 0 || den == 0 {
+		return ""
+	}
+	if num < den {
+		return fmt.Sprintf("1/%g", math.Round(den/num))
+	}
+	return fmt.Sprintf("%g", roundTo(num/den, 2))
+}
+
+func gpsCoordinate(entry tiffEntry, ref, negativeRef string) float64 {
+	value := entry.float(0) + entry.float(1)/60 + entry.float(2)/3600
+	if strings.EqualFold(ref, negativeRef) {
+		return -value
+	}
+	return value
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
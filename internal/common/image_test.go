@@ -0,0 +1,44 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// pngChunk encodes a PNG chunk with a length field of length, which need
+// not match data; the CRC is not checked when reading metadata
+func pngChunk(kind string, length uint32, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, length)
+	chunk = append(append(chunk, kind...), data...)
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func TestGetImageInfoRejectsOversizedEXIFChunk(t *testing.T) {
+	ihdr := binary.BigEndian.AppendUint32(nil, 1)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 1)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0)
+
+	var png bytes.Buffer
+	png.WriteString("\x89PNG\r\n\x1a\n")
+	png.Write(pngChunk("IHDR", uint32(len(ihdr)), ihdr))
+	png.Write(pngChunk("eXIf", 0xF0000000, []byte("Exif")))
+	path := filepath.Join(t.TempDir(), "crafted.png")
+	if err := os.WriteFile(path, png.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := GetImageInfo(path, false)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("GetImageInfo accepted an EXIF chunk longer than the file")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("GetImageInfo allocated %d bytes for a %d byte file", allocated, png.Len())
+	}
+}
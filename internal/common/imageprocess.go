@@ -0,0 +1,397 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+
+	"jarvis/internal/types"
+)
+
+// DefaultImageQuality is the quality lossy formats are written with when a
+// call does not give one
+const DefaultImageQuality = 85
+
+// Bounds on the images decoded or created, so a small file declaring huge
+// dimensions or a resize to them cannot exhaust memory. maxImagePixels is
+// 400MB as RGBA.
+const (
+	maxImageDimension = 32768
+	maxImagePixels    = 100 * 1000 * 1000
+)
+
+// How ResizeImage fits an image into a width and height
+const (
+	ImageFitContain = "contain" // scale to fit within, keeping the aspect ratio
+	ImageFitCover   = "cover"   // scale to cover, cropping the overflow
+	ImageFitFill    = "fill"    // stretch to exactly the size
+)
+
+// Directions FlipImage mirrors an image in
+const (
+	ImageFlipHorizontal = "horizontal"
+	ImageFlipVertical   = "vertical"
+)
+
+// ImageFormats lists the formats images can be written in
+var ImageFormats = []string{"jpeg", "png", "gif", "bmp", "webp", "tiff"}
+
+// imageExtensions are the file extensions of the image formats
+var imageExtensions = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"bmp":  ".bmp",
+	"webp": ".webp",
+	"tiff": ".tiff",
+}
+
+// NormalizeImageFormat returns the format an image format name or file
+// extension stands for, such as jpeg for jpg or .JPG, or "" when it is
+// not one of ImageFormats
+func NormalizeImageFormat(format string) string {
+	format = strings.ToLower(strings.TrimPrefix(format, "."))
+	switch format {
+	case "jpg":
+		format = "jpeg"
+	case "tif":
+		format = "tiff"
+	}
+	if !slices.Contains(ImageFormats, format) {
+		return ""
+	}
+	return format
+}
+
+// ImagePathWithFormat returns path with the file extension of format
+func ImagePathWithFormat(path, format string) string {
+	ext, ok := imageExtensions[NormalizeImageFormat(format)]
+	if !ok {
+		ext = "." + strings.ToLower(format)
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
+
+// DecodeImage reads an image file and returns it with its format
+func DecodeImage(path string) (image.Image, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkImageSize(config.Width, config.Height); err != nil {
+		return nil, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+// checkImageSize refuses image dimensions over maxImageDimension or
+// maxImagePixels
+func checkImageSize(width, height int) error {
+	if width > maxImageDimension || height > maxImageDimension {
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("image of %dx%d exceeds the limit of %d pixels per side", width, height, maxImageDimension))
+	}
+	if int64(width)*int64(height) > maxImagePixels {
+		return WithErrorCode(ErrorValidationFailed, fmt.Errorf("image of %dx%d exceeds the limit of %d pixels", width, height, maxImagePixels))
+	}
+	return nil
+}
+
+// CropImage returns the part of img within rect
+func CropImage(img image.Image, rect types.ImageRect) (image.Image, error) {
+	bounds := img.Bounds()
+	area := image.Rect(rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height).Add(bounds.Min)
+	if rect.Width <= 0 || rect.Height <= 0 || rect.X < 0 || rect.Y < 0 || !area.In(bounds) {
+		return nil, fmt.Errorf("crop of %dx%d at %d,%d is not within the %dx%d image",
+			rect.Width, rect.Height, rect.X, rect.Y, bounds.Dx(), bounds.Dy())
+	}
+
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Width, rect.Height))
+	draw.Draw(cropped, cropped.Bounds(), img, area.Min, draw.Src)
+	return cropped, nil
+}
+
+// RotateImage turns img clockwise by degrees, which must be a multiple
+// of 90
+func RotateImage(img image.Image, degrees int) (image.Image, error) {
+	degrees = (degrees%360 + 360) % 360
+	if degrees%90 != 0 {
+		return nil, fmt.Errorf("rotation must be a multiple of 90 degrees, not %d", degrees)
+	}
+	if degrees == 0 {
+		return img, nil
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	size := image.Rect(0, 0, height, width)
+	if degrees == 180 {
+		size = image.Rect(0, 0, width, height)
+	}
+	rotated := image.NewRGBA(size)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			switch degrees {
+			case 90:
+				rotated.Set(height-1-y, x, c)
+			case 180:
+				rotated.Set(width-1-x, height-1-y, c)
+			case 270:
+				rotated.Set(y, width-1-x, c)
+			}
+		}
+	}
+	return rotated, nil
+}
+
+// FlipImage mirrors img horizontally or vertically
+func FlipImage(img image.Image, direction string) (image.Image, error) {
+	if direction != ImageFlipHorizontal && direction != ImageFlipVertical {
+		return nil, fmt.Errorf("invalid flip %q: use %s or %s", direction, ImageFlipHorizontal, ImageFlipVertical)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	flipped := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			if direction == ImageFlipHorizontal {
+				flipped.Set(width-1-x, y, c)
+			} else {
+				flipped.Set(x, height-1-y, c)
+			}
+		}
+	}
+	return flipped, nil
+}
+
+// ResizeImage scales img to width and height. With one of them zero the
+// other is computed from the aspect ratio; with both, fit decides how the
+// image is made to fit them.
+func ResizeImage(img image.Image, width, height int, fit string) (image.Image, error) {
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return nil, fmt.Errorf("give a positive width, height or both")
+	}
+	if fit == "" {
+		fit = ImageFitContain
+	}
+	if fit != ImageFitContain && fit != ImageFitCover && fit != ImageFitFill {
+		return nil, fmt.Errorf("invalid fit %q: use %s, %s or %s", fit, ImageFitContain, ImageFitCover, ImageFitFill)
+	}
+
+	bounds := img.Bounds()
+	srcWidth, srcHeight := float64(bounds.Dx()), float64(bounds.Dy())
+	source := bounds
+	switch {
+	case width == 0:
+		width = max(int(math.Round(srcWidth*float64(height)/srcHeight)), 1)
+	case height == 0:
+		height = max(int(math.Round(srcHeight*float64(width)/srcWidth)), 1)
+	case fit == ImageFitContain:
+		scale := math.Min(float64(width)/srcWidth, float64(height)/srcHeight)
+		width = max(int(math.Round(srcWidth*scale)), 1)
+		height = max(int(math.Round(srcHeight*scale)), 1)
+	case fit == ImageFitCover:
+		// Scale the centered part of the source with the target's aspect
+		// ratio
+		scale := math.Max(float64(width)/srcWidth, float64(height)/srcHeight)
+		cropWidth := int(math.Round(float64(width) / scale))
+		cropHeight := int(math.Round(float64(height) / scale))
+		left := bounds.Min.X + (bounds.Dx()-cropWidth)/2
+		top := bounds.Min.Y + (bounds.Dy()-cropHeight)/2
+		source = image.Rect(left, top, left+cropWidth, top+cropHeight)
+	}
+	if err := checkImageSize(width, height); err != nil {
+		return nil, err
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, source, draw.Src, nil)
+	return resized, nil
+}
+
+// EncodeImage writes img to w in format. quality, from 1 to 100, sets the
+// compression of JPEG and WebP; the other formats are lossless or, for
+// GIF, limited to a 256 color palette, and ignore it. WebP is written with
+// cwebp, which must be installed.
+func EncodeImage(ctx context.Context, w io.Writer, img image.Image, format string, quality int) error {
+	switch NormalizeImageFormat(format) {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "bmp":
+		return bmp.Encode(w, img)
+	case "tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	case "webp":
+		return encodeWebP(ctx, w, img, quality)
+	default:
+		return fmt.Errorf("unsupported image format %q: use one of %s", format, strings.Join(ImageFormats, ", "))
+	}
+}
+
+// encodeWebP writes img as WebP with cwebp, as the standard library has no
+// WebP encoder
+func encodeWebP(ctx context.Context, w io.Writer, img image.Image, quality int) error {
+	cwebp, err := exec.LookPath("cwebp")
+	if err != nil {
+		return fmt.Errorf("writing WebP images needs cwebp, which is not installed")
+	}
+
+	dir, err := os.MkdirTemp("", "jarvis-webp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	input, output := filepath.Join(dir, "image.png"), filepath.Join(dir, "image.webp")
+	file, err := os.Create(input)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, cwebp, "-quiet", "-q", strconv.Itoa(quality), input, "-o", output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cwebp failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	encoded, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer encoded.Close()
+	_, err = io.Copy(w, encoded)
+	return err
+}
+
+// ProcessImage reads the image at source, applies transform and writes
+// the result to destination, which may be source itself. The format
+// written is transform.Format, else the one of the destination's
+// extension, else the source's.
+func ProcessImage(ctx context.Context, source, destination string, transform types.ImageTransform) (*types.ImageProcessResult, error) {
+	quality := transform.Quality
+	if quality == 0 {
+		quality = DefaultImageQuality
+	}
+	if quality < 1 || quality > 100 {
+		return nil, fmt.Errorf("quality must be between 1 and 100, not %d", quality)
+	}
+
+	format := NormalizeImageFormat(transform.Format)
+	if transform.Format != "" && format == "" {
+		return nil, fmt.Errorf("unsupported image format %q: use one of %s", transform.Format, strings.Join(ImageFormats, ", "))
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	img, sourceFormat, err := DecodeImage(source)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = NormalizeImageFormat(filepath.Ext(destination))
+	}
+	if format == "" {
+		format = sourceFormat
+	}
+
+	result := &types.ImageProcessResult{
+		Source:         source,
+		Destination:    destination,
+		SourceFormat:   sourceFormat,
+		Format:         format,
+		OriginalWidth:  img.Bounds().Dx(),
+		OriginalHeight: img.Bounds().Dy(),
+		OriginalSize:   info.Size(),
+	}
+	if format == "jpeg" || format == "webp" {
+		result.Quality = quality
+	}
+
+	if transform.Crop != nil {
+		if img, err = CropImage(img, *transform.Crop); err != nil {
+			return nil, err
+		}
+	}
+	if transform.Rotate != 0 {
+		if img, err = RotateImage(img, transform.Rotate); err != nil {
+			return nil, err
+		}
+	}
+	if transform.Flip != "" {
+		if img, err = FlipImage(img, transform.Flip); err != nil {
+			return nil, err
+		}
+	}
+	if transform.Width != 0 || transform.Height != 0 {
+		if img, err = ResizeImage(img, transform.Width, transform.Height, transform.Fit); err != nil {
+			return nil, err
+		}
+	}
+
+	err = WriteFileAtomicFunc(destination, 0644, func(w io.Writer) error {
+		return EncodeImage(ctx, w, img, format, quality)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write image: %w", err)
+	}
+
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	if written, err := os.Stat(destination); err == nil {
+		result.Size = written.Size()
+	}
+	return result, nil
+}
+
+// ConvertImageFormat writes the image at filePath in targetFormat next to
+// it, with the extension of the format, and returns the new path
+func ConvertImageFormat(ctx context.Context, filePath, targetFormat string, quality int) (string, error) {
+	newPath := ImagePathWithFormat(filePath, targetFormat)
+	if _, err := ProcessImage(ctx, filePath, newPath, types.ImageTransform{Format: targetFormat, Quality: quality}); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResizeImageRejectsHugeOutput(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	if _, err := ResizeImage(img, 100000, 100000, ImageFitFill); err == nil {
+		t.Error("ResizeImage accepted a 100000x100000 output")
+	}
+	if _, err := ResizeImage(img, 20000, 0, ""); err == nil {
+		t.Error("ResizeImage accepted a height derived past the pixel limit")
+	}
+	if _, err := ResizeImage(img, 4, 4, ImageFitFill); err != nil {
+		t.Errorf("ResizeImage refused a small output: %v", err)
+	}
+}
+
+func TestDecodeImageRejectsHugeDimensions(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// Declare 50000x50000 in IHDR; the dimensions are checked before any
+	// pixel data is read, so the image data need not match
+	data := encoded.Bytes()
+	copy(data[16:24], []byte{0, 0, 0xC3, 0x50, 0, 0, 0xC3, 0x50})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	path := filepath.Join(t.TempDir(), "bomb.png")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := DecodeImage(path); err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+		t.Errorf("DecodeImage of an image declaring 50000x50000 pixels returned %v", err)
+	}
+}
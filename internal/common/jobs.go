@@ -0,0 +1,363 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+const (
+	// maxScheduledJobs bounds the number of scheduled jobs
+	maxScheduledJobs = 50
+	// maxJobRuns is how many runs are kept per job, newest first
+	maxJobRuns = 20
+	// maxJobOutputBytes is the output kept per run
+	maxJobOutputBytes = 16 * 1024
+	// minJobInterval stops interval jobs from running in a tight loop
+	minJobInterval = 10 * time.Second
+)
+
+// Job run statuses
+const (
+	JobStatusSuccess = "success"
+	JobStatusFailure = "failure"
+	JobStatusTimeout = "timeout"
+)
+
+// jobTools are the tools a scheduled job may not call
+var jobTools = []string{"schedule_job", "cancel_job"}
+
+// ToolInvoker calls the tool of a job with its arguments, as the client
+// and session that scheduled the job, and returns its text output and
+// whether the tool reported an error
+type ToolInvoker func(ctx context.Context, job types.ScheduledJob) (string, bool, error)
+
+type scheduledJob struct {
+	info   types.ScheduledJob
+	cron   *CronSchedule
+	cancel context.CancelFunc
+	runs   []types.JobRun
+}
+
+var (
+	jobsMutex      sync.Mutex
+	jobs           = make(map[string]*scheduledJob)
+	jobCounter     int
+	jobToolInvoker ToolInvoker
+)
+
+// jobsPath returns the file job definitions are persisted in, next to the
+// config file
+func jobsPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-jobs.json")
+}
+
+// SetJobToolInvoker sets how scheduled jobs call tools
+func SetJobToolInvoker(invoker ToolInvoker) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	jobToolInvoker = invoker
+}
+
+// StartJobScheduler loads the persisted jobs and starts running them. Runs
+// missed while the server was down are skipped.
+func StartJobScheduler() error {
+	data, err := os.ReadFile(jobsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var saved []types.ScheduledJob
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid jobs file %s: %w", jobsPath(), err)
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	var problems []string
+	for _, info := range saved {
+		if n, err := strconv.Atoi(strings.TrimPrefix(info.ID, "job-")); err == nil && n > jobCounter {
+			jobCounter = n
+		}
+		job := &scheduledJob{info: info}
+		if info.Cron != "" {
+			if job.cron, err = ParseCron(info.Cron); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", info.ID, err))
+				continue
+			}
+		}
+		jobs[info.ID] = job
+		startJob(job)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("skipped jobs: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ScheduleJob validates and starts a new job. Exactly one of Command and
+// Tool, and one of Cron and IntervalSeconds, must be set.
+func ScheduleJob(info types.ScheduledJob) (types.ScheduledJob, error) {
+	switch {
+	case (info.Command == "") == (info.Tool == ""):
+		return info, fmt.Errorf("set exactly one of command and tool")
+	case (info.Cron == "") == (info.IntervalSeconds == 0):
+		return info, fmt.Errorf("set exactly one of cron and interval_seconds")
+	case info.IntervalSeconds != 0 && time.Duration(info.IntervalSeconds)*time.Second < minJobInterval:
+		return info, fmt.Errorf("interval_seconds must be at least %d", int(minJobInterval.Seconds()))
+	case info.TimeoutSeconds <= 0:
+		return info, fmt.Errorf("timeout_seconds must be positive")
+	case info.Command != "" && IsCommandBlocked(info.Command):
+		return info, WithErrorCode(ErrorCommandBlocked, fmt.Errorf("command contains blocked patterns"))
+	}
+	for _, tool := range jobTools {
+		if info.Tool == tool {
+			return info, fmt.Errorf("scheduled jobs cannot call %s", tool)
+		}
+	}
+
+	job := &scheduledJob{}
+	if info.Cron != "" {
+		schedule, err := ParseCron(info.Cron)
+		if err != nil {
+			return info, err
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return info, fmt.Errorf("cron expression %q never matches", info.Cron)
+		}
+		job.cron = schedule
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	if info.Tool != "" && jobToolInvoker == nil {
+		return info, fmt.Errorf("tool jobs are not available")
+	}
+	if len(jobs) >= maxScheduledJobs {
+		return info, fmt.Errorf("too many scheduled jobs (limit %d); cancel one first", maxScheduledJobs)
+	}
+
+	jobCounter++
+	info.ID = fmt.Sprintf("job-%d", jobCounter)
+	info.CreatedAt = time.Now()
+	info.NextRun, info.LastRun, info.LastStatus, info.RunCount = nil, nil, "", 0
+	job.info = info
+	jobs[info.ID] = job
+	startJob(job)
+
+	if err := saveJobs(); err != nil {
+		job.cancel()
+		delete(jobs, info.ID)
+		return info, fmt.Errorf("failed to save job: %w", err)
+	}
+	return job.info, nil
+}
+
+// nextJobRun returns when job should next run after t; callers hold
+// jobsMutex
+func nextJobRun(job *scheduledJob, t time.Time) *time.Time {
+	var next time.Time
+	if job.cron != nil {
+		next = job.cron.Next(t)
+		if next.IsZero() {
+			return nil
+		}
+	} else {
+		next = t.Add(time.Duration(job.info.IntervalSeconds) * time.Second)
+	}
+	return &next
+}
+
+// startJob schedules the first run of job and starts its loop; callers
+// hold jobsMutex
+func startJob(job *scheduledJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+	job.info.NextRun = nextJobRun(job, time.Now())
+	go runJobLoop(ctx, job)
+}
+
+// runJobLoop runs job at each scheduled time until it is cancelled. Runs
+// never overlap; a run that overshoots the next scheduled time delays it.
+func runJobLoop(ctx context.Context, job *scheduledJob) {
+	for {
+		jobsMutex.Lock()
+		next := job.info.NextRun
+		info := job.info
+		invoker := jobToolInvoker
+		jobsMutex.Unlock()
+		if next == nil {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(*next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run := executeJob(ctx, info, invoker)
+		if ctx.Err() != nil {
+			return
+		}
+
+		jobsMutex.Lock()
+		job.info.RunCount++
+		run.Run = job.info.RunCount
+		job.info.LastRun = &run.StartedAt
+		job.info.LastStatus = run.Status
+		job.info.NextRun = nextJobRun(job, time.Now())
+		job.runs = append([]types.JobRun{run}, job.runs...)
+		if len(job.runs) > maxJobRuns {
+			job.runs = job.runs[:maxJobRuns]
+		}
+		saveJobs()
+		jobsMutex.Unlock()
+	}
+}
+
+// executeJob runs a job once
+func executeJob(ctx context.Context, info types.ScheduledJob, invoker ToolInvoker) types.JobRun {
+	run := types.JobRun{JobID: info.ID, StartedAt: time.Now(), Status: JobStatusSuccess}
+	timeout := time.Duration(info.TimeoutSeconds) * time.Second
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output := NewOutputBuffer(maxJobOutputBytes)
+	var err error
+	if info.Tool != "" {
+		var text string
+		var isError bool
+		text, isError, err = invoker(runCtx, info)
+		output.Write([]byte(text))
+		if err == nil && isError {
+			run.Status = JobStatusFailure
+			run.ExitCode = 1
+		}
+	} else if IsCommandBlocked(info.Command) {
+		err = WithErrorCode(ErrorCommandBlocked, fmt.Errorf("command contains blocked patterns"))
+		run.ExitCode = -1
+	} else {
+		cmd := exec.CommandContext(runCtx, info.Shell, ShellCommandArgs(info.Shell, info.Command)...)
+		cmd.Dir = info.WorkingDir
+		cmd.Stdout, cmd.Stderr = output, output
+		if _, err = SandboxCommand(cmd); err == nil {
+			err = cmd.Run()
+		}
+		run.ExitCode = CommandExitCode(err)
+	}
+
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+	run.Output, run.Truncated = output.String(), output.Truncated()
+	if err != nil {
+		run.Status = JobStatusFailure
+		run.Error = err.Error()
+		if info.Tool != "" {
+			run.ExitCode = -1
+		}
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		run.Status = JobStatusTimeout
+		run.Error = fmt.Sprintf("job timed out after %s", timeout)
+	}
+
+	if info.Command != "" {
+		RecordCommand(types.CommandHistoryEntry{
+			Tool:       "schedule_job",
+			Command:    info.Command,
+			Shell:      info.Shell,
+			WorkingDir: info.WorkingDir,
+			StartedAt:  run.StartedAt,
+			DurationMs: run.DurationMs,
+			ExitCode:   run.ExitCode,
+			Error:      run.Error,
+			Output:     run.Output,
+			Truncated:  run.Truncated,
+		})
+	}
+	return run
+}
+
+// saveJobs persists the job definitions; callers hold jobsMutex
+func saveJobs() error {
+	saved := make([]types.ScheduledJob, 0, len(jobs))
+	for _, job := range jobs {
+		saved = append(saved, job.info)
+	}
+	sortJobs(saved)
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := EnsureDir(filepath.Dir(jobsPath())); err != nil {
+		return err
+	}
+	return WriteFileAtomic(jobsPath(), data, 0600)
+}
+
+func sortJobs(list []types.ScheduledJob) {
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+}
+
+// ListJobs returns the scheduled jobs, oldest first
+func ListJobs() []types.ScheduledJob {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	list := make([]types.ScheduledJob, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job.info)
+	}
+	sortJobs(list)
+	return list
+}
+
+// CancelJob stops a job and removes it; a run in progress is stopped
+func CancelJob(id string) (types.ScheduledJob, error) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return types.ScheduledJob{}, WithErrorCode(ErrorNotFound, fmt.Errorf("job not found: %s", id))
+	}
+	job.cancel()
+	delete(jobs, id)
+	job.info.NextRun = nil
+	return job.info, saveJobs()
+}
+
+// GetJobRuns returns the retained runs of a job, newest first. limit <= 0
+// returns all of them.
+func GetJobRuns(id string, limit int) ([]types.JobRun, error) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("job not found: %s", id))
+	}
+	runs := job.runs
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return append([]types.JobRun(nil), runs...), nil
+}
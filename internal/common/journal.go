@@ -0,0 +1,271 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+const (
+	// maxJournalEntries bounds the session journal; the oldest entries and
+	// their snapshots are dropped first
+	maxJournalEntries = 500
+	// Files larger than this are journaled without a snapshot and cannot be
+	// undone
+	maxJournalSnapshotBytes = 50 * 1024 * 1024
+)
+
+const (
+	JournalActionCreate = "create"
+	JournalActionModify = "modify"
+	JournalActionDelete = "delete"
+)
+
+var (
+	// Session edit journal. Snapshots of the previous content live in a
+	// private temporary directory that is created on first use.
+	journalEntries []types.EditJournalEntry
+	journalDir     string
+	journalNextID  = 1
+	journalMutex   sync.Mutex
+)
+
+// RecordEdit journals a modification of an existing file by a client
+// session
+func RecordEdit(session, tool, path string, before, after []byte) {
+	recordJournalEntry(session, tool, path, JournalActionModify, before, after)
+}
+
+// RecordCreate journals the creation of a new file by a client session
+func RecordCreate(session, tool, path string, after []byte) {
+	recordJournalEntry(session, tool, path, JournalActionCreate, nil, after)
+}
+
+// RecordDelete journals the deletion of a file by a client session
+func RecordDelete(session, tool, path string, before []byte) {
+	recordJournalEntry(session, tool, path, JournalActionDelete, before, nil)
+}
+
+func recordJournalEntry(session, tool, path, action string, before, after []byte) {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+
+	RememberContent(path, after)
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	entry := types.EditJournalEntry{
+		ID:        journalNextID,
+		Timestamp: time.Now(),
+		Session:   session,
+		Tool:      tool,
+		Path:      path,
+		Action:    action,
+	}
+	journalNextID++
+
+	if action != JournalActionCreate {
+		entry.BeforeHash = contentHash(before)
+		if len(before) <= maxJournalSnapshotBytes {
+			if snapshot, err := writeJournalSnapshot(entry.ID, path, before); err == nil {
+				entry.BackupPath = snapshot
+			}
+		}
+	}
+	if action != JournalActionDelete {
+		entry.AfterHash = contentHash(after)
+	}
+
+	journalEntries = append(journalEntries, entry)
+	if len(journalEntries) > maxJournalEntries {
+		for _, dropped := range journalEntries[:len(journalEntries)-maxJournalEntries] {
+			if dropped.BackupPath != "" {
+				os.Remove(dropped.BackupPath)
+			}
+		}
+		journalEntries = append([]types.EditJournalEntry(nil), journalEntries[len(journalEntries)-maxJournalEntries:]...)
+	}
+}
+
+func writeJournalSnapshot(id int, path string, content []byte) (string, error) {
+	if journalDir == "" {
+		dir, err := os.MkdirTemp("", "jarvis-journal-*")
+		if err != nil {
+			return "", err
+		}
+		journalDir = dir
+	}
+
+	snapshot := filepath.Join(journalDir, fmt.Sprintf("%06d-%s", id, filepath.Base(path)))
+	if err := os.WriteFile(snapshot, content, 0600); err != nil {
+		return "", err
+	}
+	return snapshot, nil
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ListJournalEntries returns the journal entries of a client session newest
+// first, optionally filtered by path. limit <= 0 means unlimited.
+func ListJournalEntries(session, path string, includeUndone bool, limit int) []types.EditJournalEntry {
+	if path != "" {
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+	}
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	var entries []types.EditJournalEntry
+	for i := len(journalEntries) - 1; i >= 0; i-- {
+		entry := journalEntries[i]
+		if entry.Session != session || (path != "" && entry.Path != path) || (entry.Undone && !includeUndone) {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries
+}
+
+// UndoLastEdit restores the file changed by the most recent edit of a
+// client session that has not been undone, limited to path when given
+func UndoLastEdit(session, path string, force bool) ([]types.EditJournalEntry, error) {
+	if path != "" {
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+	}
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	for i := len(journalEntries) - 1; i >= 0; i-- {
+		entry := journalEntries[i]
+		if entry.Session != session || entry.Undone || (path != "" && entry.Path != path) {
+			continue
+		}
+		return undoJournalEntries(session, []int{i}, force)
+	}
+	return nil, fmt.Errorf("no edits to undo")
+}
+
+// UndoEditsSince reverts every edit of a client session with an ID of at
+// least sinceID or made at or after since, newest first
+func UndoEditsSince(session string, sinceID int, since time.Time, force bool) ([]types.EditJournalEntry, error) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	var indexes []int
+	for i := len(journalEntries) - 1; i >= 0; i-- {
+		entry := journalEntries[i]
+		if entry.Session != session || entry.Undone {
+			continue
+		}
+		if (sinceID > 0 && entry.ID >= sinceID) || (!since.IsZero() && !entry.Timestamp.Before(since)) {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no edits to undo")
+	}
+	return undoJournalEntries(session, indexes, force)
+}
+
+// undoJournalEntries restores the entries at indexes, which must be ordered
+// newest first. Every file is checked against the allowed and denied paths
+// and the role of the session as they are now, and unless force is set for
+// changes made outside the journal, before anything is restored.
+func undoJournalEntries(session string, indexes []int, force bool) ([]types.EditJournalEntry, error) {
+	for _, i := range indexes {
+		entry := journalEntries[i]
+		if !IsPathAllowed(entry.Path) || !sessionAllowsPath(session, entry.Path) {
+			return nil, WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("edit #%d of %s cannot be undone: access to %s is not allowed", entry.ID, entry.Path, entry.Path))
+		}
+	}
+
+	if !force {
+		// Expected current hash per path; "" means the file must not exist
+		expected := make(map[string]string)
+		for _, i := range indexes {
+			entry := journalEntries[i]
+			current, seen := expected[entry.Path]
+			if !seen {
+				current = currentFileHash(entry.Path)
+			}
+			if current != entry.AfterHash {
+				return nil, fmt.Errorf("%s changed after edit #%d (%s); use force to overwrite", entry.Path, entry.ID, entry.Tool)
+			}
+			expected[entry.Path] = entry.BeforeHash
+		}
+	}
+
+	for _, i := range indexes {
+		entry := journalEntries[i]
+		if entry.Action != JournalActionCreate && entry.BackupPath == "" {
+			return nil, fmt.Errorf("edit #%d of %s has no snapshot and cannot be undone", entry.ID, entry.Path)
+		}
+	}
+
+	var undone []types.EditJournalEntry
+	for _, i := range indexes {
+		entry := &journalEntries[i]
+		var err error
+		if entry.Action == JournalActionCreate {
+			err = os.Remove(entry.Path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			var content []byte
+			content, err = os.ReadFile(entry.BackupPath)
+			if err == nil {
+				err = WriteFileAtomic(entry.Path, content, 0644)
+			}
+		}
+		if err != nil {
+			return undone, fmt.Errorf("failed to undo edit #%d of %s: %w (%d edit(s) already undone)", entry.ID, entry.Path, err, len(undone))
+		}
+		entry.Undone = true
+		undone = append(undone, *entry)
+	}
+
+	return undone, nil
+}
+
+// FormatUndoneEdits summarizes undone journal entries for a tool result
+func FormatUndoneEdits(entries []types.EditJournalEntry) string {
+	var summary strings.Builder
+	summary.WriteString(fmt.Sprintf("Undid %d edit(s)\n", len(entries)))
+	for _, entry := range entries {
+		action := "restored"
+		if entry.Action == JournalActionCreate {
+			action = "removed"
+		}
+		summary.WriteString(fmt.Sprintf("  #%d %s: %s %s\n", entry.ID, entry.Tool, action, entry.Path))
+	}
+	return summary.String()
+}
+
+func currentFileHash(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return contentHash(content)
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// journaledFile writes content to a new file in dir and journals a change
+// of it to edited by session
+func journaledFile(t *testing.T, session, dir, name, content, edited string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RecordEdit(session, "edit_file", path, []byte(content), []byte(edited))
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUndoOnlyRevertsEditsOfTheSession(t *testing.T) {
+	dir := allowedTempDir(t)
+	start := time.Now()
+	mine := journaledFile(t, "session-a", dir, "mine.txt", "before", "after")
+	theirs := journaledFile(t, "session-b", dir, "theirs.txt", "before", "after")
+
+	if _, err := UndoLastEdit("session-a", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, mine); got != "before" {
+		t.Errorf("the session's own edit was not undone: %q", got)
+	}
+	if got := readFile(t, theirs); got != "after" {
+		t.Errorf("another session's edit was undone: %q", got)
+	}
+	if _, err := UndoEditsSince("session-a", 0, start, false); err == nil {
+		t.Error("UndoEditsSince found edits of another session to undo")
+	}
+	if entries := ListJournalEntries("session-a", theirs, true, 0); len(entries) != 0 {
+		t.Errorf("the journal lists another session's edits: %v", entries)
+	}
+}
+
+func TestUndoRechecksPaths(t *testing.T) {
+	dir := allowedTempDir(t)
+	// Undoing a creation removes the file, which no write check covers
+	path := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(path, []byte("created"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RecordCreate("session-a", "write_file", path, []byte("created"))
+	setConfig(t, "deniedPathPatterns", `["*.env"]`, "[]")
+
+	if _, err := UndoLastEdit("session-a", "", false); err == nil || ErrorCodeOf(err) != ErrorPathNotAllowed {
+		t.Errorf("undoing the creation of a denied path returned %v", err)
+	}
+	if got := readFile(t, path); got != "created" {
+		t.Errorf("a denied path was changed: %q", got)
+	}
+}
+
+func TestUndoRechecksSessionRole(t *testing.T) {
+	dir := allowedTempDir(t)
+	path := journaledFile(t, "session-c", dir, "notes.txt", "before", "after")
+	setConfig(t, "roles", `{"ci": {"allowedDirectories": [`+strconv.Quote(t.TempDir())+`]}}`, "{}")
+	rolesMutex.Lock()
+	sessionRoles["session-c"] = "ci"
+	rolesMutex.Unlock()
+	t.Cleanup(func() {
+		rolesMutex.Lock()
+		delete(sessionRoles, "session-c")
+		rolesMutex.Unlock()
+	})
+
+	if _, err := UndoLastEdit("session-c", "", false); err == nil {
+		t.Error("an edit outside the session's role directories was undone")
+	}
+	if got := readFile(t, path); got != "after" {
+		t.Errorf("a path outside the role was restored: %q", got)
+	}
+}
@@ -0,0 +1,384 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPatchOperation is one operation of an RFC 6902 JSON Patch
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to a JSON document. The
+// patch is applied as a whole: if any operation fails, an error is returned
+// and nothing is changed. Key order and indentation are preserved.
+func ApplyJSONPatch(content, patch []byte) ([]byte, error) {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("invalid JSON Patch: %w", err)
+	}
+
+	root, err := DecodeOrderedJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		root, err = applyJSONPatchOperation(root, operation)
+		if err != nil {
+			path := ""
+			if operation.Path != nil {
+				path = *operation.Path
+			}
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, operation.Op, path, err)
+		}
+	}
+
+	return encodeJSONDocument(root, string(content)), nil
+}
+
+// ApplyJSONMergePatch applies an RFC 7386 JSON Merge Patch to a JSON
+// document, preserving key order and indentation
+func ApplyJSONMergePatch(content, patch []byte) ([]byte, error) {
+	root, err := DecodeOrderedJSON(content)
+	if err != nil {
+		return nil, err
+	}
+	patchValue, err := DecodeOrderedJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	return encodeJSONDocument(mergeJSONPatch(root, patchValue), string(content)), nil
+}
+
+func encodeJSONDocument(root interface{}, original string) []byte {
+	var buf bytes.Buffer
+	writeJSONValue(&buf, root, detectIndent(original, "  "), 0)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func mergeJSONPatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(*OrderedObject)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(*OrderedObject)
+	if !ok {
+		targetObj = NewOrderedObject()
+	}
+	for _, key := range patchObj.Keys() {
+		value, _ := patchObj.Get(key)
+		if value == nil {
+			targetObj.Delete(key)
+			continue
+		}
+		current, _ := targetObj.Get(key)
+		targetObj.Set(key, mergeJSONPatch(current, value))
+	}
+	return targetObj
+}
+
+func applyJSONPatchOperation(root interface{}, operation jsonPatchOperation) (interface{}, error) {
+	if operation.Path == nil {
+		return nil, fmt.Errorf("missing path")
+	}
+	path, err := parseJSONPointer(*operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		if value, err = DecodeOrderedJSON(operation.Value); err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+	case "move", "copy":
+		if operation.From == nil {
+			return nil, fmt.Errorf("missing from")
+		}
+	}
+
+	switch operation.Op {
+	case "add":
+		return jsonPointerAdd(root, path, value)
+	case "remove":
+		return jsonPointerRemove(root, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		return updateAtJSONPointer(root, path, func(container interface{}, token string) (interface{}, error) {
+			switch c := container.(type) {
+			case *OrderedObject:
+				if _, exists := c.Get(token); !exists {
+					return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("key %q not found", token))
+				}
+				c.Set(token, value)
+				return c, nil
+			case []interface{}:
+				index, err := jsonArrayIndex(token, len(c)-1)
+				if err != nil {
+					return nil, err
+				}
+				c[index] = value
+				return c, nil
+			default:
+				return nil, fmt.Errorf("parent is not an object or array")
+			}
+		})
+	case "move":
+		from, err := parseJSONPointer(*operation.From)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(*operation.Path, *operation.From+"/") {
+			return nil, fmt.Errorf("cannot move a value into one of its children")
+		}
+		moved, err := lookupJSONPointer(root, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		if root, err = jsonPointerRemove(root, from); err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return jsonPointerAdd(root, path, moved)
+	case "copy":
+		from, err := parseJSONPointer(*operation.From)
+		if err != nil {
+			return nil, err
+		}
+		copied, err := lookupJSONPointer(root, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return jsonPointerAdd(root, path, cloneJSONValue(copied))
+	case "test":
+		actual, err := lookupJSONPointer(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonValuesEqual(actual, value) {
+			return nil, fmt.Errorf("test failed: value differs")
+		}
+		return root, nil
+	default:
+		return nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown op %q", operation.Op))
+	}
+}
+
+// parseJSONPointer splits an RFC 6901 JSON Pointer into unescaped tokens
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer %q: must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// jsonArrayIndex parses an array index token no greater than max
+func jsonArrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+func lookupJSONPointer(root interface{}, path []string) (interface{}, error) {
+	current := root
+	for _, token := range path {
+		switch c := current.(type) {
+		case *OrderedObject:
+			value, exists := c.Get(token)
+			if !exists {
+				return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("key %q not found", token))
+			}
+			current = value
+		case []interface{}:
+			index, err := jsonArrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = c[index]
+		default:
+			return nil, fmt.Errorf("cannot resolve %q in a scalar value", token)
+		}
+	}
+	return current, nil
+}
+
+// updateAtJSONPointer calls update with the container holding the last token
+// of path and stores the returned container back into its parent, so array
+// insertions and removals propagate up the tree
+func updateAtJSONPointer(node interface{}, path []string, update func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return update(node, path[0])
+	}
+
+	switch c := node.(type) {
+	case *OrderedObject:
+		child, exists := c.Get(path[0])
+		if !exists {
+			return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("key %q not found", path[0]))
+		}
+		child, err := updateAtJSONPointer(child, path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(path[0], child)
+		return c, nil
+	case []interface{}:
+		index, err := jsonArrayIndex(path[0], len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateAtJSONPointer(c[index], path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		c[index] = child
+		return c, nil
+	default:
+		return nil, fmt.Errorf("cannot resolve %q in a scalar value", path[0])
+	}
+}
+
+func jsonPointerAdd(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateAtJSONPointer(root, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case *OrderedObject:
+			c.Set(token, value)
+			return c, nil
+		case []interface{}:
+			index := len(c)
+			if token != "-" {
+				var err error
+				if index, err = jsonArrayIndex(token, len(c)); err != nil {
+					return nil, err
+				}
+			}
+			c = append(c, nil)
+			copy(c[index+1:], c[index:])
+			c[index] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("parent is not an object or array")
+		}
+	})
+}
+
+func jsonPointerRemove(root interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the document root")
+	}
+	return updateAtJSONPointer(root, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case *OrderedObject:
+			if !c.Delete(token) {
+				return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("key %q not found", token))
+			}
+			return c, nil
+		case []interface{}:
+			index, err := jsonArrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:index], c[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("parent is not an object or array")
+		}
+	})
+}
+
+func cloneJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *OrderedObject:
+		clone := NewOrderedObject()
+		for _, key := range v.Keys() {
+			item, _ := v.Get(key)
+			clone.Set(key, cloneJSONValue(item))
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneJSONValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// jsonValuesEqual compares decoded JSON values; object key order is ignored
+// and numbers compare by value
+func jsonValuesEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case *OrderedObject:
+		bv, ok := b.(*OrderedObject)
+		if !ok || av.Len() != bv.Len() {
+			return false
+		}
+		for _, key := range av.Keys() {
+			aItem, _ := av.Get(key)
+			bItem, exists := bv.Get(key)
+			if !exists || !jsonValuesEqual(aItem, bItem) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonValuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aErr := av.Float64()
+		bf, bErr := bv.Float64()
+		if aErr != nil || bErr != nil {
+			return av == bv
+		}
+		return af == bf
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,48 @@
+package common
+
+import "sort"
+
+// LineSplice replaces the lines at indexes [Start, End) with Lines; Start
+// equal to End inserts Lines before index Start
+type LineSplice struct {
+	Start int
+	End   int
+	Lines []string
+}
+
+// SpliceLines returns a copy of lines with lines[start:end] replaced by
+// replacement
+func SpliceLines(lines []string, start, end int, replacement []string) []string {
+	result := make([]string, 0, len(lines)-(end-start)+len(replacement))
+	result = append(result, lines[:start]...)
+	result = append(result, replacement...)
+	return append(result, lines[end:]...)
+}
+
+// ApplyLineSplices applies splices whose indexes all refer to the
+// original lines, in any order, and returns the result. Splices are
+// applied from the bottom of the file up so earlier indexes stay valid;
+// lines inserted at the same index keep the order they were given in,
+// ahead of any lines replaced there. Splices must not overlap.
+func ApplyLineSplices(lines []string, splices []LineSplice) []string {
+	order := make([]int, len(splices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := splices[order[i]], splices[order[j]]
+		if a.Start != b.Start {
+			return a.Start > b.Start
+		}
+		if a.End != b.End {
+			return a.End > b.End
+		}
+		return order[i] > order[j]
+	})
+
+	result := append([]string(nil), lines...)
+	for _, i := range order {
+		result = SpliceLines(result, splices[i].Start, splices[i].End, splices[i].Lines)
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineTransformOptions controls how TransformLines compares lines
+type LineTransformOptions struct {
+	IgnoreCase bool
+	Descending bool
+}
+
+// TransformLines applies the operations to lines in order. Supported
+// operations are sort, sort_numeric, unique, reverse, shuffle and
+// trim_trailing.
+func TransformLines(lines []string, operations []string, opts LineTransformOptions) ([]string, error) {
+	result := make([]string, len(lines))
+	copy(result, lines)
+
+	key := func(line string) string {
+		if opts.IgnoreCase {
+			return strings.ToLower(line)
+		}
+		return line
+	}
+
+	for _, operation := range operations {
+		switch strings.TrimSpace(operation) {
+		case "sort":
+			sort.SliceStable(result, func(i, j int) bool {
+				if opts.Descending {
+					return key(result[i]) > key(result[j])
+				}
+				return key(result[i]) < key(result[j])
+			})
+		case "sort_numeric":
+			sort.SliceStable(result, func(i, j int) bool {
+				a, b := leadingNumber(result[i]), leadingNumber(result[j])
+				if opts.Descending {
+					return a > b
+				}
+				return a < b
+			})
+		case "unique":
+			seen := make(map[string]bool)
+			unique := result[:0]
+			for _, line := range result {
+				if !seen[key(line)] {
+					seen[key(line)] = true
+					unique = append(unique, line)
+				}
+			}
+			result = unique
+		case "reverse":
+			for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+				result[i], result[j] = result[j], result[i]
+			}
+		case "shuffle":
+			rand.Shuffle(len(result), func(i, j int) {
+				result[i], result[j] = result[j], result[i]
+			})
+		case "trim_trailing":
+			for i, line := range result {
+				result[i] = strings.TrimRight(line, " \t\r")
+			}
+		default:
+			return nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown operation: %s", operation))
+		}
+	}
+
+	return result, nil
+}
+
+// leadingNumber parses the number at the start of a line the way sort -n
+// does; lines without one count as zero
+func leadingNumber(line string) float64 {
+	line = strings.TrimSpace(line)
+	end := 0
+	for end < len(line) {
+		c := line[end]
+		if (c >= '0' && c <= '9') || c == '.' || ((c == '-' || c == '+') && end == 0) {
+			end++
+			continue
+		}
+		break
+	}
+	for end > 0 {
+		if value, err := strconv.ParseFloat(line[:end], 64); err == nil {
+			return value
+		}
+		end--
+	}
+	return 0
+}
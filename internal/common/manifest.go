@@ -0,0 +1,162 @@
+package common
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// ManifestOptions controls which files BuildManifest records
+type ManifestOptions struct {
+	IncludeHidden    bool
+	RespectGitignore bool
+	Exclude          []string
+}
+
+// BuildManifest walks root and records the size, modification time and
+// SHA-256 digest of every regular file, sorted by relative path
+func BuildManifest(root string, opts ManifestOptions) (*types.Manifest, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", root)
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var ignore *GitignoreMatcher
+	if opts.RespectGitignore {
+		ignore = LoadGitignore(root)
+	}
+
+	manifest := &types.Manifest{Root: absRoot, CreatedAt: time.Now(), Files: []types.ManifestEntry{}}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil // Skip unreadable entries
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if SkipInWalk(path, d.Type()) || !manifestIncludes(rel, d, opts, ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		fileInfo, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		checksum, err := CalculateFileChecksum(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+
+		manifest.Files = append(manifest.Files, types.ManifestEntry{
+			Path:    rel,
+			Size:    fileInfo.Size(),
+			ModTime: fileInfo.ModTime().UTC(),
+			SHA256:  checksum,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+
+	return manifest, nil
+}
+
+// VerifyManifest compares the current state of root against a manifest.
+// Modification times are only compared when compareMtime is set, since
+// copies and checkouts rarely preserve them.
+func VerifyManifest(manifest *types.Manifest, root string, opts ManifestOptions, compareMtime bool) (*types.ManifestDiff, error) {
+	current, err := BuildManifest(root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &types.ManifestDiff{
+		Root:    current.Root,
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []types.ManifestChange{},
+	}
+
+	expected := make(map[string]types.ManifestEntry, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		expected[entry.Path] = entry
+	}
+
+	for _, entry := range current.Files {
+		old, ok := expected[entry.Path]
+		if !ok {
+			diff.Added = append(diff.Added, entry.Path)
+			continue
+		}
+		delete(expected, entry.Path)
+
+		var reasons []string
+		if old.Size != entry.Size {
+			reasons = append(reasons, fmt.Sprintf("size %d -> %d", old.Size, entry.Size))
+		}
+		if old.SHA256 != entry.SHA256 {
+			reasons = append(reasons, "content")
+		}
+		if compareMtime && !old.ModTime.Equal(entry.ModTime) {
+			reasons = append(reasons, "mtime")
+		}
+
+		if len(reasons) > 0 {
+			diff.Changed = append(diff.Changed, types.ManifestChange{Path: entry.Path, Reasons: reasons})
+		} else {
+			diff.Unchanged++
+		}
+	}
+
+	for path := range expected {
+		diff.Removed = append(diff.Removed, path)
+	}
+	sort.Strings(diff.Removed)
+
+	return diff, nil
+}
+
+func manifestIncludes(rel string, d fs.DirEntry, opts ManifestOptions, ignore *GitignoreMatcher) bool {
+	if !opts.IncludeHidden && strings.HasPrefix(d.Name(), ".") {
+		return false
+	}
+	if ignore != nil && ignore.Match(rel, d.IsDir()) {
+		return false
+	}
+	for _, pattern := range opts.Exclude {
+		if MatchGlob(pattern, rel, true) {
+			return false
+		}
+	}
+	return true
+}
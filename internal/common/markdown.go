@@ -0,0 +1,296 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"jarvis/internal/types"
+)
+
+var (
+	atxHeadingPattern      = regexp.MustCompile(`^( {0,3})(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderlinePattern = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	tocStartPattern        = regexp.MustCompile(`(?i)^\s*<!--\s*toc\s*-->\s*$`)
+	tocEndPattern          = regexp.MustCompile(`(?i)^\s*<!--\s*(?:tocstop|/toc)\s*-->\s*$`)
+	markdownLinkPattern    = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	// Section numbers such as "1. ", "2.3 " or "2.3. "; a bare "2024 " is
+	// left alone
+	headingNumberPattern = regexp.MustCompile(`^(?:\d+(?:\.\d+)*\.|\d+(?:\.\d+)+)[ \t]+`)
+)
+
+// markdownHeadingLine is a heading together with where it sits in the
+// document lines
+type markdownHeadingLine struct {
+	types.MarkdownHeading
+	index  int
+	indent string
+	setext bool
+}
+
+// parseMarkdownHeadings finds ATX (# Title) and setext (Title / =====)
+// headings, skipping front matter and fenced code blocks
+func parseMarkdownHeadings(lines []string) []markdownHeadingLine {
+	var headings []markdownHeadingLine
+	used := make(map[string]int)
+	add := func(level int, text string, index int, indent string, setext bool) {
+		text = strings.TrimSpace(text)
+		headings = append(headings, markdownHeadingLine{
+			MarkdownHeading: types.MarkdownHeading{
+				Level:  level,
+				Text:   text,
+				Line:   index + 1,
+				Anchor: markdownAnchor(text, used),
+			},
+			index:  index,
+			indent: indent,
+			setext: setext,
+		})
+	}
+
+	fence := ""
+	for i := markdownBodyStart(lines); i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.TrimRight(trimmed, fence[:1]+" \t") == "" {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+			continue
+		}
+
+		if match := atxHeadingPattern.FindStringSubmatch(line); match != nil {
+			add(len(match[2]), match[3], i, match[1], false)
+			continue
+		}
+
+		if i+1 < len(lines) && isSetextText(line) {
+			if match := setextUnderlinePattern.FindStringSubmatch(lines[i+1]); match != nil {
+				level := 2
+				if match[1][0] == '=' {
+					level = 1
+				}
+				add(level, line, i, line[:len(line)-len(strings.TrimLeft(line, " "))], true)
+				i++
+			}
+		}
+	}
+	return headings
+}
+
+// markdownBodyStart returns the index of the first line after any YAML
+// front matter
+func markdownBodyStart(lines []string) int {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t") != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimRight(lines[i], " \t"); trimmed == "---" || trimmed == "..." {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// isSetextText reports whether line can be the text of a setext heading
+// rather than a list item, quote, table row or HTML block
+func isSetextText(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || len(line)-len(strings.TrimLeft(line, " ")) > 3 {
+		return false
+	}
+	if strings.ContainsRune("-*+>|<#", rune(trimmed[0])) {
+		return false
+	}
+	digits := strings.TrimLeft(trimmed, "0123456789")
+	return len(digits) == len(trimmed) || !(strings.HasPrefix(digits, ".") || strings.HasPrefix(digits, ")"))
+}
+
+// markdownAnchor returns the GitHub-style anchor for a heading, adding -1,
+// -2, ... to repeated headings
+func markdownAnchor(text string, used map[string]int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(markdownPlainText(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+
+	slug := b.String()
+	count := used[slug]
+	used[slug] = count + 1
+	if count > 0 {
+		return fmt.Sprintf("%s-%d", slug, count)
+	}
+	return slug
+}
+
+// markdownPlainText replaces links and images in heading text with their
+// label
+func markdownPlainText(text string) string {
+	return markdownLinkPattern.ReplaceAllString(text, "$1")
+}
+
+func markdownNewline(content string) string {
+	if strings.Contains(content, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// ParseMarkdownHeadings returns the outline of a Markdown document
+func ParseMarkdownHeadings(content string) []types.MarkdownHeading {
+	var headings []types.MarkdownHeading
+	for _, heading := range parseMarkdownHeadings(SplitLines(content)) {
+		headings = append(headings, heading.MarkdownHeading)
+	}
+	return headings
+}
+
+// GenerateMarkdownTOC renders the headings between minLevel and maxLevel as
+// a nested list of links, indented relative to the shallowest heading
+func GenerateMarkdownTOC(headings []types.MarkdownHeading, minLevel, maxLevel int, ordered bool) string {
+	top := 0
+	for _, heading := range headings {
+		if heading.Level >= minLevel && heading.Level <= maxLevel && (top == 0 || heading.Level < top) {
+			top = heading.Level
+		}
+	}
+
+	marker, width := "-", 2
+	if ordered {
+		marker, width = "1.", 3
+	}
+
+	var b strings.Builder
+	for _, heading := range headings {
+		if heading.Level < minLevel || heading.Level > maxLevel {
+			continue
+		}
+		indent := strings.Repeat(" ", (heading.Level-top)*width)
+		fmt.Fprintf(&b, "%s%s [%s](#%s)\n", indent, marker, markdownPlainText(heading.Text), heading.Anchor)
+	}
+	return b.String()
+}
+
+// UpdateMarkdownTOC regenerates the table of contents between <!-- toc -->
+// and <!-- tocstop --> markers. Without markers, a marked TOC is inserted
+// after a leading level 1 title, or at the top of the document.
+func UpdateMarkdownTOC(content string, minLevel, maxLevel int, ordered bool) (string, error) {
+	lines := SplitLines(content)
+	headings := parseMarkdownHeadings(lines)
+
+	start, end := -1, -1
+	for i, line := range lines {
+		if start == -1 && tocStartPattern.MatchString(line) {
+			start = i
+		} else if start != -1 && tocEndPattern.MatchString(line) {
+			end = i
+			break
+		}
+	}
+	if start != -1 && end == -1 {
+		return "", fmt.Errorf("TOC marker on line %d has no closing <!-- tocstop --> marker", start+1)
+	}
+
+	var outline []types.MarkdownHeading
+	for _, heading := range headings {
+		outline = append(outline, heading.MarkdownHeading)
+	}
+	toc := SplitLines(strings.TrimSuffix(GenerateMarkdownTOC(outline, minLevel, maxLevel, ordered), "\n"))
+
+	openMarker, closeMarker := "<!-- toc -->", "<!-- tocstop -->"
+	if start != -1 {
+		openMarker, closeMarker = lines[start], lines[end]
+	}
+	block := append([]string{openMarker, ""}, toc...)
+	block = append(block, "", closeMarker)
+
+	var result []string
+	switch {
+	case start != -1:
+		result = SpliceLines(lines, start, end+1, block)
+	case len(headings) > 0 && headings[0].Level == 1:
+		at := headings[0].index + 1
+		if headings[0].setext {
+			at++
+		}
+		result = append(result, lines[:at]...)
+		result = append(result, "")
+		result = append(result, block...)
+		if at < len(lines) && strings.TrimSpace(lines[at]) != "" {
+			result = append(result, "")
+		}
+		result = append(result, lines[at:]...)
+	default:
+		at := markdownBodyStart(lines)
+		result = append(result, lines[:at]...)
+		result = append(result, block...)
+		result = append(result, "")
+		result = append(result, lines[at:]...)
+	}
+
+	return strings.Join(result, markdownNewline(content)), nil
+}
+
+// NumberMarkdownHeadings prefixes headings between minLevel and maxLevel
+// with hierarchical section numbers (1., 1.1, 1.2, 2., ...), replacing any
+// existing numbers. With remove set, existing numbers are only stripped.
+// Renumbered setext headings become ATX headings, since a numbered line
+// above an underline would read as a list item.
+func NumberMarkdownHeadings(content string, minLevel, maxLevel int, remove bool) (string, int) {
+	lines := SplitLines(content)
+	counters := make([]int, 7)
+	underlines := make(map[int]bool)
+	changed := 0
+
+	for _, heading := range parseMarkdownHeadings(lines) {
+		if heading.Level < minLevel || heading.Level > maxLevel {
+			continue
+		}
+
+		text := headingNumberPattern.ReplaceAllString(heading.Text, "")
+		if !remove {
+			counters[heading.Level]++
+			for level := heading.Level + 1; level < len(counters); level++ {
+				counters[level] = 0
+			}
+			var parts []string
+			for level := minLevel; level <= heading.Level; level++ {
+				parts = append(parts, strconv.Itoa(counters[level]))
+			}
+			number := strings.Join(parts, ".")
+			if len(parts) == 1 {
+				number += "."
+			}
+			text = number + " " + text
+		}
+		if text == heading.Text {
+			continue
+		}
+
+		lines[heading.index] = heading.indent + strings.Repeat("#", heading.Level) + " " + text
+		if heading.setext {
+			underlines[heading.index+1] = true
+		}
+		changed++
+	}
+
+	result := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if !underlines[i] {
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, markdownNewline(content)), changed
+}
@@ -0,0 +1,460 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// ProbeMedia reports duration, codecs, bitrate and resolution of an audio or
+// video file. ffprobe is used when installed; otherwise WAV and MP4/MOV
+// files are parsed natively.
+func ProbeMedia(ctx context.Context, path string, useFFprobe bool) (*types.MediaInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("path is a directory")
+	}
+
+	var warning string
+	if useFFprobe {
+		if ffprobe, err := exec.LookPath("ffprobe"); err == nil {
+			info, err := probeWithFFprobe(ctx, ffprobe, path)
+			if err == nil {
+				info.FileSize = stat.Size()
+				return info, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			warning = fmt.Sprintf("ffprobe failed (%v), used native parser", err)
+		} else {
+			warning = "ffprobe not found, used native parser"
+		}
+	}
+
+	info, err := probeNative(path)
+	if err != nil {
+		if warning != "" {
+			return nil, fmt.Errorf("%s: %w", warning, err)
+		}
+		return nil, err
+	}
+	info.FileSize = stat.Size()
+	info.Warning = warning
+	if info.BitRate == 0 && info.Duration > 0 {
+		info.BitRate = int64(float64(stat.Size()*8) / info.Duration)
+	}
+	return info, nil
+}
+
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		Index         int    `json:"index"`
+		CodecType     string `json:"codec_type"`
+		CodecName     string `json:"codec_name"`
+		Width         int    `json:"width"`
+		Height        int    `json:"height"`
+		AvgFrameRate  string `json:"avg_frame_rate"`
+		SampleRate    string `json:"sample_rate"`
+		Channels      int    `json:"channels"`
+		BitsPerSample string `json:"bits_per_raw_sample"`
+		BitRate       string `json:"bit_rate"`
+	} `json:"streams"`
+}
+
+func probeWithFFprobe(ctx context.Context, ffprobe, path string) (*types.MediaInfo, error) {
+	cmd := exec.CommandContext(ctx, ffprobe, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output: %w", err)
+	}
+
+	info := &types.MediaInfo{
+		Path:    path,
+		Format:  probe.Format.FormatName,
+		Source:  "ffprobe",
+		Streams: []types.MediaStream{},
+	}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	info.BitRate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	for _, s := range probe.Streams {
+		stream := types.MediaStream{
+			Index:    s.Index,
+			Type:     s.CodecType,
+			Codec:    s.CodecName,
+			Width:    s.Width,
+			Height:   s.Height,
+			Channels: s.Channels,
+		}
+		stream.BitDepth, _ = strconv.Atoi(s.BitsPerSample)
+		stream.SampleRate, _ = strconv.Atoi(s.SampleRate)
+		stream.BitRate, _ = strconv.ParseInt(s.BitRate, 10, 64)
+		if s.CodecType == "video" && s.AvgFrameRate != "" && s.AvgFrameRate != "0/0" {
+			stream.FrameRate = formatFrameRate(s.AvgFrameRate)
+		}
+		info.Streams = append(info.Streams, stream)
+	}
+
+	return info, nil
+}
+
+// formatFrameRate turns an ffprobe rational such as 30000/1001 into 29.97
+func formatFrameRate(rate string) string {
+	parts := strings.SplitN(rate, "/", 2)
+	if len(parts) != 2 {
+		return rate
+	}
+	num, err1 := strconv.ParseFloat(parts[0], 64)
+	den, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil || den == 0 {
+		return rate
+	}
+	return strconv.FormatFloat(roundTo(num/den, 3), 'f', -1, 64)
+}
+
+func probeNative(path string) (*types.MediaInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("file too small to be a media file")
+	}
+
+	info := &types.MediaInfo{
+		Path:    path,
+		Source:  "native",
+		Streams: []types.MediaStream{},
+	}
+
+	switch {
+	case string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		info.Format = "wav"
+		err = probeWAV(file, info)
+	case string(header[4:8]) == "ftyp":
+		info.Format = "mp4"
+		if brand := string(header[8:12]); brand == "qt  " {
+			info.Format = "mov"
+		}
+		err = probeMP4(file, info)
+	default:
+		return nil, fmt.Errorf("unsupported media format (native parsing supports WAV and MP4/MOV; install ffprobe for other formats)")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// maxWAVFmtSize bounds the fmt chunk of a WAV file, which is 16 to 40
+// bytes in practice; its size comes from the file and is never trusted
+const maxWAVFmtSize = 1024
+
+func probeWAV(file *os.File, info *types.MediaInfo) error {
+	var stream *types.MediaStream
+	var byteRate uint32
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			break
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size > maxWAVFmtSize {
+				return fmt.Errorf("invalid WAV fmt chunk of %d bytes", size)
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(file, data); err != nil || len(data) < 16 {
+				return fmt.Errorf("invalid WAV fmt chunk")
+			}
+			formatTag := binary.LittleEndian.Uint16(data[0:2])
+			bitDepth := int(binary.LittleEndian.Uint16(data[14:16]))
+			byteRate = binary.LittleEndian.Uint32(data[8:12])
+			stream = &types.MediaStream{
+				Type:       "audio",
+				Codec:      wavCodecName(formatTag, bitDepth),
+				Channels:   int(binary.LittleEndian.Uint16(data[2:4])),
+				SampleRate: int(binary.LittleEndian.Uint32(data[4:8])),
+				BitDepth:   bitDepth,
+				BitRate:    int64(byteRate) * 8,
+			}
+			if size%2 == 1 {
+				file.Seek(1, io.SeekCurrent)
+			}
+			continue
+		case "data":
+			if byteRate > 0 {
+				info.Duration = roundTo(float64(size)/float64(byteRate), 3)
+			}
+		}
+
+		// Chunks are padded to an even size
+		if _, err := file.Seek(size+size%2, io.SeekCurrent); err != nil {
+			break
+		}
+	}
+
+	if stream == nil {
+		return fmt.Errorf("WAV file has no fmt chunk")
+	}
+	info.BitRate = stream.BitRate
+	info.Streams = append(info.Streams, *stream)
+	return nil
+}
+
+func wavCodecName(formatTag uint16, bitDepth int) string {
+	switch formatTag {
+	case 1, 0xFFFE:
+		if bitDepth == 8 {
+			return "pcm_u8"
+		}
+		return fmt.Sprintf("pcm_s%dle", bitDepth)
+	case 3:
+		return fmt.Sprintf("pcm_f%dle", bitDepth)
+	case 6:
+		return "pcm_alaw"
+	case 7:
+		return "pcm_mulaw"
+	case 0x55:
+		return "mp3"
+	default:
+		return fmt.Sprintf("0x%04x", formatTag)
+	}
+}
+
+// mp4Box is an ISO base media box with its payload
+type mp4Box struct {
+	kind string
+	data []byte
+}
+
+func probeMP4(file *os.File, info *types.MediaInfo) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Locate the moov box at the top level; the mdat payload is skipped
+	var moov []byte
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			break
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		headerSize := int64(8)
+		if size == 1 {
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(file, large); err != nil {
+				break
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headerSize = 16
+		}
+		if size == 0 || size < headerSize {
+			break
+		}
+
+		if string(header[4:8]) == "moov" {
+			if size-headerSize > 256*1024*1024 {
+				return fmt.Errorf("moov box too large")
+			}
+			moov = make([]byte, size-headerSize)
+			if _, err := io.ReadFull(file, moov); err != nil {
+				return fmt.Errorf("truncated moov box")
+			}
+			break
+		}
+		if _, err := file.Seek(size-headerSize, io.SeekCurrent); err != nil {
+			break
+		}
+	}
+	if moov == nil {
+		return fmt.Errorf("no moov box found (file may be incomplete)")
+	}
+
+	for _, box := range readMP4Boxes(moov) {
+		switch box.kind {
+		case "mvhd":
+			timescale, duration := mp4HeaderDuration(box.data)
+			if timescale > 0 {
+				info.Duration = roundTo(float64(duration)/float64(timescale), 3)
+			}
+		case "trak":
+			if stream, ok := probeMP4Track(box.data); ok {
+				stream.Index = len(info.Streams)
+				info.Streams = append(info.Streams, stream)
+			}
+		}
+	}
+
+	return nil
+}
+
+func probeMP4Track(trak []byte) (types.MediaStream, bool) {
+	var stream types.MediaStream
+	var width, height int
+
+	for _, box := range readMP4Boxes(trak) {
+		switch box.kind {
+		case "tkhd":
+			// Width and height are 16.16 fixed point values at the end
+			if len(box.data) >= 8 {
+				width = int(binary.BigEndian.Uint32(box.data[len(box.data)-8:]) >> 16)
+				height = int(binary.BigEndian.Uint32(box.data[len(box.data)-4:]) >> 16)
+			}
+		case "mdia":
+			for _, mdia := range readMP4Boxes(box.data) {
+				switch mdia.kind {
+				case "hdlr":
+					if len(mdia.data) >= 12 {
+						switch string(mdia.data[8:12]) {
+						case "vide":
+							stream.Type = "video"
+						case "soun":
+							stream.Type = "audio"
+						default:
+							stream.Type = strings.TrimSpace(string(mdia.data[8:12]))
+						}
+					}
+				case "minf":
+					probeMP4SampleEntry(mdia.data, &stream)
+				}
+			}
+		}
+	}
+
+	if stream.Type == "" {
+		return stream, false
+	}
+	if stream.Type == "video" && stream.Width == 0 {
+		stream.Width, stream.Height = width, height
+	}
+	return stream, true
+}
+
+func probeMP4SampleEntry(minf []byte, stream *types.MediaStream) {
+	for _, box := range readMP4Boxes(minf) {
+		if box.kind != "stbl" {
+			continue
+		}
+		for _, stbl := range readMP4Boxes(box.data) {
+			// stsd: version/flags, entry count, then the first sample entry
+			if stbl.kind != "stsd" || len(stbl.data) < 16 {
+				continue
+			}
+			entry := stbl.data[8:]
+			stream.Codec = mp4CodecName(string(entry[4:8]))
+			switch stream.Type {
+			case "video":
+				if len(entry) >= 36 {
+					stream.Width = int(binary.BigEndian.Uint16(entry[32:34]))
+					stream.Height = int(binary.BigEndian.Uint16(entry[34:36]))
+				}
+			case "audio":
+				if len(entry) >= 36 {
+					stream.Channels = int(binary.BigEndian.Uint16(entry[24:26]))
+					stream.BitDepth = int(binary.BigEndian.Uint16(entry[26:28]))
+					stream.SampleRate = int(binary.BigEndian.Uint32(entry[32:36]) >> 16)
+				}
+			}
+		}
+	}
+}
+
+func mp4CodecName(fourcc string) string {
+	switch fourcc {
+	case "avc1", "avc3":
+		return "h264"
+	case "hvc1", "hev1":
+		return "hevc"
+	case "av01":
+		return "av1"
+	case "vp09":
+		return "vp9"
+	case "mp4a":
+		return "aac"
+	case "Opus":
+		return "opus"
+	case "fLaC":
+		return "flac"
+	case "ac-3":
+		return "ac3"
+	case "ec-3":
+		return "eac3"
+	default:
+		return strings.TrimSpace(fourcc)
+	}
+}
+
+// mp4HeaderDuration reads timescale and duration from an mvhd or mdhd box
+func mp4HeaderDuration(data []byte) (uint32, uint64) {
+	if len(data) < 20 {
+		return 0, 0
+	}
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(data[20:24]), binary.BigEndian.Uint64(data[24:32])
+	}
+	return binary.BigEndian.Uint32(data[12:16]), uint64(binary.BigEndian.Uint32(data[16:20]))
+}
+
+func readMP4Boxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		kind := string(data[4:8])
+		headerSize := uint64(8)
+		if size == 1 {
+			if len(data) < 16 {
+				break
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		} else if size == 0 {
+			size = uint64(len(data))
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			break
+		}
+		boxes = append(boxes, mp4Box{kind: kind, data: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestProbeWAVRejectsOversizedFmtChunk(t *testing.T) {
+	wav := []byte("RIFF\x24\x00\x00\x00WAVEfmt ")
+	wav = binary.LittleEndian.AppendUint32(wav, 0xF0000000)
+	wav = append(wav, make([]byte, 16)...)
+	path := filepath.Join(t.TempDir(), "crafted.wav")
+	if err := os.WriteFile(path, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := probeNative(path)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("probeNative accepted a fmt chunk longer than the file")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("probeNative allocated %d bytes for a %d byte file", allocated, len(wav))
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import "fmt"
+
+// Languages tool result messages can be given in
+const (
+	LanguageEnglish = "en"
+	LanguageTurkish = "tr"
+)
+
+// Languages lists the languages of the language setting
+var Languages = []string{LanguageEnglish, LanguageTurkish}
+
+// messageCatalogs map the English format of every message to its
+// translation, by language. English is the language messages are written
+// in, so it needs no catalog.
+var messageCatalogs = map[string]map[string]string{
+	LanguageTurkish: turkishMessages,
+}
+
+// Localize formats a tool result message in the configured language.
+// format is the English message; it is used as it is when the language has
+// no translation of it.
+func Localize(format string, args ...any) string {
+	return LocalizeIn(Get().Language, format, args...)
+}
+
+// LocalizeIn formats a tool result message in language
+func LocalizeIn(language, format string, args ...any) string {
+	if translated, ok := messageCatalogs[language][format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,546 @@
+package common
+
+// turkishMessages are the Turkish translations of the messages
+var turkishMessages = map[string]string{
+	" (file is now %d bytes; use expected_offset=%d for the next chunk)": " (dosya artık %d bayt; sonraki parça için expected_offset=%d kullanın)",
+	" with placeholders: %s":                             " (yer tutucular: %s)",
+	"%d groups of duplicate files, %s reclaimable\n%s":   "%d yinelenen dosya grubu, geri kazanılabilir alan: %s\n%s",
+	"%s and %s are identical":                            "%s ve %s aynı",
+	"%s has not run yet":                                 "%s henüz çalışmadı",
+	"%s is not a text file":                              "%s bir metin dosyası değil",
+	"%s; rolled back %d file(s)":                         "%s; %d dosya geri alındı",
+	"%sPreview of changes for %s:\n%s":                   "%s%s için değişikliklerin önizlemesi:\n%s",
+	"%s\nWritten to %s":                                  "%s\n%s dosyasına yazıldı",
+	"'%s' and '%s' have the same settings":               "'%s' ve '%s' aynı ayarlara sahip",
+	"... %d more findings not shown\n":                   "... gösterilmeyen %d bulgu daha var\n",
+	"... (%d more entries, use offset=%d to continue)\n": "... (%d girdi daha var, devam etmek için offset=%d kullanın)\n",
+	"; rolled back %d file(s)":                           "; %d dosya geri alındı",
+	"Access to base directory is not allowed":            "Temel dizine erişime izin verilmiyor",
+	"Access to one or both paths is not allowed":         "Yollardan birine ya da ikisine erişime izin verilmiyor",
+	"Access to path %s (file %d) is not allowed":         "%s yoluna (dosya %d) erişime izin verilmiyor",
+	"Access to the config file is not allowed":           "Yapılandırma dosyasına erişime izin verilmiyor",
+	"Access to the dictionary path is not allowed":       "Sözlük yoluna erişime izin verilmiyor",
+	"Access to the output path is not allowed":           "Çıktı yoluna erişime izin verilmiyor",
+	"Access to the reference path is not allowed":        "Referans yoluna erişime izin verilmiyor",
+	"Access to this directory is not allowed":            "Bu dizine erişime izin verilmiyor",
+	"Access to this path is not allowed":                 "Bu yola erişime izin verilmiyor",
+	"Access to working directory %s is not allowed":      "%s çalışma dizinine erişime izin verilmiyor",
+	"Access to working directory is not allowed":         "Çalışma dizinine erişime izin verilmiyor",
+	"Already formatted: %s":                              "Zaten biçimlendirilmiş: %s",
+	"Applied %d insertions to %s":                        "%[2]s dosyasına %[1]d ekleme uygulandı",
+	"Applied %s to %s\n\nDiff:\n%s":                      "%[1]s, %[2]s dosyasına uygulandı\n\nFark:\n%[3]s",
+	"Applied patch to %d of %d file(s)\n":                "Yama %[2]d dosyadan %[1]d tanesine uygulandı\n",
+	"Approval required: %s\nNothing was done. The approval token was written to the server log; ask the user to approve the operation with it, or to discard it. The token expires at %s.": "Onay gerekli: %s\nHiçbir şey yapılmadı. Onay belirteci sunucu günlüğüne yazıldı; kullanıcıdan işlemi bu belirteçle onaylamasını ya da iptal etmesini isteyin. Belirtecin süresi %s tarihinde doluyor.",
+	"Attribute '%s' set on %s":                                                            "'%s' özniteliği %s üzerinde ayarlandı",
+	"Batch fetch failed: %v":                                                              "Toplu getirme başarısız oldu: %v",
+	"Beautified %s with %s\n%s":                                                           "%[1]s, %[2]s ile güzelleştirildi\n%[3]s",
+	"Cancelled %s after %d runs":                                                          "%s, %d çalıştırmadan sonra iptal edildi",
+	"Checksum mismatch. Expected %s: %s, Got: %s":                                         "Sağlama toplamı uyuşmuyor. Beklenen %s: %s, Alınan: %s",
+	"Checksum mismatch; the file was not saved. Expected %s: %s, Got: %s":                 "Sağlama toplamı uyuşmuyor; dosya kaydedilmedi. Beklenen %s: %s, Alınan: %s",
+	"Code formatted successfully: %s":                                                     "Kod başarıyla biçimlendirildi: %s",
+	"Command %d contains blocked patterns":                                                "%d. komut engellenmiş kalıplar içeriyor",
+	"Command '%s' found at: %s":                                                           "'%s' komutu şurada bulundu: %s",
+	"Command '%s' not found in PATH":                                                      "'%s' komutu PATH içinde bulunamadı",
+	"Command contains blocked patterns":                                                   "Komut engellenmiş kalıplar içeriyor",
+	"Command pattern '%s' added to blocked list":                                          "'%s' komut kalıbı engellenenler listesine eklendi",
+	"Command pattern '%s' removed from blocked list":                                      "'%s' komut kalıbı engellenenler listesinden çıkarıldı",
+	"Command template '%s' deleted":                                                       "'%s' komut şablonu silindi",
+	"Command template '%s' saved":                                                         "'%s' komut şablonu kaydedildi",
+	"Config profile '%s' deleted":                                                         "'%s' yapılandırma profili silindi",
+	"Config profile '%s' saved":                                                           "'%s' yapılandırma profili kaydedildi",
+	"Configuration exported to %s (%s)":                                                   "Yapılandırma %s dosyasına aktarıldı (%s)",
+	"Configuration imported; changed settings:\n%s":                                       "Yapılandırma içe aktarıldı; değişen ayarlar:\n%s",
+	"Configuration is valid":                                                              "Yapılandırma geçerli",
+	"Configuration key '%s' set to '%s'":                                                  "'%s' yapılandırma anahtarı '%s' olarak ayarlandı",
+	"Configuration reloaded; changed settings:\n%s":                                       "Yapılandırma yeniden yüklendi; değişen ayarlar:\n%s",
+	"Configuration reloaded; no settings changed":                                         "Yapılandırma yeniden yüklendi; hiçbir ayar değişmedi",
+	"Configuration reset to default values":                                               "Yapılandırma varsayılan değerlere sıfırlandı",
+	"Configuration rolled back to version %d; changed settings:\n%s":                      "Yapılandırma %d sürümüne geri alındı; değişen ayarlar:\n%s",
+	"Configuration version %d has the same settings as the current one":                   "%d yapılandırma sürümü geçerli olanla aynı ayarlara sahip",
+	"Content is not an image: %s":                                                         "İçerik bir görsel değil: %s",
+	"Content successfully appended to %s":                                                 "İçerik %s dosyasının sonuna başarıyla eklendi",
+	"Content successfully written to %s":                                                  "İçerik %s dosyasına başarıyla yazıldı",
+	"Created branch %s":                                                                   "%s dalı oluşturuldu",
+	"DRIFT: %d added, %d removed, %d changed":                                             "SAPMA: %d eklendi, %d kaldırıldı, %d değişti",
+	"DRY RUN - %d changes would be made to %s:\n":                                         "DENEME ÇALIŞTIRMASI - %[2]s dosyasında %[1]d değişiklik yapılacak:\n",
+	"DRY RUN - %d renames planned, %d conflicts:\n%s":                                     "DENEME ÇALIŞTIRMASI - %d yeniden adlandırma planlandı, %d çakışma:\n%s",
+	"DRY RUN - %s\n%s":                                                                    "DENEME ÇALIŞTIRMASI - %s\n%s",
+	"DRY RUN - Preview of changes for %s:\n%s":                                            "DENEME ÇALIŞTIRMASI - %s için değişikliklerin önizlemesi:\n%s",
+	"DRY RUN - Preview of formatting changes for %s:\n%s":                                 "DENEME ÇALIŞTIRMASI - %s için biçimlendirme değişikliklerinin önizlemesi:\n%s",
+	"DRY RUN - Preview of moving %s from %s to %s:\n":                                     "DENEME ÇALIŞTIRMASI - %[1]s öğesinin %[2]s dosyasından %[3]s dosyasına taşınmasının önizlemesi:\n",
+	"DRY RUN - would delete %s (%d file(s), %d dir(s), %s)":                               "DENEME ÇALIŞTIRMASI - %s silinecek (%d dosya, %d dizin, %s)",
+	"DRY RUN - would move %s to %s":                                                       "DENEME ÇALIŞTIRMASI - %s, %s konumuna taşınacak",
+	"DRY RUN - would replace %d occurrences in %s:\n%s":                                   "DENEME ÇALIŞTIRMASI - %[2]s içinde %[1]d eşleşme değiştirilecek:\n%[3]s",
+	"DRY RUN - would securely delete %s (%d file(s), %d overwrite pass(es))":              "DENEME ÇALIŞTIRMASI - %s güvenli biçimde silinecek (%d dosya, %d üzerine yazma geçişi)",
+	"Database file not accessible: %v":                                                    "Veritabanı dosyasına erişilemiyor: %v",
+	"Deleted %s from the front matter of %s":                                              "%[1]s, %[2]s dosyasının ön bilgilerinden silindi",
+	"Deleted %s in %d document(s) of %s\n\nDiff:\n%s":                                     "%[1]s, %[3]s dosyasının %[2]d belgesinde silindi\n\nFark:\n%[4]s",
+	"Deleted branch %s":                                                                   "%s dalı silindi",
+	"Deleted snippet %s":                                                                  "%s kod parçacığı silindi",
+	"Deleted: %s":                                                                         "Silindi: %s",
+	"Destination exists and overwrite is false":                                           "Hedef zaten var ve overwrite false",
+	"Directory '%s' added to allowed list":                                                "'%s' dizini izin verilenler listesine eklendi",
+	"Directory '%s' removed from allowed list":                                            "'%s' dizini izin verilenler listesinden çıkarıldı",
+	"Directory created: %s":                                                               "Dizin oluşturuldu: %s",
+	"Discarded: %s":                                                                       "İptal edildi: %s",
+	"Download failed: %v":                                                                 "İndirme başarısız oldu: %v",
+	"Downloaded %s:%s to %s":                                                              "%s:%s, %s konumuna indirildi",
+	"Dry run: %d backup(s) would be removed\n":                                            "Deneme çalıştırması: %d yedek kaldırılacak\n",
+	"Dry run: %d of %d file(s) would apply cleanly\n":                                     "Deneme çalıştırması: %[2]d dosyadan %[1]d tanesine sorunsuz uygulanacak\n",
+	"Either format or output_path must be provided":                                       "format ya da output_path verilmelidir",
+	"Either id or all=true must be specified":                                             "id ya da all=true belirtilmelidir",
+	"Either input or close_stdin must be provided":                                        "input ya da close_stdin verilmelidir",
+	"Either max_count or max_age_days is required when no retention policy is configured": "Saklama politikası yapılandırılmadığında max_count ya da max_age_days gereklidir",
+	"Either since_id or since is required":                                                "since_id ya da since gereklidir",
+	"Either symbol or start_line must be provided":                                        "symbol ya da start_line verilmelidir",
+	"Either width or height must be provided":                                             "width ya da height verilmelidir",
+	"Failed to access file: %v":                                                           "Dosyaya erişilemedi: %v",
+	"Failed to access path: %v":                                                           "Yola erişilemedi: %v",
+	"Failed to access source: %v":                                                         "Kaynağa erişilemedi: %v",
+	"Failed to add allowed directory: %v":                                                 "İzin verilen dizin eklenemedi: %v",
+	"Failed to add blocked command: %v":                                                   "Engellenen komut eklenemedi: %v",
+	"Failed to apply insertions: %v":                                                      "Eklemeler uygulanamadı: %v",
+	"Failed to apply patch: %v":                                                           "Yama uygulanamadı: %v",
+	"Failed to beautify file: %v":                                                         "Dosya güzelleştirilemedi: %v",
+	"Failed to build tree: %v":                                                            "Ağaç oluşturulamadı: %v",
+	"Failed to calculate checksum: %v":                                                    "Sağlama toplamı hesaplanamadı: %v",
+	"Failed to cancel job: %v":                                                            "Görev iptal edilemedi: %v",
+	"Failed to check script: %v":                                                          "Betik denetlenemedi: %v",
+	"Failed to check text: %v":                                                            "Metin denetlenemedi: %v",
+	"Failed to clean up workspace: %v":                                                    "Çalışma alanı temizlenemedi: %v",
+	"Failed to clean up workspaces: %s":                                                   "Çalışma alanları temizlenemedi: %s",
+	"Failed to copy file: %v":                                                             "Dosya kopyalanamadı: %v",
+	"Failed to create backup for %s: %v (%d file(s) already written)":                     "%s için yedek oluşturulamadı: %v (%d dosya zaten yazıldı)",
+	"Failed to create backup for %s: %v":                                                  "%s için yedek oluşturulamadı: %v",
+	"Failed to create backup: %v":                                                         "Yedek oluşturulamadı: %v",
+	"Failed to create destination directory: %v":                                          "Hedef dizin oluşturulamadı: %v",
+	"Failed to create directory for %s: %v (%d file(s) already written)":                  "%s için dizin oluşturulamadı: %v (%d dosya zaten yazıldı)",
+	"Failed to create directory: %v":                                                      "Dizin oluşturulamadı: %v",
+	"Failed to create file: %v":                                                           "Dosya oluşturulamadı: %v",
+	"Failed to create manifest: %v":                                                       "Bildirim dosyası oluşturulamadı: %v",
+	"Failed to create parent directory: %v":                                               "Üst dizin oluşturulamadı: %v",
+	"Failed to create request: %v":                                                        "İstek oluşturulamadı: %v",
+	"Failed to create temp script: %v":                                                    "Geçici betik oluşturulamadı: %v",
+	"Failed to create workspace: %v":                                                      "Çalışma alanı oluşturulamadı: %v",
+	"Failed to decrypt file: %v":                                                          "Dosyanın şifresi çözülemedi: %v",
+	"Failed to delete command template: %v":                                               "Komut şablonu silinemedi: %v",
+	"Failed to delete config profile: %v":                                                 "Yapılandırma profili silinemedi: %v",
+	"Failed to delete front matter key: %v":                                               "Ön bilgi anahtarı silinemedi: %v",
+	"Failed to delete secret: %v":                                                         "Gizli değer silinemedi: %v",
+	"Failed to delete snippet: %v":                                                        "Kod parçacığı silinemedi: %v",
+	"Failed to delete: %v":                                                                "Silinemedi: %v",
+	"Failed to diff config profiles: %v":                                                  "Yapılandırma profilleri karşılaştırılamadı: %v",
+	"Failed to edit %s: %v":                                                               "%s düzenlenemedi: %v",
+	"Failed to edit YAML: %v":                                                             "YAML düzenlenemedi: %v",
+	"Failed to encode backups: %v":                                                        "Yedekler kodlanamadı: %v",
+	"Failed to encode findings: %v":                                                       "Bulgular kodlanamadı: %v",
+	"Failed to encode journal: %v":                                                        "Günlük kodlanamadı: %v",
+	"Failed to encode outline: %v":                                                        "Ana hat kodlanamadı: %v",
+	"Failed to encode snippets: %v":                                                       "Kod parçacıkları kodlanamadı: %v",
+	"Failed to encrypt file: %v":                                                          "Dosya şifrelenemedi: %v",
+	"Failed to expand snippet: %v":                                                        "Kod parçacığı genişletilemedi: %v",
+	"Failed to export configuration: %v":                                                  "Yapılandırma dışa aktarılamadı: %v",
+	"Failed to find duplicate files: %v":                                                  "Yinelenen dosyalar bulunamadı: %v",
+	"Failed to format CSV: %v":                                                            "CSV biçimlendirilemedi: %v",
+	"Failed to format code: %v":                                                           "Kod biçimlendirilemedi: %v",
+	"Failed to format results: %v":                                                        "Sonuçlar biçimlendirilemedi: %v",
+	"Failed to get configuration: %v":                                                     "Yapılandırma alınamadı: %v",
+	"Failed to get extended attribute: %v":                                                "Genişletilmiş öznitelik alınamadı: %v",
+	"Failed to get file info: %v":                                                         "Dosya bilgisi alınamadı: %v",
+	"Failed to get file stats: %v":                                                        "Dosya istatistikleri alınamadı: %v",
+	"Failed to get image info: %v":                                                        "Görsel bilgisi alınamadı: %v",
+	"Failed to get process info for PID %d: %v":                                           "%d PID'li sürecin bilgisi alınamadı: %v",
+	"Failed to get process tree for PID %d: %v":                                           "%d PID'li sürecin ağacı alınamadı: %v",
+	"Failed to get working directory: %v":                                                 "Çalışma dizini alınamadı: %v",
+	"Failed to grant temporary access: %v":                                                "Geçici erişim verilemedi: %v",
+	"Failed to import configuration: %v":                                                  "Yapılandırma içe aktarılamadı: %v",
+	"Failed to insert snippet: %v":                                                        "Kod parçacığı eklenemedi: %v",
+	"Failed to kill process tree %d: %v (stopped: %v)":                                    "%d süreç ağacı sonlandırılamadı: %v (durdurulanlar: %v)",
+	"Failed to list backups: %v":                                                          "Yedekler listelenemedi: %v",
+	"Failed to list config profiles: %v":                                                  "Yapılandırma profilleri listelenemedi: %v",
+	"Failed to list configuration versions: %v":                                           "Yapılandırma sürümleri listelenemedi: %v",
+	"Failed to list connections: %v":                                                      "Bağlantılar listelenemedi: %v",
+	"Failed to list directory: %v":                                                        "Dizin listelenemedi: %v",
+	"Failed to list extended attributes: %v":                                              "Genişletilmiş öznitelikler listelenemedi: %v",
+	"Failed to list listening ports: %v":                                                  "Dinlenen bağlantı noktaları listelenemedi: %v",
+	"Failed to list network interfaces: %v":                                               "Ağ arayüzleri listelenemedi: %v",
+	"Failed to list packages: %v":                                                         "Paketler listelenemedi: %v",
+	"Failed to list processes: %v":                                                        "Süreçler listelenemedi: %v",
+	"Failed to list secrets: %v":                                                          "Gizli değerler listelenemedi: %v",
+	"Failed to list snippets: %v":                                                         "Kod parçacıkları listelenemedi: %v",
+	"Failed to load snippet: %v":                                                          "Kod parçacığı yüklenemedi: %v",
+	"Failed to marshal SSH hosts: %v":                                                     "SSH sunucuları kodlanamadı: %v",
+	"Failed to marshal audit log: %v":                                                     "Denetim günlüğü kodlanamadı: %v",
+	"Failed to marshal blocked commands: %v":                                              "Engellenen komutlar kodlanamadı: %v",
+	"Failed to marshal changed settings: %v":                                              "Değişen ayarlar kodlanamadı: %v",
+	"Failed to marshal client role: %v":                                                   "İstemci rolü kodlanamadı: %v",
+	"Failed to marshal command history: %v":                                               "Komut geçmişi kodlanamadı: %v",
+	"Failed to marshal command result: %v":                                                "Komut sonucu kodlanamadı: %v",
+	"Failed to marshal command results: %v":                                               "Komut sonuçları kodlanamadı: %v",
+	"Failed to marshal command templates: %v":                                             "Komut şablonları kodlanamadı: %v",
+	"Failed to marshal config profiles: %v":                                               "Yapılandırma profilleri kodlanamadı: %v",
+	"Failed to marshal config schema: %v":                                                 "Yapılandırma şeması kodlanamadı: %v",
+	"Failed to marshal configuration versions: %v":                                        "Yapılandırma sürümleri kodlanamadı: %v",
+	"Failed to marshal connections: %v":                                                   "Bağlantılar kodlanamadı: %v",
+	"Failed to marshal differences: %v":                                                   "Farklar kodlanamadı: %v",
+	"Failed to marshal environment: %v":                                                   "Ortam kodlanamadı: %v",
+	"Failed to marshal grant: %v":                                                         "İzin kodlanamadı: %v",
+	"Failed to marshal grants: %v":                                                        "İzinler kodlanamadı: %v",
+	"Failed to marshal image info: %v":                                                    "Görsel bilgisi kodlanamadı: %v",
+	"Failed to marshal job runs: %v":                                                      "Görev çalıştırmaları kodlanamadı: %v",
+	"Failed to marshal job: %v":                                                           "Görev kodlanamadı: %v",
+	"Failed to marshal jobs: %v":                                                          "Görevler kodlanamadı: %v",
+	"Failed to marshal listening ports: %v":                                               "Dinlenen bağlantı noktaları kodlanamadı: %v",
+	"Failed to marshal manifest: %v":                                                      "Bildirim dosyası kodlanamadı: %v",
+	"Failed to marshal media info: %v":                                                    "Medya bilgisi kodlanamadı: %v",
+	"Failed to marshal metrics: %v":                                                       "Ölçümler kodlanamadı: %v",
+	"Failed to marshal network interfaces: %v":                                            "Ağ arayüzleri kodlanamadı: %v",
+	"Failed to marshal packages: %v":                                                      "Paketler kodlanamadı: %v",
+	"Failed to marshal pending approvals: %v":                                             "Bekleyen onaylar kodlanamadı: %v",
+	"Failed to marshal pipeline result: %v":                                               "İşlem hattı sonucu kodlanamadı: %v",
+	"Failed to marshal policy check: %v":                                                  "Politika denetimi kodlanamadı: %v",
+	"Failed to marshal process info: %v":                                                  "Süreç bilgisi kodlanamadı: %v",
+	"Failed to marshal process tree: %v":                                                  "Süreç ağacı kodlanamadı: %v",
+	"Failed to marshal processes: %v":                                                     "Süreçler kodlanamadı: %v",
+	"Failed to marshal result: %v":                                                        "Sonuç kodlanamadı: %v",
+	"Failed to marshal results: %v":                                                       "Sonuçlar kodlanamadı: %v",
+	"Failed to marshal routes: %v":                                                        "Yönlendirmeler kodlanamadı: %v",
+	"Failed to marshal screen: %v":                                                        "Ekran kodlanamadı: %v",
+	"Failed to marshal script check: %v":                                                  "Betik denetimi kodlanamadı: %v",
+	"Failed to marshal secrets: %v":                                                       "Gizli değerler kodlanamadı: %v",
+	"Failed to marshal session output: %v":                                                "Oturum çıktısı kodlanamadı: %v",
+	"Failed to marshal session usage: %v":                                                 "Oturum kullanımı kodlanamadı: %v",
+	"Failed to marshal session: %v":                                                       "Oturum kodlanamadı: %v",
+	"Failed to marshal sessions: %v":                                                      "Oturumlar kodlanamadı: %v",
+	"Failed to marshal system info: %v":                                                   "Sistem bilgisi kodlanamadı: %v",
+	"Failed to marshal value: %v":                                                         "Değer kodlanamadı: %v",
+	"Failed to marshal wait result: %v":                                                   "Bekleme sonucu kodlanamadı: %v",
+	"Failed to marshal watch results: %v":                                                 "İzleme sonuçları kodlanamadı: %v",
+	"Failed to minify file: %v":                                                           "Dosya küçültülemedi: %v",
+	"Failed to move code: %v":                                                             "Kod taşınamadı: %v",
+	"Failed to move file: %v":                                                             "Dosya taşınamadı: %v",
+	"Failed to normalize %s: %v":                                                          "%s normalleştirilemedi: %v",
+	"Failed to open spreadsheet: %v":                                                      "Hesap tablosu açılamadı: %v",
+	"Failed to parse URLs array: %v":                                                      "URL dizisi ayrıştırılamadı: %v",
+	"Failed to parse URLs: %v":                                                            "URL'ler ayrıştırılamadı: %v",
+	"Failed to parse files: %v":                                                           "Dosyalar ayrıştırılamadı: %v",
+	"Failed to parse insertions: %v":                                                      "Eklemeler ayrıştırılamadı: %v",
+	"Failed to parse manifest: %v":                                                        "Bildirim dosyası ayrıştırılamadı: %v",
+	"Failed to parse operations: %v":                                                      "İşlemler ayrıştırılamadı: %v",
+	"Failed to parse params: %v":                                                          "Parametreler ayrıştırılamadı: %v",
+	"Failed to parse patch: %v":                                                           "Yama ayrıştırılamadı: %v",
+	"Failed to parse values: %v":                                                          "Değerler ayrıştırılamadı: %v",
+	"Failed to plan renames: %v":                                                          "Yeniden adlandırmalar planlanamadı: %v",
+	"Failed to prepare SSH command: %v":                                                   "SSH komutu hazırlanamadı: %v",
+	"Failed to probe media: %v":                                                           "Medya incelenemedi: %v",
+	"Failed to process image: %v":                                                         "Görsel işlenemedi: %v",
+	"Failed to prune backups: %v":                                                         "Yedekler budanamadı: %v",
+	"Failed to read %s: %v":                                                               "%s okunamadı: %v",
+	"Failed to read audit log: %v":                                                        "Denetim günlüğü okunamadı: %v",
+	"Failed to read command history: %v":                                                  "Komut geçmişi okunamadı: %v",
+	"Failed to read content: %v":                                                          "İçerik okunamadı: %v",
+	"Failed to read destination file: %v":                                                 "Hedef dosya okunamadı: %v",
+	"Failed to read dictionary: %v":                                                       "Sözlük okunamadı: %v",
+	"Failed to read directory: %v":                                                        "Dizin okunamadı: %v",
+	"Failed to read file %s: %v":                                                          "%s dosyası okunamadı: %v",
+	"Failed to read file chunk: %v":                                                       "Dosya parçası okunamadı: %v",
+	"Failed to read file: %v":                                                             "Dosya okunamadı: %v",
+	"Failed to read front matter: %v":                                                     "Ön bilgiler okunamadı: %v",
+	"Failed to read manifest: %v":                                                         "Bildirim dosyası okunamadı: %v",
+	"Failed to read response: %v":                                                         "Yanıt okunamadı: %v",
+	"Failed to read routing table: %v":                                                    "Yönlendirme tablosu okunamadı: %v",
+	"Failed to read schema: %v":                                                           "Şema okunamadı: %v",
+	"Failed to read sheet: %v":                                                            "Sayfa okunamadı: %v",
+	"Failed to read source file: %v":                                                      "Kaynak dosya okunamadı: %v",
+	"Failed to read value: %v":                                                            "Değer okunamadı: %v",
+	"Failed to reload configuration: %v":                                                  "Yapılandırma yeniden yüklenemedi: %v",
+	"Failed to remove %s: %v (%d file(s) already written)":                                "%s kaldırılamadı: %v (%d dosya zaten yazıldı)",
+	"Failed to remove SSH host: %v":                                                       "SSH sunucusu kaldırılamadı: %v",
+	"Failed to remove allowed directory: %v":                                              "İzin verilen dizin kaldırılamadı: %v",
+	"Failed to remove blocked command: %v":                                                "Engellenen komut kaldırılamadı: %v",
+	"Failed to remove extended attribute: %v":                                             "Genişletilmiş öznitelik kaldırılamadı: %v",
+	"Failed to rename files: %v":                                                          "Dosyalar yeniden adlandırılamadı: %v",
+	"Failed to render template %s: %v":                                                    "%s şablonu oluşturulamadı: %v",
+	"Failed to replace text: %v":                                                          "Metin değiştirilemedi: %v",
+	"Failed to restore backup: %v":                                                        "Yedek geri yüklenemedi: %v",
+	"Failed to revoke temporary access: %v":                                               "Geçici erişim geri alınamadı: %v",
+	"Failed to roll back configuration: %v":                                               "Yapılandırma geri alınamadı: %v",
+	"Failed to sandbox command: %v":                                                       "Komut korumalı alana alınamadı: %v",
+	"Failed to sandbox script: %v":                                                        "Betik korumalı alana alınamadı: %v",
+	"Failed to save SSH host: %v":                                                         "SSH sunucusu kaydedilemedi: %v",
+	"Failed to save command template: %v":                                                 "Komut şablonu kaydedilemedi: %v",
+	"Failed to save config profile: %v":                                                   "Yapılandırma profili kaydedilemedi: %v",
+	"Failed to save file: %v":                                                             "Dosya kaydedilemedi: %v",
+	"Failed to save image: %v":                                                            "Görsel kaydedilemedi: %v",
+	"Failed to save snippet: %v":                                                          "Kod parçacığı kaydedilemedi: %v",
+	"Failed to schedule job: %v":                                                          "Görev zamanlanamadı: %v",
+	"Failed to set configuration: %v":                                                     "Yapılandırma ayarlanamadı: %v",
+	"Failed to set extended attribute: %v":                                                "Genişletilmiş öznitelik ayarlanamadı: %v",
+	"Failed to set file times: %v":                                                        "Dosya zamanları ayarlanamadı: %v",
+	"Failed to set front matter: %v":                                                      "Ön bilgiler ayarlanamadı: %v",
+	"Failed to set secret: %v":                                                            "Gizli değer ayarlanamadı: %v",
+	"Failed to set value: %v":                                                             "Değer ayarlanamadı: %v",
+	"Failed to signal process %d: %v":                                                     "%d sürecine sinyal gönderilemedi: %v",
+	"Failed to start command: %v":                                                         "Komut başlatılamadı: %v",
+	"Failed to stat file: %v":                                                             "Dosya bilgisi okunamadı: %v",
+	"Failed to stat reference file: %v":                                                   "Referans dosyanın bilgisi okunamadı: %v",
+	"Failed to switch config profile: %v":                                                 "Yapılandırma profiline geçilemedi: %v",
+	"Failed to transform lines: %v":                                                       "Satırlar dönüştürülemedi: %v",
+	"Failed to undo edit: %v":                                                             "Düzenleme geri alınamadı: %v",
+	"Failed to undo edits: %v":                                                            "Düzenlemeler geri alınamadı: %v",
+	"Failed to update table of contents: %v":                                              "İçindekiler tablosu güncellenemedi: %v",
+	"Failed to validate configuration: %v":                                                "Yapılandırma doğrulanamadı: %v",
+	"Failed to verify manifest: %v":                                                       "Bildirim dosyası doğrulanamadı: %v",
+	"Failed to watch path: %v":                                                            "Yol izlenemedi: %v",
+	"Failed to write %s: %v (%d file(s) already written)":                                 "%s yazılamadı: %v (%d dosya zaten yazıldı)",
+	"Failed to write %s: %v":                                                              "%s yazılamadı: %v",
+	"Failed to write file %s: %v":                                                         "%s dosyası yazılamadı: %v",
+	"Failed to write file at operation %d: %v":                                            "Dosya %d. işlemde yazılamadı: %v",
+	"Failed to write file: %v":                                                            "Dosya yazılamadı: %v",
+	"Failed to write files: %v":                                                           "Dosyalar yazılamadı: %v",
+	"Failed to write manifest: %v":                                                        "Bildirim dosyası yazılamadı: %v",
+	"File %s (file %d) is not accessible: %v":                                             "%s dosyasına (dosya %d) erişilemiyor: %v",
+	"File %s (file %d): %v":                                                               "%s dosyası (dosya %d): %v",
+	"File already exists and overwrite is false":                                          "Dosya zaten var ve overwrite false",
+	"File copied from %s to %s":                                                           "Dosya %s konumundan %s konumuna kopyalandı",
+	"File decrypted: %s -> %s":                                                            "Dosyanın şifresi çözüldü: %s -> %s",
+	"File does not exist, not created: %s":                                                "Dosya yok, oluşturulmadı: %s",
+	"File downloaded successfully: %s (%s)":                                               "Dosya başarıyla indirildi: %s (%s)",
+	"File encrypted but failed to remove original: %v":                                    "Dosya şifrelendi ancak orijinali kaldırılamadı: %v",
+	"File encrypted: %s -> %s":                                                            "Dosya şifrelendi: %s -> %s",
+	"File has been modified since the continuation token was issued; restart from byte_offset": "Devam belirteci verildiğinden beri dosya değişti; byte_offset ile yeniden başlayın",
+	"File is %d bytes but expected_offset is %d; a chunk may be missing or duplicated":         "Dosya %d bayt ancak expected_offset %d; bir parça eksik ya da yinelenmiş olabilir",
+	"File moved from %s to %s":                     "Dosya %s konumundan %s konumuna taşındı",
+	"File too large: %.1fMB (max: %.1fMB)":         "Dosya çok büyük: %.1fMB (en fazla: %.1fMB)",
+	"Give either path or config":                   "path ya da config verin",
+	"Glob failed: %v":                              "Glob başarısız oldu: %v",
+	"HTTP Error %d: %s":                            "HTTP Hatası %d: %s",
+	"Image downloaded successfully: %s (%s, %s)":   "Görsel başarıyla indirildi: %s (%s, %s)",
+	"Image format mismatch. Expected: %s, Got: %s": "Görsel biçimi uyuşmuyor. Beklenen: %s, Alınan: %s",
+	"Importing would change these settings; repeat with apply=true to import:\n%s": "İçe aktarma şu ayarları değiştirecek; içe aktarmak için apply=true ile tekrarlayın:\n%s",
+	"Inserted snippet %s into %s":                                             "%[1]s kod parçacığı %[2]s dosyasına eklendi",
+	"Session input %q contains blocked patterns":                              "%q oturum girdisi engellenmiş kalıplar içeriyor",
+	"Invalid %s value %q: use an RFC3339 timestamp or a duration like 15m":    "Geçersiz %s değeri %q: RFC3339 zaman damgası ya da 15m gibi bir süre kullanın",
+	"Invalid JSON response: %v":                                               "Geçersiz JSON yanıtı: %v",
+	"Invalid PID":                                                             "Geçersiz PID",
+	"Invalid URL parameter: %v":                                               "Geçersiz URL parametresi: %v",
+	"Invalid URL: %v":                                                         "Geçersiz URL: %v",
+	"Invalid address parameter: %v":                                           "Geçersiz address parametresi: %v",
+	"Invalid argument %s: must be a string, number or boolean":                "Geçersiz %s argümanı: metin, sayı ya da boolean olmalı",
+	"Invalid arguments parameter: must be a JSON object: %v":                  "Geçersiz arguments parametresi: JSON nesnesi olmalı: %v",
+	"Invalid arguments parameter: use a JSON object: %v":                      "Geçersiz arguments parametresi: JSON nesnesi kullanın: %v",
+	"Invalid backup_path parameter: %v":                                       "Geçersiz backup_path parametresi: %v",
+	"Invalid command %d: must be a string or an object: %v":                   "Geçersiz %d. komut: metin ya da nesne olmalı: %v",
+	"Invalid command parameter: %v":                                           "Geçersiz command parametresi: %v",
+	"Invalid commands parameter: %v":                                          "Geçersiz commands parametresi: %v",
+	"Invalid commands parameter: must be a JSON array: %v":                    "Geçersiz commands parametresi: JSON dizisi olmalı: %v",
+	"Invalid content parameter: %v":                                           "Geçersiz content parametresi: %v",
+	"Invalid destination parameter: %v":                                       "Geçersiz destination parametresi: %v",
+	"Invalid destination_path parameter: %v":                                  "Geçersiz destination_path parametresi: %v",
+	"Invalid direction parameter: %v":                                         "Geçersiz direction parametresi: %v",
+	"Invalid directory parameter: %v":                                         "Geçersiz directory parametresi: %v",
+	"Invalid duration %q: use a positive duration like 15m":                   "Geçersiz süre %q: 15m gibi pozitif bir süre kullanın",
+	"Invalid env parameter: %v":                                               "Geçersiz env parametresi: %v",
+	"Invalid expected_checksum parameter: %v":                                 "Geçersiz expected_checksum parametresi: %v",
+	"Invalid filepath parameter: %v":                                          "Geçersiz filepath parametresi: %v",
+	"Invalid files parameter: %v":                                             "Geçersiz files parametresi: %v",
+	"Invalid find parameter: %v":                                              "Geçersiz find parametresi: %v",
+	"Invalid format %q: use json or prometheus":                               "Geçersiz biçim %q: json ya da prometheus kullanın",
+	"Invalid from parameter: %v":                                              "Geçersiz from parametresi: %v",
+	"Invalid headers parameter: %v":                                           "Geçersiz headers parametresi: %v",
+	"Invalid host parameter: %v":                                              "Geçersiz host parametresi: %v",
+	"Invalid id parameter: %v":                                                "Geçersiz id parametresi: %v",
+	"Invalid insertions parameter: %v":                                        "Geçersiz insertions parametresi: %v",
+	"Invalid job_id parameter: %v":                                            "Geçersiz job_id parametresi: %v",
+	"Invalid key parameter: %v":                                               "Geçersiz key parametresi: %v",
+	"Invalid kind parameter: %v":                                              "Geçersiz kind parametresi: %v",
+	"Invalid kind: use one of %s":                                             "Geçersiz kind: şunlardan birini kullanın: %s",
+	"Invalid line range":                                                      "Geçersiz satır aralığı",
+	"Invalid local_path parameter: %v":                                        "Geçersiz local_path parametresi: %v",
+	"Invalid manager parameter: %v":                                           "Geçersiz manager parametresi: %v",
+	"Invalid manifest_path parameter: %v":                                     "Geçersiz manifest_path parametresi: %v",
+	"Invalid mask_patterns parameter: %q: %v":                                 "Geçersiz mask_patterns parametresi: %q: %v",
+	"Invalid message parameter: %v":                                           "Geçersiz message parametresi: %v",
+	"Invalid name parameter: %v":                                              "Geçersiz name parametresi: %v",
+	"Invalid new_path parameter: %v":                                          "Geçersiz new_path parametresi: %v",
+	"Invalid new_string parameter: %v":                                        "Geçersiz new_string parametresi: %v",
+	"Invalid old_path parameter: %v":                                          "Geçersiz old_path parametresi: %v",
+	"Invalid old_string parameter: %v":                                        "Geçersiz old_string parametresi: %v",
+	"Invalid operations in file %s: %v":                                       "%s dosyasında geçersiz işlemler: %v",
+	"Invalid operations parameter: %v":                                        "Geçersiz operations parametresi: %v",
+	"Invalid outcome %q: use success or error":                                "Geçersiz sonuç %q: success ya da error kullanın",
+	"Invalid output_file: %v":                                                 "Geçersiz output_file: %v",
+	"Invalid packages parameter: %v":                                          "Geçersiz packages parametresi: %v",
+	"Invalid parameters parameter: must be a JSON array: %v":                  "Geçersiz parameters parametresi: JSON dizisi olmalı: %v",
+	"Invalid patch parameter: %v":                                             "Geçersiz patch parametresi: %v",
+	"Invalid path parameter: %v":                                              "Geçersiz path parametresi: %v",
+	"Invalid pattern parameter: %v":                                           "Geçersiz pattern parametresi: %v",
+	"Invalid pattern: %v":                                                     "Geçersiz kalıp: %v",
+	"Invalid pipeline: %v":                                                    "Geçersiz işlem hattı: %v",
+	"Invalid port":                                                            "Geçersiz bağlantı noktası",
+	"Invalid query parameter: %v":                                             "Geçersiz query parametresi: %v",
+	"Invalid remote_path parameter: %v":                                       "Geçersiz remote_path parametresi: %v",
+	"Invalid replace parameter: %v":                                           "Geçersiz replace parametresi: %v",
+	"Invalid replacement parameter: %v":                                       "Geçersiz replacement parametresi: %v",
+	"Invalid repo_path parameter: %v":                                         "Geçersiz repo_path parametresi: %v",
+	"Invalid run_as_user: %v":                                                 "Geçersiz run_as_user: %v",
+	"Invalid script parameter: %v":                                            "Geçersiz script parametresi: %v",
+	"Invalid session_id parameter: %v":                                        "Geçersiz session_id parametresi: %v",
+	"Invalid since value %q: use an RFC3339 timestamp or a duration like 15m": "Geçersiz since değeri %q: RFC3339 zaman damgası ya da 15m gibi bir süre kullanın",
+	"Invalid sort_by: %s (use path or matches)":                               "Geçersiz sort_by: %s (path ya da matches kullanın)",
+	"Invalid source parameter: %v":                                            "Geçersiz source parametresi: %v",
+	"Invalid source_path parameter: %v":                                       "Geçersiz source_path parametresi: %v",
+	"Invalid stages parameter: %v":                                            "Geçersiz stages parametresi: %v",
+	"Invalid stages parameter: must be a JSON array of stages: %v":            "Geçersiz stages parametresi: aşamalardan oluşan bir JSON dizisi olmalı: %v",
+	"Invalid status %q: use success or failure":                               "Geçersiz durum %q: success ya da failure kullanın",
+	"Invalid style %q: use word or unified":                                   "Geçersiz stil %q: word ya da unified kullanın",
+	"Invalid timeout of URL %d: %v":                                           "%d. URL'nin zaman aşımı geçersiz: %v",
+	"Invalid timeout parameter: %v":                                           "Geçersiz timeout parametresi: %v",
+	"Invalid timeout_seconds parameter: %v":                                   "Geçersiz timeout_seconds parametresi: %v",
+	"Invalid timestamp (expected RFC3339): %v":                                "Geçersiz zaman damgası (RFC3339 bekleniyor): %v",
+	"Invalid token parameter: %v":                                             "Geçersiz token parametresi: %v",
+	"Invalid tool parameter: %v":                                              "Geçersiz tool parametresi: %v",
+	"Invalid until_pattern: %v":                                               "Geçersiz until_pattern: %v",
+	"Invalid urls parameter: %v":                                              "Geçersiz urls parametresi: %v",
+	"Invalid value parameter: %v":                                             "Geçersiz value parametresi: %v",
+	"Invalid value_path parameter: %v":                                        "Geçersiz value_path parametresi: %v",
+	"Invalid version":                                                         "Geçersiz sürüm",
+	"Invalid working_dir of command %d: %v":                                   "%d. komutun working_dir değeri geçersiz: %v",
+	"Invalid working_dir parameter: %v":                                       "Geçersiz working_dir parametresi: %v",
+	"JSONPath error: %v":                                                      "JSONPath hatası: %v",
+	"Line %d, column %d: %s\n":                                                "Satır %d, sütun %d: %s\n",
+	"Made %d changes to %s:\n":                                                "%[2]s dosyasında %[1]d değişiklik yapıldı:\n",
+	"Manifest has no root; specify path":                                      "Bildirim dosyasının kökü yok; path belirtin",
+	"Manifest of %d files written to %s":                                      "%d dosyanın bildirimi %s dosyasına yazıldı",
+	"Minified %s with %s\n%s":                                                 "%[1]s, %[2]s ile küçültüldü\n%[3]s",
+	"Mixed normalization forms: %d precomposed (NFC) and %d decomposed (NFD) characters\n": "Karışık normalleştirme biçimleri: %d birleşik (NFC) ve %d ayrışık (NFD) karakter\n",
+	"Moved %s from %s to %s\n":        "%[1]s, %[2]s dosyasından %[3]s dosyasına taşındı\n",
+	"No SSH hosts configured":         "Yapılandırılmış SSH sunucusu yok",
+	"No backups found for %s":         "%s için yedek bulunamadı",
+	"No changes needed in %s\n":       "%s içinde değişiklik gerekmiyor\n",
+	"No changes to lines %d-%d in %s": "%[3]s dosyasının %[1]d-%[2]d satırlarında değişiklik yok",
+	"No changes\n":                    "Değişiklik yok\n",
+	"No command templates":            "Komut şablonu yok",
+	"No commands recorded":            "Kaydedilmiş komut yok",
+	"No config profiles":              "Yapılandırma profili yok",
+	"No configuration versions recorded yet; one is kept each time the configuration changes": "Henüz kaydedilmiş yapılandırma sürümü yok; yapılandırma her değiştiğinde bir sürüm saklanır",
+	"No edits recorded":                                           "Kaydedilmiş düzenleme yok",
+	"No extended attributes":                                      "Genişletilmiş öznitelik yok",
+	"No files matched the pattern":                                "Kalıpla eşleşen dosya yok",
+	"No headings changed in %s":                                   "%s içinde hiçbir başlık değişmedi",
+	"No headings found":                                           "Başlık bulunamadı",
+	"No matches found":                                            "Eşleşme bulunamadı",
+	"No occurrences found in %s":                                  "%s içinde eşleşme bulunamadı",
+	"No operations are waiting for approval":                      "Onay bekleyen işlem yok",
+	"No problems found\n":                                         "Sorun bulunamadı\n",
+	"No quota usage recorded for session %s":                      "%s oturumu için kaydedilmiş kota kullanımı yok",
+	"No quota usage to reset":                                     "Sıfırlanacak kota kullanımı yok",
+	"No scheduled jobs":                                           "Zamanlanmış görev yok",
+	"No secrets stored":                                           "Saklanan gizli değer yok",
+	"No session has used a quota yet":                             "Henüz hiçbir oturum kota kullanmadı",
+	"No snippets found":                                           "Kod parçacığı bulunamadı",
+	"No temporary grants in force":                                "Yürürlükte geçici izin yok",
+	"No tool calls recorded":                                      "Kaydedilmiş araç çağrısı yok",
+	"Note: %s\n":                                                  "Not: %s\n",
+	"Operation validation failed: %v":                             "İşlem doğrulaması başarısız oldu: %v",
+	"Output file %s already exists (set overwrite to replace it)": "%s çıktı dosyası zaten var (değiştirmek için overwrite ayarlayın)",
+	"Output file exists and overwrite is false":                   "Çıktı dosyası zaten var ve overwrite false",
+	"Patch applied cleanly but made no changes to %s":             "Yama sorunsuz uygulandı ancak %s dosyasında değişiklik yapmadı",
+	"Patch contains no file changes":                              "Yama dosya değişikliği içermiyor",
+	"Patch not applied: %d of %d file(s) failed, no files were changed\n": "Yama uygulanmadı: %[2]d dosyadan %[1]d tanesi başarısız oldu, hiçbir dosya değiştirilmedi\n",
+	"Permission denied: %s": "İzin reddedildi: %s",
+	"Permission denied: %v": "İzin reddedildi: %v",
+	"Process %d did not exit within %s of %s and was force killed": "%[1]d süreci %[3]s sinyalinden sonra %[2]s içinde çıkmadı ve zorla sonlandırıldı",
+	"Process tree %d force killed: %v":                             "%d süreç ağacı zorla sonlandırıldı: %v",
+	"Process tree %d terminated: %v":                               "%d süreç ağacı sonlandırıldı: %v",
+	"Query failed: %v":                                             "Sorgu başarısız oldu: %v",
+	"Rate limited: %v":                                             "Hız sınırına takıldı: %v",
+	"Refusing to delete %s: it contains denied path %s":            "%s silinmiyor: yasaklı %s yolunu içeriyor",
+	"Refusing to kill the server's own process tree":               "Sunucunun kendi süreç ağacı sonlandırılmıyor",
+	"Refusing to move %s: it contains denied path %s":              "%s taşınmıyor: yasaklı %s yolunu içeriyor",
+	"Refusing to rename: %d conflicts detected\n%s":                "Yeniden adlandırılmıyor: %d çakışma bulundu\n%s",
+	"Removed %d backup(s)\n":                                       "%d yedek kaldırıldı\n",
+	"Removed %d workspaces":                                        "%d çalışma alanı kaldırıldı",
+	"Removed attribute '%s' from %s":                               "'%s' özniteliği %s üzerinden kaldırıldı",
+	"Renamed %d files:\n%s":                                        "%d dosya yeniden adlandırıldı:\n%s",
+	"Replaced %d occurrence(s) in %s":                              "%[2]s içinde %[1]d eşleşme değiştirildi",
+	"Replaced %d occurrences in %s":                                "%[2]s içinde %[1]d eşleşme değiştirildi",
+	"Request failed: %v":                                           "İstek başarısız oldu: %v",
+	"Reset the quotas of every session":                            "Tüm oturumların kotaları sıfırlandı",
+	"Reset the quotas of session %s":                               "%s oturumunun kotaları sıfırlandı",
+	"Resized %s to %d rows x %d columns":                           "%s, %d satır x %d sütun olarak yeniden boyutlandırıldı",
+	"Response is not JSON: %s":                                     "Yanıt JSON değil: %s",
+	"Restored %d path(s)":                                          "%d yol geri yüklendi",
+	"Restored %s from %s":                                          "%[1]s, %[2]s yedeğinden geri yüklendi",
+	"Revoked temporary %s access to %q":                            "%[2]q için geçici %[1]s erişimi geri alındı",
+	"Rolling back to version %d would change these settings; repeat with apply=true to roll back:\n%s": "%d sürümüne geri dönmek şu ayarları değiştirecek; geri almak için apply=true ile tekrarlayın:\n%s",
+	"SSH host '%s' removed":                                                   "'%s' SSH sunucusu kaldırıldı",
+	"SSH host '%s' saved":                                                     "'%s' SSH sunucusu kaydedildi",
+	"Saved snippet %s":                                                        "%s kod parçacığı kaydedildi",
+	"Script contains blocked command patterns":                                "Betik engellenmiş komut kalıpları içeriyor",
+	"Script execution failed: %v\nOutput: %s%s":                               "Betik çalıştırılamadı: %v\nÇıktı: %s%s",
+	"Search failed: %v":                                                       "Arama başarısız oldu: %v",
+	"Secret %s deleted":                                                       "%s gizli değeri silindi",
+	"Secret %s stored; reference it as {{secret:%s}}":                         "%s gizli değeri saklandı; {{secret:%s}} olarak başvurun",
+	"Secure delete failed: %v":                                                "Güvenli silme başarısız oldu: %v",
+	"Securely deleted: %s (%d file(s), %d overwrite pass(es))":                "Güvenli biçimde silindi: %s (%d dosya, %d üzerine yazma geçişi)",
+	"Sent %s to process %d":                                                   "%[1]s, %[2]d sürecine gönderildi",
+	"Session quota reached: %v":                                               "Oturum kotasına ulaşıldı: %v",
+	"Set %s in %d document(s) of %s\n\nDiff:\n%s":                             "%[1]s, %[3]s dosyasının %[2]d belgesinde ayarlandı\n\nFark:\n%[4]s",
+	"Set %s in %s (%s)":                                                       "%[1]s, %[2]s içinde ayarlandı (%[3]s)",
+	"Set %s in the front matter of %s":                                        "%[1]s, %[2]s dosyasının ön bilgilerinde ayarlandı",
+	"Set either port or url":                                                  "port ya da url ayarlayın",
+	"Source and destination must be different files":                          "Kaynak ve hedef farklı dosyalar olmalı",
+	"Stopped at operation %d: %v":                                             "%d. işlemde durdu: %v",
+	"Successfully applied %d operations to %s":                                "%[2]s dosyasına %[1]d işlem başarıyla uygulandı",
+	"Successfully edited lines %d-%d in %s":                                   "%[3]s dosyasının %[1]d-%[2]d satırları başarıyla düzenlendi",
+	"Switched to %s (HEAD: %s)":                                               "%s konumuna geçildi (HEAD: %s)",
+	"Switched to config profile '%s'; changed settings:\n%s":                  "'%s' yapılandırma profiline geçildi; değişen ayarlar:\n%s",
+	"Switched to config profile '%s'; no settings changed":                    "'%s' yapılandırma profiline geçildi; hiçbir ayar değişmedi",
+	"Syntax validation failed: %v":                                            "Söz dizimi doğrulaması başarısız oldu: %v",
+	"Table of contents in %s is up to date":                                   "%s içindeki içindekiler tablosu güncel",
+	"Template %s: %v":                                                         "%s şablonu: %v",
+	"The imported configuration has the same settings as the current one":     "İçe aktarılan yapılandırma geçerli olanla aynı ayarlara sahip",
+	"Tool %s cannot be run by approve_operation":                              "%s aracı approve_operation ile çalıştırılamaz",
+	"Touched %s (atime: %s, mtime: %s)":                                       "%s zamanları güncellendi (atime: %s, mtime: %s)",
+	"Transformed lines %d-%d in %s (%d -> %d lines)":                          "%[3]s dosyasının %[1]d-%[2]d satırları dönüştürüldü (%[4]d -> %[5]d satır)",
+	"URL status check failed: %v":                                             "URL durum denetimi başarısız oldu: %v",
+	"Unknown action %q (use list, create or delete)":                          "Bilinmeyen eylem %q (list, create ya da delete kullanın)",
+	"Unknown action: %s (use get, set or delete)":                             "Bilinmeyen eylem: %s (get, set ya da delete kullanın)",
+	"Unknown format: %s (use text or json)":                                   "Bilinmeyen biçim: %s (text ya da json kullanın)",
+	"Unknown patch_type: %s (use auto, json_patch or merge_patch)":            "Bilinmeyen patch_type: %s (auto, json_patch ya da merge_patch kullanın)",
+	"Updated %d headings in %s":                                               "%[2]s içinde %[1]d başlık güncellendi",
+	"Updated table of contents in %s":                                         "%s içindeki içindekiler tablosu güncellendi",
+	"Uploaded %s to %s:%s":                                                    "%s, %s:%s konumuna yüklendi",
+	"Working directory reset; commands run in the server's working directory": "Çalışma dizini sıfırlandı; komutlar sunucunun çalışma dizininde çalışır",
+	"Working directory: %s":                                                   "Çalışma dizini: %s",
+	"Workspace created: %s\nPath: %s":                                         "Çalışma alanı oluşturuldu: %s\nYol: %s",
+	"Workspace removed: %s":                                                   "Çalışma alanı kaldırıldı: %s",
+	"Wrote %d bytes to %s":                                                    "%[2]s oturumuna %[1]d bayt yazıldı",
+	"Wrote %s (%s, %dx%d, %s) from %s (%s, %dx%d, %s)":                        "%[6]s (%[7]s, %[8]dx%[9]d, %[10]s) görselinden %[1]s (%[2]s, %[3]dx%[4]d, %[5]s) yazıldı",
+	"\n%d entries":                                                            "\n%d girdi",
+	"\n... (truncated at %d results)":                                         "\n... (%d sonuçta kesildi)",
+	"\n... results truncated at %d matches":                                   "\n... sonuçlar %d eşleşmede kesildi",
+	"\n... results truncated; use offset=%d to see more":                      "\n... sonuçlar kesildi; devamını görmek için offset=%d kullanın",
+	"\nConverted to: %s":                                                      "\nDönüştürüldü: %s",
+	"\nPrevious content backed up to: %s":                                     "\nÖnceki içerik şuraya yedeklendi: %s",
+	"\nWarning: %s":                                                           "\nUyarı: %s",
+	"\nWarning: Format conversion failed: %v":                                 "\nUyarı: Biçim dönüştürme başarısız oldu: %v",
+	"\n\nFailed to write full output to %s: %v":                               "\n\nTam çıktı %s dosyasına yazılamadı: %v",
+	"\n\nFull output (%s) written to: %s":                                     "\n\nTam çıktı (%s) şuraya yazıldı: %s",
+	"context_lines cannot be negative":                                        "context_lines negatif olamaz",
+	"direction must be upload or download":                                    "direction upload ya da download olmalı",
+	"duration_seconds must be greater than 0":                                 "duration_seconds 0'dan büyük olmalı",
+	"expected_offset requires append=true":                                    "expected_offset için append=true gerekli",
+	"git stash %s succeeded":                                                  "git stash %s başarılı oldu",
+	"interval_seconds must be at least 1":                                     "interval_seconds en az 1 olmalı",
+	"limit must be positive":                                                  "limit pozitif olmalı",
+	"max_bytes must be greater than 0":                                        "max_bytes 0'dan büyük olmalı",
+	"max_runs must be greater than 0":                                         "max_runs 0'dan büyük olmalı",
+	"min_level and max_level must satisfy 1 <= min_level <= max_level <= 6":   "min_level ve max_level 1 <= min_level <= max_level <= 6 koşulunu sağlamalı",
+	"name is required to %s a branch":                                         "Dal için %s işleminde name gerekli",
+	"old_string and new_string are identical":                                 "old_string ve new_string aynı",
+	"secure_delete cannot be combined with create_backup":                     "secure_delete, create_backup ile birlikte kullanılamaz",
+	"timeout_seconds must be greater than 0":                                  "timeout_seconds 0'dan büyük olmalı",
+	"url must start with http:// or https://":                                 "url http:// ya da https:// ile başlamalı",
+}
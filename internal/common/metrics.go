@@ -0,0 +1,192 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// metricsLatencyBuckets are the upper bounds, in milliseconds, of the
+// latency histogram kept for each tool
+var metricsLatencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// toolMetrics accumulates the calls of one tool. bucketCounts has one
+// count per bucket plus one for calls slower than the last bucket.
+type toolMetrics struct {
+	calls        int64
+	errors       int64
+	totalMs      float64
+	maxMs        float64
+	bucketCounts []int64
+}
+
+var (
+	metricsMutex   sync.Mutex
+	metricsByTool  = make(map[string]*toolMetrics)
+	metricsStarted = time.Now()
+)
+
+// RecordToolMetrics counts a tool call and its latency when telemetryEnabled
+// is set; nothing is collected otherwise
+func RecordToolMetrics(tool string, duration time.Duration, failed bool) {
+	if !Get().TelemetryEnabled {
+		return
+	}
+	ms := float64(duration.Microseconds()) / 1000
+
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	metrics, ok := metricsByTool[tool]
+	if !ok {
+		metrics = &toolMetrics{bucketCounts: make([]int64, len(metricsLatencyBuckets)+1)}
+		metricsByTool[tool] = metrics
+	}
+	metrics.calls++
+	if failed {
+		metrics.errors++
+	}
+	metrics.totalMs += ms
+	if ms > metrics.maxMs {
+		metrics.maxMs = ms
+	}
+	metrics.bucketCounts[sort.SearchFloat64s(metricsLatencyBuckets, ms)]++
+}
+
+// ResetMetrics discards the collected metrics
+func ResetMetrics() {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	metricsByTool = make(map[string]*toolMetrics)
+	metricsStarted = time.Now()
+}
+
+// GetMetrics returns the metrics collected since the server started or
+// the metrics were reset, ordered by tool. Histogram bucket counts are
+// cumulative, as in Prometheus.
+func GetMetrics() types.MetricsReport {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	report := types.MetricsReport{
+		Enabled:     Get().TelemetryEnabled,
+		Since:       metricsStarted,
+		GeneratedAt: time.Now(),
+		Tools:       make([]types.ToolMetrics, 0, len(metricsByTool)),
+	}
+	for tool, metrics := range metricsByTool {
+		summary := types.ToolMetrics{
+			Tool:         tool,
+			Calls:        metrics.calls,
+			Errors:       metrics.errors,
+			ErrorRate:    float64(metrics.errors) / float64(metrics.calls),
+			AvgLatencyMs: metrics.totalMs / float64(metrics.calls),
+			MaxLatencyMs: metrics.maxMs,
+			TotalMs:      metrics.totalMs,
+		}
+		var cumulative int64
+		for i, bound := range metricsLatencyBuckets {
+			cumulative += metrics.bucketCounts[i]
+			summary.LatencyBuckets = append(summary.LatencyBuckets, types.LatencyBucket{LeMs: bound, Count: cumulative})
+		}
+		report.Calls += metrics.calls
+		report.Errors += metrics.errors
+		report.Tools = append(report.Tools, summary)
+	}
+	sort.Slice(report.Tools, func(i, j int) bool { return report.Tools[i].Tool < report.Tools[j].Tool })
+	return report
+}
+
+// FormatPrometheusMetrics renders a metrics report in the Prometheus text
+// exposition format, with latencies in seconds
+func FormatPrometheusMetrics(report types.MetricsReport) string {
+	var b strings.Builder
+	seconds := func(ms float64) string {
+		return strconv.FormatFloat(ms/1000, 'f', -1, 64)
+	}
+
+	b.WriteString("# HELP jarvis_tool_calls_total Tool calls handled.\n# TYPE jarvis_tool_calls_total counter\n")
+	for _, tool := range report.Tools {
+		fmt.Fprintf(&b, "jarvis_tool_calls_total{tool=%q} %d\n", tool.Tool, tool.Calls)
+	}
+	b.WriteString("# HELP jarvis_tool_errors_total Tool calls that returned an error.\n# TYPE jarvis_tool_errors_total counter\n")
+	for _, tool := range report.Tools {
+		fmt.Fprintf(&b, "jarvis_tool_errors_total{tool=%q} %d\n", tool.Tool, tool.Errors)
+	}
+	b.WriteString("# HELP jarvis_tool_duration_seconds Tool call latency.\n# TYPE jarvis_tool_duration_seconds histogram\n")
+	for _, tool := range report.Tools {
+		for _, bucket := range tool.LatencyBuckets {
+			fmt.Fprintf(&b, "jarvis_tool_duration_seconds_bucket{tool=%q,le=%q} %d\n", tool.Tool, seconds(bucket.LeMs), bucket.Count)
+		}
+		fmt.Fprintf(&b, "jarvis_tool_duration_seconds_bucket{tool=%q,le=\"+Inf\"} %d\n", tool.Tool, tool.Calls)
+		fmt.Fprintf(&b, "jarvis_tool_duration_seconds_sum{tool=%q} %s\n", tool.Tool, seconds(tool.TotalMs))
+		fmt.Fprintf(&b, "jarvis_tool_duration_seconds_count{tool=%q} %d\n", tool.Tool, tool.Calls)
+	}
+	return b.String()
+}
+
+// StartMetricsExport serves the metrics at /metrics on metricsListenAddress
+// when it is set, and writes a JSON report to metricsReportPath every
+// metricsReportIntervalSeconds while telemetry is enabled. The listen
+// address is read once; the report settings are read before each report.
+func StartMetricsExport(ctx context.Context) error {
+	if address := Get().MetricsListenAddress; address != "" {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf("failed to listen for metrics on %s: %v", address, err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			fmt.Fprint(w, FormatPrometheusMetrics(GetMetrics()))
+		})
+		metricsServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go metricsServer.Serve(listener)
+		go func() {
+			<-ctx.Done()
+			metricsServer.Close()
+		}()
+	}
+
+	go func() {
+		for {
+			interval := time.Duration(Get().MetricsReportIntervalSeconds) * time.Second
+			if interval <= 0 {
+				interval = DefaultMetricsReportInterval * time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			if err := writeMetricsReport(); err != nil {
+				log.Printf("Metrics report error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// writeMetricsReport writes the metrics to metricsReportPath when telemetry
+// is enabled and a path is set
+func writeMetricsReport() error {
+	cfg := Get()
+	if !cfg.TelemetryEnabled || cfg.MetricsReportPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(GetMetrics(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(cfg.MetricsReportPath, data, 0600)
+}
@@ -0,0 +1,238 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// NativeCodeTool names the built-in JSON and SQL implementations
+const NativeCodeTool = "native"
+
+var sqlDollarQuotePattern = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// DetectBeautifier returns the default beautifier for a file extension
+func DetectBeautifier(filePath string) (string, error) {
+	switch ext := GetFileExtension(filePath); ext {
+	case ".json":
+		return NativeCodeTool, nil
+	case ".css", ".scss", ".less", ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx", ".html":
+		return "prettier", nil
+	case ".sql":
+		if _, err := exec.LookPath("sql-formatter"); err != nil {
+			if _, err := exec.LookPath("pg_format"); err == nil {
+				return "pg_format", nil
+			}
+		}
+		return "sql-formatter", nil
+	default:
+		return "", fmt.Errorf("no default beautifier for file type: %s", ext)
+	}
+}
+
+// DetectMinifier returns the default minifier for a file extension,
+// preferring esbuild for CSS and JavaScript when it is installed
+func DetectMinifier(filePath string) (string, error) {
+	ext := GetFileExtension(filePath)
+	switch ext {
+	case ".json", ".sql":
+		return NativeCodeTool, nil
+	case ".css", ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx":
+		if _, err := exec.LookPath("esbuild"); err == nil {
+			return "esbuild", nil
+		}
+		if ext == ".css" {
+			return "csso", nil
+		}
+		return "terser", nil
+	default:
+		return "", fmt.Errorf("no default minifier for file type: %s", ext)
+	}
+}
+
+// codeToolArgs returns the arguments that make a beautifier or minifier
+// read from stdin and write to stdout
+func codeToolArgs(tool, filePath string) ([]string, error) {
+	switch tool {
+	case "prettier":
+		return []string{"--stdin-filepath", filePath}, nil
+	case "sql-formatter":
+		return nil, nil
+	case "pg_format":
+		return []string{"-"}, nil
+	case "esbuild":
+		loader := strings.TrimPrefix(GetFileExtension(filePath), ".")
+		if loader == "mjs" || loader == "cjs" {
+			loader = "js"
+		}
+		return []string{"--minify", "--loader=" + loader}, nil
+	case "terser":
+		return []string{"--compress", "--mangle"}, nil
+	case "csso":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported tool: %s", tool)
+	}
+}
+
+// BeautifyCode reformats content, treated as the content of filePath, for
+// readability. JSON is indented natively with indent; other languages use
+// tool, detected from the extension when empty. It returns the result and
+// the tool used.
+func BeautifyCode(ctx context.Context, filePath, tool string, content []byte, indent string) ([]byte, string, error) {
+	if tool == "" {
+		var err error
+		if tool, err = DetectBeautifier(filePath); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if tool == NativeCodeTool {
+		if GetFileExtension(filePath) != ".json" {
+			return nil, tool, fmt.Errorf("native beautifying only supports JSON")
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimSpace(content), "", indent); err != nil {
+			return nil, tool, fmt.Errorf("invalid JSON: %w", err)
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), tool, nil
+	}
+
+	result, err := runCodeTool(ctx, tool, filePath, content)
+	return result, tool, err
+}
+
+// MinifyCode strips insignificant whitespace and comments from content.
+// JSON and SQL are minified natively; other languages use tool, detected
+// from the extension when empty. It returns the result and the tool used.
+func MinifyCode(ctx context.Context, filePath, tool string, content []byte) ([]byte, string, error) {
+	if tool == "" {
+		var err error
+		if tool, err = DetectMinifier(filePath); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if tool == NativeCodeTool {
+		switch GetFileExtension(filePath) {
+		case ".json":
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, content); err != nil {
+				return nil, tool, fmt.Errorf("invalid JSON: %w", err)
+			}
+			return buf.Bytes(), tool, nil
+		case ".sql":
+			return []byte(MinifySQL(string(content))), tool, nil
+		default:
+			return nil, tool, fmt.Errorf("native minifying only supports JSON and SQL")
+		}
+	}
+
+	result, err := runCodeTool(ctx, tool, filePath, content)
+	return result, tool, err
+}
+
+func runCodeTool(ctx context.Context, tool, filePath string, content []byte) ([]byte, error) {
+	args, err := codeToolArgs(tool, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result, _, err := runFilterCommand(ctx, tool, args, filepath.Dir(filePath), content)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 && len(content) > 0 {
+		return nil, fmt.Errorf("%s produced no output", tool)
+	}
+	return result, nil
+}
+
+// MinifySQL removes comments and collapses whitespace in SQL, leaving
+// quoted strings, quoted identifiers and dollar-quoted bodies untouched
+func MinifySQL(sql string) string {
+	var b strings.Builder
+	var last byte
+	pendingSpace := false
+	emit := func(text string) {
+		if pendingSpace && b.Len() > 0 && !strings.ContainsRune("(),;", rune(last)) && !strings.ContainsRune("(),;", rune(text[0])) {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteString(text)
+		last = text[len(text)-1]
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				end = len(sql) - i
+			}
+			i += end
+			pendingSpace = true
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+			pendingSpace = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			pendingSpace = true
+		case c == '\'' || c == '"' || c == '`':
+			// Quotes are escaped by doubling them
+			end := i + 1
+			for end < len(sql) {
+				if sql[end] == c {
+					if end+1 < len(sql) && sql[end+1] == c {
+						end += 2
+						continue
+					}
+					end++
+					break
+				}
+				end++
+			}
+			emit(sql[i:end])
+			i = end
+		case c == '$' && sqlDollarQuotePattern.MatchString(sql[i:]):
+			tag := sqlDollarQuotePattern.FindString(sql[i:])
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end == -1 {
+				end = len(sql)
+			} else {
+				end = i + len(tag) + end + len(tag)
+			}
+			emit(sql[i:end])
+			i = end
+		default:
+			emit(sql[i : i+1])
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// FormatSizeChange describes how a transformation changed content size
+func FormatSizeChange(before, after int) string {
+	if before == 0 {
+		return fmt.Sprintf("Size: %d -> %d bytes", before, after)
+	}
+	percent := float64(before-after) / float64(before) * 100
+	if after <= before {
+		return fmt.Sprintf("Size: %d -> %d bytes (saved %d bytes, %.1f%%)", before, after, before-after, percent)
+	}
+	return fmt.Sprintf("Size: %d -> %d bytes (grew %d bytes, %.1f%%)", before, after, after-before, -percent)
+}
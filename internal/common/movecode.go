@@ -0,0 +1,442 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var importVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// CodeMove is the outcome of MoveCode: the new content of both files and a
+// description of what was moved
+type CodeMove struct {
+	Source      []byte
+	Destination []byte
+	StartLine   int
+	EndLine     int
+	Notes       []string
+}
+
+// MoveCode cuts a block of code out of source and appends it to
+// destination. The block is a Go declaration selected by symbol (Name or
+// Type.Method, including its doc comment) or the 1-based inclusive line
+// range startLine..endLine. For Go files a new destination gets the package
+// clause of its directory, and imports are added to the destination and
+// dropped from the source as the moved code requires.
+func MoveCode(sourcePath, destinationPath string, source, destination []byte, symbol string, startLine, endLine int) (*CodeMove, error) {
+	isGo := GetFileExtension(sourcePath) == ".go"
+	if isGo != (GetFileExtension(destinationPath) == ".go") {
+		return nil, fmt.Errorf("source and destination must both be Go files or both be non-Go files")
+	}
+
+	var start, end int
+	var err error
+	switch {
+	case symbol != "" && !isGo:
+		return nil, fmt.Errorf("symbol selection is only supported for Go files; use start_line and end_line")
+	case symbol != "":
+		start, end, err = goDeclarationRange(sourcePath, source, symbol)
+	default:
+		start, end, err = lineRangeOffsets(source, startLine, endLine)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	move := &CodeMove{
+		StartLine: bytes.Count(source[:start], []byte("\n")) + 1,
+		EndLine:   bytes.Count(source[:end], []byte("\n")),
+	}
+	block := source[start:end]
+	if len(bytes.TrimSpace(block)) == 0 {
+		return nil, fmt.Errorf("lines %d-%d are empty", startLine, endLine)
+	}
+	if !bytes.HasSuffix(block, []byte("\n")) {
+		block = append(append([]byte{}, block...), '\n')
+	}
+
+	before := bytes.TrimRight(source[:start], "\n")
+	after := bytes.TrimLeft(source[end:], "\n")
+	remaining := append([]byte{}, before...)
+	if len(before) > 0 && len(after) > 0 {
+		remaining = append(remaining, "\n\n"...)
+	} else if len(before) > 0 {
+		remaining = append(remaining, '\n')
+	}
+	remaining = append(remaining, after...)
+
+	if !isGo {
+		move.Source = remaining
+		move.Destination = appendBlock(destination, block)
+		return move, nil
+	}
+
+	fset := token.NewFileSet()
+	sourceFile, err := parser.ParseFile(fset, sourcePath, source, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", sourcePath, err)
+	}
+
+	if len(bytes.TrimSpace(destination)) == 0 {
+		pkg := goPackageForDir(filepath.Dir(destinationPath), destinationPath)
+		if pkg == "" {
+			pkg = sourceFile.Name.Name
+		}
+		destination = []byte("package " + pkg + "\n")
+	} else {
+		destFile, err := parser.ParseFile(token.NewFileSet(), destinationPath, destination, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", destinationPath, err)
+		}
+		if symbol != "" && goDeclares(destFile, symbol) {
+			return nil, fmt.Errorf("%s already declares %s", destinationPath, symbol)
+		}
+		if destFile.Name.Name != sourceFile.Name.Name {
+			move.Notes = append(move.Notes, fmt.Sprintf("%s is in package %s and %s in package %s; references to the moved code are not updated",
+				destinationPath, destFile.Name.Name, sourcePath, sourceFile.Name.Name))
+		}
+	}
+
+	if move.Destination, err = fixGoImports(destinationPath, appendBlock(destination, block), sourceFile.Imports); err != nil {
+		return nil, err
+	}
+	if move.Source, err = fixGoImports(sourcePath, remaining, nil); err != nil {
+		return nil, err
+	}
+	return move, nil
+}
+
+// appendBlock appends block to content separated by a blank line
+func appendBlock(content, block []byte) []byte {
+	content = bytes.TrimRight(content, "\n")
+	if len(content) == 0 {
+		return block
+	}
+	result := append(append([]byte{}, content...), "\n\n"...)
+	return append(result, block...)
+}
+
+// lineRangeOffsets converts a 1-based inclusive line range into byte offsets
+func lineRangeOffsets(content []byte, startLine, endLine int) (int, int, error) {
+	if startLine < 1 || endLine < startLine {
+		return 0, 0, fmt.Errorf("invalid line range %d-%d", startLine, endLine)
+	}
+
+	start, end := -1, -1
+	line := 1
+	for i := 0; i <= len(content); i++ {
+		if line == startLine && start == -1 {
+			start = i
+		}
+		if i == len(content) {
+			break
+		}
+		if content[i] == '\n' {
+			if line == endLine {
+				end = i + 1
+				break
+			}
+			line++
+		}
+	}
+	if start == -1 || start == len(content) {
+		return 0, 0, fmt.Errorf("start line %d is beyond the end of the file", startLine)
+	}
+	if end == -1 {
+		if line < endLine {
+			return 0, 0, fmt.Errorf("end line %d is beyond the end of the file (%d lines)", endLine, line)
+		}
+		end = len(content)
+	}
+	return start, end, nil
+}
+
+// goDeclarationRange returns the byte range of the whole lines holding the
+// top-level declaration of symbol, including its doc comment
+func goDeclarationRange(path string, content []byte, symbol string) (int, int, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var node ast.Node
+	var doc *ast.CommentGroup
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if goFuncName(d) == symbol {
+				node, doc = d, d.Doc
+			}
+		case *ast.GenDecl:
+			if !goGenDeclDeclares(d, symbol) {
+				continue
+			}
+			if len(d.Specs) > 1 {
+				return 0, 0, fmt.Errorf("%s is declared in a group with other names; use start_line and end_line", symbol)
+			}
+			node, doc = d, d.Doc
+		}
+		if node != nil {
+			break
+		}
+	}
+	if node == nil {
+		return 0, 0, WithErrorCode(ErrorNotFound, fmt.Errorf("symbol %s not found in %s", symbol, path))
+	}
+
+	start := fset.Position(node.Pos()).Offset
+	if doc != nil {
+		start = fset.Position(doc.Pos()).Offset
+	}
+	end := fset.Position(node.End()).Offset
+
+	start = bytes.LastIndexByte(content[:start], '\n') + 1
+	if newline := bytes.IndexByte(content[end:], '\n'); newline == -1 {
+		end = len(content)
+	} else {
+		end += newline + 1
+	}
+	return start, end, nil
+}
+
+// goFuncName returns Name for functions and Type.Name for methods
+func goFuncName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+			continue
+		case *ast.IndexExpr:
+			recv = t.X
+			continue
+		case *ast.IndexListExpr:
+			recv = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + decl.Name.Name
+		}
+		return decl.Name.Name
+	}
+}
+
+func goGenDeclDeclares(decl *ast.GenDecl, symbol string) bool {
+	for _, spec := range decl.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if s.Name.Name == symbol {
+				return true
+			}
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				if name.Name == symbol {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func goDeclares(file *ast.File, symbol string) bool {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if goFuncName(d) == symbol {
+				return true
+			}
+		case *ast.GenDecl:
+			if goGenDeclDeclares(d, symbol) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// goPackageForDir returns the package name used by the other Go files in
+// dir, or "" when there are none
+func goPackageForDir(dir, exclude string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, match := range matches {
+		if filepath.Clean(match) == filepath.Clean(exclude) || strings.HasSuffix(match, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), match, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
+
+// goModulePath returns the module path declared by the go.mod file
+// governing dir, or "" when there is none
+func goModulePath(dir string) string {
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+					return strings.Trim(fields[1], `"`)
+				}
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goImportName returns the name an import is referred to by
+func goImportName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	path, _ := strconv.Unquote(spec.Path.Value)
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if importVersionPattern.MatchString(name) && len(parts) > 1 {
+		name = parts[len(parts)-2]
+	}
+	if dot := strings.Index(name, ".v"); dot > 0 {
+		name = name[:dot]
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// fixGoImports drops imports content no longer uses and adds the
+// candidates it now needs, then formats the result with gofmt
+func fixGoImports(path string, content []byte, candidates []*ast.ImportSpec) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("moved code does not parse in %s: %w", path, err)
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	type importLine struct{ name, path string }
+	var imports []importLine
+	present := make(map[string]bool)
+	changed := false
+	keep := func(spec *ast.ImportSpec, fromCandidates bool) {
+		name := goImportName(spec)
+		if present[spec.Path.Value] {
+			return
+		}
+		if !used[name] && name != "_" && name != "." {
+			if !fromCandidates {
+				changed = true
+			}
+			return
+		}
+		local := ""
+		if spec.Name != nil {
+			local = spec.Name.Name
+		}
+		present[spec.Path.Value] = true
+		imports = append(imports, importLine{name: local, path: spec.Path.Value})
+		if fromCandidates {
+			changed = true
+		}
+	}
+	for _, spec := range file.Imports {
+		keep(spec, false)
+	}
+	for _, spec := range candidates {
+		if spec.Name != nil && (spec.Name.Name == "_" || spec.Name.Name == ".") {
+			continue
+		}
+		keep(spec, true)
+	}
+
+	if !changed {
+		return format.Source(content)
+	}
+
+	// Replace every import declaration with a single regenerated block
+	// after the package clause
+	insertAt := fset.Position(file.Name.End()).Offset
+	var body bytes.Buffer
+	last := insertAt
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			body.Write(content[last:fset.Position(gen.Pos()).Offset])
+			last = fset.Position(gen.End()).Offset
+		}
+	}
+	body.Write(content[last:])
+
+	module := goModulePath(filepath.Dir(path))
+	var std, other []string
+	for _, imp := range imports {
+		line := imp.path
+		if imp.name != "" {
+			line = imp.name + " " + imp.path
+		}
+		importPath, _ := strconv.Unquote(imp.path)
+		if strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") || (module != "" && (importPath == module || strings.HasPrefix(importPath, module+"/"))) {
+			other = append(other, line)
+		} else {
+			std = append(std, line)
+		}
+	}
+	byPath := func(lines []string) func(i, j int) bool {
+		return func(i, j int) bool {
+			return lines[i][strings.IndexByte(lines[i], '"'):] < lines[j][strings.IndexByte(lines[j], '"'):]
+		}
+	}
+	sort.Slice(std, byPath(std))
+	sort.Slice(other, byPath(other))
+
+	var b bytes.Buffer
+	b.Write(content[:insertAt])
+	switch {
+	case len(imports) == 1:
+		b.WriteString("\n\nimport " + append(std, other...)[0] + "\n")
+	case len(imports) > 1:
+		b.WriteString("\n\nimport (\n")
+		for _, line := range std {
+			b.WriteString("\t" + line + "\n")
+		}
+		if len(std) > 0 && len(other) > 0 {
+			b.WriteString("\n")
+		}
+		for _, line := range other {
+			b.WriteString("\t" + line + "\n")
+		}
+		b.WriteString(")\n")
+	}
+	b.Write(body.Bytes())
+
+	formatted, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %w", path, err)
+	}
+	return formatted, nil
+}
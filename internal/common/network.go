@@ -0,0 +1,155 @@
+package common
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+
+	psnet "github.com/shirou/gopsutil/v4/net"
+	"github.com/shirou/gopsutil/v4/process"
+
+	"jarvis/internal/types"
+)
+
+// ConnectionKinds are the kinds of sockets ListConnections can list
+var ConnectionKinds = []string{"all", "inet", "inet4", "inet6", "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix"}
+
+// ListNetworkInterfaces returns the network interfaces with their
+// addresses and traffic counters, ordered by index
+func ListNetworkInterfaces(ctx context.Context) ([]types.NetworkInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]psnet.IOCountersStat)
+	if stats, err := psnet.IOCountersWithContext(ctx, true); err == nil {
+		for _, stat := range stats {
+			counters[stat.Name] = stat
+		}
+	}
+
+	result := make([]types.NetworkInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		info := types.NetworkInterface{
+			Name:         iface.Name,
+			Index:        iface.Index,
+			MTU:          iface.MTU,
+			HardwareAddr: iface.HardwareAddr.String(),
+			Flags:        strings.Split(iface.Flags.String(), "|"),
+			Addresses:    []string{},
+		}
+		if iface.Flags == 0 {
+			info.Flags = []string{}
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				info.Addresses = append(info.Addresses, addr.String())
+			}
+		}
+		if stat, ok := counters[iface.Name]; ok {
+			info.BytesSent, info.BytesRecv = stat.BytesSent, stat.BytesRecv
+			info.PacketsSent, info.PacketsRecv = stat.PacketsSent, stat.PacketsRecv
+			info.Errors = stat.Errin + stat.Errout
+			info.Drops = stat.Dropin + stat.Dropout
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// ListConnections returns open sockets of the given kind (see
+// ConnectionKinds). status keeps only sockets in that state, such as
+// LISTEN or ESTABLISHED; pid keeps only sockets of that process when
+// positive. Sockets owned by other users may show no PID without root.
+func ListConnections(ctx context.Context, kind, status string, pid int) ([]types.NetworkConnection, error) {
+	stats, err := psnet.ConnectionsWithContext(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int32]string)
+	processName := func(pid int32) string {
+		if pid <= 0 {
+			return ""
+		}
+		if name, ok := names[pid]; ok {
+			return name
+		}
+		var name string
+		if proc, err := process.NewProcessWithContext(ctx, pid); err == nil {
+			name, _ = proc.NameWithContext(ctx)
+		}
+		names[pid] = name
+		return name
+	}
+
+	connections := []types.NetworkConnection{}
+	for _, stat := range stats {
+		if status != "" && !strings.EqualFold(stat.Status, status) {
+			continue
+		}
+		if pid > 0 && int(stat.Pid) != pid {
+			continue
+		}
+		connections = append(connections, types.NetworkConnection{
+			Protocol:      connectionProtocol(stat),
+			LocalAddress:  stat.Laddr.IP,
+			LocalPort:     stat.Laddr.Port,
+			RemoteAddress: stat.Raddr.IP,
+			RemotePort:    stat.Raddr.Port,
+			Status:        stat.Status,
+			PID:           int(stat.Pid),
+			Process:       processName(stat.Pid),
+		})
+	}
+
+	sort.SliceStable(connections, func(i, j int) bool {
+		a, b := connections[i], connections[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.LocalPort < b.LocalPort
+	})
+	return connections, nil
+}
+
+// ListListeningPorts returns the TCP sockets accepting connections and the
+// bound UDP sockets
+func ListListeningPorts(ctx context.Context) ([]types.NetworkConnection, error) {
+	connections, err := ListConnections(ctx, "inet", "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	listening := []types.NetworkConnection{}
+	for _, conn := range connections {
+		isTCP := strings.HasPrefix(conn.Protocol, "tcp")
+		if (isTCP && conn.Status == "LISTEN") || (!isTCP && conn.RemotePort == 0) {
+			listening = append(listening, conn)
+		}
+	}
+	return listening, nil
+}
+
+// connectionProtocol names a socket's protocol, such as tcp or udp6
+func connectionProtocol(stat psnet.ConnectionStat) string {
+	if stat.Family == syscall.AF_UNIX {
+		return "unix"
+	}
+	var protocol string
+	switch stat.Type {
+	case syscall.SOCK_STREAM:
+		protocol = "tcp"
+	case syscall.SOCK_DGRAM:
+		protocol = "udp"
+	default:
+		return "unix"
+	}
+	if stat.Family == syscall.AF_INET6 {
+		protocol += "6"
+	}
+	return protocol
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// Route flags from linux/route.h
+const (
+	routeFlagUp      = 0x1
+	routeFlagGateway = 0x2
+	routeFlagHost    = 0x4
+	routeFlagReject  = 0x200
+)
+
+// GetRoutingTable returns the IPv4 and IPv6 routes from /proc/net
+func GetRoutingTable() ([]types.Route, error) {
+	routes, err := readIPv4Routes()
+	if err != nil {
+		return nil, err
+	}
+	// IPv6 may be disabled, which removes the file
+	if ipv6, err := readIPv6Routes(); err == nil {
+		routes = append(routes, ipv6...)
+	}
+	return routes, nil
+}
+
+func readIPv4Routes() ([]types.Route, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	routes := []types.Route{}
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		destination, err1 := parseProcIPv4(fields[1])
+		gateway, err2 := parseProcIPv4(fields[2])
+		mask, err3 := parseProcIPv4(fields[7])
+		flags, err4 := strconv.ParseUint(fields[3], 16, 32)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		ones, _ := net.IPMask(mask.To4()).Size()
+
+		route := types.Route{
+			Destination: fmt.Sprintf("%s/%d", destination, ones),
+			Interface:   fields[0],
+			Metric:      metric,
+			Flags:       routeFlags(flags),
+		}
+		if flags&routeFlagGateway != 0 {
+			route.Gateway = gateway.String()
+		}
+		routes = append(routes, route)
+	}
+	return routes, scanner.Err()
+}
+
+func readIPv6Routes() ([]types.Route, error) {
+	file, err := os.Open("/proc/net/ipv6_route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var routes []types.Route
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// dest prefix src src_prefix next_hop metric refcnt use flags iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		destination, err1 := hex.DecodeString(fields[0])
+		prefix, err2 := strconv.ParseUint(fields[1], 16, 8)
+		nextHop, err3 := hex.DecodeString(fields[4])
+		metric, err4 := strconv.ParseUint(fields[5], 16, 32)
+		flags, err5 := strconv.ParseUint(fields[8], 16, 32)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+			continue
+		}
+
+		route := types.Route{
+			Destination: fmt.Sprintf("%s/%d", net.IP(destination), prefix),
+			Interface:   fields[9],
+			Metric:      int(metric),
+			Flags:       routeFlags(flags),
+		}
+		if flags&routeFlagGateway != 0 {
+			route.Gateway = net.IP(nextHop).String()
+		}
+		routes = append(routes, route)
+	}
+	return routes, scanner.Err()
+}
+
+// parseProcIPv4 decodes an address from /proc/net/route, where the
+// network-order bytes are printed as a native-endian hexadecimal number
+func parseProcIPv4(text string) (net.IP, error) {
+	value, err := strconv.ParseUint(text, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, 4)
+	binary.NativeEndian.PutUint32(ip, uint32(value))
+	return ip, nil
+}
+
+// routeFlags renders route flags the way the route command does
+func routeFlags(flags uint64) string {
+	var text strings.Builder
+	if flags&routeFlagUp != 0 {
+		text.WriteByte('U')
+	}
+	if flags&routeFlagGateway != 0 {
+		text.WriteByte('G')
+	}
+	if flags&routeFlagHost != 0 {
+		text.WriteByte('H')
+	}
+	if flags&routeFlagReject != 0 {
+		text.WriteByte('!')
+	}
+	return text.String()
+}
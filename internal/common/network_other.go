@@ -0,0 +1,13 @@
+//go:build !linux
+
+package common
+
+import (
+	"fmt"
+
+	"jarvis/internal/types"
+)
+
+func GetRoutingTable() ([]types.Route, error) {
+	return nil, fmt.Errorf("reading the routing table is only supported on Linux")
+}
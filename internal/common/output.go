@@ -0,0 +1,81 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OutputBuffer collects command output. Once the output grows past its
+// limit only the first and last limit/2 bytes are kept, joined by a marker
+// saying how much was cut. A limit <= 0 keeps everything.
+type OutputBuffer struct {
+	mu    sync.Mutex
+	limit int
+	head  []byte
+	tail  []byte
+	total int64
+}
+
+// NewOutputBuffer returns a buffer keeping at most limit bytes
+func NewOutputBuffer(limit int) *OutputBuffer {
+	return &OutputBuffer{limit: limit}
+}
+
+func (b *OutputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	written := len(p)
+	b.total += int64(written)
+	if b.limit <= 0 {
+		b.head = append(b.head, p...)
+		return written, nil
+	}
+
+	half := b.limit / 2
+	if room := b.limit - half - len(b.head); room > 0 {
+		n := min(room, len(p))
+		b.head = append(b.head, p[:n]...)
+		p = p[n:]
+	}
+	// Let the tail grow to twice its size before trimming so that small
+	// writes do not each copy it
+	b.tail = append(b.tail, p...)
+	if len(b.tail) > 2*half {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-half:]...)
+	}
+	return written, nil
+}
+
+// Len returns the number of bytes written, including any that were cut
+func (b *OutputBuffer) Len() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.total
+}
+
+// Truncated reports whether any output was cut
+func (b *OutputBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated()
+}
+
+func (b *OutputBuffer) truncated() bool {
+	return b.limit > 0 && b.total > int64(b.limit)
+}
+
+func (b *OutputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	tail := b.tail
+	if half := b.limit / 2; len(tail) > half {
+		tail = tail[len(tail)-half:]
+	}
+	cut := b.total - int64(len(b.head)) - int64(len(tail))
+	return fmt.Sprintf("%s\n... [%s truncated] ...\n%s", b.head, FormatBytes(cut), tail)
+}
@@ -0,0 +1,290 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// Package management runs the system package manager directly, without a
+// shell. Installing and removing packages is off unless the
+// packageManagementEnabled setting is on, and then limited to package
+// specs matching an allowedPackages entry.
+
+// packageManager describes how to drive one package manager
+type packageManager struct {
+	// binary must be in PATH for the manager to be available
+	binary string
+	// list prints the installed packages for parseList
+	list      []string
+	parseList func(output string) ([]types.InstalledPackage, error)
+	// install and remove return the command for the given packages;
+	// dryRunFlag is added for dry runs, and without one a dry run only
+	// reports the command
+	install    []string
+	remove     []string
+	dryRunFlag string
+	// dryRunFails is set when the manager exits non-zero after a
+	// successful dry run, like dnf --assumeno
+	dryRunFails bool
+}
+
+var packageManagers = map[string]packageManager{
+	"apt": {
+		binary:     "apt-get",
+		list:       []string{"dpkg-query", "-W", "-f=${Package}\t${Version}\n"},
+		parseList:  parseTabPackages,
+		install:    []string{"apt-get", "install", "-y"},
+		remove:     []string{"apt-get", "remove", "-y"},
+		dryRunFlag: "--simulate",
+	},
+	"dnf": {
+		binary:      "dnf",
+		list:        []string{"rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\n"},
+		parseList:   parseTabPackages,
+		install:     []string{"dnf", "install", "-y"},
+		remove:      []string{"dnf", "remove", "-y"},
+		dryRunFlag:  "--assumeno",
+		dryRunFails: true,
+	},
+	"brew": {
+		binary:    "brew",
+		list:      []string{"brew", "list", "--versions"},
+		parseList: parseSpacePackages,
+		install:   []string{"brew", "install"},
+		remove:    []string{"brew", "uninstall"},
+	},
+	"npm": {
+		binary:     "npm",
+		list:       []string{"npm", "ls", "--global", "--depth=0", "--json"},
+		parseList:  parseNPMPackages,
+		install:    []string{"npm", "install", "--global"},
+		remove:     []string{"npm", "uninstall", "--global"},
+		dryRunFlag: "--dry-run",
+	},
+	"pip": {
+		binary:    "python3",
+		list:      []string{"python3", "-m", "pip", "list", "--format=json"},
+		parseList: parsePipPackages,
+		install:   []string{"python3", "-m", "pip", "install"},
+		remove:    []string{"python3", "-m", "pip", "uninstall", "-y"},
+	},
+}
+
+// packageSpecPattern accepts package names with optional version pins,
+// such as curl, requests==2.31.0, @types/node@20 or python@3.12
+var packageSpecPattern = regexp.MustCompile(`^[A-Za-z0-9@][A-Za-z0-9._+:@/=<>~!-]*$`)
+
+// PackageManagerNames returns the supported package managers in order
+func PackageManagerNames() []string {
+	names := make([]string, 0, len(packageManagers))
+	for name := range packageManagers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupPackageManager(name string) (packageManager, error) {
+	manager, ok := packageManagers[name]
+	if !ok {
+		return manager, WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown package manager %q (use %s)", name, strings.Join(PackageManagerNames(), ", ")))
+	}
+	if _, err := exec.LookPath(manager.binary); err != nil {
+		return manager, fmt.Errorf("%s is not installed", manager.binary)
+	}
+	return manager, nil
+}
+
+// ListInstalledPackages returns the packages installed with manager whose
+// name contains filter, ignoring case
+func ListInstalledPackages(ctx context.Context, managerName, filter string) ([]types.InstalledPackage, error) {
+	manager, err := lookupPackageManager(managerName)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := exec.CommandContext(ctx, manager.list[0], manager.list[1:]...).Output()
+	// npm ls exits non-zero for problems such as extraneous packages but
+	// still prints the list
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("failed to list packages: %v", err)
+	}
+	packages, err := manager.parseList(string(output))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package list: %v", err)
+	}
+
+	filter = strings.ToLower(filter)
+	matched := []types.InstalledPackage{}
+	for _, pkg := range packages {
+		if filter == "" || strings.Contains(strings.ToLower(pkg.Name), filter) {
+			matched = append(matched, pkg)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+	return matched, nil
+}
+
+// CheckPackagesAllowed reports whether packages may be installed or removed
+// with manager. Each allowedPackages entry is a package spec or a glob,
+// optionally prefixed with a manager, such as "curl", "npm:typescript" or
+// "pip:requests==*".
+func CheckPackagesAllowed(managerName string, packages []string) error {
+	cfg := Get()
+	if !cfg.PackageManagementEnabled {
+		return fmt.Errorf("package management is disabled; enable it with the packageManagementEnabled setting")
+	}
+	if len(packages) == 0 {
+		return fmt.Errorf("no packages given")
+	}
+
+	for _, spec := range packages {
+		if !packageSpecPattern.MatchString(spec) {
+			return fmt.Errorf("invalid package %q", spec)
+		}
+		allowed := false
+		for _, entry := range cfg.AllowedPackages {
+			pattern := entry
+			if manager, rest, ok := strings.Cut(entry, ":"); ok && packageManagers[manager].binary != "" {
+				if manager != managerName {
+					continue
+				}
+				pattern = rest
+			}
+			if matched, err := path.Match(pattern, spec); err == nil && matched {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("package %s is not in allowedPackages", spec)
+		}
+	}
+	return nil
+}
+
+// ChangePackages installs or, with remove, removes packages with manager.
+// A dry run asks the manager what it would do where it supports that, and
+// otherwise only reports the command.
+func ChangePackages(ctx context.Context, managerName string, packages []string, remove, dryRun bool, timeout time.Duration, maxOutput int) (types.CommandResult, error) {
+	if err := CheckPackagesAllowed(managerName, packages); err != nil {
+		return types.CommandResult{}, err
+	}
+	manager, err := lookupPackageManager(managerName)
+	if err != nil {
+		return types.CommandResult{}, err
+	}
+
+	args := append([]string{}, manager.install...)
+	if remove {
+		args = append([]string{}, manager.remove...)
+	}
+	if dryRun && manager.dryRunFlag != "" {
+		args = append(args, manager.dryRunFlag)
+	}
+	args = append(args, packages...)
+
+	result := types.CommandResult{Command: strings.Join(args, " ")}
+	if dryRun && manager.dryRunFlag == "" {
+		result.Stdout = fmt.Sprintf("%s has no dry run; would run: %s\n", managerName, result.Command)
+		return result, nil
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout, stderr := NewOutputBuffer(maxOutput), NewOutputBuffer(maxOutput)
+	cmd := exec.CommandContext(cmdCtx, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	startedAt := time.Now()
+	err = cmd.Run()
+	result.ExitCode = CommandExitCode(err)
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	result.TimedOut = cmdCtx.Err() == context.DeadlineExceeded
+	result.Truncated = stdout.Truncated() || stderr.Truncated()
+	if dryRun && manager.dryRunFails && result.ExitCode > 0 {
+		result.ExitCode = 0
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+	if result.TimedOut {
+		result.Error = fmt.Sprintf("command timed out after %s", timeout)
+	}
+
+	if !dryRun {
+		tool := "install_packages"
+		if remove {
+			tool = "remove_packages"
+		}
+		RecordCommand(types.CommandHistoryEntry{
+			Tool:       tool,
+			Command:    result.Command,
+			StartedAt:  startedAt,
+			DurationMs: result.DurationMs,
+			ExitCode:   result.ExitCode,
+			Error:      result.Error,
+			Output:     result.Stdout + result.Stderr,
+		})
+	}
+	return result, nil
+}
+
+// parseTabPackages parses "name<TAB>version" lines
+func parseTabPackages(output string) ([]types.InstalledPackage, error) {
+	var packages []types.InstalledPackage
+	for _, line := range strings.Split(output, "\n") {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if ok && name != "" {
+			packages = append(packages, types.InstalledPackage{Name: name, Version: version})
+		}
+	}
+	return packages, nil
+}
+
+// parseSpacePackages parses "name version..." lines from brew list
+// --versions; several installed versions are joined with commas
+func parseSpacePackages(output string) ([]types.InstalledPackage, error) {
+	var packages []types.InstalledPackage
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			packages = append(packages, types.InstalledPackage{Name: fields[0], Version: strings.Join(fields[1:], ",")})
+		}
+	}
+	return packages, nil
+}
+
+func parseNPMPackages(output string) ([]types.InstalledPackage, error) {
+	var tree struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal([]byte(output), &tree); err != nil {
+		return nil, err
+	}
+	var packages []types.InstalledPackage
+	for name, dep := range tree.Dependencies {
+		packages = append(packages, types.InstalledPackage{Name: name, Version: dep.Version})
+	}
+	return packages, nil
+}
+
+func parsePipPackages(output string) ([]types.InstalledPackage, error) {
+	var packages []types.InstalledPackage
+	if err := json.Unmarshal([]byte(output), &packages); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// maxParallelCommands is the most commands execute_commands runs in one
+// call
+const maxParallelCommands = 50
+
+// RunCommands runs independent commands concurrently, at most parallel at
+// a time, and returns their results in the order they were given. Commands
+// must already have been checked against the blocked commands.
+func RunCommands(ctx context.Context, commands []types.ParallelCommand, parallel, maxOutput int) (types.ParallelResult, error) {
+	if len(commands) == 0 {
+		return types.ParallelResult{}, fmt.Errorf("no commands to run")
+	}
+	if len(commands) > maxParallelCommands {
+		return types.ParallelResult{}, fmt.Errorf("%d commands given; at most %d are allowed", len(commands), maxParallelCommands)
+	}
+	for i, command := range commands {
+		if command.Command == "" {
+			return types.ParallelResult{}, fmt.Errorf("command %d is empty", i+1)
+		}
+		if command.Shell == "" {
+			return types.ParallelResult{}, fmt.Errorf("command %d has no shell", i+1)
+		}
+		if _, err := ResolveTimeout(TimeoutCommand, float64(command.TimeoutSeconds)); err != nil {
+			return types.ParallelResult{}, fmt.Errorf("command %d: %v", i+1, err)
+		}
+		if command.WorkingDir != "" && !IsPathAllowed(command.WorkingDir) {
+			return types.ParallelResult{}, WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("command %d: access to working directory %s is not allowed", i+1, command.WorkingDir))
+		}
+	}
+	parallel = max(parallel, 1)
+
+	startedAt := time.Now()
+	result := types.ParallelResult{Results: make([]types.CommandResult, len(commands))}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, command := range commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				result.Results[i] = runParallelCommand(ctx, command, maxOutput)
+			case <-ctx.Done():
+				result.Results[i] = types.CommandResult{Command: command.Command, ExitCode: -1, Error: "cancelled before it started"}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, commandResult := range result.Results {
+		if commandResult.ExitCode == 0 && !commandResult.TimedOut {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	return result, nil
+}
+
+// runParallelCommand runs one command of RunCommands and records it in the
+// command history
+func runParallelCommand(ctx context.Context, command types.ParallelCommand, maxOutput int) types.CommandResult {
+	// RunCommands checked the timeout before starting the commands
+	timeout, _ := ResolveTimeout(TimeoutCommand, float64(command.TimeoutSeconds))
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout, stderr := NewOutputBuffer(maxOutput), NewOutputBuffer(maxOutput)
+	cmd := exec.CommandContext(cmdCtx, command.Shell, ShellCommandArgs(command.Shell, command.Command)...)
+	cmd.Dir = command.WorkingDir
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	startedAt := time.Now()
+	sandbox, err := SandboxCommand(cmd)
+	if err == nil {
+		err = cmd.Run()
+	}
+
+	result := types.CommandResult{
+		Command:    command.Command,
+		ExitCode:   CommandExitCode(err),
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		TimedOut:   cmdCtx.Err() == context.DeadlineExceeded,
+		Truncated:  stdout.Truncated() || stderr.Truncated(),
+		Sandbox:    sandbox,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.TimedOut {
+		result.Error = fmt.Sprintf("command timed out after %s", timeout)
+	}
+
+	RecordCommand(types.CommandHistoryEntry{
+		Tool:       "execute_commands",
+		Command:    command.Command,
+		Shell:      command.Shell,
+		WorkingDir: command.WorkingDir,
+		StartedAt:  startedAt,
+		DurationMs: result.DurationMs,
+		ExitCode:   result.ExitCode,
+		Error:      result.Error,
+		Output:     result.Stdout + result.Stderr,
+	})
+	return result
+}
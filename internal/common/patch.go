@@ -0,0 +1,445 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// FilePatch is the set of hunks a unified diff applies to one file. OldPath
+// is empty for newly created files and NewPath is empty for deleted files.
+type FilePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []DiffHunk
+}
+
+// Reverse returns the patch that undoes p
+func (p FilePatch) Reverse() FilePatch {
+	reversed := FilePatch{OldPath: p.NewPath, NewPath: p.OldPath}
+	for _, hunk := range p.Hunks {
+		r := DiffHunk{
+			OldStart: hunk.NewStart,
+			OldLines: hunk.NewLines,
+			NewStart: hunk.OldStart,
+			NewLines: hunk.OldLines,
+		}
+		for _, line := range hunk.Lines {
+			switch line[0] {
+			case '-':
+				line = "+" + line[1:]
+			case '+':
+				line = "-" + line[1:]
+			}
+			r.Lines = append(r.Lines, line)
+		}
+		reversed.Hunks = append(reversed.Hunks, r)
+	}
+	return reversed
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParsePatch parses unified diff text, including git-style multi-file
+// patches. strip removes that many leading path components like patch -p;
+// a negative value strips a/ and b/ prefixes only when present. Hunk line
+// counts in headers are not trusted since hand-written patches often get
+// them wrong.
+func ParsePatch(text string, strip int) ([]FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var patches []FilePatch
+	var current *FilePatch
+	// A diff --git header starts a file whose ---/+++ lines are still to come
+	pendingGitHeader := false
+
+	startFile := func() {
+		patches = append(patches, FilePatch{})
+		current = &patches[len(patches)-1]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			pendingGitHeader = true
+			if oldPath, newPath, ok := parseGitDiffHeader(line); ok {
+				current.OldPath = cleanPatchPath(oldPath, strip)
+				current.NewPath = cleanPatchPath(newPath, strip)
+			}
+
+		case current != nil && strings.HasPrefix(line, "rename from "):
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case current != nil && strings.HasPrefix(line, "rename to "):
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+		case current != nil && strings.HasPrefix(line, "new file mode"):
+			current.OldPath = ""
+		case current != nil && strings.HasPrefix(line, "deleted file mode"):
+			current.NewPath = ""
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if !pendingGitHeader || current == nil || len(current.Hunks) > 0 {
+				startFile()
+			}
+			pendingGitHeader = false
+			current.OldPath = cleanPatchPath(strings.TrimPrefix(line, "--- "), strip)
+			current.NewPath = cleanPatchPath(strings.TrimPrefix(lines[i+1], "+++ "), strip)
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			match := hunkHeaderPattern.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, line)
+			}
+			hunk, next := parseHunk(lines, i+1, match)
+			if len(hunk.Lines) == 0 {
+				return nil, fmt.Errorf("line %d: empty hunk", i+1)
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			pendingGitHeader = false
+			i = next - 1
+		}
+	}
+
+	// Drop headers without hunks (mode-only changes, binary files)
+	result := patches[:0]
+	for _, patch := range patches {
+		if len(patch.Hunks) > 0 || (patch.OldPath != patch.NewPath && patch.OldPath != "" && patch.NewPath != "") {
+			result = append(result, patch)
+		}
+	}
+	return result, nil
+}
+
+// parseHunk reads hunk body lines starting at index start and returns the
+// hunk together with the index of the first line after it
+func parseHunk(lines []string, start int, header []string) (DiffHunk, int) {
+	oldStart, _ := strconv.Atoi(header[1])
+	newStart, _ := strconv.Atoi(header[3])
+	oldCount, newCount := 1, 1
+	if header[2] != "" {
+		oldCount, _ = strconv.Atoi(header[2])
+	}
+	if header[4] != "" {
+		newCount, _ = strconv.Atoi(header[4])
+	}
+
+	hunk := DiffHunk{OldStart: oldStart, NewStart: newStart}
+	oldSeen, newSeen := 0, 0
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		complete := oldSeen >= oldCount && newSeen >= newCount
+
+		if line == "" {
+			// Blank context lines often lose their leading space; a blank
+			// line after a complete hunk separates files instead
+			if complete || i == len(lines)-1 {
+				break
+			}
+			line = " "
+		}
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff ") {
+			break
+		}
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			break
+		}
+
+		switch line[0] {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		case '\\':
+		default:
+			return finishHunk(hunk), i
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+
+	return finishHunk(hunk), i
+}
+
+func finishHunk(hunk DiffHunk) DiffHunk {
+	hunk.OldLines, hunk.NewLines = 0, 0
+	for _, line := range hunk.Lines {
+		switch line[0] {
+		case ' ':
+			hunk.OldLines++
+			hunk.NewLines++
+		case '-':
+			hunk.OldLines++
+		case '+':
+			hunk.NewLines++
+		}
+	}
+	return hunk
+}
+
+func parseGitDiffHeader(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	idx := strings.LastIndex(rest, " b/")
+	if idx < 0 {
+		parts := strings.Fields(rest)
+		if len(parts) != 2 {
+			return "", "", false
+		}
+		return parts[0], parts[1], true
+	}
+	return rest[:idx], rest[idx+1:], true
+}
+
+func cleanPatchPath(path string, strip int) string {
+	// Drop timestamps appended after a tab
+	if idx := strings.Index(path, "\t"); idx >= 0 {
+		path = path[:idx]
+	}
+	path = strings.Trim(strings.TrimSpace(path), `"`)
+	if path == "/dev/null" {
+		return ""
+	}
+
+	if strip < 0 {
+		if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+			return path[2:]
+		}
+		return path
+	}
+	for n := 0; n < strip; n++ {
+		idx := strings.Index(path, "/")
+		if idx < 0 {
+			break
+		}
+		path = path[idx+1:]
+	}
+	return path
+}
+
+// ApplyHunks applies hunks to content in order. A hunk that does not match
+// at its recorded line is searched for nearby; with fuzz > 0 up to that many
+// context lines at either end may be ignored. The returned bool is true when
+// every hunk applied.
+func ApplyHunks(content string, hunks []DiffHunk, fuzz int) (string, []types.PatchHunkResult, bool) {
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	working := strings.Split(content, "\n")
+	trailingNewline := true
+	if content != "" {
+		if working[len(working)-1] == "" {
+			working = working[:len(working)-1]
+		} else {
+			trailingNewline = false
+		}
+	} else {
+		working = nil
+	}
+	for i := range working {
+		working[i] = strings.TrimSuffix(working[i], "\r")
+	}
+
+	results := make([]types.PatchHunkResult, 0, len(hunks))
+	allApplied := true
+	delta := 0
+	minPos := 0
+
+	for n, hunk := range hunks {
+		result := types.PatchHunkResult{Hunk: n + 1}
+
+		expected := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			expected = hunk.OldStart
+		}
+		expected += delta
+
+		applied := false
+		for level := 0; level <= fuzz && !applied; level++ {
+			oldBlock, newBlock, lead, ok := hunkBlocks(hunk, level)
+			if !ok {
+				break
+			}
+
+			pos := findBlock(working, oldBlock, expected+lead, minPos)
+			if pos < 0 {
+				continue
+			}
+
+			working = SpliceLines(working, pos, pos+len(oldBlock), newBlock)
+
+			result.Applied = true
+			result.Line = pos - lead + 1
+			result.Offset = pos - (expected + lead)
+			result.Fuzz = level
+			delta += len(newBlock) - len(oldBlock)
+			minPos = pos + len(newBlock)
+			applied = true
+
+			if oldNoEOL, newNoEOL := hunkNoNewline(hunk); newNoEOL {
+				trailingNewline = false
+			} else if oldNoEOL {
+				trailingNewline = true
+			}
+		}
+
+		if !applied {
+			allApplied = false
+			oldBlock, newBlock, _, _ := hunkBlocks(hunk, 0)
+			if len(newBlock) > 0 && findBlock(working, newBlock, expected, 0) >= 0 && findBlock(working, oldBlock, expected, 0) < 0 {
+				result.Error = "hunk appears to be already applied"
+			}
+			if result.Error == "" {
+				result.Error = fmt.Sprintf("context not found near line %d", hunk.OldStart)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if len(working) == 0 {
+		return "", results, allApplied
+	}
+	output := strings.Join(working, eol)
+	if trailingNewline {
+		output += eol
+	}
+	return output, results, allApplied
+}
+
+// hunkBlocks returns the lines a hunk expects and produces, ignoring up to
+// fuzz context lines at each end. lead is the number of leading lines
+// ignored. ok is false when ignoring context would leave nothing to anchor
+// the hunk.
+func hunkBlocks(hunk DiffHunk, fuzz int) ([]string, []string, int, bool) {
+	var body []string
+	for _, line := range hunk.Lines {
+		if line[0] != '\\' {
+			body = append(body, line)
+		}
+	}
+
+	lead, trail := 0, 0
+	for lead < fuzz && lead < len(body) && body[lead][0] == ' ' {
+		lead++
+	}
+	for trail < fuzz && trail < len(body)-lead && body[len(body)-1-trail][0] == ' ' {
+		trail++
+	}
+	if fuzz > 0 && lead == 0 && trail == 0 {
+		return nil, nil, 0, false
+	}
+	body = body[lead : len(body)-trail]
+
+	var oldBlock, newBlock []string
+	for _, line := range body {
+		text := strings.TrimSuffix(line[1:], "\r")
+		if line[0] != '+' {
+			oldBlock = append(oldBlock, text)
+		}
+		if line[0] != '-' {
+			newBlock = append(newBlock, text)
+		}
+	}
+	if len(oldBlock) == 0 && hunk.OldLines > 0 {
+		return nil, nil, 0, false
+	}
+	return oldBlock, newBlock, lead, true
+}
+
+// hunkNoNewline reports whether the old and new side of a hunk end without
+// a trailing newline
+func hunkNoNewline(hunk DiffHunk) (bool, bool) {
+	oldNoEOL, newNoEOL := false, false
+	for i, line := range hunk.Lines {
+		if line[0] != '\\' || i == 0 {
+			continue
+		}
+		switch hunk.Lines[i-1][0] {
+		case '-':
+			oldNoEOL = true
+		case '+':
+			newNoEOL = true
+		case ' ':
+			oldNoEOL, newNoEOL = true, true
+		}
+	}
+	return oldNoEOL, newNoEOL
+}
+
+// findBlock searches outward from expected for block, never before minPos
+func findBlock(lines, block []string, expected, minPos int) int {
+	limit := len(lines) - len(block)
+	if limit < minPos {
+		return -1
+	}
+	expected = max(min(expected, limit), minPos)
+	if len(block) == 0 {
+		return expected
+	}
+
+	for distance := 0; ; distance++ {
+		before, after := expected-distance, expected+distance
+		if before < minPos && after > limit {
+			return -1
+		}
+		if after <= limit && blockMatches(lines, block, after) {
+			return after
+		}
+		if distance > 0 && before >= minPos && blockMatches(lines, block, before) {
+			return before
+		}
+	}
+}
+
+func blockMatches(lines, block []string, pos int) bool {
+	for i, line := range block {
+		if lines[pos+i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// patchArgumentPaths returns the files the patch of an apply_patch call
+// changes, resolved against its base_dir as the tool resolves them
+func patchArgumentPaths(arguments map[string]any) []string {
+	text, _ := arguments["patch"].(string)
+	strip := -1
+	if value, ok := arguments["strip"].(float64); ok {
+		strip = int(value)
+	}
+	patches, err := ParsePatch(text, strip)
+	if err != nil {
+		return nil
+	}
+	baseDir, _ := arguments["base_dir"].(string)
+
+	var paths []string
+	for _, patch := range patches {
+		for _, path := range []string{patch.OldPath, patch.NewPath} {
+			if path == "" {
+				continue
+			}
+			if !filepath.IsAbs(path) && baseDir != "" {
+				path = filepath.Join(baseDir, path)
+			}
+			if absPath, err := filepath.Abs(path); err == nil && !slices.Contains(paths, absPath) {
+				paths = append(paths, absPath)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,238 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// PipelineStageSkipped is the status of a stage that did not run; stages
+// that ran end with a job status
+const PipelineStageSkipped = "skipped"
+
+// Default stage timeout and maximum number of stages of run_pipeline
+const (
+	defaultPipelineStageTimeout = 5 * time.Minute
+	maxPipelineStages           = 50
+)
+
+// PipelineOptions configures RunPipeline
+type PipelineOptions struct {
+	Shell      string
+	WorkingDir string
+	// FailFast skips every remaining stage after a stage fails that does
+	// not have continue_on_error; otherwise only its dependents are skipped
+	FailFast bool
+	// MaxOutput is the size of the output excerpt kept for each stage
+	MaxOutput int
+	// OnStage is called after each stage ends, e.g. to report progress
+	OnStage func(done, total int, stage types.PipelineStageResult)
+}
+
+// ValidatePipeline checks stage names, dependencies, working directories
+// and commands, and returns the stages in the order they run: each after
+// its dependencies, otherwise in the order they were given
+func ValidatePipeline(stages []types.PipelineStage) ([]types.PipelineStage, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("pipeline has no stages")
+	}
+	if len(stages) > maxPipelineStages {
+		return nil, fmt.Errorf("pipeline has %d stages; at most %d are allowed", len(stages), maxPipelineStages)
+	}
+
+	index := make(map[string]int, len(stages))
+	for i, stage := range stages {
+		if strings.TrimSpace(stage.Name) == "" {
+			return nil, fmt.Errorf("stage %d has no name", i+1)
+		}
+		if _, exists := index[stage.Name]; exists {
+			return nil, fmt.Errorf("stage %s is defined twice", stage.Name)
+		}
+		if strings.TrimSpace(stage.Command) == "" {
+			return nil, fmt.Errorf("stage %s has no command", stage.Name)
+		}
+		if IsCommandBlocked(stage.Command) {
+			return nil, WithErrorCode(ErrorCommandBlocked, fmt.Errorf("stage %s: command contains blocked patterns", stage.Name))
+		}
+		if stage.TimeoutSeconds < 0 {
+			return nil, fmt.Errorf("stage %s: timeout_seconds cannot be negative", stage.Name)
+		}
+		if stage.WorkingDir != "" {
+			absDir, err := filepath.Abs(stage.WorkingDir)
+			if err != nil || !IsPathAllowed(absDir) {
+				return nil, WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("stage %s: access to working directory %s is not allowed", stage.Name, stage.WorkingDir))
+			}
+		}
+		index[stage.Name] = i
+	}
+	for _, stage := range stages {
+		for _, dep := range stage.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("stage %s depends on unknown stage %s", stage.Name, dep))
+			}
+			if dep == stage.Name {
+				return nil, fmt.Errorf("stage %s depends on itself", stage.Name)
+			}
+		}
+	}
+
+	// Repeatedly take the first stage whose dependencies have all been
+	// ordered, which keeps the given order wherever dependencies allow
+	ordered := make([]types.PipelineStage, 0, len(stages))
+	placed := make([]bool, len(stages))
+	for len(ordered) < len(stages) {
+		progressed := false
+		for i, stage := range stages {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for _, dep := range stage.DependsOn {
+				if !placed[index[dep]] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[i] = true
+				ordered = append(ordered, stage)
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for i, stage := range stages {
+				if !placed[i] {
+					cycle = append(cycle, stage.Name)
+				}
+			}
+			return nil, fmt.Errorf("stages have circular dependencies: %s", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+// RunPipeline runs the stages one at a time in dependency order. A stage
+// runs only when each stage it depends on succeeded or failed with
+// continue_on_error; the pipeline succeeds when no stage failed without
+// continue_on_error.
+func RunPipeline(ctx context.Context, stages []types.PipelineStage, opts PipelineOptions) (types.PipelineResult, error) {
+	ordered, err := ValidatePipeline(stages)
+	if err != nil {
+		return types.PipelineResult{}, err
+	}
+
+	startedAt := time.Now()
+	result := types.PipelineResult{Success: true, Stages: make([]types.PipelineStageResult, 0, len(ordered))}
+	// passed records whether dependents of a finished stage may run
+	passed := make(map[string]bool, len(ordered))
+	stopReason := ""
+
+	for _, stage := range ordered {
+		var stageResult types.PipelineStageResult
+		switch {
+		case stopReason != "":
+			stageResult = types.PipelineStageResult{Name: stage.Name, Status: PipelineStageSkipped, ExitCode: -1, Error: stopReason}
+		case ctx.Err() != nil:
+			stopReason = "pipeline was cancelled"
+			stageResult = types.PipelineStageResult{Name: stage.Name, Status: PipelineStageSkipped, ExitCode: -1, Error: stopReason}
+		default:
+			if blocker := failedDependency(stage, passed); blocker != "" {
+				stageResult = types.PipelineStageResult{Name: stage.Name, Status: PipelineStageSkipped, ExitCode: -1,
+					Error: fmt.Sprintf("dependency %s did not succeed", blocker)}
+			} else {
+				stageResult = runPipelineStage(ctx, stage, opts)
+			}
+		}
+
+		ok := stageResult.Status == JobStatusSuccess
+		passed[stage.Name] = ok || (stage.ContinueOnError && stageResult.Status != PipelineStageSkipped)
+		if !passed[stage.Name] {
+			result.Success = false
+			if opts.FailFast && stopReason == "" && stageResult.Status != PipelineStageSkipped {
+				stopReason = fmt.Sprintf("pipeline stopped after stage %s failed", stage.Name)
+			}
+		}
+
+		result.Stages = append(result.Stages, stageResult)
+		if opts.OnStage != nil {
+			opts.OnStage(len(result.Stages), len(ordered), stageResult)
+		}
+	}
+
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	return result, nil
+}
+
+// failedDependency returns the first dependency of stage that did not pass
+func failedDependency(stage types.PipelineStage, passed map[string]bool) string {
+	for _, dep := range stage.DependsOn {
+		if !passed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// runPipelineStage runs one stage and records it in the command history
+func runPipelineStage(ctx context.Context, stage types.PipelineStage, opts PipelineOptions) types.PipelineStageResult {
+	shell := stage.Shell
+	if shell == "" {
+		shell = opts.Shell
+	}
+	workingDir := stage.WorkingDir
+	if workingDir == "" {
+		workingDir = opts.WorkingDir
+	}
+	timeout := defaultPipelineStageTimeout
+	if stage.TimeoutSeconds > 0 {
+		timeout = time.Duration(stage.TimeoutSeconds) * time.Second
+	}
+
+	stageCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := types.PipelineStageResult{Name: stage.Name, Status: JobStatusSuccess}
+	output := NewOutputBuffer(opts.MaxOutput)
+	startedAt := time.Now()
+
+	cmd := exec.CommandContext(stageCtx, shell, ShellCommandArgs(shell, stage.Command)...)
+	cmd.Dir = workingDir
+	cmd.Stdout, cmd.Stderr = output, output
+	_, err := SandboxCommand(cmd)
+	if err == nil {
+		err = cmd.Run()
+	}
+
+	result.ExitCode = CommandExitCode(err)
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	result.Output, result.Truncated = output.String(), output.Truncated()
+	if err != nil {
+		result.Status = JobStatusFailure
+		result.Error = err.Error()
+	}
+	if stageCtx.Err() == context.DeadlineExceeded {
+		result.Status = JobStatusTimeout
+		result.Error = fmt.Sprintf("stage timed out after %s", timeout)
+	}
+
+	RecordCommand(types.CommandHistoryEntry{
+		Tool:       "run_pipeline",
+		Command:    stage.Command,
+		Shell:      shell,
+		WorkingDir: workingDir,
+		StartedAt:  startedAt,
+		DurationMs: result.DurationMs,
+		ExitCode:   result.ExitCode,
+		Error:      result.Error,
+		Output:     result.Output,
+		Truncated:  result.Truncated,
+	})
+	return result
+}
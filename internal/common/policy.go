@@ -0,0 +1,202 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// Effects of policyRules entries
+const (
+	PolicyEffectDeny    = "deny"
+	PolicyEffectApprove = "approve"
+	PolicyEffectAllow   = "allow"
+)
+
+// policyCommandArguments are the tool arguments holding commands, which
+// the commands of policy rules are matched against
+var policyCommandArguments = map[string]bool{"command": true, "commands": true, "script": true}
+
+// validatePolicyRules checks the policyRules setting
+func validatePolicyRules(config *types.ServerConfig) error {
+	for name, rule := range config.PolicyRules {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("policyRules cannot contain empty rule names")
+		}
+		switch rule.Effect {
+		case PolicyEffectDeny, PolicyEffectApprove, PolicyEffectAllow:
+		default:
+			return fmt.Errorf("policy rule %s has effect %q; use deny, approve or allow", name, rule.Effect)
+		}
+		if len(rule.Tools)+len(rule.Paths)+len(rule.Hosts)+len(rule.Commands) == 0 {
+			return fmt.Errorf("policy rule %s sets no tools, paths, hosts or commands to match", name)
+		}
+		for _, condition := range [][]string{rule.Tools, rule.Paths, rule.Hosts, rule.Commands} {
+			if slices.ContainsFunc(condition, func(entry string) bool { return strings.TrimSpace(entry) == "" }) {
+				return fmt.Errorf("policy rule %s cannot contain empty entries", name)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckToolPolicy decides a call of tool with the policyRules: refuse it
+// when a deny rule matches, else run it when an allow rule matches, else
+// wait for approval when an approve rule matches. A call no rule matches
+// is allowed.
+func CheckToolPolicy(tool string, arguments map[string]any) types.ToolPolicyCheck {
+	config := Get()
+	check := types.ToolPolicyCheck{Tool: tool, Decision: PolicyAllowed, Reason: "no policy rule matches the call"}
+	if len(config.PolicyRules) == 0 {
+		return check
+	}
+
+	check.Paths = ToolCallPaths(tool, arguments)
+	check.Hosts = policyHosts(grantArgumentStrings(arguments, nil))
+	check.Commands = policyCommands("", arguments, nil)
+
+	names := make([]string, 0, len(config.PolicyRules))
+	for name := range config.PolicyRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matched := make(map[string][]string)
+	for _, name := range names {
+		rule := config.PolicyRules[name]
+		if policyRuleMatches(rule, check) {
+			check.MatchedRules = append(check.MatchedRules, name)
+			matched[rule.Effect] = append(matched[rule.Effect], name)
+		}
+	}
+
+	switch {
+	case len(matched[PolicyEffectDeny]) > 0:
+		check.Decision = PolicyBlocked
+		check.Reason = policyReason(config, matched[PolicyEffectDeny][0], "denies the call")
+	case len(matched[PolicyEffectAllow]) > 0:
+		check.Reason = policyReason(config, matched[PolicyEffectAllow][0], "allows the call")
+	case len(matched[PolicyEffectApprove]) > 0:
+		check.Decision = PolicyNeedsApproval
+		check.Reason = policyReason(config, matched[PolicyEffectApprove][0], "requires approval of the call")
+	}
+	return check
+}
+
+func policyReason(config *types.ServerConfig, name, effect string) string {
+	reason := fmt.Sprintf("policy rule %s %s", name, effect)
+	if description := config.PolicyRules[name].Description; description != "" {
+		reason += ": " + description
+	}
+	return reason
+}
+
+// policyRuleMatches reports whether every condition a rule sets matches
+// the inputs of a call. A command too deeply nested to parse matches the
+// deny and approve rules that set commands, and no allow rule.
+func policyRuleMatches(rule types.PolicyRule, check types.ToolPolicyCheck) bool {
+	if len(rule.Tools) > 0 {
+		names := append([]string{check.Tool}, ToolCategories(check.Tool)...)
+		if !slices.ContainsFunc(rule.Tools, func(pattern string) bool {
+			return slices.ContainsFunc(names, func(name string) bool { return matchWildcard(pattern, name, "") })
+		}) {
+			return false
+		}
+	}
+	if len(rule.Paths) > 0 && !slices.ContainsFunc(check.Paths, func(path string) bool { return policyPathMatches(rule.Paths, path) }) {
+		return false
+	}
+	if len(rule.Hosts) > 0 && !slices.ContainsFunc(check.Hosts, func(host string) bool { return matchesAnyPattern(rule.Hosts, host) }) {
+		return false
+	}
+	if len(rule.Commands) > 0 && !slices.ContainsFunc(check.Commands, func(command string) bool {
+		matched, tooDeep := MatchAllCommandPatterns(command, rule.Commands)
+		return len(matched) > 0 || (tooDeep && rule.Effect != PolicyEffectAllow)
+	}) {
+		return false
+	}
+	return true
+}
+
+// policyPathMatches reports whether an absolute path, or the path its
+// symlinks lead to, matches one of the patterns, which may start with "~"
+func policyPathMatches(patterns []string, path string) bool {
+	paths := []string{path}
+	if resolved, ok := resolvePath(path); ok && resolved != path {
+		paths = append(paths, resolved)
+	}
+	for _, pattern := range patterns {
+		if expanded, err := ExpandHomeDir(pattern); err == nil {
+			pattern = expanded
+		}
+		if slices.ContainsFunc(paths, func(path string) bool { return matchWildcard(pattern, path, "") }) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyPattern(patterns []string, text string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool { return matchWildcard(strings.ToLower(pattern), text, "") })
+}
+
+// policyHosts returns the hosts of the URLs in the argument strings of a
+// call, lowercased
+func policyHosts(texts []string) []string {
+	var hosts []string
+	for _, text := range texts {
+		for _, match := range grantURLPattern.FindAllString(text, -1) {
+			parsed, err := url.Parse(match)
+			if err != nil || parsed.Hostname() == "" {
+				continue
+			}
+			if host := strings.ToLower(parsed.Hostname()); !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+// policyCommands collects the commands of a call's arguments, looking
+// inside strings holding JSON arrays or objects, such as the commands of
+// execute_commands
+func policyCommands(key string, value any, commands []string) []string {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, item := range v {
+			commands = policyCommands(name, item, commands)
+		}
+	case []any:
+		for _, item := range v {
+			commands = policyCommands(key, item, commands)
+		}
+	case string:
+		if decoded, ok := decodeJSONArgument(v); ok {
+			return policyCommands(key, decoded, commands)
+		}
+		if policyCommandArguments[key] && strings.TrimSpace(v) != "" {
+			commands = append(commands, v)
+		}
+	}
+	return commands
+}
+
+// decodeJSONArgument decodes an argument string holding a JSON array or
+// object
+func decodeJSONArgument(text string) (any, bool) {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var decoded any
+	if json.Unmarshal([]byte(trimmed), &decoded) != nil {
+		return nil, false
+	}
+	return decoded, true
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// setConfig sets a configuration value for the rest of the test; reset is
+// the value restored when the test ends
+func setConfig(t *testing.T, key, value, reset string) {
+	t.Helper()
+	if err := Set(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Set(key, reset) })
+}
+
+// nestedPathCalls are calls naming path inside a JSON argument or a patch,
+// as a file or a working directory
+func nestedPathCalls(path string) map[string]map[string]any {
+	files, _ := json.Marshal([]map[string]any{{"path": path, "operations": []any{}}})
+	stages, _ := json.Marshal([]map[string]any{{"name": "build", "command": "make", "working_dir": path}})
+	commands, _ := json.Marshal([]any{map[string]any{"command": "make", "working_dir": path}})
+	return map[string]map[string]any{
+		"edit_multiple_files": {"files": string(files)},
+		"apply_patch":         {"patch": "--- " + path + "\n+++ " + path + "\n@@ -1 +1 @@\n-a\n+b\n"},
+		"run_pipeline":        {"stages": string(stages)},
+		"execute_commands":    {"commands": string(commands)},
+	}
+}
+
+func TestCheckToolPolicyFindsPathsInsideArguments(t *testing.T) {
+	setConfig(t, "policyRules", `{"no-prod": {"effect": "deny", "paths": ["/srv/prod/*"]}}`, "{}")
+
+	for tool, arguments := range nestedPathCalls("/srv/prod/app") {
+		if check := CheckToolPolicy(tool, arguments); check.Decision != PolicyBlocked {
+			t.Errorf("%s: decision %s with paths %v, want %s", tool, check.Decision, check.Paths, PolicyBlocked)
+		}
+	}
+	for tool, arguments := range nestedPathCalls("/srv/staging/app") {
+		if check := CheckToolPolicy(tool, arguments); check.Decision != PolicyAllowed {
+			t.Errorf("%s: decision %s with paths %v, want %s", tool, check.Decision, check.Paths, PolicyAllowed)
+		}
+	}
+}
+
+func TestToolCallPathsDecodesJSONArguments(t *testing.T) {
+	arguments := nestedPathCalls("/srv/prod/app.yaml")["edit_multiple_files"]
+	paths := ToolCallPaths("edit_multiple_files", arguments)
+	if len(paths) != 1 || paths[0] != "/srv/prod/app.yaml" {
+		t.Errorf("ToolCallPaths = %v, want only the file inside files", paths)
+	}
+
+	// File content is never decoded, even when it is JSON naming a path
+	content := map[string]any{"path": "/srv/work/data.json", "content": `{"path": "/srv/prod/app.yaml"}`}
+	if paths := ToolCallPaths("write_file", content); len(paths) != 1 || paths[0] != "/srv/work/data.json" {
+		t.Errorf("ToolCallPaths = %v, want only the written file", paths)
+	}
+}
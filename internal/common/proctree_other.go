@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package common
+
+// There are no process groups to signal on this platform
+
+func isProcessGroupLeader(pid int) bool {
+	return false
+}
+
+func signalProcessGroup(pgid int, force bool) error {
+	return nil
+}
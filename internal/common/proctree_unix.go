@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package common
+
+import "syscall"
+
+// isProcessGroupLeader reports whether pid leads its process group
+func isProcessGroupLeader(pid int) bool {
+	pgid, err := syscall.Getpgid(pid)
+	return err == nil && pgid == pid
+}
+
+// signalProcessGroup sends SIGTERM, or SIGKILL when force is set, to the
+// process group pgid
+func signalProcessGroup(pgid int, force bool) error {
+	signal := syscall.SIGTERM
+	if force {
+		signal = syscall.SIGKILL
+	}
+	if err := syscall.Kill(-pgid, signal); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// CurrentConfigName stands for the live configuration in
+// DiffConfigProfiles
+const CurrentConfigName = "current"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// profileExcludedKeys are the configuration keys a profile cannot set
+var profileExcludedKeys = map[string]bool{"profiles": true, "activeProfile": true}
+
+// configKeys returns the JSON names of the configuration settings
+func configKeys() map[string]bool {
+	keys := make(map[string]bool)
+	configType := reflect.TypeOf(types.ServerConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && !profileExcludedKeys[name] {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// profileConfig returns the configuration switching to a profile gives:
+// the defaults with the profile's settings applied. The caller must hold
+// mutex.
+func profileConfig(name string) (*types.ServerConfig, error) {
+	raw, ok := instance.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no config profile named %s", name)
+	}
+	config := defaultConfig()
+	if err := json.Unmarshal(raw, config); err != nil {
+		return nil, fmt.Errorf("config profile %s is invalid: %v", name, err)
+	}
+	config.Profiles, config.ActiveProfile = instance.Profiles, instance.ActiveProfile
+	return config, nil
+}
+
+// configSettings returns the settings of a configuration as a JSON object,
+// leaving out the profiles
+func configSettings(config *types.ServerConfig) (map[string]any, error) {
+	copied := *config
+	copied.Profiles, copied.ActiveProfile = nil, ""
+	data, err := json.Marshal(copied)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]any
+	err = json.Unmarshal(data, &settings)
+	return settings, err
+}
+
+// ListConfigProfiles describes the saved profiles ordered by name
+func ListConfigProfiles() ([]types.ConfigProfileInfo, error) {
+	Initialize()
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	profiles := make([]types.ConfigProfileInfo, 0, len(instance.Profiles))
+	for name, raw := range instance.Profiles {
+		var settings map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, fmt.Errorf("config profile %s is invalid: %v", name, err)
+		}
+		info := types.ConfigProfileInfo{Name: name, Active: name == instance.ActiveProfile, Keys: make([]string, 0, len(settings))}
+		for key := range settings {
+			info.Keys = append(info.Keys, key)
+		}
+		sort.Strings(info.Keys)
+
+		// Settings changed since the active profile was switched to
+		if info.Active {
+			differences, err := diffConfigs(instance, name)
+			if err != nil {
+				return nil, err
+			}
+			info.Modified = len(differences) > 0
+		}
+		profiles = append(profiles, info)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// SaveConfigProfile saves a profile, replacing one with the same name.
+// settings is a JSON object of configuration keys; without it the current
+// configuration is saved.
+func SaveConfigProfile(name string, settings json.RawMessage) error {
+	if !profileNamePattern.MatchString(name) || name == CurrentConfigName {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '.', '_' and '-', other than %q", name, CurrentConfigName)
+	}
+
+	if len(bytes.TrimSpace(settings)) == 0 {
+		current, err := configSettings(Get())
+		if err != nil {
+			return fmt.Errorf("failed to read configuration: %v", err)
+		}
+		if settings, err = json.Marshal(current); err != nil {
+			return fmt.Errorf("failed to save configuration: %v", err)
+		}
+	} else {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(settings, &keys); err != nil {
+			return fmt.Errorf("settings must be a JSON object of configuration keys: %v", err)
+		}
+		known := configKeys()
+		for key := range keys {
+			if !known[key] {
+				return WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown configuration key in settings: %s", key))
+			}
+		}
+		config := defaultConfig()
+		if err := json.Unmarshal(settings, config); err != nil {
+			return fmt.Errorf("invalid settings: %v", err)
+		}
+		if err := ValidateConfig(config); err != nil {
+			return fmt.Errorf("invalid settings: %w", err)
+		}
+		compact := new(bytes.Buffer)
+		if err := json.Compact(compact, settings); err != nil {
+			return fmt.Errorf("invalid settings: %v", err)
+		}
+		settings = compact.Bytes()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if instance == nil {
+		Initialize()
+	}
+
+	// Copied rather than modified in place, since Get shares the map
+	profiles := make(map[string]json.RawMessage, len(instance.Profiles)+1)
+	for existing, raw := range instance.Profiles {
+		profiles[existing] = raw
+	}
+	profiles[name] = settings
+	instance.Profiles = profiles
+	saveToFile()
+	return nil
+}
+
+// DeleteConfigProfile removes a profile. Deleting the active profile keeps
+// its settings in effect.
+func DeleteConfigProfile(name string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if instance == nil {
+		Initialize()
+	}
+
+	if _, ok := instance.Profiles[name]; !ok {
+		return fmt.Errorf("no config profile named %s", name)
+	}
+	profiles := make(map[string]json.RawMessage, len(instance.Profiles))
+	for existing, raw := range instance.Profiles {
+		if existing != name {
+			profiles[existing] = raw
+		}
+	}
+	instance.Profiles = profiles
+	if instance.ActiveProfile == name {
+		instance.ActiveProfile = ""
+	}
+	saveToFile()
+	return nil
+}
+
+// SwitchConfigProfile replaces the configuration with the defaults plus the
+// profile's settings, after checking the result is valid, and returns the
+// settings that changed
+func SwitchConfigProfile(name string) ([]types.ConfigDifference, error) {
+	Initialize()
+	mutex.RLock()
+	config, err := profileConfig(name)
+	var differences []types.ConfigDifference
+	if err == nil {
+		differences, err = diffConfigs(instance, name)
+	}
+	mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validation reads the configuration, so it runs without the lock
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("config profile %s is invalid: %w", name, err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	config.Profiles = instance.Profiles
+	config.ActiveProfile = name
+	instance = config
+	saveToFile()
+	return differences, nil
+}
+
+// DiffConfigProfiles returns the settings that differ between two profiles,
+// either of which may be CurrentConfigName for the live configuration
+func DiffConfigProfiles(from, to string) ([]types.ConfigDifference, error) {
+	Initialize()
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	fromConfig := instance
+	if from != CurrentConfigName {
+		config, err := profileConfig(from)
+		if err != nil {
+			return nil, err
+		}
+		fromConfig = config
+	}
+	return diffConfigs(fromConfig, to)
+}
+
+// diffConfigs compares a configuration with a profile or the live
+// configuration. The caller must hold mutex.
+func diffConfigs(fromConfig *types.ServerConfig, to string) ([]types.ConfigDifference, error) {
+	toConfig := instance
+	if to != CurrentConfigName {
+		config, err := profileConfig(to)
+		if err != nil {
+			return nil, err
+		}
+		toConfig = config
+	}
+	return diffConfigSettings(fromConfig, toConfig)
+}
+
+// diffConfigSettings returns the settings that differ between two
+// configurations, ordered by key
+func diffConfigSettings(fromConfig, toConfig *types.ServerConfig) ([]types.ConfigDifference, error) {
+	fromSettings, err := configSettings(fromConfig)
+	if err != nil {
+		return nil, err
+	}
+	toSettings, err := configSettings(toConfig)
+	if err != nil {
+		return nil, err
+	}
+	return diffSettings(fromSettings, toSettings), nil
+}
+
+// diffSettings returns the settings that differ between two sets of
+// settings from configSettings, ordered by key
+func diffSettings(fromSettings, toSettings map[string]any) []types.ConfigDifference {
+	var keys []string
+	for key := range fromSettings {
+		keys = append(keys, key)
+	}
+	for key := range toSettings {
+		if _, ok := fromSettings[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	differences := []types.ConfigDifference{}
+	for _, key := range keys {
+		if !reflect.DeepEqual(fromSettings[key], toSettings[key]) {
+			differences = append(differences, types.ConfigDifference{Key: key, From: fromSettings[key], To: toSettings[key]})
+		}
+	}
+	return differences
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// startWithPTY starts cmd attached to a new pseudo-terminal of the given
+// size and returns the master side. The command becomes a session leader
+// with the terminal as its controlling terminal.
+func startWithPTY(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	var unlock int32
+	if err := ptyIoctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+	var number uint32
+	if err := ptyIoctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to get pseudo-terminal number: %w", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+	defer slave.Close()
+
+	if err := resizePTY(master, rows, cols); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+// resizePTY sets the window size of a pseudo-terminal; the foreground
+// program receives SIGWINCH
+func resizePTY(master *os.File, rows, cols int) error {
+	size := struct{ rows, cols, x, y uint16 }{uint16(rows), uint16(cols), 0, 0}
+	if err := ptyIoctl(master, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&size))); err != nil {
+		return fmt.Errorf("failed to resize pseudo-terminal: %w", err)
+	}
+	return nil
+}
+
+func ptyIoctl(file *os.File, request, arg uintptr) error {
+	// Control keeps the file in non-blocking mode, so closing it still
+	// interrupts a pending read
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
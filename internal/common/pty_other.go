@@ -0,0 +1,17 @@
+//go:build !linux
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func startWithPTY(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	return nil, fmt.Errorf("pseudo-terminal sessions are only supported on Linux")
+}
+
+func resizePTY(master *os.File, rows, cols int) error {
+	return fmt.Errorf("pseudo-terminal sessions are only supported on Linux")
+}
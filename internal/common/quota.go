@@ -0,0 +1,236 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"jarvis/internal/types"
+)
+
+// SessionQuotaCategories are the tool categories refused to a client
+// session that has reached a session quota
+var SessionQuotaCategories = []string{"write", "delete", "execute", "network"}
+
+// Session-wide write accounting used to enforce the write quotas, and the
+// usage of each client session counted against the session quotas
+var (
+	quotaMutex          sync.Mutex
+	sessionBytesWritten int64
+	sessionUsage        = make(map[string]*types.SessionUsage)
+)
+
+// CheckWriteQuota verifies that a tool call writing the given number of
+// bytes to each file stays within the configured write quotas
+func CheckWriteQuota(sizes ...int64) error {
+	config := Get()
+
+	if config.MaxFilesPerCall > 0 && len(sizes) > config.MaxFilesPerCall {
+		return WithErrorCode(ErrorQuotaExceeded, fmt.Errorf("operation modifies %d files, exceeding maxFilesPerCall limit of %d", len(sizes), config.MaxFilesPerCall))
+	}
+
+	var total int64
+	for _, size := range sizes {
+		if config.MaxWriteBytes > 0 && size > config.MaxWriteBytes {
+			return WithErrorCode(ErrorQuotaExceeded, fmt.Errorf("write of %s exceeds maxWriteBytes limit of %s", FormatBytes(size), FormatBytes(config.MaxWriteBytes)))
+		}
+		total += size
+	}
+
+	if config.MaxSessionWriteBytes > 0 {
+		quotaMutex.Lock()
+		used := sessionBytesWritten
+		quotaMutex.Unlock()
+
+		if used+total > config.MaxSessionWriteBytes {
+			return WithErrorCode(ErrorQuotaExceeded, fmt.Errorf("write of %s would exceed maxSessionWriteBytes limit of %s (%s already written this session)",
+				FormatBytes(total), FormatBytes(config.MaxSessionWriteBytes), FormatBytes(used)))
+		}
+	}
+
+	return nil
+}
+
+// CheckWriteLineLimit verifies that content written in a single call stays
+// within fileWriteLineLimit
+func CheckWriteLineLimit(content string) error {
+	limit := Get().FileWriteLineLimit
+	if limit <= 0 {
+		return nil
+	}
+
+	lines := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	if lines > limit {
+		return WithErrorCode(ErrorQuotaExceeded, fmt.Errorf("content has %d lines, exceeding fileWriteLineLimit of %d; write the first %d lines, then append the rest in chunks with append=true and expected_offset set to the size reported after each write",
+			lines, limit, limit))
+	}
+	return nil
+}
+
+// RecordWrite adds a successfully written file to the session total and
+// to the usage of the client session that wrote it
+func RecordWrite(session string, bytes int64) {
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	sessionBytesWritten += bytes
+	usage := sessionUsageFor(session)
+	usage.FilesWritten++
+	usage.BytesWritten += bytes
+}
+
+// RecordDownload adds downloaded bytes to the usage of a client session
+func RecordDownload(session string, bytes int64) {
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	sessionUsageFor(session).BytesDownloaded += bytes
+}
+
+// RecordProcessesKilled adds killed processes to the usage of a client
+// session
+func RecordProcessesKilled(session string, count int) {
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	sessionUsageFor(session).ProcessesKilled += count
+}
+
+// RecordCommands adds commands run to the usage of a client session
+func RecordCommands(session string, count int) {
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	sessionUsageFor(session).CommandsExecuted += count
+}
+
+// sessionUsageFor returns the usage of a client session, creating it on
+// first use; callers hold quotaMutex
+func sessionUsageFor(session string) *types.SessionUsage {
+	usage, ok := sessionUsage[session]
+	if !ok {
+		usage = &types.SessionUsage{Session: session}
+		sessionUsage[session] = usage
+	}
+	return usage
+}
+
+// exceededSessionQuotas lists the session quotas a usage has reached,
+// taking the quotas of the session's role into account
+func exceededSessionQuotas(usage types.SessionUsage, config *types.ServerConfig) []string {
+	limits := sessionQuotaLimits(config, usage.Role)
+	var exceeded []string
+	check := func(key string, used, limit int64) {
+		if limit > 0 && used >= limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s (%d of %d)", key, used, limit))
+		}
+	}
+	check("maxSessionFilesWritten", int64(usage.FilesWritten), int64(limits.MaxSessionFilesWritten))
+	check("maxSessionBytesDownloaded", usage.BytesDownloaded, limits.MaxSessionBytesDownloaded)
+	check("maxSessionProcessesKilled", int64(usage.ProcessesKilled), int64(limits.MaxSessionProcessesKilled))
+	check("maxSessionCommands", int64(usage.CommandsExecuted), int64(limits.MaxSessionCommands))
+	return exceeded
+}
+
+// CheckSessionQuotas refuses a call of tool by a client session that has
+// reached a session quota, when the tool is in one of
+// SessionQuotaCategories
+func CheckSessionQuotas(session, tool string) error {
+	categories := ToolCategories(tool)
+	if !slices.ContainsFunc(SessionQuotaCategories, func(category string) bool { return slices.Contains(categories, category) }) {
+		return nil
+	}
+
+	quotaMutex.Lock()
+	usage, ok := sessionUsage[session]
+	var copied types.SessionUsage
+	if ok {
+		copied = *usage
+	}
+	quotaMutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	copied.Role = sessionRole(session)
+	if exceeded := exceededSessionQuotas(copied, Get()); len(exceeded) > 0 {
+		return WithErrorCode(ErrorQuotaExceeded, fmt.Errorf("%s; %s is refused until an operator runs reset_session_quotas", strings.Join(exceeded, ", "), tool))
+	}
+	return nil
+}
+
+// GetSessionUsage returns the usage of every client session, ordered by
+// session
+func GetSessionUsage() []types.SessionUsage {
+	config := Get()
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+
+	usages := make([]types.SessionUsage, 0, len(sessionUsage))
+	for _, usage := range sessionUsage {
+		copied := *usage
+		copied.Role = sessionRole(copied.Session)
+		copied.Exceeded = exceededSessionQuotas(copied, config)
+		usages = append(usages, copied)
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Session < usages[j].Session })
+	return usages
+}
+
+// ResetSessionQuotas clears the usage of a client session, or of every
+// session and the bytes counted against maxSessionWriteBytes when session
+// is empty. It reports whether there was usage to clear.
+func ResetSessionQuotas(session string) bool {
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+
+	if session == "" {
+		cleared := len(sessionUsage) > 0 || sessionBytesWritten > 0
+		sessionUsage = make(map[string]*types.SessionUsage)
+		sessionBytesWritten = 0
+		return cleared
+	}
+	_, ok := sessionUsage[session]
+	delete(sessionUsage, session)
+	return ok
+}
+
+// SessionBytesWritten returns the number of bytes written this session
+func SessionBytesWritten() int64 {
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	return sessionBytesWritten
+}
+
+// CheckLargeEdit refuses an edit of an existing file that changes more
+// lines than largeEditLines, or more than largeEditPercent of the file,
+// unless it was confirmed. The error carries the diff for review.
+func CheckLargeEdit(path, before, after string, confirmed bool) error {
+	config := Get()
+	if confirmed || (config.LargeEditLines <= 0 && config.LargeEditPercent <= 0) || before == "" {
+		return nil
+	}
+
+	stat := DiffStats(ComputeDiffHunks(before, after, 0))
+	changed := max(stat.Added, stat.Deleted)
+
+	total := strings.Count(before, "\n")
+	if !strings.HasSuffix(before, "\n") {
+		total++
+	}
+	percent := min(changed*100/total, 100)
+
+	var reason string
+	switch {
+	case config.LargeEditLines > 0 && changed > config.LargeEditLines:
+		reason = fmt.Sprintf("exceeding largeEditLines limit of %d", config.LargeEditLines)
+	case config.LargeEditPercent > 0 && percent > config.LargeEditPercent:
+		reason = fmt.Sprintf("%d%% of the file, exceeding largeEditPercent limit of %d%%", percent, config.LargeEditPercent)
+	default:
+		return nil
+	}
+
+	return fmt.Errorf("edit changes %d of %d lines in %s, %s; review the diff and repeat with confirm_large_edit=true to apply it:\n%s",
+		changed, total, path, reason, EditDiff(path, path, before, after, 3))
+}
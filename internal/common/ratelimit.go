@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// rateLimitWindow is the period rateLimits count calls over
+const rateLimitWindow = time.Minute
+
+var (
+	rateLimitMutex sync.Mutex
+	// rateLimitCalls holds the times of the calls within the window, keyed
+	// by the rateLimits entry they count against
+	rateLimitCalls = make(map[string][]time.Time)
+)
+
+// defaultToolCategories returns the tool categories used unless
+// toolCategories is configured
+func defaultToolCategories() map[string][]string {
+	return map[string][]string{
+		"execute": {
+			"execute_command", "execute_commands", "run_shell_script", "run_pipeline", "run_template",
+			"start_command", "watch_command", "watch_and_run", "schedule_job", "ssh_execute_command",
+		},
+		"delete": {
+			"delete_file", "prune_backups", "cleanup_workspace", "kill_process", "kill_process_tree",
+			"terminate_session", "remove_packages",
+		},
+		"write": {
+			"write_file", "copy_file", "move_file", "rename_files", "create_directory", "touch_file", "set_xattr",
+			"restore_backup", "encrypt_file", "decrypt_file", "edit_block", "edit_file", "edit_multiple_files",
+			"apply_patch", "undo_last_edit", "undo_edits_since", "replace_text", "str_replace_edit", "insert_text",
+			"transform_lines", "format_code", "set_structured_value", "edit_yaml", "patch_json", "front_matter",
+			"insert_snippet", "update_markdown_toc", "number_markdown_headings", "normalize_unicode",
+			"beautify_file", "minify_file", "move_code", "ssh_copy_file", "install_packages",
+		},
+		"network": {
+			"fetch_web", "fetch_web_content", "fetch_web_file", "fetch_web_image", "fetch_web_json",
+			"fetch_web_batch", "check_url_status",
+		},
+	}
+}
+
+// ToolCategories returns the names of the categories a tool belongs to,
+// sorted
+func ToolCategories(tool string) []string {
+	var categories []string
+	for category, tools := range Get().ToolCategories {
+		if slices.Contains(tools, tool) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// validateRateLimits checks the toolCategories and rateLimits settings
+func validateRateLimits(config *types.ServerConfig) error {
+	for category, tools := range config.ToolCategories {
+		if strings.TrimSpace(category) == "" {
+			return fmt.Errorf("toolCategories cannot contain empty category names")
+		}
+		for _, tool := range tools {
+			if strings.TrimSpace(tool) == "" {
+				return fmt.Errorf("tool category %s cannot contain empty tool names", category)
+			}
+		}
+	}
+	for key, limit := range config.RateLimits {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("rateLimits cannot contain empty tool or category names")
+		}
+		if limit < 1 {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("rate limit of %s must be at least 1 call per minute", key))
+		}
+	}
+	return nil
+}
+
+// CheckRateLimit counts a call of tool against the rateLimits entries for
+// the tool and its categories. A call over any of them is refused with the
+// time until the oldest counted call leaves the window, and is not counted.
+func CheckRateLimit(tool string) error {
+	config := Get()
+	if len(config.RateLimits) == 0 {
+		return nil
+	}
+
+	keys := ToolCategories(tool)
+	if !slices.Contains(keys, tool) {
+		keys = append(keys, tool)
+	}
+	now := time.Now()
+
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+
+	var limited []string
+	for _, key := range keys {
+		limit, ok := config.RateLimits[key]
+		if !ok {
+			continue
+		}
+		calls := rateLimitCalls[key]
+		for len(calls) > 0 && now.Sub(calls[0]) >= rateLimitWindow {
+			calls = calls[1:]
+		}
+		rateLimitCalls[key] = calls
+		if len(calls) < limit {
+			limited = append(limited, key)
+			continue
+		}
+
+		retryAfter := time.Duration(math.Ceil(rateLimitWindow.Seconds()-now.Sub(calls[len(calls)-limit]).Seconds())) * time.Second
+		if key == tool {
+			return fmt.Errorf("%s allows %d calls per minute; retry after %s", tool, limit, retryAfter)
+		}
+		return fmt.Errorf("the %s tools allow %d calls per minute together; retry %s after %s", key, limit, tool, retryAfter)
+	}
+
+	for _, key := range limited {
+		rateLimitCalls[key] = append(rateLimitCalls[key], now)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// configReloadInterval is how often WatchConfigFile checks the config file
+const configReloadInterval = 2 * time.Second
+
+var (
+	configFileMutex sync.Mutex
+	// configFileHash is the hash of the config file as last loaded or
+	// saved, so the server does not reload its own writes
+	configFileHash [sha256.Size]byte
+	// configFileErr is why the config file was last rejected, if it was
+	configFileErr error
+)
+
+func rememberConfigFile(data []byte) {
+	configFileMutex.Lock()
+	configFileHash = sha256.Sum256(data)
+	configFileMutex.Unlock()
+}
+
+func setConfigFileError(err error) {
+	configFileMutex.Lock()
+	configFileErr = err
+	configFileMutex.Unlock()
+}
+
+// ConfigFileError returns why the config file was rejected when it was
+// last loaded, or nil when it was applied
+func ConfigFileError() error {
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+	return configFileErr
+}
+
+// ReloadConfig reads the config file again and, when the result is valid,
+// replaces the live configuration with it, returning the settings that
+// changed. Unless force is set, a file that has not changed since it was
+// last loaded or saved is skipped.
+func ReloadConfig(force bool) ([]types.ConfigDifference, error) {
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	configFileMutex.Lock()
+	unchanged := sha256.Sum256(data) == configFileHash
+	configFileMutex.Unlock()
+	if unchanged && !force {
+		return nil, nil
+	}
+
+	// An invalid file is remembered too, so it is reported once rather
+	// than on every check
+	config, err := parseConfigFile(data)
+	setConfigFileError(err)
+	if err != nil {
+		rememberConfigFile(data)
+		return nil, fmt.Errorf("%w; keeping the current configuration", err)
+	}
+
+	Initialize()
+	mutex.Lock()
+	defer mutex.Unlock()
+	differences, err := diffConfigSettings(instance, config)
+	if err != nil {
+		return nil, err
+	}
+	instance = config
+	rememberConfigFile(data)
+	notifyConfigChange()
+	return differences, nil
+}
+
+// WatchConfigFile checks the config file for changes until ctx is done and
+// reloads it when it changed. onReload is called after each reload that
+// changed settings or failed.
+func WatchConfigFile(ctx context.Context, onReload func(changes []types.ConfigDifference, err error)) {
+	var lastModTime time.Time
+	var lastSize int64
+	if info, err := os.Stat(getConfigPath()); err == nil {
+		lastModTime, lastSize = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(configReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(getConfigPath())
+		if err != nil || (info.ModTime().Equal(lastModTime) && info.Size() == lastSize) {
+			continue
+		}
+		lastModTime, lastSize = info.ModTime(), info.Size()
+
+		changes, err := ReloadConfig(false)
+		if err != nil || len(changes) > 0 {
+			onReload(changes, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// GlobToRegex converts a file name glob into an anchored regular expression
+// where every * and ? becomes a capture group, so "IMG_*.jpeg" can be
+// renamed with a template such as "photo-$1.jpg".
+func GlobToRegex(glob string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for _, ch := range glob {
+		switch ch {
+		case '*':
+			re.WriteString("(.*)")
+		case '?':
+			re.WriteString("(.)")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
+
+// PlanRenames computes the renames for every file name in dir matching
+// pattern. Collisions (two sources mapping to one target, or a target that
+// already exists and is not itself being renamed) are flagged on the plan.
+func PlanRenames(dir, pattern, replacement string, useGlob, recursive bool) ([]types.RenameOperation, error) {
+	if useGlob {
+		pattern = GlobToRegex(pattern)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	var plan []types.RenameOperation
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if SkipInWalk(path, info.Mode()) && path != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := info.Name()
+		if !re.MatchString(name) {
+			return nil
+		}
+
+		newName := re.ReplaceAllString(name, replacement)
+		if newName == name {
+			return nil
+		}
+		if newName == "" || strings.ContainsRune(newName, filepath.Separator) {
+			return fmt.Errorf("invalid target name %q for %s", newName, path)
+		}
+
+		plan = append(plan, types.RenameOperation{
+			Source:      path,
+			Destination: filepath.Join(filepath.Dir(path), newName),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect collisions
+	sources := make(map[string]bool, len(plan))
+	for _, op := range plan {
+		sources[op.Source] = true
+	}
+	targets := make(map[string]int, len(plan))
+	for i, op := range plan {
+		if prev, exists := targets[op.Destination]; exists {
+			plan[i].Conflict = fmt.Sprintf("same target as %s", plan[prev].Source)
+			if plan[prev].Conflict == "" {
+				plan[prev].Conflict = fmt.Sprintf("same target as %s", op.Source)
+			}
+			continue
+		}
+		targets[op.Destination] = i
+
+		if _, err := os.Stat(op.Destination); err == nil && !sources[op.Destination] {
+			plan[i].Conflict = "target already exists"
+		}
+	}
+
+	return plan, nil
+}
+
+// ExecuteRenames applies a rename plan. Renames are staged through unique
+// temporary names so that chains and swaps (a->b, b->a) do not clobber each
+// other, and a target that appeared since the plan was made is never
+// overwritten. When any rename fails, those already made are reverted.
+func ExecuteRenames(plan []types.RenameOperation) error {
+	for _, op := range plan {
+		if op.Conflict != "" {
+			return fmt.Errorf("conflict for %s: %s", op.Source, op.Conflict)
+		}
+	}
+
+	staged := make([]string, 0, len(plan))
+	for _, op := range plan {
+		tmp, err := stageRename(op.Source)
+		if err != nil {
+			rollbackRenames(plan, staged, 0)
+			return fmt.Errorf("failed to rename %s: %w", op.Source, err)
+		}
+		staged = append(staged, tmp)
+	}
+
+	for i, op := range plan {
+		// Sources have all been moved aside, so anything here now is a file
+		// the plan does not own
+		if _, err := os.Lstat(op.Destination); !os.IsNotExist(err) {
+			rollbackRenames(plan, staged, i)
+			return WithErrorCode(ErrorAlreadyExists, fmt.Errorf("failed to rename %s: %s already exists", op.Source, op.Destination))
+		}
+		if err := os.Rename(staged[i], op.Destination); err != nil {
+			rollbackRenames(plan, staged, i)
+			return fmt.Errorf("failed to rename %s to %s: %w", op.Source, op.Destination, err)
+		}
+	}
+
+	return nil
+}
+
+// stageRename moves source to a new temporary name in its directory and
+// returns that name
+func stageRename(source string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(source), "."+filepath.Base(source)+".rename-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	if err := os.Rename(source, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// rollbackRenames reverts a partly applied plan: the first placed
+// operations are moved back to their temporary names, then every staged
+// file to its source
+func rollbackRenames(plan []types.RenameOperation, staged []string, placed int) {
+	for i := placed - 1; i >= 0; i-- {
+		os.Rename(plan[i].Destination, staged[i])
+	}
+	for i := len(staged) - 1; i >= 0; i-- {
+		os.Rename(staged[i], plan[i].Source)
+	}
+}
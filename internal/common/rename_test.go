@@ -0,0 +1,77 @@
+package common
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"jarvis/internal/types"
+)
+
+// renameDir creates a directory holding files with the given contents
+func renameDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// dirContents returns the files of dir by name
+func dirContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestExecuteRenamesSwapsFiles(t *testing.T) {
+	dir := renameDir(t, map[string]string{"a.txt": "a", "b.txt": "b", "a.txt.rename-0.tmp": "unrelated"})
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	plan := []types.RenameOperation{{Source: a, Destination: b}, {Source: b, Destination: a}}
+
+	if err := ExecuteRenames(plan); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a.txt": "b", "b.txt": "a", "a.txt.rename-0.tmp": "unrelated"}
+	if got := dirContents(t, dir); !maps.Equal(got, want) {
+		t.Errorf("directory holds %v, want %v", got, want)
+	}
+}
+
+func TestExecuteRenamesRollsBackOnFailure(t *testing.T) {
+	dir := renameDir(t, map[string]string{"1.txt": "one", "2.txt": "two"})
+	plan, err := PlanRenames(dir, "*.txt", "file-$1.txt", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(plan, func(x, y types.RenameOperation) int { return strings.Compare(x.Source, y.Source) })
+	// A file appears at the second target after the plan was made
+	if err := os.WriteFile(filepath.Join(dir, "file-2.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExecuteRenames(plan); err == nil {
+		t.Fatal("ExecuteRenames overwrote a target created after planning")
+	}
+	want := map[string]string{"1.txt": "one", "2.txt": "two", "file-2.txt": "new"}
+	if got := dirContents(t, dir); !maps.Equal(got, want) {
+		t.Errorf("directory holds %v after the failed renames, want %v", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"jarvis/internal/types"
+)
+
+var (
+	rolesMutex sync.Mutex
+	// sessionRoles holds the role each client session last called a tool
+	// with, so the session quotas of the role apply to it
+	sessionRoles = make(map[string]string)
+)
+
+// validateRoles checks the roles, clientRoles and defaultRole settings
+func validateRoles(config *types.ServerConfig) error {
+	for name, role := range config.Roles {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("roles cannot contain empty role names")
+		}
+		for _, dir := range role.AllowedDirectories {
+			if err := validateAllowedDirectory(dir); err != nil {
+				return fmt.Errorf("role %s: %v", name, err)
+			}
+		}
+		for _, tool := range append(append([]string{}, role.Tools...), role.DeniedTools...) {
+			if strings.TrimSpace(tool) == "" {
+				return fmt.Errorf("role %s cannot contain empty tool names", name)
+			}
+		}
+		if role.MaxSessionFilesWritten < 0 || role.MaxSessionBytesDownloaded < 0 || role.MaxSessionProcessesKilled < 0 || role.MaxSessionCommands < 0 {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("role %s cannot have negative session quotas", name))
+		}
+	}
+	for client, role := range config.ClientRoles {
+		if _, ok := config.Roles[role]; !ok {
+			return WithErrorCode(ErrorNotFound, fmt.Errorf("clientRoles maps %q to unknown role %q", client, role))
+		}
+	}
+	if _, ok := config.Roles[config.DefaultRole]; config.DefaultRole != "" && !ok {
+		return fmt.Errorf("defaultRole %q is not in roles", config.DefaultRole)
+	}
+	return nil
+}
+
+// ResolveClientRole returns the role of an MCP client: the clientRoles
+// entry naming the client, else the first pattern in name order matching
+// it, else defaultRole. The role is empty when none applies.
+func ResolveClientRole(client string) types.ClientRole {
+	config := Get()
+	resolved := types.ClientRole{Client: client}
+
+	if role, ok := config.ClientRoles[client]; ok {
+		resolved.Role, resolved.MatchedBy = role, client
+	} else {
+		patterns := make([]string, 0, len(config.ClientRoles))
+		for pattern := range config.ClientRoles {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			if matchWildcard(pattern, client, "") {
+				resolved.Role, resolved.MatchedBy = config.ClientRoles[pattern], pattern
+				break
+			}
+		}
+	}
+	if resolved.Role == "" && config.DefaultRole != "" {
+		resolved.Role, resolved.MatchedBy = config.DefaultRole, "defaultRole"
+	}
+
+	if role, ok := config.Roles[resolved.Role]; ok {
+		resolved.Privileges = &role
+	}
+	return resolved
+}
+
+// CheckClientRole refuses a call of tool by a client whose role does not
+// allow the tool or a file the arguments name, and records the role of
+// the session for its session quotas
+func CheckClientRole(session, client, tool string, arguments map[string]any) error {
+	resolved := ResolveClientRole(client)
+	rolesMutex.Lock()
+	sessionRoles[session] = resolved.Role
+	rolesMutex.Unlock()
+
+	role := resolved.Privileges
+	if role == nil {
+		return nil
+	}
+	if !roleAllowsTool(*role, tool) {
+		return fmt.Errorf("role %s of client %q does not allow %s", resolved.Role, client, tool)
+	}
+	if len(role.AllowedDirectories) == 0 {
+		return nil
+	}
+
+	allowedDirs := append(append([]string{}, role.AllowedDirectories...), workspaceDirectories()...)
+	for _, file := range ToolCallPaths(tool, arguments) {
+		if !isWithinDirectories(file, allowedDirs) {
+			return WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("role %s of client %q does not allow access to %s", resolved.Role, client, file))
+		}
+	}
+	return nil
+}
+
+// ClientAllowsTool reports whether the role of an MCP client lets it call
+// tool
+func ClientAllowsTool(client, tool string) bool {
+	resolved := ResolveClientRole(client)
+	return resolved.Privileges == nil || roleAllowsTool(*resolved.Privileges, tool)
+}
+
+// roleAllowsTool reports whether a role may call tool, which its tools and
+// deniedTools may name directly or through a tool category
+func roleAllowsTool(role types.Role, tool string) bool {
+	names := append([]string{tool}, ToolCategories(tool)...)
+	named := func(list []string) bool {
+		return slices.ContainsFunc(names, func(name string) bool { return slices.Contains(list, name) })
+	}
+	if named(role.DeniedTools) {
+		return false
+	}
+	return len(role.Tools) == 0 || named(role.Tools)
+}
+
+// isWithinDirectories reports whether an absolute path, and the path its
+// symlinks lead to, are within one of the allowedDirectories entries
+func isWithinDirectories(absPath string, dirs []string) bool {
+	resolved, ok := resolvePath(absPath)
+	if !ok {
+		return false
+	}
+	lexicalAllowed, resolvedAllowed := false, false
+	for _, dir := range dirs {
+		if matchAllowedDirectory(absPath, dir) {
+			lexicalAllowed = true
+		}
+		if matchAllowedDirectory(resolved, dir) || matchAllowedDirectory(resolved, resolveAllowedDirectory(dir)) {
+			resolvedAllowed = true
+		}
+	}
+	return lexicalAllowed && resolvedAllowed
+}
+
+// sessionRole returns the role a client session last called a tool with
+func sessionRole(session string) string {
+	rolesMutex.Lock()
+	defer rolesMutex.Unlock()
+	return sessionRoles[session]
+}
+
+// sessionAllowsPath reports whether the role a client session last called
+// a tool with lets it reach an absolute path
+func sessionAllowsPath(session, absPath string) bool {
+	role, ok := Get().Roles[sessionRole(session)]
+	if !ok || len(role.AllowedDirectories) == 0 {
+		return true
+	}
+	return isWithinDirectories(absPath, append(append([]string{}, role.AllowedDirectories...), workspaceDirectories()...))
+}
+
+// sessionQuotaLimits returns the server-wide session quotas with those the
+// role sets replacing them
+func sessionQuotaLimits(config *types.ServerConfig, roleName string) types.Role {
+	limits := types.Role{
+		MaxSessionFilesWritten:    config.MaxSessionFilesWritten,
+		MaxSessionBytesDownloaded: config.MaxSessionBytesDownloaded,
+		MaxSessionProcessesKilled: config.MaxSessionProcessesKilled,
+		MaxSessionCommands:        config.MaxSessionCommands,
+	}
+	role, ok := config.Roles[roleName]
+	if !ok {
+		return limits
+	}
+	if role.MaxSessionFilesWritten > 0 {
+		limits.MaxSessionFilesWritten = role.MaxSessionFilesWritten
+	}
+	if role.MaxSessionBytesDownloaded > 0 {
+		limits.MaxSessionBytesDownloaded = role.MaxSessionBytesDownloaded
+	}
+	if role.MaxSessionProcessesKilled > 0 {
+		limits.MaxSessionProcessesKilled = role.MaxSessionProcessesKilled
+	}
+	if role.MaxSessionCommands > 0 {
+		limits.MaxSessionCommands = role.MaxSessionCommands
+	}
+	return limits
+}
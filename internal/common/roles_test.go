@@ -0,0 +1,28 @@
+package common
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckClientRoleChecksPathsInsideArguments(t *testing.T) {
+	work, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, _ := json.Marshal(map[string]any{"restricted": map[string]any{"allowedDirectories": []string{work}}})
+	setConfig(t, "roles", string(roles), "{}")
+	setConfig(t, "clientRoles", `{"agent": "restricted"}`, "{}")
+
+	for tool, arguments := range nestedPathCalls(filepath.Join(work, "app")) {
+		if err := CheckClientRole("session", "agent", tool, arguments); err != nil {
+			t.Errorf("%s inside the role's directories: %v", tool, err)
+		}
+	}
+	for tool, arguments := range nestedPathCalls("/srv/prod/app") {
+		if err := CheckClientRole("session", "agent", tool, arguments); err == nil {
+			t.Errorf("%s outside the role's directories was allowed", tool)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+)
+
+// CheckRunAsUser reports whether commands may run as username. Only users
+// listed in the runAsUsers setting are permitted; an empty list disables
+// run_as_user.
+func CheckRunAsUser(username string) error {
+	allowed := Get().RunAsUsers
+	if len(allowed) == 0 {
+		return fmt.Errorf("run_as_user is disabled; list the permitted users in the runAsUsers setting")
+	}
+	if !slices.Contains(allowed, username) {
+		return WithErrorCode(ErrorPermissionDenied, fmt.Errorf("running commands as %s is not allowed by runAsUsers", username))
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package common
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func ConfigureRunAsUser(cmd *exec.Cmd, username string) error {
+	if err := CheckRunAsUser(username); err != nil {
+		return err
+	}
+	return fmt.Errorf("run_as_user is not supported on this platform")
+}
@@ -0,0 +1,78 @@
+//go:build linux || darwin
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ConfigureRunAsUser makes cmd run as username. When the server runs as
+// root the process credentials are switched directly; otherwise the
+// command is wrapped in a non-interactive sudo, which must be permitted
+// without a password.
+func ConfigureRunAsUser(cmd *exec.Cmd, username string) error {
+	if err := CheckRunAsUser(username); err != nil {
+		return err
+	}
+
+	account, err := user.Lookup(username)
+	if err != nil {
+		return WithErrorCode(ErrorNotFound, fmt.Errorf("unknown user %s", username))
+	}
+	if account.Uid == strconv.Itoa(os.Geteuid()) {
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		sudo, err := exec.LookPath("sudo")
+		if err != nil {
+			return fmt.Errorf("running as %s needs the server to run as root or sudo to be installed", username)
+		}
+		cmd.Args = append([]string{"sudo", "-n", "-H", "-u", account.Username, "--", cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = sudo
+		return nil
+	}
+
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid uid for %s: %s", username, account.Uid)
+	}
+	gid, err := strconv.ParseUint(account.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid gid for %s: %s", username, account.Gid)
+	}
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	if groupIDs, err := account.GroupIds(); err == nil {
+		for _, id := range groupIDs {
+			if group, err := strconv.ParseUint(id, 10, 32); err == nil {
+				credential.Groups = append(credential.Groups, uint32(group))
+			}
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = credential
+
+	// Give the command the target user's identity rather than the server's
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = make([]string, 0, len(env)+3)
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if name != "HOME" && name != "USER" && name != "LOGNAME" {
+			cmd.Env = append(cmd.Env, entry)
+		}
+	}
+	cmd.Env = append(cmd.Env, "HOME="+account.HomeDir, "USER="+account.Username, "LOGNAME="+account.Username)
+	return nil
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Sandbox backends; SandboxNone runs commands directly
+const (
+	SandboxNone     = ""
+	SandboxBwrap    = "bwrap"
+	SandboxFirejail = "firejail"
+)
+
+// SandboxCommand wraps cmd in the configured sandbox. Inside it the file
+// system is read-only except for the allowed directories, and the network
+// is unavailable unless sandboxAllowNetwork is set. Commands are checked
+// against the blocked-command list before they get here; the sandbox
+// limits what a command that passes the check can change.
+//
+// It returns the backend used, or an error when the sandbox is enabled but
+// cannot be used, so commands never silently run unconfined.
+func SandboxCommand(cmd *exec.Cmd) (string, error) {
+	cfg := Get()
+	if cfg.Sandbox == SandboxNone {
+		return "", nil
+	}
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("sandbox %s is only supported on Linux", cfg.Sandbox)
+	}
+
+	binary, err := exec.LookPath(cfg.Sandbox)
+	if err != nil {
+		return "", fmt.Errorf("sandbox %s is enabled but not installed", cfg.Sandbox)
+	}
+
+	var args []string
+	switch cfg.Sandbox {
+	case SandboxBwrap:
+		args = []string{"bwrap", "--die-with-parent", "--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--unshare-pid", "--unshare-ipc", "--unshare-uts"}
+		if !cfg.SandboxAllowNetwork {
+			args = append(args, "--unshare-net")
+		}
+		for _, dir := range sandboxWritableDirs() {
+			args = append(args, "--bind", dir, dir)
+		}
+		if cmd.Dir != "" {
+			args = append(args, "--chdir", cmd.Dir)
+		}
+	case SandboxFirejail:
+		args = []string{"firejail", "--quiet", "--noprofile", "--read-only=/"}
+		if !cfg.SandboxAllowNetwork {
+			args = append(args, "--net=none")
+		}
+		for _, dir := range sandboxWritableDirs() {
+			args = append(args, "--read-write="+dir)
+		}
+	default:
+		return "", WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown sandbox %q", cfg.Sandbox))
+	}
+
+	cmd.Args = append(append(args, "--", cmd.Path), cmd.Args[1:]...)
+	cmd.Path = binary
+	return cfg.Sandbox, nil
+}
+
+// sandboxWritableDirs returns the allowed directories that exist, with
+// patterns expanded, which stay writable inside the sandbox
+func sandboxWritableDirs() []string {
+	dirs := expandAllowedDirectories(Get().AllowedDirectories)
+	dirs = append(dirs, workspaceDirectories()...)
+	dirs = append(dirs, expandAllowedDirectories(grantedValues(GrantDirectory))...)
+
+	seen := make(map[string]bool)
+	var writable []string
+	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil || seen[absDir] {
+			continue
+		}
+		seen[absDir] = true
+		if info, err := os.Stat(absDir); err == nil && info.IsDir() {
+			writable = append(writable, absDir)
+		}
+	}
+	return writable
+}
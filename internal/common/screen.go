@@ -0,0 +1,303 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Escape sequence parser states
+const (
+	screenNormal = iota
+	screenEscape
+	screenCSI
+	screenOSC
+	screenOSCEscape
+	screenCharset
+)
+
+// TerminalScreen keeps the visible screen of a pseudo-terminal session by
+// interpreting the common VT100/xterm control sequences: cursor movement,
+// erasing, scrolling and line editing. Colors and other attributes are
+// ignored.
+type TerminalScreen struct {
+	rows, cols int
+	cells      [][]rune
+	row, col   int
+	savedRow   int
+	savedCol   int
+	state      int
+	params     []byte
+	pending    []byte
+}
+
+// NewTerminalScreen creates an empty screen of the given size
+func NewTerminalScreen(rows, cols int) *TerminalScreen {
+	s := &TerminalScreen{rows: rows, cols: cols}
+	s.cells = make([][]rune, rows)
+	for i := range s.cells {
+		s.cells[i] = blankScreenLine(cols)
+	}
+	return s
+}
+
+func blankScreenLine(cols int) []rune {
+	line := make([]rune, cols)
+	for i := range line {
+		line[i] = ' '
+	}
+	return line
+}
+
+// Size returns the screen size
+func (s *TerminalScreen) Size() (rows, cols int) {
+	return s.rows, s.cols
+}
+
+// Cursor returns the 0-based cursor position
+func (s *TerminalScreen) Cursor() (row, col int) {
+	return s.row, min(s.col, s.cols-1)
+}
+
+// Resize changes the screen size, keeping the top-left content
+func (s *TerminalScreen) Resize(rows, cols int) {
+	cells := make([][]rune, rows)
+	for i := range cells {
+		cells[i] = blankScreenLine(cols)
+		if i < s.rows {
+			copy(cells[i], s.cells[i])
+		}
+	}
+	s.cells, s.rows, s.cols = cells, rows, cols
+	s.row, s.col = min(s.row, rows-1), min(s.col, cols-1)
+}
+
+// Render returns the screen as text with trailing blanks removed
+func (s *TerminalScreen) Render() string {
+	lines := make([]string, s.rows)
+	for i, line := range s.cells {
+		lines[i] = strings.TrimRight(string(line), " ")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// Write interprets terminal output
+func (s *TerminalScreen) Write(p []byte) (int, error) {
+	data := append(s.pending, p...)
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		s.put(r)
+	}
+	s.pending = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (s *TerminalScreen) put(r rune) {
+	switch s.state {
+	case screenEscape:
+		s.state = screenNormal
+		switch r {
+		case '[':
+			s.state, s.params = screenCSI, s.params[:0]
+		case ']':
+			s.state = screenOSC
+		case '(', ')':
+			s.state = screenCharset
+		case '7':
+			s.savedRow, s.savedCol = s.row, s.col
+		case '8':
+			s.row, s.col = s.savedRow, s.savedCol
+		case 'M':
+			if s.row == 0 {
+				s.scrollDown(0, 1)
+			} else {
+				s.row--
+			}
+		case 'c':
+			*s = *NewTerminalScreen(s.rows, s.cols)
+		}
+		return
+	case screenCSI:
+		switch {
+		case r >= 0x30 && r <= 0x3F:
+			s.params = append(s.params, byte(r))
+		case r >= 0x40 && r <= 0x7E:
+			s.state = screenNormal
+			s.csi(r)
+		case r < 0x20 || r > 0x7E:
+			s.state = screenNormal
+		}
+		return
+	case screenOSC:
+		if r == '\a' {
+			s.state = screenNormal
+		} else if r == 0x1b {
+			s.state = screenOSCEscape
+		}
+		return
+	case screenOSCEscape:
+		s.state = screenNormal
+		return
+	case screenCharset:
+		s.state = screenNormal
+		return
+	}
+
+	switch r {
+	case 0x1b:
+		s.state = screenEscape
+	case '\r':
+		s.col = 0
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\b':
+		if s.col > 0 {
+			s.col = min(s.col, s.cols) - 1
+		}
+	case '\t':
+		s.col = min((s.col/8+1)*8, s.cols-1)
+	default:
+		if r < 0x20 || r == 0x7f {
+			return
+		}
+		if s.col >= s.cols {
+			s.col = 0
+			s.lineFeed()
+		}
+		s.cells[s.row][s.col] = r
+		s.col++
+	}
+}
+
+func (s *TerminalScreen) lineFeed() {
+	if s.row == s.rows-1 {
+		s.scrollUp(0, 1)
+	} else {
+		s.row++
+	}
+}
+
+// scrollUp moves lines from top down by n, adding blank lines at the bottom
+func (s *TerminalScreen) scrollUp(top, n int) {
+	for ; n > 0; n-- {
+		copy(s.cells[top:], s.cells[top+1:])
+		s.cells[s.rows-1] = blankScreenLine(s.cols)
+	}
+}
+
+// scrollDown moves lines from top down by n, adding blank lines at top
+func (s *TerminalScreen) scrollDown(top, n int) {
+	for ; n > 0; n-- {
+		copy(s.cells[top+1:], s.cells[top:s.rows-1])
+		s.cells[top] = blankScreenLine(s.cols)
+	}
+}
+
+func (s *TerminalScreen) csi(final rune) {
+	private := strings.HasPrefix(string(s.params), "?")
+	var args []int
+	for _, field := range strings.Split(strings.TrimLeft(string(s.params), "?>="), ";") {
+		n, _ := strconv.Atoi(field)
+		args = append(args, n)
+	}
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+	clampRow := func(row int) int { return max(0, min(row, s.rows-1)) }
+	clampCol := func(col int) int { return max(0, min(col, s.cols-1)) }
+	col := min(s.col, s.cols-1)
+
+	switch final {
+	case 'A':
+		s.row = clampRow(s.row - arg(0, 1))
+	case 'B':
+		s.row = clampRow(s.row + arg(0, 1))
+	case 'C':
+		s.col = clampCol(col + arg(0, 1))
+	case 'D':
+		s.col = clampCol(col - arg(0, 1))
+	case 'E':
+		s.row, s.col = clampRow(s.row+arg(0, 1)), 0
+	case 'F':
+		s.row, s.col = clampRow(s.row-arg(0, 1)), 0
+	case 'G':
+		s.col = clampCol(arg(0, 1) - 1)
+	case 'd':
+		s.row = clampRow(arg(0, 1) - 1)
+	case 'H', 'f':
+		s.row, s.col = clampRow(arg(0, 1)-1), clampCol(arg(1, 1)-1)
+	case 'J':
+		switch arg(0, 0) {
+		case 0:
+			s.eraseLine(s.row, col, s.cols)
+			for row := s.row + 1; row < s.rows; row++ {
+				s.cells[row] = blankScreenLine(s.cols)
+			}
+		case 1:
+			s.eraseLine(s.row, 0, col+1)
+			for row := 0; row < s.row; row++ {
+				s.cells[row] = blankScreenLine(s.cols)
+			}
+		default:
+			for row := range s.cells {
+				s.cells[row] = blankScreenLine(s.cols)
+			}
+		}
+	case 'K':
+		switch arg(0, 0) {
+		case 0:
+			s.eraseLine(s.row, col, s.cols)
+		case 1:
+			s.eraseLine(s.row, 0, col+1)
+		default:
+			s.eraseLine(s.row, 0, s.cols)
+		}
+	case 'X':
+		s.eraseLine(s.row, col, min(col+arg(0, 1), s.cols))
+	case 'P':
+		line := s.cells[s.row]
+		n := min(arg(0, 1), s.cols-col)
+		copy(line[col:], line[col+n:])
+		s.eraseLine(s.row, s.cols-n, s.cols)
+	case '@':
+		line := s.cells[s.row]
+		n := min(arg(0, 1), s.cols-col)
+		copy(line[col+n:], line[col:])
+		s.eraseLine(s.row, col, col+n)
+	case 'L':
+		s.scrollDown(s.row, min(arg(0, 1), s.rows-s.row))
+	case 'M':
+		s.scrollUp(s.row, min(arg(0, 1), s.rows-s.row))
+	case 'S':
+		s.scrollUp(0, min(arg(0, 1), s.rows))
+	case 'T':
+		s.scrollDown(0, min(arg(0, 1), s.rows))
+	case 's':
+		s.savedRow, s.savedCol = s.row, s.col
+	case 'u':
+		s.row, s.col = s.savedRow, s.savedCol
+	case 'h', 'l':
+		// Entering or leaving the alternate screen starts from a blank one
+		if private && (arg(0, 0) == 1049 || arg(0, 0) == 47) {
+			for row := range s.cells {
+				s.cells[row] = blankScreenLine(s.cols)
+			}
+			if final == 'h' {
+				s.row, s.col = 0, 0
+			}
+		}
+	}
+}
+
+func (s *TerminalScreen) eraseLine(row, from, to int) {
+	for i := max(from, 0); i < to && i < s.cols; i++ {
+		s.cells[row][i] = ' '
+	}
+}
@@ -0,0 +1,218 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// scriptCheckTimeout bounds each external checker, which only parses
+const scriptCheckTimeout = 30 * time.Second
+
+// syntaxCheckArgs are the arguments that make a shell parse a script
+// without running it; shells that are not listed are not syntax checked
+var syntaxCheckArgs = map[string][]string{
+	"sh":   {"-n"},
+	"bash": {"-n"},
+	"dash": {"-n"},
+	"ksh":  {"-n"},
+	"zsh":  {"-n"},
+	"fish": {"--no-execute"},
+}
+
+// shellcheckDialects lists the shells shellcheck understands
+var shellcheckDialects = map[string]bool{"sh": true, "bash": true, "dash": true, "ksh": true}
+
+var (
+	// Shells report syntax errors as "path: line 3: ...", "path:3: ...",
+	// "path: 3: ..." or "path (line 3): ..."
+	syntaxLinePrefix = regexp.MustCompile(`^:?\s*(?:line\s+)?(\d+):\s*(.*)$`)
+	syntaxLineAny    = regexp.MustCompile(`\bline\s+(\d+)\b`)
+)
+
+// CheckScript runs the pre-flight checks of run_shell_script without
+// running the script: the shell's own syntax check, shellcheck when it is
+// installed and useShellcheck is set, and a scan for blocked command
+// patterns line by line
+func CheckScript(ctx context.Context, script, shell string, useShellcheck bool) (types.ScriptCheckResult, error) {
+	result := types.ScriptCheckResult{Shell: shell, Checks: []string{}, Diagnostics: []types.ScriptDiagnostic{}}
+	name := shellName(shell)
+
+	scriptPath, err := CreateTempScript(script, shell)
+	if err != nil {
+		return result, err
+	}
+	defer CleanupTempFile(scriptPath)
+
+	if args, ok := syntaxCheckArgs[name]; !ok {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("syntax: %s has no syntax check", name))
+	} else if _, err := exec.LookPath(shell); err != nil {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("syntax: %s not found", shell))
+	} else {
+		result.Checks = append(result.Checks, "syntax")
+		result.Diagnostics = append(result.Diagnostics, checkScriptSyntax(ctx, shell, args, scriptPath)...)
+	}
+
+	if useShellcheck {
+		if !shellcheckDialects[name] {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("shellcheck: %s is not supported", name))
+		} else if _, err := exec.LookPath("shellcheck"); err != nil {
+			result.Skipped = append(result.Skipped, "shellcheck: not installed")
+		} else {
+			diagnostics, err := runShellcheck(ctx, name, scriptPath)
+			if err != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("shellcheck: %v", err))
+			} else {
+				result.Checks = append(result.Checks, "shellcheck")
+				result.Diagnostics = append(result.Diagnostics, diagnostics...)
+			}
+		}
+	}
+
+	result.Checks = append(result.Checks, "blocked")
+	result.Diagnostics = append(result.Diagnostics, scanBlockedLines(script)...)
+
+	result.Passed = true
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Severity == "error" {
+			result.Passed = false
+			break
+		}
+	}
+	return result, nil
+}
+
+// checkScriptSyntax parses the script with the shell and turns each line of
+// its error output into a diagnostic
+func checkScriptSyntax(ctx context.Context, shell string, args []string, scriptPath string) []types.ScriptDiagnostic {
+	checkCtx, cancel := context.WithTimeout(ctx, scriptCheckTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(checkCtx, shell, append(append([]string(nil), args...), scriptPath)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		return nil
+	} else if checkCtx.Err() != nil {
+		return []types.ScriptDiagnostic{{Source: "syntax", Severity: "error",
+			Message: fmt.Sprintf("syntax check timed out after %s", scriptCheckTimeout)}}
+	}
+
+	var diagnostics []types.ScriptDiagnostic
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// Drop everything up to the script path so only the location and
+		// message are left
+		if i := strings.LastIndex(line, scriptPath); i >= 0 {
+			line = line[i+len(scriptPath):]
+		}
+		diagnostic := types.ScriptDiagnostic{Source: "syntax", Severity: "error", Message: strings.TrimSpace(line)}
+		if m := syntaxLinePrefix.FindStringSubmatch(line); m != nil {
+			diagnostic.Line, _ = strconv.Atoi(m[1])
+			diagnostic.Message = m[2]
+		} else if m := syntaxLineAny.FindStringSubmatch(line); m != nil {
+			diagnostic.Line, _ = strconv.Atoi(m[1])
+			diagnostic.Message = strings.TrimLeft(strings.TrimSpace(strings.Replace(line, m[0], "", 1)), "():, ")
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	if len(diagnostics) == 0 {
+		diagnostics = append(diagnostics, types.ScriptDiagnostic{Source: "syntax", Severity: "error",
+			Message: fmt.Sprintf("%s rejected the script", shell)})
+	}
+	return diagnostics
+}
+
+// runShellcheck runs shellcheck on the script and returns its findings.
+// shellcheck exits with status 1 when it finds problems, so only output
+// that is not valid JSON counts as a failure.
+func runShellcheck(ctx context.Context, dialect, scriptPath string) ([]types.ScriptDiagnostic, error) {
+	checkCtx, cancel := context.WithTimeout(ctx, scriptCheckTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(checkCtx, "shellcheck", "--format=json", "--shell="+dialect, scriptPath)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+	if checkCtx.Err() != nil {
+		return nil, WithErrorCode(ErrorTimeout, fmt.Errorf("timed out after %s", scriptCheckTimeout))
+	}
+
+	var findings []struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Level   string `json:"level"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("%v: %s", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("unreadable output: %v", err)
+	}
+
+	diagnostics := make([]types.ScriptDiagnostic, 0, len(findings))
+	for _, finding := range findings {
+		diagnostics = append(diagnostics, types.ScriptDiagnostic{
+			Line:     finding.Line,
+			Column:   finding.Column,
+			Source:   "shellcheck",
+			Severity: finding.Level,
+			Code:     fmt.Sprintf("SC%d", finding.Code),
+			Message:  finding.Message,
+		})
+	}
+	return diagnostics, nil
+}
+
+// scanBlockedLines matches each command line of the script against the
+// blocked command patterns, joining lines continued with a backslash. The
+// whole script is matched as well, which catches commands that span lines
+// in other ways.
+func scanBlockedLines(script string) []types.ScriptDiagnostic {
+	patterns := Get().BlockedCommands
+	var diagnostics []types.ScriptDiagnostic
+
+	lines := strings.Split(script, "\n")
+	for i := 0; i < len(lines); i++ {
+		start := i
+		command := strings.TrimRight(lines[i], "\r")
+		for strings.HasSuffix(command, `\`) && i+1 < len(lines) {
+			i++
+			command = strings.TrimSuffix(command, `\`) + strings.TrimRight(lines[i], "\r")
+		}
+		if strings.TrimSpace(command) == "" {
+			continue
+		}
+		if pattern, blocked := MatchCommandPattern(command, patterns); blocked {
+			diagnostics = append(diagnostics, blockedDiagnostic(start+1, pattern))
+		}
+	}
+
+	if len(diagnostics) == 0 {
+		if pattern, blocked := MatchCommandPattern(script, patterns); blocked {
+			diagnostics = append(diagnostics, blockedDiagnostic(0, pattern))
+		}
+	}
+	return diagnostics
+}
+
+func blockedDiagnostic(line int, pattern string) types.ScriptDiagnostic {
+	message := "command is nested too deeply to check"
+	if pattern != "" {
+		message = fmt.Sprintf("command matches blocked pattern %q", pattern)
+	}
+	return types.ScriptDiagnostic{Line: line, Source: "blocked", Severity: "error", Message: message}
+}
@@ -0,0 +1,324 @@
+package common
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// SecretKeyEnv names the environment variable holding a passphrase for the
+// secrets file; without it a random key is kept in a key file next to it
+const SecretKeyEnv = "JARVIS_SECRETS_KEY"
+
+// minSecretLength is the shortest value set_secret accepts; shorter values
+// would be redacted wherever the same few characters appear
+const minSecretLength = 4
+
+const (
+	secretsMagic      = "JARVISSEC"
+	secretsVersion    = 1
+	secretsIterations = 600000
+)
+
+var (
+	secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	// secretReferencePattern matches {{secret:NAME}} references
+	secretReferencePattern = regexp.MustCompile(`\{\{\s*secret:([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+)
+
+type storedSecret struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	secretsMutex  sync.Mutex
+	secretsLoaded bool
+	secrets       map[string]storedSecret
+	// secretsRedactor replaces every stored value; nil when there are none
+	secretsRedactor *strings.Replacer
+)
+
+// secretsPath returns the encrypted secrets file, stored next to the
+// config file
+func secretsPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-secrets.enc")
+}
+
+// secretsKeyPath returns the file holding the random key of the secrets
+// file when SecretKeyEnv is not set
+func secretsKeyPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-secrets.key")
+}
+
+// secretsKey returns the secret the secrets file key is derived from,
+// creating a random key file on first use when create is set
+func secretsKey(create bool) ([]byte, error) {
+	if passphrase := os.Getenv(SecretKeyEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	key, err := os.ReadFile(secretsKeyPath())
+	if err == nil {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("secrets key file %s is empty", secretsKeyPath())
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read secrets key: %v", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to create secrets key: %v", err)
+	}
+	if err := WriteFileAtomic(secretsKeyPath(), key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save secrets key: %v", err)
+	}
+	return key, nil
+}
+
+// loadSecrets reads and decrypts the secrets file on first use; callers
+// hold secretsMutex
+func loadSecrets() error {
+	if secretsLoaded {
+		return nil
+	}
+	data, err := os.ReadFile(secretsPath())
+	if os.IsNotExist(err) {
+		secrets, secretsLoaded = make(map[string]storedSecret), true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read secrets: %v", err)
+	}
+
+	// The file is the magic, a version byte, a salt, a nonce and the
+	// sealed JSON object of secrets
+	header := len(secretsMagic) + 1 + encryptionSaltSize + 12
+	if len(data) < header || !bytes.HasPrefix(data, []byte(secretsMagic)) || data[len(secretsMagic)] != secretsVersion {
+		return fmt.Errorf("secrets file %s is not valid", secretsPath())
+	}
+	key, err := secretsKey(false)
+	if err != nil {
+		return err
+	}
+	salt := data[len(secretsMagic)+1 : len(secretsMagic)+1+encryptionSaltSize]
+	nonce := data[len(secretsMagic)+1+encryptionSaltSize : header]
+	aead, err := newFileAEAD(key, salt, secretsIterations)
+	if err != nil {
+		return err
+	}
+	plain, err := aead.Open(nil, nonce, data[header:], data[:header])
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secrets: wrong %s or key file, or corrupted file", SecretKeyEnv)
+	}
+
+	loaded := make(map[string]storedSecret)
+	if err := json.Unmarshal(plain, &loaded); err != nil {
+		return fmt.Errorf("secrets file %s is not valid: %v", secretsPath(), err)
+	}
+	secrets, secretsLoaded = loaded, true
+	updateSecretsRedactor()
+	return nil
+}
+
+// saveSecrets encrypts the secrets into the secrets file; callers hold
+// secretsMutex
+func saveSecrets(updated map[string]storedSecret) error {
+	plain, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+	key, err := secretsKey(true)
+	if err != nil {
+		return err
+	}
+
+	header := append([]byte(secretsMagic), secretsVersion)
+	salt := make([]byte, encryptionSaltSize)
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	header = append(append(header, salt...), nonce...)
+	aead, err := newFileAEAD(key, salt, secretsIterations)
+	if err != nil {
+		return err
+	}
+	data := aead.Seal(header, nonce, plain, header)
+	if err := WriteFileAtomic(secretsPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to save secrets: %v", err)
+	}
+
+	secrets = updated
+	updateSecretsRedactor()
+	return nil
+}
+
+// updateSecretsRedactor rebuilds the replacer used by RedactSecrets,
+// replacing longer values first so a value containing another is hidden
+// whole; callers hold secretsMutex
+func updateSecretsRedactor() {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(secrets[names[i]].Value) > len(secrets[names[j]].Value) })
+
+	var pairs []string
+	for _, name := range names {
+		pairs = append(pairs, secrets[name].Value, "{{secret:"+name+"}}")
+	}
+	secretsRedactor = nil
+	if len(pairs) > 0 {
+		secretsRedactor = strings.NewReplacer(pairs...)
+	}
+}
+
+// SetSecret stores a secret, replacing one with the same name
+func SetSecret(name, value string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '.', '_' and '-', starting with a letter or '_'", name)
+	}
+	if len(value) < minSecretLength {
+		return fmt.Errorf("secret values must be at least %d characters so they can be redacted from output", minSecretLength)
+	}
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if err := loadSecrets(); err != nil {
+		return err
+	}
+	updated := make(map[string]storedSecret, len(secrets)+1)
+	for existing, secret := range secrets {
+		updated[existing] = secret
+	}
+	updated[name] = storedSecret{Value: value, UpdatedAt: time.Now()}
+	return saveSecrets(updated)
+}
+
+// DeleteSecret removes a stored secret
+func DeleteSecret(name string) error {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if err := loadSecrets(); err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("no secret named %s", name)
+	}
+	updated := make(map[string]storedSecret, len(secrets))
+	for existing, secret := range secrets {
+		if existing != name {
+			updated[existing] = secret
+		}
+	}
+	return saveSecrets(updated)
+}
+
+// ListSecrets describes the stored secrets ordered by name, without their
+// values
+func ListSecrets() ([]types.SecretInfo, error) {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if err := loadSecrets(); err != nil {
+		return nil, err
+	}
+	infos := make([]types.SecretInfo, 0, len(secrets))
+	for name, secret := range secrets {
+		infos = append(infos, types.SecretInfo{Name: name, Reference: "{{secret:" + name + "}}", UpdatedAt: secret.UpdatedAt})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// ExpandSecrets replaces {{secret:NAME}} references in text with the
+// stored values, each passed through quote when it is not nil. Referencing
+// a secret that does not exist is an error.
+func ExpandSecrets(text string, quote func(string) (string, error)) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	var expandErr error
+	expanded := secretReferencePattern.ReplaceAllStringFunc(text, func(reference string) string {
+		if expandErr != nil {
+			return reference
+		}
+		if expandErr = loadSecrets(); expandErr != nil {
+			return reference
+		}
+		name := secretReferencePattern.FindStringSubmatch(reference)[1]
+		secret, ok := secrets[name]
+		if !ok {
+			expandErr = fmt.Errorf("no secret named %s", name)
+			return reference
+		}
+		if quote == nil {
+			return secret.Value
+		}
+		quoted, err := quote(secret.Value)
+		if err != nil {
+			expandErr = fmt.Errorf("secret %s: %v", name, err)
+			return reference
+		}
+		return quoted
+	})
+	return expanded, expandErr
+}
+
+// LoadSecrets reads the secrets file, so that their values are redacted
+// before any secret is used
+func LoadSecrets() error {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	return loadSecrets()
+}
+
+// RedactSecrets replaces every stored secret value in text with its
+// {{secret:NAME}} reference. Only secrets loaded with LoadSecrets or used
+// since are known.
+func RedactSecrets(text string) string {
+	secretsMutex.Lock()
+	redactor := secretsRedactor
+	secretsMutex.Unlock()
+	if redactor == nil {
+		return text
+	}
+	return redactor.Replace(text)
+}
+
+// redactingWriter passes writes on with stored secrets redacted
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, RedactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// NewRedactingWriter returns a writer redacting stored secrets from what is
+// written to w, for logs written a line at a time
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
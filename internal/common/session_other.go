@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package common
+
+import "os/exec"
+
+func configureSessionProcess(cmd *exec.Cmd) {}
+
+// stopSessionProcess kills the session process; there is no graceful
+// termination signal on this platform
+func stopSessionProcess(cmd *exec.Cmd, force bool) error {
+	return cmd.Process.Kill()
+}
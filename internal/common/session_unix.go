@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package common
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureSessionProcess starts a session in its own process group so it
+// can be stopped together with its children
+func configureSessionProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// stopSessionProcess sends SIGTERM, or SIGKILL when force is set, to the
+// process group of a session
+func stopSessionProcess(cmd *exec.Cmd, force bool) error {
+	signal := syscall.SIGTERM
+	if force {
+		signal = syscall.SIGKILL
+	}
+	err := syscall.Kill(-cmd.Process.Pid, signal)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
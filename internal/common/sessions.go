@@ -0,0 +1,528 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+const (
+	// maxRunningSessions bounds the number of background commands
+	maxRunningSessions = 20
+	// sessionBufferLimit is the unread output kept per stream; older output
+	// is dropped and reported as dropped_bytes
+	sessionBufferLimit = 1 << 20
+	// finishedSessionTTL is how long a finished session stays readable
+	finishedSessionTTL = time.Hour
+)
+
+// sessionStream buffers one output stream of a session. Offsets are
+// absolute positions in everything the stream has produced.
+type sessionStream struct {
+	data    []byte
+	start   int64
+	read    int64
+	dropped int64
+}
+
+func (s *sessionStream) unread() int64 {
+	return s.start + int64(len(s.data)) - s.read
+}
+
+// take returns up to max unread bytes and advances the read offset
+func (s *sessionStream) take(max int) string {
+	from := int(s.read - s.start)
+	to := min(len(s.data), from+max)
+	s.read = s.start + int64(to)
+
+	// Forget output that has been read
+	chunk := string(s.data[from:to])
+	s.data = s.data[to:]
+	s.start = s.read
+	return chunk
+}
+
+type commandSession struct {
+	mu      sync.Mutex
+	info    types.CommandSession
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  sessionStream
+	stderr  sessionStream
+	changed chan struct{}
+	done    chan struct{}
+	history *OutputBuffer
+
+	// Set for sessions running in a pseudo-terminal, whose output all
+	// arrives on stdout
+	pty    *os.File
+	screen *TerminalScreen
+
+	// input is what earlier writes left after the last line end, which a
+	// shell reading stdin has not acted on yet. inputMu guards it and
+	// serializes writes.
+	inputMu sync.Mutex
+	input   string
+}
+
+// SessionOptions controls how StartSession runs a command
+type SessionOptions struct {
+	// PTY runs the command in a pseudo-terminal of Rows x Cols so that
+	// interactive programs behave as they would in a terminal
+	PTY  bool
+	Rows int
+	Cols int
+}
+
+// notify wakes readers waiting for output; callers hold s.mu
+func (s *commandSession) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// snapshot returns the session description; callers hold s.mu
+func (s *commandSession) snapshot() types.CommandSession {
+	info := s.info
+	end := time.Now()
+	if info.EndedAt != nil {
+		end = *info.EndedAt
+	}
+	info.Duration = end.Sub(info.StartedAt).Round(time.Millisecond).String()
+	return info
+}
+
+// sessionWriter appends process output to a session stream
+type sessionWriter struct {
+	session *commandSession
+	stream  *sessionStream
+}
+
+func (w sessionWriter) Write(p []byte) (int, error) {
+	w.session.mu.Lock()
+	defer w.session.mu.Unlock()
+
+	if w.session.screen != nil {
+		w.session.screen.Write(p)
+	}
+
+	stream := w.stream
+	stream.data = append(stream.data, p...)
+	if excess := len(stream.data) - sessionBufferLimit; excess > 0 {
+		stream.data = stream.data[excess:]
+		stream.start += int64(excess)
+		if stream.read < stream.start {
+			stream.dropped += stream.start - stream.read
+			stream.read = stream.start
+		}
+	}
+	w.session.history.Write(p)
+	w.session.notify()
+	return len(p), nil
+}
+
+var (
+	sessionsMutex  sync.Mutex
+	sessions       = make(map[string]*commandSession)
+	sessionCounter int
+)
+
+// StartSession starts command in the background and returns its session.
+// Output is buffered until read with ReadSessionOutput.
+func StartSession(command, shell, workingDir string, opts SessionOptions) (types.CommandSession, error) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	running := 0
+	for id, session := range sessions {
+		session.mu.Lock()
+		if session.info.EndedAt == nil {
+			running++
+		} else if time.Since(*session.info.EndedAt) > finishedSessionTTL {
+			delete(sessions, id)
+		}
+		session.mu.Unlock()
+	}
+	if running >= maxRunningSessions {
+		return types.CommandSession{}, fmt.Errorf("too many running sessions (limit %d); terminate one first", maxRunningSessions)
+	}
+
+	cmd := exec.Command(shell, ShellCommandArgs(shell, command)...)
+	cmd.Dir = workingDir
+	if _, err := SandboxCommand(cmd); err != nil {
+		return types.CommandSession{}, err
+	}
+
+	session := &commandSession{
+		cmd:     cmd,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+		history: NewOutputBuffer(maxHistoryOutputBytes),
+	}
+
+	// Output read from a pseudo-terminal is copied by this goroutine; the
+	// session only counts as finished once it has drained
+	ptyDrained := make(chan struct{})
+	if opts.PTY {
+		if opts.Rows <= 0 || opts.Cols <= 0 {
+			return types.CommandSession{}, fmt.Errorf("invalid terminal size %dx%d", opts.Rows, opts.Cols)
+		}
+		cmd.Env = append(os.Environ(), "TERM=xterm")
+		session.screen = NewTerminalScreen(opts.Rows, opts.Cols)
+
+		master, err := startWithPTY(cmd, opts.Rows, opts.Cols)
+		if err != nil {
+			return types.CommandSession{}, err
+		}
+		session.pty, session.stdin = master, master
+		go func() {
+			defer close(ptyDrained)
+			// Reading fails with EIO once every process has closed the terminal
+			io.Copy(sessionWriter{session, &session.stdout}, master)
+		}()
+	} else {
+		close(ptyDrained)
+		configureSessionProcess(cmd)
+		cmd.Stdout = sessionWriter{session, &session.stdout}
+		cmd.Stderr = sessionWriter{session, &session.stderr}
+
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return types.CommandSession{}, err
+		}
+		session.stdin = stdin
+
+		if err := cmd.Start(); err != nil {
+			return types.CommandSession{}, err
+		}
+	}
+
+	sessionCounter++
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.info = types.CommandSession{
+		ID:        fmt.Sprintf("session-%d", sessionCounter),
+		Command:   command,
+		PID:       cmd.Process.Pid,
+		State:     "running",
+		StartedAt: time.Now(),
+	}
+	sessions[session.info.ID] = session
+
+	go func() {
+		err := cmd.Wait()
+		if session.pty != nil {
+			// Background children may keep the terminal open; do not wait
+			// for them
+			select {
+			case <-ptyDrained:
+			case <-time.After(time.Second):
+			}
+			session.pty.Close()
+		}
+
+		session.mu.Lock()
+		now := time.Now()
+		session.info.EndedAt = &now
+		exitCode := cmd.ProcessState.ExitCode()
+		session.info.ExitCode = &exitCode
+		if session.info.State == "running" {
+			session.info.State = "exited"
+		}
+		if err != nil {
+			session.info.Error = err.Error()
+		}
+		entry := types.CommandHistoryEntry{
+			Tool:       "start_command",
+			Command:    command,
+			Shell:      shell,
+			WorkingDir: workingDir,
+			StartedAt:  session.info.StartedAt,
+			DurationMs: now.Sub(session.info.StartedAt).Milliseconds(),
+			ExitCode:   exitCode,
+			Error:      session.info.Error,
+		}
+		entry.Output, entry.Truncated = session.history.String(), session.history.Truncated()
+		session.notify()
+		session.mu.Unlock()
+
+		RecordCommand(entry)
+		close(session.done)
+	}()
+
+	return session.snapshot(), nil
+}
+
+func getSession(id string) (*commandSession, error) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	session, ok := sessions[id]
+	if !ok {
+		return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("session not found: %s", id))
+	}
+	return session, nil
+}
+
+// ReadSessionOutput returns the output produced since the previous read,
+// at most maxBytes per stream. When nothing is available it waits up to
+// wait for new output or for the process to exit.
+func ReadSessionOutput(id string, wait time.Duration, maxBytes int) (types.SessionOutput, error) {
+	session, err := getSession(id)
+	if err != nil {
+		return types.SessionOutput{}, err
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	session.mu.Lock()
+	for session.stdout.unread() == 0 && session.stderr.unread() == 0 && session.info.State == "running" && wait > 0 {
+		changed := session.changed
+		session.mu.Unlock()
+		select {
+		case <-changed:
+		case <-deadline.C:
+			wait = 0
+		}
+		session.mu.Lock()
+	}
+	defer session.mu.Unlock()
+
+	output := types.SessionOutput{
+		CommandSession: session.snapshot(),
+		Stdout:         session.stdout.take(maxBytes),
+		Stderr:         session.stderr.take(maxBytes),
+		StdoutOffset:   session.stdout.read,
+		StderrOffset:   session.stderr.read,
+		DroppedBytes:   session.stdout.dropped + session.stderr.dropped,
+	}
+	output.More = session.stdout.unread() > 0 || session.stderr.unread() > 0
+	session.stdout.dropped, session.stderr.dropped = 0, 0
+	return output, nil
+}
+
+// SessionInputLines returns the non-blank lines of input written to a
+// session, which a shell session would run as commands
+func SessionInputLines(input string) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// SessionInputScript returns what a shell in the session would have read
+// once input is written: the input earlier writes left unterminated,
+// followed by input. A pseudo-terminal reads a carriage return as a line
+// end, so there it becomes a newline. Checking this rather than input
+// alone catches a command split across writes or continued with a
+// backslash.
+func SessionInputScript(id, input string) (string, error) {
+	session, err := getSession(id)
+	if err != nil {
+		return "", err
+	}
+	session.inputMu.Lock()
+	defer session.inputMu.Unlock()
+	return session.inputScript(input), nil
+}
+
+// inputScript is SessionInputScript; callers hold s.inputMu
+func (s *commandSession) inputScript(input string) string {
+	if s.pty != nil {
+		input = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(input)
+	}
+	return s.input + input
+}
+
+// unterminatedInput returns the end of script a shell has not acted on
+// yet: everything after the last line end not escaped by a backslash
+func unterminatedInput(script string) string {
+	for i := len(script) - 1; i >= 0; i-- {
+		if script[i] != '\n' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && script[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			return script[i+1:]
+		}
+	}
+	return script
+}
+
+// WriteSessionInput writes input to the standard input of a running
+// session, closing it afterwards when closeStdin is set. checked is the
+// SessionInputScript the caller checked; the write is refused when
+// another write changed it in the meantime.
+func WriteSessionInput(id, input, checked string, closeStdin bool) error {
+	session, err := getSession(id)
+	if err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	running := session.info.State == "running"
+	session.mu.Unlock()
+	if !running {
+		return fmt.Errorf("session %s is no longer running", id)
+	}
+
+	session.inputMu.Lock()
+	defer session.inputMu.Unlock()
+	if input != "" {
+		script := session.inputScript(input)
+		if script != checked {
+			return fmt.Errorf("session %s received other input since this input was checked; send it again", id)
+		}
+		if _, err := io.WriteString(session.stdin, input); err != nil {
+			return fmt.Errorf("failed to write to session %s: %w", id, err)
+		}
+		session.input = unterminatedInput(script)
+		now := time.Now()
+		for _, line := range SessionInputLines(script[:len(script)-len(session.input)]) {
+			RecordCommand(types.CommandHistoryEntry{
+				Tool:      "write_session_input",
+				Command:   line,
+				StartedAt: now,
+			})
+		}
+	}
+	if closeStdin {
+		if session.pty != nil {
+			// End of input on a terminal is Ctrl-D
+			_, err := session.pty.Write([]byte{4})
+			return err
+		}
+		return session.stdin.Close()
+	}
+	return nil
+}
+
+// ReadSessionScreen returns the current screen of a pseudo-terminal
+// session. With wait set it first waits up to that long for new output.
+func ReadSessionScreen(id string, wait time.Duration) (types.SessionScreen, error) {
+	session, err := getSession(id)
+	if err != nil {
+		return types.SessionScreen{}, err
+	}
+
+	session.mu.Lock()
+	if session.screen == nil {
+		session.mu.Unlock()
+		return types.SessionScreen{}, fmt.Errorf("session %s was not started with a pseudo-terminal", id)
+	}
+	if wait > 0 && session.info.State == "running" {
+		changed := session.changed
+		session.mu.Unlock()
+		select {
+		case <-changed:
+			// Let a burst of output finish drawing
+			time.Sleep(50 * time.Millisecond)
+		case <-time.After(wait):
+		}
+		session.mu.Lock()
+	}
+	defer session.mu.Unlock()
+
+	rows, cols := session.screen.Size()
+	cursorRow, cursorCol := session.screen.Cursor()
+	return types.SessionScreen{
+		CommandSession: session.snapshot(),
+		Rows:           rows,
+		Cols:           cols,
+		CursorRow:      cursorRow + 1,
+		CursorCol:      cursorCol + 1,
+		Screen:         session.screen.Render(),
+	}, nil
+}
+
+// ResizeSession changes the terminal size of a pseudo-terminal session
+func ResizeSession(id string, rows, cols int) error {
+	session, err := getSession(id)
+	if err != nil {
+		return err
+	}
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", rows, cols)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.screen == nil {
+		return fmt.Errorf("session %s was not started with a pseudo-terminal", id)
+	}
+	if session.info.State != "running" {
+		return fmt.Errorf("session %s is no longer running", id)
+	}
+	if err := resizePTY(session.pty, rows, cols); err != nil {
+		return err
+	}
+	session.screen.Resize(rows, cols)
+	return nil
+}
+
+// TerminateSession stops a running session and its child processes. The
+// process group is asked to exit and killed if it is still running after
+// grace; force kills it straight away.
+func TerminateSession(id string, force bool, grace time.Duration) (types.CommandSession, error) {
+	session, err := getSession(id)
+	if err != nil {
+		return types.CommandSession{}, err
+	}
+
+	session.mu.Lock()
+	if session.info.State != "running" {
+		defer session.mu.Unlock()
+		return session.snapshot(), nil
+	}
+	session.info.State = "terminated"
+	session.mu.Unlock()
+
+	if err := stopSessionProcess(session.cmd, force); err != nil {
+		return types.CommandSession{}, fmt.Errorf("failed to stop session %s: %w", id, err)
+	}
+
+	select {
+	case <-session.done:
+	case <-time.After(grace):
+		if !force {
+			stopSessionProcess(session.cmd, true)
+		}
+		select {
+		case <-session.done:
+		case <-time.After(5 * time.Second):
+			return types.CommandSession{}, fmt.Errorf("session %s did not exit after being killed", id)
+		}
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.snapshot(), nil
+}
+
+// ListSessions returns every known session, oldest first
+func ListSessions() []types.CommandSession {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	list := make([]types.CommandSession, 0, len(sessions))
+	for _, session := range sessions {
+		session.mu.Lock()
+		list = append(list, session.snapshot())
+		session.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
@@ -0,0 +1,1127 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Commands are matched against command patterns (such as blockedCommands)
+// after parsing them the way a POSIX shell would: quotes and escapes are
+// removed, simple variables, aliases and $'...' strings are expanded, and
+// command substitutions, function bodies, "sh -c" and "eval" scripts,
+// find -exec commands and statically decodable pipelines (echo ... |
+// base64 -d | sh) are parsed as commands of their own. Each simple command
+// is then compared by its executable and arguments, so "rm  -rf" and
+// "r'm' -fr" match "rm -rf" while "echo add" no longer matches "dd". An
+// executable given by an expansion whose value cannot be worked out, such
+// as a variable the script never sets, could be any command and is
+// compared by its arguments alone.
+
+// maxShellParseDepth bounds how deeply nested scripts are parsed; a command
+// nesting deeper than this is treated as matching every pattern
+const maxShellParseDepth = 8
+
+// shellStage is one command of a pipeline: its words after quote removal
+// and expansion, and its input when a here-document or here-string gives it
+type shellStage struct {
+	words []string
+	// unknown marks the words holding an expansion that could not be
+	// resolved statically
+	unknown []bool
+	stdin   *string
+}
+
+// unknownAt reports whether word i of the stage holds an expansion that
+// could not be resolved
+func (s *shellStage) unknownAt(i int) bool {
+	return i >= 0 && i < len(s.unknown) && s.unknown[i]
+}
+
+type shellPipeline []*shellStage
+
+// parsedShellCommand is the result of parseShellCommand
+type parsedShellCommand struct {
+	// commands holds the words of every simple command found, including
+	// those of substitutions and nested scripts
+	commands [][]string
+	// anyExecutable marks the commands whose executable comes from an
+	// expansion that could not be resolved, so it may be any command
+	anyExecutable []bool
+	// scripts holds the nested scripts that were decoded or extracted
+	scripts []string
+	// tooDeep is set when the nesting exceeded maxShellParseDepth
+	tooDeep bool
+}
+
+type shellParser struct {
+	src      []rune
+	pos      int
+	depth    int
+	vars     map[string]string
+	aliases  map[string]string
+	result   *parsedShellCommand
+	heredocs []pendingHeredoc
+}
+
+type pendingHeredoc struct {
+	delimiter string
+	stripTabs bool
+	body      *string
+}
+
+// shellWrapper describes a command that runs the command given in its
+// arguments, such as sudo or timeout
+type shellWrapper struct {
+	// valueOptions take the next word as their value
+	valueOptions []string
+	// positional is the number of arguments before the wrapped command
+	positional int
+}
+
+var shellWrappers = map[string]shellWrapper{
+	"sudo":     {valueOptions: []string{"-u", "-g", "-C", "-h", "-p", "-r", "-t", "-U", "-D"}},
+	"doas":     {valueOptions: []string{"-u", "-C"}},
+	"runuser":  {valueOptions: []string{"-u", "-g", "-G"}},
+	"env":      {valueOptions: []string{"-u", "-C", "--unset", "--chdir"}},
+	"nice":     {valueOptions: []string{"-n", "--adjustment"}},
+	"ionice":   {valueOptions: []string{"-c", "-n", "-p", "-P", "-u"}},
+	"timeout":  {valueOptions: []string{"-s", "-k", "--signal", "--kill-after"}, positional: 1},
+	"chroot":   {positional: 1},
+	"flock":    {valueOptions: []string{"-w", "-E", "--timeout"}, positional: 1},
+	"xargs":    {valueOptions: []string{"-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s"}},
+	"time":     {valueOptions: []string{"-f", "-o"}},
+	"exec":     {valueOptions: []string{"-a"}},
+	"strace":   {valueOptions: []string{"-e", "-o", "-p", "-s", "-u"}},
+	"stdbuf":   {valueOptions: []string{"-i", "-o", "-e"}},
+	"nohup":    {},
+	"setsid":   {},
+	"command":  {},
+	"builtin":  {},
+	"busybox":  {},
+	"unbuffer": {},
+}
+
+// shellKeywords may precede the executable of a simple command
+var shellKeywords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true, "elif": true,
+	"do": true, "while": true, "until": true, "coproc": true,
+}
+
+// shellSpecialParameters are the parameters such as $1 and $? that the
+// script cannot set
+const shellSpecialParameters = "0123456789@*#?$!-"
+
+// shellInterpreters run a script given with -c, or read one from standard
+// input without arguments
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "fish": true,
+}
+
+// parseShellCommand parses a command or script into its simple commands
+func parseShellCommand(command string) *parsedShellCommand {
+	p := &shellParser{
+		src:     []rune(command),
+		vars:    map[string]string{"IFS": " \t\n"},
+		aliases: make(map[string]string),
+		result:  &parsedShellCommand{},
+	}
+	p.parseList(0)
+	return p.result
+}
+
+// parseScript parses a nested script with the variables and aliases known
+// so far
+func (p *shellParser) parseScript(script string) {
+	if p.depth >= maxShellParseDepth {
+		p.result.tooDeep = true
+		return
+	}
+	p.result.scripts = append(p.result.scripts, script)
+	nested := &shellParser{src: []rune(script), depth: p.depth + 1, vars: p.vars, aliases: p.aliases, result: p.result}
+	nested.parseList(0)
+}
+
+func (p *shellParser) peek(offset int) rune {
+	if p.pos+offset < len(p.src) {
+		return p.src[p.pos+offset]
+	}
+	return 0
+}
+
+// shellList collects the pipelines of a command list while it is parsed
+type shellList struct {
+	p         *shellParser
+	pipelines []shellPipeline
+	pipeline  shellPipeline
+	stage     *shellStage
+	word      strings.Builder
+	inWord    bool
+	// unknown is set when the current word holds an expansion that could
+	// not be resolved
+	unknown bool
+	parens  int
+}
+
+func (l *shellList) endWord() {
+	if l.inWord {
+		l.stage.words = append(l.stage.words, l.word.String())
+		l.stage.unknown = append(l.stage.unknown, l.unknown)
+		l.word.Reset()
+		l.inWord = false
+		l.unknown = false
+	}
+}
+
+func (l *shellList) endStage() {
+	l.endWord()
+	if len(l.stage.words) > 0 || l.stage.stdin != nil {
+		l.pipeline = append(l.pipeline, l.stage)
+		l.p.finishStage(l.stage)
+	}
+	l.stage = &shellStage{}
+}
+
+func (l *shellList) endPipeline() {
+	l.endStage()
+	if len(l.pipeline) > 0 {
+		l.pipelines = append(l.pipelines, l.pipeline)
+		l.p.finishPipeline(l.pipeline)
+	}
+	l.pipeline = nil
+}
+
+func (l *shellList) appendLiteral(text string) {
+	l.word.WriteString(text)
+	l.inWord = true
+}
+
+// appendFields appends the value of an unquoted expansion, which the shell
+// splits into words at whitespace. An unknown value marks every word it
+// ends up in.
+func (l *shellList) appendFields(value string, unknown bool) {
+	if value == "" {
+		if unknown {
+			l.inWord, l.unknown = true, true
+		}
+		return
+	}
+	if strings.IndexFunc(value[:1], unicode.IsSpace) == 0 {
+		l.endWord()
+	}
+	for i, field := range strings.Fields(value) {
+		if i > 0 {
+			l.endWord()
+		}
+		l.appendLiteral(field)
+		l.unknown = l.unknown || unknown
+	}
+	if strings.LastIndexFunc(value, unicode.IsSpace) == len(value)-1 {
+		l.endWord()
+	}
+}
+
+// parseList parses commands up to term, or the end of the source when term
+// is 0, and returns their pipelines
+func (p *shellParser) parseList(term rune) []shellPipeline {
+	l := &shellList{p: p, stage: &shellStage{}}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if term != 0 && c == term && (term != ')' || l.parens == 0) {
+			p.pos++
+			break
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.endWord()
+			p.pos++
+		case c == '\n':
+			p.pos++
+			p.readHeredocs()
+			l.endPipeline()
+		case c == '&' && p.peek(1) == '>':
+			l.endWord()
+			p.redirect(l)
+		case c == ';' || c == '&':
+			l.endPipeline()
+			p.pos++
+			if p.peek(0) == c {
+				p.pos++
+			}
+		case c == '|':
+			p.pos++
+			if p.peek(0) == '|' {
+				p.pos++
+				l.endPipeline()
+			} else {
+				if p.peek(0) == '&' {
+					p.pos++
+				}
+				l.endStage()
+			}
+		case c == '(' || c == ')':
+			if c == '(' {
+				l.parens++
+			} else if l.parens > 0 {
+				l.parens--
+			}
+			l.endPipeline()
+			p.pos++
+		case c == '<' || c == '>':
+			// A number right before the operator is a file descriptor
+			if l.inWord && isDigits(l.word.String()) {
+				l.word.Reset()
+				l.inWord = false
+			}
+			l.endWord()
+			p.redirect(l)
+		case c == '#' && !l.inWord:
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			l.readWordPart()
+		}
+	}
+	l.endPipeline()
+	return l.pipelines
+}
+
+// readWordPart reads the next character, quoted string or expansion of a
+// word
+func (l *shellList) readWordPart() {
+	p := l.p
+	switch c := p.src[p.pos]; c {
+	case '\\':
+		p.pos++
+		if p.pos < len(p.src) {
+			if p.src[p.pos] != '\n' {
+				l.appendLiteral(string(p.src[p.pos]))
+			}
+			p.pos++
+		}
+	case '\'':
+		end := p.pos + 1
+		for end < len(p.src) && p.src[end] != '\'' {
+			end++
+		}
+		l.appendLiteral(string(p.src[p.pos+1 : end]))
+		p.pos = end + 1
+	case '"':
+		p.pos++
+		l.inWord = true
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			ch := p.src[p.pos]
+			switch {
+			case ch == '\\' && strings.ContainsRune("\"\\$`\n", p.peek(1)):
+				if p.peek(1) != '\n' {
+					l.word.WriteRune(p.peek(1))
+				}
+				p.pos += 2
+			case ch == '$' || ch == '`':
+				value, _, resolved := p.readExpansion()
+				l.word.WriteString(value)
+				l.unknown = l.unknown || !resolved
+			default:
+				l.word.WriteRune(ch)
+				p.pos++
+			}
+		}
+		p.pos++
+	case '$', '`':
+		if value, quoted, resolved := p.readExpansion(); quoted {
+			l.appendLiteral(value)
+			l.unknown = l.unknown || !resolved
+		} else {
+			l.appendFields(value, !resolved)
+		}
+	default:
+		l.appendLiteral(string(c))
+		p.pos++
+	}
+}
+
+// readExpansion reads a $ or backtick expansion and returns its value;
+// quoted reports that the value is not split into words, and resolved that
+// it was worked out statically rather than left as its text
+func (p *shellParser) readExpansion() (value string, quoted, resolved bool) {
+	if p.src[p.pos] == '`' {
+		p.pos++
+		output, ok := p.substitute('`')
+		return output, !ok, ok
+	}
+
+	next := p.peek(1)
+	switch {
+	case next == '\'':
+		p.pos += 2
+		start := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] != '\'' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		value := decodeShellEscapes(string(p.src[start:min(p.pos, len(p.src))]))
+		p.pos++
+		return value, true, true
+	case next == '(' && p.peek(2) == '(':
+		start := p.pos
+		p.pos += 3
+		for p.pos < len(p.src) && (p.src[p.pos] != ')' || p.peek(1) != ')') {
+			p.pos++
+		}
+		p.pos = min(p.pos+2, len(p.src))
+		return string(p.src[start:p.pos]), true, true
+	case next == '(':
+		p.pos += 2
+		output, ok := p.substitute(')')
+		return output, !ok, ok
+	case next == '{':
+		end, braces := p.pos+2, 0
+		for end < len(p.src) && (p.src[end] != '}' || braces > 0) {
+			switch p.src[end] {
+			case '{':
+				braces++
+			case '}':
+				braces--
+			}
+			end++
+		}
+		if end == len(p.src) {
+			p.pos++
+			return "$", true, true
+		}
+		expression := string(p.src[p.pos+2 : end])
+		p.pos = end + 1
+		return p.expandParameter(expression)
+	case next == '_' || unicode.IsLetter(next):
+		end := p.pos + 1
+		for end < len(p.src) && (p.src[end] == '_' || unicode.IsLetter(p.src[end]) || unicode.IsDigit(p.src[end])) {
+			end++
+		}
+		name := string(p.src[p.pos+1 : end])
+		p.pos = end
+		if value, ok := p.vars[name]; ok {
+			return value, false, true
+		}
+		return "$" + name, true, false
+	case next != 0 && strings.ContainsRune(shellSpecialParameters, next):
+		p.pos += 2
+		return "$" + string(next), true, false
+	default:
+		p.pos++
+		return "$", true, true
+	}
+}
+
+// expandParameter expands the inside of ${...}: a variable, optionally
+// followed by a default (- and :-), an assigned default (= and :=) or an
+// alternative (+ and :+) word. A variable the script does not set may still
+// come from the environment, so it and any other form are not resolved;
+// the default or alternative word is returned as the likely value.
+func (p *shellParser) expandParameter(expression string) (value string, quoted, resolved bool) {
+	name := expression
+	if i := strings.IndexFunc(expression, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}); i >= 0 {
+		name = expression[:i]
+	}
+	operator := strings.TrimPrefix(expression[len(name):], ":")
+	colon := len(operator) < len(expression)-len(name)
+	current, known := p.vars[name]
+
+	switch {
+	case name == "":
+		return "${" + expression + "}", true, false
+	case operator == "" && !colon:
+		if known {
+			return current, false, true
+		}
+		return "${" + expression + "}", true, false
+	case operator == "" || !strings.ContainsRune("-=+", rune(operator[0])):
+		return "${" + expression + "}", true, false
+	}
+
+	word, wordResolved := p.expandWord(operator[1:])
+	set := known && (!colon || current != "")
+	switch {
+	case !known:
+		return word, false, false
+	case operator[0] == '+' && set, operator[0] != '+' && !set:
+		if operator[0] == '=' {
+			p.vars[name] = word
+		}
+		return word, false, wordResolved
+	case operator[0] == '+':
+		return "", false, true
+	default:
+		return current, false, true
+	}
+}
+
+// expandWord removes the quotes from the word of a ${name:-word} expansion
+// and expands it, reporting whether it could be resolved
+func (p *shellParser) expandWord(text string) (string, bool) {
+	nested := &shellParser{src: []rune(text), depth: p.depth, vars: p.vars, aliases: p.aliases, result: p.result}
+	l := &shellList{p: nested, stage: &shellStage{}}
+	for nested.pos < len(nested.src) {
+		l.readWordPart()
+	}
+	l.endWord()
+	return strings.Join(l.stage.words, " "), !containsTrue(l.stage.unknown)
+}
+
+// substitute parses a command substitution up to term and returns its
+// output when it can be worked out statically, otherwise its text
+func (p *shellParser) substitute(term rune) (string, bool) {
+	start := p.pos
+	pipelines := p.parseList(term)
+	raw := string(p.src[start:max(start, min(p.pos-1, len(p.src)))])
+	if output, ok := evaluatePipelines(pipelines); ok {
+		p.result.scripts = append(p.result.scripts, output)
+		return strings.TrimRight(output, "\n"), true
+	}
+	if term == '`' {
+		return "`" + raw + "`", false
+	}
+	return "$(" + raw + ")", false
+}
+
+// redirect reads a redirection operator and its target. Here-documents
+// and here-strings become the input of the current stage, and process
+// substitutions are parsed as commands.
+func (p *shellParser) redirect(l *shellList) {
+	if p.peek(1) == '(' {
+		p.pos += 2
+		p.substitute(')')
+		return
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && strings.ContainsRune("<>&", p.src[p.pos]) {
+		p.pos++
+	}
+	// ">|" overrides noclobber and "<<-" strips tabs from a here-document
+	if (p.src[p.pos-1] == '>' && p.peek(0) == '|') || (string(p.src[start:p.pos]) == "<<" && p.peek(0) == '-') {
+		p.pos++
+	}
+	operator := string(p.src[start:p.pos])
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	target := p.readWord()
+
+	switch operator {
+	case "<<<":
+		input := target + "\n"
+		l.stage.stdin = &input
+	case "<<", "<<-":
+		body := new(string)
+		l.stage.stdin = body
+		p.heredocs = append(p.heredocs, pendingHeredoc{delimiter: target, stripTabs: operator == "<<-", body: body})
+	}
+}
+
+// readWord reads a single word, such as the target of a redirection
+func (p *shellParser) readWord() string {
+	l := &shellList{p: p, stage: &shellStage{}}
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n;&|()<>", p.src[p.pos]) {
+		l.readWordPart()
+	}
+	return l.word.String()
+}
+
+// readHeredocs reads the bodies of the here-documents started on the line
+// that just ended
+func (p *shellParser) readHeredocs() {
+	for _, heredoc := range p.heredocs {
+		var body strings.Builder
+		for p.pos < len(p.src) {
+			end := p.pos
+			for end < len(p.src) && p.src[end] != '\n' {
+				end++
+			}
+			line := string(p.src[p.pos:end])
+			p.pos = min(end+1, len(p.src))
+			if heredoc.stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == heredoc.delimiter {
+				break
+			}
+			body.WriteString(line + "\n")
+		}
+		*heredoc.body = body.String()
+	}
+	p.heredocs = nil
+}
+
+// finishStage records a simple command, remembers its variable and alias
+// definitions and parses any script it runs
+func (p *shellParser) finishStage(stage *shellStage) {
+	words := stage.words
+	if len(words) == 0 {
+		return
+	}
+	executable, args := unwrapShellCommand(words)
+	// args is a suffix of words, so the executable is the word before it
+	offset := len(words) - len(args)
+	p.result.commands = append(p.result.commands, words)
+	p.result.anyExecutable = append(p.result.anyExecutable, executable != "" && stage.unknownAt(offset-1))
+
+	if alias, ok := p.aliases[words[0]]; ok {
+		p.parseScript(alias + " " + strings.Join(words[1:], " "))
+		return
+	}
+
+	assignments := 0
+	switch words[0] {
+	case "export", "declare", "local", "readonly", "typeset":
+		assignments = 1
+	case "alias":
+		for _, definition := range words[1:] {
+			if name, value, ok := strings.Cut(definition, "="); ok {
+				p.aliases[name] = value
+			}
+		}
+		return
+	default:
+		if !isShellAssignment(words[len(words)-1]) {
+			assignments = len(words)
+		}
+	}
+	for i := assignments; i < len(words); i++ {
+		name, value, ok := strings.Cut(words[i], "=")
+		if !ok || !isShellAssignment(words[i]) {
+			continue
+		}
+		if stage.unknownAt(i) {
+			// Leave the variable unresolved wherever it is used
+			delete(p.vars, name)
+		} else {
+			p.vars[name] = value
+		}
+	}
+
+	switch name := shellCommandName(executable); {
+	case shellInterpreters[name]:
+		for i, arg := range args {
+			if isShortFlagCluster(arg) && strings.ContainsRune(arg, 'c') && i+1 < len(args) {
+				p.parseScript(args[i+1])
+				break
+			}
+		}
+	case name == "su":
+		for i, arg := range args {
+			if (arg == "-c" || arg == "--command") && i+1 < len(args) {
+				p.parseScript(args[i+1])
+			}
+		}
+	case name == "eval" || name == "watch":
+		if len(args) > 0 {
+			p.parseScript(strings.Join(args, " "))
+		}
+	case name == "cmd":
+		for i, arg := range args {
+			if strings.EqualFold(arg, "/c") || strings.EqualFold(arg, "/k") {
+				p.parseScript(strings.Join(args[i+1:], " "))
+				break
+			}
+		}
+	case name == "powershell" || name == "pwsh":
+		for i, arg := range args {
+			if i+1 >= len(args) {
+				break
+			}
+			switch strings.ToLower(arg) {
+			case "-command", "-c":
+				p.parseScript(strings.Join(args[i+1:], " "))
+			case "-encodedcommand", "-enc", "-e", "-ec":
+				if script, ok := decodePowerShellCommand(args[i+1]); ok {
+					p.parseScript(script)
+				}
+			}
+		}
+	case name == "find":
+		for i := 0; i < len(args); i++ {
+			switch args[i] {
+			case "-exec", "-execdir", "-ok", "-okdir":
+				end := i + 1
+				for end < len(args) && args[end] != ";" && args[end] != "+" {
+					end++
+				}
+				command := &shellStage{words: args[i+1 : end]}
+				if len(stage.unknown) == len(words) {
+					command.unknown = stage.unknown[offset+i+1 : offset+end]
+				}
+				p.finishStage(command)
+				i = end
+			}
+		}
+	}
+}
+
+// finishPipeline parses the script a shell in the pipeline reads from its
+// input, when that input is known statically
+func (p *shellParser) finishPipeline(pipeline shellPipeline) {
+	for i, stage := range pipeline {
+		executable, args := unwrapShellCommand(stage.words)
+		if !shellInterpreters[shellCommandName(executable)] || !readsScriptFromInput(args) {
+			continue
+		}
+		if stage.stdin != nil {
+			p.parseScript(*stage.stdin)
+		} else if i > 0 {
+			if output, ok := evaluatePipeline(pipeline[:i]); ok {
+				p.parseScript(output)
+			}
+		}
+	}
+}
+
+// readsScriptFromInput reports whether a shell run with args reads its
+// script from standard input
+func readsScriptFromInput(args []string) bool {
+	for _, arg := range args {
+		if arg == "-" || arg == "-s" {
+			return true
+		}
+		if !strings.HasPrefix(arg, "-") || (isShortFlagCluster(arg) && strings.ContainsRune(arg, 'c')) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluatePipelines returns the combined output of pipelines when every
+// one of them can be evaluated statically
+func evaluatePipelines(pipelines []shellPipeline) (string, bool) {
+	var output strings.Builder
+	for _, pipeline := range pipelines {
+		text, ok := evaluatePipeline(pipeline)
+		if !ok {
+			return "", false
+		}
+		output.WriteString(text)
+	}
+	return output.String(), len(pipelines) > 0
+}
+
+// evaluatePipeline works out the output of a pipeline made only of
+// commands whose output follows from their arguments and input: echo,
+// printf, cat, base64 -d, xxd -r -p and rev
+func evaluatePipeline(pipeline shellPipeline) (string, bool) {
+	var data string
+	for _, stage := range pipeline {
+		input := data
+		if stage.stdin != nil {
+			input = *stage.stdin
+		}
+		executable, args := unwrapShellCommand(stage.words)
+		switch shellCommandName(executable) {
+		case "echo":
+			data = echoOutput(args)
+		case "printf":
+			output, ok := printfOutput(args)
+			if !ok {
+				return "", false
+			}
+			data = output
+		case "cat":
+			if len(args) > 0 {
+				return "", false
+			}
+			data = input
+		case "base64":
+			if !containsAny(args, "-d", "--decode", "-D") {
+				return "", false
+			}
+			output, ok := decodeBase64Text(input)
+			if !ok {
+				return "", false
+			}
+			data = output
+		case "xxd":
+			if !containsAny(args, "-r") || !containsAny(args, "-p", "-ps", "-plain") {
+				return "", false
+			}
+			decoded, err := hex.DecodeString(strings.Join(strings.Fields(input), ""))
+			if err != nil {
+				return "", false
+			}
+			data = string(decoded)
+		case "rev":
+			lines := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+			for i, line := range lines {
+				runes := []rune(line)
+				for a, b := 0, len(runes)-1; a < b; a, b = a+1, b-1 {
+					runes[a], runes[b] = runes[b], runes[a]
+				}
+				lines[i] = string(runes)
+			}
+			data = strings.Join(lines, "\n") + "\n"
+		default:
+			return "", false
+		}
+	}
+	return data, true
+}
+
+func echoOutput(args []string) string {
+	newline, escapes := true, false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		newline = newline && !strings.ContainsRune(args[0], 'n')
+		escapes = strings.ContainsRune(args[0], 'e')
+		args = args[1:]
+	}
+	output := strings.Join(args, " ")
+	if escapes {
+		output = decodeShellEscapes(output)
+	}
+	if newline {
+		output += "\n"
+	}
+	return output
+}
+
+// printfOutput formats a printf call whose format uses only %s, %b and %%
+func printfOutput(args []string) (string, bool) {
+	if len(args) == 0 {
+		return "", false
+	}
+	format, values := decodeShellEscapes(args[0]), args[1:]
+	var output strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			output.WriteByte(format[i])
+			continue
+		}
+		if i+1 >= len(format) {
+			return "", false
+		}
+		i++
+		switch format[i] {
+		case '%':
+			output.WriteByte('%')
+		case 's', 'b':
+			if len(values) > 0 {
+				value := values[0]
+				if format[i] == 'b' {
+					value = decodeShellEscapes(value)
+				}
+				output.WriteString(value)
+				values = values[1:]
+			}
+		default:
+			return "", false
+		}
+	}
+	return output.String(), true
+}
+
+// decodeShellEscapes interprets backslash escapes as in $'...', echo -e
+// and printf
+func decodeShellEscapes(text string) string {
+	if !strings.Contains(text, `\`) {
+		return text
+	}
+	var output strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 >= len(text) {
+			output.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch c := text[i]; c {
+		case 'n':
+			output.WriteByte('\n')
+		case 't':
+			output.WriteByte('\t')
+		case 'r':
+			output.WriteByte('\r')
+		case 'a':
+			output.WriteByte('\a')
+		case 'b':
+			output.WriteByte('\b')
+		case 'e', 'E':
+			output.WriteByte(0x1b)
+		case 'x', 'u', 'U':
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			end := i + 1
+			for end < len(text) && end <= i+digits && strings.ContainsRune("0123456789abcdefABCDEF", rune(text[end])) {
+				end++
+			}
+			if end == i+1 {
+				output.WriteString(`\` + string(c))
+				continue
+			}
+			code, _ := strconv.ParseUint(text[i+1:end], 16, 32)
+			if c == 'x' {
+				output.WriteByte(byte(code))
+			} else {
+				output.WriteRune(rune(code))
+			}
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			if c == '0' {
+				end++
+			}
+			start := end
+			for end < len(text) && end < start+3 && text[end] >= '0' && text[end] <= '7' {
+				end++
+			}
+			code, _ := strconv.ParseUint("0"+text[start:end], 8, 32)
+			output.WriteByte(byte(code))
+			i = end - 1
+		default:
+			output.WriteByte(c)
+		}
+	}
+	return output.String()
+}
+
+// decodeBase64Text decodes base64 input that decodes to text
+func decodeBase64Text(input string) (string, bool) {
+	encoded := strings.Join(strings.Fields(input), "")
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(encoded); err == nil {
+			return string(decoded), true
+		}
+	}
+	return "", false
+}
+
+// decodePowerShellCommand decodes a -EncodedCommand argument, which is
+// base64 of UTF-16LE text
+func decodePowerShellCommand(encoded string) (string, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(decoded)%2 != 0 {
+		return "", false
+	}
+	units := make([]uint16, len(decoded)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(decoded[2*i:])
+	}
+	return string(utf16.Decode(units)), true
+}
+
+// unwrapShellCommand skips variable assignments, keywords and wrappers such
+// as sudo and timeout, and returns the executable that actually runs and
+// its arguments, or "" when there is none
+func unwrapShellCommand(words []string) (string, []string) {
+	for i := 0; i < len(words); {
+		word := words[i]
+		name := shellCommandName(word)
+		wrapper, isWrapper := shellWrappers[name]
+		switch {
+		case isShellAssignment(word) || shellKeywords[word]:
+			i++
+		case word == "function":
+			// The body of "function name { ...; }" follows as commands of
+			// its own
+			i += 2
+		case isWrapper:
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") && words[i] != "-" {
+				option := words[i]
+				i++
+				if option == "--" {
+					break
+				}
+				// "command -v rm" looks rm up rather than running it
+				if name == "command" && (option == "-v" || option == "-V") {
+					return "", nil
+				}
+				if containsAny(wrapper.valueOptions, option) && i < len(words) {
+					i++
+				}
+			}
+			i += min(wrapper.positional, len(words)-i)
+		default:
+			return word, words[i+1:]
+		}
+	}
+	return "", nil
+}
+
+// shellCommandName returns the name of an executable without its
+// directory and .exe suffix, in lower case
+func shellCommandName(executable string) string {
+	if i := strings.LastIndexAny(executable, `/\`); i >= 0 {
+		executable = executable[i+1:]
+	}
+	return strings.TrimSuffix(strings.ToLower(executable), ".exe")
+}
+
+func isShellAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isShortFlagCluster reports whether word is a group of short options
+// such as "-rf"
+func isShortFlagCluster(word string) bool {
+	return len(word) > 1 && word[0] == '-' && word[1] != '-'
+}
+
+func isDigits(text string) bool {
+	return text != "" && strings.Trim(text, "0123456789") == ""
+}
+
+func containsTrue(values []bool) bool {
+	for _, value := range values {
+		if value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(words []string, candidates ...string) bool {
+	for _, word := range words {
+		for _, candidate := range candidates {
+			if word == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTextPattern reports whether a command pattern uses shell syntax, in
+// which case it is matched as text rather than as an executable and
+// arguments
+func isTextPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "|&;<>()$`\\\"'*?[]{}!#")
+}
+
+// commandMatchesPattern reports whether a simple command runs the
+// pattern's executable with all of its arguments, in any order. The
+// executable also matches its dotted variants (mkfs matches mkfs.ext4),
+// short options match in any grouping (-rf matches -f -r or -Rf) and a
+// long option counts as its first letter (--recursive as -r). With
+// anyExecutable the executable could be any command, and only the
+// arguments are compared.
+func commandMatchesPattern(pattern []string, command []string, anyExecutable bool) bool {
+	executable, args := unwrapShellCommand(command)
+	if executable == "" {
+		return false
+	}
+	name, want := shellCommandName(executable), shellCommandName(pattern[0])
+	if !anyExecutable && name != want && !strings.HasPrefix(name, want+".") {
+		return false
+	}
+
+	flags := make(map[rune]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			flags[unicode.ToLower(rune(arg[2]))] = true
+		} else if isShortFlagCluster(arg) {
+			for _, r := range arg[1:] {
+				flags[unicode.ToLower(r)] = true
+			}
+		}
+	}
+
+	for _, want := range pattern[1:] {
+		if isShortFlagCluster(want) {
+			for _, r := range want[1:] {
+				if !flags[unicode.ToLower(r)] {
+					return false
+				}
+			}
+			continue
+		}
+		found := false
+		for _, arg := range args {
+			if strings.EqualFold(arg, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchCommandPattern returns the first of patterns that command matches.
+// Patterns made of plain words are compared with every simple command,
+// including nested and decoded ones; patterns using shell syntax are
+// matched as text with runs of whitespace collapsed. A command nested too
+// deeply to inspect matches any non-empty list, reported as an empty
+// pattern.
+func MatchCommandPattern(command string, patterns []string) (string, bool) {
+	if len(patterns) == 0 {
+		return "", false
+	}
+	parsed := parseShellCommand(command)
+	if parsed.tooDeep {
+		return "", true
+	}
+
+	for _, pattern := range patterns {
+		if commandMatchesPatternText(command, parsed, pattern) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// MatchAllCommandPatterns returns every one of patterns that command
+// matches, compared as MatchCommandPattern does. tooDeep is set for a
+// command nested too deeply to inspect, which no pattern is compared with.
+func MatchAllCommandPatterns(command string, patterns []string) (matched []string, tooDeep bool) {
+	parsed := parseShellCommand(command)
+	if parsed.tooDeep {
+		return nil, true
+	}
+	for _, pattern := range patterns {
+		if commandMatchesPatternText(command, parsed, pattern) {
+			matched = append(matched, pattern)
+		}
+	}
+	return matched, false
+}
+
+// commandMatchesPatternText reports whether a parsed command matches one
+// pattern
+func commandMatchesPatternText(command string, parsed *parsedShellCommand, pattern string) bool {
+	if strings.TrimSpace(pattern) == "" {
+		return false
+	}
+	if isTextPattern(pattern) {
+		needle := strings.Join(strings.Fields(pattern), " ")
+		for _, text := range append([]string{command}, parsed.scripts...) {
+			if strings.Contains(strings.Join(strings.Fields(text), " "), needle) {
+				return true
+			}
+		}
+		return false
+	}
+	words := strings.Fields(pattern)
+	for i, simple := range parsed.commands {
+		if commandMatchesPattern(words, simple, parsed.anyExecutable[i]) {
+			return true
+		}
+	}
+	return false
+}
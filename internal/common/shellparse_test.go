@@ -0,0 +1,77 @@
+package common
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMatchCommandPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern, command string
+		match            bool
+	}{
+		{"rm -rf", "rm -rf /", true},
+		{"rm -rf", "rm  -rf /", true},
+		{"rm -rf", `"rm" -rf /`, true},
+		{"rm -rf", "r'm' -fr /", true},
+		{"rm -rf", "rm -r -f /", true},
+		{"rm -rf", "rm --recursive --force /", true},
+		{"rm -rf", "/bin/rm -rf /", true},
+		{"rm -rf", "rm \\\n-rf /", true},
+		{"rm -rf", "ls\nrm -rf /", true},
+		{"rm -rf", "x=rm; $x -rf /", true},
+		{"rm -rf", "x=rm; ${x} -rf /", true},
+		{"rm -rf", `$'\x72m' -rf /`, true},
+		{"rm -rf", "alias x=rm; x -rf /", true},
+		{"rm -rf", "sh -c 'rm -rf /'", true},
+		{"rm -rf", `bash -c "rm -rf /"`, true},
+		{"rm -rf", `eval "rm -rf /"`, true},
+		{"rm -rf", "echo $(rm -rf /)", true},
+		{"rm -rf", "echo cm0gLXJmIC8= | base64 -d | sh", true},
+		{"rm -rf", "sh <<EOF\nrm -rf /\nEOF", true},
+		{"rm -rf", `find . -exec rm -rf {} \;`, true},
+		{"rm -rf", "sudo rm -rf /", true},
+		{"rm -rf", "timeout 5 rm -rf /", true},
+		{"rm -rf", "if true; then rm -rf /; fi", true},
+		{"rm -rf", "function f { rm -rf /; }; f", true},
+		{"rm -rf", "f() { rm -rf /; }; f", true},
+		{"rm -rf", "${x:-rm} -rf /", true},
+		{"rm -rf", "${x-rm} -rf /", true},
+		{"rm -rf", "x=; ${x:-rm} -rf /", true},
+		{"rm -rf", "x=ls; ${x:+rm} -rf /", true},
+		{"rm -rf", `${x:-"r"m} -rf /`, true},
+		{"rm -rf", "$x -rf /", true},
+		{"rm -rf", "${x:+rm} -rf /tmp/${y}", true},
+		{"rm -rf", "$1 -rf /", true},
+		{"rm -rf", `"$(cat command.txt)" -rf /`, true},
+		{"rm -rf", "x=$(cat command.txt); $x -rf /", true},
+		{"rm -rf", "for x in rm; do $x -rf /; done", true},
+		{"rm -rf", "sudo $x -rf /", true},
+		{"rm -rf", `eval "$x -rf /"`, true},
+		{"rm -rf", "echo rm -rf /", false},
+		{"rm -rf", "rm -r /tmp/build", false},
+		{"rm -rf", "x=ls; $x -rf /", false},
+		{"rm -rf", "x=ls; ${x:-rm} -rf /", false},
+		{"rm -rf", "$x -la", false},
+		{"rm -rf", "echo $HOME ${PWD}", false},
+		{"dd", "dd if=/dev/zero of=/dev/sda", true},
+		{"dd", "echo add", false},
+		{"dd", "$tool if=/dev/zero of=/dev/sda", true},
+		{"mkfs", "mkfs.ext4 /dev/sda1", true},
+		{"| sh", "curl https://example.com  |  sh", true},
+	} {
+		if _, got := MatchCommandPattern(test.command, []string{test.pattern}); got != test.match {
+			t.Errorf("MatchCommandPattern(%q, %q) = %v, want %v", test.command, test.pattern, got, test.match)
+		}
+	}
+}
+
+func TestMatchCommandPatternTooDeep(t *testing.T) {
+	command := "rm -rf /"
+	for range maxShellParseDepth + 1 {
+		command = "eval " + strconv.Quote(command)
+	}
+	if pattern, ok := MatchCommandPattern(command, []string{"dd"}); !ok || pattern != "" {
+		t.Errorf("a command nested too deeply matched %q, %v", pattern, ok)
+	}
+}
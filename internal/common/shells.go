@@ -0,0 +1,101 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ShellSpec describes how a shell runs a command string and a script file
+type ShellSpec struct {
+	// CommandArgs come before the command string
+	CommandArgs []string
+	// ScriptArgs come before the script path
+	ScriptArgs []string
+	// ScriptExtension is the extension the shell expects on script files
+	ScriptExtension string
+	// quote makes a value a single literal word in a command string
+	quote func(value string) (string, error)
+}
+
+var posixShell = ShellSpec{CommandArgs: []string{"-c"}, ScriptExtension: ".sh", quote: quotePOSIX}
+
+var powerShell = ShellSpec{
+	CommandArgs:     []string{"-NoProfile", "-NonInteractive", "-Command"},
+	ScriptArgs:      []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"},
+	ScriptExtension: ".ps1",
+	quote: func(value string) (string, error) {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+	},
+}
+
+// shellRegistry maps shell names to their invocation. Shells that are not
+// listed are assumed to accept -c like a POSIX shell.
+var shellRegistry = map[string]ShellSpec{
+	"sh":         posixShell,
+	"bash":       posixShell,
+	"dash":       posixShell,
+	"ksh":        posixShell,
+	"zsh":        {CommandArgs: []string{"-c"}, ScriptExtension: ".zsh", quote: quotePOSIX},
+	"fish":       {CommandArgs: []string{"-c"}, ScriptExtension: ".fish", quote: quoteFish},
+	"powershell": powerShell,
+	"pwsh":       powerShell,
+	"cmd":        {CommandArgs: []string{"/D", "/C"}, ScriptArgs: []string{"/D", "/C"}, ScriptExtension: ".cmd", quote: quoteCmd},
+}
+
+// shellName reduces a shell given as a name or a path, such as
+// /usr/bin/zsh or C:\Windows\System32\cmd.exe, to its registry name
+func shellName(shell string) string {
+	name := filepath.Base(strings.ReplaceAll(shell, `\`, "/"))
+	return strings.TrimSuffix(strings.ToLower(name), ".exe")
+}
+
+// LookupShell returns the invocation for shell
+func LookupShell(shell string) ShellSpec {
+	if spec, ok := shellRegistry[shellName(shell)]; ok {
+		return spec
+	}
+	return posixShell
+}
+
+// ShellCommandArgs returns the arguments that make shell run command
+func ShellCommandArgs(shell, command string) []string {
+	spec := LookupShell(shell)
+	return append(append([]string(nil), spec.CommandArgs...), command)
+}
+
+// ShellScriptArgs returns the arguments that make shell run the script at
+// scriptPath
+func ShellScriptArgs(shell, scriptPath string) []string {
+	spec := LookupShell(shell)
+	return append(append([]string(nil), spec.ScriptArgs...), scriptPath)
+}
+
+// QuoteShellArgument quotes value so that shell reads it as one literal
+// argument, for substituting untrusted values into a command string
+func QuoteShellArgument(shell, value string) (string, error) {
+	if strings.ContainsRune(value, 0) {
+		return "", fmt.Errorf("value contains a NUL byte")
+	}
+	return LookupShell(shell).quote(value)
+}
+
+func quotePOSIX(value string) (string, error) {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+}
+
+// quoteFish escapes backslashes too, since fish reads \\ and \' inside
+// single quotes
+func quoteFish(value string) (string, error) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'", nil
+}
+
+// quoteCmd refuses the characters cmd interprets even inside double
+// quotes, since it has no way to escape them reliably
+func quoteCmd(value string) (string, error) {
+	if strings.ContainsAny(value, "\"%!^&|<>()\r\n") {
+		return "", fmt.Errorf("value %q contains characters that cannot be quoted safely for cmd", value)
+	}
+	return `"` + value + `"`, nil
+}
@@ -0,0 +1,164 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SecureDelete shreds path, or every file below it when recursive is set,
+// and returns the number of files overwritten. All files are checked before
+// any of them is touched; storage warnings abort the operation unless
+// ignoreStorageWarnings is set, in which case they are returned. With dryRun
+// only the checks are performed.
+func SecureDelete(path string, recursive bool, passes int, ignoreStorageWarnings, dryRun bool) (int, []string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if !recursive {
+			return 0, nil, fmt.Errorf("secure delete of a directory requires recursive=true")
+		}
+		files = nil
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+	}
+
+	var warnings []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		fileWarnings, err := CheckSecureDelete(file)
+		if err != nil {
+			return 0, nil, WithErrorCode(ErrorPermissionDenied, fmt.Errorf("refused: %w", err))
+		}
+		for _, warning := range fileWarnings {
+			if !seen[warning] {
+				seen[warning] = true
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+
+	if len(warnings) > 0 && !ignoreStorageWarnings {
+		return 0, nil, WithErrorCode(ErrorPermissionDenied, fmt.Errorf("refused: %s. Overwriting cannot guarantee the data is destroyed on this storage; rely on full-disk encryption instead, or set ignore_storage_warnings=true to overwrite anyway", strings.Join(warnings, "; ")))
+	}
+
+	if dryRun {
+		return len(files), warnings, nil
+	}
+
+	for _, file := range files {
+		if err := ShredFile(file, passes); err != nil {
+			return 0, warnings, fmt.Errorf("failed to shred %s: %w", file, err)
+		}
+	}
+
+	if info.IsDir() {
+		if err := os.RemoveAll(path); err != nil {
+			return len(files), warnings, err
+		}
+	}
+
+	return len(files), warnings, nil
+}
+
+// CheckSecureDelete reports whether overwriting path is meaningful. An error
+// means the file must not be shredded at all; warnings describe storage
+// where overwritten data may survive (SSDs, copy-on-write or network
+// filesystems).
+func CheckSecureDelete(path string) ([]string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%s is a symlink; secure delete only works on regular files", path)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	if ext, ok := GetExtendedFileInfo(info); ok && ext.Links > 1 {
+		return nil, fmt.Errorf("%s has %d hard links; overwriting would destroy data reachable through the other links", path, ext.Links)
+	}
+
+	return secureDeleteStorageWarnings(path), nil
+}
+
+// ShredFile overwrites the contents of path with random data the given
+// number of times, syncing after every pass, then renames and removes it
+func ShredFile(path string, passes int) error {
+	if passes < 1 {
+		passes = 1
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	size := info.Size()
+
+	for pass := 0; pass < passes; pass++ {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := io.CopyN(file, rand.Reader, size); err != nil {
+			file.Close()
+			return fmt.Errorf("overwrite pass %d failed: %w", pass+1, err)
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return fmt.Errorf("overwrite pass %d failed: %w", pass+1, err)
+		}
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return err
+	}
+	file.Sync()
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// Rename before unlinking so the original name does not linger in the
+	// directory entry
+	target := path
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err == nil {
+		renamed := filepath.Join(filepath.Dir(path), "."+hex.EncodeToString(suffix))
+		if os.Rename(path, renamed) == nil {
+			target = renamed
+		}
+	}
+
+	if err := os.Remove(target); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
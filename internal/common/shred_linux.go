@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Filesystems where an in-place overwrite does not reach the original blocks
+var unsafeShredFilesystems = map[int64]string{
+	0x9123683E: "btrfs (copy-on-write)",
+	0x2FC12FC1: "zfs (copy-on-write)",
+	0xCA451A4E: "bcachefs (copy-on-write)",
+	0xF2F52010: "f2fs (log-structured)",
+	0x794C7630: "overlayfs (lower layers keep the original)",
+	0x6969:     "nfs (network filesystem)",
+	0xFF534D42: "cifs (network filesystem)",
+	0xFE534D42: "smb2 (network filesystem)",
+	0x65735546: "fuse (unknown backing storage)",
+}
+
+func secureDeleteStorageWarnings(path string) []string {
+	var warnings []string
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err == nil {
+		if name, ok := unsafeShredFilesystems[int64(fs.Type)]; ok {
+			warnings = append(warnings, fmt.Sprintf("file is on %s; overwritten data may persist elsewhere", name))
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return warnings
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return warnings
+	}
+
+	if rotational, ok := blockDeviceRotational(uint64(st.Dev)); ok && !rotational {
+		warnings = append(warnings, "file is on a solid-state drive; wear leveling means overwrites may not reach the original cells")
+	}
+
+	return warnings
+}
+
+// blockDeviceRotational reads the rotational flag of the block device
+// backing dev from sysfs. Partitions report it through their parent disk.
+func blockDeviceRotational(dev uint64) (bool, bool) {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+
+	device, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return false, false
+	}
+
+	for _, dir := range []string{device, filepath.Dir(device)} {
+		data, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+		if err == nil {
+			return strings.TrimSpace(string(data)) == "1", true
+		}
+	}
+
+	return false, false
+}
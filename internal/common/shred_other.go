@@ -0,0 +1,7 @@
+//go:build !linux
+
+package common
+
+func secureDeleteStorageWarnings(path string) []string {
+	return []string{"storage medium cannot be verified on this platform; SSDs and copy-on-write filesystems may retain overwritten data"}
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// signalPollInterval is how often StopProcess checks whether a process has
+// exited
+const signalPollInterval = 100 * time.Millisecond
+
+// normalizeSignalName turns "sigterm", "SIGTERM" or "term" into "TERM"
+func normalizeSignalName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	return strings.TrimPrefix(name, "SIG")
+}
+
+// StopProcess sends the named signal to pid. When grace is positive it
+// then waits up to grace for the process to exit and sends SIGKILL if it
+// has not; escalated reports whether that was needed.
+func StopProcess(ctx context.Context, pid int, signal string, grace time.Duration) (escalated bool, err error) {
+	if err := SignalProcess(pid, signal); err != nil {
+		return false, err
+	}
+	if grace <= 0 || normalizeSignalName(signal) == "KILL" {
+		return false, nil
+	}
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if !processExists(pid) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(signalPollInterval):
+		}
+	}
+	if !processExists(pid) {
+		return false, nil
+	}
+	if err := SignalProcess(pid, "KILL"); err != nil {
+		return true, fmt.Errorf("process did not exit after %s and could not be killed: %w", grace, err)
+	}
+	return true, nil
+}
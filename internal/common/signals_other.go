@@ -0,0 +1,31 @@
+//go:build !linux && !darwin
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// SignalProcess stops pid; only TERM and KILL exist on this platform and
+// both end the process immediately
+func SignalProcess(pid int, name string) error {
+	switch normalizeSignalName(name) {
+	case "TERM", "KILL":
+	default:
+		return fmt.Errorf("signal %s is not supported on this platform (use SIGTERM or SIGKILL)", name)
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("no process with PID %d", pid)
+	}
+	return proc.Kill()
+}
+
+func processExists(pid int) bool {
+	exists, err := process.PidExistsWithContext(context.Background(), int32(pid))
+	return err == nil && exists
+}
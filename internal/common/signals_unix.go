@@ -0,0 +1,51 @@
+//go:build linux || darwin
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+)
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"STOP":  syscall.SIGSTOP,
+	"CONT":  syscall.SIGCONT,
+	"TSTP":  syscall.SIGTSTP,
+	"WINCH": syscall.SIGWINCH,
+	"ALRM":  syscall.SIGALRM,
+}
+
+// SignalProcess sends a signal, given by name such as SIGHUP or HUP, or by
+// number, to pid
+func SignalProcess(pid int, name string) error {
+	signal, ok := signalNames[normalizeSignalName(name)]
+	if !ok {
+		number, err := strconv.Atoi(name)
+		if err != nil || number <= 0 || number > 64 {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown signal %q", name))
+		}
+		signal = syscall.Signal(number)
+	}
+
+	if err := syscall.Kill(pid, signal); err != nil {
+		if err == syscall.ESRCH {
+			return fmt.Errorf("no process with PID %d", pid)
+		}
+		return err
+	}
+	return nil
+}
+
+// processExists reports whether pid is still running
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+var (
+	snippetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// ${name} or ${name:default}
+	snippetPlaceholderPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+)
+
+// snippetDirectory returns the directory snippets are stored in, next to
+// the config file so a team can share it alongside their configuration
+func snippetDirectory() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), ".jarvis-mcp-snippets")
+}
+
+func snippetPath(name string) (string, error) {
+	if !snippetNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid snippet name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(snippetDirectory(), name+".json"), nil
+}
+
+// SnippetPlaceholders returns the placeholder names used in content, in
+// order of first use
+func SnippetPlaceholders(content string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range snippetPlaceholderPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// SaveSnippet stores a snippet in the library. An existing snippet of the
+// same name is only replaced when overwrite is set; its creation time is
+// kept.
+func SaveSnippet(snippet types.Snippet, overwrite bool) (types.Snippet, error) {
+	path, err := snippetPath(snippet.Name)
+	if err != nil {
+		return snippet, err
+	}
+
+	now := time.Now()
+	snippet.CreatedAt = now
+	if existing, err := LoadSnippet(snippet.Name); err == nil {
+		if !overwrite {
+			return snippet, WithErrorCode(ErrorAlreadyExists, fmt.Errorf("snippet %s already exists (set overwrite to replace it)", snippet.Name))
+		}
+		snippet.CreatedAt = existing.CreatedAt
+	}
+	snippet.UpdatedAt = now
+	snippet.Placeholders = SnippetPlaceholders(snippet.Content)
+
+	data, err := json.MarshalIndent(snippet, "", "  ")
+	if err != nil {
+		return snippet, err
+	}
+	if err := EnsureDir(snippetDirectory()); err != nil {
+		return snippet, fmt.Errorf("failed to create snippet directory: %w", err)
+	}
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
+		return snippet, fmt.Errorf("failed to save snippet: %w", err)
+	}
+	return snippet, nil
+}
+
+// LoadSnippet reads a snippet from the library
+func LoadSnippet(name string) (types.Snippet, error) {
+	var snippet types.Snippet
+	path, err := snippetPath(name)
+	if err != nil {
+		return snippet, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return snippet, WithErrorCode(ErrorNotFound, fmt.Errorf("snippet not found: %s", name))
+	}
+	if err != nil {
+		return snippet, err
+	}
+	if err := json.Unmarshal(data, &snippet); err != nil {
+		return snippet, fmt.Errorf("snippet %s is corrupt: %w", name, err)
+	}
+	return snippet, nil
+}
+
+// DeleteSnippet removes a snippet from the library
+func DeleteSnippet(name string) error {
+	path, err := snippetPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return WithErrorCode(ErrorNotFound, fmt.Errorf("snippet not found: %s", name))
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListSnippets returns the snippets in the library sorted by name,
+// optionally filtered by language and by a case-insensitive query matched
+// against name and description
+func ListSnippets(language, query string) ([]types.Snippet, error) {
+	entries, err := os.ReadDir(snippetDirectory())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var snippets []types.Snippet
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		snippet, err := LoadSnippet(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		if language != "" && !strings.EqualFold(snippet.Language, language) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(snippet.Name+" "+snippet.Description), query) {
+			continue
+		}
+		snippets = append(snippets, snippet)
+	}
+
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].Name < snippets[j].Name })
+	return snippets, nil
+}
+
+// ExpandSnippet substitutes ${name} and ${name:default} placeholders with
+// values. The built-in placeholders year and date are filled in unless
+// values overrides them; defaults apply to everything else. Placeholders
+// without a value or default are reported together as an error.
+func ExpandSnippet(content string, values map[string]string) (string, error) {
+	now := time.Now()
+	builtins := map[string]string{
+		"year": now.Format("2006"),
+		"date": now.Format("2006-01-02"),
+	}
+
+	missing := make(map[string]bool)
+	var missingNames []string
+	expanded := snippetPlaceholderPattern.ReplaceAllStringFunc(content, func(placeholder string) string {
+		match := snippetPlaceholderPattern.FindStringSubmatch(placeholder)
+		name := match[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		if value, ok := builtins[name]; ok {
+			return value
+		}
+		if strings.Contains(placeholder, ":") {
+			return match[2]
+		}
+		if !missing[name] {
+			missing[name] = true
+			missingNames = append(missingNames, name)
+		}
+		return placeholder
+	})
+
+	if len(missingNames) > 0 {
+		return "", fmt.Errorf("missing values for placeholders: %s", strings.Join(missingNames, ", "))
+	}
+	return expanded, nil
+}
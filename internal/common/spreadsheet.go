@@ -0,0 +1,300 @@
+package common
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Spreadsheet provides read access to the sheets of an .xlsx workbook.
+// Only cell values are read; styles and formulas are ignored, and dates are
+// returned as their raw serial numbers.
+type Spreadsheet struct {
+	zip           *zip.ReadCloser
+	sheets        []SpreadsheetSheet
+	sharedStrings []string
+}
+
+// SpreadsheetSheet describes a worksheet in a workbook
+type SpreadsheetSheet struct {
+	Name string `json:"name"`
+	path string
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxStringItem `xml:"si"`
+}
+
+type xlsxStringItem struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s xlsxStringItem) String() string {
+	if len(s.Runs) == 0 {
+		return s.Text
+	}
+	var b strings.Builder
+	for _, run := range s.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string         `xml:"r,attr"`
+			Type   string         `xml:"t,attr"`
+			Value  string         `xml:"v"`
+			Inline xlsxStringItem `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// OpenSpreadsheet opens an .xlsx file and reads its sheet index
+func OpenSpreadsheet(filePath string) (*Spreadsheet, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spreadsheet: %w", err)
+	}
+
+	ss := &Spreadsheet{zip: zr}
+
+	var workbook xlsxWorkbook
+	if err := ss.decode("xl/workbook.xml", &workbook); err != nil {
+		zr.Close()
+		return nil, err
+	}
+
+	var rels xlsxRelationships
+	if err := ss.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		zr.Close()
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	for _, sheet := range workbook.Sheets {
+		ss.sheets = append(ss.sheets, SpreadsheetSheet{Name: sheet.Name, path: targets[sheet.RID]})
+	}
+
+	// Shared strings are optional
+	var shared xlsxSharedStrings
+	if err := ss.decode("xl/sharedStrings.xml", &shared); err == nil {
+		for _, item := range shared.Items {
+			ss.sharedStrings = append(ss.sharedStrings, item.String())
+		}
+	}
+
+	return ss, nil
+}
+
+// Close releases the underlying file
+func (s *Spreadsheet) Close() error {
+	return s.zip.Close()
+}
+
+// Sheets returns the worksheets in workbook order
+func (s *Spreadsheet) Sheets() []SpreadsheetSheet {
+	return s.sheets
+}
+
+// maxSheetColumns and maxSheetCells bound the dense grid ReadSheet
+// returns, which a single distant cell would otherwise stretch to the
+// whole 16384 by 1048576 sheet
+const (
+	maxSheetColumns = 1024
+	maxSheetCells   = 1000000
+)
+
+// ReadSheet returns the cell values of a sheet as a dense grid. The sheet is
+// selected by name or 1-based index; an empty selector picks the first sheet.
+// cellRange (e.g. "A1:C10") limits the rows and columns returned; rows past
+// the last value are left out. A grid wider than maxSheetColumns or larger
+// than maxSheetCells is refused.
+func (s *Spreadsheet) ReadSheet(selector, cellRange string, maxRows int) ([][]string, error) {
+	sheet, err := s.findSheet(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	minCol, minRow, maxCol, maxRow := 0, 0, -1, -1
+	if cellRange != "" {
+		if minCol, minRow, maxCol, maxRow, err = ParseCellRange(cellRange); err != nil {
+			return nil, err
+		}
+		if columns := maxCol - minCol + 1; columns > maxSheetColumns {
+			return nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("range %s spans %d columns, more than the limit of %d", cellRange, columns, maxSheetColumns))
+		}
+	}
+
+	var ws xlsxWorksheet
+	if err := s.decode(sheet.path, &ws); err != nil {
+		return nil, err
+	}
+
+	cells := make(map[int]map[int]string)
+	lastRow, lastCol := -1, -1
+	for _, row := range ws.Rows {
+		for _, cell := range row.Cells {
+			col, r, err := ParseCellRef(cell.Ref)
+			if err != nil {
+				continue
+			}
+			if r < minRow || col < minCol || (maxRow >= 0 && r > maxRow) || (maxCol >= 0 && col > maxCol) {
+				continue
+			}
+
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				if idx, err := strconv.Atoi(cell.Value); err == nil && idx < len(s.sharedStrings) {
+					value = s.sharedStrings[idx]
+				}
+			case "inlineStr":
+				value = cell.Inline.String()
+			case "b":
+				value = strconv.FormatBool(cell.Value == "1")
+			}
+
+			if cells[r] == nil {
+				cells[r] = make(map[int]string)
+			}
+			cells[r][col] = value
+			lastRow = max(lastRow, r)
+			lastCol = max(lastCol, col)
+		}
+	}
+
+	if maxCol >= 0 {
+		lastCol = maxCol
+	}
+	rows, columns := lastRow-minRow+1, lastCol-minCol+1
+	if maxRows > 0 {
+		rows = min(rows, maxRows)
+	}
+	if columns > maxSheetColumns {
+		return nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("sheet %s uses %d columns, more than the limit of %d; give a cell range", sheet.Name, columns, maxSheetColumns))
+	}
+	if rows > 0 && rows*columns > maxSheetCells {
+		return nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("%d rows of %d columns exceed the limit of %d cells; give a smaller cell range or max_rows", rows, columns, maxSheetCells))
+	}
+
+	var grid [][]string
+	for r := minRow; r < minRow+rows; r++ {
+		values := make([]string, 0, columns)
+		for c := minCol; c <= lastCol; c++ {
+			values = append(values, cells[r][c])
+		}
+		grid = append(grid, values)
+	}
+
+	return grid, nil
+}
+
+func (s *Spreadsheet) findSheet(selector string) (*SpreadsheetSheet, error) {
+	if len(s.sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	if selector == "" {
+		return &s.sheets[0], nil
+	}
+	for i := range s.sheets {
+		if s.sheets[i].Name == selector {
+			return &s.sheets[i], nil
+		}
+	}
+	if idx, err := strconv.Atoi(selector); err == nil && idx >= 1 && idx <= len(s.sheets) {
+		return &s.sheets[idx-1], nil
+	}
+	return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("sheet not found: %s", selector))
+}
+
+func (s *Spreadsheet) decode(name string, v interface{}) error {
+	for _, f := range s.zip.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer rc.Close()
+
+		if err := xml.NewDecoder(io.LimitReader(rc, 512<<20)).Decode(v); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		return nil
+	}
+	return WithErrorCode(ErrorNotFound, fmt.Errorf("%s not found in workbook", name))
+}
+
+// ParseCellRef converts a cell reference such as "B3" to 0-based column and row
+func ParseCellRef(ref string) (int, int, error) {
+	ref = strings.ToUpper(strings.ReplaceAll(ref, "$", ""))
+
+	i := 0
+	col := 0
+	for i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' {
+		col = col*26 + int(ref[i]-'A'+1)
+		i++
+	}
+	if i == 0 || i == len(ref) {
+		return 0, 0, fmt.Errorf("invalid cell reference: %s", ref)
+	}
+
+	row, err := strconv.Atoi(ref[i:])
+	if err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("invalid cell reference: %s", ref)
+	}
+
+	return col - 1, row - 1, nil
+}
+
+// ParseCellRange converts a range such as "A1:C10" to 0-based bounds
+func ParseCellRange(cellRange string) (minCol, minRow, maxCol, maxRow int, err error) {
+	parts := strings.SplitN(cellRange, ":", 2)
+	if minCol, minRow, err = ParseCellRef(parts[0]); err != nil {
+		return
+	}
+	if len(parts) == 1 {
+		return minCol, minRow, minCol, minRow, nil
+	}
+	if maxCol, maxRow, err = ParseCellRef(parts[1]); err != nil {
+		return
+	}
+	if maxCol < minCol || maxRow < minRow {
+		err = fmt.Errorf("invalid cell range: %s", cellRange)
+	}
+	return
+}
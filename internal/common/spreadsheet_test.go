@@ -0,0 +1,72 @@
+package common
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// writeWorkbook writes an .xlsx file whose only sheet holds sheetData
+func writeWorkbook(t *testing.T, sheetData string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.xlsx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for name, content := range map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Data" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSheetBoundsTheGrid(t *testing.T) {
+	path := writeWorkbook(t, `<row><c r="A1" t="inlineStr"><is><t>a</t></is></c></row>`+
+		`<row><c r="XFD1048576"><v>1</v></c></row>`)
+	spreadsheet, err := OpenSpreadsheet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spreadsheet.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = spreadsheet.ReadSheet("", "", 0)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("ReadSheet returned the whole sheet up to a distant cell")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("ReadSheet allocated %d bytes for two cells", allocated)
+	}
+
+	if _, err := spreadsheet.ReadSheet("", "A1:XFD1", 0); err == nil {
+		t.Error("ReadSheet accepted a range wider than the column limit")
+	}
+	// Rows past the last value in the range are not padded
+	rows, err := spreadsheet.ReadSheet("", "A1:B1048576", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"a", ""}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadSheet = %q, want %q", rows, want)
+	}
+}
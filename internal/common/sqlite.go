@@ -0,0 +1,118 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLite access is implemented on top of the sqlite3 command line shell,
+// which is always run with -safe so SQL cannot reach the filesystem through
+// functions like writefile() or dot-commands like .shell.
+
+// RunSQLiteQuery executes SQL against a database file and returns the rows
+// as JSON. Parameters are bound to ?1..?N. Unless readOnly is false the
+// database is opened with -readonly.
+func RunSQLiteQuery(ctx context.Context, dbPath, query string, params []interface{}, readOnly bool, timeout time.Duration) (string, error) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return "", WithErrorCode(ErrorNotFound, fmt.Errorf("sqlite3 command not found in PATH"))
+	}
+
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query cannot be empty")
+	}
+
+	// Dot-commands are shell directives, not SQL; never pass them through
+	for _, line := range strings.Split(query, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ".") {
+			return "", fmt.Errorf("dot-commands are not allowed in queries")
+		}
+	}
+
+	var script strings.Builder
+	if len(params) > 0 {
+		script.WriteString(".parameter init\n")
+		for i, param := range params {
+			literal, err := sqliteLiteral(param)
+			if err != nil {
+				return "", fmt.Errorf("parameter %d: %w", i+1, err)
+			}
+			script.WriteString(fmt.Sprintf(".parameter set ?%d %s\n", i+1, literal))
+		}
+	}
+	script.WriteString(query)
+	if !strings.HasSuffix(strings.TrimSpace(query), ";") {
+		script.WriteString(";")
+	}
+	script.WriteString("\n")
+
+	args := []string{"-safe", "-bail", "-json"}
+	if readOnly {
+		args = append(args, "-readonly")
+	}
+	args = append(args, dbPath)
+
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, "sqlite3", args...)
+	cmd.Stdin = strings.NewReader(script.String())
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			return "", WithErrorCode(ErrorTimeout, fmt.Errorf("query timed out after %s", timeout))
+		}
+		return "", fmt.Errorf("%s", strings.TrimSpace(stderr.String()+" "+err.Error()))
+	}
+
+	output := strings.TrimSpace(stdout.String())
+	if output == "" {
+		output = "[]"
+	}
+	return output, nil
+}
+
+// GetSQLiteSchema returns the schema objects of a database, or the column
+// definitions of a single table when table is not empty
+func GetSQLiteSchema(ctx context.Context, dbPath, table string, timeout time.Duration) (string, error) {
+	if table != "" {
+		return RunSQLiteQuery(ctx, dbPath,
+			"SELECT cid, name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?1)",
+			[]interface{}{table}, true, timeout)
+	}
+
+	return RunSQLiteQuery(ctx, dbPath,
+		"SELECT type, name, tbl_name, sql FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name",
+		nil, true, timeout)
+}
+
+// sqliteLiteral renders a JSON-decoded value as the value of a .parameter
+// set command. The shell evaluates that value as an SQL expression, so
+// strings are given as hex blobs cast back to text, which bind exactly as
+// given rather than as whatever the string would evaluate to.
+func sqliteLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case string:
+		return fmt.Sprintf("\"CAST(X'%s' AS TEXT)\"", hex.EncodeToString([]byte(v))), nil
+	default:
+		return "", fmt.Errorf("unsupported parameter type %T", value)
+	}
+}
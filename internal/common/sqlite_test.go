@@ -0,0 +1,46 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunSQLiteQueryBindsParametersAsGiven(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	params := []interface{}{"it's", "007", "abs(-5)", "two\nlines", "", 42.0, nil}
+	output, err := RunSQLiteQuery(context.Background(), ":memory:",
+		"SELECT ?1 AS quote, ?2 AS zeros, ?3 AS expression, ?4 AS newline, ?5 AS empty, typeof(?2) AS zeros_type, ?6 AS number, ?7 AS null_value",
+		params, true, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &rows); err != nil {
+		t.Fatalf("output is not JSON rows: %v\n%s", err, output)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1: %s", len(rows), output)
+	}
+	want := map[string]interface{}{
+		"quote":      "it's",
+		"zeros":      "007",
+		"expression": "abs(-5)",
+		"newline":    "two\nlines",
+		"empty":      "",
+		"zeros_type": "text",
+		"number":     42.0,
+		"null_value": nil,
+	}
+	for column, value := range want {
+		if rows[0][column] != value {
+			t.Errorf("%s = %#v, want %#v", column, rows[0][column], value)
+		}
+	}
+}
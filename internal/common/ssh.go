@@ -0,0 +1,211 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// SSH access is implemented on top of the OpenSSH ssh and scp clients. They
+// always run in batch mode with strict host key checking, so a host key
+// must already be known and authentication can never stop at a prompt.
+
+var (
+	sshHostNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// Legacy scp passes remote paths through the remote shell, so only
+	// characters without special meaning there are accepted
+	sshRemotePathPattern = regexp.MustCompile(`^[A-Za-z0-9._/~@%+=:,-]+$`)
+)
+
+// ValidateSSHHost checks a host entry before it is saved
+func ValidateSSHHost(name string, host types.SSHHost) error {
+	if !sshHostNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid host name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	if host.Host == "" || strings.HasPrefix(host.Host, "-") || strings.ContainsAny(host.Host, " \t\r\n@") {
+		return fmt.Errorf("invalid host address %q", host.Host)
+	}
+	if host.Port < 0 || host.Port > 65535 {
+		return fmt.Errorf("invalid port %d", host.Port)
+	}
+	if strings.HasPrefix(host.User, "-") || strings.ContainsAny(host.User, " \t\r\n@") {
+		return fmt.Errorf("invalid user %q", host.User)
+	}
+	for _, file := range []string{host.IdentityFile, host.KnownHostsFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("cannot read %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// GetSSHHost returns the host entry called name
+func GetSSHHost(name string) (types.SSHHost, error) {
+	host, ok := Get().SSHHosts[name]
+	if !ok {
+		return host, WithErrorCode(ErrorNotFound, fmt.Errorf("unknown SSH host %s; add it with save_ssh_host", name))
+	}
+	return host, nil
+}
+
+// SaveSSHHost adds or replaces an SSH host entry
+func SaveSSHHost(name string, host types.SSHHost) error {
+	if err := ValidateSSHHost(name, host); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if instance == nil {
+		Initialize()
+	}
+
+	// Copied rather than modified in place, since Get shares the map
+	hosts := make(map[string]types.SSHHost, len(instance.SSHHosts)+1)
+	for existing, h := range instance.SSHHosts {
+		hosts[existing] = h
+	}
+	hosts[name] = host
+	instance.SSHHosts = hosts
+	saveToFile()
+	return nil
+}
+
+// RemoveSSHHost removes an SSH host entry
+func RemoveSSHHost(name string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if instance == nil {
+		Initialize()
+	}
+
+	if _, ok := instance.SSHHosts[name]; !ok {
+		return WithErrorCode(ErrorNotFound, fmt.Errorf("unknown SSH host %s", name))
+	}
+	hosts := make(map[string]types.SSHHost, len(instance.SSHHosts))
+	for existing, h := range instance.SSHHosts {
+		if existing != name {
+			hosts[existing] = h
+		}
+	}
+	instance.SSHHosts = hosts
+	saveToFile()
+	return nil
+}
+
+// sshOptions returns the options shared by ssh and scp; they differ only
+// in the port flag
+func sshOptions(host types.SSHHost, portFlag string) []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=yes", "-o", "ConnectTimeout=15"}
+	if host.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+host.KnownHostsFile)
+	}
+	if host.IdentityFile != "" {
+		args = append(args, "-i", host.IdentityFile, "-o", "IdentitiesOnly=yes")
+	}
+	if host.Port != 0 {
+		args = append(args, portFlag, strconv.Itoa(host.Port))
+	}
+	return args
+}
+
+// sshDestination returns user@host, or host when no user is configured
+func sshDestination(host types.SSHHost) string {
+	if host.User != "" {
+		return host.User + "@" + host.Host
+	}
+	return host.Host
+}
+
+// SSHCommand prepares a command that runs command on the named host. The
+// command is checked against the blocked-command list like local ones.
+func SSHCommand(ctx context.Context, name, command string) (*exec.Cmd, error) {
+	host, err := GetSSHHost(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("ssh command not found in PATH"))
+	}
+	if IsCommandBlocked(command) {
+		return nil, WithErrorCode(ErrorCommandBlocked, fmt.Errorf("command contains blocked patterns"))
+	}
+
+	args := append(sshOptions(host, "-p"), "--", sshDestination(host), command)
+	return exec.CommandContext(ctx, "ssh", args...), nil
+}
+
+// CopySSHFile copies between localPath and remotePath on the named host
+// with scp. upload copies the local file to the host, otherwise the remote
+// file is downloaded. The local path must be in an allowed directory.
+func CopySSHFile(ctx context.Context, name string, upload bool, localPath, remotePath string, recursive bool) error {
+	host, err := GetSSHHost(name)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("scp"); err != nil {
+		return WithErrorCode(ErrorNotFound, fmt.Errorf("scp command not found in PATH"))
+	}
+
+	localPath, err = filepath.Abs(localPath)
+	if err != nil {
+		return fmt.Errorf("invalid local path: %v", err)
+	}
+	if !IsPathAllowed(localPath) {
+		return WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to %s is not allowed", localPath))
+	}
+	if !sshRemotePathPattern.MatchString(remotePath) || strings.HasPrefix(remotePath, "-") {
+		return fmt.Errorf("invalid remote path %q: only letters, digits and ._/~@%%+=:,- are allowed", remotePath)
+	}
+
+	args := append([]string{"-q"}, sshOptions(host, "-P")...)
+	if recursive {
+		args = append(args, "-r")
+	}
+	remote := sshDestination(host) + ":" + remotePath
+	if upload {
+		args = append(args, "--", localPath, remote)
+	} else {
+		args = append(args, "--", remote, localPath)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "scp", args...)
+	cmd.Stderr = &stderr
+	startedAt := time.Now()
+	err = cmd.Run()
+	entry := types.CommandHistoryEntry{
+		Tool:       "ssh_copy_file",
+		Command:    "scp " + strings.Join(args, " "),
+		Shell:      "ssh:" + name,
+		StartedAt:  startedAt,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		ExitCode:   CommandExitCode(err),
+		Output:     stderr.String(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	RecordCommand(entry)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return WithErrorCode(ErrorTimeout, fmt.Errorf("copy timed out"))
+		}
+		return fmt.Errorf("%s", strings.TrimSpace(stderr.String()+" "+err.Error()))
+	}
+	return nil
+}
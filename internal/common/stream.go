@@ -0,0 +1,104 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// OutputStreamer splits command output into lines and hands them to emit
+// in batches, at most once per interval, so a chatty command does not
+// flood the client
+type OutputStreamer struct {
+	mu      sync.Mutex
+	emit    func(lines []string)
+	pending []string
+	partial map[string][]byte
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// NewOutputStreamer starts a streamer that flushes every interval until
+// Close is called
+func NewOutputStreamer(interval time.Duration, emit func(lines []string)) *OutputStreamer {
+	s := &OutputStreamer{
+		emit:    emit,
+		partial: make(map[string][]byte),
+		stop:    make(chan struct{}),
+	}
+
+	s.stopped.Add(1)
+	go func() {
+		defer s.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.flush()
+			}
+		}
+	}()
+	return s
+}
+
+// Writer returns a writer for one output stream. Lines from a stream with
+// a non-empty prefix, such as "[stderr] ", carry that prefix.
+func (s *OutputStreamer) Writer(prefix string) io.Writer {
+	return streamerWriter{s, prefix}
+}
+
+type streamerWriter struct {
+	streamer *OutputStreamer
+	prefix   string
+}
+
+func (w streamerWriter) Write(p []byte) (int, error) {
+	s := w.streamer
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := append(s.partial[w.prefix], p...)
+	for {
+		newline := bytes.IndexByte(data, '\n')
+		if newline == -1 {
+			break
+		}
+		s.pending = append(s.pending, w.prefix+string(bytes.TrimSuffix(data[:newline], []byte("\r"))))
+		data = data[newline+1:]
+	}
+	s.partial[w.prefix] = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (s *OutputStreamer) flush() {
+	s.mu.Lock()
+	lines := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(lines) > 0 {
+		s.emit(lines)
+	}
+}
+
+// Close stops the streamer and emits any remaining output, including a
+// final line without a newline
+func (s *OutputStreamer) Close() {
+	close(s.stop)
+	s.stopped.Wait()
+
+	s.mu.Lock()
+	for prefix, data := range s.partial {
+		if len(data) > 0 {
+			s.pending = append(s.pending, prefix+string(data))
+		}
+	}
+	s.partial = make(map[string][]byte)
+	s.mu.Unlock()
+
+	s.flush()
+}
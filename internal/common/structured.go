@@ -0,0 +1,764 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Structured file formats understood by the structured value tools
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// PathSegment is one step of a value path such as spec.containers[0].image
+type PathSegment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// ParseValuePath parses a dotted value path. Keys may be bare (a.b),
+// bracket-quoted (a["b.c"]) and array indexes are written as [n].
+// An empty path refers to the document root.
+func ParseValuePath(path string) ([]PathSegment, error) {
+	var segments []PathSegment
+	path = strings.TrimSpace(path)
+	i := 0
+
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			i++
+			if i >= len(path) || path[i] == '.' || path[i] == '[' {
+				return nil, fmt.Errorf("empty key at position %d", i)
+			}
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' at position %d", i)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				key := inner[1 : len(inner)-1]
+				if inner[0] == '"' {
+					unquoted, err := strconv.Unquote(inner)
+					if err != nil {
+						return nil, fmt.Errorf("invalid quoted key %s", inner)
+					}
+					key = unquoted
+				}
+				segments = append(segments, PathSegment{Key: key})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid array index [%s]", inner)
+				}
+				segments = append(segments, PathSegment{Index: index, IsIndex: true})
+			}
+			i += end + 1
+		default:
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			segments = append(segments, PathSegment{Key: path[i:end]})
+			i = end
+		}
+	}
+
+	return segments, nil
+}
+
+// FormatValuePath renders path segments back into canonical path syntax
+func FormatValuePath(segments []PathSegment) string {
+	var sb strings.Builder
+	for i, seg := range segments {
+		if seg.IsIndex {
+			sb.WriteString(fmt.Sprintf("[%d]", seg.Index))
+			continue
+		}
+		if seg.Key == "" || strings.ContainsAny(seg.Key, ".[]\"' ") {
+			sb.WriteString("[" + strconv.Quote(seg.Key) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(seg.Key)
+	}
+	return sb.String()
+}
+
+// DetectStructuredFormat resolves the format of a structured file from an
+// explicit override or the file extension
+func DetectStructuredFormat(path, override string) (string, error) {
+	format := strings.ToLower(override)
+	if format == "" || format == "auto" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			format = FormatJSON
+		case ".yaml", ".yml":
+			format = FormatYAML
+		case ".toml":
+			format = FormatTOML
+		default:
+			return "", fmt.Errorf("cannot detect format from extension %q; specify format", filepath.Ext(path))
+		}
+	}
+
+	switch format {
+	case FormatJSON, FormatYAML, FormatTOML:
+		return format, nil
+	case "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// GetStructuredValue parses content in the given format and returns the
+// value found at path. Objects keep their key order when marshalled.
+func GetStructuredValue(content []byte, format, path string) (interface{}, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	switch format {
+	case FormatJSON:
+		root, err := DecodeOrderedJSON(content)
+		if err != nil {
+			return nil, err
+		}
+		return lookupValue(root, segments)
+	case FormatYAML:
+		docs, err := decodeYAMLDocuments(content)
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			return nil, fmt.Errorf("document is empty")
+		}
+		node, err := lookupYAMLNode(docs[0], segments)
+		if err != nil {
+			return nil, err
+		}
+		return yamlNodeToValue(node)
+	case FormatTOML:
+		doc, err := parseTOML(string(content))
+		if err != nil {
+			return nil, err
+		}
+		return lookupValue(doc.root, segments)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// SetStructuredValue sets the value at path and returns the updated
+// content. JSON keeps key order and indentation, YAML keeps comments and
+// TOML is edited in place so the rest of the file is left untouched.
+func SetStructuredValue(content []byte, format, path string, value interface{}, createMissing bool) ([]byte, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path cannot be empty")
+	}
+
+	switch format {
+	case FormatJSON:
+		root, err := DecodeOrderedJSON(content)
+		if err != nil {
+			return nil, err
+		}
+		if err := setValue(&root, segments, value, createMissing); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		writeJSONValue(&buf, root, detectIndent(string(content), "  "), 0)
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case FormatYAML:
+		docs, err := decodeYAMLDocuments(content)
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			docs = append(docs, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}})
+		}
+		if err := setYAMLNode(docs[0], segments, valueToYAMLNode(value), createMissing); err != nil {
+			return nil, err
+		}
+		return encodeYAMLDocuments(docs, len(detectIndent(string(content), "  ")))
+	case FormatTOML:
+		updated, err := setTOMLValue(string(content), segments, value, createMissing)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(updated), nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// ParseStructuredInput decodes a tool argument as JSON, falling back to the
+// raw string when it is not valid JSON
+func ParseStructuredInput(raw string) interface{} {
+	value, err := DecodeOrderedJSON([]byte(raw))
+	if err != nil {
+		return raw
+	}
+	return value
+}
+
+// OrderedObject is a JSON object that remembers the order of its keys
+type OrderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedObject creates an empty ordered object
+func NewOrderedObject() *OrderedObject {
+	return &OrderedObject{values: make(map[string]interface{})}
+}
+
+// Get returns the value stored under key
+func (o *OrderedObject) Get(key string) (interface{}, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// Set stores value under key, appending the key if it is new
+func (o *OrderedObject) Set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Delete removes key, reporting whether it was present
+func (o *OrderedObject) Delete(key string) bool {
+	if _, exists := o.values[key]; !exists {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Keys returns the keys in insertion order
+func (o *OrderedObject) Keys() []string {
+	return o.keys
+}
+
+// Len returns the number of keys
+func (o *OrderedObject) Len() int {
+	return len(o.keys)
+}
+
+// MarshalJSON implements json.Marshaler preserving key order
+func (o *OrderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	writeJSONValue(&buf, o, "", 0)
+	return buf.Bytes(), nil
+}
+
+// DecodeOrderedJSON decodes a JSON document keeping object key order and
+// number literals intact
+func DecodeOrderedJSON(content []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	value, err := decodeOrderedJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+	return value, nil
+}
+
+func decodeOrderedJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := NewOrderedObject()
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected object key, got %v", keyToken)
+				}
+				value, err := decodeOrderedJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				obj.Set(key, value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return obj, nil
+		case '[':
+			arr := []interface{}{}
+			for decoder.More() {
+				value, err := decodeOrderedJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		default:
+			return nil, fmt.Errorf("unexpected delimiter %v", t)
+		}
+	default:
+		return token, nil
+	}
+}
+
+// writeJSONValue serialises a decoded value. An empty indent produces
+// compact output.
+func writeJSONValue(buf *bytes.Buffer, value interface{}, indent string, depth int) {
+	newline := func(level int) {
+		if indent != "" {
+			buf.WriteByte('\n')
+			buf.WriteString(strings.Repeat(indent, level))
+		}
+	}
+	separator := ":"
+	if indent != "" {
+		separator = ": "
+	}
+
+	switch v := value.(type) {
+	case *OrderedObject:
+		if v.Len() == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeJSONString(buf, key)
+			buf.WriteString(separator)
+			writeJSONValue(buf, v.values[key], indent, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeJSONValue(buf, item, indent, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte(']')
+	case string:
+		writeJSONString(buf, v)
+	case json.Number:
+		buf.WriteString(v.String())
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			buf.WriteString("null")
+			return
+		}
+		buf.Write(encoded)
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	var tmp bytes.Buffer
+	encoder := json.NewEncoder(&tmp)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	buf.Write(bytes.TrimRight(tmp.Bytes(), "\n"))
+}
+
+// detectIndent returns the indentation unit of the first indented line
+func detectIndent(content, fallback string) string {
+	for _, line := range SplitLines(content) {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || len(trimmed) == len(line) {
+			continue
+		}
+		return line[:len(line)-len(trimmed)]
+	}
+	return fallback
+}
+
+func lookupValue(root interface{}, segments []PathSegment) (interface{}, error) {
+	current := root
+	for i, seg := range segments {
+		at := FormatValuePath(segments[:i+1])
+		if seg.IsIndex {
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: parent is not an array", at)
+			}
+			if seg.Index >= len(arr) {
+				return nil, fmt.Errorf("%s: index out of range (length %d)", at, len(arr))
+			}
+			current = arr[seg.Index]
+			continue
+		}
+		obj, ok := current.(*OrderedObject)
+		if !ok {
+			return nil, fmt.Errorf("%s: parent is not an object", at)
+		}
+		value, ok := obj.Get(seg.Key)
+		if !ok {
+			return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("%s: key not found", at))
+		}
+		current = value
+	}
+	return current, nil
+}
+
+func setValue(current *interface{}, segments []PathSegment, value interface{}, createMissing bool) error {
+	seg := segments[0]
+	last := len(segments) == 1
+
+	if seg.IsIndex {
+		arr, ok := (*current).([]interface{})
+		if !ok {
+			return fmt.Errorf("cannot index into non-array value with [%d]", seg.Index)
+		}
+		if seg.Index > len(arr) || (seg.Index == len(arr) && !createMissing) {
+			return fmt.Errorf("index [%d] out of range (length %d)", seg.Index, len(arr))
+		}
+		if seg.Index == len(arr) {
+			arr = append(arr, newContainerFor(segments[1:]))
+			*current = arr
+		}
+		if last {
+			arr[seg.Index] = value
+			return nil
+		}
+		return setValue(&arr[seg.Index], segments[1:], value, createMissing)
+	}
+
+	obj, ok := (*current).(*OrderedObject)
+	if !ok {
+		return fmt.Errorf("cannot set key %q on non-object value", seg.Key)
+	}
+	if last {
+		obj.Set(seg.Key, value)
+		return nil
+	}
+	child, exists := obj.Get(seg.Key)
+	if !exists {
+		if !createMissing {
+			return WithErrorCode(ErrorNotFound, fmt.Errorf("key %q not found", seg.Key))
+		}
+		child = newContainerFor(segments[1:])
+	}
+	if err := setValue(&child, segments[1:], value, createMissing); err != nil {
+		return err
+	}
+	obj.Set(seg.Key, child)
+	return nil
+}
+
+// newContainerFor creates the intermediate container the next segment needs
+func newContainerFor(rest []PathSegment) interface{} {
+	if len(rest) > 0 && rest[0].IsIndex {
+		return []interface{}{}
+	}
+	return NewOrderedObject()
+}
+
+func decodeYAMLDocuments(content []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		doc := &yaml.Node{}
+		if err := decoder.Decode(doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func encodeYAMLDocuments(docs []*yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func resolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func lookupYAMLNode(doc *yaml.Node, segments []PathSegment) (*yaml.Node, error) {
+	current := doc
+	if current.Kind == yaml.DocumentNode && len(current.Content) > 0 {
+		current = current.Content[0]
+	}
+
+	for i, seg := range segments {
+		current = resolveYAMLAlias(current)
+		at := FormatValuePath(segments[:i+1])
+		if seg.IsIndex {
+			if current.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("%s: parent is not a sequence", at)
+			}
+			if seg.Index >= len(current.Content) {
+				return nil, fmt.Errorf("%s: index out of range (length %d)", at, len(current.Content))
+			}
+			current = current.Content[seg.Index]
+			continue
+		}
+		if current.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: parent is not a mapping", at)
+		}
+		found := false
+		for j := 0; j+1 < len(current.Content); j += 2 {
+			if current.Content[j].Value == seg.Key {
+				current = current.Content[j+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, WithErrorCode(ErrorNotFound, fmt.Errorf("%s: key not found", at))
+		}
+	}
+	return resolveYAMLAlias(current), nil
+}
+
+func setYAMLNode(doc *yaml.Node, segments []PathSegment, value *yaml.Node, createMissing bool) error {
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	current := doc.Content[0]
+
+	for i, seg := range segments {
+		current = resolveYAMLAlias(current)
+		last := i == len(segments)-1
+		at := FormatValuePath(segments[:i+1])
+
+		var slot **yaml.Node
+		if seg.IsIndex {
+			if current.Kind != yaml.SequenceNode {
+				return fmt.Errorf("%s: parent is not a sequence", at)
+			}
+			if seg.Index > len(current.Content) || (seg.Index == len(current.Content) && !createMissing) {
+				return fmt.Errorf("%s: index out of range (length %d)", at, len(current.Content))
+			}
+			if seg.Index == len(current.Content) {
+				current.Content = append(current.Content, newYAMLContainerFor(segments[i+1:]))
+			}
+			slot = &current.Content[seg.Index]
+		} else {
+			if current.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s: parent is not a mapping", at)
+			}
+			for j := 0; j+1 < len(current.Content); j += 2 {
+				if current.Content[j].Value == seg.Key {
+					slot = &current.Content[j+1]
+					break
+				}
+			}
+			if slot == nil {
+				if !createMissing {
+					return WithErrorCode(ErrorNotFound, fmt.Errorf("%s: key not found", at))
+				}
+				keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: seg.Key}
+				current.Content = append(current.Content, keyNode, newYAMLContainerFor(segments[i+1:]))
+				slot = &current.Content[len(current.Content)-1]
+			}
+		}
+
+		if last {
+			old := *slot
+			value.HeadComment = old.HeadComment
+			value.LineComment = old.LineComment
+			value.FootComment = old.FootComment
+			// Keep the anchor so aliases elsewhere still resolve, and the
+			// quoting style of a string replaced by a string
+			value.Anchor = old.Anchor
+			if old.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && old.Tag == value.Tag && value.Style == 0 {
+				value.Style = old.Style
+			}
+			*slot = value
+			return nil
+		}
+		current = *slot
+	}
+	return nil
+}
+
+// deleteYAMLNode removes the mapping key or sequence item at path
+func deleteYAMLNode(doc *yaml.Node, segments []PathSegment) error {
+	if len(segments) == 0 {
+		return fmt.Errorf("path cannot be empty")
+	}
+	parent, err := lookupYAMLNode(doc, segments[:len(segments)-1])
+	if err != nil {
+		return err
+	}
+
+	seg := segments[len(segments)-1]
+	at := FormatValuePath(segments)
+	if seg.IsIndex {
+		if parent.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: parent is not a sequence", at)
+		}
+		if seg.Index >= len(parent.Content) {
+			return fmt.Errorf("%s: index out of range (length %d)", at, len(parent.Content))
+		}
+		parent.Content = append(parent.Content[:seg.Index], parent.Content[seg.Index+1:]...)
+		return nil
+	}
+
+	if parent.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: parent is not a mapping", at)
+	}
+	for j := 0; j+1 < len(parent.Content); j += 2 {
+		if parent.Content[j].Value == seg.Key {
+			parent.Content = append(parent.Content[:j], parent.Content[j+2:]...)
+			return nil
+		}
+	}
+	return WithErrorCode(ErrorNotFound, fmt.Errorf("%s: key not found", at))
+}
+
+func newYAMLContainerFor(rest []PathSegment) *yaml.Node {
+	if len(rest) > 0 && rest[0].IsIndex {
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+// valueToYAMLNode converts a decoded JSON value into a YAML node tree
+func valueToYAMLNode(value interface{}) *yaml.Node {
+	switch v := value.(type) {
+	case *OrderedObject:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range v.keys {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				valueToYAMLNode(v.values[key]))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, valueToYAMLNode(item))
+		}
+		return node
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case json.Number:
+		tag := "!!int"
+		if _, err := v.Int64(); err != nil {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case string:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		if strings.Contains(v, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		return node
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+	}
+}
+
+// yamlNodeToValue converts a YAML node into ordered JSON-compatible values
+func yamlNodeToValue(node *yaml.Node) (interface{}, error) {
+	node = resolveYAMLAlias(node)
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeToValue(node.Content[0])
+	case yaml.MappingNode:
+		obj := NewOrderedObject()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := yamlNodeToValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(node.Content[i].Value, value)
+		}
+		return obj, nil
+	case yaml.SequenceNode:
+		arr := []interface{}{}
+		for _, item := range node.Content {
+			value, err := yamlNodeToValue(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+	default:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
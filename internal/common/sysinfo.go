@@ -0,0 +1,334 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/process"
+
+	"jarvis/internal/types"
+)
+
+// cpuSampleInterval is how long GetSystemInfo measures CPU usage for
+const cpuSampleInterval = 200 * time.Millisecond
+
+// ListProcesses returns the running processes ordered by PID. filter keeps
+// processes whose name or command line contains it, ignoring case. Thread
+// IDs are only listed with includeThreads, on platforms that expose them.
+func ListProcesses(ctx context.Context, filter string, includeThreads bool) ([]types.ProcessSummary, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter = strings.ToLower(filter)
+	var summaries []types.ProcessSummary
+	for _, proc := range procs {
+		summary, ok := summarizeProcess(ctx, proc, includeThreads)
+		if !ok {
+			continue
+		}
+		if filter != "" && !strings.Contains(strings.ToLower(summary.Name), filter) && !strings.Contains(strings.ToLower(summary.Command), filter) {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].PID < summaries[j].PID })
+	return summaries, nil
+}
+
+// GetProcessInfo returns detailed information about one process
+func GetProcessInfo(ctx context.Context, pid int) (*types.ProcessDetails, error) {
+	proc, err := process.NewProcessWithContext(ctx, int32(pid))
+	if err != nil {
+		if errors.Is(err, process.ErrorProcessNotRunning) {
+			return nil, fmt.Errorf("no process with PID %d", pid)
+		}
+		return nil, err
+	}
+
+	summary, ok := summarizeProcess(ctx, proc, true)
+	if !ok {
+		return nil, fmt.Errorf("no process with PID %d", pid)
+	}
+
+	details := &types.ProcessDetails{ProcessSummary: summary}
+	details.Executable, _ = proc.ExeWithContext(ctx)
+	details.WorkingDir, _ = proc.CwdWithContext(ctx)
+	details.Terminal, _ = proc.TerminalWithContext(ctx)
+	if nice, err := proc.NiceWithContext(ctx); err == nil {
+		details.Nice = int(nice)
+	}
+	if fds, err := proc.NumFDsWithContext(ctx); err == nil {
+		details.OpenFiles = int(fds)
+	}
+	if times, err := proc.TimesWithContext(ctx); err == nil && times != nil {
+		details.CPUUserSeconds = times.User
+		details.CPUSystemSeconds = times.System
+	}
+	if children, err := proc.ChildrenWithContext(ctx); err == nil {
+		for _, child := range children {
+			details.Children = append(details.Children, int(child.Pid))
+		}
+		sort.Ints(details.Children)
+	}
+	return details, nil
+}
+
+// summarizeProcess reads the fields list_processes reports. Fields the
+// caller may not read, such as another user's command line, are left
+// empty; ok is false when the process has exited.
+func summarizeProcess(ctx context.Context, proc *process.Process, includeThreads bool) (types.ProcessSummary, bool) {
+	name, err := proc.NameWithContext(ctx)
+	if err != nil {
+		return types.ProcessSummary{}, false
+	}
+
+	summary := types.ProcessSummary{PID: int(proc.Pid), Name: name}
+	if ppid, err := proc.PpidWithContext(ctx); err == nil {
+		summary.PPID = int(ppid)
+	}
+	summary.User, _ = proc.UsernameWithContext(ctx)
+	if status, err := proc.StatusWithContext(ctx); err == nil {
+		summary.Status = strings.Join(status, ",")
+	}
+	summary.CPUPercent, _ = proc.CPUPercentWithContext(ctx)
+	if percent, err := proc.MemoryPercentWithContext(ctx); err == nil {
+		summary.MemoryPercent = float64(percent)
+	}
+	if memory, err := proc.MemoryInfoWithContext(ctx); err == nil && memory != nil {
+		summary.RSS = memory.RSS
+		summary.VMS = memory.VMS
+	}
+	if threads, err := proc.NumThreadsWithContext(ctx); err == nil {
+		summary.NumThreads = int(threads)
+	}
+	if includeThreads {
+		if threads, err := proc.ThreadsWithContext(ctx); err == nil {
+			for tid := range threads {
+				summary.Threads = append(summary.Threads, int(tid))
+			}
+			sort.Ints(summary.Threads)
+		}
+	}
+	if created, err := proc.CreateTimeWithContext(ctx); err == nil && created > 0 {
+		startedAt := time.UnixMilli(created)
+		summary.StartedAt = &startedAt
+	}
+	summary.Command, _ = proc.CmdlineWithContext(ctx)
+	return summary, true
+}
+
+// GetSystemInfo describes the host, CPU, memory, swap and mounted disks.
+// Parts that cannot be read are reported as warnings rather than errors.
+func GetSystemInfo(ctx context.Context) *types.SystemInfo {
+	info := &types.SystemInfo{OS: runtime.GOOS, Architecture: runtime.GOARCH, Disks: []types.DiskUsage{}}
+	warn := func(part string, err error) {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("%s unavailable: %v", part, err))
+	}
+
+	if hostInfo, err := host.InfoWithContext(ctx); err == nil {
+		info.Hostname = hostInfo.Hostname
+		info.Platform = hostInfo.Platform
+		info.PlatformVersion = hostInfo.PlatformVersion
+		info.KernelVersion = hostInfo.KernelVersion
+		if hostInfo.KernelArch != "" {
+			info.Architecture = hostInfo.KernelArch
+		}
+		info.UptimeSeconds = hostInfo.Uptime
+		info.Processes = hostInfo.Procs
+		if hostInfo.BootTime > 0 {
+			bootTime := time.Unix(int64(hostInfo.BootTime), 0)
+			info.BootTime = &bootTime
+		}
+	} else {
+		warn("host information", err)
+	}
+
+	if cores, err := cpu.CountsWithContext(ctx, false); err == nil {
+		info.CPU.PhysicalCores = cores
+	}
+	if cores, err := cpu.CountsWithContext(ctx, true); err == nil {
+		info.CPU.LogicalCores = cores
+	} else {
+		info.CPU.LogicalCores = runtime.NumCPU()
+	}
+	if cpus, err := cpu.InfoWithContext(ctx); err == nil && len(cpus) > 0 {
+		info.CPU.Model = strings.TrimSpace(cpus[0].ModelName)
+		info.CPU.MHz = cpus[0].Mhz
+	}
+	if usage, err := cpu.PercentWithContext(ctx, cpuSampleInterval, false); err == nil && len(usage) > 0 {
+		info.CPU.UsagePercent = usage[0]
+	} else if err != nil {
+		warn("CPU usage", err)
+	}
+
+	if avg, err := load.AvgWithContext(ctx); err == nil {
+		info.Load = &types.LoadAverage{Load1: avg.Load1, Load5: avg.Load5, Load15: avg.Load15}
+	} else if runtime.GOOS != "windows" {
+		warn("load average", err)
+	}
+
+	if memory, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		info.Memory = types.MemoryUsage{Total: memory.Total, Used: memory.Used, Available: memory.Available, UsedPercent: memory.UsedPercent}
+	} else {
+		warn("memory", err)
+	}
+	if swap, err := mem.SwapMemoryWithContext(ctx); err == nil {
+		info.Swap = types.MemoryUsage{Total: swap.Total, Used: swap.Used, Available: swap.Free, UsedPercent: swap.UsedPercent}
+	} else {
+		warn("swap", err)
+	}
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		warn("disks", err)
+	}
+	seen := make(map[string]bool)
+	for _, partition := range partitions {
+		if seen[partition.Mountpoint] {
+			continue
+		}
+		seen[partition.Mountpoint] = true
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		info.Disks = append(info.Disks, types.DiskUsage{
+			Device:      partition.Device,
+			Mountpoint:  partition.Mountpoint,
+			Filesystem:  partition.Fstype,
+			Total:       usage.Total,
+			Used:        usage.Used,
+			Free:        usage.Free,
+			UsedPercent: usage.UsedPercent,
+		})
+	}
+
+	info.GPUs = collectGPUs(ctx, warn)
+	info.Temperatures = collectTemperatures(ctx, warn)
+	info.Batteries = collectBatteries(ctx, warn)
+
+	return info
+}
+
+// GetProcessTree returns pid with its descendants. depth limits how many
+// levels of children are included; <= 0 includes all of them.
+func GetProcessTree(ctx context.Context, pid int, depth int) (*types.ProcessNode, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *process.Process
+	children := make(map[int32][]*process.Process)
+	for _, proc := range procs {
+		if int(proc.Pid) == pid {
+			root = proc
+		}
+		if ppid, err := proc.PpidWithContext(ctx); err == nil && ppid != proc.Pid {
+			children[ppid] = append(children[ppid], proc)
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no process with PID %d", pid)
+	}
+
+	// PIDs are tracked so a reused PID can never make the tree cyclic
+	seen := make(map[int32]bool)
+	var build func(proc *process.Process, level int) *types.ProcessNode
+	build = func(proc *process.Process, level int) *types.ProcessNode {
+		seen[proc.Pid] = true
+		summary, ok := summarizeProcess(ctx, proc, false)
+		if !ok {
+			return nil
+		}
+		node := &types.ProcessNode{ProcessSummary: summary}
+		if depth > 0 && level >= depth {
+			return node
+		}
+		kids := children[proc.Pid]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].Pid < kids[j].Pid })
+		for _, child := range kids {
+			if seen[child.Pid] {
+				continue
+			}
+			if childNode := build(child, level+1); childNode != nil {
+				node.Children = append(node.Children, childNode)
+			}
+		}
+		return node
+	}
+
+	tree := build(root, 0)
+	if tree == nil {
+		return nil, fmt.Errorf("no process with PID %d", pid)
+	}
+	return tree, nil
+}
+
+// KillProcessTree terminates pid and all of its descendants, or kills them
+// with force. The tree is read before any process is stopped, so children
+// reparented when their parent exits are still reached; the parent goes
+// first so it cannot start replacements. On Unix the process group led by
+// pid is signalled as well, catching children orphaned earlier.
+func KillProcessTree(ctx context.Context, pid int, force bool) ([]int, error) {
+	tree, err := GetProcessTree(ctx, pid, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	var collect func(node *types.ProcessNode)
+	collect = func(node *types.ProcessNode) {
+		pids = append(pids, node.PID)
+		for _, child := range node.Children {
+			collect(child)
+		}
+	}
+	collect(tree)
+	groupLeader := isProcessGroupLeader(pid)
+
+	var killed []int
+	var problems []string
+	for _, target := range pids {
+		proc, err := process.NewProcessWithContext(ctx, int32(target))
+		if err != nil {
+			// Already exited
+			continue
+		}
+		if force {
+			err = proc.KillWithContext(ctx)
+		} else {
+			err = proc.TerminateWithContext(ctx)
+		}
+		if err != nil {
+			if exists, _ := process.PidExistsWithContext(ctx, int32(target)); exists {
+				problems = append(problems, fmt.Sprintf("%d: %v", target, err))
+			}
+			continue
+		}
+		killed = append(killed, target)
+	}
+	if groupLeader {
+		if err := signalProcessGroup(pid, force); err != nil {
+			problems = append(problems, fmt.Sprintf("process group %d: %v", pid, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return killed, fmt.Errorf("failed to stop %s", strings.Join(problems, "; "))
+	}
+	return killed, nil
+}
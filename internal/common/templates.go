@@ -0,0 +1,236 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// Command template parameter types
+var TemplateParameterTypes = []string{"string", "int", "number", "bool", "enum", "path"}
+
+var (
+	templateNamePattern        = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	templateParameterPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	templatePlaceholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// ValidateCommandTemplate checks that a template is well formed: every
+// placeholder is a declared parameter and every parameter is used
+func ValidateCommandTemplate(name string, template types.CommandTemplate) error {
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	if strings.TrimSpace(template.Command) == "" {
+		return fmt.Errorf("template command cannot be empty")
+	}
+	if template.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeoutSeconds cannot be negative")
+	}
+
+	declared := make(map[string]types.TemplateParameter)
+	for _, param := range template.Parameters {
+		if !templateParameterPattern.MatchString(param.Name) {
+			return fmt.Errorf("invalid parameter name %q", param.Name)
+		}
+		if _, exists := declared[param.Name]; exists {
+			return fmt.Errorf("parameter %s is declared twice", param.Name)
+		}
+		if !slices.Contains(TemplateParameterTypes, param.Type) {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("parameter %s has unknown type %q (use %s)", param.Name, param.Type, strings.Join(TemplateParameterTypes, ", ")))
+		}
+		if param.Type == "enum" && len(param.Values) == 0 {
+			return fmt.Errorf("enum parameter %s needs values", param.Name)
+		}
+		if param.Pattern != "" {
+			if param.Type != "string" {
+				return fmt.Errorf("parameter %s: pattern only applies to string parameters", param.Name)
+			}
+			if _, err := regexp.Compile(param.Pattern); err != nil {
+				return fmt.Errorf("parameter %s has an invalid pattern: %v", param.Name, err)
+			}
+		}
+		if param.Default != "" {
+			if _, err := checkTemplateValue(param, param.Default); err != nil {
+				return fmt.Errorf("default of parameter %s: %v", param.Name, err)
+			}
+		}
+		declared[param.Name] = param
+	}
+
+	used := make(map[string]bool)
+	for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(template.Command, -1) {
+		if _, ok := declared[match[1]]; !ok {
+			return fmt.Errorf("placeholder {%s} is not a declared parameter", match[1])
+		}
+		used[match[1]] = true
+	}
+	for name := range declared {
+		if !used[name] {
+			return fmt.Errorf("parameter %s is not used in the command", name)
+		}
+	}
+	return nil
+}
+
+// checkTemplateValue validates a parameter value against its type and
+// returns it in canonical form
+func checkTemplateValue(param types.TemplateParameter, value string) (string, error) {
+	switch param.Type {
+	case "int":
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", value)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "number":
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("%q is not true or false", value)
+		}
+		return strconv.FormatBool(b), nil
+	case "enum":
+		if !slices.Contains(param.Values, value) {
+			return "", fmt.Errorf("%q is not one of %s", value, strings.Join(param.Values, ", "))
+		}
+		return value, nil
+	case "path":
+		absPath, err := filepath.Abs(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid path %q", value)
+		}
+		if !IsPathAllowed(absPath) {
+			return "", WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to %s is not allowed", absPath))
+		}
+		return absPath, nil
+	default:
+		if param.Pattern != "" && !regexp.MustCompile(`^(?:`+param.Pattern+`)$`).MatchString(value) {
+			return "", fmt.Errorf("%q does not match %s", value, param.Pattern)
+		}
+		return value, nil
+	}
+}
+
+// RenderCommandTemplate substitutes validated, shell-quoted arguments into
+// a template's command. Missing optional parameters take their default.
+func RenderCommandTemplate(template types.CommandTemplate, shell string, arguments map[string]string) (string, error) {
+	values := make(map[string]string)
+	for _, param := range template.Parameters {
+		value, ok := arguments[param.Name]
+		if !ok {
+			if param.Required {
+				return "", fmt.Errorf("missing required parameter %s", param.Name)
+			}
+			value = param.Default
+		}
+		// An optional parameter without a default is left empty
+		if ok || value != "" {
+			checked, err := checkTemplateValue(param, value)
+			if err != nil {
+				return "", fmt.Errorf("parameter %s: %v", param.Name, err)
+			}
+			value = checked
+		}
+		quoted, err := QuoteShellArgument(shell, value)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s: %v", param.Name, err)
+		}
+		values[param.Name] = quoted
+	}
+	for name := range arguments {
+		if _, ok := values[name]; !ok {
+			return "", WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown parameter %s", name))
+		}
+	}
+
+	// Placeholders are filled in the template text only, so that neither
+	// parameter values nor secret values are taken for placeholders or
+	// {{secret:NAME}} references
+	fill := func(text string) string {
+		return templatePlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			return values[placeholder[1:len(placeholder)-1]]
+		})
+	}
+	var command strings.Builder
+	last := 0
+	for _, loc := range secretReferencePattern.FindAllStringIndex(template.Command, -1) {
+		secret, err := ExpandSecrets(template.Command[loc[0]:loc[1]], func(value string) (string, error) {
+			return QuoteShellArgument(shell, value)
+		})
+		if err != nil {
+			return "", err
+		}
+		command.WriteString(fill(template.Command[last:loc[0]]))
+		command.WriteString(secret)
+		last = loc[1]
+	}
+	command.WriteString(fill(template.Command[last:]))
+	return command.String(), nil
+}
+
+// GetCommandTemplate returns the template called name
+func GetCommandTemplate(name string) (types.CommandTemplate, error) {
+	template, ok := Get().CommandTemplates[name]
+	if !ok {
+		return template, WithErrorCode(ErrorNotFound, fmt.Errorf("command template not found: %s", name))
+	}
+	return template, nil
+}
+
+// SaveCommandTemplate adds or replaces a command template
+func SaveCommandTemplate(name string, template types.CommandTemplate) error {
+	if err := ValidateCommandTemplate(name, template); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if instance == nil {
+		Initialize()
+	}
+
+	// Copied rather than modified in place, since Get shares the map
+	templates := make(map[string]types.CommandTemplate, len(instance.CommandTemplates)+1)
+	for existing, t := range instance.CommandTemplates {
+		templates[existing] = t
+	}
+	templates[name] = template
+	instance.CommandTemplates = templates
+	saveToFile()
+	return nil
+}
+
+// DeleteCommandTemplate removes a command template
+func DeleteCommandTemplate(name string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if instance == nil {
+		Initialize()
+	}
+
+	if _, ok := instance.CommandTemplates[name]; !ok {
+		return WithErrorCode(ErrorNotFound, fmt.Errorf("command template not found: %s", name))
+	}
+	templates := make(map[string]types.CommandTemplate, len(instance.CommandTemplates))
+	for existing, t := range instance.CommandTemplates {
+		if existing != name {
+			templates[existing] = t
+		}
+	}
+	instance.CommandTemplates = templates
+	saveToFile()
+	return nil
+}
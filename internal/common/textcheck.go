@@ -0,0 +1,334 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"jarvis/internal/types"
+)
+
+// TextCheckRules lists the rules CheckText supports
+var TextCheckRules = []string{"spelling", "repeated_word", "trailing_whitespace", "long_line", "double_space", "weasel_words"}
+
+// systemWordList is used for spelling when neither aspell nor hunspell is
+// installed
+const systemWordList = "/usr/share/dict/words"
+
+var (
+	checkWordPattern          = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+	inlineCodePattern         = regexp.MustCompile("`[^`]*`")
+	markdownLinkTargetPattern = regexp.MustCompile(`\]\([^)]*\)`)
+	htmlTagPattern            = regexp.MustCompile(`<[^>]+>`)
+	proseURLPattern           = regexp.MustCompile(`(?:https?|ftp)://\S+|www\.\S+|\S+@\S+\.\w+`)
+	doubleSpacePattern        = regexp.MustCompile(`\S( {2,})\S`)
+
+	weaselWords = map[string]bool{
+		"actually": true, "basically": true, "clearly": true, "easily": true, "just": true,
+		"obviously": true, "quite": true, "really": true, "simply": true, "very": true,
+	}
+)
+
+// TextCheckOptions controls CheckText
+type TextCheckOptions struct {
+	Rules         []string // empty runs every rule
+	Dictionary    []string // extra words accepted by the spell checker
+	Language      string   // spell checker language, e.g. en_US
+	MaxLineLength int      // 0 disables long_line
+	Markdown      bool     // skip front matter, code, link targets and HTML
+}
+
+// ReadDictionary reads a project dictionary file with one word per line;
+// blank lines and lines starting with # are ignored
+func ReadDictionary(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word != "" && !strings.HasPrefix(word, "#") {
+			words = append(words, word)
+		}
+	}
+	return words, scanner.Err()
+}
+
+// CheckText runs spelling and style rules over content and returns the
+// findings ordered by position, plus notes about checks that could not run
+func CheckText(ctx context.Context, content string, opts TextCheckOptions) ([]types.TextFinding, []string, error) {
+	enabled := make(map[string]bool)
+	if len(opts.Rules) == 0 {
+		for _, rule := range TextCheckRules {
+			enabled[rule] = true
+		}
+	}
+	for _, rule := range opts.Rules {
+		rule = strings.TrimSpace(rule)
+		if !slices.Contains(TextCheckRules, rule) {
+			return nil, nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown rule: %s (available: %s)", rule, strings.Join(TextCheckRules, ", ")))
+		}
+		enabled[rule] = true
+	}
+
+	lines := SplitLines(content)
+	prose := maskProse(lines, opts.Markdown)
+
+	var findings []types.TextFinding
+	add := func(line, start int, rule, text, message string) {
+		findings = append(findings, types.TextFinding{
+			Line:    line + 1,
+			Column:  utf8.RuneCountInString(lines[line][:start]) + 1,
+			Rule:    rule,
+			Text:    text,
+			Message: message,
+		})
+	}
+
+	type wordAt struct {
+		line, start int
+		word        string
+	}
+	var spellingCandidates []wordAt
+
+	for i, line := range lines {
+		masked := prose[i]
+
+		if enabled["trailing_whitespace"] {
+			if trimmed := strings.TrimRight(line, " \t"); len(trimmed) < len(line) {
+				add(i, len(trimmed), "trailing_whitespace", line[len(trimmed):], "trailing whitespace")
+			}
+		}
+
+		if strings.TrimSpace(masked) == "" {
+			continue
+		}
+
+		if enabled["long_line"] && opts.MaxLineLength > 0 {
+			if length := utf8.RuneCountInString(line); length > opts.MaxLineLength {
+				add(i, 0, "long_line", "", fmt.Sprintf("line is %d characters long (limit %d)", length, opts.MaxLineLength))
+			}
+		}
+
+		if enabled["double_space"] && !strings.HasPrefix(strings.TrimSpace(masked), "|") {
+			for _, match := range doubleSpacePattern.FindAllStringSubmatchIndex(masked, -1) {
+				// Masked code and URLs also read as spaces; only report real ones
+				if strings.Trim(line[match[2]:match[3]], " ") != "" {
+					continue
+				}
+				add(i, match[2], "double_space", line[match[2]:match[3]], "multiple spaces between words")
+			}
+		}
+
+		previous, previousEnd := "", 0
+		for _, match := range checkWordPattern.FindAllStringIndex(masked, -1) {
+			word := masked[match[0]:match[1]]
+			lower := strings.ToLower(word)
+
+			if enabled["repeated_word"] && lower == previous && strings.TrimSpace(masked[previousEnd:match[0]]) == "" {
+				add(i, match[0], "repeated_word", word, fmt.Sprintf("repeated word %q", word))
+			}
+			previous, previousEnd = lower, match[1]
+
+			if enabled["weasel_words"] && weaselWords[lower] {
+				add(i, match[0], "weasel_words", word, fmt.Sprintf("%q adds little; consider removing it", word))
+			}
+
+			if enabled["spelling"] && isSpellCheckable(word) {
+				spellingCandidates = append(spellingCandidates, wordAt{line: i, start: match[0], word: word})
+			}
+		}
+	}
+
+	var notes []string
+	if enabled["spelling"] && len(spellingCandidates) > 0 {
+		accepted := make(map[string]bool)
+		for _, word := range opts.Dictionary {
+			accepted[strings.ToLower(word)] = true
+		}
+
+		seen := make(map[string]bool)
+		var words []string
+		for _, candidate := range spellingCandidates {
+			if !accepted[strings.ToLower(candidate.word)] && !seen[candidate.word] {
+				seen[candidate.word] = true
+				words = append(words, candidate.word)
+			}
+		}
+
+		misspelled, source, err := misspelledWords(ctx, words, opts.Language)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("Spelling not checked: %v", err))
+		} else {
+			notes = append(notes, fmt.Sprintf("Spelling checked with %s", source))
+			for _, candidate := range spellingCandidates {
+				suggestions, wrong := misspelled[candidate.word]
+				if !wrong || accepted[strings.ToLower(candidate.word)] {
+					continue
+				}
+				add(candidate.line, candidate.start, "spelling", candidate.word, fmt.Sprintf("possible misspelling %q", candidate.word))
+				findings[len(findings)-1].Suggestions = suggestions
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings, notes, nil
+}
+
+// maskProse blanks out everything in lines that is not prose, keeping byte
+// offsets intact so findings point at the original text
+func maskProse(lines []string, markdown bool) []string {
+	blank := func(match string) string { return strings.Repeat(" ", len(match)) }
+
+	masked := make([]string, len(lines))
+	start := 0
+	if markdown {
+		start = markdownBodyStart(lines)
+	}
+
+	fence := ""
+	for i, line := range lines {
+		if i < start {
+			continue
+		}
+		if markdown {
+			trimmed := strings.TrimLeft(line, " ")
+			if fence != "" {
+				if strings.HasPrefix(trimmed, fence) && strings.TrimRight(trimmed, fence[:1]+" \t") == "" {
+					fence = ""
+				}
+				continue
+			}
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+				continue
+			}
+			line = inlineCodePattern.ReplaceAllStringFunc(line, blank)
+			line = markdownLinkTargetPattern.ReplaceAllStringFunc(line, func(match string) string {
+				return "]" + blank(match[1:])
+			})
+			line = htmlTagPattern.ReplaceAllStringFunc(line, blank)
+		}
+		masked[i] = proseURLPattern.ReplaceAllStringFunc(line, blank)
+	}
+	return masked
+}
+
+// isSpellCheckable skips short words, acronyms and identifiers such as
+// camelCase names
+func isSpellCheckable(word string) bool {
+	if utf8.RuneCountInString(word) < 2 {
+		return false
+	}
+	for i, r := range word {
+		if i > 0 && unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// misspelledWords returns the words the spell checker rejects, each with up
+// to five suggestions, and the name of the checker used. aspell and
+// hunspell are driven through their ispell-compatible pipe mode; the system
+// word list is the fallback.
+func misspelledWords(ctx context.Context, words []string, language string) (map[string][]string, string, error) {
+	for _, checker := range []string{"aspell", "hunspell"} {
+		binary, err := exec.LookPath(checker)
+		if err != nil {
+			continue
+		}
+
+		args := []string{"-a"}
+		if language != "" {
+			if checker == "aspell" {
+				args = append(args, "--lang="+language)
+			} else {
+				args = append(args, "-d", language)
+			}
+		}
+
+		var input strings.Builder
+		for _, word := range words {
+			// ^ stops a word from being read as a pipe-mode command
+			input.WriteString("^" + word + "\n")
+		}
+
+		cmd := exec.CommandContext(ctx, binary, args...)
+		cmd.Stdin = strings.NewReader(input.String())
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, checker, fmt.Errorf("%s failed: %v", checker, err)
+		}
+		return parseIspellOutput(string(output)), checker, nil
+	}
+
+	file, err := os.Open(systemWordList)
+	if err != nil {
+		return nil, "", fmt.Errorf("no spell checker found (install aspell or hunspell)")
+	}
+	defer file.Close()
+
+	known := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		known[strings.ToLower(strings.TrimSpace(scanner.Text()))] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, systemWordList, err
+	}
+
+	misspelled := make(map[string][]string)
+	for _, word := range words {
+		lower := strings.ToLower(strings.ReplaceAll(word, "’", "'"))
+		if !known[lower] && !known[strings.TrimSuffix(lower, "'s")] {
+			misspelled[word] = nil
+		}
+	}
+	return misspelled, systemWordList, nil
+}
+
+// parseIspellOutput reads ispell pipe-mode results: "& word count offset:
+// suggestions" and "? word ..." lines carry suggestions, "# word offset"
+// lines have none
+func parseIspellOutput(output string) map[string][]string {
+	misspelled := make(map[string][]string)
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || (line[0] != '&' && line[0] != '?' && line[0] != '#') {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		var suggestions []string
+		if _, list, ok := strings.Cut(line, ": "); ok && line[0] != '#' {
+			for _, suggestion := range strings.Split(list, ", ") {
+				if suggestion = strings.TrimSpace(suggestion); suggestion != "" && len(suggestions) < 5 {
+					suggestions = append(suggestions, suggestion)
+				}
+			}
+		}
+		misspelled[fields[1]] = suggestions
+	}
+	return misspelled
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// Timeout categories, each with a default and a maximum timeout setting
+const (
+	TimeoutCommand  = "command"
+	TimeoutScript   = "script"
+	TimeoutFetch    = "fetch"
+	TimeoutDownload = "download"
+)
+
+// TimeoutCategories lists the timeout categories
+var TimeoutCategories = []string{TimeoutCommand, TimeoutScript, TimeoutFetch, TimeoutDownload}
+
+// timeoutSettings returns the default and maximum timeout of a category in
+// seconds, and the name of the maximum's setting
+func timeoutSettings(config *types.ServerConfig, category string) (int, int, string) {
+	switch category {
+	case TimeoutScript:
+		return config.ScriptTimeoutSeconds, config.MaxScriptTimeoutSeconds, "maxScriptTimeoutSeconds"
+	case TimeoutFetch:
+		return config.FetchTimeoutSeconds, config.MaxFetchTimeoutSeconds, "maxFetchTimeoutSeconds"
+	case TimeoutDownload:
+		return config.DownloadTimeoutSeconds, config.MaxDownloadTimeoutSeconds, "maxDownloadTimeoutSeconds"
+	default:
+		return config.CommandTimeoutSeconds, config.MaxCommandTimeoutSeconds, "maxCommandTimeoutSeconds"
+	}
+}
+
+// validateTimeouts checks that no default timeout is over its maximum
+func validateTimeouts(config *types.ServerConfig) error {
+	for _, category := range TimeoutCategories {
+		defaultSeconds, maxSeconds, maxKey := timeoutSettings(config, category)
+		if maxSeconds > 0 && defaultSeconds > maxSeconds {
+			return WithErrorCode(ErrorValidationFailed, fmt.Errorf("the default %s timeout of %d seconds exceeds %s of %d", category, defaultSeconds, maxKey, maxSeconds))
+		}
+	}
+	return nil
+}
+
+// DefaultTimeout returns the configured timeout of calls in a category
+// that do not give one
+func DefaultTimeout(category string) time.Duration {
+	defaultSeconds, _, _ := timeoutSettings(Get(), category)
+	return time.Duration(defaultSeconds) * time.Second
+}
+
+// ResolveTimeout returns the timeout of a call in a category: the seconds
+// it asked for, or the category's default when it asked for none. A
+// timeout over the category's maximum is refused.
+func ResolveTimeout(category string, seconds float64) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, fmt.Errorf("timeout cannot be negative")
+	}
+	if seconds == 0 {
+		return DefaultTimeout(category), nil
+	}
+	_, maxSeconds, maxKey := timeoutSettings(Get(), category)
+	if maxSeconds > 0 && seconds > float64(maxSeconds) {
+		return 0, WithErrorCode(ErrorValidationFailed, fmt.Errorf("timeout of %g seconds exceeds %s of %d", seconds, maxKey, maxSeconds))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,804 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlDocument is a parsed TOML file together with the byte positions
+// needed to edit values in place
+type tomlDocument struct {
+	src    string
+	root   *OrderedObject
+	spans  map[string][2]int          // value path -> byte span of the value
+	tables map[string]tomlInsertPoint // table path -> where new keys go
+}
+
+// tomlInsertPoint describes where a new key of a table is written. Keys of
+// implicit tables created by dotted keys are written into the enclosing
+// section with a prefix.
+type tomlInsertPoint struct {
+	offset int
+	prefix string
+	dotted bool
+	inline bool
+}
+
+type tomlParser struct {
+	doc *tomlDocument
+	pos int
+}
+
+var (
+	tomlBareKeyPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlDateTimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}|^\d{2}:\d{2}`)
+)
+
+func parseTOML(src string) (*tomlDocument, error) {
+	doc := &tomlDocument{
+		src:    src,
+		root:   NewOrderedObject(),
+		spans:  make(map[string][2]int),
+		tables: map[string]tomlInsertPoint{"": {offset: 0}},
+	}
+	p := &tomlParser{doc: doc}
+
+	section := []PathSegment{}
+	sectionObj := doc.root
+
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			break
+		}
+
+		if p.peek() == '[' {
+			arrayTable := strings.HasPrefix(src[p.pos:], "[[")
+			if arrayTable {
+				p.pos += 2
+			} else {
+				p.pos++
+			}
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			p.skipBlank(false)
+			closing := "]"
+			if arrayTable {
+				closing = "]]"
+			}
+			if !strings.HasPrefix(src[p.pos:], closing) {
+				return nil, p.errorf("expected %q after table name", closing)
+			}
+			p.pos += len(closing)
+			if err := p.finishLine(); err != nil {
+				return nil, err
+			}
+
+			section, sectionObj, err = p.openTable(keys, arrayTable)
+			if err != nil {
+				return nil, err
+			}
+			doc.tables[FormatValuePath(section)] = tomlInsertPoint{offset: p.pos}
+			continue
+		}
+
+		keys, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.peek() != '=' {
+			return nil, p.errorf("expected '=' after key")
+		}
+		p.pos++
+		p.skipBlank(false)
+
+		path := append(append([]PathSegment{}, section...), keys...)
+		value, err := p.parseValue(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.finishLine(); err != nil {
+			return nil, err
+		}
+
+		// Walk dotted keys, creating implicit tables
+		obj := sectionObj
+		for i, key := range keys[:len(keys)-1] {
+			child, ok := obj.Get(key.Key)
+			if !ok {
+				child = NewOrderedObject()
+				obj.Set(key.Key, child)
+			}
+			childObj, ok := child.(*OrderedObject)
+			if !ok {
+				return nil, p.errorf("key %q is not a table", key.Key)
+			}
+			tablePath := FormatValuePath(path[:len(section)+i+1])
+			if existing, ok := doc.tables[tablePath]; !ok || existing.dotted {
+				doc.tables[tablePath] = tomlInsertPoint{offset: p.pos, prefix: tomlKeyPrefix(keys[:i+1]), dotted: true}
+			}
+			obj = childObj
+		}
+
+		lastKey := keys[len(keys)-1].Key
+		if _, exists := obj.Get(lastKey); exists {
+			return nil, p.errorf("duplicate key %q", FormatValuePath(path))
+		}
+		obj.Set(lastKey, value)
+
+		sectionPath := FormatValuePath(section)
+		point := doc.tables[sectionPath]
+		point.offset = p.pos
+		doc.tables[sectionPath] = point
+	}
+
+	return doc, nil
+}
+
+// openTable resolves a [table] or [[array.table]] header
+func (p *tomlParser) openTable(keys []PathSegment, arrayTable bool) ([]PathSegment, *OrderedObject, error) {
+	path := []PathSegment{}
+	obj := p.doc.root
+
+	for i, key := range keys {
+		last := i == len(keys)-1
+		path = append(path, key)
+		child, ok := obj.Get(key.Key)
+
+		if last && arrayTable {
+			if !ok {
+				child = []interface{}{}
+			}
+			arr, isArr := child.([]interface{})
+			if !isArr {
+				return nil, nil, p.errorf("key %q is not an array of tables", key.Key)
+			}
+			table := NewOrderedObject()
+			obj.Set(key.Key, append(arr, table))
+			path = append(path, PathSegment{Index: len(arr), IsIndex: true})
+			return path, table, nil
+		}
+
+		if !ok {
+			child = NewOrderedObject()
+			obj.Set(key.Key, child)
+		}
+		switch c := child.(type) {
+		case *OrderedObject:
+			obj = c
+		case []interface{}:
+			if len(c) == 0 {
+				return nil, nil, p.errorf("key %q is an empty array", key.Key)
+			}
+			table, isTable := c[len(c)-1].(*OrderedObject)
+			if !isTable {
+				return nil, nil, p.errorf("key %q is not a table", key.Key)
+			}
+			path = append(path, PathSegment{Index: len(c) - 1, IsIndex: true})
+			obj = table
+		default:
+			return nil, nil, p.errorf("key %q is not a table", key.Key)
+		}
+	}
+
+	return path, obj, nil
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.doc.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.doc.src[p.pos]
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.doc.src[:p.pos], "\n") + 1
+	return fmt.Errorf("invalid TOML at line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips spaces and tabs, and with newlines also line breaks and
+// comments
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case newlines && (c == '\n' || c == '\r'):
+			p.pos++
+		case newlines && c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// finishLine consumes trailing whitespace, an optional comment and the
+// line break
+func (p *tomlParser) finishLine() error {
+	p.skipBlank(false)
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *tomlParser) parseKey() ([]PathSegment, error) {
+	var keys []PathSegment
+	for {
+		p.skipBlank(false)
+		var key string
+		switch p.peek() {
+		case '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.eof() && isTOMLBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected key")
+			}
+			key = p.doc.src[start:p.pos]
+		}
+		keys = append(keys, PathSegment{Key: key})
+
+		p.skipBlank(false)
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseValue(path []PathSegment) (interface{}, error) {
+	start := p.pos
+	value, err := p.parseRawValue(path)
+	if err != nil {
+		return nil, err
+	}
+	p.doc.spans[FormatValuePath(path)] = [2]int{start, p.pos}
+	return value, nil
+}
+
+func (p *tomlParser) parseRawValue(path []PathSegment) (interface{}, error) {
+	src := p.doc.src
+	switch c := p.peek(); {
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		p.pos++
+		arr := []interface{}{}
+		for {
+			p.skipBlank(true)
+			if p.peek() == ']' {
+				p.pos++
+				return arr, nil
+			}
+			itemPath := append(append([]PathSegment{}, path...), PathSegment{Index: len(arr), IsIndex: true})
+			item, err := p.parseValue(itemPath)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+			p.skipBlank(true)
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case ']':
+				p.pos++
+				return arr, nil
+			default:
+				return nil, p.errorf("expected ',' or ']' in array")
+			}
+		}
+	case c == '{':
+		p.pos++
+		obj := NewOrderedObject()
+		p.doc.tables[FormatValuePath(path)] = tomlInsertPoint{inline: true}
+		p.skipBlank(false)
+		if p.peek() == '}' {
+			p.pos++
+			return obj, nil
+		}
+		for {
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if p.peek() != '=' {
+				return nil, p.errorf("expected '=' in inline table")
+			}
+			p.pos++
+			p.skipBlank(false)
+			memberPath := append(append([]PathSegment{}, path...), keys...)
+			value, err := p.parseValue(memberPath)
+			if err != nil {
+				return nil, err
+			}
+			target := obj
+			for _, key := range keys[:len(keys)-1] {
+				child, ok := target.Get(key.Key)
+				if !ok {
+					child = NewOrderedObject()
+					target.Set(key.Key, child)
+				}
+				childObj, ok := child.(*OrderedObject)
+				if !ok {
+					return nil, p.errorf("key %q is not a table", key.Key)
+				}
+				target = childObj
+			}
+			target.Set(keys[len(keys)-1].Key, value)
+			p.skipBlank(false)
+			switch p.peek() {
+			case ',':
+				p.pos++
+				p.skipBlank(false)
+			case '}':
+				p.pos++
+				return obj, nil
+			default:
+				return nil, p.errorf("expected ',' or '}' in inline table")
+			}
+		}
+	case strings.HasPrefix(src[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(src[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	}
+
+	start := p.pos
+	for !p.eof() && strings.IndexByte("0123456789abcdefABCDEFxobinf_+-.:TZtz", p.peek()) >= 0 {
+		p.pos++
+	}
+	// Date-times may separate the date and time with a space
+	if p.pos-start == 10 && p.peek() == ' ' && p.pos+1 < len(src) && src[p.pos+1] >= '0' && src[p.pos+1] <= '9' {
+		p.pos++
+		for !p.eof() && strings.IndexByte("0123456789:.+-Zz", p.peek()) >= 0 {
+			p.pos++
+		}
+	}
+	token := src[start:p.pos]
+	if token == "" {
+		return nil, p.errorf("expected value")
+	}
+
+	if tomlDateTimePattern.MatchString(token) {
+		return token, nil
+	}
+
+	switch strings.TrimLeft(token, "+-") {
+	case "inf":
+		if strings.HasPrefix(token, "-") {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+
+	clean := strings.ReplaceAll(token, "_", "")
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if strings.HasPrefix(clean, prefix) {
+			n, err := strconv.ParseInt(clean[2:], base, 64)
+			if err != nil {
+				return nil, p.errorf("invalid integer %q", token)
+			}
+			return n, nil
+		}
+	}
+	if strings.ContainsAny(clean, ".eE") {
+		f, err := strconv.ParseFloat(clean, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %q", token)
+		}
+		return f, nil
+	}
+	n, err := strconv.ParseInt(clean, 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid value %q", token)
+	}
+	return n, nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	src := p.doc.src
+	multiline := strings.HasPrefix(src[p.pos:], `"""`)
+	if multiline {
+		p.pos += 3
+		if strings.HasPrefix(src[p.pos:], "\r\n") {
+			p.pos += 2
+		} else if p.peek() == '\n' {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		if multiline && strings.HasPrefix(src[p.pos:], `"""`) {
+			p.pos += 3
+			// Up to two quotes may directly precede the closing delimiter
+			for i := 0; i < 2 && p.peek() == '"'; i++ {
+				sb.WriteByte('"')
+				p.pos++
+			}
+			return sb.String(), nil
+		}
+		if !multiline && c == '"' {
+			p.pos++
+			return sb.String(), nil
+		}
+		if !multiline && c == '\n' {
+			return "", p.errorf("newline in single-line string")
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(src[p.pos:])
+			sb.WriteRune(r)
+			p.pos += size
+			continue
+		}
+
+		p.pos++
+		if p.eof() {
+			return "", p.errorf("unterminated escape sequence")
+		}
+		escape := p.peek()
+		p.pos++
+		switch escape {
+		case 'b':
+			sb.WriteByte('\b')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'e':
+			sb.WriteByte(0x1b)
+		case '"':
+			sb.WriteByte('"')
+		case '\\':
+			sb.WriteByte('\\')
+		case 'u', 'U':
+			length := 4
+			if escape == 'U' {
+				length = 8
+			}
+			if p.pos+length > len(src) {
+				return "", p.errorf("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(src[p.pos:p.pos+length], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid unicode escape")
+			}
+			sb.WriteRune(rune(code))
+			p.pos += length
+		case ' ', '\t', '\r', '\n':
+			if !multiline {
+				return "", p.errorf("invalid escape sequence")
+			}
+			// Line-ending backslash trims the following whitespace
+			p.pos--
+			for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+				p.pos++
+			}
+		default:
+			return "", p.errorf("invalid escape sequence \\%c", escape)
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	src := p.doc.src
+	if strings.HasPrefix(src[p.pos:], "'''") {
+		p.pos += 3
+		if strings.HasPrefix(src[p.pos:], "\r\n") {
+			p.pos += 2
+		} else if p.peek() == '\n' {
+			p.pos++
+		}
+		end := strings.Index(src[p.pos:], "'''")
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		s := src[p.pos : p.pos+end]
+		p.pos += end + 3
+		for i := 0; i < 2 && p.peek() == '\''; i++ {
+			s += "'"
+			p.pos++
+		}
+		return s, nil
+	}
+
+	p.pos++
+	end := strings.IndexAny(src[p.pos:], "'\n")
+	if end < 0 || src[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// setTOMLValue rewrites the value at path in place, or inserts a new key
+// into its table, leaving the rest of the document untouched
+func setTOMLValue(src string, segments []PathSegment, value interface{}, createMissing bool) (string, error) {
+	doc, err := parseTOML(src)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := encodeTOMLValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	target := FormatValuePath(segments)
+	var updated string
+
+	if span, ok := doc.spans[target]; ok {
+		updated = src[:span[0]] + encoded + src[span[1]:]
+	} else if point, ok := doc.tables[target]; ok && !point.inline {
+		return "", fmt.Errorf("%s is a table header; set its keys individually", target)
+	} else {
+		updated, err = doc.insertValue(segments, encoded, createMissing)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Make sure the edit produced a document that still parses
+	if _, err := parseTOML(updated); err != nil {
+		return "", fmt.Errorf("edit would produce invalid TOML: %w", err)
+	}
+	return updated, nil
+}
+
+func (doc *tomlDocument) insertValue(segments []PathSegment, encoded string, createMissing bool) (string, error) {
+	src := doc.src
+	parent := segments[:len(segments)-1]
+	last := segments[len(segments)-1]
+	parentPath := FormatValuePath(parent)
+
+	if !createMissing {
+		return "", WithErrorCode(ErrorNotFound, fmt.Errorf("%s: not found", FormatValuePath(segments)))
+	}
+
+	if last.IsIndex {
+		span, ok := doc.spans[parentPath]
+		parentValue, _ := lookupValue(doc.root, parent)
+		arr, isArr := parentValue.([]interface{})
+		if !ok || !isArr {
+			return "", fmt.Errorf("%s: not an inline array", parentPath)
+		}
+		if last.Index != len(arr) {
+			return "", fmt.Errorf("%s: index out of range (length %d)", FormatValuePath(segments), len(arr))
+		}
+		closing := span[1] - 1
+		insert := encoded
+		if len(arr) > 0 {
+			insert = ", " + encoded
+			// Keep a trailing comma in place if the array already has one
+			before := strings.TrimRight(src[span[0]:closing], " \t\r\n")
+			if strings.HasSuffix(before, ",") {
+				insert = " " + encoded
+			}
+			closing = span[0] + len(before)
+		}
+		return src[:closing] + insert + src[closing:], nil
+	}
+
+	keyText := encodeTOMLKey(last.Key)
+	point, ok := doc.tables[parentPath]
+	if ok && point.inline {
+		span := doc.spans[parentPath]
+		closing := span[1] - 1
+		before := strings.TrimRight(src[span[0]:closing], " \t")
+		insert := " " + keyText + " = " + encoded + " "
+		if before != "{" {
+			insert = ", " + keyText + " = " + encoded + " "
+		}
+		return src[:span[0]+len(before)] + insert + src[closing:], nil
+	}
+	if ok {
+		line := point.prefix + keyText + " = " + encoded + "\n"
+		if point.offset > 0 && !strings.HasSuffix(src[:point.offset], "\n") {
+			line = "\n" + line
+		}
+		return src[:point.offset] + line + src[point.offset:], nil
+	}
+
+	// The parent table does not exist yet; refuse to create one below a
+	// value or array element, otherwise append a new [table] section
+	for i := range parent {
+		prefix := FormatValuePath(parent[:i+1])
+		if parent[i].IsIndex {
+			return "", WithErrorCode(ErrorNotFound, fmt.Errorf("%s: not found", prefix))
+		}
+		if _, isValue := doc.spans[prefix]; isValue {
+			return "", fmt.Errorf("%s is not a table", prefix)
+		}
+	}
+
+	var header []string
+	for _, seg := range parent {
+		header = append(header, encodeTOMLKey(seg.Key))
+	}
+	section := "[" + strings.Join(header, ".") + "]\n" + keyText + " = " + encoded + "\n"
+	switch {
+	case src == "":
+	case strings.HasSuffix(src, "\n"):
+		section = "\n" + section
+	default:
+		section = "\n\n" + section
+	}
+	return src + section, nil
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func tomlKeyPrefix(keys []PathSegment) string {
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(encodeTOMLKey(key.Key))
+		sb.WriteByte('.')
+	}
+	return sb.String()
+}
+
+func encodeTOMLKey(key string) string {
+	if tomlBareKeyPattern.MatchString(key) {
+		return key
+	}
+	return encodeTOMLString(key)
+}
+
+func encodeTOMLString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				sb.WriteString(fmt.Sprintf(`\u%04X`, r))
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// encodeTOMLValue renders a value as an inline TOML expression
+func encodeTOMLValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("TOML has no null value")
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return encodeTOMLString(v), nil
+	case json.Number:
+		return v.String(), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return "inf", nil
+		case math.IsInf(v, -1):
+			return "-inf", nil
+		case math.IsNaN(v):
+			return "nan", nil
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			encoded, err := encodeTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, encoded)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case *OrderedObject:
+		if v.Len() == 0 {
+			return "{}", nil
+		}
+		members := make([]string, 0, v.Len())
+		for _, key := range v.Keys() {
+			item, _ := v.Get(key)
+			encoded, err := encodeTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			members = append(members, encodeTOMLKey(key)+" = "+encoded)
+		}
+		return "{ " + strings.Join(members, ", ") + " }", nil
+	case map[string]interface{}:
+		obj := NewOrderedObject()
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			obj.Set(key, v[key])
+		}
+		return encodeTOMLValue(obj)
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
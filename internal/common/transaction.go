@@ -0,0 +1,78 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileTransaction snapshots files before they are modified so that a batch
+// of writes can be undone if any of them fails
+type FileTransaction struct {
+	snapshots []fileSnapshot
+	seen      map[string]bool
+}
+
+type fileSnapshot struct {
+	path    string
+	content []byte
+	mode    os.FileMode
+	existed bool
+}
+
+// NewFileTransaction creates an empty transaction
+func NewFileTransaction() *FileTransaction {
+	return &FileTransaction{seen: make(map[string]bool)}
+}
+
+// Snapshot records the current state of path. Only the first snapshot of a
+// path is kept, so rolling back restores the state before the transaction.
+func (t *FileTransaction) Snapshot(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if t.seen[absPath] {
+		return nil
+	}
+
+	snapshot := fileSnapshot{path: absPath, mode: 0644}
+	info, err := os.Stat(absPath)
+	switch {
+	case err == nil:
+		if snapshot.content, err = os.ReadFile(absPath); err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", path, err)
+		}
+		snapshot.mode = info.Mode().Perm()
+		snapshot.existed = true
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+
+	t.seen[absPath] = true
+	t.snapshots = append(t.snapshots, snapshot)
+	return nil
+}
+
+// Rollback restores every snapshotted file, newest first, removing files
+// that did not exist before. It returns the number of files restored and
+// any files that could not be restored.
+func (t *FileTransaction) Rollback() (int, []error) {
+	restored := 0
+	var errs []error
+	for i := len(t.snapshots) - 1; i >= 0; i-- {
+		snapshot := t.snapshots[i]
+		var err error
+		if snapshot.existed {
+			err = WriteFileAtomic(snapshot.path, snapshot.content, snapshot.mode)
+		} else if err = os.Remove(snapshot.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", snapshot.path, err))
+			continue
+		}
+		restored++
+	}
+	return restored, errs
+}
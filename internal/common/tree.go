@@ -0,0 +1,135 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"jarvis/internal/types"
+)
+
+// TreeOptions controls how BuildTree walks a directory hierarchy
+type TreeOptions struct {
+	MaxDepth         int
+	MaxEntries       int
+	IncludeHidden    bool
+	RespectGitignore bool
+	DirectoriesOnly  bool
+}
+
+// BuildTree walks root and returns its hierarchy. Walking stops once
+// MaxEntries nodes have been collected; the skipped count is recorded on
+// the directory where the cap was reached.
+func BuildTree(root string, opts TreeOptions) (*types.TreeNode, int, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to stat %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, 0, fmt.Errorf("not a directory: %s", root)
+	}
+
+	var ignore *GitignoreMatcher
+	if opts.RespectGitignore {
+		ignore = LoadGitignore(root)
+	}
+
+	node := &types.TreeNode{Name: filepath.Base(root), Type: "directory"}
+	count := 0
+	buildTreeLevel(root, "", node, 1, opts, ignore, &count)
+
+	return node, count, nil
+}
+
+func buildTreeLevel(dir, relDir string, node *types.TreeNode, depth int, opts TreeOptions, ignore *GitignoreMatcher, count *int) {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	// Directories first, then files, each alphabetically
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for i, entry := range entries {
+		name := entry.Name()
+		relPath := filepath.ToSlash(filepath.Join(relDir, name))
+
+		if !opts.IncludeHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if SkipInWalk(filepath.Join(dir, name), entry.Type()) {
+			continue
+		}
+		if name == ".git" && opts.RespectGitignore {
+			continue
+		}
+		if ignore != nil && ignore.Match(relPath, entry.IsDir()) {
+			continue
+		}
+		if opts.DirectoriesOnly && !entry.IsDir() {
+			continue
+		}
+
+		if opts.MaxEntries > 0 && *count >= opts.MaxEntries {
+			node.Omitted = len(entries) - i
+			return
+		}
+		*count++
+
+		child := &types.TreeNode{Name: name, Type: "file"}
+		if entry.IsDir() {
+			child.Type = "directory"
+		} else if info, err := entry.Info(); err == nil {
+			child.Size = info.Size()
+		}
+		node.Children = append(node.Children, child)
+
+		if entry.IsDir() {
+			buildTreeLevel(filepath.Join(dir, name), relPath, child, depth+1, opts, ignore, count)
+		}
+	}
+}
+
+// RenderTree renders a tree in the classic `tree` ASCII layout
+func RenderTree(root *types.TreeNode, showSize bool) string {
+	var out strings.Builder
+	out.WriteString(root.Name + "\n")
+	renderTreeChildren(&out, root, "", showSize)
+	return out.String()
+}
+
+func renderTreeChildren(out *strings.Builder, node *types.TreeNode, prefix string, showSize bool) {
+	for i, child := range node.Children {
+		last := i == len(node.Children)-1 && node.Omitted == 0
+
+		connector, childPrefix := "├── ", "│   "
+		if last {
+			connector, childPrefix = "└── ", "    "
+		}
+
+		label := child.Name
+		if child.Type == "directory" {
+			label += "/"
+		} else if showSize {
+			label += fmt.Sprintf(" (%s)", FormatBytes(child.Size))
+		}
+
+		out.WriteString(prefix + connector + label + "\n")
+		renderTreeChildren(out, child, prefix+childPrefix, showSize)
+	}
+
+	if node.Omitted > 0 {
+		out.WriteString(fmt.Sprintf("%s└── ... (%d more entries)\n", prefix, node.Omitted))
+	}
+}
@@ -0,0 +1,169 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"jarvis/internal/types"
+)
+
+// UnicodeFixOptions selects what NormalizeUnicode fixes
+type UnicodeFixOptions struct {
+	BOM               bool
+	ZeroWidth         bool
+	NonBreakingSpaces bool
+	// Normalization is "nfc", "nfd" or empty to leave composition alone
+	Normalization string
+}
+
+// invisibleCharacters are removed by the zero-width fix. Joiners are only
+// removed between ASCII characters, since emoji sequences and several
+// scripts depend on them.
+var invisibleCharacters = map[rune]string{
+	'\u00AD': "SOFT HYPHEN",
+	'\u200B': "ZERO WIDTH SPACE",
+	'\u200C': "ZERO WIDTH NON-JOINER",
+	'\u200D': "ZERO WIDTH JOINER",
+	'\u2060': "WORD JOINER",
+	'\uFEFF': "ZERO WIDTH NO-BREAK SPACE",
+}
+
+// spaceCharacters are replaced with a plain space by the non-breaking
+// space fix
+var spaceCharacters = map[rune]string{
+	'\u00A0': "NO-BREAK SPACE",
+	'\u2000': "EN QUAD",
+	'\u2001': "EM QUAD",
+	'\u2002': "EN SPACE",
+	'\u2003': "EM SPACE",
+	'\u2004': "THREE-PER-EM SPACE",
+	'\u2005': "FOUR-PER-EM SPACE",
+	'\u2006': "SIX-PER-EM SPACE",
+	'\u2007': "FIGURE SPACE",
+	'\u2008': "PUNCTUATION SPACE",
+	'\u2009': "THIN SPACE",
+	'\u200A': "HAIR SPACE",
+	'\u202F': "NARROW NO-BREAK SPACE",
+}
+
+func describeRunes(runes []rune) string {
+	parts := make([]string, len(runes))
+	for i, r := range runes {
+		if unicode.Is(unicode.M, r) {
+			parts[i] = fmt.Sprintf("U+%04X", r)
+		} else {
+			parts[i] = string(r)
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+// NormalizeUnicode removes a leading byte order mark and invisible
+// characters, replaces non-breaking and other unusual spaces with plain
+// spaces, and converts text to NFC or NFD. Every change is reported with
+// the line and column it had in the original content.
+func NormalizeUnicode(content string, opts UnicodeFixOptions) (string, []types.UnicodeChange, error) {
+	if !utf8.ValidString(content) {
+		return "", nil, fmt.Errorf("content is not valid UTF-8")
+	}
+	if opts.Normalization != "" && opts.Normalization != "nfc" && opts.Normalization != "nfd" {
+		return "", nil, WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown normalization: %s (use nfc, nfd or none)", opts.Normalization))
+	}
+
+	runes := []rune(content)
+	out := make([]rune, 0, len(runes))
+	// positions holds the original line and column of each rune of out
+	positions := make([][2]int, 0, len(runes))
+	var changes []types.UnicodeChange
+	line, column := 1, 1
+	record := func(position [2]int, kind, detail string) {
+		changes = append(changes, types.UnicodeChange{Line: position[0], Column: position[1], Kind: kind, Detail: detail})
+	}
+	isASCII := func(i int) bool {
+		return i < 0 || i >= len(runes) || runes[i] < utf8.RuneSelf
+	}
+
+	for i, r := range runes {
+		name, invisible := invisibleCharacters[r]
+		spaceName, space := spaceCharacters[r]
+		position := [2]int{line, column}
+
+		switch {
+		case i == 0 && r == '\uFEFF' && opts.BOM:
+			record(position, "bom", "removed byte order mark")
+		case invisible && opts.ZeroWidth && !(i == 0 && r == '\uFEFF'):
+			if (r == '\u200C' || r == '\u200D') && !(isASCII(i-1) && isASCII(i+1)) {
+				out, positions = append(out, r), append(positions, position)
+				break
+			}
+			record(position, "zero_width", fmt.Sprintf("removed %s (U+%04X)", name, r))
+		case space && opts.NonBreakingSpaces:
+			record(position, "nbsp", fmt.Sprintf("replaced %s (U+%04X) with a space", spaceName, r))
+			out, positions = append(out, ' '), append(positions, position)
+		default:
+			out, positions = append(out, r), append(positions, position)
+		}
+
+		if r == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+
+	if opts.Normalization == "" {
+		return string(out), changes, nil
+	}
+
+	// Normalize one segment, a starter with the marks following it, at a
+	// time so each change is reported where it was
+	form, verb := norm.NFC, "composed"
+	if opts.Normalization == "nfd" {
+		form, verb = norm.NFD, "decomposed"
+	}
+	text := string(out)
+	var normalized strings.Builder
+	normalized.Grow(len(text))
+	index := 0
+	for start := 0; start < len(text); {
+		end := start + form.NextBoundaryInString(text[start:], true)
+		if end <= start {
+			end = len(text)
+		}
+		segment := text[start:end]
+		result := form.String(segment)
+		if result != segment {
+			record(positions[index], "normalization", fmt.Sprintf("%s %s into %s", verb, describeRunes([]rune(segment)), describeRunes([]rune(result))))
+		}
+		normalized.WriteString(result)
+		index += utf8.RuneCountInString(segment)
+		start = end
+	}
+
+	return normalized.String(), changes, nil
+}
+
+// DetectNormalizationForms counts precomposed characters that NFD would
+// decompose and character sequences that NFC would compose; a file with
+// both mixes normalization forms
+func DetectNormalizationForms(content string) (composed, decomposed int) {
+	for start := 0; start < len(content); {
+		end := start + norm.NFC.NextBoundaryInString(content[start:], true)
+		if end <= start {
+			end = len(content)
+		}
+		segment := content[start:end]
+		if !norm.NFD.IsNormalString(segment) {
+			composed++
+		}
+		if !norm.NFC.IsNormalString(segment) {
+			decomposed++
+		}
+		start = end
+	}
+	return composed, decomposed
+}
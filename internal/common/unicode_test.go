@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestNormalizeUnicodeForms(t *testing.T) {
+	tests := []struct {
+		name, form, input, want string
+	}{
+		{"compose latin", "nfc", "cafe\u0301", "caf\u00e9"},
+		{"decompose latin", "nfd", "caf\u00e9", "cafe\u0301"},
+		{"compose hangul", "nfc", "\u1112\u1161\u11ab", "\ud55c"},
+		{"decompose hangul", "nfd", "\ud55c", "\u1112\u1161\u11ab"},
+		// The dot below sorts before the dot above, so they compose into
+		// U+1E69 whichever order they were typed in
+		{"reorder marks", "nfc", "s\u0307\u0323", "\u1e69"},
+		{"reorder marks nfd", "nfd", "s\u0307\u0323", "s\u0323\u0307"},
+	}
+	for _, test := range tests {
+		got, changes, err := NormalizeUnicode(test.input, UnicodeFixOptions{Normalization: test.form})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %+q, want %+q", test.name, got, test.want)
+		}
+		if len(changes) != 1 {
+			t.Errorf("%s: got %d changes, want 1: %v", test.name, len(changes), changes)
+		}
+	}
+}
+
+func TestNormalizeUnicodeReportsOriginalPositions(t *testing.T) {
+	_, changes, err := NormalizeUnicode("a\u200bb\nx\u00a0cafe\u0301", UnicodeFixOptions{ZeroWidth: true, NonBreakingSpaces: true, Normalization: "nfc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int{{1, 2}, {2, 2}, {2, 6}}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %v", len(changes), len(want), changes)
+	}
+	for i, change := range changes {
+		if position := [2]int{change.Line, change.Column}; position != want[i] {
+			t.Errorf("change %d (%s) at %v, want %v", i, change.Kind, position, want[i])
+		}
+	}
+}
+
+func TestDetectNormalizationForms(t *testing.T) {
+	composed, decomposed := DetectNormalizationForms("caf\u00e9 cafe\u0301 \ud55c")
+	if composed != 2 || decomposed != 1 {
+		t.Errorf("got %d composed and %d decomposed, want 2 and 1", composed, decomposed)
+	}
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"jarvis/internal/types"
+)
+
+// WaitForPort polls address (host:port) until it accepts a TCP connection
+// or ctx is done
+func WaitForPort(ctx context.Context, address string, interval time.Duration) types.WaitResult {
+	dialer := &net.Dialer{}
+	return pollUntilReady(ctx, address, interval, func(attemptCtx context.Context) (int, error) {
+		conn, err := dialer.DialContext(attemptCtx, "tcp", address)
+		if err != nil {
+			return 0, err
+		}
+		conn.Close()
+		return 0, nil
+	})
+}
+
+// WaitForURL polls url until a GET returns expectedStatus, or any 2xx
+// status when expectedStatus is 0, or ctx is done
+func WaitForURL(ctx context.Context, url string, expectedStatus int, interval time.Duration) types.WaitResult {
+	client := &http.Client{}
+	return pollUntilReady(ctx, url, interval, func(attemptCtx context.Context) (int, error) {
+		req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, url, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		ready := resp.StatusCode >= 200 && resp.StatusCode < 300
+		if expectedStatus != 0 {
+			ready = resp.StatusCode == expectedStatus
+		}
+		if !ready {
+			return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return resp.StatusCode, nil
+	})
+}
+
+// pollUntilReady calls check every interval until it succeeds or ctx is
+// done. Each attempt is bounded by the interval, with a floor so slow
+// handshakes can still finish.
+func pollUntilReady(ctx context.Context, target string, interval time.Duration, check func(context.Context) (int, error)) types.WaitResult {
+	result := types.WaitResult{Target: target}
+	start := time.Now()
+	attemptTimeout := max(interval, 2*time.Second)
+
+	for {
+		result.Attempts++
+		attemptCtx, cancel := context.WithTimeout(ctx, attemptTimeout)
+		status, err := check(attemptCtx)
+		cancel()
+		result.StatusCode = status
+		if err == nil {
+			result.Ready = true
+			result.LastError = ""
+			break
+		}
+		result.LastError = err.Error()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	return result
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchOptions controls which files a polling watcher observes
+type WatchOptions struct {
+	Pattern       string
+	IncludeHidden bool
+	PollInterval  time.Duration
+	Debounce      time.Duration
+}
+
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// WatchForChanges polls root and calls onChange with the relative paths of
+// created, modified and deleted files once changes have settled for the
+// debounce period. Watching ends when ctx is done or onChange returns false.
+func WatchForChanges(ctx context.Context, root string, opts WatchOptions, onChange func(changed []string) bool) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 500 * time.Millisecond
+	}
+
+	previous, err := snapshotTree(root, opts)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]bool)
+	var lastChange time.Time
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := snapshotTree(root, opts)
+		if err != nil {
+			return err
+		}
+		if changed := diffSnapshots(previous, current); len(changed) > 0 {
+			for _, path := range changed {
+				pending[path] = true
+			}
+			lastChange = time.Now()
+		}
+		previous = current
+
+		if len(pending) == 0 || time.Since(lastChange) < opts.Debounce {
+			continue
+		}
+
+		changed := make([]string, 0, len(pending))
+		for path := range pending {
+			changed = append(changed, path)
+		}
+		sort.Strings(changed)
+		pending = make(map[string]bool)
+
+		if !onChange(changed) {
+			return nil
+		}
+
+		// Ignore changes made by the triggered command itself
+		previous, err = snapshotTree(root, opts)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func snapshotTree(root string, opts WatchOptions) (map[string]watchedFile, error) {
+	snapshot := make(map[string]watchedFile)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		snapshot[filepath.Base(root)] = watchedFile{size: info.Size(), modTime: info.ModTime()}
+		return snapshot, nil
+	}
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		denied := SkipInWalk(path, d.Type())
+		if !opts.IncludeHidden && strings.HasPrefix(d.Name(), ".") || denied {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if opts.Pattern != "" && !MatchGlob(opts.Pattern, rel, true) {
+			return nil
+		}
+
+		if fileInfo, err := d.Info(); err == nil {
+			snapshot[rel] = watchedFile{size: fileInfo.Size(), modTime: fileInfo.ModTime()}
+		}
+		return nil
+	})
+
+	return snapshot, nil
+}
+
+func diffSnapshots(previous, current map[string]watchedFile) []string {
+	var changed []string
+	for path, state := range current {
+		if old, ok := previous[path]; !ok || old.size != state.size || !old.modTime.Equal(state.modTime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
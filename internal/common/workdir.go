@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// Working directories set with set_working_directory, keyed by MCP
+	// client session ID. Like workspaces they last for the lifetime of the
+	// server and are never written to the config file.
+	sessionWorkingDirs  = make(map[string]string)
+	sessionWorkingMutex sync.RWMutex
+)
+
+// SessionWorkingDirectory returns the working directory set for a session,
+// or "" when none is set or it is no longer allowed
+func SessionWorkingDirectory(session string) string {
+	sessionWorkingMutex.RLock()
+	dir := sessionWorkingDirs[session]
+	sessionWorkingMutex.RUnlock()
+
+	// The allowed directories may have changed since it was set
+	if dir == "" || !IsPathAllowed(dir) {
+		return ""
+	}
+	return dir
+}
+
+// ResolveSessionPath makes path absolute, resolving a relative path
+// against the session's working directory like a shell would after cd
+func ResolveSessionPath(session, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		if base := SessionWorkingDirectory(session); base != "" {
+			path = filepath.Join(base, path)
+		}
+	}
+	return filepath.Abs(path)
+}
+
+// SetSessionWorkingDirectory changes the working directory of a session
+// and returns it as an absolute path. A relative dir is resolved against
+// the current one; the directory must exist and be allowed.
+func SetSessionWorkingDirectory(session, dir string) (string, error) {
+	absDir, err := ResolveSessionPath(session, dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid directory %s: %v", dir, err)
+	}
+	if !IsPathAllowed(absDir) {
+		return "", WithErrorCode(ErrorPathNotAllowed, fmt.Errorf("access to %s is not allowed", absDir))
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return "", fmt.Errorf("cannot access %s: %v", absDir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", absDir)
+	}
+
+	sessionWorkingMutex.Lock()
+	sessionWorkingDirs[session] = absDir
+	sessionWorkingMutex.Unlock()
+	return absDir, nil
+}
+
+// ClearSessionWorkingDirectory forgets the working directory of a session,
+// so commands run in the server's own working directory again
+func ClearSessionWorkingDirectory(session string) {
+	sessionWorkingMutex.Lock()
+	delete(sessionWorkingDirs, session)
+	sessionWorkingMutex.Unlock()
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Workspace represents a scratch directory allocated for the current session
+type Workspace struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	// Session workspaces, keyed by ID. They are allowed paths for the lifetime
+	// of the server but are never written to the config file.
+	workspaces     = make(map[string]*Workspace)
+	workspaceMutex sync.RWMutex
+)
+
+// CreateWorkspace allocates a new temporary directory and allows access to it
+func CreateWorkspace(prefix string) (*Workspace, error) {
+	if prefix == "" {
+		prefix = "jarvis-workspace"
+	}
+
+	dir, err := os.MkdirTemp("", prefix+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+
+	// Resolve symlinks (e.g. /tmp -> /private/tmp) so path checks match
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	ws := &Workspace{
+		ID:        filepath.Base(dir),
+		Path:      dir,
+		CreatedAt: time.Now(),
+	}
+
+	workspaceMutex.Lock()
+	workspaces[ws.ID] = ws
+	workspaceMutex.Unlock()
+
+	return ws, nil
+}
+
+// CleanupWorkspace removes a workspace directory and revokes access to it
+func CleanupWorkspace(id string) error {
+	workspaceMutex.Lock()
+	ws, exists := workspaces[id]
+	if exists {
+		delete(workspaces, id)
+	}
+	workspaceMutex.Unlock()
+
+	if !exists {
+		return WithErrorCode(ErrorNotFound, fmt.Errorf("workspace not found: %s", id))
+	}
+
+	if err := os.RemoveAll(ws.Path); err != nil {
+		return fmt.Errorf("failed to remove workspace %s: %w", id, err)
+	}
+	return nil
+}
+
+// CleanupAllWorkspaces removes every workspace created during the session
+func CleanupAllWorkspaces() []error {
+	var errs []error
+	for _, ws := range ListWorkspaces() {
+		if err := CleanupWorkspace(ws.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ListWorkspaces returns the active workspaces ordered by creation time
+func ListWorkspaces() []Workspace {
+	workspaceMutex.RLock()
+	defer workspaceMutex.RUnlock()
+
+	list := make([]Workspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		list = append(list, *ws)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// workspaceDirectories returns the paths of all active workspaces
+func workspaceDirectories() []string {
+	workspaceMutex.RLock()
+	defer workspaceMutex.RUnlock()
+
+	dirs := make([]string, 0, len(workspaces))
+	for _, ws := range workspaces {
+		dirs = append(dirs, ws.Path)
+	}
+	return dirs
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package common
+
+import "fmt"
+
+var errXattrUnsupported = fmt.Errorf("extended attributes are not supported on this platform")
+
+// ListXattrs is not supported on this platform
+func ListXattrs(path string) (map[string]string, error) {
+	return nil, errXattrUnsupported
+}
+
+// GetXattr is not supported on this platform
+func GetXattr(path, name string) (string, error) {
+	return "", errXattrUnsupported
+}
+
+// SetXattr is not supported on this platform
+func SetXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
+
+// RemoveXattr is not supported on this platform
+func RemoveXattr(path, name string) error {
+	return errXattrUnsupported
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLEditOptions selects the documents of a multi-document YAML file that
+// EditYAML changes
+type YAMLEditOptions struct {
+	// Document is the zero-based document index; -1 selects every document
+	Document int
+	// Selector restricts the edit to documents whose scalar values match,
+	// e.g. "kind=Deployment,metadata.name=web"
+	Selector      string
+	CreateMissing bool
+}
+
+type yamlSelectorTerm struct {
+	path  []PathSegment
+	value string
+}
+
+// EditYAML sets (action "set") or removes (action "delete") the value at
+// path in the selected documents of a YAML stream. The node tree is edited
+// in place, so comments, anchors and key order survive. It returns the
+// updated content and the number of documents changed.
+func EditYAML(content []byte, action, path string, value interface{}, opts YAMLEditOptions) ([]byte, int, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid path: %w", err)
+	}
+	if len(segments) == 0 {
+		return nil, 0, fmt.Errorf("path cannot be empty")
+	}
+
+	selector, err := parseYAMLSelector(opts.Selector)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	docs, err := decodeYAMLDocuments(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(docs) == 0 {
+		return nil, 0, fmt.Errorf("document is empty")
+	}
+	if opts.Document >= len(docs) {
+		return nil, 0, fmt.Errorf("document %d out of range (file has %d)", opts.Document, len(docs))
+	}
+
+	edited := 0
+	for i, doc := range docs {
+		if opts.Document >= 0 && i != opts.Document {
+			continue
+		}
+		if !yamlDocumentMatches(doc, selector) {
+			continue
+		}
+
+		switch action {
+		case "set":
+			err = setYAMLNode(doc, segments, valueToYAMLNode(value), opts.CreateMissing)
+		case "delete":
+			err = deleteYAMLNode(doc, segments)
+		default:
+			return nil, 0, WithErrorCode(ErrorValidationFailed, fmt.Errorf("unknown action: %s (use set or delete)", action))
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("document %d: %w", i, err)
+		}
+		edited++
+	}
+	if edited == 0 {
+		return nil, 0, fmt.Errorf("no document matches the selection")
+	}
+
+	updated, err := encodeYAMLDocuments(docs, len(detectIndent(string(content), "  ")))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The encoder drops a leading document marker; restore it so manifests
+	// that start with --- keep doing so
+	if strings.HasPrefix(string(content), "---") && !strings.HasPrefix(string(updated), "---") {
+		updated = append([]byte("---\n"), updated...)
+	}
+	return updated, edited, nil
+}
+
+// parseYAMLSelector parses comma-separated path=value terms
+func parseYAMLSelector(selector string) ([]yamlSelectorTerm, error) {
+	var terms []yamlSelectorTerm
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		path, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid selector term %q: expected path=value", part)
+		}
+		segments, err := ParseValuePath(strings.TrimSpace(path))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector path %q: %w", path, err)
+		}
+		terms = append(terms, yamlSelectorTerm{path: segments, value: strings.TrimSpace(value)})
+	}
+	return terms, nil
+}
+
+func yamlDocumentMatches(doc *yaml.Node, selector []yamlSelectorTerm) bool {
+	for _, term := range selector {
+		node, err := lookupYAMLNode(doc, term.path)
+		if err != nil || node.Kind != yaml.ScalarNode || node.Value != term.value {
+			return false
+		}
+	}
+	return true
+}
@@ -1,7 +1,14 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"jarvis/handlers"
+	"jarvis/internal/common"
+	"jarvis/internal/types"
+	"log"
+	"slices"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -17,16 +24,22 @@ func RegisterConfigTools(s *server.MCPServer) {
 
 	// set_config_value tool
 	setConfigTool := mcp.NewTool("set_config_value",
-		mcp.WithDescription("Set a specific configuration value by key"),
-		mcp.WithString("key", mcp.Required(), mcp.Description("Configuration key to set")),
-		mcp.WithString("value", mcp.Required(), mcp.Description("Configuration value")),
+		mcp.WithDescription("Set a specific configuration value by key. The value is checked against the key's type and constraints, and the resulting configuration must be valid."),
+		mcp.WithString("key", mcp.Required(), mcp.Description("Configuration key to set; get_config_schema lists every key with its type and constraints")),
+		mcp.WithString("value", mcp.Required(), mcp.Description(`Configuration value: true or false for bool keys, a whole number for int keys, and a JSON array such as ["/home","/tmp"] or a comma-separated list for list keys such as blockedCommands and allowedDirectories`)),
 	)
 	s.AddTool(setConfigTool, handlers.HandleSetConfig)
 
+	// get_config_schema tool
+	getSchemaTool := mcp.NewTool("get_config_schema",
+		mcp.WithDescription("List every configuration key with its type, description, allowed values and bounds as JSON; keys with edited_with are changed with that tool instead of set_config_value"),
+	)
+	s.AddTool(getSchemaTool, handlers.HandleGetConfigSchema)
+
 	// add_allowed_directory tool
 	addDirTool := mcp.NewTool("add_allowed_directory",
 		mcp.WithDescription("Add a directory to the allowed directories list"),
-		mcp.WithString("directory", mcp.Required(), mcp.Description("Directory path to allow")),
+		mcp.WithString("directory", mcp.Required(), mcp.Description("Directory path to allow. A leading ~ is the home directory and glob patterns allow every matching directory, e.g. '~/projects/*' or '/srv/app-?'")),
 	)
 	s.AddTool(addDirTool, handlers.HandleAddAllowedDirectory)
 
@@ -40,19 +53,315 @@ func RegisterConfigTools(s *server.MCPServer) {
 	// add_blocked_command tool
 	addBlockedTool := mcp.NewTool("add_blocked_command",
 		mcp.WithDescription("Add a command pattern to the blocked commands list"),
-		mcp.WithString("pattern", mcp.Required(), mcp.Description("Command pattern to block")),
+		mcp.WithString("pattern", mcp.Required(), mcp.Description("Command pattern to block, e.g. 'rm -rf' (an executable and the arguments it must be given, in any order) or text with shell syntax, which is matched literally")),
 	)
 	s.AddTool(addBlockedTool, handlers.HandleAddBlockedCommand)
 
+	// list_blocked_commands tool
+	listBlockedTool := mcp.NewTool("list_blocked_commands",
+		mcp.WithDescription("List the blocked command patterns"),
+	)
+	s.AddTool(listBlockedTool, handlers.HandleListBlockedCommands)
+
+	// remove_blocked_command tool
+	removeBlockedTool := mcp.NewTool("remove_blocked_command",
+		mcp.WithDescription("Remove a command pattern from the blocked commands list"),
+		mcp.WithString("pattern", mcp.Required(), mcp.Description("Blocked pattern to remove, exactly as list_blocked_commands shows it")),
+	)
+	s.AddTool(removeBlockedTool, handlers.HandleRemoveBlockedCommand)
+
+	// test_command_against_policy tool
+	testPolicyTool := mcp.NewTool("test_command_against_policy",
+		mcp.WithDescription("Check a command against the blocked and approval patterns without running it. Returns the decision (blocked, needs_approval or allowed), the reason and every pattern the command matches."),
+		mcp.WithString("command", mcp.Required(), mcp.Description("Command to check")),
+	)
+	s.AddTool(testPolicyTool, handlers.HandleTestCommandAgainstPolicy)
+
+	// test_tool_against_policy tool
+	testToolPolicyTool := mcp.NewTool("test_tool_against_policy",
+		mcp.WithDescription("Check a tool call against the policyRules without making it. Returns the decision (blocked, needs_approval or allowed), the reason, every matching rule and the paths, URL hosts and commands the rules were matched against."),
+		mcp.WithString("tool", mcp.Required(), mcp.Description("Tool the call is made to")),
+		mcp.WithString("arguments", mcp.Description(`Arguments of the call as a JSON object, e.g. {"path": "/srv/prod/app.yaml"}`)),
+	)
+	s.AddTool(testToolPolicyTool, handlers.HandleTestToolAgainstPolicy)
+
 	// validate_config tool
 	validateTool := mcp.NewTool("validate_config",
 		mcp.WithDescription("Validate the current server configuration"),
 	)
 	s.AddTool(validateTool, handlers.HandleValidateConfig)
 
+	// reload_config tool
+	reloadTool := mcp.NewTool("reload_config",
+		mcp.WithDescription("Reload the configuration from the config file and return the settings that changed; an invalid file is reported and the current configuration kept. The file is also reloaded automatically when it changes."),
+	)
+	s.AddTool(reloadTool, handlers.HandleReloadConfig)
+
 	// reset_config tool
 	resetTool := mcp.NewTool("reset_config",
 		mcp.WithDescription("Reset configuration to default values"),
 	)
 	s.AddTool(resetTool, handlers.HandleResetConfig)
+
+	// export_config tool
+	exportConfigTool := mcp.NewTool("export_config",
+		mcp.WithDescription("Export the configuration as a JSON config file, returned or written to a file, so it can be versioned or copied to another machine"),
+		mcp.WithString("path", mcp.Description("File to write the export to (default: return it)")),
+		mcp.WithBoolean("strip_secrets", mcp.Description("Replace stored secret values with {{secret:NAME}} references and mask passwords in URLs (default: true)")),
+	)
+	s.AddTool(exportConfigTool, handlers.HandleExportConfig)
+
+	// import_config tool
+	importConfigTool := mcp.NewTool("import_config",
+		mcp.WithDescription("Validate an exported configuration and show the settings importing it would change; with apply=true, replace the configuration with it. Saved profiles are kept unless the import has its own."),
+		mcp.WithString("path", mcp.Description("Config file to import")),
+		mcp.WithString("config", mcp.Description("Configuration JSON to import, instead of path")),
+		mcp.WithBoolean("apply", mcp.Description("Replace the configuration instead of only previewing the changes (default: false)")),
+	)
+	s.AddTool(importConfigTool, handlers.HandleImportConfig)
+
+	// list_config_versions tool
+	listConfigVersionsTool := mcp.NewTool("list_config_versions",
+		mcp.WithDescription("List the configuration versions kept each time the configuration changes, newest first, with the settings each change touched"),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of versions to list (default: 20)")),
+	)
+	s.AddTool(listConfigVersionsTool, handlers.HandleListConfigVersions)
+
+	// rollback_config tool
+	rollbackConfigTool := mcp.NewTool("rollback_config",
+		mcp.WithDescription("Show the settings going back to a configuration version from list_config_versions would change; with apply=true, restore its settings. Saved profiles are kept, and the rollback is recorded as a new version."),
+		mcp.WithNumber("version", mcp.Required(), mcp.Description("Configuration version to roll back to")),
+		mcp.WithBoolean("apply", mcp.Description("Restore the version instead of only previewing the changes (default: false)")),
+	)
+	s.AddTool(rollbackConfigTool, handlers.HandleRollbackConfig)
+
+	// save_command_template tool
+	saveTemplateTool := mcp.NewTool("save_command_template",
+		mcp.WithDescription("Define or replace a named command template that run_template executes. Placeholders such as {env} in the command are replaced by validated, shell-quoted arguments, so operators can expose safe parameterized actions instead of free-form shell."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Template name (letters, digits, '.', '_' and '-')")),
+		mcp.WithString("command", mcp.Required(), mcp.Description("Command with {parameter} placeholders, e.g. './deploy.sh --env {env}'")),
+		mcp.WithString("description", mcp.Description("What the template does")),
+		mcp.WithString("parameters", mcp.Description(`Parameters as a JSON array of objects with name, type (string, int, number, bool, enum, path), description, required, default, values (for enum) and pattern (regular expression a string must fully match), e.g. [{"name":"env","type":"enum","values":["staging","prod"],"required":true}]`)),
+		mcp.WithString("shell", mcp.Description("Shell to run the command with (default: the configured default shell)")),
+		mcp.WithString("working_dir", mcp.Description("Working directory for the command")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: commandTimeoutSeconds, at most maxCommandTimeoutSeconds)")),
+	)
+	s.AddTool(saveTemplateTool, handlers.HandleSaveCommandTemplate)
+
+	// delete_command_template tool
+	deleteTemplateTool := mcp.NewTool("delete_command_template",
+		mcp.WithDescription("Delete a command template"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Template name")),
+	)
+	s.AddTool(deleteTemplateTool, handlers.HandleDeleteCommandTemplate)
+
+	// list_command_templates tool
+	listTemplatesTool := mcp.NewTool("list_command_templates",
+		mcp.WithDescription("List the command templates with their commands and parameters"),
+	)
+	s.AddTool(listTemplatesTool, handlers.HandleListCommandTemplates)
+
+	// save_ssh_host tool
+	saveSSHHostTool := mcp.NewTool("save_ssh_host",
+		mcp.WithDescription("Add or replace an SSH host that ssh_execute_command and ssh_copy_file may connect to. Connections use strict host key checking, so the host key must already be in known_hosts."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name the SSH tools refer to the host by")),
+		mcp.WithString("address", mcp.Required(), mcp.Description("Host name or IP address")),
+		mcp.WithNumber("port", mcp.Description("SSH port (default: 22)")),
+		mcp.WithString("user", mcp.Description("Remote user (default: the local user name)")),
+		mcp.WithString("identity_file", mcp.Description("Private key file (default: the SSH agent and default keys)")),
+		mcp.WithString("known_hosts_file", mcp.Description("known_hosts file to verify the host key against (default: ~/.ssh/known_hosts)")),
+	)
+	s.AddTool(saveSSHHostTool, handlers.HandleSaveSSHHost)
+
+	// remove_ssh_host tool
+	removeSSHHostTool := mcp.NewTool("remove_ssh_host",
+		mcp.WithDescription("Remove an SSH host"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Host name")),
+	)
+	s.AddTool(removeSSHHostTool, handlers.HandleRemoveSSHHost)
+
+	// approve_operation tool
+	approveOperationTool := mcp.NewTool("approve_operation",
+		mcp.WithDescription("Run an operation that is waiting for approval. Only call this with a token the user gave after confirming the operation described when it was requested."),
+		mcp.WithString("token", mcp.Required(), mcp.Description("Approval token the server wrote to its log when the operation asked for approval")),
+	)
+	s.AddTool(approveOperationTool, handlers.HandleApproveOperation)
+
+	// deny_operation tool
+	denyOperationTool := mcp.NewTool("deny_operation",
+		mcp.WithDescription("Discard an operation that is waiting for approval"),
+		mcp.WithString("token", mcp.Required(), mcp.Description("Approval token the server wrote to its log when the operation asked for approval")),
+	)
+	s.AddTool(denyOperationTool, handlers.HandleDenyOperation)
+
+	// list_pending_approvals tool
+	listPendingApprovalsTool := mcp.NewTool("list_pending_approvals",
+		mcp.WithDescription("List the operations waiting for approval with their arguments and expiry times"),
+	)
+	s.AddTool(listPendingApprovalsTool, handlers.HandleListPendingApprovals)
+
+	// list_config_profiles tool
+	listProfilesTool := mcp.NewTool("list_config_profiles",
+		mcp.WithDescription("List the saved config profiles with the settings each sets, marking the active one and whether its settings were changed since switching to it"),
+	)
+	s.AddTool(listProfilesTool, handlers.HandleListConfigProfiles)
+
+	// save_config_profile tool
+	saveProfileTool := mcp.NewTool("save_config_profile",
+		mcp.WithDescription("Save a named config profile, such as restricted, dev or ci, replacing one with the same name. Switching to a profile applies its settings on top of the defaults."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Profile name (letters, digits, '.', '_' and '-')")),
+		mcp.WithString("settings", mcp.Description(`Settings as a JSON object with the keys get_config returns, e.g. {"allowedDirectories":["/tmp"],"sandbox":"bwrap","approvalRequired":true} (default: the current configuration)`)),
+	)
+	s.AddTool(saveProfileTool, handlers.HandleSaveConfigProfile)
+
+	// switch_config_profile tool
+	switchProfileTool := mcp.NewTool("switch_config_profile",
+		mcp.WithDescription("Replace the configuration with the defaults plus a profile's settings and return the settings that changed. Templates and SSH hosts not in the profile are replaced too, so save the current configuration as a profile first to be able to switch back."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Profile name")),
+	)
+	s.AddTool(switchProfileTool, handlers.HandleSwitchConfigProfile)
+
+	// diff_config_profiles tool
+	diffProfilesTool := mcp.NewTool("diff_config_profiles",
+		mcp.WithDescription("Show the settings that differ between two config profiles as JSON"),
+		mcp.WithString("from", mcp.Required(), mcp.Description("Profile name, or 'current' for the current configuration")),
+		mcp.WithString("to", mcp.Description("Profile name, or 'current' for the current configuration (default: current)")),
+	)
+	s.AddTool(diffProfilesTool, handlers.HandleDiffConfigProfiles)
+
+	// delete_config_profile tool
+	deleteProfileTool := mcp.NewTool("delete_config_profile",
+		mcp.WithDescription("Delete a config profile; deleting the active profile keeps its settings in effect"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Profile name")),
+	)
+	s.AddTool(deleteProfileTool, handlers.HandleDeleteConfigProfile)
+
+	// query_audit_log tool
+	queryAuditTool := mcp.NewTool("query_audit_log",
+		mcp.WithDescription("Show recorded tool calls, newest first, with their session, arguments (secrets masked), outcome, duration and the files they touched; the audit log persists across restarts and includes rotated logs"),
+		mcp.WithString("since", mcp.Description("Only calls made at or after this RFC3339 timestamp, or within this duration (e.g. 2h)")),
+		mcp.WithString("until", mcp.Description("Only calls made at or before this RFC3339 timestamp, or this long ago (e.g. 30m)")),
+		mcp.WithString("tool", mcp.Description("Only calls of this tool")),
+		mcp.WithString("session", mcp.Description("Only calls from this client session")),
+		mcp.WithString("outcome", mcp.Description("Only successful calls with 'success', or failed ones with 'error'")),
+		mcp.WithString("path", mcp.Description("Only calls that touched this file or a file below this directory")),
+		mcp.WithString("query", mcp.Description("Only calls whose arguments or error contain this text (case-insensitive)")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of calls to return (default: 100)")),
+	)
+	s.AddTool(queryAuditTool, handlers.HandleQueryAuditLog)
+
+	// get_metrics tool
+	getMetricsTool := mcp.NewTool("get_metrics",
+		mcp.WithDescription("Show per-tool call counts, error rates and latency histograms collected while telemetryEnabled is set"),
+		mcp.WithString("format", mcp.Description("Output format: json or prometheus (default: json)")),
+		mcp.WithBoolean("reset", mcp.Description("Discard the collected metrics after returning them (default: false)")),
+	)
+	s.AddTool(getMetricsTool, handlers.HandleGetMetrics)
+
+	// get_session_usage tool
+	getSessionUsageTool := mcp.NewTool("get_session_usage",
+		mcp.WithDescription("Show each client session's files written, bytes downloaded, processes killed and commands run, and which session quotas it has reached"),
+	)
+	s.AddTool(getSessionUsageTool, handlers.HandleGetSessionUsage)
+
+	// get_client_role tool
+	getClientRoleTool := mcp.NewTool("get_client_role",
+		mcp.WithDescription("Show the client name this session connected as, the role it was mapped to through clientRoles or defaultRole, and the directories, tools and session quotas of that role"),
+	)
+	s.AddTool(getClientRoleTool, handlers.HandleGetClientRole)
+
+	// reset_session_quotas tool
+	resetSessionQuotasTool := mcp.NewTool("reset_session_quotas",
+		mcp.WithDescription("Reset the session quota counters so a session that reached a quota can use write, delete, execute and network tools again; needs approval when approvalRequired is set"),
+		mcp.WithString("session", mcp.Description("Session ID from get_session_usage (default: every session)")),
+	)
+	s.AddTool(resetSessionQuotasTool, handlers.HandleResetSessionQuotas)
+
+	// grant_temporary_access tool
+	grantTemporaryAccessTool := mcp.NewTool("grant_temporary_access",
+		mcp.WithDescription("Temporarily allow a directory, URL pattern or command pattern for a duration, a number of tool calls or both, instead of changing allowedDirectories, allowedURLPatterns or blockedCommands for good. The grant is revoked automatically and recorded in the audit log; needs approval when approvalRequired is set."),
+		mcp.WithString("kind", mcp.Required(), mcp.Description("What to allow: directory, url or command")),
+		mcp.WithString("value", mcp.Required(), mcp.Description("Directory path or glob, URL pattern or command pattern; '*' matches any text; in command patterns it matches part of one word and never shell operators or whitespace")),
+		mcp.WithString("duration", mcp.Description("How long the grant lasts, like 15m")),
+		mcp.WithNumber("uses", mcp.Description("Number of tool calls the grant allows")),
+	)
+	s.AddTool(grantTemporaryAccessTool, handlers.HandleGrantTemporaryAccess)
+
+	// list_temporary_grants tool
+	listTemporaryGrantsTool := mcp.NewTool("list_temporary_grants",
+		mcp.WithDescription("List the temporary grants in force with their expiry and remaining uses"),
+	)
+	s.AddTool(listTemporaryGrantsTool, handlers.HandleListTemporaryGrants)
+
+	// revoke_temporary_access tool
+	revokeTemporaryAccessTool := mcp.NewTool("revoke_temporary_access",
+		mcp.WithDescription("Revoke a temporary grant before it runs out"),
+		mcp.WithString("id", mcp.Required(), mcp.Description("Grant ID from grant_temporary_access or list_temporary_grants")),
+	)
+	s.AddTool(revokeTemporaryAccessTool, handlers.HandleRevokeTemporaryAccess)
+
+	// set_secret tool
+	setSecretTool := mcp.NewTool("set_secret",
+		mcp.WithDescription("Store a secret encrypted on disk. Fetch headers, command environment variables and command templates can reference it as {{secret:NAME}}, and its value is redacted from tool output and logs."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Secret name: letters, digits, '.', '_' and '-', starting with a letter or '_'")),
+		mcp.WithString("value", mcp.Required(), mcp.Description("Secret value, at least 4 characters")),
+	)
+	s.AddTool(setSecretTool, handlers.HandleSetSecret)
+
+	// list_secrets tool
+	listSecretsTool := mcp.NewTool("list_secrets",
+		mcp.WithDescription("List the names of stored secrets and when they were set, without their values"),
+	)
+	s.AddTool(listSecretsTool, handlers.HandleListSecrets)
+
+	// delete_secret tool
+	deleteSecretTool := mcp.NewTool("delete_secret",
+		mcp.WithDescription("Delete a stored secret"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Secret name")),
+	)
+	s.AddTool(deleteSecretTool, handlers.HandleDeleteSecret)
+}
+
+// WatchConfigFile reloads the config file whenever it changes, logging what
+// changed; clients learn of the changes through NotifyConfigChanges
+func WatchConfigFile(s *server.MCPServer) {
+	go common.WatchConfigFile(context.Background(), func(changes []types.ConfigDifference, err error) {
+		if err != nil {
+			log.Printf("Config reload error: %v", err)
+			s.SendNotificationToAllClients("notifications/message", map[string]any{
+				"level": "error", "logger": "config", "data": err.Error(),
+			})
+			return
+		}
+		log.Printf("Configuration reloaded; changed settings: %s", strings.Join(changedKeys(changes), ", "))
+	})
+}
+
+// NotifyConfigChanges tells connected clients with a log notification each
+// time the configuration changes, whether through a tool or a reload, and
+// sends tools/list_changed when the change affects which tools they may
+// call
+func NotifyConfigChanges(s *server.MCPServer) {
+	common.OnConfigChange(func(changes []types.ConfigDifference) {
+		keys := changedKeys(changes)
+		s.SendNotificationToAllClients("notifications/message", map[string]any{
+			"level":  "info",
+			"logger": "config",
+			"data": map[string]any{
+				"message": fmt.Sprintf("Configuration changed; changed settings: %s", strings.Join(keys, ", ")),
+				"changes": changes,
+			},
+		})
+		if slices.ContainsFunc(keys, func(key string) bool { return slices.Contains(common.ToolAvailabilitySettings, key) }) {
+			s.SendNotificationToAllClients(mcp.MethodNotificationToolsListChanged, nil)
+		}
+	})
+}
+
+func changedKeys(changes []types.ConfigDifference) []string {
+	keys := make([]string, 0, len(changes))
+	for _, change := range changes {
+		keys = append(keys, change.Key)
+	}
+	return keys
 }
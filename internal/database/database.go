@@ -0,0 +1,31 @@
+package database
+
+import (
+	"jarvis/handlers"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterDatabaseTools registers all database file MCP tools
+func RegisterDatabaseTools(s *server.MCPServer) {
+	// sqlite_schema tool
+	sqliteSchema := mcp.NewTool("sqlite_schema",
+		mcp.WithDescription("List tables, views and indexes of a SQLite database file, or the columns of one table"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Path to the SQLite database file")),
+		mcp.WithString("table", mcp.Description("Table name to describe (default: list all objects)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 30)")),
+	)
+	s.AddTool(sqliteSchema, handlers.HandleSQLiteSchema)
+
+	// sqlite_query tool
+	sqliteQuery := mcp.NewTool("sqlite_query",
+		mcp.WithDescription("Run SQL against a SQLite database file and return rows as JSON (read-only unless allow_write is set)"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Path to the SQLite database file")),
+		mcp.WithString("query", mcp.Required(), mcp.Description("SQL to execute; use ?1, ?2, ... for parameters")),
+		mcp.WithString("params", mcp.Description("JSON array of parameter values bound to ?1..?N")),
+		mcp.WithBoolean("allow_write", mcp.Description("Open the database read-write (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 30)")),
+	)
+	s.AddTool(sqliteQuery, handlers.HandleSQLiteQuery)
+}
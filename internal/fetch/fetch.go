@@ -14,9 +14,9 @@ func RegisterFetchTools(s *server.MCPServer) {
 		mcp.WithDescription("Fetch represents a structured HTTP request for fetching resources"),
 		mcp.WithString("url", mcp.Required(), mcp.Description("URL to fetch")),
 		mcp.WithString("method", mcp.Description("HTTP method (default: GET)")),
-		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string")),
+		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string; values may reference stored secrets as {{secret:NAME}}")),
 		mcp.WithString("body", mcp.Description("Request body")),
-		mcp.WithNumber("timeout", mcp.Description("Request timeout in seconds (default: 30)")),
+		mcp.WithNumber("timeout", mcp.Description("Request timeout in seconds (default: fetchTimeoutSeconds, at most maxFetchTimeoutSeconds)")),
 		mcp.WithBoolean("follow_redirects", mcp.Description("Follow HTTP redirects (default: true)")),
 		mcp.WithNumber("max_redirects", mcp.Description("Maximum number of redirects to follow (default: 10)")),
 	)
@@ -27,7 +27,7 @@ func RegisterFetchTools(s *server.MCPServer) {
 		mcp.WithDescription("Fetch web content with options for headers, method, and body"),
 		mcp.WithString("url", mcp.Required(), mcp.Description("URL to fetch content from")),
 		mcp.WithString("method", mcp.Description("HTTP method (default: GET)")),
-		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string")),
+		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string; values may reference stored secrets as {{secret:NAME}}")),
 		mcp.WithString("body", mcp.Description("Request body")),
 		mcp.WithString("user_agent", mcp.Description("Custom User-Agent string")),
 		mcp.WithBoolean("include_headers", mcp.Description("Include response headers in output (default: false)")),
@@ -40,11 +40,11 @@ func RegisterFetchTools(s *server.MCPServer) {
 		mcp.WithDescription("Fetch a file from a URL and save it locally"),
 		mcp.WithString("url", mcp.Required(), mcp.Description("URL of the file to download")),
 		mcp.WithString("filepath", mcp.Required(), mcp.Description("Local path to save the file")),
-		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string")),
+		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string; values may reference stored secrets as {{secret:NAME}}")),
 		mcp.WithBoolean("overwrite", mcp.Description("Overwrite existing file (default: false)")),
 		mcp.WithBoolean("resume", mcp.Description("Resume partial downloads (default: false)")),
 		mcp.WithBoolean("verify_checksum", mcp.Description("Verify file integrity if checksum available (default: false)")),
-		mcp.WithString("expected_checksum", mcp.Description("Expected file checksum (SHA256)")),
+		mcp.WithString("expected_checksum", mcp.Description("Expected file checksum: a SHA-256, SHA-1, MD5 or xxHash hex digest, optionally prefixed with its algorithm like sha1:")),
 	)
 	s.AddTool(fetchWebFile, handlers.HandleFetchWebFile)
 
@@ -53,11 +53,11 @@ func RegisterFetchTools(s *server.MCPServer) {
 		mcp.WithDescription("Fetch an image from a URL and save it locally"),
 		mcp.WithString("url", mcp.Required(), mcp.Description("URL of the image to download")),
 		mcp.WithString("filepath", mcp.Required(), mcp.Description("Local path to save the image")),
-		mcp.WithString("format", mcp.Description("Expected image format (jpg, png, gif, webp)")),
+		mcp.WithString("format", mcp.Description("Expected image format (jpg, png, gif, bmp, webp, tiff)")),
 		mcp.WithBoolean("validate_image", mcp.Description("Validate that downloaded content is an image (default: true)")),
 		mcp.WithNumber("max_size_mb", mcp.Description("Maximum file size in MB (default: 50)")),
 		mcp.WithBoolean("convert_format", mcp.Description("Convert to specified format if different (default: false)")),
-		mcp.WithString("quality", mcp.Description("Image quality for conversion (default: 85)")),
+		mcp.WithNumber("quality", mcp.Description("Quality from 1 to 100 of JPEG and WebP conversions (default: 85)")),
 	)
 	s.AddTool(fetchWebImage, handlers.HandleFetchWebImage)
 
@@ -65,7 +65,7 @@ func RegisterFetchTools(s *server.MCPServer) {
 	fetchWebJSON := mcp.NewTool("fetch_web_json",
 		mcp.WithDescription("Fetch JSON data from a URL and parse it"),
 		mcp.WithString("url", mcp.Required(), mcp.Description("URL to fetch JSON from")),
-		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string")),
+		mcp.WithString("headers", mcp.Description("HTTP headers as JSON string; values may reference stored secrets as {{secret:NAME}}")),
 		mcp.WithString("method", mcp.Description("HTTP method (default: GET)")),
 		mcp.WithString("body", mcp.Description("Request body for POST/PUT requests")),
 		mcp.WithBoolean("pretty_print", mcp.Description("Pretty print JSON response (default: true)")),
@@ -89,7 +89,7 @@ func RegisterFetchTools(s *server.MCPServer) {
 	checkURLStatus := mcp.NewTool("check_url_status",
 		mcp.WithDescription("Check the status and availability of one or more URLs"),
 		mcp.WithString("urls", mcp.Required(), mcp.Description("Single URL or JSON array of URLs to check")),
-		mcp.WithNumber("timeout", mcp.Description("Request timeout in seconds (default: 10)")),
+		mcp.WithNumber("timeout", mcp.Description("Request timeout in seconds (default: fetchTimeoutSeconds, at most maxFetchTimeoutSeconds)")),
 		mcp.WithBoolean("follow_redirects", mcp.Description("Follow redirects (default: true)")),
 		mcp.WithBoolean("check_ssl", mcp.Description("Check SSL certificate validity (default: true)")),
 		mcp.WithBoolean("include_headers", mcp.Description("Include response headers (default: false)")),
@@ -18,14 +18,42 @@ func RegisterFilesystemTools(s *server.MCPServer) {
 	)
 	s.AddTool(readFile, handlers.HandleReadFile)
 
+	// read_file_chunk tool
+	readFileChunk := mcp.NewTool("read_file_chunk",
+		mcp.WithDescription("Read a byte range from a large file without loading it into memory, with a continuation token for the next chunk"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to read")),
+		mcp.WithNumber("byte_offset", mcp.Description("Byte offset to start reading from (default: 0)")),
+		mcp.WithNumber("max_bytes", mcp.Description("Maximum number of bytes to return (default: 65536)")),
+		mcp.WithBoolean("align_lines", mcp.Description("End the chunk at a line boundary when possible (default: true)")),
+		mcp.WithString("continuation_token", mcp.Description("Token from a previous chunk; overrides byte_offset")),
+	)
+	s.AddTool(readFileChunk, handlers.HandleReadFileChunk)
+
+	// read_spreadsheet tool
+	readSpreadsheet := mcp.NewTool("read_spreadsheet",
+		mcp.WithDescription("List sheets and extract cell ranges from .xlsx spreadsheets as CSV or JSON"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Path to the .xlsx file")),
+		mcp.WithString("sheet", mcp.Description("Sheet name or 1-based index (default: first sheet)")),
+		mcp.WithString("range", mcp.Description("Cell range to extract, e.g. A1:D20 (default: entire sheet). At most 1024 columns and 1,000,000 cells are returned")),
+		mcp.WithString("output_format", mcp.Description("Output format: csv or json (default: csv)")),
+		mcp.WithBoolean("list_sheets", mcp.Description("Only list the sheets in the workbook (default: false)")),
+		mcp.WithNumber("max_rows", mcp.Description("Maximum rows to return (default: fileReadLineLimit)")),
+	)
+	s.AddTool(readSpreadsheet, handlers.HandleReadSpreadsheet)
+
 	// write_file tool
 	writeFile := mcp.NewTool("write_file",
-		mcp.WithDescription("Write file contents with options for rewrite or append mode"),
+		mcp.WithDescription("Write file contents with options for rewrite or append mode; content longer than fileWriteLineLimit must be written in chunks"),
 		mcp.WithString("path", mcp.Required(), mcp.Description("File path to write")),
 		mcp.WithString("content", mcp.Required(), mcp.Description("Content to write")),
 		mcp.WithBoolean("append", mcp.Description("Append to file instead of overwriting")),
+		mcp.WithNumber("expected_offset", mcp.Description("For chunked appends, the file size in bytes the previous write reported; the write fails if the file size differs")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before writing (default: false)")),
 		mcp.WithString("encoding", mcp.Description("File encoding (default: utf-8)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(writeFile, handlers.HandleWriteFile)
 
@@ -45,9 +73,27 @@ func RegisterFilesystemTools(s *server.MCPServer) {
 		mcp.WithBoolean("include_hidden", mcp.Description("Include hidden files (default: false)")),
 		mcp.WithBoolean("recursive", mcp.Description("List recursively (default: false)")),
 		mcp.WithString("sort_by", mcp.Description("Sort by: name, size, modified (default: name)")),
+		mcp.WithString("sort_order", mcp.Description("Sort order: asc or desc (default: asc)")),
+		mcp.WithNumber("offset", mcp.Description("Number of entries to skip (default: 0)")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of entries to return (default: unlimited)")),
+		mcp.WithString("output_format", mcp.Description("Output format: text or json (default: text)")),
 	)
 	s.AddTool(listDir, handlers.HandleListDirectory)
 
+	// tree tool
+	tree := mcp.NewTool("tree",
+		mcp.WithDescription("Render a directory hierarchy as an ASCII tree or JSON"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Root directory")),
+		mcp.WithNumber("max_depth", mcp.Description("Maximum depth to descend, 0 for unlimited (default: 3)")),
+		mcp.WithNumber("max_entries", mcp.Description("Maximum number of entries to include, 0 for unlimited (default: 500)")),
+		mcp.WithBoolean("show_size", mcp.Description("Annotate files with their size (default: false)")),
+		mcp.WithBoolean("include_hidden", mcp.Description("Include hidden files (default: false)")),
+		mcp.WithBoolean("respect_gitignore", mcp.Description("Skip entries ignored by the root .gitignore (default: true)")),
+		mcp.WithBoolean("directories_only", mcp.Description("Only show directories (default: false)")),
+		mcp.WithString("output_format", mcp.Description("Output format: text or json (default: text)")),
+	)
+	s.AddTool(tree, handlers.HandleTree)
+
 	// search_files tool
 	searchFiles := mcp.NewTool("search_files",
 		mcp.WithDescription("Find files by name using pattern matching"),
@@ -56,17 +102,117 @@ func RegisterFilesystemTools(s *server.MCPServer) {
 		mcp.WithBoolean("case_sensitive", mcp.Description("Case sensitive search (default: false)")),
 		mcp.WithBoolean("include_directories", mcp.Description("Include directories in results (default: false)")),
 		mcp.WithNumber("max_depth", mcp.Description("Maximum search depth (default: unlimited)")),
+		mcp.WithNumber("max_results", mcp.Description("Maximum number of results, 0 for unlimited (default: 1000)")),
 	)
 	s.AddTool(searchFiles, handlers.HandleSearchFiles)
 
+	// glob tool
+	glob := mcp.NewTool("glob",
+		mcp.WithDescription("Find files matching a glob pattern with ** and brace expansion support (e.g. src/**/*.{go,mod})"),
+		mcp.WithString("pattern", mcp.Required(), mcp.Description("Glob pattern relative to root")),
+		mcp.WithString("root", mcp.Description("Root directory to match from (default: current)")),
+		mcp.WithNumber("max_results", mcp.Description("Maximum number of matches to return (default: 1000)")),
+		mcp.WithBoolean("include_directories", mcp.Description("Include directories in results (default: false)")),
+		mcp.WithBoolean("include_hidden", mcp.Description("Include hidden files and directories (default: false)")),
+		mcp.WithBoolean("case_sensitive", mcp.Description("Case sensitive matching (default: true)")),
+	)
+	s.AddTool(glob, handlers.HandleGlob)
+
 	// get_file_info tool
 	getFileInfo := mcp.NewTool("get_file_info",
 		mcp.WithDescription("Retrieve detailed metadata about a file or directory"),
 		mcp.WithString("path", mcp.Required(), mcp.Description("File or directory path")),
 		mcp.WithBoolean("include_checksum", mcp.Description("Calculate file checksum (default: false)")),
+		mcp.WithBoolean("include_xattrs", mcp.Description("Include extended attributes (default: false)")),
 	)
 	s.AddTool(getFileInfo, handlers.HandleGetFileInfo)
 
+	// get_image_info tool
+	getImageInfo := mcp.NewTool("get_image_info",
+		mcp.WithDescription("Get dimensions, format, color depth and EXIF data (camera, GPS, timestamps) of a local image"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Image file path (jpeg, png, gif, bmp, webp, tiff)")),
+		mcp.WithBoolean("include_all_tags", mcp.Description("Include every EXIF tag as raw values (default: false)")),
+	)
+	s.AddTool(getImageInfo, handlers.HandleGetImageInfo)
+
+	// convert_image tool
+	convertImage := mcp.NewTool("convert_image",
+		mcp.WithDescription("Convert a local image to another format, optionally cropping, rotating and flipping it. Reads jpeg, png, gif, bmp, webp and tiff; writing webp needs cwebp."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Image file to convert")),
+		mcp.WithString("format", mcp.Description("Format to write: jpeg, png, gif, bmp, webp or tiff (default: the extension of output_path)")),
+		mcp.WithString("output_path", mcp.Description("File to write (default: path with the extension of format)")),
+		mcp.WithNumber("quality", mcp.Description("Quality from 1 to 100 of jpeg and webp output (default: 85)")),
+		mcp.WithNumber("crop_x", mcp.Description("Left edge of the area to keep, in pixels (default: 0)")),
+		mcp.WithNumber("crop_y", mcp.Description("Top edge of the area to keep, in pixels (default: 0)")),
+		mcp.WithNumber("crop_width", mcp.Description("Width of the area to keep; cropping needs crop_width and crop_height")),
+		mcp.WithNumber("crop_height", mcp.Description("Height of the area to keep")),
+		mcp.WithNumber("rotate", mcp.Description("Degrees to rotate clockwise after cropping: 90, 180 or 270")),
+		mcp.WithString("flip", mcp.Description("Mirror the image after rotating: horizontal or vertical")),
+		mcp.WithBoolean("overwrite", mcp.Description("Overwrite output_path if it exists (default: false)")),
+	)
+	s.AddTool(convertImage, handlers.HandleConvertImage)
+
+	// resize_image tool
+	resizeImage := mcp.NewTool("resize_image",
+		mcp.WithDescription("Resize a local image. With only width or height the aspect ratio is kept; with both, fit decides how the image is made to fit. Images are limited to 32768 pixels per side and 100 megapixels."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Image file to resize")),
+		mcp.WithNumber("width", mcp.Description("Width in pixels")),
+		mcp.WithNumber("height", mcp.Description("Height in pixels")),
+		mcp.WithString("fit", mcp.Description("contain scales within width and height, cover fills them and crops the overflow, fill stretches to exactly them (default: contain)")),
+		mcp.WithString("output_path", mcp.Description("File to write, in the format of its extension (default: replace path)")),
+		mcp.WithNumber("quality", mcp.Description("Quality from 1 to 100 of jpeg and webp output (default: 85)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Back up path before replacing it (default: false)")),
+		mcp.WithBoolean("overwrite", mcp.Description("Overwrite output_path if it exists (default: false)")),
+	)
+	s.AddTool(resizeImage, handlers.HandleResizeImage)
+
+	// probe_media tool
+	probeMedia := mcp.NewTool("probe_media",
+		mcp.WithDescription("Report duration, codecs, bitrate and resolution of an audio or video file using ffprobe, falling back to native WAV/MP4 parsing"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Media file path")),
+		mcp.WithBoolean("use_ffprobe", mcp.Description("Use ffprobe when it is installed (default: true)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout for ffprobe in seconds (default: 30)")),
+	)
+	s.AddTool(probeMedia, handlers.HandleProbeMedia)
+
+	// get_xattr tool
+	getXattr := mcp.NewTool("get_xattr",
+		mcp.WithDescription("Read extended attributes of a file"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path")),
+		mcp.WithString("name", mcp.Description("Attribute name (default: list all attributes)")),
+	)
+	s.AddTool(getXattr, handlers.HandleGetXattr)
+
+	// set_xattr tool
+	setXattr := mcp.NewTool("set_xattr",
+		mcp.WithDescription("Set or remove an extended attribute on a file"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path")),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Attribute name (e.g. user.comment)")),
+		mcp.WithString("value", mcp.Description("Attribute value")),
+		mcp.WithBoolean("remove", mcp.Description("Remove the attribute instead of setting it (default: false)")),
+	)
+	s.AddTool(setXattr, handlers.HandleSetXattr)
+
+	// file_stats tool
+	fileStats := mcp.NewTool("file_stats",
+		mcp.WithDescription("Get line, word and byte counts for a file without reading its full contents"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to analyze")),
+		mcp.WithNumber("head", mcp.Description("Also return the first N lines of the file (default: 0)")),
+	)
+	s.AddTool(fileStats, handlers.HandleFileStats)
+
+	// touch_file tool
+	touchFile := mcp.NewTool("touch_file",
+		mcp.WithDescription("Create an empty file or update its access and modification times"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to touch")),
+		mcp.WithString("timestamp", mcp.Description("RFC3339 timestamp to set (default: now)")),
+		mcp.WithString("reference", mcp.Description("Copy times from this file instead of using timestamp")),
+		mcp.WithBoolean("access_time", mcp.Description("Update the access time (default: true)")),
+		mcp.WithBoolean("modification_time", mcp.Description("Update the modification time (default: true)")),
+		mcp.WithBoolean("no_create", mcp.Description("Do not create the file if it does not exist (default: false)")),
+	)
+	s.AddTool(touchFile, handlers.HandleTouchFile)
+
 	copyFile := mcp.NewTool("copy_file",
 		mcp.WithDescription("Copy a file or directory to another location"),
 		mcp.WithString("source", mcp.Required(), mcp.Description("Source path")),
@@ -81,18 +227,63 @@ func RegisterFilesystemTools(s *server.MCPServer) {
 		mcp.WithString("source", mcp.Required(), mcp.Description("Source path")),
 		mcp.WithString("destination", mcp.Required(), mcp.Description("Destination path")),
 		mcp.WithBoolean("overwrite", mcp.Description("Overwrite destination if exists (default: false)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Check and describe the move without performing it (default: false)")),
 	)
 	s.AddTool(moveFile, handlers.HandleMoveFile)
 
+	// rename_files tool
+	renameFiles := mcp.NewTool("rename_files",
+		mcp.WithDescription("Batch rename files using regex or glob captures (e.g. IMG_(\\d+).jpeg -> photo-$1.jpg)"),
+		mcp.WithString("directory", mcp.Required(), mcp.Description("Directory containing the files")),
+		mcp.WithString("pattern", mcp.Required(), mcp.Description("Pattern matched against file names")),
+		mcp.WithString("replacement", mcp.Required(), mcp.Description("Replacement template, $1..$n refer to captures")),
+		mcp.WithString("pattern_type", mcp.Description("Pattern type: regex or glob (default: regex)")),
+		mcp.WithBoolean("recursive", mcp.Description("Include files in subdirectories (default: false)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Preview renames without applying them (default: true)")),
+	)
+	s.AddTool(renameFiles, handlers.HandleRenameFiles)
+
 	// delete_file tool
 	deleteFile := mcp.NewTool("delete_file",
 		mcp.WithDescription("Delete a file or directory"),
 		mcp.WithString("path", mcp.Required(), mcp.Description("Path to delete")),
 		mcp.WithBoolean("recursive", mcp.Description("Delete directories recursively (default: false)")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before deletion (default: false)")),
+		mcp.WithBoolean("secure_delete", mcp.Description("Overwrite file contents before unlinking; refused on SSDs and copy-on-write or network filesystems (default: false)")),
+		mcp.WithNumber("overwrite_passes", mcp.Description("Number of random overwrite passes for secure_delete (default: 1)")),
+		mcp.WithBoolean("ignore_storage_warnings", mcp.Description("Overwrite even when the storage may retain old data (default: false)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Describe what would be deleted without deleting (default: false)")),
 	)
 	s.AddTool(deleteFile, handlers.HandleDeleteFile)
 
+	// list_backups tool
+	listBackups := mcp.NewTool("list_backups",
+		mcp.WithDescription("List backups of a file, or of the files in a directory, newest first"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File or directory whose backups to list")),
+		mcp.WithBoolean("recursive", mcp.Description("Include backups in subdirectories (default: false)")),
+	)
+	s.AddTool(listBackups, handlers.HandleListBackups)
+
+	// restore_backup tool
+	restoreBackup := mcp.NewTool("restore_backup",
+		mcp.WithDescription("Restore a file from one of its backups"),
+		mcp.WithString("backup_path", mcp.Required(), mcp.Description("Backup file to restore")),
+		mcp.WithString("target", mcp.Description("Path to restore to (default: the original file)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Back up the current content before restoring (default: true)")),
+	)
+	s.AddTool(restoreBackup, handlers.HandleRestoreBackup)
+
+	// prune_backups tool
+	pruneBackups := mcp.NewTool("prune_backups",
+		mcp.WithDescription("Delete old backups, keeping the newest per file and removing those past a maximum age"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File or directory whose backups to prune")),
+		mcp.WithBoolean("recursive", mcp.Description("Include backups in subdirectories (default: false)")),
+		mcp.WithNumber("max_count", mcp.Description("Backups to keep per file (default: backupMaxCount config value)")),
+		mcp.WithNumber("max_age_days", mcp.Description("Remove backups older than this many days (default: backupMaxAgeDays config value)")),
+		mcp.WithBoolean("dry_run", mcp.Description("List the backups that would be removed without deleting them (default: false)")),
+	)
+	s.AddTool(pruneBackups, handlers.HandlePruneBackups)
+
 	// find_in_files tool
 	findInFiles := mcp.NewTool("find_in_files",
 		mcp.WithDescription("Search for text patterns within file contents"),
@@ -102,6 +293,84 @@ func RegisterFilesystemTools(s *server.MCPServer) {
 		mcp.WithBoolean("case_sensitive", mcp.Description("Case sensitive search (default: false)")),
 		mcp.WithBoolean("regex", mcp.Description("Use regular expressions (default: false)")),
 		mcp.WithNumber("context_lines", mcp.Description("Number of context lines around matches (default: 0)")),
+		mcp.WithNumber("max_results", mcp.Description("Maximum number of matches to return (default: 200)")),
+		mcp.WithNumber("max_matches_per_file", mcp.Description("Maximum matches collected per file (default: unlimited)")),
+		mcp.WithNumber("offset", mcp.Description("Number of matches to skip for pagination (default: 0)")),
+		mcp.WithString("sort_by", mcp.Description("Result order: path, or matches to rank files by match count (default: path)")),
+		mcp.WithString("output_format", mcp.Description("Output format: text or json with file, line, column, match and context (default: text)")),
 	)
 	s.AddTool(findInFiles, handlers.HandleFindInFiles)
+
+	// create_workspace tool
+	createWorkspace := mcp.NewTool("create_workspace",
+		mcp.WithDescription("Create a temporary scratch directory that is allowed for this session and removed on shutdown"),
+		mcp.WithString("prefix", mcp.Description("Directory name prefix (default: jarvis-workspace)")),
+	)
+	s.AddTool(createWorkspace, handlers.HandleCreateWorkspace)
+
+	// cleanup_workspace tool
+	cleanupWorkspace := mcp.NewTool("cleanup_workspace",
+		mcp.WithDescription("Remove a scratch workspace and revoke access to it"),
+		mcp.WithString("id", mcp.Description("Workspace ID returned by create_workspace")),
+		mcp.WithBoolean("all", mcp.Description("Remove all workspaces created in this session (default: false)")),
+	)
+	s.AddTool(cleanupWorkspace, handlers.HandleCleanupWorkspace)
+
+	// encrypt_file tool
+	encryptFile := mcp.NewTool("encrypt_file",
+		mcp.WithDescription("Encrypt a file with AES-256-GCM using a passphrase or key file (PBKDF2-SHA256 key derivation)"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File to encrypt")),
+		mcp.WithString("output_path", mcp.Description("Encrypted output path (default: path + .enc)")),
+		mcp.WithString("passphrase", mcp.Description("Passphrase to derive the key from")),
+		mcp.WithString("key_file", mcp.Description("File whose contents are used as the secret instead of a passphrase")),
+		mcp.WithBoolean("remove_original", mcp.Description("Delete the plaintext file after successful encryption (default: false)")),
+		mcp.WithBoolean("overwrite", mcp.Description("Overwrite the output file if it exists (default: false)")),
+	)
+	s.AddTool(encryptFile, handlers.HandleEncryptFile)
+
+	// decrypt_file tool
+	decryptFile := mcp.NewTool("decrypt_file",
+		mcp.WithDescription("Decrypt a file created by encrypt_file; no output is written if the key is wrong or the file was modified"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Encrypted file to decrypt")),
+		mcp.WithString("output_path", mcp.Description("Decrypted output path (default: path without .enc)")),
+		mcp.WithString("passphrase", mcp.Description("Passphrase used for encryption")),
+		mcp.WithString("key_file", mcp.Description("Key file used for encryption")),
+		mcp.WithBoolean("overwrite", mcp.Description("Overwrite the output file if it exists (default: false)")),
+	)
+	s.AddTool(decryptFile, handlers.HandleDecryptFile)
+
+	// create_manifest tool
+	createManifest := mcp.NewTool("create_manifest",
+		mcp.WithDescription("Walk a directory tree and record path, size, mtime and SHA-256 of every file"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Root directory of the tree")),
+		mcp.WithString("output_path", mcp.Description("Write the manifest JSON to this file instead of returning it")),
+		mcp.WithString("exclude", mcp.Description("Comma-separated glob patterns of paths to skip, e.g. **/*.log,node_modules")),
+		mcp.WithBoolean("include_hidden", mcp.Description("Include hidden files and directories (default: false)")),
+		mcp.WithBoolean("respect_gitignore", mcp.Description("Skip paths matched by the root .gitignore (default: false)")),
+	)
+	s.AddTool(createManifest, handlers.HandleCreateManifest)
+
+	// verify_manifest tool
+	verifyManifest := mcp.NewTool("verify_manifest",
+		mcp.WithDescription("Compare a directory tree against a manifest and report added, removed and changed files"),
+		mcp.WithString("manifest_path", mcp.Required(), mcp.Description("Manifest file created by create_manifest")),
+		mcp.WithString("path", mcp.Description("Root directory to verify (default: root recorded in the manifest)")),
+		mcp.WithString("exclude", mcp.Description("Comma-separated glob patterns of paths to skip")),
+		mcp.WithBoolean("include_hidden", mcp.Description("Include hidden files and directories (default: false)")),
+		mcp.WithBoolean("respect_gitignore", mcp.Description("Skip paths matched by the root .gitignore (default: false)")),
+		mcp.WithBoolean("compare_mtime", mcp.Description("Also report files whose modification time changed (default: false)")),
+	)
+	s.AddTool(verifyManifest, handlers.HandleVerifyManifest)
+
+	// find_duplicate_files tool
+	findDuplicates := mcp.NewTool("find_duplicate_files",
+		mcp.WithDescription("Find files with identical contents under a directory. Only files of equal size are hashed; groups are listed by the space they waste."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Root directory to search")),
+		mcp.WithString("algorithm", mcp.Description("Hash algorithm: xxhash, sha256, sha1 or md5 (default: xxhash)")),
+		mcp.WithNumber("min_size", mcp.Description("Skip files smaller than this many bytes (default: 1)")),
+		mcp.WithString("exclude", mcp.Description("Comma-separated glob patterns of paths to skip")),
+		mcp.WithBoolean("include_hidden", mcp.Description("Include hidden files and directories (default: false)")),
+		mcp.WithBoolean("respect_gitignore", mcp.Description("Skip paths matched by the root .gitignore (default: false)")),
+	)
+	s.AddTool(findDuplicates, handlers.HandleFindDuplicateFiles)
 }
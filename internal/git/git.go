@@ -0,0 +1,104 @@
+package git
+
+import (
+	"jarvis/handlers"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterGitTools registers the git MCP tools
+func RegisterGitTools(s *server.MCPServer) {
+	// git_status tool
+	gitStatus := mcp.NewTool("git_status",
+		mcp.WithDescription("Show the current branch, upstream ahead/behind counts and changed files of a git repository as JSON"),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+	)
+	s.AddTool(gitStatus, handlers.HandleGitStatus)
+
+	// git_diff tool
+	gitDiff := mcp.NewTool("git_diff",
+		mcp.WithDescription("Show a diff as JSON with per-file added/deleted line counts and the unified patch. By default compares the work tree with the index."),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithBoolean("staged", mcp.Description("Compare the index with HEAD (or ref) instead, showing what would be committed (default: false)")),
+		mcp.WithString("ref", mcp.Description("Commit, branch or range (e.g. 'main...HEAD') to compare against")),
+		mcp.WithString("paths", mcp.Description("Comma-separated paths, relative to repo_path, to limit the diff to")),
+		mcp.WithNumber("context_lines", mcp.Description("Lines of context around each change (default: 3)")),
+	)
+	s.AddTool(gitDiff, handlers.HandleGitDiff)
+
+	// git_log tool
+	gitLog := mcp.NewTool("git_log",
+		mcp.WithDescription("List commits, newest first, as JSON with hash, author, date, subject and body"),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("ref", mcp.Description("Branch, commit or range to list (default: HEAD)")),
+		mcp.WithString("path", mcp.Description("Only commits that changed this path")),
+		mcp.WithString("author", mcp.Description("Only commits whose author name or email matches this pattern")),
+		mcp.WithString("since", mcp.Description("Only commits after this date, e.g. '2024-01-31' or '2 weeks ago'")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of commits (default: 20)")),
+	)
+	s.AddTool(gitLog, handlers.HandleGitLog)
+
+	// git_blame tool
+	gitBlame := mcp.NewTool("git_blame",
+		mcp.WithDescription("Show the commit, author and date that last changed each line of a file as JSON"),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File to blame, relative to repo_path")),
+		mcp.WithNumber("start_line", mcp.Description("First line to include (1-based)")),
+		mcp.WithNumber("end_line", mcp.Description("Last line to include")),
+		mcp.WithString("ref", mcp.Description("Blame the file as of this commit (default: the work tree)")),
+	)
+	s.AddTool(gitBlame, handlers.HandleGitBlame)
+
+	// git_branch tool
+	gitBranch := mcp.NewTool("git_branch",
+		mcp.WithDescription("List, create or delete branches. Creating a branch does not switch to it; use git_checkout for that."),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("action", mcp.Description("list (default), create or delete")),
+		mcp.WithString("name", mcp.Description("Branch to create or delete")),
+		mcp.WithString("start_point", mcp.Description("Commit or branch to create the branch at (default: HEAD)")),
+		mcp.WithBoolean("force", mcp.Description("Delete the branch even if it is not merged (default: false)")),
+		mcp.WithBoolean("include_remote", mcp.Description("Also list remote-tracking branches (default: false)")),
+	)
+	s.AddTool(gitBranch, handlers.HandleGitBranch)
+
+	// git_add tool
+	gitAdd := mcp.NewTool("git_add",
+		mcp.WithDescription("Stage files for the next commit and return the resulting status"),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("paths", mcp.Description("Comma-separated paths to stage, relative to repo_path")),
+		mcp.WithBoolean("all", mcp.Description("Stage every change, including new and deleted files, instead of paths (default: false)")),
+	)
+	s.AddTool(gitAdd, handlers.HandleGitAdd)
+
+	// git_commit tool
+	gitCommit := mcp.NewTool("git_commit",
+		mcp.WithDescription("Commit the staged changes and return the new commit as JSON. Repository hooks are never run."),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("message", mcp.Required(), mcp.Description("Commit message")),
+		mcp.WithBoolean("amend", mcp.Description("Replace the last commit instead of creating a new one (default: false)")),
+		mcp.WithBoolean("allow_empty", mcp.Description("Allow a commit with no changes (default: false)")),
+	)
+	s.AddTool(gitCommit, handlers.HandleGitCommit)
+
+	// git_stash tool
+	gitStash := mcp.NewTool("git_stash",
+		mcp.WithDescription("Stash work tree changes, or list, apply, pop or drop stash entries"),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("action", mcp.Description("push (default), pop, apply, drop or list")),
+		mcp.WithString("message", mcp.Description("Message for push")),
+		mcp.WithBoolean("include_untracked", mcp.Description("Also stash untracked files on push (default: false)")),
+		mcp.WithString("stash", mcp.Description("Entry for pop, apply or drop, e.g. 'stash@{1}' (default: the newest)")),
+	)
+	s.AddTool(gitStash, handlers.HandleGitStash)
+
+	// git_checkout tool
+	gitCheckout := mcp.NewTool("git_checkout",
+		mcp.WithDescription("Switch to a branch or commit, optionally creating the branch, or restore paths from a commit or the index without switching"),
+		mcp.WithString("repo_path", mcp.Required(), mcp.Description("Path to the repository or a directory inside it; must be in an allowed directory")),
+		mcp.WithString("target", mcp.Description("Branch or commit to switch to, or to restore paths from (default for paths: the index)")),
+		mcp.WithBoolean("create", mcp.Description("Create target as a new branch at HEAD and switch to it (default: false)")),
+		mcp.WithString("paths", mcp.Description("Comma-separated paths to restore, relative to repo_path; local changes to them are discarded")),
+	)
+	s.AddTool(gitCheckout, handlers.HandleGitCheckout)
+}
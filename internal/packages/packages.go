@@ -0,0 +1,39 @@
+package packages
+
+import (
+	"jarvis/handlers"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterPackageTools registers the package manager MCP tools
+func RegisterPackageTools(s *server.MCPServer) {
+	// list_packages tool
+	listPackages := mcp.NewTool("list_packages",
+		mcp.WithDescription("List installed packages and their versions as JSON"),
+		mcp.WithString("manager", mcp.Required(), mcp.Description("Package manager: apt, dnf, brew, npm (global packages) or pip")),
+		mcp.WithString("filter", mcp.Description("Only packages whose name contains this text (case-insensitive)")),
+	)
+	s.AddTool(listPackages, handlers.HandleListPackages)
+
+	// install_packages tool
+	installPackages := mcp.NewTool("install_packages",
+		mcp.WithDescription("Install packages with a package manager. Requires the packageManagementEnabled setting, and every package must match an allowedPackages entry. Returns JSON with exit_code, stdout and stderr."),
+		mcp.WithString("manager", mcp.Required(), mcp.Description("Package manager: apt, dnf, brew, npm (global packages) or pip")),
+		mcp.WithString("packages", mcp.Required(), mcp.Description("Comma-separated packages, optionally with versions in the manager's syntax, e.g. 'curl,jq' or 'requests==2.31.0'")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show what would be installed without changing anything (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 600)")),
+	)
+	s.AddTool(installPackages, handlers.HandleInstallPackages)
+
+	// remove_packages tool
+	removePackages := mcp.NewTool("remove_packages",
+		mcp.WithDescription("Remove packages with a package manager, under the same opt-in and allowedPackages rules as install_packages"),
+		mcp.WithString("manager", mcp.Required(), mcp.Description("Package manager: apt, dnf, brew, npm (global packages) or pip")),
+		mcp.WithString("packages", mcp.Required(), mcp.Description("Comma-separated package names")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show what would be removed without changing anything (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 600)")),
+	)
+	s.AddTool(removePackages, handlers.HandleRemovePackages)
+}
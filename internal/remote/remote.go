@@ -0,0 +1,41 @@
+package remote
+
+import (
+	"jarvis/handlers"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterRemoteTools registers the MCP tools that work on remote hosts
+// over SSH
+func RegisterRemoteTools(s *server.MCPServer) {
+	// ssh_execute_command tool
+	sshExecute := mcp.NewTool("ssh_execute_command",
+		mcp.WithDescription("Run a command on a configured SSH host; returns JSON with exit_code, stdout, stderr, duration_ms and timed_out like execute_command. The host key must already be known and authentication must not prompt."),
+		mcp.WithString("host", mcp.Required(), mcp.Description("Name of a host added with save_ssh_host (see list_ssh_hosts)")),
+		mcp.WithString("command", mcp.Required(), mcp.Description("Command to run in the remote user's login shell")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: commandTimeoutSeconds, at most maxCommandTimeoutSeconds)")),
+		mcp.WithBoolean("stream_output", mcp.Description("Send output lines to the client as progress or log notifications while it runs (default: true)")),
+		mcp.WithNumber("max_output_bytes", mcp.Description("Return at most this many bytes of output, keeping the start and the end (default: maxOutputBytes config value)")),
+	)
+	s.AddTool(sshExecute, handlers.HandleSSHExecuteCommand)
+
+	// ssh_copy_file tool
+	sshCopy := mcp.NewTool("ssh_copy_file",
+		mcp.WithDescription("Copy a file or directory between this machine and a configured SSH host with scp"),
+		mcp.WithString("host", mcp.Required(), mcp.Description("Name of a host added with save_ssh_host")),
+		mcp.WithString("direction", mcp.Required(), mcp.Description("upload to copy local_path to the host, download to copy remote_path here")),
+		mcp.WithString("local_path", mcp.Required(), mcp.Description("Local path, which must be in an allowed directory")),
+		mcp.WithString("remote_path", mcp.Required(), mcp.Description("Path on the host; relative paths start in the remote user's home directory")),
+		mcp.WithBoolean("recursive", mcp.Description("Copy directories recursively (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 300)")),
+	)
+	s.AddTool(sshCopy, handlers.HandleSSHCopyFile)
+
+	// list_ssh_hosts tool
+	listHosts := mcp.NewTool("list_ssh_hosts",
+		mcp.WithDescription("List the configured SSH hosts with their address, port, user and key settings"),
+	)
+	s.AddTool(listHosts, handlers.HandleListSSHHosts)
+}
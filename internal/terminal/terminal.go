@@ -11,34 +11,69 @@ import (
 func RegisterTerminalTools(s *server.MCPServer) {
 	// execute_command tool
 	executeCmd := mcp.NewTool("execute_command",
-		mcp.WithDescription("Execute a terminal command with configurable timeout and shell selection"),
+		mcp.WithDescription("Execute a terminal command with configurable timeout and shell selection; returns JSON with exit_code, stdout, stderr, duration_ms and timed_out"),
 		mcp.WithString("command", mcp.Required(), mcp.Description("Command to execute")),
-		mcp.WithString("shell", mcp.Description("Shell to use (default: from config)")),
-		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 30)")),
-		mcp.WithString("working_dir", mcp.Description("Working directory for command execution")),
-		mcp.WithBoolean("capture_stderr", mcp.Description("Capture stderr separately (default: false)")),
+		mcp.WithString("shell", mcp.Description("Shell to use, such as bash, zsh, fish, pwsh or cmd (default: from config)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: commandTimeoutSeconds, at most maxCommandTimeoutSeconds)")),
+		mcp.WithString("working_dir", mcp.Description("Working directory for command execution (default: the directory set with set_working_directory)")),
+		mcp.WithBoolean("capture_stderr", mcp.Description("Return stderr in its own field; when false it is interleaved into stdout (default: true)")),
+		mcp.WithBoolean("stream_output", mcp.Description("Send output lines to the client as progress or log notifications while it runs (default: true)")),
+		mcp.WithNumber("max_output_bytes", mcp.Description("Return at most this many bytes of output, keeping the start and the end (default: maxOutputBytes config value)")),
+		mcp.WithString("output_file", mcp.Description("Also write the complete output to this file, which must be in an allowed directory and counts against the write quotas; it is replaced once the command finishes and its path is returned")),
+		mcp.WithString("run_as_user", mcp.Description("Run the command as this user, which must be listed in the runAsUsers setting; needs the server to run as root or passwordless sudo")),
+		mcp.WithString("env", mcp.Description(`Extra environment variables as a JSON object; values may reference stored secrets, e.g. {"API_TOKEN":"{{secret:api_token}}"}`)),
 	)
 	s.AddTool(executeCmd, handlers.HandleExecuteCommand)
 
+	// execute_commands tool
+	executeCommands := mcp.NewTool("execute_commands",
+		mcp.WithDescription("Run several independent commands concurrently, e.g. tests of several packages at once. Returns JSON with succeeded and failed counts and a result per command, in the order given, like execute_command."),
+		mcp.WithString("commands", mcp.Required(), mcp.Description(`Commands as a JSON array of strings or objects with command and optionally shell, working_dir and timeout_seconds, e.g. ["go test ./a/...", {"command":"npm test","working_dir":"web"}]`)),
+		mcp.WithNumber("max_parallel", mcp.Description("Maximum number of commands running at once (default: 4)")),
+		mcp.WithString("shell", mcp.Description("Default shell for the commands (default: from config)")),
+		mcp.WithString("working_dir", mcp.Description("Default working directory (default: the directory set with set_working_directory)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Default timeout per command in seconds (default: commandTimeoutSeconds, at most maxCommandTimeoutSeconds)")),
+		mcp.WithNumber("max_output_bytes", mcp.Description("Maximum stdout and stderr bytes kept per command; longer output keeps its start and end (default: maxOutputBytes from config)")),
+	)
+	s.AddTool(executeCommands, handlers.HandleExecuteCommands)
+
 	// list_processes tool
 	listProcesses := mcp.NewTool("list_processes",
-		mcp.WithDescription("List all running processes with detailed information"),
-		mcp.WithString("filter", mcp.Description("Filter processes by name pattern")),
-		mcp.WithBoolean("include_threads", mcp.Description("Include thread information (default: false)")),
+		mcp.WithDescription("List running processes as JSON with PID, parent PID, user, status, CPU and memory usage, start time and command line"),
+		mcp.WithString("filter", mcp.Description("Only list processes whose name or command line contains this text (case-insensitive)")),
+		mcp.WithBoolean("include_threads", mcp.Description("Include the thread IDs of each process where the platform exposes them (default: false)")),
 	)
 	s.AddTool(listProcesses, handlers.HandleListProcesses)
 
 	// kill_process tool
 	killProcess := mcp.NewTool("kill_process",
-		mcp.WithDescription("Terminate a running process by PID"),
-		mcp.WithNumber("pid", mcp.Required(), mcp.Description("Process ID to terminate")),
+		mcp.WithDescription("Send a signal to a running process by PID, optionally force killing it if it has not exited after a grace period"),
+		mcp.WithNumber("pid", mcp.Required(), mcp.Description("Process ID to signal")),
 		mcp.WithBoolean("force", mcp.Description("Force kill with SIGKILL (default: false)")),
+		mcp.WithString("signal", mcp.Description("Signal name or number, such as SIGTERM, SIGKILL, SIGHUP, SIGINT, SIGSTOP, SIGCONT, SIGUSR1 or SIGUSR2 (default: SIGTERM, or SIGKILL with force); only SIGTERM and SIGKILL on Windows")),
+		mcp.WithNumber("escalate_after_seconds", mcp.Description("Wait this long for the process to exit after the signal, then send SIGKILL (default: 0, no escalation)")),
 	)
 	s.AddTool(killProcess, handlers.HandleKillProcess)
 
+	// kill_process_tree tool
+	killProcessTree := mcp.NewTool("kill_process_tree",
+		mcp.WithDescription("Terminate a process and all of its descendants, including the process group it leads, so no orphaned children are left running"),
+		mcp.WithNumber("pid", mcp.Required(), mcp.Description("Process ID at the root of the tree")),
+		mcp.WithBoolean("force", mcp.Description("Force kill with SIGKILL (default: false)")),
+	)
+	s.AddTool(killProcessTree, handlers.HandleKillProcessTree)
+
+	// get_process_tree tool
+	getProcessTree := mcp.NewTool("get_process_tree",
+		mcp.WithDescription("Show a process and its descendants as a JSON tree of PID, name, user, status, resource usage and command line"),
+		mcp.WithNumber("pid", mcp.Required(), mcp.Description("Process ID at the root of the tree (1 for the whole system)")),
+		mcp.WithNumber("depth", mcp.Description("Levels of children to include (default: all)")),
+	)
+	s.AddTool(getProcessTree, handlers.HandleGetProcessTree)
+
 	// get_process_info tool
 	getProcessInfo := mcp.NewTool("get_process_info",
-		mcp.WithDescription("Get detailed information about a specific process"),
+		mcp.WithDescription("Get detailed information about a specific process as JSON, including executable, working directory, open files, CPU times and children"),
 		mcp.WithNumber("pid", mcp.Required(), mcp.Description("Process ID to query")),
 	)
 	s.AddTool(getProcessInfo, handlers.HandleGetProcessInfo)
@@ -47,9 +82,15 @@ func RegisterTerminalTools(s *server.MCPServer) {
 	runScript := mcp.NewTool("run_shell_script",
 		mcp.WithDescription("Execute a multi-line shell script"),
 		mcp.WithString("script", mcp.Required(), mcp.Description("Shell script content")),
-		mcp.WithString("shell", mcp.Description("Shell interpreter (default: from config)")),
-		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: 60)")),
+		mcp.WithString("shell", mcp.Description("Shell interpreter, such as bash, zsh, fish, pwsh or cmd; the script file gets the extension it expects (default: from config)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout in seconds (default: scriptTimeoutSeconds, at most maxScriptTimeoutSeconds)")),
 		mcp.WithBoolean("create_temp_file", mcp.Description("Create temporary script file (default: true)")),
+		mcp.WithBoolean("stream_output", mcp.Description("Send output lines to the client as progress or log notifications while it runs (default: true)")),
+		mcp.WithNumber("max_output_bytes", mcp.Description("Return at most this many bytes of output, keeping the start and the end (default: maxOutputBytes config value)")),
+		mcp.WithString("output_file", mcp.Description("Also write the complete output to this file, which must be in an allowed directory and counts against the write quotas; it is replaced once the command finishes and its path is returned")),
+		mcp.WithBoolean("preflight", mcp.Description("Check the script first with the shell's syntax check, shellcheck and the blocked command patterns, and return the diagnostics instead of running it if any is an error (default: false)")),
+		mcp.WithBoolean("validate_only", mcp.Description("Only run the pre-flight checks and return their diagnostics without running the script (default: false)")),
+		mcp.WithBoolean("shellcheck", mcp.Description("Run shellcheck during the pre-flight checks when it is installed (default: true)")),
 	)
 	s.AddTool(runScript, handlers.HandleRunShellScript)
 
@@ -60,9 +101,240 @@ func RegisterTerminalTools(s *server.MCPServer) {
 	)
 	s.AddTool(checkCommand, handlers.HandleCheckCommandExists)
 
+	// list_network_interfaces tool
+	listInterfaces := mcp.NewTool("list_network_interfaces",
+		mcp.WithDescription("List network interfaces as JSON with flags, MTU, hardware address, IP addresses and traffic counters"),
+	)
+	s.AddTool(listInterfaces, handlers.HandleListNetworkInterfaces)
+
+	// list_network_connections tool
+	listConnections := mcp.NewTool("list_network_connections",
+		mcp.WithDescription("List open sockets as JSON with protocol, local and remote address and port, state and owning process, like ss or netstat"),
+		mcp.WithString("kind", mcp.Description("Sockets to list: all, inet, inet4, inet6, tcp, tcp4, tcp6, udp, udp4, udp6 or unix (default: inet)")),
+		mcp.WithString("status", mcp.Description("Only sockets in this state, such as LISTEN, ESTABLISHED or TIME_WAIT")),
+		mcp.WithNumber("pid", mcp.Description("Only sockets owned by this process")),
+	)
+	s.AddTool(listConnections, handlers.HandleListNetworkConnections)
+
+	// list_listening_ports tool
+	listListeningPorts := mcp.NewTool("list_listening_ports",
+		mcp.WithDescription("List listening TCP ports and bound UDP ports with the owning process; processes of other users may need root to be shown"),
+	)
+	s.AddTool(listListeningPorts, handlers.HandleListListeningPorts)
+
+	// get_routing_table tool
+	getRoutingTable := mcp.NewTool("get_routing_table",
+		mcp.WithDescription("Show the IPv4 and IPv6 routing table as JSON with destination, gateway, interface, metric and flags (Linux)"),
+	)
+	s.AddTool(getRoutingTable, handlers.HandleGetRoutingTable)
+
+	// wait_for_port tool
+	waitForPort := mcp.NewTool("wait_for_port",
+		mcp.WithDescription("Wait until a TCP port accepts connections or an HTTP endpoint responds successfully, polling until a timeout; use after starting a server with start_command instead of sleeping"),
+		mcp.WithNumber("port", mcp.Description("TCP port to wait for; set either port or url")),
+		mcp.WithString("host", mcp.Description("Host of the port (default: localhost)")),
+		mcp.WithString("url", mcp.Description("HTTP(S) health endpoint to wait for instead of a port")),
+		mcp.WithNumber("expected_status", mcp.Description("HTTP status that counts as ready (default: any 2xx)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Give up after this many seconds (default: 30)")),
+		mcp.WithNumber("interval_ms", mcp.Description("Milliseconds between attempts (default: 500)")),
+	)
+	s.AddTool(waitForPort, handlers.HandleWaitForPort)
+
+	// watch_command tool
+	watchCommand := mcp.NewTool("watch_command",
+		mcp.WithDescription("Rerun a command at an interval for a bounded time and report each time its output or exit code changes, with a diff against the previous run, e.g. to follow 'kubectl get pods' until a rollout completes"),
+		mcp.WithString("command", mcp.Required(), mcp.Description("Command to run (subject to blocked command policy)")),
+		mcp.WithNumber("interval_seconds", mcp.Description("Seconds between runs (default: 5, minimum 1)")),
+		mcp.WithNumber("duration_seconds", mcp.Description("Stop watching after this many seconds (default: 300)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout for each run in seconds (default: commandTimeoutSeconds, at most maxCommandTimeoutSeconds)")),
+		mcp.WithString("until_pattern", mcp.Description("Stop once the output matches this regular expression")),
+		mcp.WithBoolean("stop_on_change", mcp.Description("Stop at the first change (default: false)")),
+		mcp.WithBoolean("stream_output", mcp.Description("Send each change to the client as a progress or log notification (default: true)")),
+		mcp.WithString("shell", mcp.Description("Shell to use, such as bash, zsh, fish, pwsh or cmd (default: from config)")),
+		mcp.WithString("working_dir", mcp.Description("Working directory for command execution")),
+	)
+	s.AddTool(watchCommand, handlers.HandleWatchCommand)
+
+	// watch_and_run tool
+	watchAndRun := mcp.NewTool("watch_and_run",
+		mcp.WithDescription("Watch a file or directory and run a command whenever matching files change, until max_runs or duration is reached"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File or directory to watch")),
+		mcp.WithString("command", mcp.Required(), mcp.Description("Command to run on change (subject to blocked command policy)")),
+		mcp.WithString("pattern", mcp.Description("Glob pattern for files that trigger a run, e.g. **/*.go (default: all files)")),
+		mcp.WithNumber("debounce_ms", mcp.Description("Wait until changes settle for this long before running (default: 500)")),
+		mcp.WithNumber("poll_interval_ms", mcp.Description("How often to check for changes (default: 500)")),
+		mcp.WithNumber("max_runs", mcp.Description("Stop after this many command runs (default: 5)")),
+		mcp.WithNumber("duration_seconds", mcp.Description("Stop watching after this many seconds (default: 300)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Timeout for each command run in seconds (default: commandTimeoutSeconds, at most maxCommandTimeoutSeconds)")),
+		mcp.WithBoolean("run_on_start", mcp.Description("Run the command once before watching (default: false)")),
+		mcp.WithBoolean("include_hidden", mcp.Description("Watch hidden files and directories (default: false)")),
+		mcp.WithString("shell", mcp.Description("Shell to use, such as bash, zsh, fish, pwsh or cmd (default: from config)")),
+		mcp.WithString("working_dir", mcp.Description("Working directory for command execution")),
+	)
+	s.AddTool(watchAndRun, handlers.HandleWatchAndRun)
+
 	// get_system_info tool
 	getSystemInfo := mcp.NewTool("get_system_info",
-		mcp.WithDescription("Get system information including OS, CPU, memory, and disk usage"),
+		mcp.WithDescription("Get system information as JSON including OS, uptime, CPU, load average, memory, swap, disk usage, and where available GPUs (nvidia-smi, rocm-smi or macOS), temperature sensors and batteries"),
 	)
 	s.AddTool(getSystemInfo, handlers.HandleGetSystemInfo)
+
+	// get_environment tool
+	getEnvironment := mcp.NewTool("get_environment",
+		mcp.WithDescription("Get the environment commands run in as JSON: user and home directory, default and user shell, locale, working directory, PATH entries with existence checks and environment variables with secret values masked"),
+		mcp.WithBoolean("include_variables", mcp.Description("Include environment variables (default: true)")),
+		mcp.WithString("filter", mcp.Description("Only include variables whose name contains this text, ignoring case")),
+		mcp.WithString("mask_patterns", mcp.Description("Comma-separated glob patterns of variable names to mask in addition to the secretEnvPatterns config value, such as *_DSN")),
+	)
+	s.AddTool(getEnvironment, handlers.HandleGetEnvironment)
+
+	// start_command tool
+	startCommand := mcp.NewTool("start_command",
+		mcp.WithDescription("Start a long-running command such as a dev server or watcher in the background and return a session id; use read_session_output to follow its output"),
+		mcp.WithString("command", mcp.Required(), mcp.Description("Command to start (subject to blocked command policy)")),
+		mcp.WithString("shell", mcp.Description("Shell to use, such as bash, zsh, fish, pwsh or cmd (default: from config)")),
+		mcp.WithString("working_dir", mcp.Description("Working directory for command execution")),
+		mcp.WithNumber("wait_ms", mcp.Description("Wait up to this long for initial output before returning (default: 500)")),
+		mcp.WithBoolean("pty", mcp.Description("Run in a pseudo-terminal so interactive programs such as REPLs, ssh prompts and installers work; stdout and stderr are merged and the screen can be read with read_session_screen (default: false)")),
+		mcp.WithNumber("rows", mcp.Description("Terminal height for pty sessions (default: 24)")),
+		mcp.WithNumber("cols", mcp.Description("Terminal width for pty sessions (default: 80)")),
+	)
+	s.AddTool(startCommand, handlers.HandleStartCommand)
+
+	// read_session_output tool
+	readSessionOutput := mcp.NewTool("read_session_output",
+		mcp.WithDescription("Read the stdout and stderr a background session produced since the previous read, along with its state and exit code"),
+		mcp.WithString("session_id", mcp.Required(), mcp.Description("Session id returned by start_command")),
+		mcp.WithNumber("wait_ms", mcp.Description("When no output is pending, wait up to this long for new output or for the command to exit (default: 1000)")),
+		mcp.WithNumber("max_bytes", mcp.Description("Maximum bytes to return per stream; the rest is returned by the next read (default: 65536)")),
+	)
+	s.AddTool(readSessionOutput, handlers.HandleReadSessionOutput)
+
+	// write_session_input tool
+	writeSessionInput := mcp.NewTool("write_session_input",
+		mcp.WithDescription("Send input to the stdin of a running background session. The input, together with any unterminated input of earlier writes, is checked against the blocked and approval patterns like a script; a carriage return counts as a line end in pty sessions. Completed lines are recorded in the command history."),
+		mcp.WithString("session_id", mcp.Required(), mcp.Description("Session id returned by start_command")),
+		mcp.WithString("input", mcp.Description("Text to send")),
+		mcp.WithBoolean("append_newline", mcp.Description("Append a newline to input if it has none (default: true)")),
+		mcp.WithBoolean("close_stdin", mcp.Description("Close stdin after writing, signalling end of input; sends Ctrl-D to pty sessions (default: false)")),
+	)
+	s.AddTool(writeSessionInput, handlers.HandleWriteSessionInput)
+
+	// terminate_session tool
+	terminateSession := mcp.NewTool("terminate_session",
+		mcp.WithDescription("Stop a background session and its child processes; its remaining output can still be read"),
+		mcp.WithString("session_id", mcp.Required(), mcp.Description("Session id returned by start_command")),
+		mcp.WithBoolean("force", mcp.Description("Kill immediately with SIGKILL instead of SIGTERM (default: false)")),
+		mcp.WithNumber("grace_seconds", mcp.Description("Time to wait after SIGTERM before killing (default: 5)")),
+	)
+	s.AddTool(terminateSession, handlers.HandleTerminateSession)
+
+	// read_session_screen tool
+	readSessionScreen := mcp.NewTool("read_session_screen",
+		mcp.WithDescription("Show the current terminal screen of a pty session as text, with the cursor position, as a user would see it"),
+		mcp.WithString("session_id", mcp.Required(), mcp.Description("Session id of a session started with pty=true")),
+		mcp.WithNumber("wait_ms", mcp.Description("Wait up to this long for new output before reading the screen (default: 0)")),
+	)
+	s.AddTool(readSessionScreen, handlers.HandleReadSessionScreen)
+
+	// resize_session tool
+	resizeSession := mcp.NewTool("resize_session",
+		mcp.WithDescription("Resize the terminal of a pty session; the program is notified with SIGWINCH"),
+		mcp.WithString("session_id", mcp.Required(), mcp.Description("Session id of a session started with pty=true")),
+		mcp.WithNumber("rows", mcp.Required(), mcp.Description("New terminal height")),
+		mcp.WithNumber("cols", mcp.Required(), mcp.Description("New terminal width")),
+	)
+	s.AddTool(resizeSession, handlers.HandleResizeSession)
+
+	// list_sessions tool
+	listSessions := mcp.NewTool("list_sessions",
+		mcp.WithDescription("List background command sessions with their state, PID and running time"),
+	)
+	s.AddTool(listSessions, handlers.HandleListSessions)
+
+	// get_command_history tool
+	getCommandHistory := mcp.NewTool("get_command_history",
+		mcp.WithDescription("Show previously executed commands, newest first, with working directory, shell, duration, exit code and truncated output; history persists across restarts"),
+		mcp.WithString("since", mcp.Description("Only commands started at or after this RFC3339 timestamp, or within this duration (e.g. 2h)")),
+		mcp.WithString("until", mcp.Description("Only commands started at or before this RFC3339 timestamp, or this long ago (e.g. 30m)")),
+		mcp.WithString("status", mcp.Description("Only successful (exit code 0) commands with 'success', or failed ones with 'failure'")),
+		mcp.WithString("query", mcp.Description("Only commands whose command text or output contains this text (case-insensitive)")),
+		mcp.WithString("tool", mcp.Description("Only commands run by this tool: execute_command, execute_commands, run_shell_script, start_command, schedule_job, run_template, run_pipeline or ssh_execute_command")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of commands to return (default: 50)")),
+	)
+	s.AddTool(getCommandHistory, handlers.HandleGetCommandHistory)
+
+	// Scheduled jobs call other tools through the server
+	handlers.EnableJobToolCalls(s)
+
+	// schedule_job tool
+	scheduleJob := mcp.NewTool("schedule_job",
+		mcp.WithDescription("Run a command or another tool on a cron schedule or at a fixed interval; jobs persist across restarts and keep the output of their recent runs"),
+		mcp.WithString("name", mcp.Description("Optional label for the job")),
+		mcp.WithString("command", mcp.Description("Command to run (subject to blocked command policy); set either command or tool")),
+		mcp.WithString("shell", mcp.Description("Shell for command jobs (default: from config)")),
+		mcp.WithString("working_dir", mcp.Description("Working directory for command jobs")),
+		mcp.WithString("tool", mcp.Description("Name of a tool to call instead of a command")),
+		mcp.WithString("arguments", mcp.Description("Tool arguments as a JSON object")),
+		mcp.WithString("cron", mcp.Description("Five-field cron expression in local time (minute hour day-of-month month day-of-week), e.g. '*/15 9-17 * * mon-fri', or @hourly, @daily, @weekly, @monthly; set either cron or interval_seconds")),
+		mcp.WithNumber("interval_seconds", mcp.Description("Run every this many seconds (minimum 10)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Stop a run after this many seconds (default: 300)")),
+	)
+	s.AddTool(scheduleJob, handlers.HandleScheduleJob)
+
+	// list_jobs tool
+	listJobs := mcp.NewTool("list_jobs",
+		mcp.WithDescription("List scheduled jobs with their schedule, next and last run, and last status"),
+	)
+	s.AddTool(listJobs, handlers.HandleListJobs)
+
+	// cancel_job tool
+	cancelJob := mcp.NewTool("cancel_job",
+		mcp.WithDescription("Cancel a scheduled job, stopping a run in progress"),
+		mcp.WithString("job_id", mcp.Required(), mcp.Description("Job id returned by schedule_job")),
+	)
+	s.AddTool(cancelJob, handlers.HandleCancelJob)
+
+	// get_job_runs tool
+	getJobRuns := mcp.NewTool("get_job_runs",
+		mcp.WithDescription("Show the recent runs of a scheduled job, newest first, with status, exit code, duration and output"),
+		mcp.WithString("job_id", mcp.Required(), mcp.Description("Job id returned by schedule_job")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of runs to return (default: 10; up to 20 are kept)")),
+	)
+	s.AddTool(getJobRuns, handlers.HandleGetJobRuns)
+
+	// run_template tool
+	runTemplate := mcp.NewTool("run_template",
+		mcp.WithDescription("Run a command template defined with save_command_template. Arguments are validated against the template's parameter types and shell-quoted before substitution. Returns JSON like execute_command."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Template name (see list_command_templates)")),
+		mcp.WithString("arguments", mcp.Description(`Template arguments as a JSON object, e.g. {"env":"staging"}`)),
+		mcp.WithBoolean("stream_output", mcp.Description("Send output lines to the client as progress or log notifications while it runs (default: true)")),
+	)
+	s.AddTool(runTemplate, handlers.HandleRunTemplate)
+
+	// run_pipeline tool
+	runPipeline := mcp.NewTool("run_pipeline",
+		mcp.WithDescription("Run several commands as one pipeline. Stages run one at a time, each after the stages it depends on; a stage is skipped when a dependency failed without continue_on_error. Returns JSON with overall success and each stage's status (success, failure, timeout or skipped), exit code, duration and an output excerpt."),
+		mcp.WithString("stages", mcp.Required(), mcp.Description(`Stages as a JSON array. Each stage has name and command, and optionally depends_on (stage names), timeout_seconds (default 300), continue_on_error, shell and working_dir, e.g. [{"name":"build","command":"go build ./..."},{"name":"test","command":"go test ./...","depends_on":["build"]}]`)),
+		mcp.WithString("working_dir", mcp.Description("Default working directory for stages")),
+		mcp.WithString("shell", mcp.Description("Default shell for stages")),
+		mcp.WithBoolean("fail_fast", mcp.Description("Skip all remaining stages once a stage fails without continue_on_error; when false only its dependents are skipped (default: true)")),
+		mcp.WithNumber("max_output_bytes", mcp.Description("Output kept per stage; longer output keeps its start and end (default: 4096)")),
+		mcp.WithBoolean("stream_output", mcp.Description("Report each finished stage to the client as a progress or log notification (default: true)")),
+	)
+	s.AddTool(runPipeline, handlers.HandleRunPipeline)
+
+	// set_working_directory tool
+	setWorkingDirectory := mcp.NewTool("set_working_directory",
+		mcp.WithDescription("Set the working directory for later execute_command calls in this session, like cd. Commands without working_dir run there, and a relative working_dir is resolved against it."),
+		mcp.WithString("path", mcp.Description("Directory to change to, absolute or relative to the current working directory; must be in an allowed directory")),
+		mcp.WithBoolean("reset", mcp.Description("Forget the working directory so commands run in the server's working directory again (default: false)")),
+	)
+	s.AddTool(setWorkingDirectory, handlers.HandleSetWorkingDirectory)
+
+	// get_working_directory tool
+	getWorkingDirectory := mcp.NewTool("get_working_directory",
+		mcp.WithDescription("Show the working directory execute_command uses in this session"),
+	)
+	s.AddTool(getWorkingDirectory, handlers.HandleGetWorkingDirectory)
 }
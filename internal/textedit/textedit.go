@@ -16,9 +16,13 @@ func RegisterTextEditingTools(s *server.MCPServer) {
 		mcp.WithNumber("start_line", mcp.Required(), mcp.Description("Starting line number (1-based)")),
 		mcp.WithNumber("end_line", mcp.Required(), mcp.Description("Ending line number (1-based)")),
 		mcp.WithString("replacement", mcp.Required(), mcp.Description("Replacement text")),
-		mcp.WithBoolean("show_diff", mcp.Description("Show character-level diff feedback (default: true)")),
+		mcp.WithBoolean("show_diff", mcp.Description("Show a unified diff of the change (default: true)")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
 		mcp.WithBoolean("validate_syntax", mcp.Description("Validate syntax for known file types (default: false)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(editBlock, handlers.HandleEditBlock)
 
@@ -29,53 +33,399 @@ func RegisterTextEditingTools(s *server.MCPServer) {
 		mcp.WithString("operations", mcp.Required(), mcp.Description("JSON array of edit operations: [{\"start_line\": 1, \"end_line\": 3, \"replacement\": \"new text\", \"description\": \"optional\"}]")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
 		mcp.WithBoolean("validate_operations", mcp.Description("Validate operations before applying (default: true)")),
-		mcp.WithBoolean("show_preview", mcp.Description("Show preview of changes (default: false)")),
+		mcp.WithBoolean("show_preview", mcp.Description("Show a unified diff of the changes without applying them (default: false)")),
+		mcp.WithBoolean("show_diff", mcp.Description("Include a unified diff of the applied changes (default: false)")),
 		mcp.WithBoolean("atomic", mcp.Description("Apply all operations atomically (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(editFile, handlers.HandleEditFile)
 
 	// edit_multiple_files - Edit multiple files simultaneously
 	editMultipleFiles := mcp.NewTool("edit_multiple_files",
 		mcp.WithDescription("Edit multiple files simultaneously with line-based replacements"),
-		mcp.WithString("files", mcp.Required(), mcp.Description("JSON array of file edit requests: [{\"path\": \"file.txt\", \"operations\": [...], \"create_backup\": true}]")),
-		mcp.WithBoolean("atomic", mcp.Description("All operations succeed or all fail (default: true)")),
+		mcp.WithString("files", mcp.Required(), mcp.Description("JSON array of file edit requests: [{\"path\": \"file.txt\", \"operations\": [...], \"create_backup\": true}]; each may set expected_checksum and expected_mtime to refuse files changed since they were read")),
+		mcp.WithBoolean("atomic", mcp.Description("All files are written or none are; a failed write restores every file already written (default: true)")),
 		mcp.WithBoolean("dry_run", mcp.Description("Preview changes without applying them (default: false)")),
 		mcp.WithBoolean("continue_on_error", mcp.Description("Continue processing files even if one fails (ignored if atomic=true)")),
 		mcp.WithBoolean("validate_all", mcp.Description("Validate all operations before starting (default: true)")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(editMultipleFiles, handlers.HandleEditMultipleFiles)
 
+	// apply_patch - Apply a unified diff
+	applyPatch := mcp.NewTool("apply_patch",
+		mcp.WithDescription("Apply a unified diff (git-style, multi-file) with fuzzy hunk matching and per-hunk results"),
+		mcp.WithString("patch", mcp.Required(), mcp.Description("Unified diff text")),
+		mcp.WithString("base_dir", mcp.Description("Directory that relative paths in the patch are resolved against")),
+		mcp.WithNumber("strip", mcp.Description("Leading path components to strip like patch -p (default: strip a/ and b/ prefixes)")),
+		mcp.WithNumber("fuzz", mcp.Description("Context lines that may be ignored at each end of a hunk (default: 2)")),
+		mcp.WithBoolean("reverse", mcp.Description("Reverse the patch to undo it (default: false)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Check whether the patch applies without changing files (default: false)")),
+		mcp.WithBoolean("atomic", mcp.Description("Change no files unless every file applies cleanly (default: true)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backups of modified files (default: true)")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(applyPatch, handlers.HandleApplyPatch)
+
+	// list_edit_journal - Show recorded edits
+	listEditJournal := mcp.NewTool("list_edit_journal",
+		mcp.WithDescription("List file edits recorded this session, newest first, with before/after hashes"),
+		mcp.WithString("path", mcp.Description("Only show edits of this file")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of entries (default: 50)")),
+		mcp.WithBoolean("include_undone", mcp.Description("Include edits that were already undone (default: false)")),
+	)
+	s.AddTool(listEditJournal, handlers.HandleListEditJournal)
+
+	// undo_last_edit - Revert the most recent edit
+	undoLastEdit := mcp.NewTool("undo_last_edit",
+		mcp.WithDescription("Restore the content a file had before the most recent edit recorded this session"),
+		mcp.WithString("path", mcp.Description("Only undo the last edit of this file (default: last edit of any file)")),
+		mcp.WithBoolean("force", mcp.Description("Undo even if the file was changed outside the journal since (default: false)")),
+	)
+	s.AddTool(undoLastEdit, handlers.HandleUndoLastEdit)
+
+	// undo_edits_since - Revert a range of edits
+	undoEditsSince := mcp.NewTool("undo_edits_since",
+		mcp.WithDescription("Revert every edit recorded this session since a journal ID or point in time, newest first. Files no longer allowed are refused"),
+		mcp.WithNumber("since_id", mcp.Description("Undo edits with this journal ID and later")),
+		mcp.WithString("since", mcp.Description("Undo edits made at or after this RFC3339 timestamp, or within this duration (e.g. 15m)")),
+		mcp.WithBoolean("force", mcp.Description("Undo even if files were changed outside the journal since (default: false)")),
+	)
+	s.AddTool(undoEditsSince, handlers.HandleUndoEditsSince)
+
 	// replace_text - Simple find and replace
 	replaceText := mcp.NewTool("replace_text",
 		mcp.WithDescription("Find and replace text in a file with optional regex support"),
 		mcp.WithString("path", mcp.Required(), mcp.Description("File path to edit")),
 		mcp.WithString("find", mcp.Required(), mcp.Description("Text to find")),
-		mcp.WithString("replace", mcp.Required(), mcp.Description("Replacement text")),
+		mcp.WithString("replace", mcp.Required(), mcp.Description("Replacement text; with regex, $1 or ${name} insert capture groups")),
 		mcp.WithBoolean("regex", mcp.Description("Use regular expressions (default: false)")),
 		mcp.WithBoolean("case_sensitive", mcp.Description("Case sensitive search (default: true)")),
 		mcp.WithBoolean("whole_word", mcp.Description("Match whole words only (default: false)")),
+		mcp.WithBoolean("multiline", mcp.Description("With regex, ^ and $ match at line boundaries (default: false)")),
+		mcp.WithBoolean("dot_all", mcp.Description("With regex, . also matches newlines (default: false)")),
 		mcp.WithNumber("max_replacements", mcp.Description("Maximum number of replacements (default: unlimited)")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(replaceText, handlers.HandleReplaceText)
 
+	// str_replace_edit - Replace an exact, unique string
+	strReplaceEdit := mcp.NewTool("str_replace_edit",
+		mcp.WithDescription("Replace an exact string in a file; fails if the string is missing or occurs more than once"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to edit")),
+		mcp.WithString("old_string", mcp.Required(), mcp.Description("Exact text to replace, including whitespace and indentation; must be unique in the file")),
+		mcp.WithString("new_string", mcp.Required(), mcp.Description("Text to replace it with")),
+		mcp.WithBoolean("replace_all", mcp.Description("Replace every occurrence instead of requiring a unique match (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("show_diff", mcp.Description("Show a unified diff of the change (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(strReplaceEdit, handlers.HandleStrReplaceEdit)
+
 	// insert_text - Insert text at specific positions
 	insertText := mcp.NewTool("insert_text",
-		mcp.WithDescription("Insert text at specific line positions in a file"),
+		mcp.WithDescription("Insert text at specific line positions, or next to lines matching an anchor, in a file"),
 		mcp.WithString("path", mcp.Required(), mcp.Description("File path to edit")),
-		mcp.WithString("insertions", mcp.Required(), mcp.Description("JSON array of insertions: [{\"line\": 5, \"text\": \"new line\", \"position\": \"before|after\"}]")),
+		mcp.WithString("insertions", mcp.Required(), mcp.Description("JSON array of insertions: [{\"line\": 5, \"content\": \"new line\", \"before\": false}] or anchored [{\"anchor\": \"^import \\\\(\", \"anchor_regex\": true, \"occurrence\": \"first|last|N\", \"if_not_found\": \"error|skip|start|end\", \"content\": \"...\"}]")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
 		mcp.WithBoolean("adjust_line_numbers", mcp.Description("Automatically adjust subsequent line numbers (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(insertText, handlers.HandleInsertText)
 
+	// transform_lines - Sort, deduplicate or otherwise rearrange lines
+	transformLines := mcp.NewTool("transform_lines",
+		mcp.WithDescription("Sort, deduplicate, reverse, shuffle or trim lines of a file or line range"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to edit")),
+		mcp.WithString("operations", mcp.Required(), mcp.Description("Comma-separated operations applied in order: sort, sort_numeric, unique, reverse, shuffle, trim_trailing")),
+		mcp.WithNumber("start_line", mcp.Description("First line of the range (default: 1)")),
+		mcp.WithNumber("end_line", mcp.Description("Last line of the range (default: last line)")),
+		mcp.WithBoolean("ignore_case", mcp.Description("Compare lines case-insensitively for sort and unique (default: false)")),
+		mcp.WithBoolean("descending", mcp.Description("Sort in descending order (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("show_diff", mcp.Description("Show a unified diff of the change (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(transformLines, handlers.HandleTransformLines)
+
 	// format_code - Format code files
 	formatCode := mcp.NewTool("format_code",
-		mcp.WithDescription("Format code files using appropriate formatters"),
+		mcp.WithDescription("Format a code file by running the appropriate formatter and report the changes"),
 		mcp.WithString("path", mcp.Required(), mcp.Description("File path to format")),
-		mcp.WithString("formatter", mcp.Description("Specific formatter to use (auto-detected if not specified)")),
+		mcp.WithString("formatter", mcp.Description("Formatter to run: gofmt, goimports, black, prettier, clang-format or google-java-format (auto-detected if not specified)")),
 		mcp.WithBoolean("create_backup", mcp.Description("Create backup before formatting (default: true)")),
 		mcp.WithString("config_file", mcp.Description("Path to formatter configuration file")),
+		mcp.WithBoolean("show_diff", mcp.Description("Include a unified diff of the formatting changes (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the formatting diff without writing (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Maximum time the formatter may run (default: 30)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
 	)
 	s.AddTool(formatCode, handlers.HandleFormatCode)
+
+	// read_structured - Read values from JSON/YAML/TOML files
+	readStructured := mcp.NewTool("read_structured",
+		mcp.WithDescription("Parse a JSON, YAML or TOML file and return the value at a path (e.g. spec.containers[0].image) as JSON"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to read")),
+		mcp.WithString("value_path", mcp.Description("Dotted path with [n] indexes; keys containing dots use [\"a.b\"] (default: whole document)")),
+		mcp.WithString("format", mcp.Description("File format: auto, json, yaml, toml (default: auto, by extension)")),
+	)
+	s.AddTool(readStructured, handlers.HandleReadStructured)
+
+	// set_structured_value - Set values in JSON/YAML/TOML files
+	setStructuredValue := mcp.NewTool("set_structured_value",
+		mcp.WithDescription("Set the value at a path in a JSON, YAML or TOML file, preserving key order, comments and formatting where possible"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to edit")),
+		mcp.WithString("value_path", mcp.Required(), mcp.Description("Dotted path with [n] indexes, e.g. spec.containers[0].image; index equal to the length appends")),
+		mcp.WithString("value", mcp.Required(), mcp.Description("New value as JSON (objects, arrays, numbers, true/false, null); non-JSON input is stored as a string")),
+		mcp.WithBoolean("raw_string", mcp.Description("Always store value as a string without JSON parsing (default: false)")),
+		mcp.WithString("format", mcp.Description("File format: auto, json, yaml, toml (default: auto, by extension)")),
+		mcp.WithBoolean("create_missing", mcp.Description("Create missing keys and tables along the path (default: true)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(setStructuredValue, handlers.HandleSetStructuredValue)
+
+	// edit_yaml - Comment-preserving YAML edits
+	editYAML := mcp.NewTool("edit_yaml",
+		mcp.WithDescription("Set or delete a value by path in a YAML file, including multi-document manifests, preserving comments, anchors and key order"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("YAML file path to edit")),
+		mcp.WithString("value_path", mcp.Required(), mcp.Description("Dotted path with [n] indexes, e.g. spec.template.spec.containers[0].image")),
+		mcp.WithString("action", mcp.Description("Action to perform: set or delete (default: set)")),
+		mcp.WithString("value", mcp.Description("New value for set, as JSON; non-JSON input is stored as a string")),
+		mcp.WithBoolean("raw_string", mcp.Description("Always store value as a string without JSON parsing (default: false)")),
+		mcp.WithNumber("document", mcp.Description("Zero-based document index, or -1 for all documents (default: 0, or all when select is given)")),
+		mcp.WithString("select", mcp.Description("Only edit documents matching comma-separated path=value terms, e.g. kind=Deployment,metadata.name=web")),
+		mcp.WithBoolean("create_missing", mcp.Description("Create missing keys along the path (default: true)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(editYAML, handlers.HandleEditYAML)
+
+	// patch_json - Apply JSON Patch or JSON Merge Patch
+	patchJSON := mcp.NewTool("patch_json",
+		mcp.WithDescription("Apply an RFC 6902 JSON Patch or RFC 7386 JSON Merge Patch to a JSON file, preserving key order and indentation"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("JSON file path to edit")),
+		mcp.WithString("patch", mcp.Required(), mcp.Description("JSON Patch array, e.g. [{\"op\": \"replace\", \"path\": \"/version\", \"value\": \"2.0\"}], or a merge patch object")),
+		mcp.WithString("patch_type", mcp.Description("Patch type: auto, json_patch or merge_patch (default: auto, arrays are JSON Patches)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(patchJSON, handlers.HandlePatchJSON)
+
+	// front_matter - Read and edit Markdown front matter
+	frontMatter := mcp.NewTool("front_matter",
+		mcp.WithDescription("Get, set or delete keys in the YAML front matter of a Markdown file, leaving the body untouched"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Markdown file path")),
+		mcp.WithString("action", mcp.Description("Action to perform: get, set or delete (default: get)")),
+		mcp.WithString("key", mcp.Description("Dotted key path with [n] indexes, e.g. tags[0]; empty gets the whole front matter")),
+		mcp.WithString("value", mcp.Description("New value for set, as JSON; non-JSON input is stored as a string")),
+		mcp.WithBoolean("raw_string", mcp.Description("Always store value as a string without JSON parsing (default: false)")),
+		mcp.WithBoolean("create_missing", mcp.Description("Create missing keys along the path (default: true)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(frontMatter, handlers.HandleFrontMatter)
+
+	// save_snippet - Store a reusable snippet
+	saveSnippet := mcp.NewTool("save_snippet",
+		mcp.WithDescription("Save a named code or text snippet to the shared snippet library; ${name} and ${name:default} mark placeholders"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Snippet name (letters, digits, '.', '_' and '-')")),
+		mcp.WithString("content", mcp.Required(), mcp.Description("Snippet text, e.g. \"// Copyright ${year} ${owner}\"")),
+		mcp.WithString("description", mcp.Description("What the snippet is for")),
+		mcp.WithString("language", mcp.Description("Language the snippet is written in, used to filter listings")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace an existing snippet of the same name (default: false)")),
+	)
+	s.AddTool(saveSnippet, handlers.HandleSaveSnippet)
+
+	// list_snippets - Browse the snippet library
+	listSnippets := mcp.NewTool("list_snippets",
+		mcp.WithDescription("List snippets in the snippet library with their placeholders"),
+		mcp.WithString("language", mcp.Description("Only list snippets for this language")),
+		mcp.WithString("query", mcp.Description("Only list snippets whose name or description contains this text")),
+		mcp.WithBoolean("show_content", mcp.Description("Include snippet content (default: false)")),
+	)
+	s.AddTool(listSnippets, handlers.HandleListSnippets)
+
+	// delete_snippet - Remove a snippet
+	deleteSnippet := mcp.NewTool("delete_snippet",
+		mcp.WithDescription("Delete a snippet from the snippet library"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Snippet name")),
+	)
+	s.AddTool(deleteSnippet, handlers.HandleDeleteSnippet)
+
+	// insert_snippet - Insert a snippet into a file
+	insertSnippet := mcp.NewTool("insert_snippet",
+		mcp.WithDescription("Insert a library snippet into a file with its placeholders filled in; year, date and filename are built in"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Snippet name")),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to insert into; created if missing when appending")),
+		mcp.WithString("values", mcp.Description("JSON object of placeholder values, e.g. {\"owner\": \"Acme\"}")),
+		mcp.WithNumber("line", mcp.Description("Insert after this 1-based line (default: append to the end of the file)")),
+		mcp.WithBoolean("before", mcp.Description("Insert before the line or anchor instead of after it (default: false)")),
+		mcp.WithString("anchor", mcp.Description("Insert next to the first line containing this text instead of at a line number")),
+		mcp.WithBoolean("anchor_regex", mcp.Description("Treat anchor as a regular expression (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+	)
+	s.AddTool(insertSnippet, handlers.HandleInsertSnippet)
+
+	// markdown_outline - Show the heading structure of a Markdown file
+	markdownOutline := mcp.NewTool("markdown_outline",
+		mcp.WithDescription("Extract the heading outline of a Markdown file with line numbers and anchors"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Markdown file path")),
+		mcp.WithNumber("max_level", mcp.Description("Deepest heading level to include (default: 6)")),
+		mcp.WithString("format", mcp.Description("Output format: text or json (default: text)")),
+	)
+	s.AddTool(markdownOutline, handlers.HandleMarkdownOutline)
+
+	// update_markdown_toc - Generate or refresh a table of contents
+	updateMarkdownTOC := mcp.NewTool("update_markdown_toc",
+		mcp.WithDescription("Generate or refresh the table of contents of a Markdown file between <!-- toc --> and <!-- tocstop --> markers; without markers it is inserted after the title"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Markdown file path")),
+		mcp.WithNumber("min_level", mcp.Description("Shallowest heading level to list (default: 2)")),
+		mcp.WithNumber("max_level", mcp.Description("Deepest heading level to list (default: 3)")),
+		mcp.WithBoolean("ordered", mcp.Description("Use a numbered list instead of bullets (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+	)
+	s.AddTool(updateMarkdownTOC, handlers.HandleUpdateMarkdownTOC)
+
+	// number_markdown_headings - Renumber section headings
+	numberMarkdownHeadings := mcp.NewTool("number_markdown_headings",
+		mcp.WithDescription("Number Markdown headings hierarchically (1., 1.1, 1.2, 2., ...), replacing existing numbers, or strip the numbers"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Markdown file path")),
+		mcp.WithNumber("min_level", mcp.Description("Heading level numbered 1., 2., ... (default: 2)")),
+		mcp.WithNumber("max_level", mcp.Description("Deepest heading level to number (default: 4)")),
+		mcp.WithBoolean("remove", mcp.Description("Remove section numbers instead of adding them (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+	)
+	s.AddTool(numberMarkdownHeadings, handlers.HandleNumberMarkdownHeadings)
+
+	// check_text - Spelling and style checks
+	checkText := mcp.NewTool("check_text",
+		mcp.WithDescription("Check a text or Markdown file for spelling and style problems and report line/column findings; spelling uses aspell or hunspell when installed"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to check")),
+		mcp.WithString("rules", mcp.Description("Comma-separated rules: spelling, repeated_word, trailing_whitespace, long_line, double_space, weasel_words (default: all)")),
+		mcp.WithString("dictionary", mcp.Description("Project dictionary file with one accepted word per line")),
+		mcp.WithString("words", mcp.Description("Comma-separated extra words to accept")),
+		mcp.WithString("language", mcp.Description("Spell checker language, e.g. en_US (default: checker default)")),
+		mcp.WithNumber("max_line_length", mcp.Description("Line length limit for long_line, 0 to disable (default: 120)")),
+		mcp.WithBoolean("markdown", mcp.Description("Skip front matter, code, link targets and HTML (default: true for .md files)")),
+		mcp.WithNumber("max_findings", mcp.Description("Maximum findings to return (default: 200)")),
+		mcp.WithString("format", mcp.Description("Output format: text or json (default: text)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("Spell checker timeout (default: 30)")),
+	)
+	s.AddTool(checkText, handlers.HandleCheckText)
+
+	// normalize_unicode - Fix BOMs, invisible characters and normalization
+	normalizeUnicode := mcp.NewTool("normalize_unicode",
+		mcp.WithDescription("Detect and fix byte order marks, zero-width characters, non-breaking spaces and mixed NFC/NFD normalization, reporting each change by line and column"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to normalize")),
+		mcp.WithBoolean("fix_bom", mcp.Description("Remove a leading byte order mark (default: true)")),
+		mcp.WithBoolean("fix_zero_width", mcp.Description("Remove zero-width characters and soft hyphens; joiners are kept outside ASCII text (default: true)")),
+		mcp.WithBoolean("fix_nbsp", mcp.Description("Replace non-breaking and other unusual spaces with plain spaces (default: true)")),
+		mcp.WithString("normalization", mcp.Description("Normalization form: nfc, nfd or none (default: nfc)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup before editing (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Report the changes without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(normalizeUnicode, handlers.HandleNormalizeUnicode)
+
+	// beautify_file - Pretty-print JSON, CSS, JavaScript or SQL
+	beautifyFile := mcp.NewTool("beautify_file",
+		mcp.WithDescription("Beautify a JSON, CSS, JavaScript or SQL file in place or to a new file and report the size change; JSON is handled natively, other languages by prettier, sql-formatter or pg_format"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to beautify")),
+		mcp.WithString("output_path", mcp.Description("Write the result to this file instead of in place")),
+		mcp.WithString("tool", mcp.Description("Tool to use: native, prettier, sql-formatter or pg_format (default: auto, by extension)")),
+		mcp.WithNumber("indent", mcp.Description("Spaces per indentation level for native JSON (default: 2)")),
+		mcp.WithBoolean("use_tabs", mcp.Description("Indent native JSON with tabs (default: false)")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace an existing output_path file (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup of the file being replaced (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Show the resulting diff and size change without writing (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("External tool timeout (default: 30)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(beautifyFile, handlers.HandleBeautifyFile)
+
+	// minify_file - Minify JSON, CSS, JavaScript or SQL
+	minifyFile := mcp.NewTool("minify_file",
+		mcp.WithDescription("Minify a JSON, CSS, JavaScript or SQL file in place or to a new file and report the size savings; JSON and SQL are handled natively, CSS and JavaScript by esbuild, terser or csso"),
+		mcp.WithString("path", mcp.Required(), mcp.Description("File path to minify")),
+		mcp.WithString("output_path", mcp.Description("Write the result to this file instead of in place, e.g. app.min.js")),
+		mcp.WithString("tool", mcp.Description("Tool to use: native, esbuild, terser or csso (default: auto, by extension)")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace an existing output_path file (default: false)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backup of the file being replaced (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Report the size savings without writing (default: false)")),
+		mcp.WithNumber("timeout_seconds", mcp.Description("External tool timeout (default: 30)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(minifyFile, handlers.HandleMinifyFile)
+
+	// move_code - Move a declaration or line range to another file
+	moveCode := mcp.NewTool("move_code",
+		mcp.WithDescription("Move a function, type or other declaration (Go, by symbol name) or a range of lines (any language) from one file to another as a single atomic operation. For Go, a new destination file gets the package clause of its directory and imports are added to the destination and removed from the source as needed."),
+		mcp.WithString("source_path", mcp.Required(), mcp.Description("File to move the code out of")),
+		mcp.WithString("destination_path", mcp.Required(), mcp.Description("File to append the code to; created if it does not exist")),
+		mcp.WithString("symbol", mcp.Description("Go declaration to move, e.g. ParseConfig or Server.Start for a method, including its doc comment")),
+		mcp.WithNumber("start_line", mcp.Description("First line to move (1-based), when not selecting by symbol")),
+		mcp.WithNumber("end_line", mcp.Description("Last line to move, inclusive (default: start_line)")),
+		mcp.WithBoolean("create_backup", mcp.Description("Create backups of both files (default: true)")),
+		mcp.WithBoolean("dry_run", mcp.Description("Preview the changes to both files without writing (default: false)")),
+		mcp.WithString("expected_checksum", mcp.Description("SHA-256 of the file as last read (see get_file_info); the write is refused with a diff if the file changed since")),
+		mcp.WithString("expected_mtime", mcp.Description("Modification time of the file as last read, RFC3339; the write is refused if the file changed since")),
+		mcp.WithBoolean("confirm_large_edit", mcp.Description("Apply an edit that exceeds the largeEditLines or largeEditPercent threshold; without it such an edit is refused and its diff returned for review (default: false)")),
+	)
+	s.AddTool(moveCode, handlers.HandleMoveCode)
+
+	// diff_files - Compare two text files
+	diffFiles := mcp.NewTool("diff_files",
+		mcp.WithDescription("Compare two text files and return the number of lines added and deleted with a diff in the same format edit tools use"),
+		mcp.WithString("old_path", mcp.Required(), mcp.Description("Original file")),
+		mcp.WithString("new_path", mcp.Required(), mcp.Description("Changed file")),
+		mcp.WithNumber("context_lines", mcp.Description("Unchanged lines shown around each change (default: 3)")),
+		mcp.WithString("style", mcp.Description("Diff style: word marks changed words inside lines, unified is a plain unified diff (default: diffStyle setting)")),
+		mcp.WithBoolean("stat_only", mcp.Description("Only return the line counts (default: false)")),
+	)
+	s.AddTool(diffFiles, handlers.HandleDiffFiles)
 }
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // EditOperation represents a single edit operation
 type EditOperation struct {
 	StartLine   int    `json:"start_line"`
@@ -10,9 +15,11 @@ type EditOperation struct {
 
 // FileEditRequest represents multiple edits for a single file
 type FileEditRequest struct {
-	Path         string          `json:"path"`
-	Operations   []EditOperation `json:"operations"`
-	CreateBackup bool            `json:"create_backup,omitempty"`
+	Path             string          `json:"path"`
+	Operations       []EditOperation `json:"operations"`
+	CreateBackup     bool            `json:"create_backup,omitempty"`
+	ExpectedChecksum string          `json:"expected_checksum,omitempty"`
+	ExpectedMtime    string          `json:"expected_mtime,omitempty"`
 }
 
 // MultiFileEditRequest represents edits for multiple files
@@ -22,6 +29,911 @@ type MultiFileEditRequest struct {
 	DryRun bool              `json:"dry_run,omitempty"`
 }
 
+// FileStats represents line, word and byte statistics for a file
+type FileStats struct {
+	Path           string   `json:"path"`
+	Lines          int      `json:"lines"`
+	Words          int      `json:"words"`
+	Bytes          int64    `json:"bytes"`
+	BlankLines     int      `json:"blank_lines"`
+	LongestLine    int      `json:"longest_line"`
+	LongestLineNum int      `json:"longest_line_number"`
+	Head           []string `json:"head,omitempty"`
+}
+
+// ExtendedFileInfo represents platform-specific file metadata
+type ExtendedFileInfo struct {
+	Owner     string     `json:"owner,omitempty"`
+	Group     string     `json:"group,omitempty"`
+	UID       uint32     `json:"uid"`
+	GID       uint32     `json:"gid"`
+	Inode     uint64     `json:"inode"`
+	Links     uint64     `json:"links"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// FileChunk represents a byte range read from a file
+type FileChunk struct {
+	Path              string `json:"path"`
+	Offset            int64  `json:"offset"`
+	Length            int64  `json:"length"`
+	FileSize          int64  `json:"file_size"`
+	NextOffset        int64  `json:"next_offset"`
+	EOF               bool   `json:"eof"`
+	ContinuationToken string `json:"continuation_token,omitempty"`
+	Content           string `json:"content"`
+}
+
+// DirectoryEntry represents a single entry in a directory listing
+type DirectoryEntry struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	Permissions string    `json:"permissions"`
+}
+
+// DirectoryListing represents a paginated directory listing
+type DirectoryListing struct {
+	Path    string           `json:"path"`
+	Total   int              `json:"total"`
+	Offset  int              `json:"offset"`
+	Entries []DirectoryEntry `json:"entries"`
+}
+
+// TreeNode represents a node in a directory tree
+type TreeNode struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Size     int64       `json:"size,omitempty"`
+	Children []*TreeNode `json:"children,omitempty"`
+	Omitted  int         `json:"omitted,omitempty"`
+}
+
+// RenameOperation represents a single planned file rename
+type RenameOperation struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Conflict    string `json:"conflict,omitempty"`
+}
+
+// SearchMatch represents a single pattern match within a file
+type SearchMatch struct {
+	File          string   `json:"file"`
+	Line          int      `json:"line"`
+	Column        int      `json:"column"`
+	Match         string   `json:"match"`
+	LineText      string   `json:"line_text"`
+	ContextBefore []string `json:"context_before,omitempty"`
+	ContextAfter  []string `json:"context_after,omitempty"`
+}
+
+// SearchResults represents a paginated set of content search matches
+type SearchResults struct {
+	Matches       []SearchMatch `json:"matches"`
+	Offset        int           `json:"offset"`
+	FilesSearched int           `json:"files_searched"`
+	FilesMatched  int           `json:"files_matched"`
+	Truncated     bool          `json:"truncated"`
+	NextOffset    int           `json:"next_offset,omitempty"`
+}
+
+// ManifestEntry represents a single file recorded in a manifest
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// Manifest represents the recorded state of a directory tree
+type Manifest struct {
+	Root      string          `json:"root"`
+	CreatedAt time.Time       `json:"created_at"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// DuplicateGroup is a set of files with identical contents
+type DuplicateGroup struct {
+	Size     int64    `json:"size"`
+	Checksum string   `json:"checksum"`
+	Paths    []string `json:"paths"`
+}
+
+// DuplicateReport represents the identical files found under a directory;
+// WastedBytes is what keeping one file of each group would free
+type DuplicateReport struct {
+	Root         string           `json:"root"`
+	Algorithm    string           `json:"algorithm"`
+	FilesScanned int              `json:"files_scanned"`
+	WastedBytes  int64            `json:"wasted_bytes"`
+	Groups       []DuplicateGroup `json:"groups"`
+}
+
+// ManifestChange represents a file whose state differs from the manifest
+type ManifestChange struct {
+	Path    string   `json:"path"`
+	Reasons []string `json:"reasons"`
+}
+
+// ManifestDiff represents the result of verifying a tree against a manifest
+type ManifestDiff struct {
+	Root      string           `json:"root"`
+	Added     []string         `json:"added"`
+	Removed   []string         `json:"removed"`
+	Changed   []ManifestChange `json:"changed"`
+	Unchanged int              `json:"unchanged"`
+}
+
+// ImageInfo represents dimensions, format and metadata of an image file
+type ImageInfo struct {
+	Path       string     `json:"path"`
+	Format     string     `json:"format"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	ColorModel string     `json:"color_model,omitempty"`
+	BitDepth   int        `json:"bit_depth,omitempty"`
+	Channels   int        `json:"channels,omitempty"`
+	FileSize   int64      `json:"file_size"`
+	EXIF       *ImageEXIF `json:"exif,omitempty"`
+}
+
+// ImageEXIF represents the commonly used EXIF fields of an image
+type ImageEXIF struct {
+	Make              string            `json:"make,omitempty"`
+	Model             string            `json:"model,omitempty"`
+	LensModel         string            `json:"lens_model,omitempty"`
+	Software          string            `json:"software,omitempty"`
+	Artist            string            `json:"artist,omitempty"`
+	Copyright         string            `json:"copyright,omitempty"`
+	Orientation       int               `json:"orientation,omitempty"`
+	DateTime          string            `json:"date_time,omitempty"`
+	DateTimeOriginal  string            `json:"date_time_original,omitempty"`
+	DateTimeDigitized string            `json:"date_time_digitized,omitempty"`
+	ExposureTime      string            `json:"exposure_time,omitempty"`
+	FNumber           float64           `json:"f_number,omitempty"`
+	ISO               int               `json:"iso,omitempty"`
+	FocalLength       float64           `json:"focal_length_mm,omitempty"`
+	GPS               *ImageGPS         `json:"gps,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
+}
+
+// ImageGPS represents the GPS position recorded in EXIF data
+type ImageGPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+}
+
+// ImageRect is a rectangle of an image in pixels, from its top left corner
+type ImageRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ImageTransform describes how an image is changed before it is written,
+// in the order of the fields; zero values leave the image as it is
+type ImageTransform struct {
+	Crop   *ImageRect
+	Rotate int    // clockwise degrees, a multiple of 90
+	Flip   string // horizontal or vertical
+
+	// Width and Height are the size to resize to; with only one of them
+	// the other keeps the aspect ratio, with both Fit decides how the
+	// image is made to fit: contain, cover or fill
+	Width  int
+	Height int
+	Fit    string
+
+	Format  string // format to write; empty takes it from the destination
+	Quality int    // 1-100, used by the lossy formats
+}
+
+// ImageProcessResult reports an image written by image processing
+type ImageProcessResult struct {
+	Source         string `json:"source"`
+	Destination    string `json:"destination"`
+	SourceFormat   string `json:"source_format"`
+	Format         string `json:"format"`
+	OriginalWidth  int    `json:"original_width"`
+	OriginalHeight int    `json:"original_height"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	OriginalSize   int64  `json:"original_size"`
+	Size           int64  `json:"size"`
+	Quality        int    `json:"quality,omitempty"`
+}
+
+// MediaInfo represents container and stream details of an audio/video file
+type MediaInfo struct {
+	Path     string        `json:"path"`
+	Format   string        `json:"format"`
+	Duration float64       `json:"duration_seconds"`
+	BitRate  int64         `json:"bit_rate,omitempty"`
+	FileSize int64         `json:"file_size"`
+	Streams  []MediaStream `json:"streams"`
+	Source   string        `json:"source"`
+	Warning  string        `json:"warning,omitempty"`
+}
+
+// MediaStream represents a single audio or video stream of a media file
+type MediaStream struct {
+	Index      int    `json:"index"`
+	Type       string `json:"type"`
+	Codec      string `json:"codec"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	FrameRate  string `json:"frame_rate,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	BitDepth   int    `json:"bit_depth,omitempty"`
+	BitRate    int64  `json:"bit_rate,omitempty"`
+}
+
+// PatchHunkResult represents the outcome of applying a single patch hunk
+type PatchHunkResult struct {
+	Hunk    int    `json:"hunk"`
+	Applied bool   `json:"applied"`
+	Line    int    `json:"line,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+	Fuzz    int    `json:"fuzz,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PatchFileResult represents the outcome of applying a patch to one file
+type PatchFileResult struct {
+	Path   string            `json:"path"`
+	Action string            `json:"action"`
+	Hunks  []PatchHunkResult `json:"hunks"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// EditJournalEntry represents one recorded file mutation that can be undone
+type EditJournalEntry struct {
+	ID         int       `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Session    string    `json:"session,omitempty"`
+	Tool       string    `json:"tool"`
+	Path       string    `json:"path"`
+	Action     string    `json:"action"`
+	BeforeHash string    `json:"before_hash,omitempty"`
+	AfterHash  string    `json:"after_hash,omitempty"`
+	BackupPath string    `json:"backup_path,omitempty"`
+	Undone     bool      `json:"undone"`
+}
+
+// BackupInfo represents a backup copy of a file
+type BackupInfo struct {
+	Path           string    `json:"path"`
+	OriginalPath   string    `json:"original_path"`
+	CreatedAt      time.Time `json:"created_at"`
+	Size           int64     `json:"size"`
+	OriginalExists bool      `json:"original_exists"`
+}
+
+// Snippet represents a named, reusable piece of text saved in the snippet
+// library
+type Snippet struct {
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	Language     string    `json:"language,omitempty"`
+	Content      string    `json:"content"`
+	Placeholders []string  `json:"placeholders,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MarkdownHeading represents one heading of a Markdown document outline
+type MarkdownHeading struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Anchor string `json:"anchor"`
+}
+
+// TextFinding represents a spelling or style problem found by check_text
+type TextFinding struct {
+	Line        int      `json:"line"`
+	Column      int      `json:"column"`
+	Rule        string   `json:"rule"`
+	Text        string   `json:"text"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// UnicodeChange represents one character fixed by normalize_unicode
+type UnicodeChange struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// CommandSession describes a background command started with start_command
+type CommandSession struct {
+	ID        string     `json:"session_id"`
+	Command   string     `json:"command"`
+	PID       int        `json:"pid"`
+	State     string     `json:"state"` // running, exited or terminated
+	ExitCode  *int       `json:"exit_code,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Duration  string     `json:"duration"`
+}
+
+// SessionOutput is the output a session produced since the previous read.
+// Offsets count every byte the stream has produced; DroppedBytes is output
+// discarded from the buffer before it was read.
+type SessionOutput struct {
+	CommandSession
+	Stdout       string `json:"stdout"`
+	Stderr       string `json:"stderr"`
+	StdoutOffset int64  `json:"stdout_offset"`
+	StderrOffset int64  `json:"stderr_offset"`
+	DroppedBytes int64  `json:"dropped_bytes,omitempty"`
+	More         bool   `json:"more,omitempty"`
+}
+
+// SessionScreen is the visible screen of a pseudo-terminal session; the
+// cursor position is 1-based
+type SessionScreen struct {
+	CommandSession
+	Rows      int    `json:"rows"`
+	Cols      int    `json:"cols"`
+	CursorRow int    `json:"cursor_row"`
+	CursorCol int    `json:"cursor_col"`
+	Screen    string `json:"screen"`
+}
+
+// CommandResult is the result of execute_command. ExitCode is -1 when the
+// command did not exit normally, such as when it timed out.
+type CommandResult struct {
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	DurationMs int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out"`
+	Error      string `json:"error,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	OutputFile string `json:"output_file,omitempty"`
+	Sandbox    string `json:"sandbox,omitempty"`
+}
+
+// CommandHistoryEntry is one command recorded in the command history
+type CommandHistoryEntry struct {
+	ID         int       `json:"id"`
+	Tool       string    `json:"tool"`
+	Command    string    `json:"command"`
+	Shell      string    `json:"shell,omitempty"`
+	WorkingDir string    `json:"working_dir,omitempty"`
+	User       string    `json:"user,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// AuditEntry is one tool call recorded in the audit log. Arguments have
+// secret values masked and long values shortened.
+type AuditEntry struct {
+	Time       time.Time      `json:"time"`
+	Session    string         `json:"session,omitempty"`
+	Tool       string         `json:"tool"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	Outcome    string         `json:"outcome"`
+	Error      string         `json:"error,omitempty"`
+	ErrorCode  string         `json:"error_code,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+	Files      []string       `json:"files,omitempty"`
+}
+
+// SecretInfo describes a stored secret without its value
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	Reference string    `json:"reference"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MetricsReport is the telemetry collected for get_metrics and the
+// periodic metrics report
+type MetricsReport struct {
+	Enabled     bool          `json:"enabled"`
+	Since       time.Time     `json:"since"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Calls       int64         `json:"calls"`
+	Errors      int64         `json:"errors"`
+	Tools       []ToolMetrics `json:"tools"`
+}
+
+// ToolMetrics summarizes the calls of one tool. LatencyBuckets count the
+// calls that took at most LeMs milliseconds.
+type ToolMetrics struct {
+	Tool           string          `json:"tool"`
+	Calls          int64           `json:"calls"`
+	Errors         int64           `json:"errors"`
+	ErrorRate      float64         `json:"error_rate"`
+	AvgLatencyMs   float64         `json:"avg_latency_ms"`
+	MaxLatencyMs   float64         `json:"max_latency_ms"`
+	TotalMs        float64         `json:"total_ms"`
+	LatencyBuckets []LatencyBucket `json:"latency_buckets"`
+}
+
+// LatencyBucket is one bucket of a cumulative latency histogram
+type LatencyBucket struct {
+	LeMs  float64 `json:"le_ms"`
+	Count int64   `json:"count"`
+}
+
+// ScheduledJob is a command or tool call that runs on a cron schedule or
+// at a fixed interval. Tool calls run with the role and session quotas of
+// the Client and Session that scheduled them.
+type ScheduledJob struct {
+	ID              string         `json:"id"`
+	Name            string         `json:"name,omitempty"`
+	Command         string         `json:"command,omitempty"`
+	Shell           string         `json:"shell,omitempty"`
+	WorkingDir      string         `json:"working_dir,omitempty"`
+	Tool            string         `json:"tool,omitempty"`
+	Arguments       map[string]any `json:"arguments,omitempty"`
+	Client          string         `json:"client,omitempty"`
+	Session         string         `json:"session,omitempty"`
+	Cron            string         `json:"cron,omitempty"`
+	IntervalSeconds int            `json:"interval_seconds,omitempty"`
+	TimeoutSeconds  int            `json:"timeout_seconds"`
+	CreatedAt       time.Time      `json:"created_at"`
+	NextRun         *time.Time     `json:"next_run,omitempty"`
+	LastRun         *time.Time     `json:"last_run,omitempty"`
+	LastStatus      string         `json:"last_status,omitempty"`
+	RunCount        int            `json:"run_count"`
+}
+
+// JobRun is one run of a scheduled job
+type JobRun struct {
+	JobID      string    `json:"job_id"`
+	Run        int       `json:"run"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Status     string    `json:"status"` // success, failure or timeout
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// ProcessSummary is one process as listed by list_processes
+type ProcessSummary struct {
+	PID           int        `json:"pid"`
+	PPID          int        `json:"ppid"`
+	Name          string     `json:"name"`
+	User          string     `json:"user,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	CPUPercent    float64    `json:"cpu_percent"`
+	MemoryPercent float64    `json:"memory_percent"`
+	RSS           uint64     `json:"rss_bytes"`
+	VMS           uint64     `json:"vms_bytes"`
+	NumThreads    int        `json:"num_threads,omitempty"`
+	Threads       []int      `json:"threads,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	Command       string     `json:"command,omitempty"`
+}
+
+// ProcessNode is a process with its child processes, as returned by
+// get_process_tree
+type ProcessNode struct {
+	ProcessSummary
+	Children []*ProcessNode `json:"children,omitempty"`
+}
+
+// ProcessDetails is the result of get_process_info
+type ProcessDetails struct {
+	ProcessSummary
+	Executable       string  `json:"executable,omitempty"`
+	WorkingDir       string  `json:"working_dir,omitempty"`
+	Terminal         string  `json:"terminal,omitempty"`
+	Nice             int     `json:"nice"`
+	OpenFiles        int     `json:"open_files,omitempty"`
+	CPUUserSeconds   float64 `json:"cpu_user_seconds"`
+	CPUSystemSeconds float64 `json:"cpu_system_seconds"`
+	Children         []int   `json:"children,omitempty"`
+}
+
+// SystemInfo is the result of get_system_info. Fields that cannot be read
+// on the current platform are left empty and explained in Warnings.
+type SystemInfo struct {
+	Hostname        string       `json:"hostname"`
+	OS              string       `json:"os"`
+	Platform        string       `json:"platform,omitempty"`
+	PlatformVersion string       `json:"platform_version,omitempty"`
+	KernelVersion   string       `json:"kernel_version,omitempty"`
+	Architecture    string       `json:"architecture"`
+	BootTime        *time.Time   `json:"boot_time,omitempty"`
+	UptimeSeconds   uint64       `json:"uptime_seconds"`
+	Processes       uint64       `json:"processes"`
+	CPU             CPUInfo      `json:"cpu"`
+	Load            *LoadAverage `json:"load,omitempty"`
+	Memory          MemoryUsage  `json:"memory"`
+	Swap            MemoryUsage  `json:"swap"`
+	Disks           []DiskUsage  `json:"disks"`
+	GPUs            []GPUInfo    `json:"gpus,omitempty"`
+	Temperatures    []SensorTemp `json:"temperatures,omitempty"`
+	Batteries       []Battery    `json:"batteries,omitempty"`
+	Warnings        []string     `json:"warnings,omitempty"`
+}
+
+// CPUInfo describes the processors of a machine
+type CPUInfo struct {
+	Model         string  `json:"model,omitempty"`
+	PhysicalCores int     `json:"physical_cores"`
+	LogicalCores  int     `json:"logical_cores"`
+	MHz           float64 `json:"mhz,omitempty"`
+	UsagePercent  float64 `json:"usage_percent"`
+}
+
+// LoadAverage is the 1, 5 and 15 minute load average
+type LoadAverage struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// MemoryUsage describes physical memory or swap, in bytes
+type MemoryUsage struct {
+	Total       uint64  `json:"total_bytes"`
+	Used        uint64  `json:"used_bytes"`
+	Available   uint64  `json:"available_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// DiskUsage describes one mounted filesystem, in bytes
+type DiskUsage struct {
+	Device      string  `json:"device"`
+	Mountpoint  string  `json:"mountpoint"`
+	Filesystem  string  `json:"filesystem"`
+	Total       uint64  `json:"total_bytes"`
+	Used        uint64  `json:"used_bytes"`
+	Free        uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// GPUInfo describes one graphics processor, as reported by nvidia-smi,
+// rocm-smi or system_profiler (Source); memory is in bytes
+type GPUInfo struct {
+	Index              int     `json:"index"`
+	Vendor             string  `json:"vendor"`
+	Model              string  `json:"model"`
+	Driver             string  `json:"driver,omitempty"`
+	Cores              int     `json:"cores,omitempty"`
+	MemoryTotal        uint64  `json:"memory_total_bytes,omitempty"`
+	MemoryUsed         uint64  `json:"memory_used_bytes,omitempty"`
+	UtilizationPercent float64 `json:"utilization_percent,omitempty"`
+	TemperatureC       float64 `json:"temperature_c,omitempty"`
+	PowerWatts         float64 `json:"power_watts,omitempty"`
+	Source             string  `json:"source"`
+}
+
+// SensorTemp is the reading of a temperature sensor in degrees Celsius
+type SensorTemp struct {
+	Sensor   string  `json:"sensor"`
+	Current  float64 `json:"current_c"`
+	High     float64 `json:"high_c,omitempty"`
+	Critical float64 `json:"critical_c,omitempty"`
+}
+
+// Battery describes one battery; Status is charging, discharging, full or
+// as reported by the platform
+type Battery struct {
+	Name             string  `json:"name"`
+	Status           string  `json:"status"`
+	Percent          float64 `json:"percent"`
+	RemainingMinutes int     `json:"remaining_minutes,omitempty"`
+	HealthPercent    float64 `json:"health_percent,omitempty"`
+	CycleCount       int     `json:"cycle_count,omitempty"`
+}
+
+// EnvironmentInfo is the result of get_environment. Values of variables
+// whose names match a secret pattern are masked.
+type EnvironmentInfo struct {
+	User             EnvironmentUser   `json:"user"`
+	Shell            EnvironmentShell  `json:"shell"`
+	Locale           map[string]string `json:"locale"`
+	WorkingDirectory string            `json:"working_directory"`
+	Path             []PathEntry       `json:"path"`
+	Variables        map[string]string `json:"variables,omitempty"`
+	MaskedVariables  []string          `json:"masked_variables,omitempty"`
+	Warnings         []string          `json:"warnings,omitempty"`
+}
+
+// EnvironmentUser is the user the server runs as
+type EnvironmentUser struct {
+	Username string `json:"username"`
+	Name     string `json:"name,omitempty"`
+	UID      string `json:"uid"`
+	GID      string `json:"gid"`
+	HomeDir  string `json:"home_dir"`
+}
+
+// EnvironmentShell is the configured default shell and the user's shell
+type EnvironmentShell struct {
+	Default     string `json:"default"`
+	DefaultPath string `json:"default_path,omitempty"`
+	User        string `json:"user,omitempty"`
+}
+
+// PathEntry is one directory of PATH
+type PathEntry struct {
+	Dir       string `json:"dir"`
+	Exists    bool   `json:"exists"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NetworkInterface is a network interface with its addresses and traffic
+// counters
+type NetworkInterface struct {
+	Name         string   `json:"name"`
+	Index        int      `json:"index"`
+	MTU          int      `json:"mtu"`
+	HardwareAddr string   `json:"hardware_addr,omitempty"`
+	Flags        []string `json:"flags"`
+	Addresses    []string `json:"addresses"`
+	BytesSent    uint64   `json:"bytes_sent"`
+	BytesRecv    uint64   `json:"bytes_recv"`
+	PacketsSent  uint64   `json:"packets_sent"`
+	PacketsRecv  uint64   `json:"packets_recv"`
+	Errors       uint64   `json:"errors"`
+	Drops        uint64   `json:"drops"`
+}
+
+// NetworkConnection is an open socket; listening sockets have no remote
+// address
+type NetworkConnection struct {
+	Protocol      string `json:"protocol"`
+	LocalAddress  string `json:"local_address"`
+	LocalPort     uint32 `json:"local_port"`
+	RemoteAddress string `json:"remote_address,omitempty"`
+	RemotePort    uint32 `json:"remote_port,omitempty"`
+	Status        string `json:"status,omitempty"`
+	PID           int    `json:"pid,omitempty"`
+	Process       string `json:"process,omitempty"`
+}
+
+// Route is an entry of the kernel routing table
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway,omitempty"`
+	Interface   string `json:"interface"`
+	Metric      int    `json:"metric"`
+	Flags       string `json:"flags,omitempty"`
+}
+
+// InstalledPackage is a package reported by a package manager
+type InstalledPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// WaitResult is the outcome of wait_for_port
+type WaitResult struct {
+	Target     string `json:"target"`
+	Ready      bool   `json:"ready"`
+	Attempts   int    `json:"attempts"`
+	ElapsedMs  int64  `json:"elapsed_ms"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+// ParallelCommand is one command of an execute_commands call
+type ParallelCommand struct {
+	Command        string `json:"command"`
+	Shell          string `json:"shell,omitempty"`
+	WorkingDir     string `json:"working_dir,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// ParallelResult aggregates the results of execute_commands, which are in
+// the order the commands were given
+type ParallelResult struct {
+	Succeeded  int             `json:"succeeded"`
+	Failed     int             `json:"failed"`
+	DurationMs int64           `json:"duration_ms"`
+	Results    []CommandResult `json:"results"`
+}
+
+// ScriptDiagnostic is a problem found in a script before running it
+type ScriptDiagnostic struct {
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Source   string `json:"source"`   // syntax, shellcheck or blocked
+	Severity string `json:"severity"` // error, warning, info or style
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ScriptCheckResult is the outcome of the pre-flight checks of
+// run_shell_script; a script passes when no diagnostic is an error
+type ScriptCheckResult struct {
+	Shell       string             `json:"shell"`
+	Passed      bool               `json:"passed"`
+	Checks      []string           `json:"checks"`
+	Skipped     []string           `json:"skipped,omitempty"`
+	Diagnostics []ScriptDiagnostic `json:"diagnostics"`
+}
+
+// PendingApproval is an operation waiting for approve_operation
+type PendingApproval struct {
+	Token       string         `json:"-"`
+	Tool        string         `json:"tool"`
+	Summary     string         `json:"summary"`
+	Arguments   map[string]any `json:"arguments"`
+	RequestedAt time.Time      `json:"requested_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+}
+
+// TemporaryGrant adds a directory, URL pattern or command pattern to what
+// is allowed until ExpiresAt or until it was used MaxUses times, whichever
+// comes first; a zero MaxUses or ExpiresAt sets no limit
+type TemporaryGrant struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Value     string     `json:"value"`
+	Session   string     `json:"session,omitempty"`
+	GrantedAt time.Time  `json:"granted_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxUses   int        `json:"max_uses,omitempty"`
+	Uses      int        `json:"uses"`
+}
+
+// CommandPolicyCheck is the result of test_command_against_policy.
+// BlockedBy and ApprovalPatterns list every pattern the command matches.
+type CommandPolicyCheck struct {
+	Command          string   `json:"command"`
+	Decision         string   `json:"decision"`
+	Reason           string   `json:"reason"`
+	BlockedBy        []string `json:"blocked_by,omitempty"`
+	ApprovalPatterns []string `json:"approval_patterns,omitempty"`
+	NestedTooDeep    bool     `json:"nested_too_deep,omitempty"`
+	Sandbox          string   `json:"sandbox,omitempty"`
+}
+
+// PipelineStage is one command of a run_pipeline call
+type PipelineStage struct {
+	Name            string   `json:"name"`
+	Command         string   `json:"command"`
+	DependsOn       []string `json:"depends_on,omitempty"`
+	Shell           string   `json:"shell,omitempty"`
+	WorkingDir      string   `json:"working_dir,omitempty"`
+	TimeoutSeconds  int      `json:"timeout_seconds,omitempty"`
+	ContinueOnError bool     `json:"continue_on_error,omitempty"`
+}
+
+// PipelineStageResult reports how one pipeline stage ended
+type PipelineStageResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // success, failure, timeout or skipped
+	ExitCode   int    `json:"exit_code"`
+	DurationMs int64  `json:"duration_ms"`
+	Output     string `json:"output,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PipelineResult is the report of a run_pipeline call
+type PipelineResult struct {
+	Success    bool                  `json:"success"`
+	DurationMs int64                 `json:"duration_ms"`
+	Stages     []PipelineStageResult `json:"stages"`
+}
+
+// GitStatus is the branch state and changed files of a git work tree
+type GitStatus struct {
+	Branch   string          `json:"branch"`
+	Commit   string          `json:"commit,omitempty"`
+	Upstream string          `json:"upstream,omitempty"`
+	Ahead    int             `json:"ahead"`
+	Behind   int             `json:"behind"`
+	Clean    bool            `json:"clean"`
+	Files    []GitFileStatus `json:"files"`
+}
+
+// GitFileStatus is a changed file; Staged and Unstaged hold git's status
+// letters (M, A, D, R, ...) for the index and the work tree
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	Kind     string `json:"kind"` // changed, renamed, unmerged or untracked
+	Staged   string `json:"staged,omitempty"`
+	Unstaged string `json:"unstaged,omitempty"`
+}
+
+// GitDiff is a patch with per-file line counts
+type GitDiff struct {
+	Files     []GitDiffStat `json:"files"`
+	Patch     string        `json:"patch"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// GitDiffStat counts the lines changed in one file of a diff
+type GitDiffStat struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	Added    int    `json:"added"`
+	Deleted  int    `json:"deleted"`
+	Binary   bool   `json:"binary,omitempty"`
+}
+
+// DiffStat counts the lines a diff adds and deletes
+type DiffStat struct {
+	Added   int `json:"added"`
+	Deleted int `json:"deleted"`
+}
+
+// GitCommit is a commit in git_log output
+type GitCommit struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"short_hash"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Date      time.Time `json:"date"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body,omitempty"`
+	Parents   []string  `json:"parents,omitempty"`
+}
+
+// GitBlameLine is a line of a file with the commit that last changed it
+type GitBlameLine struct {
+	Line    int       `json:"line"`
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	Content string    `json:"content"`
+}
+
+// GitBranch is a local or remote-tracking branch
+type GitBranch struct {
+	Name     string `json:"name"`
+	Commit   string `json:"commit"`
+	Upstream string `json:"upstream,omitempty"`
+	Current  bool   `json:"current,omitempty"`
+	Remote   bool   `json:"remote,omitempty"`
+	Subject  string `json:"subject"`
+}
+
+// GitStash is a stash entry
+type GitStash struct {
+	Ref     string `json:"ref"`
+	Commit  string `json:"commit"`
+	Message string `json:"message"`
+}
+
+// WatchRun represents one command execution triggered by watch_and_run
+type WatchRun struct {
+	Run       int       `json:"run"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+	Trigger   []string  `json:"trigger"`
+	ExitCode  int       `json:"exit_code"`
+	Output    string    `json:"output"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// WatchChange is a rerun of watch_command whose output or exit code
+// differed from the run before it
+type WatchChange struct {
+	Run      int       `json:"run"`
+	At       time.Time `json:"at"`
+	ExitCode int       `json:"exit_code"`
+	Diff     string    `json:"diff,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
 // ServerConfig represents the server configuration
 type ServerConfig struct {
 	BlockedCommands    []string `json:"blockedCommands"`
@@ -30,6 +942,283 @@ type ServerConfig struct {
 	FileReadLineLimit  int      `json:"fileReadLineLimit"`
 	FileWriteLineLimit int      `json:"fileWriteLineLimit"`
 	TelemetryEnabled   bool     `json:"telemetryEnabled"`
+
+	// With TelemetryEnabled, per-tool metrics are served in the Prometheus
+	// format at /metrics on MetricsListenAddress and written as JSON to
+	// MetricsReportPath every MetricsReportIntervalSeconds, when set
+	MetricsListenAddress         string `json:"metricsListenAddress,omitempty"`
+	MetricsReportPath            string `json:"metricsReportPath,omitempty"`
+	MetricsReportIntervalSeconds int    `json:"metricsReportIntervalSeconds,omitempty"`
+
+	// DeniedDirectories and DeniedPathPatterns override AllowedDirectories:
+	// paths within a denied directory, or with an element matching a
+	// denied pattern such as ".ssh", are refused
+	DeniedDirectories  []string `json:"deniedDirectories,omitempty"`
+	DeniedPathPatterns []string `json:"deniedPathPatterns,omitempty"`
+
+	// Write quotas; zero means unlimited
+	MaxWriteBytes        int64 `json:"maxWriteBytes"`
+	MaxFilesPerCall      int   `json:"maxFilesPerCall"`
+	MaxSessionWriteBytes int64 `json:"maxSessionWriteBytes"`
+
+	// Quotas per client session; zero means unlimited. Once one is reached
+	// the session's write, delete, execute and network tools are refused
+	// until reset_session_quotas.
+	MaxSessionFilesWritten    int   `json:"maxSessionFilesWritten"`
+	MaxSessionBytesDownloaded int64 `json:"maxSessionBytesDownloaded"`
+	MaxSessionProcessesKilled int   `json:"maxSessionProcessesKilled"`
+	MaxSessionCommands        int   `json:"maxSessionCommands"`
+
+	// Edits changing more lines, or more percent of an existing file, need
+	// confirm_large_edit; zero disables a threshold
+	LargeEditLines   int `json:"largeEditLines"`
+	LargeEditPercent int `json:"largeEditPercent"`
+
+	// Backups are kept next to the original file unless BackupDirectory is
+	// set; zero retention limits keep every backup. The retention limits
+	// are applied to a file's backups when it is backed up, and every
+	// BackupSweepIntervalMinutes to the backup directory and the
+	// directories backups were written to.
+	BackupDirectory            string `json:"backupDirectory"`
+	BackupMaxCount             int    `json:"backupMaxCount"`
+	BackupMaxAgeDays           int    `json:"backupMaxAgeDays"`
+	BackupSweepIntervalMinutes int    `json:"backupSweepIntervalMinutes"`
+
+	// DiffStyle is "word" to mark changes inside edited lines in edit
+	// results, or "unified" for plain unified diffs
+	DiffStyle string `json:"diffStyle"`
+
+	// MaxOutputBytes caps the command output returned to the client; longer
+	// output keeps its start and end
+	MaxOutputBytes int `json:"maxOutputBytes"`
+
+	// Language is the language of the messages in tool results, "en" or
+	// "tr"; tool output such as file contents is never translated
+	Language string `json:"language"`
+
+	// Timeouts, in seconds, of calls that do not give one, by category;
+	// the Max settings cap the timeouts calls may ask for, 0 meaning no cap
+	CommandTimeoutSeconds     int `json:"commandTimeoutSeconds"`
+	ScriptTimeoutSeconds      int `json:"scriptTimeoutSeconds"`
+	FetchTimeoutSeconds       int `json:"fetchTimeoutSeconds"`
+	DownloadTimeoutSeconds    int `json:"downloadTimeoutSeconds"`
+	MaxCommandTimeoutSeconds  int `json:"maxCommandTimeoutSeconds"`
+	MaxScriptTimeoutSeconds   int `json:"maxScriptTimeoutSeconds"`
+	MaxFetchTimeoutSeconds    int `json:"maxFetchTimeoutSeconds"`
+	MaxDownloadTimeoutSeconds int `json:"maxDownloadTimeoutSeconds"`
+
+	// CommandTemplates are vetted commands run by name with run_template
+	CommandTemplates map[string]CommandTemplate `json:"commandTemplates,omitempty"`
+
+	// RunAsUsers lists the users execute_command may run commands as with
+	// run_as_user; empty disables it
+	RunAsUsers []string `json:"runAsUsers,omitempty"`
+
+	// Sandbox runs commands under bwrap or firejail with the file system
+	// read-only outside the allowed directories; empty runs them directly
+	Sandbox             string `json:"sandbox,omitempty"`
+	SandboxAllowNetwork bool   `json:"sandboxAllowNetwork,omitempty"`
+
+	// SSHHosts are the remote hosts the SSH tools may connect to, by name
+	SSHHosts map[string]SSHHost `json:"sshHosts,omitempty"`
+
+	// Installing and removing packages needs PackageManagementEnabled and
+	// is limited to packages matching an AllowedPackages entry
+	PackageManagementEnabled bool     `json:"packageManagementEnabled,omitempty"`
+	AllowedPackages          []string `json:"allowedPackages,omitempty"`
+
+	// With ApprovalRequired, recursive deletes and killing processes wait
+	// for approve_operation; commands matching ApprovalPatterns always do.
+	// Pending operations expire after ApprovalTimeoutSeconds.
+	ApprovalRequired       bool     `json:"approvalRequired,omitempty"`
+	ApprovalPatterns       []string `json:"approvalPatterns,omitempty"`
+	ApprovalTimeoutSeconds int      `json:"approvalTimeoutSeconds,omitempty"`
+
+	// SecretEnvPatterns are case-insensitive glob patterns of environment
+	// variable names whose values get_environment masks
+	SecretEnvPatterns []string `json:"secretEnvPatterns,omitempty"`
+
+	// Every tool call is appended to the audit log at AuditLogPath, or next
+	// to the config file when empty. The log is rotated once it reaches
+	// AuditLogMaxBytes, keeping AuditLogMaxFiles rotated files.
+	AuditLogPath     string `json:"auditLogPath,omitempty"`
+	AuditLogMaxBytes int64  `json:"auditLogMaxBytes,omitempty"`
+	AuditLogMaxFiles int    `json:"auditLogMaxFiles,omitempty"`
+
+	// AllowedURLPatterns limit the URLs the fetch tools may access, "*"
+	// matching any text; empty allows every http and https URL
+	AllowedURLPatterns []string `json:"allowedURLPatterns,omitempty"`
+
+	// ToolCategories name groups of tools, such as "write" or "network",
+	// that RateLimits can refer to
+	ToolCategories map[string][]string `json:"toolCategories,omitempty"`
+
+	// RateLimits caps the calls per minute of a tool, or of all the tools
+	// of a category together; a call over any limit that applies is refused
+	RateLimits map[string]int `json:"rateLimits,omitempty"`
+
+	// Roles are named sets of privileges; ClientRoles maps MCP client
+	// names, "*" matching any text, to the role their calls run with, and
+	// DefaultRole is the role of clients matching no entry. A client with
+	// no role is limited only by the settings above.
+	Roles       map[string]Role   `json:"roles,omitempty"`
+	ClientRoles map[string]string `json:"clientRoles,omitempty"`
+	DefaultRole string            `json:"defaultRole,omitempty"`
+
+	// PolicyRules are named rules deciding tool calls before they run, on
+	// top of every setting above
+	PolicyRules map[string]PolicyRule `json:"policyRules,omitempty"`
+
+	// Profiles are named sets of settings that switch_config_profile
+	// applies on top of the defaults; ActiveProfile is the last one applied
+	Profiles      map[string]json.RawMessage `json:"profiles,omitempty"`
+	ActiveProfile string                     `json:"activeProfile,omitempty"`
+}
+
+// SessionUsage counts the operations of a client session against the
+// session quotas; Exceeded lists the quotas it has reached
+type SessionUsage struct {
+	Session          string   `json:"session"`
+	Role             string   `json:"role,omitempty"`
+	FilesWritten     int      `json:"files_written"`
+	BytesWritten     int64    `json:"bytes_written"`
+	BytesDownloaded  int64    `json:"bytes_downloaded"`
+	ProcessesKilled  int      `json:"processes_killed"`
+	CommandsExecuted int      `json:"commands_executed"`
+	Exceeded         []string `json:"exceeded,omitempty"`
+}
+
+// ConfigVersion is a snapshot of the configuration settings, without
+// profiles, taken after a change. Settings is left out of listings.
+type ConfigVersion struct {
+	Version   int             `json:"version"`
+	Time      time.Time       `json:"time"`
+	Changed   []string        `json:"changed,omitempty"`
+	Settings  json.RawMessage `json:"settings,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// PolicyRule denies, asks for approval of or allows the tool calls it
+// matches. A rule matches a call when every condition it sets matches: a
+// tool name or category, a target path, a URL host or a command. "*"
+// matches any text. A deny rule always wins; an allow rule only exempts a
+// call from approve rules.
+type PolicyRule struct {
+	Effect      string   `json:"effect"`
+	Tools       []string `json:"tools,omitempty"`
+	Paths       []string `json:"paths,omitempty"`
+	Hosts       []string `json:"hosts,omitempty"`
+	Commands    []string `json:"commands,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// ToolPolicyCheck is the decision of the policy rules on a tool call,
+// with the inputs the rules were matched against
+type ToolPolicyCheck struct {
+	Tool         string   `json:"tool"`
+	Decision     string   `json:"decision"`
+	Reason       string   `json:"reason"`
+	MatchedRules []string `json:"matched_rules,omitempty"`
+	Paths        []string `json:"paths,omitempty"`
+	Hosts        []string `json:"hosts,omitempty"`
+	Commands     []string `json:"commands,omitempty"`
+}
+
+// Role narrows what the clients mapped to it may do. Its directories and
+// tools apply on top of the server-wide settings, and its quotas replace
+// the session quotas that are set (non-zero).
+type Role struct {
+	// AllowedDirectories limit the files the role's tools may access;
+	// empty leaves the server-wide allowedDirectories
+	AllowedDirectories []string `json:"allowedDirectories,omitempty"`
+	// Tools are the tools or tool categories the role may call; empty
+	// allows every tool not in DeniedTools
+	Tools       []string `json:"tools,omitempty"`
+	DeniedTools []string `json:"deniedTools,omitempty"`
+
+	MaxSessionFilesWritten    int   `json:"maxSessionFilesWritten,omitempty"`
+	MaxSessionBytesDownloaded int64 `json:"maxSessionBytesDownloaded,omitempty"`
+	MaxSessionProcessesKilled int   `json:"maxSessionProcessesKilled,omitempty"`
+	MaxSessionCommands        int   `json:"maxSessionCommands,omitempty"`
+}
+
+// ClientRole is the role a client's calls run with, as get_client_role
+// reports it
+type ClientRole struct {
+	Session string `json:"session,omitempty"`
+	Client  string `json:"client"`
+	Role    string `json:"role,omitempty"`
+	// MatchedBy is the clientRoles entry that selected the role, or
+	// "defaultRole"
+	MatchedBy  string `json:"matched_by,omitempty"`
+	Privileges *Role  `json:"privileges,omitempty"`
+}
+
+// ConfigField describes a configuration key for get_config_schema.
+// Fields with EditedWith are changed with that tool rather than
+// set_config_value.
+type ConfigField struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Values      []string `json:"values,omitempty"`
+	Min         *int64   `json:"min,omitempty"`
+	Max         *int64   `json:"max,omitempty"`
+	Path        bool     `json:"path,omitempty"`
+	EditedWith  string   `json:"edited_with,omitempty"`
+}
+
+// ConfigProfileInfo describes a saved config profile. Modified is set when
+// the active profile's settings were changed after switching to it.
+type ConfigProfileInfo struct {
+	Name     string   `json:"name"`
+	Active   bool     `json:"active"`
+	Modified bool     `json:"modified,omitempty"`
+	Keys     []string `json:"keys"`
+}
+
+// ConfigDifference is a setting that differs between two configurations;
+// From or To is null where a setting is not set
+type ConfigDifference struct {
+	Key  string `json:"key"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// SSHHost is a remote host reachable over SSH. Authentication uses
+// IdentityFile when set, otherwise the SSH agent and default keys; the
+// host key must already be in KnownHostsFile or the user's known_hosts.
+type SSHHost struct {
+	Host           string `json:"host"`
+	Port           int    `json:"port,omitempty"`
+	User           string `json:"user,omitempty"`
+	IdentityFile   string `json:"identityFile,omitempty"`
+	KnownHostsFile string `json:"knownHostsFile,omitempty"`
+}
+
+// CommandTemplate is a named command with typed parameters, referenced in
+// the command as {name}. Parameter values are validated and shell-quoted
+// before they are substituted.
+type CommandTemplate struct {
+	Description    string              `json:"description,omitempty"`
+	Command        string              `json:"command"`
+	Shell          string              `json:"shell,omitempty"`
+	WorkingDir     string              `json:"workingDir,omitempty"`
+	TimeoutSeconds int                 `json:"timeoutSeconds,omitempty"`
+	Parameters     []TemplateParameter `json:"parameters,omitempty"`
+}
+
+// TemplateParameter is one parameter of a command template
+type TemplateParameter struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, int, number, bool, enum or path
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	// Values lists the choices of an enum parameter
+	Values []string `json:"values,omitempty"`
+	// Pattern is a regular expression a string value must match in full
+	Pattern string `json:"pattern,omitempty"`
 }
 
 // HTTPRequestConfig represents HTTP request configuration
@@ -64,17 +1253,25 @@ type CommandExecutionConfig struct {
 
 // OperationResult represents the result of any operation
 type OperationResult struct {
-	Success  bool                   `json:"success"`
-	Message  string                 `json:"message"`
-	Data     interface{}            `json:"data,omitempty"`
-	Error    string                 `json:"error,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	// ErrorCode is one of the common.ErrorCodes when the operation failed
+	ErrorCode string                 `json:"error_code,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
 type TextInsertion struct {
 	Line    int    `json:"line"`
 	Content string `json:"content"`
 	Before  bool   `json:"before,omitempty"` // If true, insert before the line, otherwise after
+
+	// Anchor selects the line by content instead of number
+	Anchor      string `json:"anchor,omitempty"`
+	AnchorRegex bool   `json:"anchor_regex,omitempty"`
+	Occurrence  string `json:"occurrence,omitempty"`   // first (default), last, or a 1-based match number
+	IfNotFound  string `json:"if_not_found,omitempty"` // error (default), skip, start or end
 }
 type TextInsertionRequest struct {
 	Insertions []TextInsertion `json:"insertions"`
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"jarvis/handlers"
 	"jarvis/internal/common"
 	"jarvis/internal/config"
+	"jarvis/internal/database"
+	"jarvis/internal/git"
+	"jarvis/internal/packages"
+	"jarvis/internal/remote"
 	"jarvis/internal/terminal"
 	"jarvis/internal/textedit"
 	"log"
+	"os"
 
 	fetching "jarvis/internal/fetch"
 	"jarvis/internal/filesystem"
@@ -19,22 +26,72 @@ func main() {
 		"jarvis",                          // Sunucu adı
 		"1.0.0",                           // Versiyon
 		server.WithToolCapabilities(true), // Tool desteği
-		server.WithResourceCapabilities(true, true), // Resource desteği
-		server.WithPromptCapabilities(true),         // Prompt desteği
-		server.WithRecovery(),                       // Hata kurtarma
+		server.WithResourceCapabilities(true, true),                     // Resource desteği
+		server.WithPromptCapabilities(true),                             // Prompt desteği
+		server.WithToolHandlerMiddleware(handlers.AuditToolCalls),       // Denetim kaydı
+		server.WithToolHandlerMiddleware(handlers.AttachErrorCodes),     // Hata kodları
+		server.WithToolHandlerMiddleware(handlers.CollectToolMetrics),   // Telemetri ölçümleri
+		server.WithToolHandlerMiddleware(handlers.EnforceClientRole),    // İstemci rolleri
+		server.WithToolHandlerMiddleware(handlers.EnforceToolPolicy),    // Politika kuralları
+		server.WithToolHandlerMiddleware(handlers.LimitToolRate),        // Araç çağrı sınırları
+		server.WithToolHandlerMiddleware(handlers.EnforceSessionQuotas), // Oturum kotaları
+		server.WithToolHandlerMiddleware(handlers.TrackTemporaryGrants), // Geçici izin kullanımları
+		server.WithToolHandlerMiddleware(handlers.RedactToolResults),    // Gizli değerleri çıktılardan gizle
+		server.WithToolFilter(handlers.FilterToolsByRole),               // Rolün izin vermediği araçları listeden çıkar
+		server.WithRecovery(), // Hata kurtarma
 		server.WithLogging(),
 	)
 
-	config.RegisterConfigTools(s)         // Yapılandırma araçlarını kaydet
-	terminal.RegisterTerminalTools(s)     // Terminal araçlarını kaydet
-	filesystem.RegisterFilesystemTools(s) // Dosya sistemi araçlarını kaydet
-	textedit.RegisterTextEditingTools(s)  // Metin düzenleme araçlarını kaydet
-	fetching.RegisterFetchTools(s)        // Fetching araçlarını kaydet
+	// Gizli değerleri yükle ki çıktılarda ve loglarda gizlensinler
+	log.SetOutput(common.NewRedactingWriter(os.Stderr))
+	if err := common.LoadSecrets(); err != nil {
+		log.Printf("Secrets error: %v", err)
+	}
+
+	registerTools(s)
 	logStartupInfo()
+
+	// Kaydedilmiş zamanlanmış işleri başlat
+	if err := common.StartJobScheduler(); err != nil {
+		log.Printf("Job scheduler error: %v", err)
+	}
+
+	// Telemetri ölçümlerini dışa aktar
+	if err := common.StartMetricsExport(context.Background()); err != nil {
+		log.Printf("Metrics export error: %v", err)
+	}
+
+	// Saklama sınırlarını aşan yedekleri düzenli olarak temizle
+	common.StartBackupSweep(context.Background())
+
+	// Yapılandırma değiştiğinde istemcileri bilgilendir
+	config.NotifyConfigChanges(s)
+
+	// Yapılandırma dosyası değiştiğinde yeniden yükle
+	config.WatchConfigFile(s)
+
 	// Sunucuyu stdio üzerinden başlat
 	if err := server.ServeStdio(s); err != nil {
 		fmt.Printf("Sunucu hatası: %v\n", err)
 	}
+
+	// Oturum boyunca oluşturulan geçici çalışma alanlarını temizle
+	for _, err := range common.CleanupAllWorkspaces() {
+		log.Printf("Workspace cleanup error: %v", err)
+	}
+}
+
+// registerTools registers every tool of the server on s
+func registerTools(s *server.MCPServer) {
+	config.RegisterConfigTools(s)         // Yapılandırma araçlarını kaydet
+	terminal.RegisterTerminalTools(s)     // Terminal araçlarını kaydet
+	filesystem.RegisterFilesystemTools(s) // Dosya sistemi araçlarını kaydet
+	textedit.RegisterTextEditingTools(s)  // Metin düzenleme araçlarını kaydet
+	fetching.RegisterFetchTools(s)        // Fetching araçlarını kaydet
+	database.RegisterDatabaseTools(s)     // Veritabanı araçlarını kaydet
+	remote.RegisterRemoteTools(s)         // SSH uzak sunucu araçlarını kaydet
+	packages.RegisterPackageTools(s)      // Paket yöneticisi araçlarını kaydet
+	git.RegisterGitTools(s)               // Git araçlarını kaydet
 }
 
 // logStartupInfo logs server startup information
@@ -46,6 +103,7 @@ func logStartupInfo() {
 	log.Printf("  Allowed Directories: %v", cfg.AllowedDirectories)
 	log.Printf("  File Read Line Limit: %d", cfg.FileReadLineLimit)
 	log.Printf("  File Write Line Limit: %d", cfg.FileWriteLineLimit)
+	log.Printf("  Write Quotas: %d bytes/write, %d files/call, %d bytes/session", cfg.MaxWriteBytes, cfg.MaxFilesPerCall, cfg.MaxSessionWriteBytes)
 	log.Printf("  Blocked Commands: %v", cfg.BlockedCommands)
 	log.Printf("  Telemetry: %t", cfg.TelemetryEnabled)
 
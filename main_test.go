@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"jarvis/internal/common"
+)
+
+// pathLikeArgument matches tool argument names that suggest a local file
+var pathLikeArgument = regexp.MustCompile(`path|file|dir$|director|root|source|destination|target|dictionary`)
+
+// notLocalPaths are path-like tool arguments that do not name local files
+var notLocalPaths = map[string]bool{
+	"remote_path":  true, // a path on an SSH host
+	"json_path":    true, // a path inside a JSON document
+	"value_path":   true, // a path inside a structured file
+	"file_pattern": true, // a glob filtering file names
+}
+
+// jsonExampleKey finds the keys in the JSON examples of argument
+// descriptions
+var jsonExampleKey = regexp.MustCompile(`\\?"([a-z_]+)\\?"\s*:`)
+
+// notPathJSON are JSON tool arguments holding data rather than more files
+// or commands
+var notPathJSON = map[string]bool{
+	"config":     true, // a configuration to import
+	"env":        true, // environment variables
+	"headers":    true, // HTTP headers
+	"insertions": true, // lines and anchors within the edited file
+	"operations": true, // line ranges within the edited file
+	"parameters": true, // parameter definitions of a command template
+	"params":     true, // SQL parameter values
+	"patch":      true, // a JSON Patch applied to a document
+	"patch_type": true, // the kind of JSON patch
+	"settings":   true, // a configuration profile
+	"url":        true, // a URL returning JSON
+	"urls":       true, // URLs with request options
+	"value":      true, // a value stored in a document or the configuration
+	"values":     true, // snippet placeholder values
+}
+
+// Roles, grants and policy rules only check the paths named by
+// common.PathArguments, so a tool taking a file under another name would
+// slip past them
+func TestPathArgumentsCoverRegisteredTools(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := server.NewMCPServer("jarvis", "test", server.WithToolCapabilities(true))
+	registerTools(s)
+
+	request, err := json.Marshal(map[string]any{"jsonrpc": mcp.JSONRPC_VERSION, "id": 1, "method": mcp.MethodToolsList})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, ok := s.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatal("tools/list failed")
+	}
+	result, ok := response.Result.(mcp.ListToolsResult)
+	if !ok || len(result.Tools) == 0 {
+		t.Fatalf("tools/list returned no tools: %#v", response.Result)
+	}
+
+	var missing, undecoded []string
+	for _, tool := range result.Tools {
+		for name, property := range tool.InputSchema.Properties {
+			schema, _ := property.(map[string]any)
+			if kind := schema["type"]; kind != "string" && kind != "array" {
+				continue
+			}
+			if pathLikeArgument.MatchString(name) && !common.PathArguments[name] && !notLocalPaths[name] {
+				missing = append(missing, tool.Name+"."+name)
+			}
+
+			// Paths inside a JSON argument are only found when it is
+			// decoded, and then only under the names in PathArguments
+			description, _ := schema["description"].(string)
+			if !strings.Contains(description, "JSON") || common.PathArguments[name] || notLocalPaths[name] {
+				continue
+			}
+			if !common.JSONArguments[name] && !notPathJSON[name] {
+				undecoded = append(undecoded, tool.Name+"."+name)
+				continue
+			}
+			for _, match := range jsonExampleKey.FindAllStringSubmatch(description, -1) {
+				if key := match[1]; common.JSONArguments[name] && pathLikeArgument.MatchString(key) && !common.PathArguments[key] && !notLocalPaths[key] {
+					missing = append(missing, tool.Name+"."+name+"."+key)
+				}
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("path arguments missing from common.PathArguments: %v", missing)
+	}
+	sort.Strings(undecoded)
+	if len(undecoded) > 0 {
+		t.Errorf("JSON arguments in neither common.JSONArguments nor notPathJSON: %v", undecoded)
+	}
+}